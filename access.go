@@ -0,0 +1,126 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotAllowed is wrapped by the compile errors of the operators and selectors
+// which are not allowed by the CompileConfig, see AllowOperators
+var ErrNotAllowed = errors.New("not allowed")
+
+// accessList restricts the names which can be used in the expressions,
+// the denied names are not allowed, and only the allowed names are allowed if there are any
+type accessList struct {
+	allowed map[string]bool
+	denied  map[string]bool
+}
+
+func (l accessList) allows(name string) bool {
+	if l.denied[name] {
+		return false
+	}
+	return l.allowed == nil || l.allowed[name]
+}
+
+func (l accessList) copy() accessList {
+	return accessList{allowed: copyNameSet(l.allowed), denied: copyNameSet(l.denied)}
+}
+
+func copyNameSet(names map[string]bool) map[string]bool {
+	if names == nil {
+		return nil
+	}
+	res := make(map[string]bool, len(names))
+	for name := range names {
+		res[name] = true
+	}
+	return res
+}
+
+func addNames(names map[string]bool, added []string) map[string]bool {
+	if names == nil {
+		names = make(map[string]bool, len(added))
+	}
+	for _, name := range added {
+		names[name] = true
+	}
+	return names
+}
+
+// AllowOperators restricts the operators which can be called in the expressions to the allowed ones,
+// so that the untrusted expression authors can't call the others, e.g. the expensive or stateful ones.
+// It's enforced at compile time, including the builtin operators and the ones the syntax sugars compile to,
+// e.g. the dot-paths => field, the list literals => list, the unary minus => sub.
+// The allowed operators are accumulated by the calls.
+func (cc *CompileConfig) AllowOperators(names ...string) {
+	cc.operatorAccess.allowed = addNames(cc.operatorAccess.allowed, names)
+	cc.changed()
+}
+
+// DenyOperators forbids the operators in the expressions, it takes precedence over AllowOperators
+func (cc *CompileConfig) DenyOperators(names ...string) {
+	cc.operatorAccess.denied = addNames(cc.operatorAccess.denied, names)
+	cc.changed()
+}
+
+// AllowSelectors restricts the selectors which can be read by the expressions to the allowed ones.
+// The dot-paths are checked by their root selectors, e.g. user of user.name,
+// unless the full paths are registered as the selectors.
+// The allowed selectors are accumulated by the calls.
+func (cc *CompileConfig) AllowSelectors(names ...string) {
+	cc.selectorAccess.allowed = addNames(cc.selectorAccess.allowed, names)
+	cc.changed()
+}
+
+// DenySelectors forbids the selectors in the expressions, it takes precedence over AllowSelectors
+func (cc *CompileConfig) DenySelectors(names ...string) {
+	cc.selectorAccess.denied = addNames(cc.selectorAccess.denied, names)
+	cc.changed()
+}
+
+type accessChecker struct {
+	conf *CompileConfig
+	// errWithPos decorates the errors with the position in the source
+	errWithPos func(err error, pos int) error
+}
+
+func (p *parser) checkAccess(root *astNode) error {
+	return accessChecker{conf: p.conf, errWithPos: p.errWithPos}.check(root, nil)
+}
+
+// checkAccess checks the tree compiled from the public syntax tree, which has no source
+func checkAccess(conf *CompileConfig, root *astNode) error {
+	noPos := func(err error, _ int) error {
+		return err
+	}
+	return accessChecker{conf: conf, errWithPos: noPos}.check(root, nil)
+}
+
+// check checks the operators and selectors of the tree by the access lists of the config,
+// the params of the lambdas are not checked
+func (c accessChecker) check(root *astNode, bindings []string) error {
+	n := root.node
+	switch n.getNodeType() {
+	case operator, fastOperator:
+		if name, ok := n.value.(string); ok && !c.conf.operatorAccess.allows(name) {
+			return c.errWithPos(fmt.Errorf("operator %w: %s", ErrNotAllowed, name), root.pos)
+		}
+	case selector:
+		if name, ok := n.value.(string); ok && !isBound(bindings, name) && !c.conf.selectorAccess.allows(name) {
+			return c.errWithPos(fmt.Errorf("selector %w: %s", ErrNotAllowed, name), root.pos)
+		}
+	}
+
+	param, isLambda := lambdaParam(c.conf, root)
+	for i, child := range root.children {
+		childBindings := bindings
+		if isLambda && i == 2 {
+			childBindings = append(bindings[:len(bindings):len(bindings)], param)
+		}
+		if err := c.check(child, childBindings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
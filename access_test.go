@@ -0,0 +1,121 @@
+package eval
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/larry618/eval/ast"
+)
+
+func TestAccessList(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "US",
+		"ssn":     "123",
+		"user":    map[string]interface{}{"name": "larry"},
+		"tags":    []string{"a", "b"},
+	}
+	newConf := func() *CompileConfig {
+		cc := NewCompileConfig(RegisterSelKeys(vals))
+		assertNil(t, RegisterOperator(cc, "lookup", func(_ *Ctx, _ []Value) (Value, error) {
+			return true, nil
+		}))
+		return cc
+	}
+
+	testCases := []struct {
+		conf   func(cc *CompileConfig)
+		expr   string
+		errMsg string
+	}{
+		{
+			conf: func(cc *CompileConfig) {},
+			expr: `(and (> age 18) (lookup ssn))`,
+		},
+		{
+			conf: func(cc *CompileConfig) {
+				cc.AllowOperators("and", ">", "=")
+			},
+			expr: `(and (> age 18) (= country "US"))`,
+		},
+		{
+			conf: func(cc *CompileConfig) {
+				cc.AllowOperators("and", ">")
+				cc.AllowOperators("=")
+			},
+			expr:   `(and (> age 18) (lookup ssn))`,
+			errMsg: "operator not allowed: lookup",
+		},
+		{
+			conf: func(cc *CompileConfig) {
+				cc.AllowOperators("and", ">", "lookup")
+				cc.DenyOperators("lookup")
+			},
+			expr:   `(and (> age 18) (lookup ssn))`,
+			errMsg: "operator not allowed: lookup",
+		},
+		{
+			// the operators of the syntax sugars
+			conf: func(cc *CompileConfig) {
+				cc.DenyOperators("field")
+			},
+			expr:   `(= user.name "larry")`,
+			errMsg: "operator not allowed: field",
+		},
+		{
+			conf: func(cc *CompileConfig) {
+				cc.DenySelectors("ssn")
+			},
+			expr:   `(and (> age 18) (lookup ssn))`,
+			errMsg: "selector not allowed: ssn",
+		},
+		{
+			conf: func(cc *CompileConfig) {
+				cc.AllowSelectors("age", "user")
+			},
+			expr: `(and (> age 18) (= user.name "larry"))`,
+		},
+		{
+			conf: func(cc *CompileConfig) {
+				cc.AllowSelectors("age")
+			},
+			expr:   `(and (> age 18) (= country "US"))`,
+			errMsg: "selector not allowed: country",
+		},
+		{
+			// the params of the lambdas are not selectors
+			conf: func(cc *CompileConfig) {
+				cc.AllowSelectors("tags")
+			},
+			expr: `(any tags "t" (= t "a"))`,
+		},
+	}
+
+	for _, c := range testCases {
+		cc := newConf()
+		c.conf(cc)
+		_, err := Compile(cc, c.expr)
+		if c.errMsg == "" {
+			assertNil(t, err)
+			continue
+		}
+		assertErrStrContains(t, err, c.errMsg)
+		assertEquals(t, errors.Is(err, ErrNotAllowed), true)
+
+		// the copies of the config are restricted as well
+		_, err = Compile(CopyCompileConfig(cc), c.expr)
+		assertErrStrContains(t, err, c.errMsg)
+	}
+
+	// infix syntax and the public syntax tree
+	cc := newConf()
+	cc.DenyOperators("lookup")
+	EnableInfixSyntax(cc)
+	_, err := Compile(cc, `age > 18 && lookup(ssn)`)
+	assertErrStrContains(t, err, "operator not allowed: lookup")
+
+	_, err = CompileAST(cc, &ast.Node{Kind: ast.Operator, Value: "lookup", Children: []*ast.Node{
+		{Kind: ast.Selector, Value: "ssn"},
+	}})
+	assertErrStrContains(t, err, "operator not allowed: lookup")
+}
@@ -0,0 +1,184 @@
+// Package aggregate provides the stateful operators which aggregate the events across the evaluations
+// in the sliding time windows, e.g. "more than 3 logins in 10 minutes" of the fraud rules.
+// The events are kept in a pluggable Store by the keys computed from the ctx,
+// and the operators can be registered to a CompileConfig by Register.
+//
+//	store := aggregate.NewMemoryStore()
+//	if err := aggregate.Register(cc, store); err != nil {
+//		...
+//	}
+//	expr, err := eval.Compile(cc, `(> (countOverWindow (format "login:%v" userId) "10m") 3)`)
+//
+// The operators record an event on each execution, so they should not be skipped by the short circuit
+// of and/or if all the events are expected to be counted, e.g. put them before the other conditions.
+// The windows are the seconds or the duration strings, e.g. 600, "10m", "1h30m".
+package aggregate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/larry618/eval"
+)
+
+const (
+	typeStr    = "string"
+	typeNumber = "int64 or float64"
+	typeWindow = "int64 or duration string"
+)
+
+// Event is an event of a key recorded by the operators
+type Event struct {
+	Time  time.Time
+	Value float64
+}
+
+// Store keeps the events of the keys, it should be safe for concurrent use
+type Store interface {
+	// Add evicts the events of the key older than the window before e.Time, and then records e
+	// if the number of the remaining events is less than limit, which is unlimited if it is not positive.
+	// It returns the events of the key in the window, including e if it is added, and whether e is added,
+	// which should be done atomically, e.g. by a lua script of Redis.
+	Add(key string, e Event, window time.Duration, limit int) (events []Event, added bool, err error)
+}
+
+type options struct {
+	now func() time.Time
+}
+
+// Option configures the operators registered by Register
+type Option func(o *options)
+
+// WithClock replaces the clock of the events, it's mainly used in tests
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// Register registers the operators of the store to cc
+func Register(cc *eval.CompileConfig, store Store, opts ...Option) error {
+	for name, op := range Operators(store, opts...) {
+		if err := eval.RegisterOperator(cc, name, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Operators returns the operators of the store:
+//
+//	countOverWindow: (countOverWindow key window) records an event, and returns the number of the events in the window
+//	sumOverWindow: (sumOverWindow key value window) records the value, and returns the sum of the values in the window
+//	rateLimit: (rateLimit key n window) returns true and records an event if there are less than n events in the window,
+//	otherwise it returns false, so at most n evaluations are allowed in any window
+func Operators(store Store, opts ...Option) map[string]eval.Operator {
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	a := aggregator{store: store, now: o.now}
+	return map[string]eval.Operator{
+		"countOverWindow": a.countOverWindow,
+		"sumOverWindow":   a.sumOverWindow,
+		"rateLimit":       a.rateLimit,
+	}
+}
+
+type aggregator struct {
+	store Store
+	now   func() time.Time
+}
+
+func (a aggregator) countOverWindow(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "countOverWindow"
+	if len(params) != 2 {
+		return nil, eval.ParamsCountError(op, 2, len(params))
+	}
+	events, _, err := a.add(op, params[0], 1, params[1], 0)
+	if err != nil {
+		return nil, err
+	}
+	return int64(len(events)), nil
+}
+
+func (a aggregator) sumOverWindow(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "sumOverWindow"
+	if len(params) != 3 {
+		return nil, eval.ParamsCountError(op, 3, len(params))
+	}
+	var v float64
+	switch n := params[1].(type) {
+	case int64:
+		v = float64(n)
+	case float64:
+		v = n
+	default:
+		return nil, eval.ParamTypeError(op, typeNumber, params[1])
+	}
+
+	events, _, err := a.add(op, params[0], v, params[2], 0)
+	if err != nil {
+		return nil, err
+	}
+	var sum float64
+	for _, e := range events {
+		sum += e.Value
+	}
+	return sum, nil
+}
+
+func (a aggregator) rateLimit(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "rateLimit"
+	if len(params) != 3 {
+		return nil, eval.ParamsCountError(op, 3, len(params))
+	}
+	n, ok := params[1].(int64)
+	if !ok {
+		return nil, eval.ParamTypeError(op, "int64", params[1])
+	}
+	if n <= 0 {
+		return nil, eval.OpExecError(op, fmt.Errorf("limit should be positive, got: %d", n))
+	}
+	_, added, err := a.add(op, params[0], 1, params[2], int(n))
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+func (a aggregator) add(op string, key eval.Value, v float64, window eval.Value, limit int) ([]Event, bool, error) {
+	k, ok := key.(string)
+	if !ok {
+		return nil, false, eval.ParamTypeError(op, typeStr, key)
+	}
+	w, err := windowOf(op, window)
+	if err != nil {
+		return nil, false, err
+	}
+	events, added, err := a.store.Add(k, Event{Time: a.now(), Value: v}, w, limit)
+	if err != nil {
+		return nil, false, eval.OpExecError(op, err)
+	}
+	return events, added, nil
+}
+
+// windowOf returns the window of the seconds or the duration string, e.g. 600, "10m"
+func windowOf(op string, p eval.Value) (time.Duration, error) {
+	var w time.Duration
+	switch v := p.(type) {
+	case int64:
+		w = time.Duration(v) * time.Second
+	case string:
+		var err error
+		if w, err = time.ParseDuration(v); err != nil {
+			return 0, eval.OpExecError(op, err)
+		}
+	default:
+		return 0, eval.ParamTypeError(op, typeWindow, p)
+	}
+	if w <= 0 {
+		return 0, eval.OpExecError(op, fmt.Errorf("window should be positive, got: %v", p))
+	}
+	return w, nil
+}
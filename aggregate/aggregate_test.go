@@ -0,0 +1,142 @@
+package aggregate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/larry618/eval"
+)
+
+func TestOperators(t *testing.T) {
+	start := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	now := start
+	cc := eval.NewCompileConfig(eval.EnableStringSelectors)
+	if err := Register(cc, NewMemoryStore(), WithClock(func() time.Time { return now })); err != nil {
+		t.Fatal(err)
+	}
+
+	// the steps are evaluated in order, each one after the offset from the start
+	testCases := []struct {
+		offset time.Duration
+		expr   string
+		vals   map[string]interface{}
+		want   eval.Value
+		errMsg string
+	}{
+		// more than 3 logins of a user in 10 minutes
+		{offset: 0, expr: `(> (countOverWindow (format "login:%v" userId) "10m") 3)`, vals: map[string]interface{}{"userId": 1}, want: false},
+		{offset: time.Minute, expr: `(> (countOverWindow (format "login:%v" userId) "10m") 3)`, vals: map[string]interface{}{"userId": 1}, want: false},
+		{offset: 2 * time.Minute, expr: `(> (countOverWindow (format "login:%v" userId) "10m") 3)`, vals: map[string]interface{}{"userId": 2}, want: false},
+		{offset: 3 * time.Minute, expr: `(> (countOverWindow (format "login:%v" userId) "10m") 3)`, vals: map[string]interface{}{"userId": 1}, want: false},
+		{offset: 4 * time.Minute, expr: `(> (countOverWindow (format "login:%v" userId) "10m") 3)`, vals: map[string]interface{}{"userId": 1}, want: true},
+		// the first login is out of the window
+		{offset: 10 * time.Minute, expr: `(countOverWindow (format "login:%v" userId) 600)`, vals: map[string]interface{}{"userId": 1}, want: int64(4)},
+
+		{offset: 0, expr: `(sumOverWindow card amount "1h")`, vals: map[string]interface{}{"card": "c1", "amount": 100}, want: 100.0},
+		{offset: 30 * time.Minute, expr: `(sumOverWindow card amount "1h")`, vals: map[string]interface{}{"card": "c1", "amount": 50.5}, want: 150.5},
+		// the first amount is out of the window
+		{offset: 61 * time.Minute, expr: `(> (sumOverWindow card amount "1h") 1000)`, vals: map[string]interface{}{"card": "c1", "amount": 900}, want: false},
+		{offset: 62 * time.Minute, expr: `(> (sumOverWindow card amount "1h") 1000)`, vals: map[string]interface{}{"card": "c1", "amount": 100}, want: true},
+
+		// at most 2 requests in a second
+		{offset: 0, expr: `(rateLimit ip 2 1)`, vals: map[string]interface{}{"ip": "10.0.0.1"}, want: true},
+		{offset: 100 * time.Millisecond, expr: `(rateLimit ip 2 1)`, vals: map[string]interface{}{"ip": "10.0.0.1"}, want: true},
+		{offset: 200 * time.Millisecond, expr: `(rateLimit ip 2 1)`, vals: map[string]interface{}{"ip": "10.0.0.1"}, want: false},
+		{offset: 200 * time.Millisecond, expr: `(rateLimit ip 2 1)`, vals: map[string]interface{}{"ip": "10.0.0.2"}, want: true},
+		{offset: 1000 * time.Millisecond, expr: `(rateLimit ip 2 1)`, vals: map[string]interface{}{"ip": "10.0.0.1"}, want: true},
+		{offset: 1050 * time.Millisecond, expr: `(rateLimit ip 2 1)`, vals: map[string]interface{}{"ip": "10.0.0.1"}, want: false},
+		{offset: 1100 * time.Millisecond, expr: `(rateLimit ip 2 1)`, vals: map[string]interface{}{"ip": "10.0.0.1"}, want: true},
+
+		{expr: `(countOverWindow 1 "10m")`, errMsg: "unexpected param type"},
+		{expr: `(countOverWindow "k" 1.5)`, errMsg: "expected: int64 or duration string"},
+		{expr: `(countOverWindow "k" "10x")`, errMsg: `unknown unit "x"`},
+		{expr: `(countOverWindow "k" 0)`, errMsg: "window should be positive, got: 0"},
+		{expr: `(countOverWindow "k")`, errMsg: "unexpected params count"},
+		{expr: `(sumOverWindow "k" "1" "1h")`, errMsg: "expected: int64 or float64"},
+		{expr: `(rateLimit "k" 0 "1s")`, errMsg: "limit should be positive, got: 0"},
+		{expr: `(rateLimit "k" "2" "1s")`, errMsg: "expected: int64"},
+	}
+
+	for _, c := range testCases {
+		now = start.Add(c.offset)
+		got, err := eval.Eval(c.expr, c.vals, cc)
+		if len(c.errMsg) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Fatalf("unexpected error, expr: %s, got: %v, want: %s", c.expr, err, c.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, err: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected result, expr: %s, offset: %v, got: %v, want: %v", c.expr, c.offset, got, c.want)
+		}
+	}
+
+	// registering twice causes conflicts
+	if err := Register(cc, NewMemoryStore()); err == nil {
+		t.Fatal("operators should not be registered twice")
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Add(string, Event, time.Duration, int) ([]Event, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func TestStoreError(t *testing.T) {
+	cc := eval.NewCompileConfig()
+	if err := Register(cc, failingStore{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := eval.Eval(`(rateLimit "k" 1 "1s")`, nil, cc)
+	if err == nil || !strings.Contains(err.Error(), "operator: rateLimit, error: connection refused") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	start := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	// the concurrent adds are limited atomically
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	added := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok, _ := s.Add("k", Event{Time: start, Value: 1}, time.Minute, 10)
+			if ok {
+				mu.Lock()
+				added++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if added != 10 {
+		t.Fatalf("unexpected added, got: %d, want: %d", added, 10)
+	}
+
+	events, ok, _ := s.Add("other", Event{Time: start.Add(30 * time.Second), Value: 2}, time.Minute, 0)
+	if !ok || !reflect.DeepEqual(events, []Event{{Time: start.Add(30 * time.Second), Value: 2}}) {
+		t.Fatalf("unexpected events: %v", events)
+	}
+
+	s.Cleanup(start.Add(time.Minute))
+	if s.Len() != 1 {
+		t.Fatalf("unexpected keys, got: %d, want: %d", s.Len(), 1)
+	}
+	s.Cleanup(start.Add(2 * time.Minute))
+	if s.Len() != 0 {
+		t.Fatalf("unexpected keys, got: %d, want: %d", s.Len(), 0)
+	}
+}
@@ -0,0 +1,79 @@
+package aggregate
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store which keeps the events in memory, it's safe for concurrent use.
+// The events of a key are evicted when a new event of the key is added,
+// call Cleanup periodically to remove the keys which are not active any more.
+type MemoryStore struct {
+	mu     sync.Mutex
+	events map[string][]Event
+	// windows are the latest windows of the keys, which are used by Cleanup
+	windows map[string]time.Duration
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		events:  make(map[string][]Event),
+		windows: make(map[string]time.Duration),
+	}
+}
+
+func (s *MemoryStore) Add(key string, e Event, window time.Duration, limit int) ([]Event, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := evict(s.events[key], e.Time.Add(-window))
+	added := limit <= 0 || len(events) < limit
+	if added {
+		events = append(events, e)
+	}
+	s.events[key] = events
+	s.windows[key] = window
+
+	res := make([]Event, len(events))
+	copy(res, events)
+	return res, added, nil
+}
+
+// Cleanup removes the events which are out of their windows at now
+func (s *MemoryStore) Cleanup(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, events := range s.events {
+		events = evict(events, now.Add(-s.windows[key]))
+		if len(events) == 0 {
+			delete(s.events, key)
+			delete(s.windows, key)
+			continue
+		}
+		s.events[key] = events
+	}
+}
+
+// Len returns the number of the keys in the store
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+// evict removes the events not after the start of the window, the events are in time order
+func evict(events []Event, start time.Time) []Event {
+	i := 0
+	for i < len(events) && !events[i].Time.After(start) {
+		i++
+	}
+	if i == 0 {
+		return events
+	}
+	// the evicted events are not retained by the underlying array
+	return append(events[:0:0], events[i:]...)
+}
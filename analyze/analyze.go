@@ -0,0 +1,343 @@
+// Package analyze provides the static analysis of the boolean expressions,
+// e.g. detecting the duplicate and shadowed rules among the uploaded ones:
+//
+//	eq, err := analyze.Equivalent(a, b) // a and b match the same inputs
+//	sub, err := analyze.Subsumes(a, b)  // a matches all the inputs b matches, i.e. b is shadowed by a
+//	key, err := analyze.Normalize(rule) // the normal form of the rule, e.g. the key of the deduplication
+//
+// The expressions are canonicalized first, i.e. the operands of the commutative operators are sorted,
+// the nested and/or are flattened, and the comparisons are oriented with the constants on the right,
+// then their boolean structure is checked by a truth table over their predicates, which is pruned
+// once the result is decided. The comparisons of the same operand with the constants are decided
+// together, e.g. (> age 18) implies (> age 10), and (= country "US") excludes (= country "CA"),
+// the numbers are assumed to be real numbers. The other predicates are opaque, e.g. (hasTag "vip").
+//
+// The checks are sound but incomplete, i.e. true is proven, while false means that a counterexample
+// is found over the abstraction, which may be spurious if the predicates are related in the other ways,
+// e.g. (> age 18) and (>= age 19) are equivalent for the integers, but not proven.
+// The predicates are assumed to be bool, the nulls and the errors are not considered.
+package analyze
+
+import (
+	"fmt"
+
+	"github.com/larry618/eval"
+	"github.com/larry618/eval/ast"
+)
+
+// MaxPredicates is the maximum number of the distinct predicates of the checked expressions,
+// since the size of the truth table is exponential in it
+const MaxPredicates = 24
+
+// Equivalent reports whether the expressions match the same inputs
+func Equivalent(a, b *eval.Expr) (bool, error) {
+	return check(a, b, func(fa, fb truth) bool {
+		return fa != unknown && fb != unknown && fa != fb
+	}, func(fa, fb truth) bool {
+		return fa != unknown && fb != unknown
+	})
+}
+
+// Subsumes reports whether a matches all the inputs b matches, i.e. b implies a,
+// so b is shadowed by a if they are evaluated in order, e.g. (> age 18) subsumes (> age 30)
+func Subsumes(a, b *eval.Expr) (bool, error) {
+	return check(a, b, func(fa, fb truth) bool {
+		return fb == isTrue && fa == isFalse
+	}, func(fa, fb truth) bool {
+		return fb == isFalse || fa == isTrue
+	})
+}
+
+// check searches for a counterexample of the expressions, it's true if none is found.
+// found reports whether the partial results are a counterexample,
+// and pruned reports whether no counterexample can be found by assigning the rest predicates.
+func check(a, b *eval.Expr, found, pruned func(fa, fb truth) bool) (bool, error) {
+	var atoms atomTable
+	fa, err := atoms.formula(canonicalize(a.AST()))
+	if err != nil {
+		return false, err
+	}
+	fb, err := atoms.formula(canonicalize(b.AST()))
+	if err != nil {
+		return false, err
+	}
+	if len(atoms.atoms) > MaxPredicates {
+		return false, fmt.Errorf("too many predicates to analyze, predicates: %d, max: %d", len(atoms.atoms), MaxPredicates)
+	}
+
+	s := &search{
+		atoms:   atoms.atoms,
+		values:  make([]truth, len(atoms.atoms)),
+		found:   found,
+		pruned:  pruned,
+		fa:      fa,
+		fb:      fb,
+		byTerms: atoms.byTerms(),
+	}
+	return !s.counterexample(0), nil
+}
+
+// truth is the three-valued result of the formulas of the partial assignments
+type truth uint8
+
+const (
+	unknown truth = iota
+	isFalse
+	isTrue
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return isTrue
+	}
+	return isFalse
+}
+
+func (t truth) not() truth {
+	switch t {
+	case isTrue:
+		return isFalse
+	case isFalse:
+		return isTrue
+	}
+	return unknown
+}
+
+type formulaKind uint8
+
+const (
+	atomFormula formulaKind = iota
+	constFormula
+	andFormula
+	orFormula
+	notFormula
+	xorFormula
+)
+
+// formula is the boolean structure of an expression over its predicates
+type formula struct {
+	kind     formulaKind
+	atom     int
+	value    bool
+	children []*formula
+}
+
+func (f *formula) eval(values []truth) truth {
+	switch f.kind {
+	case atomFormula:
+		return values[f.atom]
+	case constFormula:
+		return truthOf(f.value)
+	case notFormula:
+		return f.children[0].eval(values).not()
+	case andFormula, orFormula:
+		// and is decided by any false operand, or is decided by any true operand
+		decisive := truthOf(f.kind == orFormula)
+		res := decisive.not()
+		for _, c := range f.children {
+			switch c.eval(values) {
+			case decisive:
+				return decisive
+			case unknown:
+				res = unknown
+			}
+		}
+		return res
+	case xorFormula:
+		res := false
+		for _, c := range f.children {
+			v := c.eval(values)
+			if v == unknown {
+				return unknown
+			}
+			res = res != (v == isTrue)
+		}
+		return truthOf(res)
+	}
+	return unknown
+}
+
+// atom is a predicate of the expressions, the comparisons of the terms with the constants
+// are decided by the theory, and the others are opaque
+type atom struct {
+	key  string
+	term string
+	cmp  *constraint
+}
+
+type atomTable struct {
+	atoms []atom
+	index map[string]int
+}
+
+func (t *atomTable) atom(n *ast.Node) *formula {
+	key := n.String()
+	if idx, exist := t.index[key]; exist {
+		return &formula{kind: atomFormula, atom: idx}
+	}
+	if t.index == nil {
+		t.index = make(map[string]int)
+	}
+
+	a := atom{key: key}
+	if op, isCmp := comparisonOps[fmt.Sprint(n.Value)]; isCmp && n.Kind == ast.Operator && len(n.Children) == 2 {
+		l, r := n.Children[0], n.Children[1]
+		if l.Kind != ast.Constant && r.Kind == ast.Constant && isTheoryValue(r.Value) {
+			a.term, a.cmp = l.String(), &constraint{op: op, val: r.Value}
+		}
+	}
+	t.index[key] = len(t.atoms)
+	t.atoms = append(t.atoms, a)
+	return &formula{kind: atomFormula, atom: t.index[key]}
+}
+
+// formula returns the boolean structure of the canonical tree
+func (t *atomTable) formula(n *ast.Node) (*formula, error) {
+	switch n.Kind {
+	case ast.Constant:
+		if b, ok := n.Value.(bool); ok {
+			return &formula{kind: constFormula, value: b}, nil
+		}
+		return nil, fmt.Errorf("analyze error, not a bool constant: %s", n)
+	case ast.Selector:
+		return t.atom(n), nil
+	case ast.Cond:
+		// (if c x y) => (or (and c x) (and (not c) y))
+		children, err := t.formulas(n.Children)
+		if err != nil {
+			return nil, err
+		}
+		notCond := &formula{kind: notFormula, children: children[:1]}
+		return &formula{kind: orFormula, children: []*formula{
+			{kind: andFormula, children: []*formula{children[0], children[1]}},
+			{kind: andFormula, children: []*formula{notCond, children[2]}},
+		}}, nil
+	}
+
+	kind, isLogic := map[string]formulaKind{
+		"and": andFormula, "or": orFormula, "xor": xorFormula, "not": notFormula, "!": notFormula,
+	}[fmt.Sprint(n.Value)]
+	if isLogic {
+		children, err := t.formulas(n.Children)
+		if err != nil {
+			return nil, err
+		}
+		return &formula{kind: kind, children: children}, nil
+	}
+
+	switch n.Value {
+	case "in", "not_in":
+		// (in x (1 2)) => (or (= x 1) (= x 2))
+		if len(n.Children) != 2 || n.Children[0].Kind == ast.Constant || n.Children[1].Kind != ast.Constant {
+			break
+		}
+		elems, ok := listOf(n.Children[1].Value)
+		if !ok {
+			break
+		}
+		f := &formula{kind: orFormula}
+		for _, elem := range elems {
+			eq := canonicalize(&ast.Node{Kind: ast.Operator, Value: "=", Children: []*ast.Node{
+				n.Children[0], {Kind: ast.Constant, Value: elem},
+			}})
+			f.children = append(f.children, t.atom(eq))
+		}
+		if n.Value == "not_in" {
+			f = &formula{kind: notFormula, children: []*formula{f}}
+		}
+		return f, nil
+	}
+	return t.atom(n), nil
+}
+
+func (t *atomTable) formulas(nodes []*ast.Node) ([]*formula, error) {
+	res := make([]*formula, len(nodes))
+	for i, n := range nodes {
+		f, err := t.formula(n)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = f
+	}
+	return res, nil
+}
+
+// byTerms returns the indexes of the atoms of the theory grouped by their terms
+func (t *atomTable) byTerms() map[string][]int {
+	res := make(map[string][]int)
+	for i, a := range t.atoms {
+		if a.cmp != nil {
+			res[a.term] = append(res[a.term], i)
+		}
+	}
+	return res
+}
+
+func listOf(v eval.Value) ([]eval.Value, bool) {
+	switch l := v.(type) {
+	case []int64:
+		res := make([]eval.Value, len(l))
+		for i, e := range l {
+			res[i] = e
+		}
+		return res, true
+	case []string:
+		res := make([]eval.Value, len(l))
+		for i, e := range l {
+			res[i] = e
+		}
+		return res, true
+	case []eval.Value:
+		return l, true
+	}
+	return nil, false
+}
+
+// search assigns the atoms in order, and prunes the partial assignments
+// which are infeasible or can't lead to a counterexample
+type search struct {
+	atoms   []atom
+	values  []truth
+	byTerms map[string][]int
+
+	found, pruned func(fa, fb truth) bool
+	fa, fb        *formula
+}
+
+func (s *search) counterexample(i int) bool {
+	fa, fb := s.fa.eval(s.values), s.fb.eval(s.values)
+	if s.found(fa, fb) {
+		return true
+	}
+	if s.pruned(fa, fb) || i == len(s.atoms) {
+		return false
+	}
+
+	for _, v := range []truth{isTrue, isFalse} {
+		s.values[i] = v
+		if s.consistent(i) && s.counterexample(i+1) {
+			return true
+		}
+	}
+	s.values[i] = unknown
+	return false
+}
+
+// consistent reports whether the assigned atoms of the term of the atom i are satisfiable
+func (s *search) consistent(i int) bool {
+	a := s.atoms[i]
+	if a.cmp == nil {
+		return true
+	}
+	var cs []constraint
+	for _, j := range s.byTerms[a.term] {
+		c := s.atoms[j].cmp
+		switch s.values[j] {
+		case isTrue:
+			cs = append(cs, *c)
+		case isFalse:
+			cs = append(cs, constraint{op: negatedOps[c.op], val: c.val})
+		}
+	}
+	return feasible(cs)
+}
@@ -0,0 +1,110 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval"
+)
+
+func compile(t *testing.T, expr string) *eval.Expr {
+	t.Helper()
+	cc := eval.NewCompileConfig(eval.EnableStringSelectors, eval.EnableInfixSyntax)
+	e, err := eval.Compile(cc, expr)
+	if err != nil {
+		t.Fatalf("compile error, expr: %s, error: %v", expr, err)
+	}
+	return e
+}
+
+func TestEquivalent(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want bool
+	}{
+		{a: `age > 18 && country == "US"`, b: `"US" == country && 18 < age`, want: true},
+		{a: `a && (b && c)`, b: `(c && a) && b`, want: true},
+		{a: `a && (b || c)`, b: `(a && b) || (a && c)`, want: true},
+		{a: `!(a || b)`, b: `!a && !b`, want: true},
+		{a: `a && a`, b: `a`, want: true},
+		{a: `a || (a && b)`, b: `a`, want: true},
+		{a: `country in ["US", "CA"]`, b: `country == "CA" || country == "US"`, want: true},
+		{a: `!(country in ["US", "CA"])`, b: `country != "CA" && country != "US"`, want: true},
+		{a: `age > 18 && age > 30`, b: `age > 30`, want: true},
+		{a: `age >= 18 || age < 18`, b: `true`, want: true},
+		{a: `country == "US" && country == "CA"`, b: `false`, want: true},
+		{a: `age > 10 && age < 5`, b: `false`, want: true},
+		{a: `(age >= 18 && age <= 18) && age != 18`, b: `false`, want: true},
+		{a: `age >= 18 && age <= 18`, b: `age == 18`, want: true},
+		{a: `vip ? age > 10 : age > 20`, b: `(vip && age > 10) || (!vip && age > 20)`, want: true},
+		{a: `matches(name, "^a") && age > 1`, b: `1 < age && matches(name, "^a")`, want: true},
+
+		{a: `age > 18`, b: `age > 30`, want: false},
+		{a: `a && b`, b: `a || b`, want: false},
+		{a: `country == "US"`, b: `country != "CA"`, want: false},
+		// the integers are not modeled
+		{a: `age > 18`, b: `age >= 19`, want: false},
+	}
+
+	for _, c := range testCases {
+		got, err := Equivalent(compile(t, c.a), compile(t, c.b))
+		if err != nil {
+			t.Fatalf("unexpected error, a: %s, b: %s, error: %v", c.a, c.b, err)
+		}
+		if got != c.want {
+			t.Fatalf("unexpected result, a: %s, b: %s, got: %v, want: %v", c.a, c.b, got, c.want)
+		}
+		if got, _ := Equivalent(compile(t, c.b), compile(t, c.a)); got != c.want {
+			t.Fatalf("not symmetric, a: %s, b: %s", c.a, c.b)
+		}
+	}
+}
+
+func TestSubsumes(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want bool
+	}{
+		{a: `age > 18`, b: `age > 30`, want: true},
+		{a: `age > 30`, b: `age > 18`, want: false},
+		{a: `age >= 18`, b: `age == 18`, want: true},
+		{a: `age > 18`, b: `age == 18`, want: false},
+		{a: `country != "CA"`, b: `country == "US"`, want: true},
+		{a: `country in ["US", "CA"]`, b: `country == "US" && age > 18`, want: true},
+		{a: `country in ["US", "CA"]`, b: `country == "UK"`, want: false},
+		{a: `a || b`, b: `a && c`, want: true},
+		{a: `a && c`, b: `a || b`, want: false},
+		{a: `vip || score > 80`, b: `score > 90 && region == "EU"`, want: true},
+		{a: `score > 80 && vip`, b: `score > 90`, want: false},
+		{a: `true`, b: `a`, want: true},
+		{a: `a`, b: `false`, want: true},
+	}
+
+	for _, c := range testCases {
+		got, err := Subsumes(compile(t, c.a), compile(t, c.b))
+		if err != nil {
+			t.Fatalf("unexpected error, a: %s, b: %s, error: %v", c.a, c.b, err)
+		}
+		if got != c.want {
+			t.Fatalf("unexpected result, a: %s, b: %s, got: %v, want: %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestTooManyPredicates(t *testing.T) {
+	preds := make([]string, MaxPredicates+1)
+	for i := range preds {
+		preds[i] = "p" + string(rune('a'+i))
+	}
+	a := compile(t, strings.Join(preds, " && "))
+	_, err := Equivalent(a, a)
+	if err == nil || !strings.Contains(err.Error(), "too many predicates to analyze") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the non-bool constants are not predicates
+	_, err = Subsumes(compile(t, `1 + 2`), a)
+	if err == nil || !strings.Contains(err.Error(), "not a bool constant: 3") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package analyze
+
+import (
+	"sort"
+
+	"github.com/larry618/eval/ast"
+)
+
+// commutativeOps are the operators whose operands can be sorted
+var commutativeOps = map[string]bool{
+	"and": true, "or": true, "xor": true,
+	"=": true, "eq": true, "!=": true, "ne": true,
+	"+": true, "add": true, "*": true, "mul": true,
+	"overlap": true,
+}
+
+// flattenedOps are the associative operators whose nested operands are flattened,
+// e.g. (and a (and b c)) => (and a b c)
+var flattenedOps = map[string]bool{
+	"and": true, "or": true,
+}
+
+// comparisonOps maps the comparison operators to their symbols
+var comparisonOps = map[string]string{
+	"=": "=", "eq": "=",
+	"!=": "!=", "ne": "!=",
+	"<": "<", "lt": "<",
+	"<=": "<=", "le": "<=",
+	">": ">", "gt": ">",
+	">=": ">=", "ge": ">=",
+}
+
+// flippedOps are the comparisons of the swapped operands, e.g. (< 18 age) => (> age 18)
+var flippedOps = map[string]string{
+	"=": "=", "!=": "!=",
+	"<": ">", "<=": ">=",
+	">": "<", ">=": "<=",
+}
+
+// canonicalize returns the canonical form of the tree, the operands of the commutative operators
+// are sorted, the nested and/or are flattened, and the comparisons are oriented with the constants
+// on the right, e.g. (and (< 18 age) (and (= "US" country) vip)) => (and (= country "US") (> age 18) vip)
+func canonicalize(n *ast.Node) *ast.Node {
+	if n.Kind != ast.Operator && n.Kind != ast.Cond {
+		return n
+	}
+
+	res := &ast.Node{Kind: n.Kind, Value: n.Value, Children: make([]*ast.Node, 0, len(n.Children))}
+	name, _ := n.Value.(string)
+	for _, child := range n.Children {
+		c := canonicalize(child)
+		if flattenedOps[name] && c.Kind == ast.Operator && c.Value == name {
+			res.Children = append(res.Children, c.Children...)
+			continue
+		}
+		res.Children = append(res.Children, c)
+	}
+	if n.Kind != ast.Operator {
+		return res
+	}
+
+	if op, isCmp := comparisonOps[name]; isCmp && len(res.Children) == 2 {
+		res.Value = op
+		l, r := res.Children[0], res.Children[1]
+		if (l.Kind == ast.Constant && r.Kind != ast.Constant) ||
+			(l.Kind != ast.Constant && r.Kind != ast.Constant && l.String() > r.String()) {
+			res.Value = flippedOps[op]
+			res.Children[0], res.Children[1] = r, l
+		}
+		return res
+	}
+
+	if commutativeOps[name] {
+		keys := make(map[*ast.Node]string, len(res.Children))
+		for _, c := range res.Children {
+			keys[c] = c.String()
+		}
+		sort.SliceStable(res.Children, func(i, j int) bool {
+			return keys[res.Children[i]] < keys[res.Children[j]]
+		})
+	}
+	return res
+}
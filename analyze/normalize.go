@@ -0,0 +1,165 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/larry618/eval"
+	"github.com/larry618/eval/ast"
+)
+
+// Normalize returns the normal form of the expression in the prefix notation, so that the textually
+// different but logically identical expressions are the same, e.g. they can be deduplicated by the hashes:
+//
+//	(and (< 18 age) (and (= "US" country) vip (gt age 18)))  => (and (= country "US") (> age 18) vip)
+//	country == "US" && age > 18.0 && true                   => (and (= country "US") (> age 18.0))
+//
+// The operands of the commutative operators are sorted, the nested and/or are flattened,
+// the identical predicates of and/or are de-duplicated, and the literals are normalized,
+// i.e. the comparisons are oriented with the constants on the right, the aliases of the comparisons
+// are replaced by the symbols, the double negations and the bool constants of and/or are eliminated,
+// the lists of in are sorted and de-duplicated, and the floats are always formatted with the fractions.
+// The expression is parsed with the options, e.g. eval.EnableInfixSyntax, the operators and selectors
+// are not required to be registered, and the result can be compiled in the prefix notation.
+func Normalize(expr string, opts ...eval.CompileOption) (string, error) {
+	root, err := eval.Parse(eval.NewCompileConfig(opts...), expr)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	format(&sb, normalize(root))
+	return sb.String(), nil
+}
+
+// normalize normalizes the tree bottom-up
+func normalize(n *ast.Node) *ast.Node {
+	if n.Kind != ast.Operator && n.Kind != ast.Cond {
+		return n
+	}
+	res := &ast.Node{Kind: n.Kind, Value: n.Value, Children: make([]*ast.Node, len(n.Children))}
+	for i, child := range n.Children {
+		res.Children[i] = normalize(child)
+	}
+	if n.Kind != ast.Operator {
+		return res
+	}
+
+	switch res.Value {
+	case "not", "!":
+		res.Value = "not"
+		// (not (not a)) => a
+		if c := res.Children; len(c) == 1 && c[0].Kind == ast.Operator && c[0].Value == "not" && len(c[0].Children) == 1 {
+			return c[0].Children[0]
+		}
+		return res
+	case "in", "not_in":
+		if len(res.Children) == 2 && res.Children[1].Kind == ast.Constant {
+			res.Children[1] = &ast.Node{Kind: ast.Constant, Value: normalizeSet(res.Children[1].Value)}
+		}
+		return res
+	}
+
+	res = canonicalize(res)
+	if flattenedOps[fmt.Sprint(res.Value)] {
+		return normalizeBoolOp(res)
+	}
+	return res
+}
+
+// normalizeBoolOp de-duplicates the sorted operands of and/or, and eliminates the bool constants,
+// e.g. (and a a true) => a, (or a true) => true
+func normalizeBoolOp(n *ast.Node) *ast.Node {
+	// and is decided by false, or is decided by true
+	decisive := n.Value == "or"
+
+	children := n.Children[:0:0]
+	var last string
+	for _, c := range n.Children {
+		if b, ok := c.Value.(bool); ok && c.Kind == ast.Constant {
+			if b == decisive {
+				return c
+			}
+			continue
+		}
+		if key := c.String(); len(children) == 0 || key != last {
+			children, last = append(children, c), key
+		}
+	}
+
+	switch len(children) {
+	case 0:
+		return &ast.Node{Kind: ast.Constant, Value: !decisive}
+	case 1:
+		return children[0]
+	}
+	n.Children = children
+	return n
+}
+
+// normalizeSet sorts and de-duplicates the constant list of in
+func normalizeSet(v eval.Value) eval.Value {
+	switch l := v.(type) {
+	case []int64:
+		res := append([]int64(nil), l...)
+		sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+		uniq := res[:0]
+		for i, e := range res {
+			if i == 0 || e != res[i-1] {
+				uniq = append(uniq, e)
+			}
+		}
+		return uniq
+	case []string:
+		res := append([]string(nil), l...)
+		sort.Strings(res)
+		uniq := res[:0]
+		for i, e := range res {
+			if i == 0 || e != res[i-1] {
+				uniq = append(uniq, e)
+			}
+		}
+		return uniq
+	}
+	return v
+}
+
+// format formats the tree in the prefix notation with the normalized literals
+func format(sb *strings.Builder, n *ast.Node) {
+	switch n.Kind {
+	case ast.Constant:
+		sb.WriteString(formatLiteral(n.Value))
+		return
+	case ast.Selector:
+		sb.WriteString(fmt.Sprint(n.Value))
+		return
+	}
+	sb.WriteString(fmt.Sprintf("(%v", n.Value))
+	for _, child := range n.Children {
+		sb.WriteByte(' ')
+		format(sb, child)
+	}
+	sb.WriteByte(')')
+}
+
+func formatLiteral(v eval.Value) string {
+	switch x := v.(type) {
+	case float64:
+		s := strconv.FormatFloat(x, 'f', -1, 64)
+		if !strings.ContainsAny(s, ".NI") {
+			// the integral floats are not formatted as the integers, e.g. 1.0
+			s += ".0"
+		}
+		return s
+	case []eval.Value:
+		elems := make([]string, len(x))
+		for i, e := range x {
+			elems[i] = formatLiteral(e)
+		}
+		return "(" + strings.Join(elems, " ") + ")"
+	}
+	// the same as the public syntax tree, e.g. "US", (1 2 3), null
+	return (&ast.Node{Kind: ast.Constant, Value: v}).String()
+}
@@ -0,0 +1,87 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/larry618/eval"
+)
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		opt    eval.CompileOption
+		expr   string
+		want   string
+		errMsg string
+	}{
+		{
+			expr: `(and (< 18 age) (and (= "US" country) vip (gt age 18)))`,
+			want: `(and (= country "US") (> age 18) vip)`,
+		},
+		{
+			opt:  eval.EnableInfixSyntax,
+			expr: `country == "US" && age > 18.0 && true`,
+			want: `(and (= country "US") (> age 18.0))`,
+		},
+		{
+			opt:  eval.EnableSQLSyntax,
+			expr: `age > 18 AND (country = 'US' OR country = 'US')`,
+			want: `(and (= country "US") (> age 18))`,
+		},
+		{
+			expr: `(or a (or b a) false)`,
+			want: `(or a b)`,
+		},
+		{
+			expr: `(or a (and b true) (not (not c)) true)`,
+			want: `true`,
+		},
+		{
+			expr: `(and a (or b false))`,
+			want: `(and a b)`,
+		},
+		{
+			expr: `(and (in country ("US" "CA" "US")) (not_in level (3 1 2 1)))`,
+			want: `(and (in country ("CA" "US")) (not_in level (1 2 3)))`,
+		},
+		{
+			// the operands of the non-commutative operators are kept in order
+			expr: `(and (> (- b a) 1.5) (= (+ b a) (* 2 c)))`,
+			want: `(and (= (* 2 c) (+ a b)) (> (- b a) 1.5))`,
+		},
+		{
+			// the operands are compared by their normal forms
+			expr: `(if (< b a) (< x 1) (! (= y 2)))`,
+			want: `(if (> a b) (< x 1) (not (= y 2)))`,
+		},
+		{
+			expr:   `(and a`,
+			errMsg: "error",
+		},
+	}
+
+	for _, c := range testCases {
+		var opts []eval.CompileOption
+		if c.opt != nil {
+			opts = append(opts, c.opt)
+		}
+		got, err := Normalize(c.expr, opts...)
+		if c.errMsg != "" {
+			if err == nil {
+				t.Fatalf("error expected, expr: %s", c.expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, error: %v", c.expr, err)
+		}
+		if got != c.want {
+			t.Fatalf("unexpected result, expr: %s, got: %s, want: %s", c.expr, got, c.want)
+		}
+
+		// the normal form is stable, and can be compiled again
+		again, err := Normalize(got)
+		if err != nil || again != got {
+			t.Fatalf("unstable normal form, expr: %s, got: %s, again: %s, error: %v", c.expr, got, again, err)
+		}
+	}
+}
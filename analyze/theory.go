@@ -0,0 +1,174 @@
+package analyze
+
+import (
+	"github.com/larry618/eval"
+)
+
+// negatedOps are the comparisons of the false atoms, e.g. !(< a 1) => (>= a 1)
+var negatedOps = map[string]string{
+	"=": "!=", "!=": "=",
+	"<": ">=", ">=": "<",
+	">": "<=", "<=": ">",
+}
+
+// constraint is a comparison of a term with a constant, e.g. (> age 18)
+type constraint struct {
+	op  string
+	val eval.Value
+}
+
+// bound is the lower or upper bound of the values of a term
+type bound struct {
+	val    eval.Value
+	strict bool
+	set    bool
+}
+
+// feasible reports whether there is a value of the term satisfying all the constraints,
+// the numbers are assumed to be real numbers, and the constraints of the different types
+// are independent except the equalities, since a value is of only one type
+func feasible(cs []constraint) bool {
+	var (
+		eq      eval.Value
+		hasEq   bool
+		lower   = make(map[string]bound)
+		upper   = make(map[string]bound)
+		neqVals []eval.Value
+	)
+
+	for _, c := range cs {
+		kind := kindOf(c.val)
+		switch c.op {
+		case "=":
+			if hasEq && compare(eq, c.val) != 0 {
+				return false
+			}
+			eq, hasEq = c.val, true
+		case "!=":
+			neqVals = append(neqVals, c.val)
+		case ">", ">=":
+			b := lower[kind]
+			if cmp := compare(c.val, b.val); !b.set || cmp > 0 || (cmp == 0 && c.op == ">") {
+				lower[kind] = bound{val: c.val, strict: c.op == ">", set: true}
+			}
+		case "<", "<=":
+			b := upper[kind]
+			if cmp := compare(c.val, b.val); !b.set || cmp < 0 || (cmp == 0 && c.op == "<") {
+				upper[kind] = bound{val: c.val, strict: c.op == "<", set: true}
+			}
+		}
+	}
+
+	if hasEq {
+		for _, v := range neqVals {
+			if compare(eq, v) == 0 {
+				return false
+			}
+		}
+		kind := kindOf(eq)
+		if lo := lower[kind]; lo.set && !lo.below(eq) {
+			return false
+		}
+		if hi := upper[kind]; hi.set && !hi.above(eq) {
+			return false
+		}
+		return true
+	}
+
+	for kind, lo := range lower {
+		hi := upper[kind]
+		if !hi.set {
+			continue
+		}
+		switch cmp := compare(lo.val, hi.val); {
+		case cmp > 0:
+			return false
+		case cmp == 0:
+			if lo.strict || hi.strict {
+				return false
+			}
+			// the only value of the range is excluded
+			for _, v := range neqVals {
+				if compare(lo.val, v) == 0 {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// below reports whether the lower bound is below v
+func (b bound) below(v eval.Value) bool {
+	cmp := compare(v, b.val)
+	return cmp > 0 || (cmp == 0 && !b.strict)
+}
+
+// above reports whether the upper bound is above v
+func (b bound) above(v eval.Value) bool {
+	cmp := compare(b.val, v)
+	return cmp > 0 || (cmp == 0 && !b.strict)
+}
+
+func kindOf(v eval.Value) string {
+	switch v.(type) {
+	case int64, float64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	}
+	return "unknown"
+}
+
+// isTheoryValue reports whether the constraints of the value are decided by the theory
+func isTheoryValue(v eval.Value) bool {
+	return kindOf(v) != "unknown"
+}
+
+// compare compares the values of the same kind, the values of the different kinds are ordered by the kinds
+func compare(a, b eval.Value) int {
+	ka, kb := kindOf(a), kindOf(b)
+	if ka != kb {
+		return compareStr(ka, kb)
+	}
+	switch x := a.(type) {
+	case string:
+		return compareStr(x, b.(string))
+	case bool:
+		y := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	}
+	x, y := toFloat(a), toFloat(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func compareStr(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v eval.Value) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
@@ -0,0 +1,188 @@
+package eval
+
+import (
+	"errors"
+	"math"
+)
+
+// ArithmeticPolicy decides the results of the integer divide-by-zero and overflow
+// in the builtin arithmetic operators. The float64 arithmetic is not affected.
+type ArithmeticPolicy int
+
+const (
+	// ArithmeticDefault fails on divide-by-zero, and the overflowed results wrap around
+	ArithmeticDefault ArithmeticPolicy = iota
+	// ArithmeticError fails on both divide-by-zero and overflow
+	ArithmeticError
+	// ArithmeticSentinel returns CompileConfig.ArithmeticSentinel on divide-by-zero and overflow
+	ArithmeticSentinel
+	// ArithmeticSaturate clamps the overflowed results to math.MaxInt64 or math.MinInt64,
+	// x/0 is saturated by the sign of x, and both 0/0 and x%0 are 0
+	ArithmeticSaturate
+)
+
+var (
+	errDivideByZero = errors.New("divide by zero")
+	errIntOverflow  = errors.New("integer overflow")
+
+	// arithmeticModes are the modes of the arithmetic operators affected by the ArithmeticPolicy
+	arithmeticModes = map[string]mode{
+		"add": add, "sub": sub, "mul": mul, "div": div, "mod": mod,
+		"+": add, "-": sub, "*": mul, "/": div, "%": mod,
+	}
+
+	// intDivisionModes are the modes of the integer division operators affected by the ArithmeticPolicy
+	intDivisionModes = map[string]mode{
+		"truncdiv": truncDiv, "floordiv": floorDiv, "floormod": floorMod,
+	}
+)
+
+// executeChecked applies the mode to x and y, the divide-by-zero and overflow are
+// handled by the policy, stop is true if the sentinel or the error is returned
+func (a arithmetic) executeChecked(x, y int64) (res Value, stop bool, err error) {
+	r, err := checkedInt(a.mode, x, y)
+	switch {
+	case err == nil:
+		return r, false, nil
+	case err != errDivideByZero && err != errIntOverflow:
+		return nil, true, err
+	}
+	switch a.policy {
+	case ArithmeticSentinel:
+		return a.sentinel, true, nil
+	case ArithmeticSaturate:
+		return r, false, nil
+	}
+	return nil, true, OpExecError(modeNames[a.mode], err)
+}
+
+// checkedInt returns the error if x op y divides by zero or overflows,
+// along with the saturated result
+func checkedInt(m mode, x, y int64) (int64, error) {
+	switch m {
+	case add:
+		switch {
+		case y > 0 && x > math.MaxInt64-y:
+			return math.MaxInt64, errIntOverflow
+		case y < 0 && x < math.MinInt64-y:
+			return math.MinInt64, errIntOverflow
+		}
+		return x + y, nil
+	case sub:
+		switch {
+		case y < 0 && x > math.MaxInt64+y:
+			return math.MaxInt64, errIntOverflow
+		case y > 0 && x < math.MinInt64+y:
+			return math.MinInt64, errIntOverflow
+		}
+		return x - y, nil
+	case mul:
+		if x == 0 || y == 0 {
+			return 0, nil
+		}
+		r := x * y
+		if r/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+			if (x < 0) == (y < 0) {
+				return math.MaxInt64, errIntOverflow
+			}
+			return math.MinInt64, errIntOverflow
+		}
+		return r, nil
+	case div:
+		switch {
+		case y == 0:
+			return saturateSign(x), errDivideByZero
+		case x == math.MinInt64 && y == -1:
+			return math.MaxInt64, errIntOverflow
+		}
+		return x / y, nil
+	case mod:
+		if y == 0 {
+			return 0, errDivideByZero
+		}
+		return x % y, nil
+	}
+	return 0, errInvalidMode(m, "arithmetic")
+}
+
+func saturateSign(x int64) int64 {
+	switch {
+	case x > 0:
+		return math.MaxInt64
+	case x < 0:
+		return math.MinInt64
+	}
+	return 0
+}
+
+// intDivision divides the int64 params with the defined rounding of the negative operands:
+//   - truncdiv: the quotient is truncated toward zero, e.g. (truncdiv -7 2) => -3, the same as div
+//   - floordiv: the quotient is rounded toward negative infinity, e.g. (floordiv -7 2) => -4
+//   - floormod: the remainder of floordiv, which has the sign of the divisor, e.g. (floormod -7 2) => 1
+//
+// The remainder of mod is the one of truncdiv, which has the sign of the dividend, e.g. (mod -7 2) => -1.
+// The divide-by-zero and the overflow of (floordiv math.MinInt64 -1) are handled by the policy.
+type intDivision struct {
+	mode     mode
+	policy   ArithmeticPolicy
+	sentinel Value
+}
+
+func (d intDivision) execute(_ *Ctx, params []Value) (Value, error) {
+	if len(params) != 2 {
+		return nil, errCnt2(d.mode, params)
+	}
+	x, ok := params[0].(int64)
+	if !ok {
+		return nil, errTypeInt(d.mode, params[0])
+	}
+	y, ok := params[1].(int64)
+	if !ok {
+		return nil, errTypeInt(d.mode, params[1])
+	}
+
+	var (
+		// the saturated result of the divide-by-zero or overflow
+		saturated int64
+		err       error
+	)
+	switch {
+	case y == 0:
+		err = errDivideByZero
+		if d.mode != floorMod {
+			saturated = saturateSign(x)
+		}
+	case x == math.MinInt64 && y == -1 && d.mode != floorMod:
+		err, saturated = errIntOverflow, math.MaxInt64
+	}
+	if err != nil {
+		switch {
+		case d.policy == ArithmeticSentinel:
+			return d.sentinel, nil
+		case d.policy == ArithmeticSaturate:
+			return saturated, nil
+		case d.policy == ArithmeticError || err == errDivideByZero:
+			return nil, OpExecError(modeNames[d.mode], err)
+		}
+		// the overflowed result wraps around by default
+	}
+
+	q, r := x/y, x%y
+	// the quotient and remainder are adjusted if they are rounded toward the opposite of the floor
+	needsFloor := r != 0 && (r < 0) != (y < 0)
+	switch d.mode {
+	case truncDiv:
+		return q, nil
+	case floorDiv:
+		if needsFloor {
+			q--
+		}
+		return q, nil
+	case floorMod:
+		if needsFloor {
+			r += y
+		}
+		return r, nil
+	}
+	return nil, errInvalidMode(d.mode, "integer division")
+}
@@ -0,0 +1,130 @@
+package eval
+
+import (
+	"math"
+	"testing"
+)
+
+func TestArithmeticPolicy(t *testing.T) {
+	vals := map[string]interface{}{
+		"max":  int64(math.MaxInt64),
+		"min":  int64(math.MinInt64),
+		"zero": 0,
+		"x":    10,
+	}
+
+	testCases := []struct {
+		expr    string
+		policy  ArithmeticPolicy
+		results []Value // of ArithmeticDefault, ArithmeticSentinel and ArithmeticSaturate
+		errMsg  string  // of ArithmeticError if the results overflow
+	}{
+		{expr: `(+ x 1)`, results: []Value{int64(11), int64(11), int64(11)}},
+		{expr: `(+ max 1)`, results: []Value{int64(math.MinInt64), int64(-1), int64(math.MaxInt64)}, errMsg: "integer overflow"},
+		{expr: `(- min 1)`, results: []Value{int64(math.MaxInt64), int64(-1), int64(math.MinInt64)}, errMsg: "integer overflow"},
+		{expr: `(* max 2)`, results: []Value{int64(-2), int64(-1), int64(math.MaxInt64)}, errMsg: "integer overflow"},
+		{expr: `(* min -1)`, results: []Value{int64(math.MinInt64), int64(-1), int64(math.MaxInt64)}, errMsg: "integer overflow"},
+		{expr: `(* max -2)`, results: []Value{int64(2), int64(-1), int64(math.MinInt64)}, errMsg: "integer overflow"},
+		{expr: `(/ min -1)`, results: []Value{int64(math.MinInt64), int64(-1), int64(math.MaxInt64)}, errMsg: "integer overflow"},
+		{expr: `(/ x zero)`, results: []Value{nil, int64(-1), int64(math.MaxInt64)}, errMsg: "divide by zero"},
+		{expr: `(/ (- 0 x) zero)`, results: []Value{nil, int64(-1), int64(math.MinInt64)}, errMsg: "divide by zero"},
+		{expr: `(/ zero zero)`, results: []Value{nil, int64(-1), int64(0)}, errMsg: "divide by zero"},
+		{expr: `(% x zero)`, results: []Value{nil, int64(-1), int64(0)}, errMsg: "divide by zero"},
+		{expr: `(+ max 1 -1)`, results: []Value{int64(math.MaxInt64), int64(-1), int64(math.MaxInt64 - 1)}, errMsg: "integer overflow"},
+		{expr: `(/ 1.0 0.0)`, errMsg: "divide by zero"},
+		{expr: `(floordiv x zero)`, results: []Value{nil, int64(-1), int64(math.MaxInt64)}, errMsg: "divide by zero"},
+		{expr: `(floordiv min -1)`, results: []Value{int64(math.MinInt64), int64(-1), int64(math.MaxInt64)}, errMsg: "integer overflow"},
+		{expr: `(truncdiv (- 0 x) zero)`, results: []Value{nil, int64(-1), int64(math.MinInt64)}, errMsg: "divide by zero"},
+		{expr: `(floormod x zero)`, results: []Value{nil, int64(-1), int64(0)}, errMsg: "divide by zero"},
+		{expr: `(floormod min -1)`, results: []Value{int64(0), int64(0), int64(0)}},
+	}
+
+	strictNumeric := func(c *CompileConfig) {
+		c.CompileOptions[StrictNumeric] = true
+	}
+	policies := []struct {
+		policy ArithmeticPolicy
+		opts   []CompileOption
+	}{
+		{policy: ArithmeticDefault},
+		{policy: ArithmeticSentinel, opts: []CompileOption{ReturnOnArithmeticError(int64(-1))}},
+		{policy: ArithmeticSaturate, opts: []CompileOption{OnArithmeticError(ArithmeticSaturate)}},
+		{policy: ArithmeticError, opts: []CompileOption{OnArithmeticError(ArithmeticError)}},
+	}
+
+	for _, c := range testCases {
+		for i, p := range policies {
+			for _, opts := range [][]CompileOption{nil, {Optimizations(false)}, {strictNumeric}} {
+				cc := NewCompileConfig(append(append(opts, p.opts...), EnableStringSelectors)...)
+				res, err := Eval(c.expr, vals, cc)
+
+				switch {
+				case p.policy == ArithmeticError && c.errMsg != "",
+					c.results == nil,
+					p.policy == ArithmeticDefault && c.results[i] == nil:
+					assertErrStrContains(t, err, c.errMsg, c.expr, p.policy)
+				case p.policy == ArithmeticError:
+					assertNil(t, err, c.expr, p.policy)
+					assertEquals(t, res, c.results[0], c.expr, p.policy)
+				default:
+					assertNil(t, err, c.expr, p.policy)
+					assertEquals(t, res, c.results[i], c.expr, p.policy)
+				}
+			}
+		}
+	}
+}
+
+func TestIntDivision(t *testing.T) {
+	vals := map[string]interface{}{
+		"userId": -1234567,
+		"x":      7,
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(truncdiv 7 2)`, res: int64(3)},
+		{expr: `(truncdiv -7 2)`, res: int64(-3)},
+		{expr: `(floordiv 7 2)`, res: int64(3)},
+		{expr: `(floordiv -7 2)`, res: int64(-4)},
+		{expr: `(floordiv 7 -2)`, res: int64(-4)},
+		{expr: `(floordiv -7 -2)`, res: int64(3)},
+		{expr: `(floordiv -8 2)`, res: int64(-4)},
+		{expr: `(mod -7 2)`, res: int64(-1)},
+		{expr: `(% 7 -2)`, res: int64(1)},
+		{expr: `(floormod -7 2)`, res: int64(1)},
+		{expr: `(floormod 7 -2)`, res: int64(-1)},
+		{expr: `(floormod -7 -2)`, res: int64(-1)},
+		{expr: `(floormod -8 2)`, res: int64(0)},
+		{expr: `(< (floormod userId 100) 5)`, res: false},
+		{expr: `(= (floormod userId 100) 33)`, res: true},
+		{expr: `(= (% userId 100) -67)`, res: true},
+		{expr: `userId % 100 < 5`, opts: []CompileOption{EnableInfixSyntax}, res: true},
+		{expr: `floormod(userId, 100) < 5`, opts: []CompileOption{EnableInfixSyntax}, res: false},
+		{expr: `(floordiv x 2)`, opts: []CompileOption{EnableTypeCheck}, res: int64(3)},
+
+		{expr: `(floordiv x 2.0)`, errMsg: "expected: int64"},
+		{expr: `(floordiv x 2.0)`, opts: []CompileOption{EnableTypeCheck}, errMsg: "floordiv param 1 should be int64, got: float64"},
+		{expr: `(floormod x)`, errMsg: "operator: floormod, expected: 2, got: 1"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+
+	// the constant params are folded at compile time
+	expr, err := Compile(NewCompileConfig(RegisterSelKeys(vals)), `(< (floormod userId 100) (floordiv -9 2))`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(< (floormod userId 100) -5)`)
+}
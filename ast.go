@@ -0,0 +1,194 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/larry618/eval/ast"
+)
+
+// Parse parses the expression into the public syntax tree.
+// The operators and selectors are not required to be registered,
+// they are resolved when the tree is compiled by CompileAST.
+func Parse(cc *CompileConfig, exprStr string) (*ast.Node, error) {
+	p := newParser(cc, exprStr)
+	p.deferResolving = true
+	root, _, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return toPublicAst(root), nil
+}
+
+// CompileAST compiles the public syntax tree into an Expr,
+// the types of the tree are checked if TypeCheck is enabled
+func CompileAST(cc *CompileConfig, root *ast.Node) (*Expr, error) {
+	conf := CopyCompileConfig(cc)
+	for _, rewrite := range conf.Rewriters {
+		var err error
+		root, err = ast.Rewrite(root, rewrite)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tree, err := fromPublicAst(conf, root, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = conf.Limits.checkTree(tree); err != nil {
+		return nil, err
+	}
+	if err = checkAccess(conf, tree); err != nil {
+		return nil, err
+	}
+	markShortCircuits(conf, tree)
+	if conf.CompileOptions[TypeCheck] {
+		if _, err = checkTypes(conf, tree); err != nil {
+			return nil, err
+		}
+	}
+	return compileAstTree(conf, tree)
+}
+
+// AST returns the public syntax tree of the compiled expression,
+// the optimizations like the constant folding and the reordering have been applied to it.
+func (e *Expr) AST() *ast.Node {
+	return toPublicAst(e.toAstTree(e.getNode(0), nil))
+}
+
+func rewriteAstTree(conf *CompileConfig, root *astNode) (*astNode, error) {
+	pub := toPublicAst(root)
+	for _, rewrite := range conf.Rewriters {
+		var err error
+		pub, err = ast.Rewrite(pub, rewrite)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fromPublicAst(conf, pub, nil)
+}
+
+func publicKind(n *node) ast.Kind {
+	switch n.getNodeType() {
+	case constant:
+		return ast.Constant
+	case selector:
+		return ast.Selector
+	case cond:
+		return ast.Cond
+	}
+	return ast.Operator
+}
+
+func toPublicAst(root *astNode) *ast.Node {
+	n := &ast.Node{
+		Kind:  publicKind(root.node),
+		Value: root.node.value,
+	}
+
+	for _, child := range root.children {
+		if child.node.getNodeType() == end {
+			continue
+		}
+		n.Children = append(n.Children, toPublicAst(child))
+	}
+	return n
+}
+
+// fromPublicAst converts the public syntax tree, the bindings are the params of the enclosing lambdas
+func fromPublicAst(conf *CompileConfig, root *ast.Node, bindings []string) (*astNode, error) {
+	if root == nil {
+		return nil, fmt.Errorf("invalid ast error, node is nil")
+	}
+
+	children := make([]*astNode, 0, len(root.Children)+1)
+	for i, child := range root.Children {
+		scope := bindings
+		if param, ok := publicLambdaParam(conf, root); ok && i == 2 {
+			scope = append(bindings[:len(bindings):len(bindings)], param)
+		}
+		c, err := fromPublicAst(conf, child, scope)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, c)
+	}
+
+	switch root.Kind {
+	case ast.Constant:
+		return &astNode{
+			node: &node{
+				flag:  constant,
+				value: unifyType(root.Value),
+			},
+		}, nil
+	case ast.Selector:
+		name, ok := root.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid ast error, selector name should be string, got: %v", root.Value)
+		}
+		key, exist := conf.SelectorMap[name]
+		if isBound(bindings, name) {
+			key, exist = UndefinedSelKey, true
+		}
+		if !exist {
+			if !conf.CompileOptions[AllowUnknownSelectors] {
+				return nil, fmt.Errorf("unknown token error, selector: %s", name)
+			}
+			key = UndefinedSelKey
+		}
+		return &astNode{
+			node: &node{
+				flag:   selector,
+				value:  name,
+				selKey: key,
+			},
+		}, nil
+	}
+
+	name, ok := root.Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid ast error, operator name should be string, got: %v", root.Value)
+	}
+
+	if root.Kind == ast.Cond || name == "if" {
+		if len(children) != 3 {
+			return nil, fmt.Errorf("if parameters count error (want: %d, got: %d)", 3, len(children))
+		}
+		// append an end node
+		children = append(children, &astNode{
+			node: &node{
+				flag:  end,
+				value: "end",
+			},
+		})
+		return &astNode{
+			node: &node{
+				flag:  cond,
+				value: "if",
+			},
+			children: children,
+		}, nil
+	}
+
+	if root.Kind != ast.Operator {
+		return nil, fmt.Errorf("invalid ast error, unknown node kind: %v", root.Kind)
+	}
+
+	if err := conf.checkArity(name, len(children)); err != nil {
+		return nil, err
+	}
+
+	op, exist := conf.getOperator(name)
+	if !exist {
+		return nil, fmt.Errorf("unknown token error, operator: %s", name)
+	}
+	return &astNode{
+		node: &node{
+			flag:     operator,
+			value:    name,
+			operator: op,
+		},
+		children: children,
+	}, nil
+}
@@ -0,0 +1,170 @@
+// Package ast defines the public abstract syntax tree of the expressions,
+// it can be inspected and rewritten before the bytecode is generated.
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Constant Kind = iota + 1 // the value of a constant, e.g. 1, "a", true, (1 2 3)
+	Selector                 // the name of a selector, e.g. age
+	Operator                 // the name of an operator, e.g. and, +, between
+	Cond                     // the if expression, its children are the condition and the two branches
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Constant:
+		return "constant"
+	case Selector:
+		return "selector"
+	case Operator:
+		return "operator"
+	case Cond:
+		return "cond"
+	}
+	return "unknown"
+}
+
+// Node is a node of the syntax tree
+type Node struct {
+	Kind Kind
+	// Value is the value of a constant node,
+	// the name of a selector node or an operator node,
+	// and "if" for a cond node.
+	Value    interface{}
+	Children []*Node
+}
+
+// String formats the tree in the prefix notation, which can be compiled again
+func (n *Node) String() string {
+	var sb strings.Builder
+	n.format(&sb)
+	return sb.String()
+}
+
+func (n *Node) format(sb *strings.Builder) {
+	switch n.Kind {
+	case Constant:
+		sb.WriteString(formatValue(n.Value))
+		return
+	case Selector:
+		sb.WriteString(fmt.Sprint(n.Value))
+		return
+	}
+	sb.WriteString(fmt.Sprintf("(%v", n.Value))
+	for _, child := range n.Children {
+		sb.WriteRune(' ')
+		child.format(sb)
+	}
+	sb.WriteRune(')')
+}
+
+func formatValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return "null"
+	case string:
+		return QuoteString(v)
+	case float64:
+		return FormatFloat(v)
+	case []string:
+		strs := make([]string, len(v))
+		for i, s := range v {
+			strs[i] = QuoteString(s)
+		}
+		return "(" + strings.Join(strs, " ") + ")"
+	case []int64:
+		strs := make([]string, len(v))
+		for i, n := range v {
+			strs[i] = strconv.FormatInt(n, 10)
+		}
+		return "(" + strings.Join(strs, " ") + ")"
+	case []interface{}:
+		strs := make([]string, len(v))
+		for i, e := range v {
+			strs[i] = " " + formatValue(e)
+		}
+		return "(list" + strings.Join(strs, "") + ")"
+	}
+	return fmt.Sprint(val)
+}
+
+// QuoteString quotes the string with a delimiter which doesn't appear in it, since there are no escapes
+// in the string literals, e.g. say "hi" => `say "hi"`. The strings containing both the double quotes and
+// the backticks are built by the format operator, e.g. (format "%s`%s" `"` `"`)
+func QuoteString(s string) string {
+	switch {
+	case !strings.Contains(s, `"`):
+		return `"` + s + `"`
+	case !strings.Contains(s, "`") && !strings.Contains(s, "${"):
+		// the template without the placeholders is a plain string
+		return "`" + s + "`"
+	}
+	format := strings.ReplaceAll(strings.ReplaceAll(s, "%", "%%"), `"`, "%s")
+	return `(format "` + format + `"` + strings.Repeat(" `\"`", strings.Count(s, `"`)) + ")"
+}
+
+// FormatFloat formats the float literal, which is kept a float literal if it's integral, e.g. 1.0
+func FormatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(n *Node) (w Visitor)
+}
+
+// Walk traverses the tree in depth-first order
+func Walk(v Visitor, n *Node) {
+	if v = v.Visit(n); v == nil {
+		return
+	}
+	for _, child := range n.Children {
+		Walk(v, child)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(*Node) bool
+
+func (f inspector) Visit(n *Node) Visitor {
+	if n != nil && f(n) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree in depth-first order,
+// the children of n are skipped if f(n) returns false.
+func Inspect(n *Node, f func(*Node) bool) {
+	Walk(inspector(f), n)
+}
+
+// RewriteFunc returns the node which replaces n,
+// returning n itself means no replacement.
+type RewriteFunc func(n *Node) (*Node, error)
+
+// Rewrite rewrites the tree bottom-up, the children are rewritten
+// before their parent, and it returns the new root of the tree.
+func Rewrite(n *Node, fn RewriteFunc) (*Node, error) {
+	for i, child := range n.Children {
+		res, err := Rewrite(child, fn)
+		if err != nil {
+			return nil, err
+		}
+		n.Children[i] = res
+	}
+	return fn(n)
+}
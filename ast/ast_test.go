@@ -0,0 +1,114 @@
+package ast
+
+import (
+	"errors"
+	"testing"
+)
+
+func tree() *Node {
+	return &Node{
+		Kind:  Operator,
+		Value: "and",
+		Children: []*Node{
+			{
+				Kind:  Operator,
+				Value: ">",
+				Children: []*Node{
+					{Kind: Selector, Value: "age"},
+					{Kind: Constant, Value: int64(18)},
+				},
+			},
+			{
+				Kind:  Cond,
+				Value: "if",
+				Children: []*Node{
+					{Kind: Selector, Value: "vip"},
+					{Kind: Constant, Value: true},
+					{
+						Kind:  Operator,
+						Value: "in",
+						Children: []*Node{
+							{Kind: Selector, Value: "country"},
+							{Kind: Constant, Value: []string{"US", "CA"}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestString(t *testing.T) {
+	got := tree().String()
+	want := `(and (> age 18) (if vip true (in country ("US" "CA"))))`
+	if got != want {
+		t.Fatalf("got: %s, want: %s", got, want)
+	}
+}
+
+type counter struct {
+	kinds map[Kind]int
+	nils  int
+}
+
+func (c *counter) Visit(n *Node) Visitor {
+	if n == nil {
+		c.nils++
+		return nil
+	}
+	c.kinds[n.Kind]++
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	c := &counter{kinds: make(map[Kind]int)}
+	Walk(c, tree())
+	if c.kinds[Operator] != 3 || c.kinds[Selector] != 3 || c.kinds[Constant] != 3 || c.kinds[Cond] != 1 {
+		t.Fatalf("unexpected node count: %v", c.kinds)
+	}
+	// a nil node is visited after the children of each node
+	if c.nils != 10 {
+		t.Fatalf("unexpected nil count: %d", c.nils)
+	}
+
+	var selectors []interface{}
+	Inspect(tree(), func(n *Node) bool {
+		if n.Kind == Cond {
+			return false // skip the if expression
+		}
+		if n.Kind == Selector {
+			selectors = append(selectors, n.Value)
+		}
+		return true
+	})
+	if len(selectors) != 1 || selectors[0] != "age" {
+		t.Fatalf("unexpected selectors: %v", selectors)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	// replace the selector vip with a constant
+	root, err := Rewrite(tree(), func(n *Node) (*Node, error) {
+		if n.Kind == Selector && n.Value == "vip" {
+			return &Node{Kind: Constant, Value: false}, nil
+		}
+		return n, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `(and (> age 18) (if false true (in country ("US" "CA"))))`
+	if got := root.String(); got != want {
+		t.Fatalf("got: %s, want: %s", got, want)
+	}
+
+	_, err = Rewrite(tree(), func(n *Node) (*Node, error) {
+		if n.Kind == Cond {
+			return nil, errors.New("if is not allowed")
+		}
+		return n, nil
+	})
+	if err == nil || err.Error() != "if is not allowed" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package eval
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/larry618/eval/ast"
+)
+
+func TestParse(t *testing.T) {
+	// operators and selectors are not required to be registered
+	root, err := Parse(nil, `(and (adult age) (if vip true (in country ("US" "CA"))))`)
+	assertNil(t, err)
+	assertEquals(t, root.String(), `(and (adult age) (if vip true (in country ("US" "CA"))))`)
+	assertEquals(t, root.Kind, ast.Operator)
+	assertEquals(t, root.Children[1].Kind, ast.Cond)
+	assertEquals(t, len(root.Children[1].Children), 3)
+
+	root, err = Parse(NewCompileConfig(EnableInfixSyntax), `age >= 18 && -score < 10`)
+	assertNil(t, err)
+	assertEquals(t, root.String(), `(and (ge age 18) (lt (sub 0 score) 10))`)
+
+	_, err = Parse(nil, `(and (adult age)`)
+	assertErrStrContains(t, err, "parentheses unmatched error")
+}
+
+func TestParse_StringRoundTrip(t *testing.T) {
+	cc := NewCompileConfig()
+	testCases := []struct {
+		root *ast.Node
+		want Value
+	}{
+		{root: constantNode(2.0), want: 2.0},
+		{root: constantNode(1e21), want: 1e21},
+		{root: constantNode(`say "hi"`), want: `say "hi"`},
+		{root: constantNode("a`b\"c%d${e}"), want: "a`b\"c%d${e}"},
+		{root: constantNode([]string{`"a"`, "b"}), want: []string{`"a"`, "b"}},
+		{root: constantNode([]interface{}{1.0, `"a"`}), want: []Value{1.0, `"a"`}},
+		{
+			root: &ast.Node{Kind: ast.Operator, Value: "/", Children: []*ast.Node{constantNode(int64(3)), constantNode(2.0)}},
+			want: 1.5,
+		},
+	}
+
+	for _, c := range testCases {
+		// the string of the tree can be compiled again
+		str := c.root.String()
+		root, err := Parse(cc, str)
+		assertNil(t, err, str)
+		assertEquals(t, root.String(), str)
+
+		res, err := Eval(str, nil, cc)
+		assertNil(t, err, str)
+		assertEquals(t, res, c.want, str)
+	}
+}
+
+func constantNode(v interface{}) *ast.Node {
+	return &ast.Node{Kind: ast.Constant, Value: v}
+}
+
+func TestCompileAST(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "US",
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+
+	root := &ast.Node{
+		Kind:  ast.Operator,
+		Value: "and",
+		Children: []*ast.Node{
+			{
+				Kind:  ast.Operator,
+				Value: ">",
+				Children: []*ast.Node{
+					{Kind: ast.Selector, Value: "age"},
+					{Kind: ast.Constant, Value: 18},
+				},
+			},
+			{
+				Kind:  ast.Cond,
+				Value: "if",
+				Children: []*ast.Node{
+					{Kind: ast.Constant, Value: true},
+					{
+						Kind:  ast.Operator,
+						Value: "in",
+						Children: []*ast.Node{
+							{Kind: ast.Selector, Value: "country"},
+							{Kind: ast.Constant, Value: []string{"US", "CA"}},
+						},
+					},
+					{Kind: ast.Constant, Value: false},
+				},
+			},
+		},
+	}
+
+	expr, err := CompileAST(cc, root)
+	assertNil(t, err)
+	res, err := expr.EvalBool(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, true)
+
+	_, err = CompileAST(cc, &ast.Node{Kind: ast.Selector, Value: "gender"})
+	assertErrStrContains(t, err, "unknown token error, selector: gender")
+
+	_, err = CompileAST(cc, &ast.Node{Kind: ast.Operator, Value: "adult"})
+	assertErrStrContains(t, err, "unknown token error, operator: adult")
+
+	_, err = CompileAST(cc, &ast.Node{Kind: ast.Cond, Value: "if"})
+	assertErrStrContains(t, err, "if parameters count error")
+
+	// the types are checked as Compile does
+	tc := NewCompileConfig(EnableTypeCheck, RegisterSelKeys(vals))
+	root, err = Parse(tc, `(+ "a" 1)`)
+	assertNil(t, err)
+	_, err = CompileAST(tc, root)
+	assertErrStrContains(t, err, "type check error")
+
+	root, err = Parse(tc, `(+ 1 2)`)
+	assertNil(t, err)
+	expr, err = CompileAST(tc, root)
+	assertNil(t, err)
+	assertEquals(t, expr.ReturnType(), TypeInt)
+}
+
+func TestRewriters(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     16,
+		"country": "CA",
+	}
+
+	// macro expansion: (adult x) => (>= x 18)
+	adult := func(n *ast.Node) (*ast.Node, error) {
+		if n.Kind != ast.Operator || n.Value != "adult" {
+			return n, nil
+		}
+		if len(n.Children) != 1 {
+			return nil, errors.New("adult requires exactly one param")
+		}
+		return &ast.Node{
+			Kind:     ast.Operator,
+			Value:    ">=",
+			Children: []*ast.Node{n.Children[0], {Kind: ast.Constant, Value: int64(18)}},
+		}, nil
+	}
+
+	// policy: region is an alias of country
+	region := func(n *ast.Node) (*ast.Node, error) {
+		if n.Kind == ast.Selector && n.Value == "region" {
+			return &ast.Node{Kind: ast.Selector, Value: "country"}, nil
+		}
+		return n, nil
+	}
+
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+	cc.Rewriters = []ast.RewriteFunc{adult, region}
+
+	expr, err := Compile(cc, `(or (adult age) (= region "CA"))`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(or (>= age 18) (= country "CA"))`)
+	res, err := expr.EvalBool(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, true)
+
+	_, err = Compile(cc, `(adult age 1)`)
+	assertErrStrContains(t, err, "adult requires exactly one param")
+
+	// the unknown operators are still rejected after rewriting
+	_, err = Compile(cc, `(child age)`)
+	assertErrStrContains(t, err, "unknown token error, operator: child")
+
+	// the rewriters are also applied to the public syntax tree
+	root, err := Parse(cc, `(adult age)`)
+	assertNil(t, err)
+	expr, err = CompileAST(cc, root)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(>= age 18)`)
+}
@@ -0,0 +1,53 @@
+package eval
+
+import "fmt"
+
+// SelectorRead is a selector read by an evaluation, which is recorded by EvalWithAudit
+type SelectorRead struct {
+	Name string
+	// Value is the value returned by the selector, it's redacted if the redact func is given
+	Value Value
+	Err   error
+}
+
+// EvalWithAudit evaluates the expression like Eval, and returns the selectors read by the evaluation
+// in the order they are first read, each selector is recorded once. The selectors skipped by
+// short circuit or the branches not taken are not recorded. The redact func, if it isn't nil,
+// converts the values to be recorded, e.g. masking the sensitive ones, the evaluation is not affected.
+func (e *Expr) EvalWithAudit(ctx *Ctx, redact func(name string, val Value) Value) (Value, []SelectorRead, error) {
+	sel := &auditSelector{redact: redact}
+	c := &Ctx{Selector: sel}
+	if ctx != nil {
+		sel.Selector = ctx.Selector
+		c.Ctx = ctx.Ctx
+	}
+
+	res, err := e.Eval(c)
+	return res, sel.reads, err
+}
+
+// auditSelector records the reads of the selector
+type auditSelector struct {
+	Selector
+	redact func(name string, val Value) Value
+	reads  []SelectorRead
+}
+
+func (s *auditSelector) Get(selKey SelectorKey, strKey string) (Value, error) {
+	if s.Selector == nil {
+		return nil, fmt.Errorf("%w %s", ErrSelectorNotExist, strKey)
+	}
+	val, err := s.Selector.Get(selKey, strKey)
+	for _, r := range s.reads {
+		if r.Name == strKey {
+			return val, err
+		}
+	}
+
+	read := SelectorRead{Name: strKey, Value: val, Err: err}
+	if s.redact != nil && err == nil {
+		read.Value = s.redact(strKey, val)
+	}
+	s.reads = append(s.reads, read)
+	return val, err
+}
@@ -0,0 +1,78 @@
+package eval
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExpr_EvalWithAudit(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "US",
+		"ssn":     "123-45-6789",
+		"tags":    []string{"vip"},
+	}
+	redact := func(name string, val Value) Value {
+		if s, ok := val.(string); ok && name == "ssn" {
+			return strings.Repeat("*", len(s)-4) + s[len(s)-4:]
+		}
+		return val
+	}
+
+	testCases := []struct {
+		expr  string
+		want  Value
+		reads []SelectorRead
+	}{
+		{
+			expr: `(and (> age 18) (= country "US") (!= ssn "") (> age 0))`,
+			want: true,
+			reads: []SelectorRead{
+				{Name: "age", Value: int64(20)},
+				{Name: "country", Value: "US"},
+				{Name: "ssn", Value: "*******6789"},
+			},
+		},
+		{
+			// the selectors skipped by short circuit are not read
+			expr:  `(or (= country "US") (in "vip" tags))`,
+			want:  true,
+			reads: []SelectorRead{{Name: "country", Value: "US"}},
+		},
+		{
+			expr:  `(if (< age 18) country "adult")`,
+			want:  "adult",
+			reads: []SelectorRead{{Name: "age", Value: int64(20)}},
+		},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(RegisterSelKeys(vals), Optimizations(false))
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err)
+
+		res, reads, err := expr.EvalWithAudit(NewCtxWithMap(cc, vals), redact)
+		assertNil(t, err)
+		assertEquals(t, res, c.want, c.expr)
+		assertEquals(t, reads, c.reads, c.expr)
+	}
+}
+
+func TestExpr_EvalWithAudit_Errors(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors)
+	expr, err := Compile(cc, `(and (> age 18) (= country "US"))`)
+	assertNil(t, err)
+
+	res, reads, err := expr.EvalWithAudit(&Ctx{Selector: NewMapSelector(map[string]interface{}{"age": 20})}, nil)
+	assertEquals(t, errors.Is(err, ErrSelectorNotExist), true)
+	assertNil(t, res)
+	assertEquals(t, len(reads), 2)
+	assertEquals(t, reads[0], SelectorRead{Name: "age", Value: int64(20)})
+	assertEquals(t, reads[1].Name, "country")
+	assertEquals(t, errors.Is(reads[1].Err, ErrSelectorNotExist), true)
+
+	_, reads, err = expr.EvalWithAudit(nil, nil)
+	assertEquals(t, errors.Is(err, ErrSelectorNotExist), true)
+	assertEquals(t, len(reads), 0)
+}
@@ -0,0 +1,82 @@
+package eval
+
+import "fmt"
+
+// EvalBatch evaluates the expression against each ctx,
+// the stacks are allocated once and reused across all the evaluations.
+// The results and errors are in the same order as ctxs.
+func (e *Expr) EvalBatch(ctxs []*Ctx) ([]Value, []error) {
+	var (
+		size = max(int(e.maxStackSize), 8)
+		os   = make([]Value, size)
+		sf   = make([]int16, size)
+
+		res  = make([]Value, len(ctxs))
+		errs = make([]error, len(ctxs))
+	)
+
+	for i, ctx := range ctxs {
+		res[i], errs[i] = e.evalWithStacks(ctx, os, sf)
+	}
+	return res, errs
+}
+
+// EvalColumns evaluates the expression against the rows of the columnar values,
+// the columns are keyed by the names of selectors, and the i-th row consists of
+// the i-th value of each column. The number of rows is the length of the longest column,
+// reading a row beyond the length of a shorter column results in an error of that row.
+func (e *Expr) EvalColumns(columns map[string][]Value) ([]Value, []error) {
+	var rows int
+	for _, col := range columns {
+		rows = max(rows, len(col))
+	}
+
+	var (
+		size = max(int(e.maxStackSize), 8)
+		os   = make([]Value, size)
+		sf   = make([]int16, size)
+
+		sel = &columnSelector{columns: columns}
+		ctx = &Ctx{Selector: sel}
+
+		res  = make([]Value, rows)
+		errs = make([]error, rows)
+	)
+
+	for i := 0; i < rows; i++ {
+		sel.row = i
+		res[i], errs[i] = e.evalWithStacks(ctx, os, sf)
+	}
+	return res, errs
+}
+
+// columnSelector gets the values of the current row from the columns
+type columnSelector struct {
+	columns map[string][]Value
+	row     int
+}
+
+func (s *columnSelector) Get(_ SelectorKey, strKey string) (Value, error) {
+	col, exist := s.columns[strKey]
+	if !exist {
+		return nil, fmt.Errorf("column not exist %s", strKey)
+	}
+	if s.row >= len(col) {
+		return nil, fmt.Errorf("column %s out of range, row: %d, length: %d", strKey, s.row, len(col))
+	}
+	return col[s.row], nil
+}
+
+func (s *columnSelector) Set(_ SelectorKey, strKey string, val Value) error {
+	col, exist := s.columns[strKey]
+	if !exist || s.row >= len(col) {
+		return fmt.Errorf("column %s out of range, row: %d", strKey, s.row)
+	}
+	col[s.row] = val
+	return nil
+}
+
+func (s *columnSelector) Cached(_ SelectorKey, strKey string) bool {
+	col, exist := s.columns[strKey]
+	return exist && s.row < len(col)
+}
@@ -0,0 +1,58 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestExpr_EvalBatch(t *testing.T) {
+	users := []map[string]interface{}{
+		{"age": 20, "country": "US"},
+		{"age": 16, "country": "US"},
+		{"age": 30, "country": "CN"},
+		{"age": "40", "country": "US"},
+	}
+
+	cc := NewCompileConfig(RegisterSelKeys(users[0]))
+	exprStr := `(and (> age 18) (= country "US"))`
+	expr, err := Compile(cc, exprStr)
+	assertNil(t, err)
+
+	ctxs := make([]*Ctx, len(users))
+	for i, user := range users {
+		ctxs[i] = NewCtxWithMap(cc, user)
+	}
+
+	res, errs := expr.EvalBatch(ctxs)
+	assertEquals(t, len(res), len(users))
+	assertEquals(t, len(errs), len(users))
+	for i, ctx := range ctxs {
+		want, wantErr := expr.Eval(ctx)
+		assertEquals(t, res[i], want)
+		assertEquals(t, errs[i], wantErr)
+	}
+	assertEquals(t, res[:3], []Value{true, false, false})
+	assertNotNil(t, errs[3])
+
+	res, errs = expr.EvalBatch(nil)
+	assertEquals(t, len(res), 0)
+	assertEquals(t, len(errs), 0)
+}
+
+func TestExpr_EvalColumns(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors)
+	expr, err := Compile(cc, `(if (> age 18) (+ score 10) score)`)
+	assertNil(t, err)
+
+	res, errs := expr.EvalColumns(map[string][]Value{
+		"age":   {20, 16, int64(30), 40},
+		"score": {1, 2, 3},
+	})
+	assertEquals(t, res[:3], []Value{int64(11), int64(2), int64(13)})
+	assertEquals(t, errs[:3], []error{nil, nil, nil})
+	assertErrStrContains(t, errs[3], "column score out of range, row: 3, length: 3")
+
+	_, errs = expr.EvalColumns(map[string][]Value{
+		"age": {20},
+	})
+	assertErrStrContains(t, errs[0], "column not exist score")
+}
@@ -0,0 +1,238 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const typeBigInt = "bigint"
+
+// bigIntModes are the modes of the operators supporting the big integers if the BigIntegers option is enabled
+var bigIntModes = map[string]mode{
+	"add": add, "sub": sub, "mul": mul, "div": div, "mod": mod,
+	"+": add, "-": sub, "*": mul, "/": div, "%": mod,
+	"eq": equals, "ne": notEquals, "gt": greater, "lt": less, "ge": greaterEquals, "le": lessEquals,
+	"=": equals, "!=": notEquals, ">": greater, "<": less, ">=": greaterEquals, "<=": lessEquals,
+	"between":  between,
+	"truncdiv": truncDiv, "floordiv": floorDiv, "floormod": floorMod,
+	"<<": shiftLeft, ">>": shiftRight,
+}
+
+// maxBigIntShift is the maximum shift count of the big integers, e.g. (<< 1 64),
+// so that a huge count doesn't exhaust the memory
+const maxBigIntShift = 1 << 16
+
+// bigIntArithmetic executes the operator with the *big.Int values if any param is a big integer,
+// or the int64 arithmetic overflows, the int64 params are promoted to big integers.
+// The results within the range of int64 are int64 values, so the big integers only
+// represent the ones beyond int64. The operator of the other params is executed by next.
+type bigIntArithmetic struct {
+	mode mode
+	next Operator
+}
+
+func (b bigIntArithmetic) execute(ctx *Ctx, params []Value) (Value, error) {
+	if !hasBigInt(params) && !b.overflows(params) {
+		return b.next(ctx, params)
+	}
+
+	op := modeNames[b.mode]
+	switch b.mode {
+	case equals, notEquals:
+		if len(params) < 2 || (b.mode == notEquals && len(params) != 2) {
+			return nil, errCnt2(b.mode, params)
+		}
+		for _, p := range params[1:] {
+			if !bigIntEquals(params[0], p) {
+				return b.mode == notEquals, nil
+			}
+		}
+		return b.mode == equals, nil
+	}
+
+	ints := make([]*big.Int, len(params))
+	for i, p := range params {
+		v, ok := toBigInt(p)
+		if !ok {
+			return nil, ParamTypeError(op, typeBigInt, p)
+		}
+		ints[i] = v
+	}
+
+	switch b.mode {
+	case greater, less, greaterEquals, lessEquals:
+		if len(ints) != 2 {
+			return nil, errCnt2(b.mode, params)
+		}
+		c := ints[0].Cmp(ints[1])
+		switch b.mode {
+		case greater:
+			return c > 0, nil
+		case less:
+			return c < 0, nil
+		case greaterEquals:
+			return c >= 0, nil
+		}
+		return c <= 0, nil
+	case between:
+		if len(ints) != 3 {
+			return nil, ParamsCountError(op, 3, len(ints))
+		}
+		return ints[1].Cmp(ints[0]) <= 0 && ints[0].Cmp(ints[2]) <= 0, nil
+	case shiftLeft, shiftRight:
+		if len(ints) != 2 {
+			return nil, errCnt2(b.mode, params)
+		}
+		n := ints[1]
+		if n.Sign() < 0 {
+			return nil, OpExecError(op, errors.New("negative shift count"))
+		}
+		if !n.IsInt64() || n.Int64() > maxBigIntShift {
+			return nil, OpExecError(op, fmt.Errorf("shift count too large: %s", n))
+		}
+		if b.mode == shiftLeft {
+			return narrowBigInt(new(big.Int).Lsh(ints[0], uint(n.Int64()))), nil
+		}
+		return narrowBigInt(new(big.Int).Rsh(ints[0], uint(n.Int64()))), nil
+	case truncDiv, floorDiv, floorMod:
+		if len(ints) != 2 {
+			return nil, errCnt2(b.mode, params)
+		}
+		x, y := ints[0], ints[1]
+		if y.Sign() == 0 {
+			return nil, OpExecError(op, errDivideByZero)
+		}
+		q, r := new(big.Int).QuoRem(x, y, new(big.Int))
+		// rounded toward negative infinity like the int64 ones, see intDivision
+		if b.mode != truncDiv && r.Sign() != 0 && (r.Sign() < 0) != (y.Sign() < 0) {
+			q.Sub(q, big.NewInt(1))
+			r.Add(r, y)
+		}
+		if b.mode == floorMod {
+			return narrowBigInt(r), nil
+		}
+		return narrowBigInt(q), nil
+	}
+
+	if len(ints) < 2 {
+		return nil, errCnt2(b.mode, params)
+	}
+	res := new(big.Int).Set(ints[0])
+	for _, v := range ints[1:] {
+		switch b.mode {
+		case add:
+			res.Add(res, v)
+		case sub:
+			res.Sub(res, v)
+		case mul:
+			res.Mul(res, v)
+		case div, mod:
+			if v.Sign() == 0 {
+				return nil, OpExecError(op, errDivideByZero)
+			}
+			// truncated like the int64 ones
+			if b.mode == div {
+				res.Quo(res, v)
+			} else {
+				res.Rem(res, v)
+			}
+		default:
+			return nil, errInvalidMode(b.mode, "big integer arithmetic")
+		}
+	}
+	return narrowBigInt(res), nil
+}
+
+// overflows reports whether the arithmetic of the int64 params overflows int64,
+// which is executed with the big integers instead
+func (b bigIntArithmetic) overflows(params []Value) bool {
+	m := b.mode
+	switch m {
+	case shiftLeft:
+		if len(params) != 2 {
+			return false
+		}
+		x, ok := params[0].(int64)
+		n, isInt := params[1].(int64)
+		if !ok || !isInt || n < 0 {
+			// the negative shift count is handled by the next operator
+			return false
+		}
+		// the bits shifted beyond the 64 bits are kept
+		if n >= 64 {
+			return x != 0
+		}
+		return (x<<uint64(n))>>uint64(n) != x
+	case add, sub, mul, div:
+	case truncDiv, floorDiv:
+		// the quotients only overflow by (/ math.MinInt64 -1), the same as div
+		m = div
+	default:
+		return false
+	}
+
+	var acc int64
+	for i, p := range params {
+		v, ok := p.(int64)
+		if !ok {
+			return false
+		}
+		if i == 0 {
+			acc = v
+			continue
+		}
+		r, err := checkedInt(m, acc, v)
+		if err != nil {
+			// the divide-by-zero is handled by the next operator
+			return err == errIntOverflow
+		}
+		acc = r
+	}
+	return false
+}
+
+func hasBigInt(params []Value) bool {
+	for _, p := range params {
+		if _, ok := p.(*big.Int); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// toBigInt converts the *big.Int or int64 param to *big.Int
+func toBigInt(p Value) (*big.Int, bool) {
+	switch v := p.(type) {
+	case *big.Int:
+		return v, v != nil
+	case int64:
+		return big.NewInt(v), true
+	}
+	return nil, false
+}
+
+// narrowBigInt returns the int64 value if the big integer is within the range of int64
+func narrowBigInt(v *big.Int) Value {
+	if v.IsInt64() {
+		return v.Int64()
+	}
+	return v
+}
+
+// bigIntEquals compares the big integer with the int64 or big integer by their numeric values
+func bigIntEquals(a, b Value) bool {
+	x, ok := toBigInt(a)
+	y, ok2 := toBigInt(b)
+	if !ok || !ok2 {
+		return a == b
+	}
+	return x.Cmp(y) == 0
+}
+
+// isBigIntLiteral reports whether s is an integer literal, which may be beyond int64
+func isBigIntLiteral(s string) bool {
+	digits := strings.TrimLeft(s, "+-")
+	return len(digits) != 0 && len(s)-len(digits) <= 1 && strings.Trim(digits, "0123456789") == ""
+}
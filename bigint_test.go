@@ -0,0 +1,121 @@
+package eval
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestBigInt(t *testing.T) {
+	vals := map[string]interface{}{
+		"balance": mustBigInt("340282366920938463463374607431768211455"),
+		"amount":  int64(math.MaxInt64),
+		"fee":     int64(10),
+		"rate":    0.5,
+	}
+
+	testCases := []struct {
+		expr string
+		opts []CompileOption
+		// the BigIntegers option is enabled unless disabled is true
+		disabled bool
+		res      Value
+		errMsg   string
+	}{
+		{expr: `(+ amount 1)`, res: mustBigInt("9223372036854775808")},
+		{expr: `(* amount fee)`, res: mustBigInt("92233720368547758070")},
+		{expr: `(- (+ amount 1) 1)`, res: int64(math.MaxInt64)},
+		{expr: `(- -9223372036854775808 1)`, res: mustBigInt("-9223372036854775809")},
+		{expr: `(/ -9223372036854775808 -1)`, res: mustBigInt("9223372036854775808")},
+		{expr: `(+ 1 2)`, res: int64(3)},
+		{expr: `(+ 1.5 2)`, res: 3.5},
+		{expr: `(/ balance 340282366920938463463374607431768211455)`, res: int64(1)},
+		{expr: `(% balance 1000)`, res: int64(455)},
+		{expr: `(> balance amount)`, res: true},
+		{expr: `(< 100000000000000000000 balance)`, res: true},
+		{expr: `(between amount 0 balance)`, res: true},
+		{expr: `(= balance 340282366920938463463374607431768211455)`, res: true},
+		{expr: `(!= (+ amount 1) 9223372036854775808)`, res: false},
+		{expr: `(= (- (+ amount 1) 1) amount)`, res: true},
+		{expr: `(= balance "x")`, res: false},
+		{expr: `balance > 18446744073709551615 && -18446744073709551616 < 0`, opts: []CompileOption{EnableInfixSyntax}, res: true},
+		{expr: `balance > 18446744073709551615 AND -18446744073709551616 < 0`, opts: []CompileOption{EnableSQLSyntax}, res: true},
+		{expr: `(+ amount 1)`, opts: []CompileOption{OnArithmeticError(ArithmeticError)}, res: mustBigInt("9223372036854775808")},
+		{expr: `(+ balance 1)`, opts: []CompileOption{EnableTypeCheck}, res: mustBigInt("340282366920938463463374607431768211456")},
+		{expr: `(> (* amount 2) amount)`, opts: []CompileOption{Optimizations(false)}, res: true},
+		{expr: `(floordiv -9223372036854775808 -1)`, res: mustBigInt("9223372036854775808")},
+		{expr: `(truncdiv -9223372036854775808 -1)`, res: mustBigInt("9223372036854775808")},
+		{expr: `(floordiv -9223372036854775808 -1)`, opts: []CompileOption{OnArithmeticError(ArithmeticSaturate)}, res: mustBigInt("9223372036854775808")},
+		{expr: `(floormod -9223372036854775808 -1)`, res: int64(0)},
+		{expr: `(floordiv (- 0 balance) 1000)`, res: mustBigInt("-340282366920938463463374607431768212")},
+		{expr: `(truncdiv (- 0 balance) 1000)`, res: mustBigInt("-340282366920938463463374607431768211")},
+		{expr: `(floormod (- 0 balance) 1000)`, res: int64(545)},
+		{expr: `(floormod balance -1000)`, res: int64(-545)},
+		{expr: `(floordiv balance -1000)`, res: mustBigInt("-340282366920938463463374607431768212")},
+		{expr: `(floordiv balance 0)`, errMsg: "divide by zero"},
+		{expr: `(floordiv -7 2)`, res: int64(-4)},
+		{expr: `(<< 1 64)`, res: mustBigInt("18446744073709551616")},
+		{expr: `(<< -1 63)`, res: int64(math.MinInt64)},
+		{expr: `(<< 3 62)`, res: mustBigInt("13835058055282163712")},
+		{expr: `(<< fee 2)`, res: int64(40)},
+		{expr: `(>> (<< 1 64) 60)`, res: int64(16)},
+		{expr: `(>> balance 100)`, res: int64(268435455)},
+		{expr: `(<< 1 -1)`, errMsg: "negative shift count"},
+		{expr: `(<< balance 100000)`, errMsg: "shift count too large: 100000"},
+		{expr: `(<< 1 64)`, disabled: true, res: int64(0)},
+
+		{expr: `(+ amount 1)`, disabled: true, res: int64(math.MinInt64)},
+		{expr: `18446744073709551616`, disabled: true, errMsg: "value out of range"},
+		{expr: `(+ balance rate)`, errMsg: "expected: bigint"},
+		{expr: `(/ balance 0)`, errMsg: "divide by zero"},
+		{expr: `(/ amount 0)`, errMsg: "divide by zero"},
+		{expr: `(/ amount 0)`, opts: []CompileOption{ReturnOnArithmeticError(int64(0))}, res: int64(0)},
+	}
+
+	for _, c := range testCases {
+		opts := append(c.opts, RegisterSelKeys(vals))
+		if !c.disabled {
+			opts = append(opts, EnableBigIntegers)
+		}
+		cc := NewCompileConfig(opts...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+}
+
+func TestBigInt_Expr(t *testing.T) {
+	cc := NewCompileConfig(EnableBigIntegers, RegisterSelKeys(map[string]interface{}{"supply": 0}))
+
+	expr, err := Compile(cc, `(>= supply 100000000000000000000000)`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(>= supply 100000000000000000000000)`)
+
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	loaded, err := UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+
+	ctx := NewCtxWithMap(cc, map[string]interface{}{"supply": mustBigInt("210000000000000000000000")})
+	for _, e := range []*Expr{expr, loaded} {
+		res, err := e.Eval(ctx)
+		assertNil(t, err)
+		assertEquals(t, res, true)
+	}
+
+	folded, err := Compile(cc, `(* 9223372036854775807 2)`)
+	assertNil(t, err)
+	assertEquals(t, folded.Decompile(), `18446744073709551614`)
+}
+
+func mustBigInt(s string) *big.Int {
+	b, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid big integer: " + s)
+	}
+	return b
+}
@@ -0,0 +1,66 @@
+package eval
+
+import "errors"
+
+// bitwise executes the bitwise operators over the int64 params, e.g. (bitand permissions 4), (<< 1 3),
+// bitand, bitor and bitxor accept two or more params, and the shifts accept exactly two.
+// The shift count should not be negative, the bits shifted beyond the 64 bits are discarded,
+// unless the BigIntegers option is enabled, e.g. (<< 1 64) => 18446744073709551616.
+type bitwise struct {
+	mode mode
+}
+
+func (b bitwise) execute(_ *Ctx, params []Value) (Value, error) {
+	if len(params) < 2 || ((b.mode == shiftLeft || b.mode == shiftRight) && len(params) != 2) {
+		return nil, errCnt2(b.mode, params)
+	}
+
+	var res int64
+	for i, p := range params {
+		v, ok := p.(int64)
+		if !ok {
+			return nil, errTypeInt(b.mode, p)
+		}
+		if i == 0 {
+			res = v
+			continue
+		}
+
+		switch b.mode {
+		case bitAnd:
+			res &= v
+		case bitOr:
+			res |= v
+		case bitXor:
+			res ^= v
+		case shiftLeft, shiftRight:
+			if v < 0 {
+				return nil, OpExecError(modeNames[b.mode], errors.New("negative shift count"))
+			}
+			if b.mode == shiftLeft {
+				res <<= uint64(v)
+			} else {
+				res >>= uint64(v)
+			}
+		default:
+			return nil, errInvalidMode(b.mode, "bitwise")
+		}
+	}
+	return res, nil
+}
+
+// maskOrLogic executes the bitwise operator if the params are int64 values,
+// otherwise the logic operator, e.g. (& flags 4) and (& vip active)
+type maskOrLogic struct {
+	mode    mode
+	bitMode mode
+}
+
+func (m maskOrLogic) execute(ctx *Ctx, params []Value) (Value, error) {
+	if len(params) != 0 {
+		if _, isInt := params[0].(int64); isInt {
+			return bitwise{mode: m.bitMode}.execute(ctx, params)
+		}
+	}
+	return logic{mode: m.mode}.execute(ctx, params)
+}
@@ -0,0 +1,70 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestBitwise(t *testing.T) {
+	vals := map[string]interface{}{
+		"permissions": 0b1011,
+		"flags":       int64(1) << 40,
+		"vip":         true,
+		"active":      false,
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(& permissions 2)`, res: int64(2)},
+		{expr: `(= (& permissions 4) 4)`, res: false},
+		{expr: `(| permissions 4)`, res: int64(15)},
+		{expr: `(^ permissions 1)`, res: int64(10)},
+		{expr: `(& permissions 3 2)`, res: int64(2)},
+		{expr: `(bitand permissions 8)`, res: int64(8)},
+		{expr: `(bitor 1 2 4)`, res: int64(7)},
+		{expr: `(bitxor permissions permissions)`, res: int64(0)},
+		{expr: `(<< 1 40)`, res: int64(1) << 40},
+		{expr: `(>> flags 38)`, res: int64(4)},
+		{expr: `(>> -8 1)`, res: int64(-4)},
+		{expr: `(<< 1 64)`, res: int64(0)},
+		{expr: `(!= (& flags (<< 1 40)) 0)`, res: true},
+		{expr: `(& vip active)`, res: false},
+		{expr: `(| vip active)`, res: true},
+		{expr: `(^ vip active)`, res: true},
+		{expr: `(& permissions 2)`, opts: []CompileOption{Optimizations(false)}, res: int64(2)},
+		{expr: `(& permissions 2)`, opts: []CompileOption{EnableTypeCheck}, res: int64(2)},
+		{expr: `(and (& vip true) (= (& permissions 2) 2))`, opts: []CompileOption{EnableTypeCheck}, res: true},
+		{expr: `(= (& permissions 2) 2)`, opts: []CompileOption{EnableZeroAlloc}, res: true},
+		{
+			expr: `(= (& permissions 2) 2)`,
+			opts: []CompileOption{func(c *CompileConfig) { c.Backend = ClosureBackend }},
+			res:  true,
+		},
+		{expr: `permissions & 2 == 2 && (permissions | 4) == 15`, opts: []CompileOption{EnableInfixSyntax}, res: true},
+		{expr: `1 << 3 + 1`, opts: []CompileOption{EnableInfixSyntax}, res: int64(9)},
+		{expr: `permissions ^ 1 >> 1`, opts: []CompileOption{EnableInfixSyntax}, res: int64(11)},
+
+		{expr: `(& permissions true)`, errMsg: "operator: &"},
+		{expr: `(bitand vip active)`, errMsg: "expected: int64"},
+		{expr: `(<< 1 -1)`, errMsg: "negative shift count"},
+		{expr: `(<< 1 2 3)`, errMsg: "operator: <<, expected: 2, got: 3"},
+		{expr: `(bitor 1)`, errMsg: "operator: bitor, expected: 2, got: 1"},
+		{expr: `(& permissions "x")`, opts: []CompileOption{EnableTypeCheck}, errMsg: "& param 1 should be bool or int64, got: string"},
+		{expr: `(and (& 6 2) true)`, opts: []CompileOption{EnableTypeCheck}, errMsg: "and param 0 should be bool, got: int64"},
+		{expr: `(<< permissions 1.5)`, opts: []CompileOption{EnableTypeCheck}, errMsg: "<< param 1 should be int64"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+}
@@ -0,0 +1,100 @@
+package eval
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// CompileCache caches the compiled expressions by the identity of the CompileConfig and the
+// expression source, the least recently used ones are evicted once the capacity is exceeded.
+// The concurrent compilations of the same expression are deduplicated, so that the
+// expression is compiled only once. The compile errors are not cached.
+//
+// The configs are identified by themselves and their versions, which are changed by the options,
+// the Register* functions and the access lists, so the different configs never share the expressions,
+// even if they are copied from each other. The fields of the config shouldn't be changed directly
+// once it's used by the cache, copy it by CopyCompileConfig and change the copy instead.
+//
+// It's safe for concurrent use, and it can be used by Compile and Eval by setting it
+// to CompileConfig.CompileCache, e.g. NewCompileConfig(WithCompileCache(cache))
+type CompileCache struct {
+	lru *lruCache
+
+	mu    sync.Mutex
+	calls map[string]*compileCall
+}
+
+// compileCall is an in-flight compilation waited by the concurrent callers
+type compileCall struct {
+	wg   sync.WaitGroup
+	expr *Expr
+	err  error
+}
+
+// NewCompileCache creates a CompileCache caching at most capacity expressions
+func NewCompileCache(capacity int) *CompileCache {
+	return &CompileCache{
+		lru:   newLRUCache(capacity),
+		calls: make(map[string]*compileCall),
+	}
+}
+
+// WithCompileCache compiles the expressions with the cache
+func WithCompileCache(cache *CompileCache) CompileOption {
+	return func(c *CompileConfig) {
+		c.CompileCache = cache
+	}
+}
+
+// Compile returns the cached expression compiled with the same config,
+// or compiles the expression and caches it
+func (c *CompileCache) Compile(cc *CompileConfig, exprStr string) (*Expr, error) {
+	key := configKey(cc) + exprStr
+	if expr, exist := c.lru.get(key); exist {
+		return expr.(*Expr), nil
+	}
+
+	c.mu.Lock()
+	if call, exist := c.calls[key]; exist {
+		c.mu.Unlock()
+		call.wg.Wait()
+		return call.expr, call.err
+	}
+	call := &compileCall{}
+	call.wg.Add(1)
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	call.expr, call.err = compile(cc, exprStr)
+	if call.err == nil {
+		c.lru.add(key, call.expr)
+	}
+	call.wg.Done()
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+	return call.expr, call.err
+}
+
+// Len returns the number of the cached expressions
+func (c *CompileCache) Len() int {
+	return c.lru.len()
+}
+
+// configIDs allocates the ids of the configs used by the caches
+var configIDs uint64
+
+// configKey returns the id and the version of the config, the id is allocated when it's first used
+func configKey(cc *CompileConfig) string {
+	if cc == nil {
+		return "-:"
+	}
+	id := atomic.LoadUint64(&cc.id)
+	if id == 0 {
+		atomic.CompareAndSwapUint64(&cc.id, 0, atomic.AddUint64(&configIDs, 1))
+		id = atomic.LoadUint64(&cc.id)
+	}
+	return strconv.FormatUint(id, 36) + "." + strconv.FormatUint(atomic.LoadUint64(&cc.version), 36) + ":"
+}
@@ -0,0 +1,179 @@
+package eval
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/larry618/eval/ast"
+)
+
+func TestCompileCache(t *testing.T) {
+	var compiles int64
+	counting := func(n *ast.Node) (*ast.Node, error) {
+		if n.Kind == ast.Operator && n.Value == "slow" {
+			atomic.AddInt64(&compiles, 1)
+			time.Sleep(10 * time.Millisecond)
+			n.Value = "+"
+		}
+		return n, nil
+	}
+
+	cache := NewCompileCache(2)
+	cc := NewCompileConfig(EnableStringSelectors, WithCompileCache(cache))
+	cc.Rewriters = append(cc.Rewriters, counting)
+
+	// the concurrent compilations are deduplicated
+	var wg sync.WaitGroup
+	exprs := make([]*Expr, 8)
+	for i := range exprs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			exprs[i], err = Compile(cc, `(slow a 1)`)
+			assertNil(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assertEquals(t, atomic.LoadInt64(&compiles), int64(1))
+	for _, expr := range exprs {
+		if expr != exprs[0] {
+			t.Fatal("the same expression should be shared")
+		}
+	}
+
+	// Eval compiles with the cache of the config
+	res, err := Eval(`(slow a 1)`, map[string]interface{}{"a": 1}, cc)
+	assertNil(t, err)
+	assertEquals(t, res, int64(2))
+	assertEquals(t, atomic.LoadInt64(&compiles), int64(1))
+
+	// the copied config misses the cache
+	copied := CopyCompileConfig(cc)
+	expr, err := Compile(copied, `(slow a 1)`)
+	assertNil(t, err)
+	if expr == exprs[0] {
+		t.Fatal("the copied config should miss the cache")
+	}
+	assertEquals(t, atomic.LoadInt64(&compiles), int64(2))
+	assertEquals(t, cache.Len(), 2)
+
+	// the least recently used one is evicted
+	_, err = Compile(cc, `(slow a 2)`)
+	assertNil(t, err)
+	assertEquals(t, cache.Len(), 2)
+	_, err = Compile(cc, `(slow a 1)`)
+	assertNil(t, err)
+	assertEquals(t, atomic.LoadInt64(&compiles), int64(4))
+
+	// the changed config misses the cache
+	Optimizations(false, Reordering)(cc)
+	_, err = Compile(cc, `(slow a 1)`)
+	assertNil(t, err)
+	assertEquals(t, atomic.LoadInt64(&compiles), int64(5))
+
+	// the errors are not cached
+	for i := 0; i < 2; i++ {
+		_, err = Compile(cc, `(slow a`)
+		assertErrStrContains(t, err, "parentheses unmatched")
+	}
+	assertEquals(t, cache.Len(), 2)
+}
+
+func TestCompileCacheFuncs(t *testing.T) {
+	cache := NewCompileCache(8)
+	configs := make([]*CompileConfig, 2)
+	for i, fn := range []func(int64) int64{
+		func(x int64) int64 { return x + 1 },
+		func(x int64) int64 { return x * 100 },
+	} {
+		configs[i] = NewCompileConfig(WithCompileCache(cache))
+		assertNil(t, configs[i].RegisterFunc("f", fn))
+	}
+
+	// the configs registering the different funcs of the same name don't share the expressions
+	for i, want := range []Value{int64(6), int64(500)} {
+		res, err := Eval(`(f 5)`, nil, configs[i])
+		assertNil(t, err)
+		assertEquals(t, res, want)
+	}
+	assertEquals(t, cache.Len(), 2)
+}
+
+func TestCompileCacheRestrictions(t *testing.T) {
+	cache := NewCompileCache(8)
+	cc := NewCompileConfig(EnableStringSelectors, WithCompileCache(cache))
+	_, err := Compile(cc, `(+ age 1)`)
+	assertNil(t, err)
+
+	// the expressions compiled without the restrictions are not shared with the restricted configs
+	denyOp := CopyCompileConfig(cc)
+	denyOp.DenyOperators("+")
+	_, err = Compile(denyOp, `(+ age 1)`)
+	assertErrStrContains(t, err, "operator not allowed: +")
+
+	denySel := CopyCompileConfig(cc)
+	denySel.DenySelectors("age")
+	_, err = Compile(denySel, `(+ age 1)`)
+	assertErrStrContains(t, err, "selector not allowed: age")
+
+	allowOp := CopyCompileConfig(cc)
+	allowOp.AllowOperators("-")
+	_, err = Compile(allowOp, `(+ age 1)`)
+	assertErrStrContains(t, err, "operator not allowed: +")
+
+	limited := CopyCompileConfig(cc)
+	LimitComplexity(ComplexityLimits{MaxNodes: 1})(limited)
+	_, err = Compile(limited, `(+ age 1)`)
+	assertErrStrContains(t, err, "expression too complex, MaxNodes: 1")
+}
+
+func TestConfigKey(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(cc *CompileConfig)
+		same   bool
+	}{
+		{name: "nothing", modify: func(cc *CompileConfig) {}, same: true},
+		{name: "cache", modify: WithCompileCache(NewCompileCache(1)), same: true},
+		{name: "registered selector", modify: func(cc *CompileConfig) { GetOrRegisterKey(cc, "a") }, same: true},
+		{name: "selector", modify: func(cc *CompileConfig) { GetOrRegisterKey(cc, "c") }},
+		{name: "option", modify: EnableDebug},
+		{name: "cost", modify: SelectorCost("a", 100)},
+		{name: "syntax", modify: EnableInfixSyntax},
+		{name: "limits", modify: LimitComplexity(ComplexityLimits{MaxNodes: 1})},
+		{name: "operator", modify: func(cc *CompileConfig) { assertNil(t, RegisterOperator(cc, "f", stringMatches)) }},
+		{name: "func", modify: func(cc *CompileConfig) { assertNil(t, cc.RegisterFunc("f", strings.ToUpper)) }},
+		{name: "module", modify: func(cc *CompileConfig) {
+			assertNil(t, cc.RegisterModule("m", map[string]Operator{"f": stringMatches}))
+		}},
+		{name: "meta", modify: func(cc *CompileConfig) {
+			assertNil(t, RegisterOperator(cc, "f", stringMatches))
+			key := configKey(cc)
+			assertNil(t, RegisterOperatorMeta(cc, "f", OperatorMeta{Deterministic: true}))
+			assertEquals(t, configKey(cc) != key, true)
+		}},
+		{name: "short circuit", modify: func(cc *CompileConfig) {
+			assertNil(t, RegisterOperator(cc, "f", stringMatches))
+			key := configKey(cc)
+			assertNil(t, RegisterShortCircuit(cc, "f", ShortCircuitIfFalse))
+			assertEquals(t, configKey(cc) != key, true)
+		}},
+		{name: "allow operators", modify: func(cc *CompileConfig) { cc.AllowOperators("+") }},
+		{name: "deny operators", modify: func(cc *CompileConfig) { cc.DenyOperators("+") }},
+		{name: "allow selectors", modify: func(cc *CompileConfig) { cc.AllowSelectors("a") }},
+		{name: "deny selectors", modify: func(cc *CompileConfig) { cc.DenySelectors("a") }},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(RegisterSelKeys(map[string]interface{}{"a": 1, "b": 2}))
+		key := configKey(cc)
+		c.modify(cc)
+		assertEquals(t, configKey(cc) == key, c.same, c.name)
+		// the copied config is different
+		assertEquals(t, configKey(CopyCompileConfig(cc)) == configKey(cc), false, c.name)
+	}
+}
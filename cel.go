@@ -0,0 +1,84 @@
+package eval
+
+import (
+	"strings"
+	"time"
+)
+
+// celFunctions maps the CEL functions to the builtin operators by default,
+// they can be overridden by CompileConfig.CELFunctions
+var celFunctions = map[string]string{
+	"timestamp": "datetime",
+	"size":      "len",
+}
+
+// parseCELMember parses the member access of the CEL syntax following the receiver,
+// e.g. user.address.city, tags[0].size() or name.startsWith("L").
+// The fields are accessed by the field operator, and the method call is
+// compiled to the operator call with the receiver as the first param.
+func (p *parser) parseCELMember(recv *astNode) (*astNode, error) {
+	p.walk() // skip the dot
+
+	t := p.next()
+	if t.typ != ident {
+		return nil, p.tokenTypeError(ident, t)
+	}
+	fields := strings.Split(t.val, ".")
+
+	var method string
+	if p.peek().typ == lParen {
+		method, fields = fields[len(fields)-1], fields[:len(fields)-1]
+	}
+
+	n := recv
+	if len(fields) != 0 {
+		children := []*astNode{recv}
+		if recv.node.getNodeType() == operator && recv.node.value == "field" {
+			// a.b.c => (field a "b" "c") rather than (field (field a "b") "c")
+			children = recv.children
+		}
+		for _, field := range fields {
+			children = append(children, p.valNodeAt(field, t))
+		}
+		var err error
+		if n, err = p.buildNode(token{typ: ident, val: "field", pos: t.pos}, children); err != nil {
+			return nil, err
+		}
+	}
+	if method == "" {
+		return n, nil
+	}
+
+	p.walk() // skip the left parenthesis
+	args, err := p.parseInfixElements(rParen)
+	if err != nil {
+		return nil, err
+	}
+	return p.buildCELCall(token{typ: ident, val: method, pos: t.pos}, append([]*astNode{n}, args...))
+}
+
+// isModuleOperator reports whether the name is an operator of a module registered by RegisterModule
+func (p *parser) isModuleOperator(name string) bool {
+	_, exist := p.conf.OperatorMap[name]
+	return exist && strings.Contains(name, moduleSeparator)
+}
+
+// buildCELCall builds the call of the CEL function with the operator it is mapped to
+func (p *parser) buildCELCall(car token, children []*astNode) (*astNode, error) {
+	name, exist := p.conf.CELFunctions[car.val]
+	if !exist {
+		name, exist = celFunctions[car.val]
+	}
+	if exist {
+		if car.val == "timestamp" && name == "datetime" && len(children) == 1 {
+			// the timestamps of CEL are in RFC 3339 format
+			children = append(children, p.valNodeAt(time.RFC3339, car))
+		}
+		car.val = name
+	}
+
+	if p.isKeyword(car) {
+		return p.buildKeywordNode(car, children)
+	}
+	return p.buildNode(car, children)
+}
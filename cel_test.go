@@ -0,0 +1,102 @@
+package eval
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCEL(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors)
+	for name, fn := range map[string]Operator{
+		"startsWith": func(_ *Ctx, params []Value) (Value, error) {
+			return strings.HasPrefix(params[0].(string), params[1].(string)), nil
+		},
+	} {
+		cc.OperatorMap[name] = fn
+	}
+
+	testCases := []struct {
+		cel    string
+		prefix string
+		errMsg string
+	}{
+		{
+			cel:    `age >= 18u && country == 'US'`,
+			prefix: `(and (ge age 18) (eq country "US"))`,
+		},
+		{
+			cel:    `name.startsWith("L") || !(tag in tags)`,
+			prefix: `(or (startsWith name "L") (not (in tag tags)))`,
+		},
+		{
+			cel:    `user.name.startsWith('L') && tags.size() > 1 && size(tags) < 3`,
+			prefix: `(and (startsWith user.name "L") (gt (len tags) 1) (lt (len tags) 3))`,
+		},
+		{
+			cel:    `"Larry".startsWith(prefix) ? items[0].price.amount : 0`,
+			prefix: `(if (startsWith "Larry" prefix) (field (index items 0) "price" "amount") 0)`,
+		},
+		{
+			cel:    `timestamp("2022-05-06T03:56:12Z") < now && email.matches("^.+@example\\.com$")`,
+			prefix: `(and (lt (datetime "2022-05-06T03:56:12Z" "2006-01-02T15:04:05Z07:00") now) (matches email "^.+@example\\.com$"))`,
+		},
+		{
+			// the word operators of the infix syntax are selectors in CEL
+			cel:    `not || and`,
+			prefix: `(or not and)`,
+		},
+		{
+			cel:    `name.unknown()`,
+			errMsg: "unknown token error",
+		},
+		{
+			cel:    `name.`,
+			errMsg: "token type unexpected error",
+		},
+	}
+
+	for _, c := range testCases {
+		conf := CopyCompileConfig(cc)
+		conf.SyntaxMode = CELSyntax
+		Optimizations(false)(conf)
+
+		got, err := Compile(conf, c.cel)
+		if len(c.errMsg) != 0 {
+			assertErrStrContains(t, err, c.errMsg, c)
+			continue
+		}
+		assertNil(t, err, c)
+
+		conf.SyntaxMode = PrefixSyntax
+		want, err := Compile(conf, c.prefix)
+		assertNil(t, err, c)
+
+		assertEquals(t, Dump(got), Dump(want), c)
+	}
+}
+
+func TestEvalCEL(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":  20,
+		"tags": []string{"vip", "new"},
+		"user": map[string]interface{}{
+			"address": map[string]interface{}{"city": "NY"},
+		},
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals), EnableCELSyntax)
+
+	testCases := []struct {
+		expr string
+		want Value
+	}{
+		{expr: `age >= 18 && 'vip' in tags`, want: true},
+		{expr: `user.address.city == "NY" ? tags[1] : ''`, want: "new"},
+		{expr: `timestamp('1970-01-01T00:01:00Z') + 1`, want: int64(61)},
+	}
+
+	for _, c := range testCases {
+		got, err := Eval(c.expr, vals, cc)
+		assertNil(t, err, c)
+		assertEquals(t, got, c.want, c)
+	}
+}
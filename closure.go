@@ -0,0 +1,228 @@
+package eval
+
+import (
+	"fmt"
+)
+
+// Backend decides how the compiled expressions are executed
+type Backend int
+
+const (
+	// BytecodeBackend executes the nodes with the stack-based interpreter
+	BytecodeBackend Backend = iota
+	// ClosureBackend compiles the nodes into a tree of Go closures,
+	// which is faster for the small expressions evaluated frequently.
+	// The expressions compiled with the Debug option or limited by MaxSteps
+	// are still executed by the interpreter, and the cancellation of Ctx.Ctx
+	// is only checked before the evaluation.
+	ClosureBackend
+)
+
+func (b Backend) String() string {
+	switch b {
+	case BytecodeBackend:
+		return "bytecode"
+	case ClosureBackend:
+		return "closure"
+	}
+	return fmt.Sprintf("Backend(%d)", int(b))
+}
+
+// evalFunc evaluates a subexpression compiled by the closure backend
+type evalFunc func(ctx *Ctx, s *scratch) (Value, error)
+
+// scratch is allocated once per evaluation, its params are reused by the binary operators,
+// which are executed after all their operands are evaluated. The operators with more params
+// take their params from the buffer like a stack, since their operands may use it as well,
+// the buffer is allocated once it's needed.
+type scratch struct {
+	param2 [2]Value
+	params []Value
+	top    int
+}
+
+// evalClosure evaluates the expression with the closures built by the closure backend
+func (e *Expr) evalClosure(ctx *Ctx) (Value, error) {
+	if ctx != nil && ctx.Ctx != nil {
+		if err := ctx.Ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return checkMissingResult(e.closure(ctx, &scratch{}))
+}
+
+// setBackend builds the closures of the expression if the closure backend is applicable
+func (e *Expr) setBackend(b Backend) {
+	e.backend = b
+	e.closure = nil
+
+	isDebug := len(e.nodes) != 0 && e.nodes[0].getNodeType() == debug
+	if b != ClosureBackend || isDebug || e.maxSteps > 0 || e.hook != nil {
+		return
+	}
+
+	e.closure = e.buildClosure(e.nodes[0])
+}
+
+// buildClosure compiles the subexpression into a closure,
+// the short circuit follows the same rules as the interpreter.
+func (e *Expr) buildClosure(n *node) evalFunc {
+	switch n.getNodeType() {
+	case constant:
+		v := n.value
+		return func(*Ctx, *scratch) (Value, error) {
+			return v, nil
+		}
+	case selector:
+		return func(ctx *Ctx, _ *scratch) (Value, error) {
+			return e.getSelectorValue(ctx, n)
+		}
+	}
+
+	children := make([]evalFunc, 0, n.childCnt)
+	for i := int16(0); i < int16(n.childCnt); i++ {
+		if child := e.nodes[n.childIdx+i]; child.getNodeType() != end {
+			children = append(children, e.buildClosure(child))
+		}
+	}
+
+	if n.getNodeType() == cond {
+		return condClosure(children[0], children[1], children[2])
+	}
+
+	op, name := n.operator, n.value
+	var execute = func(ctx *Ctx, params []Value) (Value, error) {
+		res, err := op(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", name, err)
+		}
+		return res, nil
+	}
+
+	if isBoolOpNode(n) {
+		return boolOpClosure(children, isAndOpNode(n), isCustomBoolOpNode(n), execute)
+	}
+
+	if n.getNodeType() == fastOperator && n.childCnt == 2 {
+		// the operands are constants or selectors
+		n0, n1 := e.nodes[n.childIdx], e.nodes[n.childIdx+1]
+		return func(ctx *Ctx, s *scratch) (Value, error) {
+			p0, err := e.getNodeValue(ctx, n0)
+			if err != nil {
+				return nil, err
+			}
+			p1, err := e.getNodeValue(ctx, n1)
+			if err != nil {
+				return nil, err
+			}
+			s.param2[0], s.param2[1] = p0, p1
+			return execute(ctx, s.param2[:])
+		}
+	}
+
+	if len(children) == 2 {
+		c0, c1 := children[0], children[1]
+		return func(ctx *Ctx, s *scratch) (Value, error) {
+			p0, err := c0(ctx, s)
+			if err != nil {
+				return nil, err
+			}
+			p1, err := c1(ctx, s)
+			if err != nil {
+				return nil, err
+			}
+			s.param2[0], s.param2[1] = p0, p1
+			return execute(ctx, s.param2[:])
+		}
+	}
+
+	if cnt := len(children); cnt > 2 {
+		size := int(e.paramsSize)
+		return func(ctx *Ctx, s *scratch) (res Value, err error) {
+			if s.params == nil {
+				s.params = make([]Value, size)
+			}
+			params := s.params[s.top : s.top+cnt]
+			s.top += cnt
+			for i, child := range children {
+				if params[i], err = child(ctx, s); err != nil {
+					s.top -= cnt
+					return nil, err
+				}
+			}
+			s.top -= cnt
+			return execute(ctx, params)
+		}
+	}
+
+	return func(ctx *Ctx, s *scratch) (res Value, err error) {
+		params := make([]Value, len(children))
+		for i, child := range children {
+			if params[i], err = child(ctx, s); err != nil {
+				return nil, err
+			}
+		}
+		return execute(ctx, params)
+	}
+}
+
+func condClosure(condition, then, otherwise evalFunc) evalFunc {
+	return func(ctx *Ctx, s *scratch) (Value, error) {
+		res, err := condition(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		condRes, ok := res.(bool)
+		if !ok {
+			return nil, condTypeError(res)
+		}
+		if condRes {
+			return then(ctx, s)
+		}
+		return otherwise(ctx, s)
+	}
+}
+
+// boolOpClosure returns the value of the first operand deciding the result,
+// the operator is executed only if the operands are not all bool values or it's a custom one
+func boolOpClosure(children []evalFunc, isAnd, isCustom bool, execute func(*Ctx, []Value) (Value, error)) evalFunc {
+	// the last param decides the result of and/or, the custom operators are executed after it
+	last := len(children) - 1
+	if isCustom {
+		last = -1
+	}
+	return func(ctx *Ctx, s *scratch) (Value, error) {
+		var params []Value
+		for i, child := range children {
+			res, err := child(ctx, s)
+			if err != nil {
+				return nil, err
+			}
+			if b, ok := res.(bool); ok {
+				if i == last || b != isAnd {
+					return b, nil
+				}
+				if params == nil {
+					continue
+				}
+			} else if params == nil {
+				// the previous operands are all bool values not deciding the result
+				params = boolParams(len(children), isAnd)
+			}
+			params[i] = res
+		}
+		if params == nil {
+			params = boolParams(len(children), isAnd)
+		}
+		return execute(ctx, params)
+	}
+}
+
+// boolParams returns the params of the bool operator whose operands are all bool values not deciding the result
+func boolParams(cnt int, isAnd bool) []Value {
+	params := make([]Value, cnt)
+	for i := range params {
+		params[i] = isAnd
+	}
+	return params
+}
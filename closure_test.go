@@ -0,0 +1,129 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClosureBackend(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "US",
+		"tags":    []string{"vip", "new"},
+		"zero":    0,
+	}
+
+	testCases := []struct {
+		expr   string
+		want   Value
+		errMsg string
+	}{
+		{expr: `(and (> age 18) (= country "US") (in "vip" tags))`, want: true},
+		{expr: `(or (< age 18) (= country "CN") (in "old" tags))`, want: false},
+		{expr: `(if (> age 18) (* age 2) (- age 1))`, want: int64(40)},
+		{expr: `(+ age 1 2 3)`, want: int64(26)},
+		// short circuit
+		{expr: `(and (= zero 1) (> (/ 1 zero) 1))`, want: false},
+		{expr: `(or (= zero 0) (> (/ 1 zero) 1))`, want: true},
+		{expr: `(not (and (!= zero 0) (> (/ 1 zero) 1)))`, want: true},
+		// errors
+		{expr: `(> (/ 1 zero) 1)`, errMsg: "operator execution error, operator: /"},
+		{expr: `(if age 1 2)`, errMsg: "result type of if condition should be bool"},
+		{expr: `(+ age unknown)`, errMsg: "unknown"},
+	}
+
+	for _, c := range testCases {
+		for _, opts := range [][]CompileOption{nil, {Optimizations(false)}} {
+			cc := NewCompileConfig(append(opts, EnableStringSelectors)...)
+			cc.Backend = ClosureBackend
+			expr, err := Compile(cc, c.expr)
+			assertNil(t, err)
+			if expr.closure == nil {
+				t.Fatalf("closure is not built, expr: %s", c.expr)
+			}
+
+			got, err := expr.Eval(NewCtxWithMap(cc, vals))
+			if c.errMsg != "" {
+				assertErrStrContains(t, err, c.errMsg, c.expr)
+				continue
+			}
+			assertNil(t, err, c.expr)
+			assertEquals(t, got, c.want, c.expr)
+		}
+	}
+}
+
+func TestClosureBackend_Fallback(t *testing.T) {
+	const exprStr = `(and (> age 18) (= country "US"))`
+	testCases := []struct {
+		name    string
+		opts    []CompileOption
+		closure bool
+		// the debug option and the step limit are kept by the partial evaluation
+		partialClosure bool
+	}{
+		{name: "closure", closure: true, partialClosure: true},
+		{name: "debug", opts: []CompileOption{EnableDebug}},
+		{name: "step limit", opts: []CompileOption{LimitSteps(100)}},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, EnableStringSelectors)...)
+		cc.Backend = ClosureBackend
+		cc.DebugHandler = func(DebugEvent) {}
+		expr, err := Compile(cc, exprStr)
+		assertNil(t, err)
+		assertEquals(t, expr.closure != nil, c.closure, c.name)
+
+		// the backend is kept by marshalling and partial evaluation
+		bs, err := expr.Marshal()
+		assertNil(t, err)
+		loaded, err := UnmarshalExpr(bs, cc)
+		assertNil(t, err)
+		assertEquals(t, loaded.closure != nil, c.closure, c.name)
+
+		partial, err := expr.PartialEval(nil)
+		assertNil(t, err)
+		assertEquals(t, partial.closure != nil, c.partialClosure, c.name)
+	}
+
+	// the cancellation is checked before the evaluation
+	cc := NewCompileConfig(EnableStringSelectors)
+	cc.Backend = ClosureBackend
+	expr, err := Compile(cc, exprStr)
+	assertNil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = expr.Eval(&Ctx{Selector: NewMapSelector(nil), Ctx: ctx})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got: %v", err)
+	}
+}
+
+func BenchmarkClosureBackend(b *testing.B) {
+	const exprStr = `(and (> age 18) (= country "US") (in "vip" tags) (< (+ age 5) 100))`
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "US",
+		"tags":    []string{"vip", "new"},
+	}
+
+	for _, backend := range []Backend{BytecodeBackend, ClosureBackend} {
+		b.Run(backend.String(), func(b *testing.B) {
+			cc := NewCompileConfig(RegisterSelKeys(vals))
+			cc.Backend = backend
+			expr, err := Compile(cc, exprStr)
+			if err != nil {
+				b.Fatal(err)
+			}
+			ctx := NewCtxWithMap(cc, vals)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = expr.Eval(ctx)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// Command eval compiles and evaluates an expression, it's useful to reproduce
+// the evaluation issues and to inspect how an expression is compiled.
+//
+// Usage:
+//
+//	eval [flags] <expression>
+//
+// Examples:
+//
+//	eval --ctx '{"age": 20}' '(> age 18)'
+//	eval --infix --ctx '{"age": 20, "tags": ["a"]}' 'age > 18 && "a" in tags'
+//	eval --ast --bytecode --debug --ctx '{"age": 20}' '(and (> age 18) (< age 60))'
+//	eval --bench 1000000 --ctx '{"age": 20}' '(> age 18)'
+//	eval --repl --ctx '{"age": 20}'
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/larry618/eval"
+	"github.com/larry618/eval/repl"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(2)
+	}
+}
+
+type options struct {
+	ctx      string
+	ctxFile  string
+	infix    bool
+	noOpt    bool
+	ast      bool
+	bytecode bool
+	dot      bool
+	debug    bool
+	explain  bool
+	bench    int
+	repl     bool
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var opts options
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.ctx, "ctx", "", "values of the selectors in a JSON object, e.g. '{\"age\": 20}'")
+	fs.StringVar(&opts.ctxFile, "ctx-file", "", "file of the JSON object of the selector values")
+	fs.BoolVar(&opts.infix, "infix", false, "parse the expression in the infix syntax")
+	fs.BoolVar(&opts.noOpt, "no-opt", false, "disable all the optimizations")
+	fs.BoolVar(&opts.ast, "ast", false, "print the syntax tree of the compiled expression")
+	fs.BoolVar(&opts.bytecode, "bytecode", false, "print the compiled nodes")
+	fs.BoolVar(&opts.dot, "dot", false, "print the compiled nodes as a Graphviz DOT graph")
+	fs.BoolVar(&opts.debug, "debug", false, "print the stacks of each evaluation step")
+	fs.BoolVar(&opts.explain, "explain", false, "print the trace explaining the result")
+	fs.BoolVar(&opts.repl, "repl", false, "start an interactive shell with the selector values of the ctx")
+	fs.IntVar(&opts.bench, "bench", 0, "evaluate the expression `n` times and print the time per evaluation")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: eval [flags] <expression>\n       eval --repl [flags]")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case opts.repl && fs.NArg() != 0:
+		fs.Usage()
+		return errors.New("eval: no expression is allowed in the repl mode")
+	case !opts.repl && fs.NArg() != 1:
+		fs.Usage()
+		return errors.New("eval: exactly one expression is required")
+	}
+
+	vals, err := loadCtx(opts)
+	if err != nil {
+		return err
+	}
+
+	cc := eval.NewCompileConfig(eval.EnableStringSelectors, eval.RegisterSelKeys(vals))
+	if opts.infix {
+		eval.EnableInfixSyntax(cc)
+	}
+	if opts.noOpt {
+		eval.Optimizations(false)(cc)
+	}
+
+	if opts.repl {
+		r := repl.New(cc)
+		for name, val := range vals {
+			r.Set(name, val)
+		}
+		return r.Run(stdin, stdout)
+	}
+
+	expr, err := eval.Compile(cc, fs.Arg(0))
+	if err != nil {
+		return compileError(err)
+	}
+
+	if opts.ast {
+		fmt.Fprintf(stdout, "ast:\n%s\n\n", eval.IndentByParentheses(expr.AST().String()))
+	}
+	if opts.bytecode {
+		fmt.Fprintf(stdout, "bytecode:\n%s\n", eval.PrintExpr(expr))
+	}
+	if opts.dot {
+		fmt.Fprintln(stdout, expr.ToDot())
+	}
+
+	if opts.bench > 0 {
+		return bench(stdout, expr, eval.NewCtxWithMap(cc, vals), opts.bench)
+	}
+
+	if opts.debug {
+		cc.DebugWriter = stdout
+		eval.EnableDebug(cc)
+		if expr, err = eval.Compile(cc, fs.Arg(0)); err != nil {
+			return compileError(err)
+		}
+	}
+
+	ctx := eval.NewCtxWithMap(cc, vals)
+	if opts.explain {
+		_, trace, err := expr.EvalBoolWithTrace(ctx)
+		fmt.Fprintf(stdout, "trace:\n%s\n\n", trace)
+		if err != nil {
+			return fmt.Errorf("eval error: %w", err)
+		}
+	}
+
+	res, err := expr.Eval(ctx)
+	if err != nil {
+		return fmt.Errorf("eval error: %w", err)
+	}
+	return printResult(stdout, res)
+}
+
+// compileError points out the position of the compile error in the expression
+func compileError(err error) error {
+	var ce *eval.CompileError
+	if errors.As(err, &ce) {
+		return fmt.Errorf("compile error at line %d, column %d: %w\n%s", ce.Line, ce.Column, ce.Err, ce.Snippet)
+	}
+	return fmt.Errorf("compile error: %w", err)
+}
+
+// loadCtx decodes the JSON object of the selector values,
+// the integers are decoded as int64, and the arrays of the same type as []int64 or []string
+func loadCtx(opts options) (map[string]interface{}, error) {
+	data := []byte(opts.ctx)
+	if opts.ctxFile != "" {
+		if opts.ctx != "" {
+			return nil, errors.New("eval: --ctx and --ctx-file are exclusive")
+		}
+		var err error
+		if data, err = os.ReadFile(opts.ctxFile); err != nil {
+			return nil, err
+		}
+	}
+
+	vals := make(map[string]interface{})
+	if len(bytes.TrimSpace(data)) == 0 {
+		return vals, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid ctx: %w", err)
+	}
+	for k, v := range raw {
+		vals[k] = eval.ConvertJSON(v)
+	}
+	return vals, nil
+}
+
+func bench(w io.Writer, expr *eval.Expr, ctx *eval.Ctx, n int) error {
+	var (
+		res eval.Value
+		err error
+	)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		res, err = expr.Eval(ctx)
+	}
+	elapsed := time.Since(start)
+	if err != nil {
+		return fmt.Errorf("eval error: %w", err)
+	}
+	fmt.Fprintf(w, "%d evaluations, %v total, %v/op\n", n, elapsed, elapsed/time.Duration(n))
+	return printResult(w, res)
+}
+
+func printResult(w io.Writer, res eval.Value) error {
+	bs, err := json.Marshal(res)
+	if err != nil {
+		_, err = fmt.Fprintf(w, "%v\n", res)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", bs)
+	return err
+}
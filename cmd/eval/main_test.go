@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		args   []string
+		stdin  string
+		want   []string
+		errMsg string
+	}{
+		{
+			args: []string{"--ctx", `{"age": 20, "tags": ["a", "b"]}`, `(and (> age 18) (in "a" tags))`},
+			want: []string{"true\n"},
+		},
+		{
+			args: []string{"--ctx", `{"price": 1.5, "cnt": 2}`, `(* price cnt)`},
+			want: []string{"3\n"},
+		},
+		{
+			args: []string{"--infix", "--ctx", `{"age": 20}`, `age > 18 && age < 60`},
+			want: []string{"true\n"},
+		},
+		{
+			args: []string{"--ast", "--bytecode", "--dot", "--ctx", `{"age": 20}`, `(> age 18)`},
+			want: []string{"ast:\n(> age 18)", "bytecode:\n", "digraph expr {", "true\n"},
+		},
+		{
+			args: []string{"--explain", "--ctx", `{"age": 20}`, `(or (< age 18) (> age 60))`},
+			want: []string{"trace:\n(or (< age 18) (> age 60)) => false\n", "false\n"},
+		},
+		{
+			args: []string{"--debug", "--ctx", `{"age": 20}`, `(> age 18)`},
+			want: []string{"Stack Frame:", "true\n"},
+		},
+		{
+			args: []string{"--bench", "10", `(+ 1 2)`},
+			want: []string{"10 evaluations", "3\n"},
+		},
+		{
+			args:   []string{`(and (> age 18)`},
+			errMsg: "compile error at line 1, column 1: parentheses unmatched error\n(and (> age 18)\n^",
+		},
+		{
+			args:   []string{"--ctx", `{"age": "a"}`, `(> age 18)`},
+			errMsg: "eval error",
+		},
+		{
+			args:   []string{"--ctx", `[1]`, `(> age 18)`},
+			errMsg: "invalid ctx",
+		},
+		{
+			args:  []string{"--repl", "--ctx", `{"age": 20}`},
+			stdin: "(> age 18)\n",
+			want:  []string{">>> true\n"},
+		},
+		{
+			args:   []string{},
+			errMsg: "exactly one expression is required",
+		},
+		{
+			args:   []string{"--repl", "(> age 18)"},
+			errMsg: "no expression is allowed",
+		},
+	}
+
+	for _, c := range testCases {
+		var stdout, stderr bytes.Buffer
+		err := run(c.args, strings.NewReader(c.stdin), &stdout, &stderr)
+		if c.errMsg != "" {
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Errorf("args: %q, want error: %s, got: %v", c.args, c.errMsg, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("args: %q, unexpected error: %v", c.args, err)
+			continue
+		}
+		for _, want := range c.want {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("args: %q, want: %q, got: %q", c.args, want, stdout.String())
+			}
+		}
+	}
+}
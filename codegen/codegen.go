@@ -0,0 +1,316 @@
+// Package codegen generates the Go source code of the compiled expressions,
+// the generated functions are equivalent to the expressions, and they can be
+// built into the binary for the rules evaluated most frequently.
+//
+// For each rule, a constructor named New<Name> is generated, it resolves the operators
+// from a CompileConfig, and returns a function evaluating the rule against a Ctx:
+//
+//	func NewIsAdult(cc *eval.CompileConfig) (func(ctx *eval.Ctx) (eval.Value, error), error)
+//
+// The constants are inlined, the selectors are read by their keys,
+// and the and/or/if expressions are turned into Go control flow.
+// The missing selectors always fail the generated functions,
+// the SelectorDefaults and the MissingSelector policy are not supported.
+package codegen
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/larry618/eval"
+	"github.com/larry618/eval/ast"
+)
+
+// Rule is an expression to be generated
+type Rule struct {
+	// Name of the rule, it should be an exported Go identifier
+	Name string
+	Expr *eval.Expr
+}
+
+// Generate returns the formatted source code of the package containing the rules.
+// The selector keys are resolved from cc.SelectorMap, the unregistered selectors
+// are read by their names with eval.UndefinedSelKey.
+func Generate(cc *eval.CompileConfig, pkg string, rules ...Rule) ([]byte, error) {
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("codegen error, invalid package name: %q", pkg)
+	}
+	if cc == nil {
+		cc = eval.NewCompileConfig()
+	}
+
+	var (
+		body    strings.Builder
+		usesFmt bool
+	)
+	names := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if !token.IsIdentifier(r.Name) || !token.IsExported(r.Name) {
+			return nil, fmt.Errorf("codegen error, invalid rule name: %q", r.Name)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("codegen error, duplicate rule name: %s", r.Name)
+		}
+		names[r.Name] = true
+
+		g := &generator{cc: cc, opIdx: make(map[string]int)}
+		if err := g.rule(&body, r); err != nil {
+			return nil, err
+		}
+		usesFmt = usesFmt || g.usesFmt
+	}
+
+	var sb strings.Builder
+	sb.WriteString("// Code generated by github.com/larry618/eval/codegen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&sb, "package %s\n\n", pkg)
+	if usesFmt {
+		sb.WriteString("import (\n\t\"fmt\"\n\n\t\"github.com/larry618/eval\"\n)\n")
+	} else {
+		sb.WriteString("import \"github.com/larry618/eval\"\n")
+	}
+	sb.WriteString(body.String())
+
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return nil, fmt.Errorf("codegen error, format source: %w", err)
+	}
+	return src, nil
+}
+
+type generator struct {
+	cc      *eval.CompileConfig
+	body    strings.Builder
+	vars    int
+	usesFmt bool
+
+	ops   []string
+	opIdx map[string]int
+}
+
+func (g *generator) rule(sb *strings.Builder, r Rule) error {
+	root := r.Expr.AST()
+	res, err := g.node(root)
+	if err != nil {
+		return fmt.Errorf("codegen error, rule: %s, %w", r.Name, err)
+	}
+
+	fmt.Fprintf(sb, "\n// New%s returns the function evaluating:\n//\n", r.Name)
+	for _, line := range strings.Split(root.String(), "\n") {
+		fmt.Fprintf(sb, "//\t%s\n", line)
+	}
+	fmt.Fprintf(sb, "func New%s(cc *eval.CompileConfig) (func(ctx *eval.Ctx) (eval.Value, error), error) {\n", r.Name)
+	if len(g.ops) != 0 {
+		g.usesFmt = true
+		fmt.Fprintf(sb, "ops := make([]eval.Operator, %d)\n", len(g.ops))
+		sb.WriteString("for i, name := range []string{")
+		for i, name := range g.ops {
+			if i != 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(strconv.Quote(name))
+		}
+		sb.WriteString("} {\n")
+		sb.WriteString("op, exist := eval.LookupOperator(cc, name)\n")
+		sb.WriteString("if !exist {\nreturn nil, fmt.Errorf(\"unknown operator: %s\", name)\n}\n")
+		sb.WriteString("ops[i] = op\n}\n\n")
+	}
+	sb.WriteString("return func(ctx *eval.Ctx) (eval.Value, error) {\n")
+	sb.WriteString(g.body.String())
+	fmt.Fprintf(sb, "return %s, nil\n}, nil\n}\n", res)
+	return nil
+}
+
+func (g *generator) newVar() string {
+	g.vars++
+	return fmt.Sprintf("v%d", g.vars)
+}
+
+func (g *generator) operator(name string) string {
+	idx, exist := g.opIdx[name]
+	if !exist {
+		idx = len(g.ops)
+		g.opIdx[name] = idx
+		g.ops = append(g.ops, name)
+	}
+	return fmt.Sprintf("ops[%d]", idx)
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	if strings.Contains(format, "fmt.") {
+		g.usesFmt = true
+	}
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+// value evaluates the node as an interface value, so that the type can be asserted
+func (g *generator) value(n *ast.Node) (string, error) {
+	v, err := g.node(n)
+	if err != nil || n.Kind != ast.Constant {
+		return v, err
+	}
+	return fmt.Sprintf("eval.Value(%s)", v), nil
+}
+
+// node writes the statements evaluating the node to the body,
+// and returns the Go expression of its value
+func (g *generator) node(n *ast.Node) (string, error) {
+	switch n.Kind {
+	case ast.Constant:
+		return literal(n.Value)
+	case ast.Selector:
+		name := n.Value.(string)
+		key := eval.UndefinedSelKey
+		if k, exist := g.cc.SelectorMap[name]; exist {
+			key = k
+		}
+		v := g.newVar()
+		g.printf("%s, err := ctx.GetValue(%s, %s)\n", v, selectorKey(key), strconv.Quote(name))
+		g.printf("if err != nil {\nreturn nil, err\n}\n")
+		return v, nil
+	case ast.Cond:
+		return g.cond(n)
+	}
+
+	name := fmt.Sprint(n.Value)
+	if name == "and" || name == "&" || name == "or" || name == "|" {
+		return g.boolOp(n, name == "and" || name == "&")
+	}
+
+	params := make([]string, len(n.Children))
+	for i, child := range n.Children {
+		p, err := g.node(child)
+		if err != nil {
+			return "", err
+		}
+		params[i] = p
+	}
+	v := g.newVar()
+	g.printf("%s, err := %s(ctx, []eval.Value{%s})\n", v, g.operator(name), strings.Join(params, ", "))
+	g.printf("if err != nil {\nreturn nil, fmt.Errorf(\"operator execution error, operator: %%v, error: %%w\", %s, err)\n}\n",
+		strconv.Quote(name))
+	return v, nil
+}
+
+func (g *generator) cond(n *ast.Node) (string, error) {
+	if len(n.Children) != 3 {
+		return "", fmt.Errorf("if parameters count error: %d", len(n.Children))
+	}
+	c, err := g.value(n.Children[0])
+	if err != nil {
+		return "", err
+	}
+
+	v := g.newVar()
+	g.printf("var %s eval.Value\n", v)
+	g.printf("if b, ok := %s.(bool); !ok {\n", c)
+	g.printf("return nil, fmt.Errorf(\"eval error, result type of if condition should be bool, got: [%%v]\", %s)\n", c)
+	g.printf("} else if b {\n")
+	then, err := g.node(n.Children[1])
+	if err != nil {
+		return "", err
+	}
+	g.printf("%s = %s\n} else {\n", v, then)
+	otherwise, err := g.node(n.Children[2])
+	if err != nil {
+		return "", err
+	}
+	g.printf("%s = %s\n}\n", v, otherwise)
+	return v, nil
+}
+
+// boolOp evaluates the operands in a function literal, which returns the first bool operand
+// deciding the result, the operator is executed only if the operands are not all bool values
+func (g *generator) boolOp(n *ast.Node, isAnd bool) (string, error) {
+	v := g.newVar()
+	g.printf("%s, err := func() (eval.Value, error) {\n", v)
+
+	params := make([]string, len(n.Children))
+	for i, child := range n.Children {
+		p, err := g.value(child)
+		if err != nil {
+			return "", err
+		}
+		params[i] = p
+
+		switch {
+		case i == len(n.Children)-1:
+			g.printf("if b, ok := %s.(bool); ok {\nreturn b, nil\n}\n", p)
+		case isAnd:
+			g.printf("if b, ok := %s.(bool); ok && !b {\nreturn b, nil\n}\n", p)
+		default:
+			g.printf("if b, ok := %s.(bool); ok && b {\nreturn b, nil\n}\n", p)
+		}
+	}
+
+	name := fmt.Sprint(n.Value)
+	g.printf("res, err := %s(ctx, []eval.Value{%s})\n", g.operator(name), strings.Join(params, ", "))
+	g.printf("if err != nil {\nreturn nil, fmt.Errorf(\"operator execution error, operator: %%v, error: %%w\", %s, err)\n}\n",
+		strconv.Quote(name))
+	g.printf("return res, nil\n}()\n")
+	g.printf("if err != nil {\nreturn nil, err\n}\n")
+	return v, nil
+}
+
+func selectorKey(key eval.SelectorKey) string {
+	if key == eval.UndefinedSelKey {
+		return "eval.UndefinedSelKey"
+	}
+	return fmt.Sprintf("eval.SelectorKey(%d)", key)
+}
+
+// literal returns the Go expression of the constant
+func literal(v eval.Value) (string, error) {
+	switch c := v.(type) {
+	case nil:
+		return "nil", nil
+	case bool:
+		return strconv.FormatBool(c), nil
+	case int64:
+		return fmt.Sprintf("int64(%d)", c), nil
+	case float64:
+		return fmt.Sprintf("float64(%s)", strconv.FormatFloat(c, 'g', -1, 64)), nil
+	case string:
+		return strconv.Quote(c), nil
+	case []int64:
+		items := make([]string, len(c))
+		for i, item := range c {
+			items[i] = strconv.FormatInt(item, 10)
+		}
+		return fmt.Sprintf("[]int64{%s}", strings.Join(items, ", ")), nil
+	case []string:
+		items := make([]string, len(c))
+		for i, item := range c {
+			items[i] = strconv.Quote(item)
+		}
+		return fmt.Sprintf("[]string{%s}", strings.Join(items, ", ")), nil
+	case []eval.Value:
+		items := make([]string, len(c))
+		for i, item := range c {
+			var err error
+			if items[i], err = literal(item); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("[]eval.Value{%s}", strings.Join(items, ", ")), nil
+	case map[string]eval.Value:
+		keys := make([]string, 0, len(c))
+		for k := range c {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			item, err := literal(c[k])
+			if err != nil {
+				return "", err
+			}
+			items[i] = fmt.Sprintf("%s: %s", strconv.Quote(k), item)
+		}
+		return fmt.Sprintf("map[string]eval.Value{%s}", strings.Join(items, ", ")), nil
+	}
+	return "", fmt.Errorf("unsupported constant type: %T", v)
+}
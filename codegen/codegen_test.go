@@ -0,0 +1,143 @@
+package codegen_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/larry618/eval"
+	"github.com/larry618/eval/codegen"
+	"github.com/larry618/eval/codegen/internal/testrules"
+)
+
+var update = flag.Bool("update", false, "update the generated test rules")
+
+const generatedFile = "internal/testrules/rules.go"
+
+type testRule struct {
+	name string
+	expr string
+	opts []eval.CompileOption
+	// constructor of the generated rule
+	newFunc func(cc *eval.CompileConfig) (func(ctx *eval.Ctx) (eval.Value, error), error)
+}
+
+var testRules = []testRule{
+	{
+		name:    "IsAdultUS",
+		expr:    `(and (> age 18) (= country "US"))`,
+		newFunc: testrules.NewIsAdultUS,
+	},
+	{
+		name:    "Score",
+		expr:    `(if (in "vip" tags) (* score 2) (+ score bonus))`,
+		newFunc: testrules.NewScore,
+	},
+	{
+		name:    "Blocked",
+		expr:    `(or (is_blocked user) (in country ("KP" "IR")) (< age 0))`,
+		newFunc: testrules.NewBlocked,
+	},
+	{
+		name:    "Unoptimized",
+		expr:    `(or false (and (> age 60) true) (= (+ 1 2) score))`,
+		opts:    []eval.CompileOption{eval.Optimizations(false)},
+		newFunc: testrules.NewUnoptimized,
+	},
+	{
+		name:    "Constant",
+		expr:    `(+ 1 2)`,
+		newFunc: testrules.NewConstant,
+	},
+}
+
+func newCompileConfig(opts ...eval.CompileOption) *eval.CompileConfig {
+	cc := eval.NewCompileConfig(append(opts, eval.EnableStringSelectors)...)
+	cc.SelectorMap = map[string]eval.SelectorKey{
+		"age":     1,
+		"country": 2,
+		"tags":    3,
+		"score":   4,
+		"user":    5,
+	}
+	err := eval.RegisterOperator(cc, "is_blocked", func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		return params[0] == "u0", nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return cc
+}
+
+func generate(t *testing.T) []byte {
+	rules := make([]codegen.Rule, len(testRules))
+	for i, r := range testRules {
+		expr, err := eval.Compile(newCompileConfig(r.opts...), r.expr)
+		if err != nil {
+			t.Fatalf("compile %s error: %v", r.name, err)
+		}
+		rules[i] = codegen.Rule{Name: r.name, Expr: expr}
+	}
+
+	src, err := codegen.Generate(newCompileConfig(), "testrules", rules...)
+	if err != nil {
+		t.Fatalf("generate error: %v", err)
+	}
+	return src
+}
+
+func TestGenerate(t *testing.T) {
+	src := generate(t)
+	if *update {
+		if err := os.WriteFile(generatedFile, src, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Fatalf("%s is out of date, run go test with -update", generatedFile)
+	}
+}
+
+func TestGeneratedRules(t *testing.T) {
+	ctxs := []map[string]interface{}{
+		{"age": 20, "country": "US", "tags": []string{"vip"}, "score": 10, "bonus": 1, "user": "u0"},
+		{"age": 70, "country": "CN", "tags": []string{}, "score": 3, "bonus": 2, "user": "u1"},
+		{"age": 10, "country": "IR", "tags": []string{"new"}, "score": 5, "user": "u2"},
+		{"age": -1, "country": "US", "score": 1, "bonus": 1, "user": "u3"},
+	}
+
+	for _, r := range testRules {
+		cc := newCompileConfig(r.opts...)
+		expr, err := eval.Compile(cc, r.expr)
+		if err != nil {
+			t.Fatalf("compile %s error: %v", r.name, err)
+		}
+		fn, err := r.newFunc(cc)
+		if err != nil {
+			t.Fatalf("new %s error: %v", r.name, err)
+		}
+
+		for _, vals := range ctxs {
+			want, wantErr := expr.Eval(eval.NewCtxWithMap(cc, vals))
+			got, gotErr := fn(eval.NewCtxWithMap(cc, vals))
+			if !reflect.DeepEqual(got, want) || (gotErr == nil) != (wantErr == nil) {
+				t.Errorf("rule: %s, vals: %v, want: %v, %v, got: %v, %v", r.name, vals, want, wantErr, got, gotErr)
+			}
+			if gotErr != nil && gotErr.Error() != wantErr.Error() {
+				t.Errorf("rule: %s, want error: %v, got: %v", r.name, wantErr, gotErr)
+			}
+		}
+	}
+
+	// the operators are resolved from the config
+	if _, err := testrules.NewBlocked(eval.NewCompileConfig()); err == nil {
+		t.Fatal("want unknown operator error")
+	}
+}
@@ -0,0 +1,256 @@
+// Code generated by github.com/larry618/eval/codegen. DO NOT EDIT.
+
+package testrules
+
+import (
+	"fmt"
+
+	"github.com/larry618/eval"
+)
+
+// NewIsAdultUS returns the function evaluating:
+//
+//	(and (> age 18) (= country "US"))
+func NewIsAdultUS(cc *eval.CompileConfig) (func(ctx *eval.Ctx) (eval.Value, error), error) {
+	ops := make([]eval.Operator, 3)
+	for i, name := range []string{">", "=", "and"} {
+		op, exist := eval.LookupOperator(cc, name)
+		if !exist {
+			return nil, fmt.Errorf("unknown operator: %s", name)
+		}
+		ops[i] = op
+	}
+
+	return func(ctx *eval.Ctx) (eval.Value, error) {
+		v1, err := func() (eval.Value, error) {
+			v2, err := ctx.GetValue(eval.SelectorKey(1), "age")
+			if err != nil {
+				return nil, err
+			}
+			v3, err := ops[0](ctx, []eval.Value{v2, int64(18)})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", ">", err)
+			}
+			if b, ok := v3.(bool); ok && !b {
+				return b, nil
+			}
+			v4, err := ctx.GetValue(eval.SelectorKey(2), "country")
+			if err != nil {
+				return nil, err
+			}
+			v5, err := ops[1](ctx, []eval.Value{v4, "US"})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "=", err)
+			}
+			if b, ok := v5.(bool); ok {
+				return b, nil
+			}
+			res, err := ops[2](ctx, []eval.Value{v3, v5})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "and", err)
+			}
+			return res, nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+		return v1, nil
+	}, nil
+}
+
+// NewScore returns the function evaluating:
+//
+//	(if (in "vip" tags) (* score 2) (+ score bonus))
+func NewScore(cc *eval.CompileConfig) (func(ctx *eval.Ctx) (eval.Value, error), error) {
+	ops := make([]eval.Operator, 3)
+	for i, name := range []string{"in", "*", "+"} {
+		op, exist := eval.LookupOperator(cc, name)
+		if !exist {
+			return nil, fmt.Errorf("unknown operator: %s", name)
+		}
+		ops[i] = op
+	}
+
+	return func(ctx *eval.Ctx) (eval.Value, error) {
+		v1, err := ctx.GetValue(eval.SelectorKey(3), "tags")
+		if err != nil {
+			return nil, err
+		}
+		v2, err := ops[0](ctx, []eval.Value{"vip", v1})
+		if err != nil {
+			return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "in", err)
+		}
+		var v3 eval.Value
+		if b, ok := v2.(bool); !ok {
+			return nil, fmt.Errorf("eval error, result type of if condition should be bool, got: [%v]", v2)
+		} else if b {
+			v4, err := ctx.GetValue(eval.SelectorKey(4), "score")
+			if err != nil {
+				return nil, err
+			}
+			v5, err := ops[1](ctx, []eval.Value{v4, int64(2)})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "*", err)
+			}
+			v3 = v5
+		} else {
+			v6, err := ctx.GetValue(eval.SelectorKey(4), "score")
+			if err != nil {
+				return nil, err
+			}
+			v7, err := ctx.GetValue(eval.UndefinedSelKey, "bonus")
+			if err != nil {
+				return nil, err
+			}
+			v8, err := ops[2](ctx, []eval.Value{v6, v7})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "+", err)
+			}
+			v3 = v8
+		}
+		return v3, nil
+	}, nil
+}
+
+// NewBlocked returns the function evaluating:
+//
+//	(or (is_blocked user) (in country ("KP" "IR")) (< age 0))
+func NewBlocked(cc *eval.CompileConfig) (func(ctx *eval.Ctx) (eval.Value, error), error) {
+	ops := make([]eval.Operator, 4)
+	for i, name := range []string{"is_blocked", "in", "<", "or"} {
+		op, exist := eval.LookupOperator(cc, name)
+		if !exist {
+			return nil, fmt.Errorf("unknown operator: %s", name)
+		}
+		ops[i] = op
+	}
+
+	return func(ctx *eval.Ctx) (eval.Value, error) {
+		v1, err := func() (eval.Value, error) {
+			v2, err := ctx.GetValue(eval.SelectorKey(5), "user")
+			if err != nil {
+				return nil, err
+			}
+			v3, err := ops[0](ctx, []eval.Value{v2})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "is_blocked", err)
+			}
+			if b, ok := v3.(bool); ok && b {
+				return b, nil
+			}
+			v4, err := ctx.GetValue(eval.SelectorKey(2), "country")
+			if err != nil {
+				return nil, err
+			}
+			v5, err := ops[1](ctx, []eval.Value{v4, []string{"KP", "IR"}})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "in", err)
+			}
+			if b, ok := v5.(bool); ok && b {
+				return b, nil
+			}
+			v6, err := ctx.GetValue(eval.SelectorKey(1), "age")
+			if err != nil {
+				return nil, err
+			}
+			v7, err := ops[2](ctx, []eval.Value{v6, int64(0)})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "<", err)
+			}
+			if b, ok := v7.(bool); ok {
+				return b, nil
+			}
+			res, err := ops[3](ctx, []eval.Value{v3, v5, v7})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "or", err)
+			}
+			return res, nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+		return v1, nil
+	}, nil
+}
+
+// NewUnoptimized returns the function evaluating:
+//
+//	(or false (and (> age 60) true) (= (+ 1 2) score))
+func NewUnoptimized(cc *eval.CompileConfig) (func(ctx *eval.Ctx) (eval.Value, error), error) {
+	ops := make([]eval.Operator, 5)
+	for i, name := range []string{">", "and", "+", "=", "or"} {
+		op, exist := eval.LookupOperator(cc, name)
+		if !exist {
+			return nil, fmt.Errorf("unknown operator: %s", name)
+		}
+		ops[i] = op
+	}
+
+	return func(ctx *eval.Ctx) (eval.Value, error) {
+		v1, err := func() (eval.Value, error) {
+			if b, ok := eval.Value(false).(bool); ok && b {
+				return b, nil
+			}
+			v2, err := func() (eval.Value, error) {
+				v3, err := ctx.GetValue(eval.SelectorKey(1), "age")
+				if err != nil {
+					return nil, err
+				}
+				v4, err := ops[0](ctx, []eval.Value{v3, int64(60)})
+				if err != nil {
+					return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", ">", err)
+				}
+				if b, ok := v4.(bool); ok && !b {
+					return b, nil
+				}
+				if b, ok := eval.Value(true).(bool); ok {
+					return b, nil
+				}
+				res, err := ops[1](ctx, []eval.Value{v4, eval.Value(true)})
+				if err != nil {
+					return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "and", err)
+				}
+				return res, nil
+			}()
+			if err != nil {
+				return nil, err
+			}
+			if b, ok := v2.(bool); ok && b {
+				return b, nil
+			}
+			v5, err := ops[2](ctx, []eval.Value{int64(1), int64(2)})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "+", err)
+			}
+			v6, err := ctx.GetValue(eval.SelectorKey(4), "score")
+			if err != nil {
+				return nil, err
+			}
+			v7, err := ops[3](ctx, []eval.Value{v5, v6})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "=", err)
+			}
+			if b, ok := v7.(bool); ok {
+				return b, nil
+			}
+			res, err := ops[4](ctx, []eval.Value{eval.Value(false), v2, v7})
+			if err != nil {
+				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", "or", err)
+			}
+			return res, nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+		return v1, nil
+	}, nil
+}
+
+// NewConstant returns the function evaluating:
+//
+//	3
+func NewConstant(cc *eval.CompileConfig) (func(ctx *eval.Ctx) (eval.Value, error), error) {
+	return func(ctx *eval.Ctx) (eval.Value, error) {
+		return int64(3), nil
+	}, nil
+}
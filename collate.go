@@ -0,0 +1,124 @@
+package eval
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Collator compares the strings in the order of a locale, it returns -1, 0 or 1
+// if a is less than, equal to or greater than b, e.g. the CompareString of golang.org/x/text/collate
+type Collator func(a, b string) int
+
+// caseInsensitiveModes are the modes of the equality operators ignoring the cases with the CaseInsensitive option
+var caseInsensitiveModes = map[string]mode{
+	"eq": equals, "ne": notEquals, "=": equals, "!=": notEquals,
+}
+
+// caseInsensitiveEquals compares the strings ignoring the cases by the Unicode case folding,
+// the operator of the other params is executed by next
+type caseInsensitiveEquals struct {
+	mode mode
+	next Operator
+}
+
+func (c caseInsensitiveEquals) execute(ctx *Ctx, params []Value) (Value, error) {
+	if len(params) < 2 || (c.mode == notEquals && len(params) != 2) {
+		return c.next(ctx, params)
+	}
+	x, ok := params[0].(string)
+	if !ok {
+		return c.next(ctx, params)
+	}
+	for _, p := range params[1:] {
+		if _, ok := p.(string); !ok {
+			return c.next(ctx, params)
+		}
+	}
+
+	for _, p := range params[1:] {
+		if !strings.EqualFold(x, p.(string)) {
+			return c.mode == notEquals, nil
+		}
+	}
+	return c.mode == equals, nil
+}
+
+// equalsIgnoreCase reports whether the strings are equal ignoring the cases, e.g. (equalsIgnoreCase email "A@b.com")
+func equalsIgnoreCase(_ *Ctx, params []Value) (Value, error) {
+	const op = "equalsIgnoreCase"
+	if len(params) != 2 {
+		return nil, ParamsCountError(op, 2, len(params))
+	}
+	x, ok := params[0].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, params[0])
+	}
+	y, ok := params[1].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, params[1])
+	}
+	return strings.EqualFold(x, y), nil
+}
+
+// collationCompare compares the strings by the collator of the locale, e.g. (compare "de" a b),
+// the locales are looked up in the collators, then by their languages, e.g. "de-AT" => "de".
+// The strings of the other locales are compared by defaultCollate.
+type collationCompare struct {
+	collators map[string]Collator
+}
+
+func (c collationCompare) execute(_ *Ctx, params []Value) (Value, error) {
+	const op = "compare"
+	if len(params) != 3 {
+		return nil, ParamsCountError(op, 3, len(params))
+	}
+	strs := make([]string, len(params))
+	for i, p := range params {
+		s, ok := p.(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, p)
+		}
+		strs[i] = s
+	}
+	return int64(c.collator(strs[0])(strs[1], strs[2])), nil
+}
+
+func (c collationCompare) collator(locale string) Collator {
+	if collate, exist := c.collators[locale]; exist {
+		return collate
+	}
+	if i := strings.IndexAny(locale, "-_"); i != -1 {
+		if collate, exist := c.collators[locale[:i]]; exist {
+			return collate
+		}
+	}
+	return defaultCollate
+}
+
+// defaultCollate compares the strings rune by rune ignoring the cases first,
+// and the ties are broken by the byte order, e.g. "apple" < "Banana" < "banana"
+func defaultCollate(a, b string) int {
+	for x, y := a, b; x != "" && y != ""; {
+		r1, size1 := utf8.DecodeRuneInString(x)
+		r2, size2 := utf8.DecodeRuneInString(y)
+		x, y = x[size1:], y[size2:]
+		if c := cmpRune(unicode.ToLower(r1), unicode.ToLower(r2)); c != 0 {
+			return c
+		}
+	}
+	if c := cmpRune(rune(utf8.RuneCountInString(a)), rune(utf8.RuneCountInString(b))); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func cmpRune(x, y rune) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
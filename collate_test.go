@@ -0,0 +1,127 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestCaseInsensitive(t *testing.T) {
+	vals := map[string]interface{}{
+		"email": "Larry@Example.com",
+		"name":  "ÉCOLE",
+		"id":    7,
+	}
+
+	testCases := []struct {
+		expr string
+		opts []CompileOption
+		// the CaseInsensitive option is enabled unless disabled is true
+		disabled bool
+		res      Value
+		errMsg   string
+	}{
+		{expr: `(= email "larry@example.com")`, res: true},
+		{expr: `(eq email "LARRY@EXAMPLE.COM" "larry@example.com")`, res: true},
+		{expr: `(!= email "larry@example.com")`, res: false},
+		{expr: `(ne email "bob@example.com")`, res: true},
+		{expr: `(= name "école")`, res: true},
+		{expr: `(= id 7)`, res: true},
+		{expr: `(= email id)`, res: false},
+		{expr: `(= email "larry@example.com")`, disabled: true, res: false},
+		{expr: `;;;; case_insensitive:true
+(= email "larry@example.com")`, disabled: true, res: true},
+		{expr: `(= email "larry@example.com")`, opts: []CompileOption{EnableZeroAlloc}, res: true},
+		{expr: `(!= email "LARRY@example.com")`, opts: []CompileOption{EnableZeroAlloc}, res: false},
+		{expr: `(= email "larry@example.com")`, opts: []CompileOption{EnableZeroAlloc}, disabled: true, res: false},
+		{expr: `email == "larry@example.com"`, opts: []CompileOption{EnableInfixSyntax}, res: true},
+		{expr: `(equalsIgnoreCase email "LARRY@example.COM")`, disabled: true, res: true},
+		{expr: `(equalsIgnoreCase name "ecole")`, disabled: true, res: false},
+		{expr: `(equalsIgnoreCase email "x")`, opts: []CompileOption{EnableTypeCheck}, disabled: true, res: false},
+
+		{expr: `(equalsIgnoreCase email id)`, disabled: true, errMsg: "unexpected param type, operator: equalsIgnoreCase, expected: string"},
+		{expr: `(equalsIgnoreCase email)`, disabled: true, errMsg: "unexpected params count"},
+	}
+
+	for _, c := range testCases {
+		opts := append(c.opts, RegisterSelKeys(vals))
+		if !c.disabled {
+			opts = append(opts, EnableCaseInsensitive)
+		}
+		cc := NewCompileConfig(opts...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+
+	// the unmarshaled predicate ignores the cases with the config
+	cc := NewCompileConfig(EnableCaseInsensitive, EnableZeroAlloc, RegisterSelKeys(vals))
+	expr, err := Compile(cc, `(= email "LARRY@EXAMPLE.COM")`)
+	assertNil(t, err)
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	loaded, err := UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+	res, err := loaded.Eval(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, true)
+}
+
+func TestCompare(t *testing.T) {
+	vals := map[string]interface{}{
+		"a": "apple",
+		"b": "Banana",
+		"c": "banana",
+	}
+	// the reversed order, to tell the registered collators from the default one
+	reversed := func(x, y string) int { return -defaultCollate(x, y) }
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(compare "en" a b)`, res: int64(-1)},
+		{expr: `(compare "en" b c)`, res: int64(-1)},
+		{expr: `(compare "en" c b)`, res: int64(1)},
+		{expr: `(compare "" c "banana")`, res: int64(0)},
+		{expr: `(compare "en" "ban" c)`, res: int64(-1)},
+		{expr: `(compare "en" "Éclair" "eclair")`, res: int64(1)},
+		{expr: `(compare "de" a b)`, opts: []CompileOption{LocaleCollator("de", reversed)}, res: int64(1)},
+		{expr: `(compare "de-AT" a b)`, opts: []CompileOption{LocaleCollator("de", reversed)}, res: int64(1)},
+		{expr: `(compare "en" a b)`, opts: []CompileOption{LocaleCollator("de", reversed)}, res: int64(-1)},
+		{expr: `(< (compare "en" a b) 0)`, opts: []CompileOption{EnableTypeCheck}, res: true},
+
+		{expr: `(compare a b)`, errMsg: "unexpected params count, operator: compare, expected: 3, got: 2"},
+		{expr: `(compare "en" a 1)`, errMsg: "unexpected param type, operator: compare, expected: string"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "", b: "a", want: -1},
+		{a: "a", b: "B", want: -1},
+		{a: "B", b: "b", want: -1},
+		{a: "straße", b: "STRASSE", want: 1},
+		{a: "Zebra", b: "apple", want: 1},
+	} {
+		assertEquals(t, defaultCollate(c.a, c.b), c.want, c.a, c.b)
+		assertEquals(t, defaultCollate(c.b, c.a), -c.want, c.b, c.a)
+	}
+}
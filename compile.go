@@ -2,8 +2,13 @@ package eval
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/larry618/eval/ast"
 )
 
 type Option string
@@ -16,6 +21,17 @@ const (
 
 	Debug                 Option = "debug"
 	AllowUnknownSelectors Option = "allow_unknown_selectors"
+	TypeCheck             Option = "type_check"
+	StrictNumeric         Option = "strict_numeric"     // no promotion from int64 to float64
+	MemoizeSelectors      Option = "memoize_selectors"  // read each selector once per evaluation
+	NullPropagation       Option = "null_propagation"   // arithmetic with null results in null
+	RecoverPanics         Option = "recover_panics"     // convert the operator panics to ErrOperatorPanic
+	ZeroAlloc             Option = "zero_alloc"         // evaluate the boolean predicates without allocation
+	DecimalArithmetic     Option = "decimal_arithmetic" // exact arithmetic of the decimal literals and values
+	BigIntegers           Option = "big_integers"       // promote the integers beyond int64 to *big.Int
+	ByteLength            Option = "byte_length"        // len counts the bytes of the strings instead of the runes
+	CaseInsensitive       Option = "case_insensitive"   // the builtin string equality ignores the cases
+	NoShortCircuit        Option = "no_short_circuit"   // all the params of and/or are evaluated, e.g. to observe their side effects
 )
 
 var AllOptimizations = []Option{Reordering, FastEvaluation, ConstantFolding}
@@ -40,35 +56,202 @@ func CopyCompileConfig(origin *CompileConfig) *CompileConfig {
 	for k, v := range origin.CostsMap {
 		conf.CostsMap[k] = v
 	}
+	for k, v := range origin.SelectorTypes {
+		conf.SelectorTypes[k] = v
+	}
+	for k, v := range origin.OperatorSignatures {
+		conf.OperatorSignatures[k] = v
+	}
+	for k, v := range origin.OperatorArities {
+		conf.OperatorArities[k] = v
+	}
+	for k, v := range origin.CELFunctions {
+		conf.CELFunctions[k] = v
+	}
+	for k, v := range origin.Collators {
+		conf.Collators[k] = v
+	}
+	for k, v := range origin.OperatorSpecializers {
+		conf.OperatorSpecializers[k] = v
+	}
+	conf.operatorAccess = origin.operatorAccess.copy()
+	conf.selectorAccess = origin.selectorAccess.copy()
+	for k, v := range origin.OperatorShortCircuits {
+		conf.OperatorShortCircuits[k] = v
+	}
+	for k, v := range origin.OperatorMetas {
+		conf.OperatorMetas[k] = v
+	}
+	for k, v := range origin.SelectorDefaults {
+		conf.SelectorDefaults[k] = v
+	}
+	conf.MissingSelector = origin.MissingSelector
+	conf.ArithmeticPolicy = origin.ArithmeticPolicy
+	conf.ArithmeticSentinel = origin.ArithmeticSentinel
+	conf.DecimalScale = origin.DecimalScale
+	conf.ConversionPolicy = origin.ConversionPolicy
+	conf.SyntaxMode = origin.SyntaxMode
+	conf.Rewriters = append(conf.Rewriters, origin.Rewriters...)
+	conf.MaxSteps = origin.MaxSteps
+	conf.Limits = origin.Limits
+	conf.Backend = origin.Backend
+	conf.CompileCache = origin.CompileCache
+	conf.DebugWriter = origin.DebugWriter
+	conf.DebugHandler = origin.DebugHandler
+	conf.EvalHook = origin.EvalHook
 	return conf
 }
 
 type CompileOption func(conf *CompileConfig)
 
+// option returns the CompileOption which changes the version of the config,
+// so that the expressions cached by the CompileCache before the change are not used
+func option(apply func(c *CompileConfig)) CompileOption {
+	return func(c *CompileConfig) {
+		apply(c)
+		c.changed()
+	}
+}
+
 var (
-	EnableStringSelectors CompileOption = func(c *CompileConfig) {
+	EnableStringSelectors CompileOption = option(func(c *CompileConfig) {
 		c.CompileOptions[AllowUnknownSelectors] = true
-	}
-	EnableDebug CompileOption = func(c *CompileConfig) {
+	})
+	EnableDebug CompileOption = option(func(c *CompileConfig) {
 		c.CompileOptions[Debug] = true
-	}
+	})
+	DisableShortCircuit CompileOption = option(func(c *CompileConfig) {
+		c.CompileOptions[NoShortCircuit] = true
+	})
+	EnableInfixSyntax CompileOption = option(func(c *CompileConfig) {
+		c.SyntaxMode = InfixSyntax
+	})
+	EnableCELSyntax CompileOption = option(func(c *CompileConfig) {
+		c.SyntaxMode = CELSyntax
+	})
+	EnableSQLSyntax CompileOption = option(func(c *CompileConfig) {
+		c.SyntaxMode = SQLSyntax
+	})
+	EnableTypeCheck CompileOption = option(func(c *CompileConfig) {
+		c.CompileOptions[TypeCheck] = true
+	})
+	EnableSelectorMemoization CompileOption = option(func(c *CompileConfig) {
+		c.CompileOptions[MemoizeSelectors] = true
+	})
+	EnableNullPropagation CompileOption = option(func(c *CompileConfig) {
+		c.CompileOptions[NullPropagation] = true
+	})
+	EnablePanicRecovery CompileOption = option(func(c *CompileConfig) {
+		c.CompileOptions[RecoverPanics] = true
+	})
+	EnableZeroAlloc CompileOption = option(func(c *CompileConfig) {
+		c.CompileOptions[ZeroAlloc] = true
+	})
+	EnableDecimalArithmetic CompileOption = option(func(c *CompileConfig) {
+		c.CompileOptions[DecimalArithmetic] = true
+	})
+	EnableBigIntegers CompileOption = option(func(c *CompileConfig) {
+		c.CompileOptions[BigIntegers] = true
+	})
+	EnableByteLength CompileOption = option(func(c *CompileConfig) {
+		c.CompileOptions[ByteLength] = true
+	})
+	EnableCaseInsensitive CompileOption = option(func(c *CompileConfig) {
+		c.CompileOptions[CaseInsensitive] = true
+	})
 	Optimizations = func(enable bool, opts ...Option) CompileOption {
-		return func(c *CompileConfig) {
+		return option(func(c *CompileConfig) {
 			if len(opts) == 0 || opts[0] == Optimize {
 				opts = AllOptimizations
 			}
 			for _, opt := range opts {
 				c.CompileOptions[opt] = enable
 			}
-		}
+		})
+	}
+
+	LimitSteps = func(maxSteps int) CompileOption {
+		return option(func(c *CompileConfig) {
+			c.MaxSteps = maxSteps
+		})
+	}
+
+	LimitComplexity = func(limits ComplexityLimits) CompileOption {
+		return option(func(c *CompileConfig) {
+			c.Limits = limits
+		})
+	}
+
+	// SelectorCost declares the cost of the selector, e.g. the latency of fetching it
+	SelectorCost = func(name string, cost int) CompileOption {
+		return option(func(c *CompileConfig) {
+			c.CostsMap["selector."+name] = cost
+		})
+	}
+
+	// OperatorCost declares the cost of the operator
+	OperatorCost = func(name string, cost int) CompileOption {
+		return option(func(c *CompileConfig) {
+			c.CostsMap["operator."+name] = cost
+		})
+	}
+
+	// SelectorDefault declares the value of the selector used if it doesn't exist in the ctx
+	SelectorDefault = func(name string, val Value) CompileOption {
+		return option(func(c *CompileConfig) {
+			c.SelectorDefaults[name] = val
+		})
+	}
+
+	// OnArithmeticError decides the results of the integer divide-by-zero and overflow
+	OnArithmeticError = func(policy ArithmeticPolicy) CompileOption {
+		return option(func(c *CompileConfig) {
+			c.ArithmeticPolicy = policy
+		})
+	}
+
+	// DecimalScale sets the scale of the decimal results of mul and div
+	DecimalScale = func(scale int) CompileOption {
+		return option(func(c *CompileConfig) {
+			c.DecimalScale = scale
+		})
+	}
+
+	// ReturnOnArithmeticError returns the sentinel on the integer divide-by-zero and overflow
+	ReturnOnArithmeticError = func(sentinel Value) CompileOption {
+		return option(func(c *CompileConfig) {
+			c.ArithmeticPolicy = ArithmeticSentinel
+			c.ArithmeticSentinel = sentinel
+		})
+	}
+
+	// OnConversionError decides the results of the failed conversions of the conversion operators
+	OnConversionError = func(policy ConversionPolicy) CompileOption {
+		return option(func(c *CompileConfig) {
+			c.ConversionPolicy = policy
+		})
+	}
+
+	// LocaleCollator registers the collator of the locale used by the compare operator
+	LocaleCollator = func(locale string, collator Collator) CompileOption {
+		return option(func(c *CompileConfig) {
+			c.Collators[locale] = collator
+		})
+	}
+
+	// OnMissingSelector decides how the missing selectors without defaults are evaluated
+	OnMissingSelector = func(policy MissingSelectorPolicy) CompileOption {
+		return option(func(c *CompileConfig) {
+			c.MissingSelector = policy
+		})
 	}
 
 	RegisterSelKeys = func(vals map[string]interface{}) CompileOption {
-		return func(c *CompileConfig) {
+		return option(func(c *CompileConfig) {
 			for s := range vals {
 				GetOrRegisterKey(c, s)
 			}
-		}
+		})
 	}
 )
 
@@ -79,6 +262,17 @@ func NewCompileConfig(opts ...CompileOption) *CompileConfig {
 		OperatorMap:    make(map[string]Operator),
 		CompileOptions: make(map[Option]bool),
 		CostsMap:       make(map[string]int),
+
+		SelectorTypes:      make(map[string]Type),
+		OperatorSignatures: make(map[string]Signature),
+		OperatorArities:    make(map[string]Arity),
+		CELFunctions:       make(map[string]string),
+		Collators:          make(map[string]Collator),
+		SelectorDefaults:   make(map[string]Value),
+
+		OperatorSpecializers:  make(map[string]OperatorSpecializer),
+		OperatorMetas:         make(map[string]OperatorMeta),
+		OperatorShortCircuits: make(map[string]ShortCircuit),
 	}
 	for _, opt := range opts {
 		opt(conf)
@@ -87,15 +281,132 @@ func NewCompileConfig(opts ...CompileOption) *CompileConfig {
 }
 
 type CompileConfig struct {
+	// id and version identify the config and its changes in the CompileCache, they are the first words,
+	// so that they are 64-bit aligned for the atomic operations on the 32-bit platforms
+	id      uint64
+	version uint64
+
 	ConstantMap map[string]Value
 	SelectorMap map[string]SelectorKey
 	OperatorMap map[string]Operator
 
-	// cost of performance
+	// CostsMap declares the relative costs of the selectors and operators, the operands of
+	// the and/or operators are reordered by their costs with the Reordering option, so that
+	// the cheap ones are evaluated first. The keys are looked up in the order of
+	// "selector.<name>" or "operator.<name>", "<name>", then "selectors" or "operators".
+	// Note that the reordering keeps the result, but the selectors and operators may be
+	// executed in a different order, e.g. the error of a skipped operand is not reported.
 	CostsMap map[string]int
 
 	// compile options
 	CompileOptions map[Option]bool
+
+	// SelectorTypes and OperatorSignatures declare the types used by the
+	// compile-time type checking, which is enabled by the TypeCheck option.
+	// The undeclared selectors and operators are not checked.
+	SelectorTypes      map[string]Type
+	OperatorSignatures map[string]Signature
+
+	// OperatorSpecializers build the specialized operators registered to OperatorMap
+	// with their constant params at compile time, see RegisterSpecializer.
+	OperatorSpecializers map[string]OperatorSpecializer
+
+	// OperatorArities declare the range of the params count of the operators,
+	// which are checked at compile time. The undeclared operators are not checked.
+	OperatorArities map[string]Arity
+
+	// OperatorShortCircuits declare the operators registered to OperatorMap short-circuiting like and/or,
+	// see RegisterShortCircuit.
+	OperatorShortCircuits map[string]ShortCircuit
+
+	// OperatorMetas describe the operators registered to OperatorMap for the introspection,
+	// see RegisterOperatorMeta and Operators.
+	OperatorMetas map[string]OperatorMeta
+
+	// SelectorDefaults declare the values of the selectors which don't exist in the ctx,
+	// i.e. the Selector returns an error wrapping ErrSelectorNotExist.
+	// The missing selectors without defaults are evaluated by the MissingSelector policy.
+	SelectorDefaults map[string]Value
+	MissingSelector  MissingSelectorPolicy
+
+	// ArithmeticPolicy decides the results of the integer divide-by-zero and overflow
+	// in the builtin arithmetic operators, the ArithmeticSentinel value is returned by the
+	// ArithmeticSentinel policy, it should be of the same type as the results, e.g. int64(0).
+	ArithmeticPolicy   ArithmeticPolicy
+	ArithmeticSentinel Value
+
+	// DecimalScale is the max number of the fractional digits of the decimal results of mul and div
+	// with the DecimalArithmetic option, which are rounded half away from zero. It's 8 if not set.
+	DecimalScale int
+
+	// ConversionPolicy decides the results of the failed conversions of the conversion operators,
+	// e.g. (toInt "abc") fails by default, or results in null with the ConversionNull policy.
+	ConversionPolicy ConversionPolicy
+
+	// syntax of the expression source, prefix notation by default
+	SyntaxMode SyntaxMode
+
+	// CELFunctions maps the names of the CEL functions to the operators,
+	// which are used in the CEL syntax, e.g. "size" => "len".
+	// The unmapped functions are called as the operators of the same names.
+	CELFunctions map[string]string
+
+	// Collators compare the strings in the order of the locales by the compare operator,
+	// e.g. (compare "de" a b), the strings of the unregistered locales are compared ignoring the cases first.
+	Collators map[string]Collator
+
+	// Backend executes the compiled expressions, the bytecode interpreter by default
+	Backend Backend
+
+	// CompileCache caches the expressions compiled by Compile with this config, nothing is cached if it is nil
+	CompileCache *CompileCache
+
+	// Rewriters rewrite the syntax tree in order before the bytecode is generated,
+	// each of them is applied to all the nodes bottom-up by ast.Rewrite.
+	// The operators and selectors in the expression are not required to be registered
+	// until all the rewriters are applied.
+	Rewriters []ast.RewriteFunc
+
+	// MaxSteps is the maximum number of instructions executed in an evaluation,
+	// the evaluation aborts with ErrStepLimitExceeded once it is exceeded.
+	// There is no limit if it is not positive.
+	MaxSteps int
+
+	// Limits limits the complexity of the expressions, the ComplexityError is returned by Compile
+	// if any of them is exceeded
+	Limits ComplexityLimits
+
+	// DebugWriter and DebugHandler receive the debug info of the expressions
+	// compiled with the Debug option, the text is written to DebugWriter and
+	// the structured events are passed to DebugHandler.
+	// The debug info is printed to the stdout if neither of them is set.
+	DebugWriter  io.Writer
+	DebugHandler func(DebugEvent)
+
+	// EvalHook observes the evaluation of each node, the expressions are evaluated
+	// recursively instead of by the Backend if it is set
+	EvalHook EvalHook
+
+	// operatorAccess and selectorAccess restrict the operators and selectors in the expressions,
+	// see AllowOperators and AllowSelectors
+	operatorAccess accessList
+	selectorAccess accessList
+}
+
+// SyntaxMode decides which front-end is used to parse the expression source.
+// All the syntax modes are compiled into the same bytecode.
+type SyntaxMode int
+
+const (
+	PrefixSyntax SyntaxMode = iota // (and (> age 18) (= country "US"))
+	InfixSyntax                    // age > 18 && country == "US"
+	CELSyntax                      // age > 18 && name.startsWith("L")
+	SQLSyntax                      // age > 18 AND country IN ('US', 'CA')
+)
+
+// changed increases the version of the config, it's called by the Register* functions and the options
+func (cc *CompileConfig) changed() {
+	atomic.AddUint64(&cc.version, 1)
 }
 
 func (cc *CompileConfig) getCosts(nodeType uint8, nodeName string) int {
@@ -130,13 +441,165 @@ func (cc *CompileConfig) getCosts(nodeType uint8, nodeName string) int {
 	return fallback
 }
 
+// getOperator returns the operator of the name, the builtin operators are searched first,
+// and the higher-order operators are searched last
+func (cc *CompileConfig) getOperator(name string) (Operator, bool) {
+	if op, exist := cc.getBuiltinOperator(name); exist {
+		return op, true
+	}
+	if op, exist := cc.OperatorMap[name]; exist {
+		return op, true
+	}
+	op, exist := higherOrderOperators[name]
+	return op, exist
+}
+
+// Arity is the range of the params count of an operator
+type Arity struct {
+	Min int
+	Max int // unlimited if it is negative
+}
+
+func (a Arity) String() string {
+	switch {
+	case a.Max < 0:
+		return fmt.Sprintf("at least %d", a.Min)
+	case a.Min == a.Max:
+		return strconv.Itoa(a.Min)
+	}
+	return fmt.Sprintf("%d to %d", a.Min, a.Max)
+}
+
+func (a Arity) accepts(cnt int) bool {
+	return cnt >= a.Min && (a.Max < 0 || cnt <= a.Max)
+}
+
+// checkArity checks the params count of the operator if its arity is declared
+func (cc *CompileConfig) checkArity(name string, cnt int) error {
+	if arity, exist := cc.OperatorArities[name]; exist && !arity.accepts(cnt) {
+		return fmt.Errorf("%s parameters count error (want: %s, got: %d)", name, arity, cnt)
+	}
+	return nil
+}
+
+func (cc *CompileConfig) decimalScale() int32 {
+	if cc.DecimalScale <= 0 || cc.DecimalScale > maxDecimalScale {
+		return defaultDecimalScale
+	}
+	return int32(cc.DecimalScale)
+}
+
+func (cc *CompileConfig) getBuiltinOperator(name string) (Operator, bool) {
+	op, exist := builtinOperators[name]
+	if cc.CompileOptions[StrictNumeric] {
+		if strictOp, isStrict := strictNumericOperators[name]; isStrict {
+			op, exist = strictOp, true
+		}
+	}
+	if m, isArithmetic := arithmeticModes[name]; isArithmetic && cc.ArithmeticPolicy != ArithmeticDefault {
+		op = arithmetic{
+			mode:     m,
+			strict:   cc.CompileOptions[StrictNumeric],
+			policy:   cc.ArithmeticPolicy,
+			sentinel: cc.ArithmeticSentinel,
+		}.execute
+	}
+	if m, isDivision := intDivisionModes[name]; isDivision && cc.ArithmeticPolicy != ArithmeticDefault {
+		op = intDivision{mode: m, policy: cc.ArithmeticPolicy, sentinel: cc.ArithmeticSentinel}.execute
+	}
+	if name == modeNames[length] && cc.CompileOptions[ByteLength] {
+		op = lengthOf{bytes: true}.execute
+	}
+	if m, isConversion := conversionModes[name]; isConversion && cc.ConversionPolicy == ConversionNull {
+		op = valueConvert{mode: m, nullOnError: true}.execute
+	}
+	if m, isEquality := caseInsensitiveModes[name]; isEquality && cc.CompileOptions[CaseInsensitive] {
+		op = caseInsensitiveEquals{mode: m, next: op}.execute
+	}
+	if name == "compare" && len(cc.Collators) != 0 {
+		op = collationCompare{collators: cc.Collators}.execute
+	}
+	if m, isBig := bigIntModes[name]; isBig && cc.CompileOptions[BigIntegers] {
+		// the overflowed results are promoted instead of being handled by the ArithmeticPolicy
+		op = bigIntArithmetic{mode: m, next: op}.execute
+	}
+	if m, isDecimal := decimalModes[name]; isDecimal && cc.CompileOptions[DecimalArithmetic] {
+		op = decimalArithmetic{mode: m, scale: cc.decimalScale(), next: op}.execute
+	}
+	if exist && cc.CompileOptions[NullPropagation] && nullPropagatingOperators[name] {
+		op = propagateNull(op)
+	}
+	return op, exist
+}
+
+// Compile compiles the expression with the config,
+// the compiled expression is cached if the CompileCache of the config is set.
 func Compile(originConf *CompileConfig, exprStr string) (*Expr, error) {
-	ast, conf, err := newParser(originConf, exprStr).parse()
+	if originConf != nil && originConf.CompileCache != nil {
+		return originConf.CompileCache.Compile(originConf, exprStr)
+	}
+	return compile(originConf, exprStr)
+}
+
+func compile(originConf *CompileConfig, exprStr string) (*Expr, error) {
+	ast, conf, err := parseAndRewrite(originConf, exprStr)
 	if err != nil {
 		return nil, err
 	}
+	return compileAstTree(conf, ast)
+}
+
+// parseAndRewrite parses the expression, applies the rewriters of the config,
+// and checks the types of the tree if TypeCheck is enabled
+func parseAndRewrite(originConf *CompileConfig, exprStr string) (*astNode, *CompileConfig, error) {
+	if originConf != nil {
+		if err := originConf.Limits.checkLength(exprStr); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	p := newParser(originConf, exprStr)
+	p.deferResolving = originConf != nil && len(originConf.Rewriters) != 0
+
+	ast, conf, err := p.parse()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(conf.Rewriters) != 0 {
+		ast, err = rewriteAstTree(conf, ast)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err = conf.Limits.checkTree(ast); err != nil {
+		return nil, nil, err
+	}
+	markShortCircuits(conf, ast)
+
+	if err = p.checkArity(ast); err != nil {
+		return nil, nil, err
+	}
 
+	if err = p.checkAccess(ast); err != nil {
+		return nil, nil, err
+	}
+
+	if conf.CompileOptions[TypeCheck] {
+		if _, err = p.typeCheck(ast); err != nil {
+			return nil, nil, err
+		}
+	}
+	return ast, conf, nil
+}
+
+func compileAstTree(conf *CompileConfig, ast *astNode) (*Expr, error) {
+	if err := compileLambdas(conf, ast); err != nil {
+		return nil, err
+	}
 	optimize(conf, ast)
+	splitWideBoolOps(ast)
 
 	res := check(ast)
 	if res.err != nil {
@@ -144,24 +607,40 @@ func Compile(originConf *CompileConfig, exprStr string) (*Expr, error) {
 	}
 
 	expr := compress(ast, res.size)
+	expr.conf = conf
+	expr.maxSteps = conf.MaxSteps
+	expr.returnType = inferType(conf, ast)
+	expr.memoizeSelectors = conf.CompileOptions[MemoizeSelectors] && hasRepeatedSelectors(expr)
+	expr.cachesURLs = callsURLOperators(expr)
+	expr.ignoreCase = conf.CompileOptions[CaseInsensitive]
 
 	setExtraInfo(expr)
 
+	if err := specializeOperators(expr, conf); err != nil {
+		return nil, err
+	}
+	expr.setMissingSelectors(conf.MissingSelector, conf.SelectorDefaults)
+	expr.setRequiredSelectors()
+	if conf.CompileOptions[RecoverPanics] {
+		expr.setRecoverPanics()
+	}
+
 	if conf.CompileOptions[Debug] {
+		expr.setDebugOutput(conf)
 		setDebugInfo(expr)
 	}
-	return expr, nil
-}
 
-func setDebugInfo(e *Expr) {
-	var wrapDebugInfo = func(name string, op Operator) Operator {
-		return func(ctx *Ctx, params []Value) (res Value, err error) {
-			res, err = op(ctx, params)
-			fmt.Printf("execute operator, op: %s, params: %v, res: %v, err: %v\n\n", name, params, res, err)
-			return
+	expr.hook = conf.EvalHook
+	expr.setBackend(conf.Backend)
+	if conf.CompileOptions[ZeroAlloc] {
+		if err := expr.setPredicate(); err != nil {
+			return nil, err
 		}
 	}
+	return expr, nil
+}
 
+func setDebugInfo(e *Expr) {
 	size := int16(len(e.nodes))
 	offset := size
 
@@ -185,9 +664,9 @@ func setDebugInfo(e *Expr) {
 		realNode.scIdx += offset
 		switch realNode.getNodeType() {
 		case operator:
-			realNode.operator = wrapDebugInfo(realNode.value.(string), realNode.operator)
+			realNode.operator = e.wrapDebugInfo(int(i), realNode.value.(string), realNode.operator)
 		case fastOperator:
-			realNode.operator = wrapDebugInfo(realNode.value.(string), realNode.operator)
+			realNode.operator = e.wrapDebugInfo(int(i), realNode.value.(string), realNode.operator)
 			realNode.childIdx += offset
 		}
 
@@ -197,10 +676,47 @@ func setDebugInfo(e *Expr) {
 	}
 }
 
+// specializeOperators replaces the builtin operators and the ones having the OperatorSpecializers
+// with the specialized ones, which are built with their constant params at compile time.
+func specializeOperators(e *Expr, cc *CompileConfig) error {
+	for _, n := range e.nodes {
+		if typ := n.getNodeType(); typ != operator && typ != fastOperator {
+			continue
+		}
+		name := n.value.(string)
+		specialize, exist := builtinSpecializers[name]
+		if _, isBuiltin := builtinOperators[name]; !isBuiltin {
+			specialize, exist = cc.OperatorSpecializers[name]
+		}
+		if !exist {
+			continue
+		}
+
+		cnt := int(n.childCnt)
+		consts, known := make([]Value, cnt), make([]bool, cnt)
+		for i := 0; i < cnt; i++ {
+			child := e.getNode(int(n.childIdx) + i)
+			if child.getNodeType() == constant {
+				consts[i], known[i] = child.value, true
+			}
+		}
+
+		op, err := specialize(consts, known)
+		if err != nil {
+			return err
+		}
+		if op != nil {
+			n.operator = op
+		}
+	}
+	return nil
+}
+
 func setExtraInfo(e *Expr) {
 	calAndSetParentIndex(e)
 	calAndSetStackSize(e)
 	calAndSetShortCircuit(e)
+	calAndSetParamsSize(e)
 }
 
 func calAndSetParentIndex(e *Expr) {
@@ -310,6 +826,27 @@ func calAndSetStackSize(e *Expr) {
 	copy(e.osSize, f2)
 }
 
+// calAndSetParamsSize calculates the size of the params buffer shared by the operators
+// with more than 2 params, which is the maximum sum of their params counts along
+// the paths from the root, so that the nested ones don't overlap in the closure backend.
+// The and/or operators are excluded, they are rarely executed because of short circuit.
+func calAndSetParamsSize(e *Expr) {
+	size := len(e.nodes)
+	f := make([]int16, size)
+	var res int16
+	for i := 0; i < size; i++ {
+		if i != 0 {
+			f[i] = f[e.parentIdx[i]]
+		}
+		n := e.nodes[i]
+		if typ := n.getNodeType(); (typ == operator || typ == fastOperator) && n.childCnt > 2 && !isBoolOpNode(n) {
+			f[i] += int16(n.childCnt)
+		}
+		res = maxInt16(res, f[i])
+	}
+	e.paramsSize = res
+}
+
 func calAndSetShortCircuit(e *Expr) {
 	var isLastChild = func(idx int16) bool {
 		parentIdx := e.parentIdx[idx]
@@ -348,7 +885,8 @@ func calAndSetShortCircuit(e *Expr) {
 			continue
 		}
 
-		if !isBoolOpNode(p) {
+		// the operators declared by RegisterShortCircuit are executed after their last params
+		if !isBoolOpNode(p) || (isCustomBoolOpNode(p) && isLastChild(i)) {
 			f[i] = i
 			continue
 		}
@@ -403,6 +941,12 @@ func isBoolOpNode(n *node) bool {
 	if nodeType != operator && nodeType != fastOperator {
 		return false
 	}
+	if n.flag&noShortCircuit != 0 {
+		return false
+	}
+	if n.flag&(scAndOp|scOrOp) != 0 {
+		return true
+	}
 
 	switch n.value.(string) {
 	case "and", "&":
@@ -419,7 +963,7 @@ func isAndOpNode(n *node) bool {
 		return false
 	}
 	v := n.value.(string)
-	return v == "and" || v == "&"
+	return v == "and" || v == "&" || n.flag&scAndOp != 0
 }
 
 func isOrOpNode(n *node) bool {
@@ -427,7 +971,7 @@ func isOrOpNode(n *node) bool {
 		return false
 	}
 	v := n.value.(string)
-	return v == "or" || v == "|"
+	return v == "or" || v == "|" || n.flag&scOrOp != 0
 }
 
 func calculateNodeCosts(conf *CompileConfig, root *astNode) {
@@ -503,7 +1047,8 @@ func optimizeReordering(root *astNode) {
 		optimizeReordering(child)
 	}
 
-	if !isBoolOpNode(root.node) {
+	// the params of the custom operators may be executed in order
+	if !isBoolOpNode(root.node) || isCustomBoolOpNode(root.node) {
 		return
 	}
 
@@ -513,15 +1058,36 @@ func optimizeReordering(root *astNode) {
 	})
 }
 
+// optimizeConstantFolding evaluates the subtrees whose operands are all constants
+// and replaces them with constant nodes. If a subtree fails to be evaluated,
+// it is left as it is, the error will be reported again at runtime,
+// and the folding of the other subtrees still goes on.
 func optimizeConstantFolding(cc *CompileConfig, root *astNode) error {
+	var firstErr error
 	for _, child := range root.children {
 		err := optimizeConstantFolding(cc, child)
-		if err != nil {
-			return err
+		if err != nil && firstErr == nil {
+			firstErr = err
 		}
 	}
+	if firstErr != nil {
+		return firstErr
+	}
 
 	n := root.node
+	if n.getNodeType() == cond {
+		// select the branch in advance if the condition is constant
+		condNode := root.children[0].node
+		if b, ok := condNode.value.(bool); ok && condNode.getNodeType() == constant {
+			branch := root.children[2]
+			if b {
+				branch = root.children[1]
+			}
+			*root = *branch
+		}
+		return nil
+	}
+
 	stateless, fn := isStatelessOp(cc, n)
 	if !stateless {
 		return nil
@@ -579,12 +1145,13 @@ func isStatelessOp(c *CompileConfig, n *node) (bool, Operator) {
 		return false, nil
 	}
 
-	// by default, we only do constant folding on builtin operators
-	if _, exist := c.OperatorMap[s]; exist {
-		return false, nil
+	// by default, we only do constant folding on builtin operators,
+	// and the registered ones declared deterministic by RegisterOperatorMeta
+	if op, exist := c.OperatorMap[s]; exist {
+		return c.OperatorMetas[s].Deterministic, op
 	}
 
-	fn, exist := builtinOperators[s] // should be stateless function
+	fn, exist := c.getBuiltinOperator(s) // should be stateless function
 	if !exist {
 		return false, nil
 	}
@@ -613,6 +1180,33 @@ func optimizeFastEvaluation(cc *CompileConfig, root *astNode) {
 	root.node.flag = fastOperator | (root.node.flag & otherPartMask)
 }
 
+// splitWideBoolOps nests the params of the and/or operators exceeding the
+// maximum params count, e.g. (and a1 ... a200) => (and (and a1 ... a127) a128 ... a200).
+// The nested ones short-circuit to the same target as the flat one.
+func splitWideBoolOps(root *astNode) {
+	for _, child := range root.children {
+		splitWideBoolOps(child)
+	}
+	if !isBoolOpNode(root.node) || len(root.children) <= math.MaxInt8 {
+		return
+	}
+	// the nested operators aren't leaves, so they can't be evaluated by the fast operators
+	if root.node.getNodeType() == fastOperator {
+		root.node.flag = operator | (root.node.flag &^ nodeTypeMask)
+	}
+	for len(root.children) > math.MaxInt8 {
+		n := *root.node
+		n.flag &^= scIfTrue | scIfFalse
+		nested := &astNode{
+			node:     &n,
+			children: root.children[:math.MaxInt8:math.MaxInt8],
+			cost:     root.cost,
+			pos:      root.pos,
+		}
+		root.children = append([]*astNode{nested}, root.children[math.MaxInt8:]...)
+	}
+}
+
 type checkRes struct {
 	size int
 	err  error
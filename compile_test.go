@@ -348,6 +348,80 @@ func TestOptimizeConstantFolding(t *testing.T) {
 				},
 			},
 		},
+
+		// select the branch of if node with a constant condition
+		{
+			expr: `(if (= 1 1) v1 (+ 1 2))`,
+			cc: &CompileConfig{
+				SelectorMap: map[string]SelectorKey{
+					"v1": SelectorKey(1),
+				},
+			},
+			ast: verifyNode{tpy: selector, data: "v1"},
+		},
+		{
+			expr: `(+ (if (> 1 2) 1 (* 2 3)) 4)`,
+			ast:  verifyNode{tpy: constant, data: int64(10)},
+		},
+		{
+			expr: `(if v1 (+ 1 2) 4)`,
+			cc: &CompileConfig{
+				SelectorMap: map[string]SelectorKey{
+					"v1": SelectorKey(1),
+				},
+			},
+			ast: verifyNode{
+				tpy:  cond,
+				data: "if",
+				children: []verifyNode{
+					{tpy: selector, data: "v1"},
+					{tpy: constant, data: int64(3)},
+					{tpy: constant, data: int64(4)},
+					{tpy: end, data: "end"},
+				},
+			},
+		},
+
+		// the failed subtree is left as it is,
+		// and the other subtrees are still folded
+		{
+			expr: `(and (= v1 (/ 1 0)) (= v1 (+ 1 2)))`,
+			cc: &CompileConfig{
+				SelectorMap: map[string]SelectorKey{
+					"v1": SelectorKey(1),
+				},
+			},
+			errMsg: "divide by zero",
+			ast: verifyNode{
+				tpy:  operator,
+				data: "and",
+				children: []verifyNode{
+					{
+						tpy:  operator,
+						data: "=",
+						children: []verifyNode{
+							{tpy: selector, data: "v1"},
+							{
+								tpy:  operator,
+								data: "/",
+								children: []verifyNode{
+									{tpy: constant, data: int64(1)},
+									{tpy: constant, data: int64(0)},
+								},
+							},
+						},
+					},
+					{
+						tpy:  operator,
+						data: "=",
+						children: []verifyNode{
+							{tpy: selector, data: "v1"},
+							{tpy: constant, data: int64(3)},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, c := range testCases {
@@ -356,7 +430,9 @@ func TestOptimizeConstantFolding(t *testing.T) {
 		err = optimizeConstantFolding(cc, ast)
 		if len(c.errMsg) != 0 {
 			assertErrStrContains(t, err, c.errMsg, c)
-			continue
+			if c.ast.tpy == 0 {
+				continue
+			}
 		}
 
 		assertAstTreeIdentical(t, ast, c.ast, c)
@@ -1062,3 +1138,157 @@ func TestCalculateStackSize(t *testing.T) {
 func TestCompile(t *testing.T) {
 
 }
+
+func TestOperatorArities(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors)
+	maxOp := func(_ *Ctx, params []Value) (Value, error) {
+		res := params[0].(int64)
+		for _, p := range params[1:] {
+			if v := p.(int64); v > res {
+				res = v
+			}
+		}
+		return res, nil
+	}
+	assertNil(t, RegisterOperator(cc, "max", maxOp))
+	assertNil(t, RegisterOperator(cc, "clamp", maxOp))
+	cc.OperatorArities["max"] = Arity{Min: 1, Max: -1}
+	cc.OperatorArities["clamp"] = Arity{Min: 3, Max: 3}
+	cc.OperatorArities["and"] = Arity{Min: 2, Max: -1}
+
+	testCases := []struct {
+		expr   string
+		want   Value
+		errMsg string
+	}{
+		{expr: `(max 1 2 3 4 5)`, want: int64(5)},
+		{expr: `(max 7)`, want: int64(7)},
+		{expr: `(max)`, errMsg: "max parameters count error (want: at least 1, got: 0)"},
+		{expr: `(clamp 1 2 3)`, want: int64(3)},
+		{expr: `(clamp 1 2)`, errMsg: "clamp parameters count error (want: 3, got: 2)"},
+		{expr: `(and true)`, errMsg: "and parameters count error (want: at least 2, got: 1)"},
+		{expr: `(and true false true)`, want: false},
+	}
+	for _, c := range testCases {
+		res, err := Eval(c.expr, nil, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.want, c.expr)
+	}
+
+	assertEquals(t, Arity{Min: 1, Max: 3}.String(), "1 to 3")
+
+	// the arities are also checked with the public syntax tree
+	root, err := Parse(cc, `(max)`)
+	assertNil(t, err)
+	_, err = CompileAST(cc, root)
+	assertErrStrContains(t, err, "max parameters count error")
+}
+
+func TestSplitWideBoolOps(t *testing.T) {
+	const size = 300
+	vals := make(map[string]interface{}, size)
+	names := make([]string, size)
+	for i := range names {
+		names[i] = fmt.Sprintf("v%d", i)
+		vals[names[i]] = true
+	}
+
+	// the fast evaluation is enabled by default
+	for _, opts := range [][]CompileOption{{Optimizations(false)}, nil} {
+		cc := NewCompileConfig(append(opts, RegisterSelKeys(vals))...)
+
+		and, err := Compile(cc, fmt.Sprintf(`(and %s)`, strings.Join(names, " ")))
+		assertNil(t, err)
+		or, err := Compile(cc, fmt.Sprintf(`(or %s)`, strings.Join(names, " ")))
+		assertNil(t, err)
+
+		for _, falseIdx := range []int{-1, 0, 126, 127, 200, size - 1} {
+			ctxVals := make(map[string]interface{}, size)
+			for k := range vals {
+				ctxVals[k] = true
+			}
+			if falseIdx >= 0 {
+				ctxVals[names[falseIdx]] = false
+			}
+
+			sel := &countingSelector{MapSelector: NewMapSelector(ctxVals)}
+			res, err := and.Eval(&Ctx{Selector: sel})
+			assertNil(t, err)
+			assertEquals(t, res, falseIdx < 0, falseIdx)
+			// the selectors after the false one are skipped
+			if falseIdx >= 0 {
+				assertEquals(t, sel.cnt, falseIdx+1, falseIdx)
+			}
+
+			sel = &countingSelector{MapSelector: NewMapSelector(ctxVals)}
+			res, err = or.Eval(&Ctx{Selector: sel})
+			assertNil(t, err)
+			assertEquals(t, res, true, falseIdx)
+			assertEquals(t, sel.cnt, 1+boolToInt(falseIdx == 0), falseIdx)
+		}
+
+		// the operators except and/or are still limited
+		_, err = Compile(cc, fmt.Sprintf(`(= %s)`, strings.Join(names, " ")))
+		assertErrStrContains(t, err, "operators cannot exceed a maximum of 127 parameters")
+	}
+}
+
+type countingSelector struct {
+	MapSelector
+	cnt int
+}
+
+func (s *countingSelector) Get(key SelectorKey, strKey string) (Value, error) {
+	s.cnt++
+	return s.MapSelector.Get(key, strKey)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestCostOptions(t *testing.T) {
+	const exprStr = `(and (= risk_score 1) (= country "US") (!= (remote_check user) "ok"))`
+	remoteCheck := func(_ *Ctx, params []Value) (Value, error) {
+		return "ok", nil
+	}
+
+	testCases := []struct {
+		opts []CompileOption
+		want string
+	}{
+		{
+			want: `(and (= risk_score 1) (= country "US") (!= (remote_check user) "ok"))`,
+		},
+		{
+			opts: []CompileOption{SelectorCost("risk_score", 100)},
+			want: `(and (= country "US") (!= (remote_check user) "ok") (= risk_score 1))`,
+		},
+		{
+			opts: []CompileOption{SelectorCost("risk_score", 100), OperatorCost("remote_check", 1000)},
+			want: `(and (= country "US") (= risk_score 1) (!= (remote_check user) "ok"))`,
+		},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, EnableStringSelectors)...)
+		assertNil(t, RegisterOperator(cc, "remote_check", remoteCheck))
+		expr, err := Compile(cc, exprStr)
+		assertNil(t, err)
+		assertEquals(t, expr.Decompile(), c.want)
+
+		// the reordering keeps the result
+		res, err := expr.EvalBool(NewCtxWithMap(cc, map[string]interface{}{
+			"risk_score": 1, "country": "US", "user": "u1",
+		}))
+		assertNil(t, err)
+		assertEquals(t, res, false)
+	}
+}
@@ -0,0 +1,194 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ConversionPolicy decides the results of the failed conversions of the builtin conversion operators,
+// e.g. (toInt "abc"), the params count errors are always reported.
+type ConversionPolicy int
+
+const (
+	// ConversionError fails the evaluation on the failed conversions
+	ConversionError ConversionPolicy = iota
+	// ConversionNull returns null on the failed conversions, e.g. (coalesce (toInt code) -1)
+	ConversionNull
+)
+
+// conversionModes are the modes of the conversion operators affected by the ConversionPolicy
+var conversionModes = map[string]mode{
+	"toInt": castInt, "toFloat": castFloat, "toString": castString, "toBool": castBool,
+	"parseInt": parseInteger, "parseFloat": parseFloating,
+}
+
+var errNullConversion = errors.New("can not convert null")
+
+// valueConvert converts the param to the type of the mode:
+//
+//	toInt: the floats and decimals are truncated, the strings are parsed in base 10, and the bools are 0 or 1
+//	toFloat: the numbers are converted to the nearest float64, and the strings are parsed
+//	toString: the numbers, bools and strings are formatted, e.g. 1.5 => "1.5"
+//	toBool: the numbers are true if not zero, and the strings are parsed, e.g. "true", "1", "F"
+//	parseInt: the strings are parsed with the base prefixes, e.g. "0x1f", "0b101", "1_000"
+//	parseFloat: the strings are parsed, e.g. "1.5", "1e-3"
+//
+// The leading and trailing spaces of the strings are ignored, and the null param fails.
+type valueConvert struct {
+	mode mode
+	// return null instead of the errors of the failed conversions
+	nullOnError bool
+}
+
+func (c valueConvert) execute(_ *Ctx, params []Value) (Value, error) {
+	op := modeNames[c.mode]
+	if len(params) != 1 {
+		return nil, ParamsCountError(op, 1, len(params))
+	}
+
+	res, err := c.convert(params[0])
+	if err != nil {
+		if c.nullOnError {
+			return nil, nil
+		}
+		return nil, OpExecError(op, err)
+	}
+	return res, nil
+}
+
+func (c valueConvert) convert(p Value) (Value, error) {
+	if p == nil {
+		return nil, errNullConversion
+	}
+	if s, ok := p.(string); ok {
+		return c.parse(strings.TrimSpace(s))
+	}
+
+	switch c.mode {
+	case castInt:
+		return convertToInt(p)
+	case castFloat:
+		return convertToFloat(p)
+	case castString:
+		return convertToString(p)
+	case castBool:
+		return convertToBool(p)
+	case parseInteger, parseFloating:
+		return nil, fmt.Errorf("can not parse %T, expected: %s", p, typeStr)
+	}
+	return nil, errInvalidMode(c.mode, "conversion")
+}
+
+func (c valueConvert) parse(s string) (Value, error) {
+	var (
+		res Value
+		typ = typeInt
+		err error
+	)
+	switch c.mode {
+	case castInt:
+		res, err = strconv.ParseInt(s, 10, 64)
+	case parseInteger:
+		res, err = strconv.ParseInt(s, 0, 64)
+	case castFloat, parseFloating:
+		typ = typeFloat
+		res, err = strconv.ParseFloat(s, 64)
+	case castBool:
+		typ = typeBool
+		res, err = strconv.ParseBool(s)
+	case castString:
+		return s, nil
+	default:
+		return nil, errInvalidMode(c.mode, "conversion")
+	}
+	if err != nil {
+		// the cause of strconv.NumError, whose message contains the strconv function name
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) {
+			err = numErr.Err
+		}
+		return nil, fmt.Errorf("can not parse %q as %s, error: %w", s, typ, err)
+	}
+	return res, nil
+}
+
+func convertToInt(p Value) (Value, error) {
+	switch v := p.(type) {
+	case int64:
+		return v, nil
+	case float64:
+		if math.IsNaN(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return nil, fmt.Errorf("%v is out of the range of int64", v)
+		}
+		return int64(v), nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case Decimal:
+		return v.unscaled / pow10(v.scale).Int64(), nil
+	case *big.Int:
+		if !v.IsInt64() {
+			return nil, fmt.Errorf("%v is out of the range of int64", v)
+		}
+		return v.Int64(), nil
+	}
+	return nil, fmt.Errorf("can not convert %T to %s", p, typeInt)
+}
+
+func convertToFloat(p Value) (Value, error) {
+	switch v := p.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case bool:
+		if v {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	case Decimal:
+		return v.Float64(), nil
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, nil
+	}
+	return nil, fmt.Errorf("can not convert %T to %s", p, typeFloat)
+}
+
+func convertToString(p Value) (Value, error) {
+	switch v := p.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case Decimal:
+		return v.String(), nil
+	case *big.Int:
+		return v.String(), nil
+	}
+	return nil, fmt.Errorf("can not convert %T to %s", p, typeStr)
+}
+
+func convertToBool(p Value) (Value, error) {
+	switch v := p.(type) {
+	case bool:
+		return v, nil
+	case int64:
+		return v != 0, nil
+	case float64:
+		return v != 0, nil
+	case Decimal:
+		return v.unscaled != 0, nil
+	case *big.Int:
+		return v.Sign() != 0, nil
+	}
+	return nil, fmt.Errorf("can not convert %T to %s", p, typeBool)
+}
@@ -0,0 +1,81 @@
+package eval
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     " 42 ",
+		"score":   "98.5",
+		"active":  "true",
+		"flags":   "0x1f",
+		"ratio":   2.75,
+		"count":   7,
+		"enabled": true,
+		"price":   mustDecimal("19.99"),
+		"tags":    []string{"a"},
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(toInt age)`, res: int64(42)},
+		{expr: `(toInt ratio)`, res: int64(2)},
+		{expr: `(toInt -2.75)`, res: int64(-2)},
+		{expr: `(toInt enabled)`, res: int64(1)},
+		{expr: `(toInt price)`, opts: []CompileOption{EnableDecimalArithmetic}, res: int64(19)},
+		{expr: `(toFloat score)`, res: 98.5},
+		{expr: `(toFloat count)`, res: 7.0},
+		{expr: `(toString count)`, res: "7"},
+		{expr: `(toString ratio)`, res: "2.75"},
+		{expr: `(toString enabled)`, res: "true"},
+		{expr: `(toString price)`, opts: []CompileOption{EnableDecimalArithmetic}, res: "19.99"},
+		{expr: `(toBool active)`, res: true},
+		{expr: `(toBool "0")`, res: false},
+		{expr: `(toBool count)`, res: true},
+		{expr: `(toBool 0.0)`, res: false},
+		{expr: `(parseInt flags)`, res: int64(31)},
+		{expr: `(parseInt "0b101")`, res: int64(5)},
+		{expr: `(parseInt "1_000")`, res: int64(1000)},
+		{expr: `(parseFloat "1e-3")`, res: 0.001},
+		{expr: `(> (toInt age) 18)`, opts: []CompileOption{EnableTypeCheck}, res: true},
+		{expr: `toInt(age) + 1`, opts: []CompileOption{EnableInfixSyntax}, res: int64(43)},
+		{expr: `(toInt "abc")`, opts: []CompileOption{OnConversionError(ConversionNull)}, res: nil},
+		{expr: `(coalesce (toInt score) -1)`, opts: []CompileOption{OnConversionError(ConversionNull)}, res: int64(-1)},
+		{expr: `(toInt null)`, opts: []CompileOption{OnConversionError(ConversionNull)}, res: nil},
+
+		{expr: `(toInt "abc")`, errMsg: `can not parse "abc" as int64, error: invalid syntax`},
+		{expr: `(toInt score)`, errMsg: `can not parse "98.5" as int64`},
+		{expr: `(toInt flags)`, errMsg: `can not parse "0x1f" as int64`},
+		{expr: `(toInt "99999999999999999999")`, errMsg: "value out of range"},
+		{expr: `(toInt 1e19)`, errMsg: "out of the range of int64"},
+		{expr: `(toInt null)`, errMsg: "can not convert null"},
+		{expr: `(toFloat tags)`, errMsg: "can not convert []string to float64"},
+		{expr: `(toBool "yes")`, errMsg: `can not parse "yes" as bool`},
+		{expr: `(parseInt count)`, errMsg: "can not parse int64, expected: string"},
+		{expr: `(parseFloat "1.5.0")`, errMsg: `can not parse "1.5.0" as float64`},
+		{expr: `(toInt age 10)`, errMsg: "unexpected params count, operator: toInt, expected: 1, got: 2"},
+		{expr: `(toInt age 10)`, opts: []CompileOption{OnConversionError(ConversionNull)}, errMsg: "unexpected params count"},
+		{expr: `(parseInt 10)`, opts: []CompileOption{EnableTypeCheck}, errMsg: "parseInt param 0 should be string, got: int"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+
+	res, err := Eval(`(toFloat 9223372036854775807)`, nil, NewCompileConfig())
+	assertNil(t, err)
+	assertEquals(t, res, float64(math.MaxInt64))
+}
@@ -0,0 +1,133 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Coverage collects which nodes of an expression are evaluated across the evaluations,
+// and how the bool subexpressions are decided, e.g. to find the dead conditions of the rules
+// with the production traffic. It's an EvalHook, so the evaluations are much slower,
+// and it's safe for concurrent use.
+//
+//	cov := eval.NewCoverage(expr)
+//	res, err := cov.Eval(ctx)
+//	...
+//	fmt.Println(cov.Report())
+type Coverage struct {
+	// evals is the first word, so that it is 64-bit aligned for the atomic operations on the 32-bit platforms
+	evals int64
+	expr  *Expr
+	nodes []nodeCounter
+}
+
+type nodeCounter struct {
+	hits, trues, falses, errs int64
+}
+
+// NodeCoverage is the coverage of a node
+type NodeCoverage struct {
+	// Index is the index of the node, which is the same as HookNode.Index
+	Index int
+	// Expr is the decompiled subexpression
+	Expr string
+	// Hits is the number of the evaluations of the node
+	Hits int64
+	// True and False are the numbers of the bool results
+	True  int64
+	False int64
+	// Errors is the number of the failed evaluations
+	Errors int64
+}
+
+// NewCoverage creates a Coverage of the expression
+func NewCoverage(expr *Expr) *Coverage {
+	size := len(expr.nodes)
+	if expr.nodes[0].getNodeType() == debug {
+		size /= 2
+	}
+	return &Coverage{expr: expr, nodes: make([]nodeCounter, size)}
+}
+
+// Eval evaluates the expression, and collects the coverage of the evaluation
+func (c *Coverage) Eval(ctx *Ctx) (Value, error) {
+	atomic.AddInt64(&c.evals, 1)
+	return c.expr.EvalWithOptions(ctx, EvalOptions{Hook: c})
+}
+
+// Evaluations returns the number of the evaluations
+func (c *Coverage) Evaluations() int64 {
+	return atomic.LoadInt64(&c.evals)
+}
+
+func (c *Coverage) BeforeNode(HookNode) {}
+
+func (c *Coverage) AfterNode(n HookNode, res Value, err error, _ time.Duration) {
+	if n.Index < 0 || n.Index >= len(c.nodes) {
+		return
+	}
+	counter := &c.nodes[n.Index]
+	atomic.AddInt64(&counter.hits, 1)
+	switch {
+	case err != nil:
+		atomic.AddInt64(&counter.errs, 1)
+	case res == true:
+		atomic.AddInt64(&counter.trues, 1)
+	case res == false:
+		atomic.AddInt64(&counter.falses, 1)
+	}
+}
+
+// Nodes returns the coverage of all the nodes in the order of their indexes
+func (c *Coverage) Nodes() []NodeCoverage {
+	res := make([]NodeCoverage, 0, len(c.nodes))
+	for i := range c.nodes {
+		n := c.expr.getNode(i)
+		if n.getNodeType() == end {
+			continue
+		}
+		counter := &c.nodes[i]
+		res = append(res, NodeCoverage{
+			Index:  i,
+			Expr:   c.expr.decompileNode(n),
+			Hits:   atomic.LoadInt64(&counter.hits),
+			True:   atomic.LoadInt64(&counter.trues),
+			False:  atomic.LoadInt64(&counter.falses),
+			Errors: atomic.LoadInt64(&counter.errs),
+		})
+	}
+	return res
+}
+
+// Report renders the coverage, it lists the subexpressions never evaluated, whose parents
+// have been evaluated, and the non-constant bool subexpressions which are always true or false.
+func (c *Coverage) Report() string {
+	nodes := c.Nodes()
+	hits := make(map[int]int64, len(nodes))
+	var covered int
+	for _, n := range nodes {
+		hits[n.Index] = n.Hits
+		if n.Hits > 0 {
+			covered++
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("evaluations: %d, covered nodes: %d/%d\n", c.Evaluations(), covered, len(nodes)))
+	for _, n := range nodes {
+		switch {
+		case n.Hits == 0:
+			if n.Index == 0 || hits[int(c.expr.parentIdx[n.Index])] > 0 {
+				sb.WriteString(fmt.Sprintf("never evaluated: %s\n", n.Expr))
+			}
+		case c.expr.getNode(n.Index).getNodeType() == constant:
+		case n.True == n.Hits:
+			sb.WriteString(fmt.Sprintf("always true: %s\n", n.Expr))
+		case n.False == n.Hits:
+			sb.WriteString(fmt.Sprintf("always false: %s\n", n.Expr))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,45 @@
+package eval
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors, Optimizations(false))
+	expr, err := Compile(cc, `(if (and (> age 0) (= country "US")) (>= age 21) (or (>= age 18) (= tier "gold")))`)
+	assertNil(t, err)
+
+	cov := NewCoverage(expr)
+	rows := []map[string]interface{}{
+		{"age": 30, "country": "US"},
+		{"age": 16, "country": "US"},
+		{"age": 20, "country": "CA"},
+	}
+	var wg sync.WaitGroup
+	for _, row := range rows {
+		wg.Add(1)
+		go func(row map[string]interface{}) {
+			defer wg.Done()
+			_, err := cov.Eval(&Ctx{Selector: NewMapSelector(row)})
+			assertNil(t, err)
+		}(row)
+	}
+	wg.Wait()
+
+	res, err := cov.Eval(&Ctx{Selector: NewMapSelector(map[string]interface{}{"age": 18, "country": 1})})
+	assertNil(t, err)
+	assertEquals(t, res, true)
+
+	want := `evaluations: 4, covered nodes: 15/18
+always true: (or (>= age 18) (= tier "gold"))
+always true: (> age 0)
+always true: (>= age 18)
+never evaluated: (= tier "gold")`
+	assertEquals(t, cov.Report(), want)
+
+	nodes := cov.Nodes()
+	assertEquals(t, nodes[0].Expr, expr.Decompile())
+	assertEquals(t, nodes[0].Hits, int64(4))
+	assertEquals(t, nodes[1], NodeCoverage{Index: 1, Expr: `(and (> age 0) (= country "US"))`, Hits: 4, True: 2, False: 2})
+}
@@ -0,0 +1,112 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DebugEventType is the type of the DebugEvent
+type DebugEventType int
+
+const (
+	// StepEvent is emitted before a node is executed
+	StepEvent DebugEventType = iota
+	// OperatorEvent is emitted after an operator is executed
+	OperatorEvent
+)
+
+// DebugEvent is the structured debug info emitted by the expressions compiled in the debug mode
+type DebugEvent struct {
+	Type DebugEventType
+	// NodeIdx is the index of the node, and Node is its value,
+	// which is the name of the operator or selector, or the constant value
+	NodeIdx int
+	Node    Value
+
+	// the fields of StepEvent
+	// ShortCircuit is true if short circuit was triggered by the previous node
+	ShortCircuit bool
+	// StackFrame and OperandStack are the values of the stacks, top first
+	StackFrame   []Value
+	OperandStack []Value
+
+	// the fields of OperatorEvent
+	Params []Value
+	Result Value
+	Err    error
+}
+
+// setDebugOutput sets where the debug info goes,
+// it's printed to the stdout if neither DebugWriter nor DebugHandler is set.
+func (e *Expr) setDebugOutput(cc *CompileConfig) {
+	e.debugWriter, e.debugHandler = cc.DebugWriter, cc.DebugHandler
+	if e.debugWriter == nil && e.debugHandler == nil {
+		e.debugWriter = os.Stdout
+	}
+}
+
+func (e *Expr) wrapDebugInfo(idx int, name string, op Operator) Operator {
+	return func(ctx *Ctx, params []Value) (res Value, err error) {
+		res, err = op(ctx, params)
+		if e.debugWriter != nil {
+			fmt.Fprintf(e.debugWriter, "execute operator, op: %s, params: %v, res: %v, err: %v\n\n", name, params, res, err)
+		}
+		if e.debugHandler != nil {
+			e.debugHandler(DebugEvent{
+				Type:    OperatorEvent,
+				NodeIdx: idx,
+				Node:    name,
+				Params:  append([]Value(nil), params...),
+				Result:  res,
+				Err:     err,
+			})
+		}
+		return
+	}
+}
+
+func (e *Expr) printStacks(curtIdx int16, scTriggered bool, maxIdx int16, os []Value, osTop int16, sf []int16, sfTop int16) {
+	if e.debugHandler != nil {
+		ev := DebugEvent{
+			Type:         StepEvent,
+			NodeIdx:      int(curtIdx),
+			Node:         e.nodes[curtIdx].value,
+			ShortCircuit: scTriggered,
+			StackFrame:   make([]Value, 0, sfTop+1),
+			OperandStack: make([]Value, 0, osTop+1),
+		}
+		for i := sfTop; i >= 0; i-- {
+			ev.StackFrame = append(ev.StackFrame, e.nodes[sf[i]].value)
+		}
+		for i := osTop; i >= 0; i-- {
+			ev.OperandStack = append(ev.OperandStack, os[i])
+		}
+		e.debugHandler(ev)
+	}
+
+	w := e.debugWriter
+	if w == nil {
+		return
+	}
+	if scTriggered {
+		fmt.Fprintf(w, "short circuit triggered\n\n")
+	}
+	var sb strings.Builder
+
+	offset := int16(len(e.nodes)) / 2
+
+	fmt.Fprintf(w, "maxIdx:%d, sfTop:%d, osTop:%d\n", maxIdx-offset, sfTop, osTop)
+	sb.WriteString(fmt.Sprintf("%15s", "Stack Frame: "))
+	for i := sfTop; i >= 0; i-- {
+		sb.WriteString(fmt.Sprintf("|%4v", e.nodes[sf[i]].value))
+	}
+	sb.WriteString("|\n")
+
+	sb.WriteString(fmt.Sprintf("%15s", "Operand Stack: "))
+	for i := osTop; i >= 0; i-- {
+		sb.WriteString(fmt.Sprintf("|%4v", os[i]))
+	}
+	sb.WriteString("|\n")
+	fmt.Fprintln(w, sb.String())
+}
@@ -0,0 +1,96 @@
+package eval
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDebugOutput(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "CA",
+	}
+
+	var (
+		buf    bytes.Buffer
+		events []DebugEvent
+	)
+	cc := NewCompileConfig(EnableDebug, RegisterSelKeys(vals), Optimizations(false))
+	cc.DebugWriter = &buf
+	cc.DebugHandler = func(ev DebugEvent) {
+		events = append(events, ev)
+	}
+
+	expr, err := Compile(cc, `(or (= country "US") (> age 18))`)
+	assertNil(t, err)
+	res, err := expr.EvalBool(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, true)
+
+	out := buf.String()
+	assertEquals(t, strings.Contains(out, "execute operator, op: =, params: [CA US], res: false"), true)
+	assertEquals(t, strings.Contains(out, "Operand Stack: "), true)
+
+	var steps, ops []DebugEvent
+	for _, ev := range events {
+		switch ev.Type {
+		case StepEvent:
+			steps = append(steps, ev)
+		case OperatorEvent:
+			ops = append(ops, ev)
+		}
+	}
+
+	// the operators are stepped twice, before and after their params
+	assertEquals(t, len(steps), 9)
+	assertEquals(t, steps[0].NodeIdx, 0)
+	assertEquals(t, steps[0].Node, "or")
+	assertEquals(t, steps[0].StackFrame[0], "or")
+	assertEquals(t, steps[3].Node, "US")
+	assertEquals(t, steps[3].OperandStack, []Value{"CA"})
+
+	assertEquals(t, len(ops), 2)
+	assertEquals(t, ops[0].Node, "=")
+	assertEquals(t, ops[0].Params, []Value{"CA", "US"})
+	assertEquals(t, ops[0].Result, false)
+	assertEquals(t, ops[1].Node, ">")
+	assertEquals(t, ops[1].Result, true)
+
+	// the debug info is also emitted by the unmarshalled expression
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	events = nil
+	buf.Reset()
+	expr, err = UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+	_, err = expr.EvalBool(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, len(events), 11)
+	assertEquals(t, strings.Contains(buf.String(), "execute operator, op: >"), true)
+}
+
+func TestDebugOutput_ShortCircuit(t *testing.T) {
+	var events []DebugEvent
+	cc := NewCompileConfig(EnableDebug, Optimizations(false))
+	cc.DebugHandler = func(ev DebugEvent) {
+		events = append(events, ev)
+	}
+
+	expr, err := Compile(cc, `(or (and false (= 1 1)) true)`)
+	assertNil(t, err)
+	res, err := expr.EvalBool(nil)
+	assertNil(t, err)
+	assertEquals(t, res, true)
+
+	// (= 1 1) is skipped after false is evaluated
+	var nodes []Value
+	for _, ev := range events {
+		nodes = append(nodes, ev.Node)
+		if ev.ShortCircuit {
+			assertEquals(t, ev.Node, true)
+		}
+	}
+	assertEquals(t, nodes, []Value{"or", "and", false, true})
+	assertEquals(t, events[3].ShortCircuit, true)
+}
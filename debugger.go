@@ -0,0 +1,189 @@
+package eval
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrDebuggerStopped is returned by the evaluation stopped by Debugger.Stop
+var ErrDebuggerStopped = errors.New("debugger stopped")
+
+// Debugger evaluates an expression step by step.
+// The evaluation pauses before each node is executed, the paused step
+// is returned as a StepEvent, which contains the stack frame and operand stack.
+// A Debugger evaluates the expression once, and it should not be used concurrently.
+type Debugger struct {
+	expr *Expr
+	ctx  *Ctx
+
+	nodeBreakpoints map[int]bool
+	selBreakpoints  map[string]bool
+
+	cancel  context.CancelFunc
+	events  chan DebugEvent
+	resume  chan struct{}
+	stopped chan struct{}
+	done    chan struct{}
+
+	started bool
+	curt    DebugEvent
+	res     Value
+	err     error
+}
+
+// NewDebugger creates a debugger evaluating the expression against the ctx,
+// the expression is copied, so it can still be used by others.
+// The node indices of the expression are kept in the copy.
+func NewDebugger(expr *Expr, ctx *Ctx) (*Debugger, error) {
+	d := &Debugger{
+		nodeBreakpoints: make(map[int]bool),
+		selBreakpoints:  make(map[string]bool),
+		events:          make(chan DebugEvent),
+		resume:          make(chan struct{}),
+		stopped:         make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+
+	cc := NewCompileConfig(EnableDebug, Optimizations(false), OnMissingSelector(expr.missingSelector))
+	cc.SelectorDefaults = expr.selectorDefaults
+	cc.CompileOptions[RecoverPanics] = expr.recoverPanics
+	cc.DebugHandler = d.handle
+
+	e, err := compileAstTree(cc, expr.toAstTree(expr.getNode(0), nil))
+	if err != nil {
+		return nil, err
+	}
+	e.maxSteps = expr.maxSteps
+	e.memoizeSelectors = expr.memoizeSelectors
+	d.expr = e
+
+	c := &Ctx{}
+	if ctx != nil {
+		*c = *ctx
+	}
+	parent := c.Ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	c.Ctx, d.cancel = context.WithCancel(parent)
+	d.ctx = c
+	return d, nil
+}
+
+// SetBreakpoint makes Continue pause before the node of the index is executed
+func (d *Debugger) SetBreakpoint(nodeIdx int) {
+	d.nodeBreakpoints[nodeIdx] = true
+}
+
+// ClearBreakpoint removes the breakpoint set by SetBreakpoint
+func (d *Debugger) ClearBreakpoint(nodeIdx int) {
+	delete(d.nodeBreakpoints, nodeIdx)
+}
+
+// BreakOnSelector makes Continue pause before the selector of the name is read
+func (d *Debugger) BreakOnSelector(name string) {
+	d.selBreakpoints[name] = true
+}
+
+// ClearSelectorBreakpoint removes the breakpoint set by BreakOnSelector
+func (d *Debugger) ClearSelectorBreakpoint(name string) {
+	delete(d.selBreakpoints, name)
+}
+
+// Step executes the paused node and pauses before the next one.
+// It returns false once the evaluation is finished.
+func (d *Debugger) Step() (DebugEvent, bool) {
+	if !d.started {
+		d.started = true
+		go d.run()
+	} else if d.Done() {
+		return DebugEvent{}, false
+	} else {
+		d.resume <- struct{}{}
+	}
+
+	select {
+	case ev := <-d.events:
+		d.curt = ev
+		return ev, true
+	case <-d.done:
+		d.curt = DebugEvent{}
+		return DebugEvent{}, false
+	}
+}
+
+// Continue runs until a breakpoint is hit, it returns false once the evaluation is finished.
+func (d *Debugger) Continue() (DebugEvent, bool) {
+	for {
+		ev, ok := d.Step()
+		if !ok || d.isBreakpoint(ev) {
+			return ev, ok
+		}
+	}
+}
+
+// Current returns the paused step
+func (d *Debugger) Current() DebugEvent {
+	return d.curt
+}
+
+// Done reports whether the evaluation is finished
+func (d *Debugger) Done() bool {
+	select {
+	case <-d.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Result returns the result of the evaluation, it's only valid when Done is true
+func (d *Debugger) Result() (Value, error) {
+	return d.res, d.err
+}
+
+// Stop aborts the evaluation, and the result error is ErrDebuggerStopped
+func (d *Debugger) Stop() {
+	if d.Done() {
+		return
+	}
+	close(d.stopped)
+	d.cancel()
+	if d.started {
+		<-d.done
+	} else {
+		d.started = true
+		close(d.done)
+	}
+	d.res, d.err = nil, ErrDebuggerStopped
+	d.curt = DebugEvent{}
+}
+
+func (d *Debugger) run() {
+	d.res, d.err = d.expr.Eval(d.ctx)
+	d.cancel()
+	close(d.done)
+}
+
+func (d *Debugger) handle(ev DebugEvent) {
+	if ev.Type != StepEvent {
+		return
+	}
+	select {
+	case d.events <- ev:
+	case <-d.stopped:
+		return
+	}
+	select {
+	case <-d.resume:
+	case <-d.stopped:
+	}
+}
+
+func (d *Debugger) isBreakpoint(ev DebugEvent) bool {
+	if d.nodeBreakpoints[ev.NodeIdx] {
+		return true
+	}
+	n := d.expr.getNode(ev.NodeIdx)
+	return n.getNodeType() == selector && d.selBreakpoints[n.value.(string)]
+}
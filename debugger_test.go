@@ -0,0 +1,103 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestDebugger(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "CA",
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals), Optimizations(false))
+	expr, err := Compile(cc, `(or (= country "US") (> age 18))`)
+	assertNil(t, err)
+
+	d, err := NewDebugger(expr, NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+
+	ev, ok := d.Step()
+	assertEquals(t, ok, true)
+	assertEquals(t, ev.NodeIdx, 0)
+	assertEquals(t, ev.Node, "or")
+	assertEquals(t, d.Current().Node, "or")
+
+	d.BreakOnSelector("age")
+	ev, ok = d.Continue()
+	assertEquals(t, ok, true)
+	assertEquals(t, ev.Node, "age")
+	// the result of (= country "US") is on the operand stack
+	assertEquals(t, ev.OperandStack, []Value{false})
+
+	ev, ok = d.Step()
+	assertEquals(t, ok, true)
+	assertEquals(t, ev.Node, int64(18))
+	assertEquals(t, ev.OperandStack, []Value{int64(20), false})
+
+	ev, ok = d.Continue()
+	assertEquals(t, ok, false)
+	assertEquals(t, d.Done(), true)
+	res, err := d.Result()
+	assertNil(t, err)
+	assertEquals(t, res, true)
+
+	_, ok = d.Step()
+	assertEquals(t, ok, false)
+
+	// the original expression is not changed
+	res, err = expr.Eval(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, true)
+}
+
+func TestDebugger_Breakpoint(t *testing.T) {
+	expr, err := Compile(NewCompileConfig(Optimizations(false)), `(if (> 2 1) (+ 1 2) 0)`)
+	assertNil(t, err)
+
+	d, err := NewDebugger(expr, nil)
+	assertNil(t, err)
+
+	var idx int
+	for i, n := range expr.nodes {
+		if n.value == "+" {
+			idx = i
+		}
+	}
+	d.SetBreakpoint(idx)
+
+	ev, ok := d.Continue()
+	assertEquals(t, ok, true)
+	assertEquals(t, ev.NodeIdx, idx)
+	assertEquals(t, ev.Node, "+")
+
+	d.ClearBreakpoint(idx)
+	_, ok = d.Continue()
+	assertEquals(t, ok, false)
+	res, err := d.Result()
+	assertNil(t, err)
+	assertEquals(t, res, int64(3))
+}
+
+func TestDebugger_Stop(t *testing.T) {
+	expr, err := Compile(NewCompileConfig(Optimizations(false)), `(+ 1 2 3 4 5 6 7 8 9 10)`)
+	assertNil(t, err)
+
+	d, err := NewDebugger(expr, nil)
+	assertNil(t, err)
+	_, ok := d.Step()
+	assertEquals(t, ok, true)
+
+	d.Stop()
+	assertEquals(t, d.Done(), true)
+	_, err = d.Result()
+	assertEquals(t, err, ErrDebuggerStopped)
+	_, ok = d.Step()
+	assertEquals(t, ok, false)
+
+	// stop before started
+	d, err = NewDebugger(expr, nil)
+	assertNil(t, err)
+	d.Stop()
+	_, ok = d.Continue()
+	assertEquals(t, ok, false)
+}
@@ -0,0 +1,332 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultDecimalScale is the scale of the decimal results if CompileConfig.DecimalScale isn't set
+	defaultDecimalScale = 8
+	// maxDecimalScale is the max scale of the decimals, 10^18 is the max power of 10 of int64
+	maxDecimalScale = 18
+
+	typeDecimal = "decimal"
+)
+
+var errDecimalOverflow = errors.New("decimal overflow")
+
+// Decimal is a fixed-point decimal number, whose value is unscaled / 10^scale,
+// e.g. 19.99 is {1999, 2}. The decimals are normalized without the trailing zeros
+// of the fraction, so the equal decimals are the equal values, e.g. 1.50 is 1.5.
+type Decimal struct {
+	unscaled int64
+	scale    int32
+}
+
+// NewDecimal returns the decimal of unscaled / 10^scale, e.g. NewDecimal(1999, 2) is 19.99
+func NewDecimal(unscaled int64, scale int) (Decimal, error) {
+	if scale < 0 || scale > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal scale out of range [0, %d], got: %d", maxDecimalScale, scale)
+	}
+	return Decimal{unscaled: unscaled, scale: int32(scale)}.normalize(), nil
+}
+
+// ParseDecimal parses the decimal string, e.g. "19.99", "-0.5", "42"
+func ParseDecimal(s string) (Decimal, error) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 || digits == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal: %q", s)
+	}
+
+	intPart, frac := digits, ""
+	if idx := strings.IndexByte(digits, '.'); idx != -1 {
+		intPart, frac = digits[:idx], digits[idx+1:]
+	}
+	if intPart == "" || len(frac) > maxDecimalScale || strings.ContainsAny(intPart+frac, "+-_") {
+		return Decimal{}, fmt.Errorf("invalid decimal: %q", s)
+	}
+	unscaled, err := strconv.ParseInt(s[:len(s)-len(digits)]+intPart+frac, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid decimal: %q, error: %w", s, err)
+	}
+	return Decimal{unscaled: unscaled, scale: int32(len(frac))}.normalize(), nil
+}
+
+// String formats the decimal without the trailing zeros, e.g. 19.99, -0.5, 42
+func (d Decimal) String() string {
+	s := strconv.FormatInt(d.unscaled, 10)
+	if d.scale == 0 {
+		return s
+	}
+
+	sign := ""
+	if d.unscaled < 0 {
+		sign, s = "-", s[1:]
+	}
+	if pad := int(d.scale) + 1 - len(s); pad > 0 {
+		s = strings.Repeat("0", pad) + s
+	}
+	point := len(s) - int(d.scale)
+	return sign + s[:point] + "." + s[point:]
+}
+
+// MarshalJSON encodes the decimal as a JSON number without the rounding, e.g. 19.99
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON decodes the decimal from a JSON number or string, e.g. 19.99 or "19.99"
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	res, err := ParseDecimal(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*d = res
+	return nil
+}
+
+// Float64 returns the nearest float64 of the decimal
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// Cmp returns -1, 0 or 1 if d is less than, equal to or greater than x
+func (d Decimal) Cmp(x Decimal) int {
+	return d.bigInt(maxDecimalScale).Cmp(x.bigInt(maxDecimalScale))
+}
+
+func (d Decimal) neg() Decimal {
+	d.unscaled = -d.unscaled
+	return d
+}
+
+func (d Decimal) normalize() Decimal {
+	for d.scale > 0 && d.unscaled%10 == 0 {
+		d.unscaled /= 10
+		d.scale--
+	}
+	return d
+}
+
+// bigInt returns the unscaled value of the decimal at the scale, which isn't less than d.scale
+func (d Decimal) bigInt(scale int32) *big.Int {
+	res := big.NewInt(d.unscaled)
+	if scale > d.scale {
+		res.Mul(res, pow10(scale-d.scale))
+	}
+	return res
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// newDecimalRounded returns the decimal of unscaled / 10^from rounded to the scale,
+// the halves are rounded away from zero
+func newDecimalRounded(unscaled *big.Int, from, scale int32) (Decimal, error) {
+	if from > scale {
+		unscaled = divRound(unscaled, pow10(from-scale))
+		from = scale
+	}
+	if !unscaled.IsInt64() {
+		return Decimal{}, errDecimalOverflow
+	}
+	return Decimal{unscaled: unscaled.Int64(), scale: from}.normalize(), nil
+}
+
+// divRound returns x / y rounded half away from zero
+func divRound(x, y *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(x, y, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	r.Abs(r).Lsh(r, 1)
+	if r.Cmp(new(big.Int).Abs(y)) >= 0 {
+		if x.Sign() == y.Sign() {
+			q.Add(q, big.NewInt(1))
+		} else {
+			q.Sub(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// toDecimal converts the decimal or int64 param to Decimal,
+// the float64 params are not converted implicitly to avoid the rounding errors
+func toDecimal(p Value) (Decimal, bool) {
+	switch v := p.(type) {
+	case Decimal:
+		return v, true
+	case int64:
+		return Decimal{unscaled: v}, true
+	}
+	return Decimal{}, false
+}
+
+func hasDecimal(params []Value) bool {
+	for _, p := range params {
+		if _, ok := p.(Decimal); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// decimalModes are the modes of the operators supporting the decimals if the DecimalArithmetic option is enabled
+var decimalModes = map[string]mode{
+	"add": add, "sub": sub, "mul": mul, "div": div, "mod": mod,
+	"+": add, "-": sub, "*": mul, "/": div, "%": mod,
+	"eq": equals, "ne": notEquals, "gt": greater, "lt": less, "ge": greaterEquals, "le": lessEquals,
+	"=": equals, "!=": notEquals, ">": greater, "<": less, ">=": greaterEquals, "<=": lessEquals,
+	"between": between,
+}
+
+// decimalArithmetic executes the operator with the decimals exactly if any param is a decimal,
+// the int64 params are promoted to decimals, and the mixed float64 params result in errors.
+// The operator of the other params is executed by next.
+type decimalArithmetic struct {
+	mode mode
+	// the results of mul and div are rounded to the scale
+	scale int32
+	next  Operator
+}
+
+func (d decimalArithmetic) execute(ctx *Ctx, params []Value) (Value, error) {
+	if !hasDecimal(params) {
+		return d.next(ctx, params)
+	}
+
+	op := modeNames[d.mode]
+	switch d.mode {
+	case equals, notEquals:
+		if len(params) < 2 || (d.mode == notEquals && len(params) != 2) {
+			return nil, errCnt2(d.mode, params)
+		}
+		for _, p := range params[1:] {
+			if !decimalEquals(params[0], p) {
+				return d.mode == notEquals, nil
+			}
+		}
+		return d.mode == equals, nil
+	}
+
+	ds := make([]Decimal, len(params))
+	for i, p := range params {
+		v, ok := toDecimal(p)
+		if !ok {
+			return nil, ParamTypeError(op, typeDecimal, p)
+		}
+		ds[i] = v
+	}
+
+	switch d.mode {
+	case greater, less, greaterEquals, lessEquals:
+		if len(ds) != 2 {
+			return nil, errCnt2(d.mode, params)
+		}
+		c := ds[0].Cmp(ds[1])
+		switch d.mode {
+		case greater:
+			return c > 0, nil
+		case less:
+			return c < 0, nil
+		case greaterEquals:
+			return c >= 0, nil
+		}
+		return c <= 0, nil
+	case between:
+		if len(ds) != 3 {
+			return nil, ParamsCountError(op, 3, len(ds))
+		}
+		return ds[1].Cmp(ds[0]) <= 0 && ds[0].Cmp(ds[2]) <= 0, nil
+	}
+
+	if len(ds) < 2 {
+		return nil, errCnt2(d.mode, params)
+	}
+	res := ds[0]
+	for _, v := range ds[1:] {
+		var err error
+		if res, err = d.apply(res, v); err != nil {
+			return nil, OpExecError(op, err)
+		}
+	}
+	return res, nil
+}
+
+// apply returns x op y, the results of mul and div are rounded to the scale
+func (d decimalArithmetic) apply(x, y Decimal) (Decimal, error) {
+	scale := x.scale
+	if y.scale > scale {
+		scale = y.scale
+	}
+	a, b := x.bigInt(scale), y.bigInt(scale)
+	switch d.mode {
+	case add:
+		return newDecimalRounded(a.Add(a, b), scale, scale)
+	case sub:
+		return newDecimalRounded(a.Sub(a, b), scale, scale)
+	case mul:
+		return newDecimalRounded(a.Mul(a, b), 2*scale, d.scale)
+	case div:
+		if b.Sign() == 0 {
+			return Decimal{}, errDivideByZero
+		}
+		// a/b = (a * 10^d.scale / b) / 10^d.scale
+		return newDecimalRounded(divRound(a.Mul(a, pow10(d.scale)), b), d.scale, d.scale)
+	case mod:
+		if b.Sign() == 0 {
+			return Decimal{}, errDivideByZero
+		}
+		return newDecimalRounded(a.Rem(a, b), scale, scale)
+	}
+	return Decimal{}, errInvalidMode(d.mode, "decimal arithmetic")
+}
+
+// decimalEquals compares the decimal with the int64 or decimal by their numeric values
+func decimalEquals(a, b Value) bool {
+	x, ok := toDecimal(a)
+	y, ok2 := toDecimal(b)
+	if !ok || !ok2 {
+		return a == b
+	}
+	return x.Cmp(y) == 0
+}
+
+// decimalConvert converts the string, int64 or float64 param to Decimal, e.g. (decimal "19.99"),
+// the float64 param is converted by its shortest representation, e.g. 0.1 => 0.1
+func decimalConvert(_ *Ctx, params []Value) (Value, error) {
+	const op = "decimal"
+	if len(params) != 1 {
+		return nil, ParamsCountError(op, 1, len(params))
+	}
+
+	switch v := params[0].(type) {
+	case Decimal:
+		return v, nil
+	case int64:
+		return Decimal{unscaled: v}, nil
+	case string:
+		d, err := ParseDecimal(v)
+		if err != nil {
+			return nil, OpExecError(op, err)
+		}
+		return d, nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, OpExecError(op, fmt.Errorf("invalid decimal: %v", v))
+		}
+		d, err := ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+		if err != nil {
+			return nil, OpExecError(op, err)
+		}
+		return d, nil
+	}
+	return nil, ParamTypeError(op, "string, int64 or float64", params[0])
+}
@@ -0,0 +1,145 @@
+package eval
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	vals := map[string]interface{}{
+		"price":    mustDecimal("19.99"),
+		"quantity": 3,
+		"rate":     0.1,
+		"discount": "2.5",
+	}
+
+	testCases := []struct {
+		expr string
+		opts []CompileOption
+		// the DecimalArithmetic option is enabled unless disabled is true
+		disabled bool
+		res      Value
+		errMsg   string
+	}{
+		{expr: `(+ 0.1d 0.2d)`, res: mustDecimal("0.3")},
+		{expr: `(= (+ 0.1d 0.2d) 0.3d)`, res: true},
+		{expr: `(* price quantity)`, res: mustDecimal("59.97")},
+		{expr: `(- (* price quantity) 0.97d)`, res: mustDecimal("59")},
+		{expr: `(= (- (* price quantity) 0.97d) 59)`, res: true},
+		{expr: `(/ 10d 3)`, res: mustDecimal("3.33333333")},
+		{expr: `(/ 10d 3)`, opts: []CompileOption{DecimalScale(2)}, res: mustDecimal("3.33")},
+		{expr: `(/ 2d 3)`, opts: []CompileOption{DecimalScale(2)}, res: mustDecimal("0.67")},
+		{expr: `(/ -2d 3)`, opts: []CompileOption{DecimalScale(2)}, res: mustDecimal("-0.67")},
+		{expr: `(* 1.25d 1.25d)`, opts: []CompileOption{DecimalScale(3)}, res: mustDecimal("1.563")},
+		{expr: `(% 10.5d 3)`, res: mustDecimal("1.5")},
+		{expr: `(> price 19.9d)`, res: true},
+		{expr: `(<= price 20)`, res: true},
+		{expr: `(between price 10 20)`, res: true},
+		{expr: `(!= 1.50d 1.5d)`, res: false},
+		{expr: `(= price "19.99")`, res: false},
+		{expr: `(coalesce null 1.5d)`, res: mustDecimal("1.5")},
+		{expr: `(- price (decimal discount))`, res: mustDecimal("17.49")},
+		{expr: `(decimal rate)`, res: mustDecimal("0.1")},
+		{expr: `(+ 1 2)`, res: int64(3)},
+		{expr: `(+ 1.5 2)`, res: 3.5},
+		{expr: `price * quantity > 50`, opts: []CompileOption{EnableInfixSyntax}, res: true},
+		{expr: `-1.5d + 2d`, opts: []CompileOption{EnableInfixSyntax}, res: mustDecimal("0.5")},
+		{expr: `(+ price 0.01d)`, opts: []CompileOption{EnableTypeCheck}, res: mustDecimal("20")},
+		{expr: `(+ price 0.01d)`, opts: []CompileOption{EnableNullPropagation}, res: mustDecimal("20")},
+		{expr: `(> (* price quantity) 50)`, opts: []CompileOption{Optimizations(false)}, res: true},
+		{expr: `(> price 19.9d)`, opts: []CompileOption{EnableZeroAlloc}, res: true},
+
+		{expr: `(+ 1.5d 1)`, disabled: true, errMsg: "requires the decimal_arithmetic option"},
+		{expr: `(+ price 1)`, disabled: true, errMsg: "expected: int64"},
+		{expr: `(+ price rate)`, errMsg: "expected: decimal"},
+		{expr: `(> price rate)`, errMsg: "expected: decimal"},
+		{expr: `(/ price 0)`, errMsg: "divide by zero"},
+		{expr: `(* 9223372036854775807d 10)`, errMsg: "decimal overflow"},
+		{expr: `99999999999999999999d`, errMsg: "invalid decimal"},
+		{expr: `(decimal "1.2.3")`, errMsg: "invalid decimal"},
+		{expr: `(decimal true)`, errMsg: "expected: string, int64 or float64"},
+		{expr: `(+ price 1.5)`, opts: []CompileOption{EnableTypeCheck}, errMsg: "expected: decimal"},
+	}
+
+	for _, c := range testCases {
+		opts := append(c.opts, RegisterSelKeys(vals))
+		if !c.disabled {
+			opts = append(opts, EnableDecimalArithmetic)
+		}
+		cc := NewCompileConfig(opts...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+}
+
+func TestDecimal_Expr(t *testing.T) {
+	cc := NewCompileConfig(EnableDecimalArithmetic, EnableTypeCheck, RegisterSelKeys(map[string]interface{}{"price": 0}))
+	cc.SelectorTypes["price"] = TypeDecimal
+
+	expr, err := Compile(cc, `(>= (* price 1.10d) 100.00d)`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(>= (* price 1.1d) 100d)`)
+
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	loaded, err := UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+
+	ctx := NewCtxWithMap(cc, map[string]interface{}{"price": mustDecimal("90.91")})
+	for _, e := range []*Expr{expr, loaded} {
+		res, err := e.Eval(ctx)
+		assertNil(t, err)
+		assertEquals(t, res, true)
+	}
+
+	folded, err := Compile(cc, `(+ 0.1d 0.2d)`)
+	assertNil(t, err)
+	assertEquals(t, folded.Decompile(), `0.3d`)
+
+	_, err = Compile(cc, `(and price true)`)
+	assertErrStrContains(t, err, "and param 0 should be bool, got: decimal")
+
+	for _, c := range []struct {
+		unscaled int64
+		scale    int
+		str      string
+	}{
+		{unscaled: 1999, scale: 2, str: "19.99"},
+		{unscaled: -5, scale: 1, str: "-0.5"},
+		{unscaled: 1500, scale: 3, str: "1.5"},
+		{unscaled: 7, scale: 4, str: "0.0007"},
+		{unscaled: 42, scale: 0, str: "42"},
+	} {
+		d, err := NewDecimal(c.unscaled, c.scale)
+		assertNil(t, err)
+		assertEquals(t, d.String(), c.str)
+		parsed, err := ParseDecimal(c.str)
+		assertNil(t, err)
+		assertEquals(t, parsed, d)
+	}
+	assertEquals(t, mustDecimal("0.25").Float64(), 0.25)
+
+	bs, err = json.Marshal(map[string]Value{"total": mustDecimal("59.97")})
+	assertNil(t, err)
+	assertEquals(t, string(bs), `{"total":59.97}`)
+	var total struct{ Total Decimal }
+	assertNil(t, json.Unmarshal([]byte(`{"Total": "59.97"}`), &total))
+	assertEquals(t, total.Total, mustDecimal("59.97"))
+	assertEquals(t, mustDecimal("1.5").Cmp(mustDecimal("1.50")), 0)
+
+	_, err = NewDecimal(1, 19)
+	assertErrStrContains(t, err, "decimal scale out of range")
+}
+
+func mustDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
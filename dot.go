@@ -0,0 +1,78 @@
+package eval
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToDot renders the compiled nodes as a Graphviz DOT graph.
+// The nodes are labeled with their indices, the solid edges point to the children,
+// and the dashed edges are the short circuit jumps, which are taken if the
+// result of the node is the label of the edge, e.g. false for the operands of and.
+func (e *Expr) ToDot() string {
+	size := len(e.nodes)
+	offset := 0
+	if size != 0 && e.nodes[0].getNodeType() == debug {
+		size /= 2
+		offset = size
+	}
+
+	var sb strings.Builder
+	sb.WriteString("digraph expr {\n")
+	sb.WriteString("  node [fontname=\"monospace\"];\n")
+
+	for i := 0; i < size; i++ {
+		n := e.getNode(i)
+		label, shape := dotLabel(n)
+		sb.WriteString(fmt.Sprintf("  n%d [label=%s, shape=%s];\n", i, strconv.Quote(fmt.Sprintf("#%d %s", i, label)), shape))
+	}
+
+	for i := 0; i < size; i++ {
+		n := e.getNode(i)
+		childIdx := int(n.childIdx)
+		if offset != 0 && childIdx >= offset {
+			// the children of the fast operators are the real nodes in the debug mode
+			childIdx -= offset
+		}
+		for j := 0; j < int(n.childCnt); j++ {
+			sb.WriteString(fmt.Sprintf("  n%d -> n%d;\n", i, childIdx+j))
+		}
+
+		var label, color string
+		switch {
+		case n.flag&scIfTrue != 0 && n.flag&scIfFalse != 0:
+			label, color = "true/false", "blue"
+		case n.flag&scIfTrue != 0:
+			label, color = "true", "darkgreen"
+		case n.flag&scIfFalse != 0:
+			label, color = "false", "red"
+		default:
+			continue
+		}
+		target := int(n.scIdx) - offset
+		sb.WriteString(fmt.Sprintf("  n%d -> n%d [style=dashed, color=%s, fontcolor=%s, label=%q, constraint=false];\n",
+			i, target, color, color, label))
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func dotLabel(n *node) (string, string) {
+	switch n.getNodeType() {
+	case constant:
+		return decompileValue(n.value), "plaintext"
+	case selector:
+		return fmt.Sprint(n.value), "ellipse"
+	case operator:
+		return fmt.Sprint(n.value), "box"
+	case fastOperator:
+		return fmt.Sprintf("%v (fast)", n.value), "box"
+	case cond:
+		return "if", "diamond"
+	case end:
+		return "end", "circle"
+	}
+	return "", "box"
+}
@@ -0,0 +1,45 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestExpr_ToDot(t *testing.T) {
+	const exprStr = `(and (> age 18) (or vip (= country "US")))`
+	want := `digraph expr {
+  node [fontname="monospace"];
+  n0 [label="#0 and", shape=box];
+  n1 [label="#1 > (fast)", shape=box];
+  n2 [label="#2 or", shape=box];
+  n3 [label="#3 age", shape=ellipse];
+  n4 [label="#4 18", shape=plaintext];
+  n5 [label="#5 vip", shape=ellipse];
+  n6 [label="#6 = (fast)", shape=box];
+  n7 [label="#7 country", shape=ellipse];
+  n8 [label="#8 \"US\"", shape=plaintext];
+  n0 -> n1;
+  n0 -> n2;
+  n1 -> n3;
+  n1 -> n4;
+  n1 -> n0 [style=dashed, color=red, fontcolor=red, label="false", constraint=false];
+  n2 -> n5;
+  n2 -> n6;
+  n2 -> n0 [style=dashed, color=blue, fontcolor=blue, label="true/false", constraint=false];
+  n5 -> n0 [style=dashed, color=darkgreen, fontcolor=darkgreen, label="true", constraint=false];
+  n6 -> n7;
+  n6 -> n8;
+  n6 -> n0 [style=dashed, color=blue, fontcolor=blue, label="true/false", constraint=false];
+}
+`
+
+	for _, opts := range [][]CompileOption{
+		{Optimizations(false, Reordering)},
+		{Optimizations(false, Reordering), EnableDebug},
+	} {
+		cc := NewCompileConfig(append(opts, EnableStringSelectors)...)
+		cc.DebugHandler = func(DebugEvent) {}
+		expr, err := Compile(cc, exprStr)
+		assertNil(t, err)
+		assertEquals(t, expr.ToDot(), want)
+	}
+}
@@ -4,7 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"io"
+	"sync"
 	"time"
 )
 
@@ -16,9 +17,22 @@ type (
 
 type Ctx struct {
 	Selector
+	// Ctx is checked periodically during the evaluation,
+	// Eval returns the error of Ctx once it is cancelled or its deadline expires
 	Ctx context.Context
+
+	// urls caches the parsed URLs of the url operators during the evaluation, see withURLCache
+	urls *urlCache
 }
 
+// ErrStepLimitExceeded is returned when the number of executed instructions
+// exceeds the MaxSteps of the CompileConfig
+var ErrStepLimitExceeded = errors.New("step limit exceeded")
+
+// cancelCheckInterval is the number of instructions executed
+// between two checks of the cancellation of Ctx.Ctx
+const cancelCheckInterval = 8
+
 const (
 	// node types
 	nodeTypeMask = uint8(0b111)
@@ -49,14 +63,72 @@ func (n *node) getNodeType() uint8 {
 	return n.flag & nodeTypeMask
 }
 
+// Expr is a compiled expression, it's immutable once compiled.
+//
+// An Expr is safe for concurrent use by multiple goroutines, all the state of an
+// evaluation, e.g. the stacks and the memoized selector values, is local to the call.
+// The caller is responsible for the things shared by the evaluations:
+//   - a Ctx should not be shared by the concurrent evaluations, unless its Selector is safe for concurrent use
+//   - the registered Operators should be safe for concurrent use, and they should not retain
+//     the params after they return, since the params are reused within an evaluation
+//   - in the debug mode, the DebugWriter and DebugHandler should be safe for concurrent use
 type Expr struct {
 	maxStackSize int16
-	nodes        []*node
+	// size of the params buffer shared by the operators with more than 2 params in an evaluation
+	paramsSize int16
+	// maximum number of executed instructions, unlimited if it is not positive
+	maxSteps int
+	// statically inferred type of the result
+	returnType Type
+	// the repeated selector reads within one evaluation are cached
+	memoizeSelectors bool
+	// the URLs parsed by the url operators within one evaluation are cached
+	cachesURLs bool
+	backend    Backend
+	// the root closure built by ClosureBackend
+	closure evalFunc
+	nodes   []*node
 	// extra info
 	parentIdx []int16
 	scIdx     []int16
 	sfSize    []int16
 	osSize    []int16
+
+	// the missing selectors are evaluated to the defaults or by the policy
+	missingSelector  MissingSelectorPolicy
+	selectorDefaults map[string]Value
+	// the operator panics are recovered
+	recoverPanics bool
+	// the nodes are reported to the hook if it isn't nil
+	hook EvalHook
+	// the root predicate built by ZeroAlloc
+	zeroAlloc bool
+	predicate predicateFunc
+	// the strings are compared ignoring the cases by the predicate
+	ignoreCase bool
+
+	// the selectors read by every evaluation, see SelectorPlan,
+	// and the registered ones are fetched before the evaluation if the Selector is a BatchSelector
+	requiredSelectors []memoEntry
+	batchKeys         []SelectorKey
+	// the required selectors are fetched concurrently by at most parallelSelectors goroutines
+	parallelSelectors int
+
+	// the config the expression is compiled with, which is copied by PartialEval
+	conf *CompileConfig
+
+	// debug output, only used in the debug mode
+	debugWriter  io.Writer
+	debugHandler func(DebugEvent)
+}
+
+// getNode returns the real node of the index, the debug node is skipped
+func (e *Expr) getNode(idx int) *node {
+	n := e.nodes[idx]
+	if n.getNodeType() == debug {
+		return e.nodes[idx+len(e.nodes)/2]
+	}
+	return n
 }
 
 func Eval(expr string, vals map[string]interface{}, confs ...*CompileConfig) (Value, error) {
@@ -79,31 +151,121 @@ func Eval(expr string, vals map[string]interface{}, confs ...*CompileConfig) (Va
 	return tree.Eval(NewCtxWithMap(conf, vals))
 }
 
+func EvalBool(expr string, vals map[string]interface{}, confs ...*CompileConfig) (bool, error) {
+	return boolResult(Eval(expr, vals, confs...))
+}
+
+func EvalInt64(expr string, vals map[string]interface{}, confs ...*CompileConfig) (int64, error) {
+	return int64Result(Eval(expr, vals, confs...))
+}
+
+func EvalFloat64(expr string, vals map[string]interface{}, confs ...*CompileConfig) (float64, error) {
+	return float64Result(Eval(expr, vals, confs...))
+}
+
+func EvalString(expr string, vals map[string]interface{}, confs ...*CompileConfig) (string, error) {
+	return stringResult(Eval(expr, vals, confs...))
+}
+
+// ReturnType returns the result type inferred at compile time with the declared
+// CompileConfig.SelectorTypes and OperatorSignatures, it's TypeAny if the type is unknown.
+// The expression never results in a value of another type unless the evaluation fails.
+func (e *Expr) ReturnType() Type {
+	return e.returnType
+}
+
 func (e *Expr) EvalBool(ctx *Ctx) (bool, error) {
-	res, err := e.Eval(ctx)
+	return boolResult(e.Eval(ctx))
+}
+
+func (e *Expr) EvalInt64(ctx *Ctx) (int64, error) {
+	return int64Result(e.Eval(ctx))
+}
+
+// EvalFloat64 evaluates the expression and converts the numeric result to float64
+func (e *Expr) EvalFloat64(ctx *Ctx) (float64, error) {
+	return float64Result(e.Eval(ctx))
+}
+
+func (e *Expr) EvalString(ctx *Ctx) (string, error) {
+	return stringResult(e.Eval(ctx))
+}
+
+func boolResult(res Value, err error) (bool, error) {
 	if err != nil {
 		return false, err
 	}
 	v, ok := res.(bool)
 	if !ok {
-		return false, fmt.Errorf("invalid result type: %v", res)
+		return false, resultTypeError(typeBool, res)
 	}
 	return v, nil
 }
 
-func (e *Expr) Eval(ctx *Ctx) (Value, error) {
-	var (
-		size   = e.maxStackSize
-		nodes  = e.nodes
-		maxIdx = int16(-1)
+func int64Result(res Value, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	v, ok := unifyType(res).(int64)
+	if !ok {
+		return 0, resultTypeError(typeInt, res)
+	}
+	return v, nil
+}
+
+func float64Result(res Value, err error) (float64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v := unifyType(res).(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	}
+	return 0, resultTypeError("float64", res)
+}
+
+func stringResult(res Value, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	v, ok := res.(string)
+	if !ok {
+		return "", resultTypeError(typeStr, res)
+	}
+	return v, nil
+}
+
+// condTypeError is the error of the if condition which isn't a bool value
+func condTypeError(res Value) error {
+	if m, ok := res.(missingValue); ok {
+		return m.err
+	}
+	return fmt.Errorf("eval error, result type of if condition should be bool, got: [%v]", res)
+}
 
-		sf    []int16 // stack frame
-		sfTop = int16(-1)
+func resultTypeError(want string, res Value) error {
+	return fmt.Errorf("invalid result type: %v, expected: %s, got: %T", res, want, res)
+}
 
-		os    []Value // operand stack
-		osTop = int16(-1)
+func (e *Expr) Eval(ctx *Ctx) (Value, error) {
+	if e.isBatchSelector(ctx) {
+		return e.evalWithStacks(ctx, nil, nil)
+	}
+	if e.predicate != nil {
+		return e.evalPredicate(ctx)
+	}
+	if e.memoizeSelectors || e.cachesURLs || e.closure != nil || e.hook != nil {
+		return e.evalWithStacks(ctx, nil, nil)
+	}
 
-		scTriggered bool
+	var (
+		size = e.maxStackSize
+		sf   []int16 // stack frame
+		os   []Value // operand stack
 	)
 
 	// ensure that variables do not escape to the heap in most cases
@@ -115,10 +277,100 @@ func (e *Expr) Eval(ctx *Ctx) (Value, error) {
 		os = make([]Value, 16)
 		sf = make([]int16, 16)
 	default:
-		os = make([]Value, size)
-		sf = make([]int16, size)
+		// the large stacks are reused, which would be allocated on the heap anyway
+		s := getStacks(size)
+		res, err := e.eval(ctx, s.os, s.sf)
+		putStacks(s)
+		return res, err
 	}
 
+	return e.eval(ctx, os, sf)
+}
+
+// stacks of the expressions whose maxStackSize is too large to be allocated on the stack
+type evalStacks struct {
+	os []Value
+	sf []int16
+}
+
+var stacksPool = sync.Pool{
+	New: func() interface{} {
+		return &evalStacks{}
+	},
+}
+
+func getStacks(size int16) *evalStacks {
+	s := stacksPool.Get().(*evalStacks)
+	if int16(len(s.os)) < size {
+		s.os = make([]Value, size)
+		s.sf = make([]int16, size)
+	}
+	return s
+}
+
+func putStacks(s *evalStacks) {
+	// the operands should not be kept alive by the pool
+	for i := range s.os {
+		s.os[i] = nil
+	}
+	stacksPool.Put(s)
+}
+
+// paramsOf returns the params of the operator from the buffer,
+// they are allocated for the and/or operators which are not counted in the paramsSize
+func paramsOf(buf []Value, cnt int16) []Value {
+	if int(cnt) > len(buf) {
+		return make([]Value, cnt)
+	}
+	return buf[:cnt]
+}
+
+// evalWithStacks evaluates the expression with the options of the expression applied,
+// the stacks are only used by the interpreter, they are allocated if they are nil.
+func (e *Expr) evalWithStacks(ctx *Ctx, os []Value, sf []int16) (Value, error) {
+	ctx, err := e.prefetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case ctx == nil:
+	case e.parallelSelectors > 0:
+		// the fetched values are memoized
+		ctx = e.fetchParallel(ctx)
+	case e.memoizeSelectors:
+		ctx = newMemoCtx(ctx)
+	}
+	if e.cachesURLs {
+		ctx = withURLCache(ctx)
+	}
+	if e.hook != nil {
+		return e.evalHooked(ctx)
+	}
+	if e.predicate != nil {
+		return e.evalPredicate(ctx)
+	}
+	if e.closure != nil {
+		return e.evalClosure(ctx)
+	}
+	if os == nil {
+		size := max(int(e.maxStackSize), 8)
+		os, sf = make([]Value, size), make([]int16, size)
+	}
+	return e.eval(ctx, os, sf)
+}
+
+// eval executes the expression with the given stacks,
+// the length of both stacks should not be less than maxStackSize
+func (e *Expr) eval(ctx *Ctx, os []Value, sf []int16) (Value, error) {
+	var (
+		nodes  = e.nodes
+		maxIdx = int16(-1)
+		sfTop  = int16(-1)
+		osTop  = int16(-1)
+
+		scTriggered bool
+	)
+
 	var (
 		curtIdx int16
 		curt    *node
@@ -126,17 +378,41 @@ func (e *Expr) Eval(ctx *Ctx) (Value, error) {
 		res Value // result of current stack frame
 		err error
 
+		// the params of all the operators are in the buffer allocated once per evaluation
 		param  []Value
-		param2 [2]Value
+		params = make([]Value, maxInt16(e.paramsSize, 2))
+	)
+
+	// the done channel of the context is checked every cancelCheckInterval instructions
+	var (
+		done     <-chan struct{}
+		steps    int
+		maxSteps = e.maxSteps
 	)
+	if ctx != nil && ctx.Ctx != nil {
+		done = ctx.Ctx.Done()
+	}
 
-	// push the root node to the stack frame
-	// just increase the sfTop because the index of root node is zero,
-	// so we don't need to actually push zero to stack
+	// push the root node to the stack frame, the index of root node is zero
 	// e.g. sf[sfTop+1], sfTop = 0, sfTop+1
-	sfTop = 0
+	// the stacks may be reused by EvalBatch, so the zero is written explicitly
+	sf[0], sfTop = 0, 0
 
 	for sfTop != -1 { // while stack frame is not empty
+		if done != nil || maxSteps > 0 {
+			if done != nil && steps%cancelCheckInterval == 0 {
+				select {
+				case <-done:
+					return nil, ctx.Ctx.Err()
+				default:
+				}
+			}
+			steps++
+			if maxSteps > 0 && steps > maxSteps {
+				return nil, fmt.Errorf("%w, max steps: %d", ErrStepLimitExceeded, maxSteps)
+			}
+		}
+
 		curtIdx, sfTop = sf[sfTop], sfTop-1
 		curt = nodes[curtIdx]
 
@@ -144,21 +420,20 @@ func (e *Expr) Eval(ctx *Ctx) (Value, error) {
 		case fastOperator:
 			cnt := int16(curt.childCnt)
 			childIdx := curt.childIdx
+			param = paramsOf(params, cnt)
 			if cnt == 2 {
-				param2[0], err = getNodeValue(ctx, nodes[childIdx])
+				param[0], err = e.getNodeValue(ctx, nodes[childIdx])
 				if err != nil {
 					return nil, err
 				}
-				param2[1], err = getNodeValue(ctx, nodes[childIdx+1])
+				param[1], err = e.getNodeValue(ctx, nodes[childIdx+1])
 				if err != nil {
 					return nil, err
 				}
-				param = param2[:]
 			} else {
-				param = make([]Value, cnt)
 				for i := int16(0); i < cnt; i++ {
 					child := nodes[childIdx+i]
-					param[i], err = getNodeValue(ctx, child)
+					param[i], err = e.getNodeValue(ctx, child)
 					if err != nil {
 						return nil, err
 					}
@@ -193,11 +468,10 @@ func (e *Expr) Eval(ctx *Ctx) (Value, error) {
 			// current node has been visited
 			maxIdx = curtIdx
 			osTop = osTop - cnt
+			param = paramsOf(params, cnt)
 			if cnt == 2 {
-				param2[0], param2[1] = os[osTop+1], os[osTop+2]
-				param = param2[:]
+				param[0], param[1] = os[osTop+1], os[osTop+2]
 			} else {
-				param = make([]Value, cnt)
 				copy(param, os[osTop+1:])
 			}
 			res, err = curt.operator(ctx, param)
@@ -205,7 +479,7 @@ func (e *Expr) Eval(ctx *Ctx) (Value, error) {
 				return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", curt.value, err)
 			}
 		case selector:
-			res, err = getSelectorValue(ctx, curt)
+			res, err = e.getSelectorValue(ctx, curt)
 			if err != nil {
 				return nil, err
 			}
@@ -225,7 +499,7 @@ func (e *Expr) Eval(ctx *Ctx) (Value, error) {
 				res, osTop = os[osTop], osTop-1
 				condRes, ok := res.(bool)
 				if !ok {
-					return nil, fmt.Errorf("eval error, result type of if condition should be bool, got: [%v]", res)
+					return nil, condTypeError(res)
 				}
 				if condRes {
 					sf[sfTop+1], sfTop = childIdx+1, sfTop+1
@@ -245,7 +519,7 @@ func (e *Expr) Eval(ctx *Ctx) (Value, error) {
 			// push the real node to print stacks
 			sf[sfTop+1], sfTop = curtIdx+offset, sfTop+1
 
-			e.printStacks(scTriggered, maxIdx, os, osTop, sf, sfTop)
+			e.printStacks(curtIdx, scTriggered, maxIdx, os, osTop, sf, sfTop)
 			scTriggered = false
 			continue
 		}
@@ -272,13 +546,15 @@ func (e *Expr) Eval(ctx *Ctx) (Value, error) {
 		// push the result of current frame to operator stack
 		os[osTop+1], osTop = res, osTop+1
 	}
-	return os[0], nil
+	return checkMissingResult(os[0], nil)
 }
 
 func unifyType(val Value) Value {
 	switch v := val.(type) {
 	case int:
 		return int64(v)
+	case float32:
+		return float64(v)
 	case time.Time:
 		return v.Unix()
 	case time.Duration:
@@ -307,26 +583,22 @@ func unifyType(val Value) Value {
 	return val
 }
 
-func getNodeValue(ctx *Ctx, n *node) (res Value, err error) {
-	if n.flag&nodeTypeMask == constant {
-		res = n.value
-	} else {
-		res, err = getSelectorValue(ctx, n)
-	}
-	return
-}
-
-func getSelectorValue(ctx *Ctx, n *node) (res Value, err error) {
-	res, err = ctx.Get(n.selKey, n.value.(string))
+// GetValue gets the value of the selector, and converts it to the types used in the expressions
+func (c *Ctx) GetValue(selKey SelectorKey, strKey string) (res Value, err error) {
+	res, err = c.Get(selKey, strKey)
 	if err != nil {
 		return
 	}
+	return unifySelectorValue(res), nil
+}
 
-	switch res.(type) {
-	case bool, string, int64, []int64, []string:
-		return
+// unifySelectorValue converts the value got from the selector to the types of the operands
+func unifySelectorValue(val Value) Value {
+	switch val.(type) {
+	case bool, string, int64, float64, []int64, []string:
+		return val
 	default:
-		return unifyType(res), nil
+		return unifyType(val)
 	}
 }
 
@@ -338,26 +610,3 @@ func debugStackFrame(sf []int16, sfTop, offset int16) {
 		}
 	}
 }
-
-func (e *Expr) printStacks(scTriggered bool, maxIdx int16, os []Value, osTop int16, sf []int16, sfTop int16) {
-	if scTriggered {
-		fmt.Printf("short circuit triggered\n\n")
-	}
-	var sb strings.Builder
-
-	offset := int16(len(e.nodes)) / 2
-
-	fmt.Printf("maxIdx:%d, sfTop:%d, osTop:%d\n", maxIdx-offset, sfTop, osTop)
-	sb.WriteString(fmt.Sprintf("%15s", "Stack Frame: "))
-	for i := sfTop; i >= 0; i-- {
-		sb.WriteString(fmt.Sprintf("|%4v", e.nodes[sf[i]].value))
-	}
-	sb.WriteString("|\n")
-
-	sb.WriteString(fmt.Sprintf("%15s", "Operand Stack: "))
-	for i := osTop; i >= 0; i-- {
-		sb.WriteString(fmt.Sprintf("|%4v", os[i]))
-	}
-	sb.WriteString("|\n")
-	fmt.Println(sb.String())
-}
@@ -1,6 +1,8 @@
 package eval
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"reflect"
@@ -453,6 +455,205 @@ func TestEval_AllowUnknownSelector(t *testing.T) {
 
 }
 
+func TestEval_TypedResults(t *testing.T) {
+	vals := map[string]interface{}{
+		"i32":  int32(3),
+		"f32":  float32(1.5),
+		"name": "eval",
+		"ok":   true,
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+	ctx := NewCtxWithMap(cc, vals)
+
+	compile := func(s string) *Expr {
+		expr, err := Compile(cc, s)
+		assertNil(t, err, s)
+		return expr
+	}
+
+	b, err := compile(`(> i32 2)`).EvalBool(ctx)
+	assertNil(t, err)
+	assertEquals(t, b, true)
+
+	i, err := compile(`(+ i32 2)`).EvalInt64(ctx)
+	assertNil(t, err)
+	assertEquals(t, i, int64(5))
+
+	f, err := compile(`(+ i32 2)`).EvalFloat64(ctx)
+	assertNil(t, err)
+	assertEquals(t, f, float64(5))
+
+	f, err = compile(`(if ok f32 0)`).EvalFloat64(ctx)
+	assertNil(t, err)
+	assertEquals(t, f, 1.5)
+
+	s, err := compile(`(if ok name "")`).EvalString(ctx)
+	assertNil(t, err)
+	assertEquals(t, s, "eval")
+
+	_, err = compile(`(if ok name "")`).EvalInt64(ctx)
+	assertErrStrContains(t, err, "invalid result type: eval, expected: int64, got: string")
+
+	_, err = compile(`(not ok)`).EvalFloat64(ctx)
+	assertErrStrContains(t, err, "expected: float64")
+
+	_, err = compile(`(if ok i32 0)`).EvalString(ctx)
+	assertErrStrContains(t, err, "expected: string")
+
+	_, err = compile(`(if ok i32 0)`).EvalBool(ctx)
+	assertErrStrContains(t, err, "expected: bool")
+
+	// package level
+	b, err = EvalBool(`(= name "eval")`, vals)
+	assertNil(t, err)
+	assertEquals(t, b, true)
+
+	i, err = EvalInt64(`(* i32 i32)`, vals)
+	assertNil(t, err)
+	assertEquals(t, i, int64(9))
+
+	f, err = EvalFloat64(`(- i32 4)`, vals)
+	assertNil(t, err)
+	assertEquals(t, f, float64(-1))
+
+	s, err = EvalString(`(if ok name "")`, vals)
+	assertNil(t, err)
+	assertEquals(t, s, "eval")
+
+	_, err = EvalString(`(+ 1`, vals)
+	assertErrStrContains(t, err, "parentheses unmatched error")
+}
+
+type slowSelector struct {
+	MapSelector
+	delay time.Duration
+}
+
+func (s slowSelector) Get(selKey SelectorKey, strKey string) (Value, error) {
+	time.Sleep(s.delay)
+	return s.MapSelector.Get(selKey, strKey)
+}
+
+func TestEval_ContextCancellation(t *testing.T) {
+	vals := map[string]interface{}{
+		"v1": 1,
+		"v2": 2,
+		"v3": 3,
+	}
+	cc := NewCompileConfig(EnableStringSelectors, Optimizations(false))
+	expr, err := Compile(cc, fmt.Sprintf(`(+ %s)`, strings.Repeat("v1 v2 v3 (+ v1 v2) ", 20)))
+	assertNil(t, err)
+
+	sel := slowSelector{
+		MapSelector: NewMapSelector(vals),
+		delay:       time.Millisecond,
+	}
+
+	// without deadline
+	res, err := expr.Eval(&Ctx{Selector: sel, Ctx: context.Background()})
+	assertNil(t, err)
+	assertEquals(t, res, int64(180))
+
+	// cancelled before evaluation
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = expr.Eval(&Ctx{Selector: sel, Ctx: cancelled})
+	assertEquals(t, err, context.Canceled)
+
+	// deadline expires during evaluation
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = expr.Eval(&Ctx{Selector: sel, Ctx: timeout})
+	assertEquals(t, err, context.DeadlineExceeded)
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("evaluation is not aborted in time, elapsed: %v", elapsed)
+	}
+}
+
+// TestExpr_ConcurrentEval evaluates the shared expressions from many goroutines,
+// it should be run with -race to detect the shared mutable state
+func TestExpr_ConcurrentEval(t *testing.T) {
+	const (
+		goroutines = 16
+		rounds     = 200
+	)
+
+	var debugEvents int64
+	deep := strings.Repeat("(+ age ", 20) + "1" + strings.Repeat(")", 20)
+
+	testCases := []struct {
+		name string
+		expr string
+		opts []CompileOption
+	}{
+		{name: "bytecode", expr: `(and (> age 18) (in country ("US" "CA")))`},
+		{name: "large stacks", expr: deep},
+		{name: "closure", expr: `(if (> age 18) (* age 2) (- 0 age))`, opts: []CompileOption{
+			func(c *CompileConfig) { c.Backend = ClosureBackend },
+		}},
+		{name: "memoization", expr: `(or (> age 60) (< age 18) (= age 30))`, opts: []CompileOption{EnableSelectorMemoization}},
+		{name: "regex cache", expr: `(matches country pattern)`},
+		{name: "step limit", expr: `(and (> age 18) (in country ("US" "CA")))`, opts: []CompileOption{LimitSteps(100)}},
+		{name: "zero alloc", expr: `(and (> age 18) (!= country "CN"))`, opts: []CompileOption{EnableZeroAlloc}},
+		{name: "debug", expr: `(or (> age 60) (< age 18))`, opts: []CompileOption{EnableDebug, func(c *CompileConfig) {
+			c.DebugHandler = func(DebugEvent) { atomic.AddInt64(&debugEvents, 1) }
+		}}},
+	}
+
+	newVals := func(i int) map[string]interface{} {
+		return map[string]interface{}{
+			"age":     int64(i % 80),
+			"country": []string{"US", "CA", "JP"}[i%3],
+			"pattern": []string{"^U", "A$", "P"}[i%3],
+		}
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			cc := NewCompileConfig(append([]CompileOption{EnableStringSelectors}, c.opts...)...)
+			expr, err := Compile(cc, c.expr)
+			assertNil(t, err, c.name)
+
+			wants := make([]Value, rounds)
+			for i := range wants {
+				wants[i], err = expr.Eval(NewCtxWithMap(cc, newVals(i)))
+				assertNil(t, err, c.name)
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, goroutines)
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := range wants {
+						// start from different rounds, so that different values are evaluated at the same time
+						j := (i + g*rounds/goroutines) % rounds
+						got, err := expr.Eval(NewCtxWithMap(cc, newVals(j)))
+						if err == nil && !reflect.DeepEqual(got, wants[j]) {
+							err = fmt.Errorf("round: %d, want: %v, got: %v", j, wants[j], got)
+						}
+						if err != nil {
+							errs <- err
+							return
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	if atomic.LoadInt64(&debugEvents) == 0 {
+		t.Fatal("no debug events are received")
+	}
+}
+
 func TestRandomExpressions(t *testing.T) {
 	const (
 		size          = 10000
@@ -511,7 +712,7 @@ func TestRandomExpressions(t *testing.T) {
 			for atomic.LoadInt32(&genCnt) < size {
 				i := int(atomic.AddInt32(&genCnt, 1))
 				options := make([]GenExprOption, 0, 4)
-				v := random.Intn(0b1000)
+				v := r.Intn(0b1000)
 
 				if v&0b001 != 0 {
 					options = append(options, GenType(Bool))
@@ -546,12 +747,15 @@ func TestRandomExpressions(t *testing.T) {
 		go func(r *rand.Rand) {
 			defer cwg.Done()
 			for expr := range exprChan {
-				v := r.Intn(0b1000)
-				// combination of optimizations
+				v := r.Intn(0b10000)
+				// combination of optimizations and backends
 				cc := CopyCompileConfig(conf)
 				cc.CompileOptions[Reordering] = v&0b1 != 0
 				cc.CompileOptions[FastEvaluation] = v&0b10 != 0
 				cc.CompileOptions[ConstantFolding] = v&0b100 != 0
+				if v&0b1000 != 0 {
+					cc.Backend = ClosureBackend
+				}
 				got, err := Eval(expr.Expr, valMap, cc)
 
 				verifyChan <- execRes{
@@ -630,3 +834,224 @@ func assertErrStrContains(t *testing.T, err error, errMsg string, msg ...any) {
 		t.Fatalf("assertErrStrContains failed, err: %v, want: %s, msg: %+v", err, errMsg, msg)
 	}
 }
+
+func TestEval_StepLimit(t *testing.T) {
+	vals := map[string]interface{}{
+		"v1": 1,
+		"v2": 2,
+	}
+	exprStr := fmt.Sprintf(`(+ %s)`, strings.Repeat("v1 v2 (+ v1 v2) ", 20))
+
+	cc := NewCompileConfig(RegisterSelKeys(vals), Optimizations(false))
+	expr, err := Compile(cc, exprStr)
+	assertNil(t, err)
+	res, err := expr.Eval(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, int64(120))
+
+	cc = NewCompileConfig(RegisterSelKeys(vals), Optimizations(false), LimitSteps(1000))
+	expr, err = Compile(cc, exprStr)
+	assertNil(t, err)
+	res, err = expr.Eval(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, int64(120))
+
+	cc = NewCompileConfig(RegisterSelKeys(vals), Optimizations(false), LimitSteps(50))
+	expr, err = Compile(cc, exprStr)
+	assertNil(t, err)
+	_, err = expr.Eval(NewCtxWithMap(cc, vals))
+	assertEquals(t, errors.Is(err, ErrStepLimitExceeded), true)
+	assertErrStrContains(t, err, "max steps: 50")
+
+	// works together with the context
+	_, err = expr.Eval(&Ctx{Selector: NewMapSelector(vals), Ctx: context.Background()})
+	assertEquals(t, errors.Is(err, ErrStepLimitExceeded), true)
+
+	// the limit is kept in the serialized expression
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	loaded, err := UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+	_, err = loaded.Eval(NewCtxWithMap(cc, vals))
+	assertEquals(t, errors.Is(err, ErrStepLimitExceeded), true)
+}
+
+func TestEval_Float(t *testing.T) {
+	vals := map[string]interface{}{
+		"price":    float32(9.5),
+		"quantity": 3,
+		"ratio":    0.25,
+	}
+
+	testCases := []struct {
+		expr   string
+		infix  bool
+		strict bool
+		want   Value
+		errMsg string
+	}{
+		{expr: `(* price quantity)`, want: 28.5},
+		{expr: `(+ 1.5 2)`, want: 3.5},
+		{expr: `(- 1 0.25 0.25)`, want: 0.5},
+		{expr: `(/ 1 4.0)`, want: 0.25},
+		{expr: `(% 5.5 2)`, want: 1.5},
+		{expr: `(/ price 0.0)`, errMsg: "divide by zero"},
+		{expr: `(> price 9)`, want: true},
+		{expr: `(<= ratio 0.25)`, want: true},
+		{expr: `(= 2 2.0)`, want: true},
+		{expr: `(!= 2 2.5)`, want: true},
+		{expr: `(between ratio 0 1)`, want: true},
+		{expr: `(/ 7 2)`, want: int64(3)},
+		{expr: `price * quantity >= 28.5 && ratio < 1e-1 * 3`, infix: true, want: true},
+		{expr: `-1.5 * 2`, infix: true, want: -3.0},
+		{expr: `1.5e2 + 1`, infix: true, want: 151.0},
+
+		// the int64 values are not promoted in the strict mode
+		{expr: `(* price quantity)`, strict: true, errMsg: paramTypeErrMsg},
+		{expr: `(> ratio 0)`, strict: true, errMsg: paramTypeErrMsg},
+		{expr: `(= 2 2.0)`, strict: true, want: false},
+		{expr: `(* price 2.0)`, strict: true, want: 19.0},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(RegisterSelKeys(vals))
+		if c.infix {
+			cc.SyntaxMode = InfixSyntax
+		}
+		cc.CompileOptions[StrictNumeric] = c.strict
+
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.want, c.expr)
+	}
+
+	// the float constants are kept in the decompiled and serialized expressions
+	cc := NewCompileConfig(RegisterSelKeys(vals), Optimizations(false))
+	expr, err := Compile(cc, `(> (* price 2.0) 1e21 -0.5)`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(> (* price 2.0) 1e+21 -0.5)`)
+	_, err = Compile(cc, expr.Decompile())
+	assertNil(t, err)
+
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	loaded, err := UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+	assertEquals(t, loaded.Decompile(), expr.Decompile())
+}
+
+func TestEval_PooledStacks(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors, Optimizations(false))
+	small, err := Compile(cc, fmt.Sprintf(`(+ %s)`, strings.Repeat("v ", 20)))
+	assertNil(t, err)
+	large, err := Compile(cc, fmt.Sprintf(`(+ %s)`, strings.Repeat("v (+ v v) ", 20)))
+	assertNil(t, err)
+	assertEquals(t, small.maxStackSize > 16, true)
+	assertEquals(t, large.maxStackSize > small.maxStackSize, true)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(v int64) {
+			defer wg.Done()
+			ctx := NewCtxWithMap(cc, map[string]interface{}{"v": v})
+			for j := 0; j < 100; j++ {
+				res, err := small.Eval(ctx)
+				assertNil(t, err)
+				assertEquals(t, res, 20*v)
+
+				res, err = large.Eval(ctx)
+				assertNil(t, err)
+				assertEquals(t, res, 60*v)
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	// the stacks are taken from the pool instead of being allocated
+	expr, err := Compile(cc, fmt.Sprintf(`(and %s)`, strings.Repeat("(= 1 1) ", 20)))
+	assertNil(t, err)
+	assertEquals(t, expr.maxStackSize > 16, true)
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = expr.Eval(nil)
+	})
+	if allocs > 1 {
+		t.Fatalf("stacks are allocated, allocs: %v", allocs)
+	}
+}
+
+func TestEval_ParamsBuffer(t *testing.T) {
+	vals := map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4, "t": true}
+	testCases := []struct {
+		expr string
+		want Value
+	}{
+		{expr: `(+ a b c d)`, want: int64(10)},
+		{expr: `(+ a (* b c d) (- d c) (+ a (+ b c d) d))`, want: int64(40)},
+		{expr: `(and t (> a 0) (< b 3) (= c 3) (!= d 0) t)`, want: true},
+		{expr: `(= (+ a b c) (+ c b a) (- (* d 2 1) 2))`, want: true},
+		{expr: `(if (= a 1 1) (list a b c d) (list d c b a))`, want: []int64{1, 2, 3, 4}},
+	}
+
+	for _, c := range testCases {
+		for _, backend := range []Backend{BytecodeBackend, ClosureBackend} {
+			cc := NewCompileConfig(RegisterSelKeys(vals), Optimizations(false))
+			cc.Backend = backend
+			expr, err := Compile(cc, c.expr)
+			assertNil(t, err)
+			ctx := NewCtxWithMap(cc, vals)
+
+			res, err := expr.Eval(ctx)
+			assertNil(t, err)
+			assertEquals(t, res, c.want, c.expr, backend)
+
+			// the params buffer is allocated once per evaluation
+			allocs := testing.AllocsPerRun(100, func() {
+				_, _ = expr.Eval(ctx)
+			})
+			if _, isList := c.want.([]int64); !isList && allocs > 2 {
+				t.Fatalf("unexpected allocs: %v, expr: %s, backend: %v", allocs, c.expr, backend)
+			}
+		}
+	}
+}
+
+func TestEval_Switch(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":  30,
+		"name": "larry",
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals), Optimizations(false))
+
+	testCases := []struct {
+		expr   string
+		res    Value
+		errMsg string
+	}{
+		{expr: `(switch (< age 18) "minor" (< age 65) "adult" "senior")`, res: "adult"},
+		{expr: `(switch (> age 60) "senior" "other")`, res: "other"},
+		// the branches after the taken one are not evaluated
+		{expr: `(switch (= name "larry") 1 (> name 1) 2 3)`, res: int64(1)},
+		{expr: `(switch (> name 1) 1 2)`, errMsg: "operator: >"},
+		{expr: `(switch age 1 2)`, errMsg: "if condition should be bool"},
+		{expr: `(switch (< age 18) 1 2 3)`, errMsg: "switch parameters count error"},
+	}
+
+	for _, c := range testCases {
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+
+	expr, err := Compile(cc, `(switch (< age 18) "minor" (< age 65) "adult" "senior")`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(if (< age 18) "minor" (if (< age 65) "adult" "senior"))`)
+}
@@ -0,0 +1,530 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: eval.proto
+
+package evalpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Syntax int32
+
+const (
+	Syntax_SYNTAX_UNSPECIFIED Syntax = 0
+	Syntax_SYNTAX_PREFIX      Syntax = 1
+	Syntax_SYNTAX_INFIX       Syntax = 2
+	Syntax_SYNTAX_CEL         Syntax = 3
+	Syntax_SYNTAX_SQL         Syntax = 4
+)
+
+// Enum value maps for Syntax.
+var (
+	Syntax_name = map[int32]string{
+		0: "SYNTAX_UNSPECIFIED",
+		1: "SYNTAX_PREFIX",
+		2: "SYNTAX_INFIX",
+		3: "SYNTAX_CEL",
+		4: "SYNTAX_SQL",
+	}
+	Syntax_value = map[string]int32{
+		"SYNTAX_UNSPECIFIED": 0,
+		"SYNTAX_PREFIX":      1,
+		"SYNTAX_INFIX":       2,
+		"SYNTAX_CEL":         3,
+		"SYNTAX_SQL":         4,
+	}
+)
+
+func (x Syntax) Enum() *Syntax {
+	p := new(Syntax)
+	*p = x
+	return p
+}
+
+func (x Syntax) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Syntax) Descriptor() protoreflect.EnumDescriptor {
+	return file_eval_proto_enumTypes[0].Descriptor()
+}
+
+func (Syntax) Type() protoreflect.EnumType {
+	return &file_eval_proto_enumTypes[0]
+}
+
+func (x Syntax) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Syntax.Descriptor instead.
+func (Syntax) EnumDescriptor() ([]byte, []int) {
+	return file_eval_proto_rawDescGZIP(), []int{0}
+}
+
+type CompileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Expr          string                 `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
+	Syntax        Syntax                 `protobuf:"varint,2,opt,name=syntax,proto3,enum=eval.v1.Syntax" json:"syntax,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompileRequest) Reset() {
+	*x = CompileRequest{}
+	mi := &file_eval_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompileRequest) ProtoMessage() {}
+
+func (x *CompileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eval_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompileRequest.ProtoReflect.Descriptor instead.
+func (*CompileRequest) Descriptor() ([]byte, []int) {
+	return file_eval_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CompileRequest) GetExpr() string {
+	if x != nil {
+		return x.Expr
+	}
+	return ""
+}
+
+func (x *CompileRequest) GetSyntax() Syntax {
+	if x != nil {
+		return x.Syntax
+	}
+	return Syntax_SYNTAX_UNSPECIFIED
+}
+
+type CompileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReturnType    string                 `protobuf:"bytes,1,opt,name=return_type,json=returnType,proto3" json:"return_type,omitempty"`
+	Selectors     []string               `protobuf:"bytes,2,rep,name=selectors,proto3" json:"selectors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompileResponse) Reset() {
+	*x = CompileResponse{}
+	mi := &file_eval_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompileResponse) ProtoMessage() {}
+
+func (x *CompileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_eval_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompileResponse.ProtoReflect.Descriptor instead.
+func (*CompileResponse) Descriptor() ([]byte, []int) {
+	return file_eval_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CompileResponse) GetReturnType() string {
+	if x != nil {
+		return x.ReturnType
+	}
+	return ""
+}
+
+func (x *CompileResponse) GetSelectors() []string {
+	if x != nil {
+		return x.Selectors
+	}
+	return nil
+}
+
+type EvalRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Expr          string                 `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
+	Syntax        Syntax                 `protobuf:"varint,2,opt,name=syntax,proto3,enum=eval.v1.Syntax" json:"syntax,omitempty"`
+	Ctx           *structpb.Struct       `protobuf:"bytes,3,opt,name=ctx,proto3" json:"ctx,omitempty"`
+	Trace         bool                   `protobuf:"varint,4,opt,name=trace,proto3" json:"trace,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EvalRequest) Reset() {
+	*x = EvalRequest{}
+	mi := &file_eval_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EvalRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvalRequest) ProtoMessage() {}
+
+func (x *EvalRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eval_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvalRequest.ProtoReflect.Descriptor instead.
+func (*EvalRequest) Descriptor() ([]byte, []int) {
+	return file_eval_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *EvalRequest) GetExpr() string {
+	if x != nil {
+		return x.Expr
+	}
+	return ""
+}
+
+func (x *EvalRequest) GetSyntax() Syntax {
+	if x != nil {
+		return x.Syntax
+	}
+	return Syntax_SYNTAX_UNSPECIFIED
+}
+
+func (x *EvalRequest) GetCtx() *structpb.Struct {
+	if x != nil {
+		return x.Ctx
+	}
+	return nil
+}
+
+func (x *EvalRequest) GetTrace() bool {
+	if x != nil {
+		return x.Trace
+	}
+	return false
+}
+
+type EvalResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Result        *structpb.Value        `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	Trace         string                 `protobuf:"bytes,2,opt,name=trace,proto3" json:"trace,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EvalResponse) Reset() {
+	*x = EvalResponse{}
+	mi := &file_eval_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EvalResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvalResponse) ProtoMessage() {}
+
+func (x *EvalResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_eval_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvalResponse.ProtoReflect.Descriptor instead.
+func (*EvalResponse) Descriptor() ([]byte, []int) {
+	return file_eval_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EvalResponse) GetResult() *structpb.Value {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *EvalResponse) GetTrace() string {
+	if x != nil {
+		return x.Trace
+	}
+	return ""
+}
+
+func (x *EvalResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type EvalBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Expr          string                 `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
+	Syntax        Syntax                 `protobuf:"varint,2,opt,name=syntax,proto3,enum=eval.v1.Syntax" json:"syntax,omitempty"`
+	Ctxs          []*structpb.Struct     `protobuf:"bytes,3,rep,name=ctxs,proto3" json:"ctxs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EvalBatchRequest) Reset() {
+	*x = EvalBatchRequest{}
+	mi := &file_eval_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EvalBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvalBatchRequest) ProtoMessage() {}
+
+func (x *EvalBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_eval_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvalBatchRequest.ProtoReflect.Descriptor instead.
+func (*EvalBatchRequest) Descriptor() ([]byte, []int) {
+	return file_eval_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *EvalBatchRequest) GetExpr() string {
+	if x != nil {
+		return x.Expr
+	}
+	return ""
+}
+
+func (x *EvalBatchRequest) GetSyntax() Syntax {
+	if x != nil {
+		return x.Syntax
+	}
+	return Syntax_SYNTAX_UNSPECIFIED
+}
+
+func (x *EvalBatchRequest) GetCtxs() []*structpb.Struct {
+	if x != nil {
+		return x.Ctxs
+	}
+	return nil
+}
+
+type EvalBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*EvalResponse        `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EvalBatchResponse) Reset() {
+	*x = EvalBatchResponse{}
+	mi := &file_eval_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EvalBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvalBatchResponse) ProtoMessage() {}
+
+func (x *EvalBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_eval_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvalBatchResponse.ProtoReflect.Descriptor instead.
+func (*EvalBatchResponse) Descriptor() ([]byte, []int) {
+	return file_eval_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EvalBatchResponse) GetResults() []*EvalResponse {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+var File_eval_proto protoreflect.FileDescriptor
+
+const file_eval_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"eval.proto\x12\aeval.v1\x1a\x1cgoogle/protobuf/struct.proto\"M\n" +
+	"\x0eCompileRequest\x12\x12\n" +
+	"\x04expr\x18\x01 \x01(\tR\x04expr\x12'\n" +
+	"\x06syntax\x18\x02 \x01(\x0e2\x0f.eval.v1.SyntaxR\x06syntax\"P\n" +
+	"\x0fCompileResponse\x12\x1f\n" +
+	"\vreturn_type\x18\x01 \x01(\tR\n" +
+	"returnType\x12\x1c\n" +
+	"\tselectors\x18\x02 \x03(\tR\tselectors\"\x8b\x01\n" +
+	"\vEvalRequest\x12\x12\n" +
+	"\x04expr\x18\x01 \x01(\tR\x04expr\x12'\n" +
+	"\x06syntax\x18\x02 \x01(\x0e2\x0f.eval.v1.SyntaxR\x06syntax\x12)\n" +
+	"\x03ctx\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x03ctx\x12\x14\n" +
+	"\x05trace\x18\x04 \x01(\bR\x05trace\"j\n" +
+	"\fEvalResponse\x12.\n" +
+	"\x06result\x18\x01 \x01(\v2\x16.google.protobuf.ValueR\x06result\x12\x14\n" +
+	"\x05trace\x18\x02 \x01(\tR\x05trace\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"|\n" +
+	"\x10EvalBatchRequest\x12\x12\n" +
+	"\x04expr\x18\x01 \x01(\tR\x04expr\x12'\n" +
+	"\x06syntax\x18\x02 \x01(\x0e2\x0f.eval.v1.SyntaxR\x06syntax\x12+\n" +
+	"\x04ctxs\x18\x03 \x03(\v2\x17.google.protobuf.StructR\x04ctxs\"D\n" +
+	"\x11EvalBatchResponse\x12/\n" +
+	"\aresults\x18\x01 \x03(\v2\x15.eval.v1.EvalResponseR\aresults*e\n" +
+	"\x06Syntax\x12\x16\n" +
+	"\x12SYNTAX_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rSYNTAX_PREFIX\x10\x01\x12\x10\n" +
+	"\fSYNTAX_INFIX\x10\x02\x12\x0e\n" +
+	"\n" +
+	"SYNTAX_CEL\x10\x03\x12\x0e\n" +
+	"\n" +
+	"SYNTAX_SQL\x10\x042\x83\x02\n" +
+	"\vEvalService\x12<\n" +
+	"\aCompile\x12\x17.eval.v1.CompileRequest\x1a\x18.eval.v1.CompileResponse\x123\n" +
+	"\x04Eval\x12\x14.eval.v1.EvalRequest\x1a\x15.eval.v1.EvalResponse\x12B\n" +
+	"\tEvalBatch\x12\x19.eval.v1.EvalBatchRequest\x1a\x1a.eval.v1.EvalBatchResponse\x12=\n" +
+	"\n" +
+	"EvalStream\x12\x14.eval.v1.EvalRequest\x1a\x15.eval.v1.EvalResponse(\x010\x01B*Z(github.com/larry618/eval/evalgrpc/evalpbb\x06proto3"
+
+var (
+	file_eval_proto_rawDescOnce sync.Once
+	file_eval_proto_rawDescData []byte
+)
+
+func file_eval_proto_rawDescGZIP() []byte {
+	file_eval_proto_rawDescOnce.Do(func() {
+		file_eval_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_eval_proto_rawDesc), len(file_eval_proto_rawDesc)))
+	})
+	return file_eval_proto_rawDescData
+}
+
+var file_eval_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_eval_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_eval_proto_goTypes = []any{
+	(Syntax)(0),               // 0: eval.v1.Syntax
+	(*CompileRequest)(nil),    // 1: eval.v1.CompileRequest
+	(*CompileResponse)(nil),   // 2: eval.v1.CompileResponse
+	(*EvalRequest)(nil),       // 3: eval.v1.EvalRequest
+	(*EvalResponse)(nil),      // 4: eval.v1.EvalResponse
+	(*EvalBatchRequest)(nil),  // 5: eval.v1.EvalBatchRequest
+	(*EvalBatchResponse)(nil), // 6: eval.v1.EvalBatchResponse
+	(*structpb.Struct)(nil),   // 7: google.protobuf.Struct
+	(*structpb.Value)(nil),    // 8: google.protobuf.Value
+}
+var file_eval_proto_depIdxs = []int32{
+	0,  // 0: eval.v1.CompileRequest.syntax:type_name -> eval.v1.Syntax
+	0,  // 1: eval.v1.EvalRequest.syntax:type_name -> eval.v1.Syntax
+	7,  // 2: eval.v1.EvalRequest.ctx:type_name -> google.protobuf.Struct
+	8,  // 3: eval.v1.EvalResponse.result:type_name -> google.protobuf.Value
+	0,  // 4: eval.v1.EvalBatchRequest.syntax:type_name -> eval.v1.Syntax
+	7,  // 5: eval.v1.EvalBatchRequest.ctxs:type_name -> google.protobuf.Struct
+	4,  // 6: eval.v1.EvalBatchResponse.results:type_name -> eval.v1.EvalResponse
+	1,  // 7: eval.v1.EvalService.Compile:input_type -> eval.v1.CompileRequest
+	3,  // 8: eval.v1.EvalService.Eval:input_type -> eval.v1.EvalRequest
+	5,  // 9: eval.v1.EvalService.EvalBatch:input_type -> eval.v1.EvalBatchRequest
+	3,  // 10: eval.v1.EvalService.EvalStream:input_type -> eval.v1.EvalRequest
+	2,  // 11: eval.v1.EvalService.Compile:output_type -> eval.v1.CompileResponse
+	4,  // 12: eval.v1.EvalService.Eval:output_type -> eval.v1.EvalResponse
+	6,  // 13: eval.v1.EvalService.EvalBatch:output_type -> eval.v1.EvalBatchResponse
+	4,  // 14: eval.v1.EvalService.EvalStream:output_type -> eval.v1.EvalResponse
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_eval_proto_init() }
+func file_eval_proto_init() {
+	if File_eval_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_eval_proto_rawDesc), len(file_eval_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_eval_proto_goTypes,
+		DependencyIndexes: file_eval_proto_depIdxs,
+		EnumInfos:         file_eval_proto_enumTypes,
+		MessageInfos:      file_eval_proto_msgTypes,
+	}.Build()
+	File_eval_proto = out.File
+	file_eval_proto_goTypes = nil
+	file_eval_proto_depIdxs = nil
+}
@@ -0,0 +1,230 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: eval.proto
+
+package evalpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	EvalService_Compile_FullMethodName    = "/eval.v1.EvalService/Compile"
+	EvalService_Eval_FullMethodName       = "/eval.v1.EvalService/Eval"
+	EvalService_EvalBatch_FullMethodName  = "/eval.v1.EvalService/EvalBatch"
+	EvalService_EvalStream_FullMethodName = "/eval.v1.EvalService/EvalStream"
+)
+
+// EvalServiceClient is the client API for EvalService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EvalServiceClient interface {
+	Compile(ctx context.Context, in *CompileRequest, opts ...grpc.CallOption) (*CompileResponse, error)
+	Eval(ctx context.Context, in *EvalRequest, opts ...grpc.CallOption) (*EvalResponse, error)
+	EvalBatch(ctx context.Context, in *EvalBatchRequest, opts ...grpc.CallOption) (*EvalBatchResponse, error)
+	EvalStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[EvalRequest, EvalResponse], error)
+}
+
+type evalServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEvalServiceClient(cc grpc.ClientConnInterface) EvalServiceClient {
+	return &evalServiceClient{cc}
+}
+
+func (c *evalServiceClient) Compile(ctx context.Context, in *CompileRequest, opts ...grpc.CallOption) (*CompileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompileResponse)
+	err := c.cc.Invoke(ctx, EvalService_Compile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *evalServiceClient) Eval(ctx context.Context, in *EvalRequest, opts ...grpc.CallOption) (*EvalResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EvalResponse)
+	err := c.cc.Invoke(ctx, EvalService_Eval_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *evalServiceClient) EvalBatch(ctx context.Context, in *EvalBatchRequest, opts ...grpc.CallOption) (*EvalBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EvalBatchResponse)
+	err := c.cc.Invoke(ctx, EvalService_EvalBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *evalServiceClient) EvalStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[EvalRequest, EvalResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EvalService_ServiceDesc.Streams[0], EvalService_EvalStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[EvalRequest, EvalResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EvalService_EvalStreamClient = grpc.BidiStreamingClient[EvalRequest, EvalResponse]
+
+// EvalServiceServer is the server API for EvalService service.
+// All implementations must embed UnimplementedEvalServiceServer
+// for forward compatibility.
+type EvalServiceServer interface {
+	Compile(context.Context, *CompileRequest) (*CompileResponse, error)
+	Eval(context.Context, *EvalRequest) (*EvalResponse, error)
+	EvalBatch(context.Context, *EvalBatchRequest) (*EvalBatchResponse, error)
+	EvalStream(grpc.BidiStreamingServer[EvalRequest, EvalResponse]) error
+	mustEmbedUnimplementedEvalServiceServer()
+}
+
+// UnimplementedEvalServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEvalServiceServer struct{}
+
+func (UnimplementedEvalServiceServer) Compile(context.Context, *CompileRequest) (*CompileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Compile not implemented")
+}
+func (UnimplementedEvalServiceServer) Eval(context.Context, *EvalRequest) (*EvalResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Eval not implemented")
+}
+func (UnimplementedEvalServiceServer) EvalBatch(context.Context, *EvalBatchRequest) (*EvalBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EvalBatch not implemented")
+}
+func (UnimplementedEvalServiceServer) EvalStream(grpc.BidiStreamingServer[EvalRequest, EvalResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method EvalStream not implemented")
+}
+func (UnimplementedEvalServiceServer) mustEmbedUnimplementedEvalServiceServer() {}
+func (UnimplementedEvalServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeEvalServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EvalServiceServer will
+// result in compilation errors.
+type UnsafeEvalServiceServer interface {
+	mustEmbedUnimplementedEvalServiceServer()
+}
+
+func RegisterEvalServiceServer(s grpc.ServiceRegistrar, srv EvalServiceServer) {
+	// If the following call pancis, it indicates UnimplementedEvalServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EvalService_ServiceDesc, srv)
+}
+
+func _EvalService_Compile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EvalServiceServer).Compile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EvalService_Compile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EvalServiceServer).Compile(ctx, req.(*CompileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EvalService_Eval_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvalRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EvalServiceServer).Eval(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EvalService_Eval_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EvalServiceServer).Eval(ctx, req.(*EvalRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EvalService_EvalBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvalBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EvalServiceServer).EvalBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EvalService_EvalBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EvalServiceServer).EvalBatch(ctx, req.(*EvalBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EvalService_EvalStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EvalServiceServer).EvalStream(&grpc.GenericServerStream[EvalRequest, EvalResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EvalService_EvalStreamServer = grpc.BidiStreamingServer[EvalRequest, EvalResponse]
+
+// EvalService_ServiceDesc is the grpc.ServiceDesc for EvalService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EvalService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "eval.v1.EvalService",
+	HandlerType: (*EvalServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Compile",
+			Handler:    _EvalService_Compile_Handler,
+		},
+		{
+			MethodName: "Eval",
+			Handler:    _EvalService_Eval_Handler,
+		},
+		{
+			MethodName: "EvalBatch",
+			Handler:    _EvalService_EvalBatch_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "EvalStream",
+			Handler:       _EvalService_EvalStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "eval.proto",
+}
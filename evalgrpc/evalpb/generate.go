@@ -0,0 +1,5 @@
+// Package evalpb contains the protobuf messages and the gRPC stubs of the EvalService,
+// which are generated from eval.proto.
+package evalpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative eval.proto
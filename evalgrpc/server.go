@@ -0,0 +1,241 @@
+// Package evalgrpc implements the gRPC EvalService defined in evalpb/eval.proto,
+// so that the services written in the other languages can evaluate the same rules.
+//
+//	s := grpc.NewServer()
+//	evalpb.RegisterEvalServiceServer(s, evalgrpc.NewServer(evalgrpc.Config{CompileConfig: cc}))
+//
+// It's a separate module, so that the gRPC dependencies are not required by the eval package.
+package evalgrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/larry618/eval"
+	"github.com/larry618/eval/evalgrpc/evalpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const defaultCacheSize = 1024
+
+// Config configures the Server
+type Config struct {
+	// CompileConfig compiles the expressions, the unknown selectors are always allowed,
+	// as the selectors are provided by the ctx of the requests
+	CompileConfig *eval.CompileConfig
+
+	// CacheSize is the number of the compiled expressions cached by their syntax and source,
+	// it's 1024 by default, and the cache is disabled if it is negative
+	CacheSize int
+}
+
+var syntaxModes = map[evalpb.Syntax]eval.SyntaxMode{
+	evalpb.Syntax_SYNTAX_PREFIX: eval.PrefixSyntax,
+	evalpb.Syntax_SYNTAX_INFIX:  eval.InfixSyntax,
+	evalpb.Syntax_SYNTAX_CEL:    eval.CELSyntax,
+	evalpb.Syntax_SYNTAX_SQL:    eval.SQLSyntax,
+}
+
+// Server implements evalpb.EvalServiceServer, it's safe for concurrent use
+type Server struct {
+	evalpb.UnimplementedEvalServiceServer
+
+	cc    *eval.CompileConfig
+	cache *eval.CompileCache
+
+	// copies of cc for each syntax mode requested explicitly
+	configs map[evalpb.Syntax]*eval.CompileConfig
+}
+
+var _ evalpb.EvalServiceServer = (*Server)(nil)
+
+// NewServer creates a Server, the CompileConfig of conf is copied,
+// so the modification of it after NewServer doesn't take effect.
+func NewServer(conf Config) *Server {
+	s := &Server{cc: eval.CopyCompileConfig(conf.CompileConfig)}
+	eval.EnableStringSelectors(s.cc)
+
+	switch {
+	case conf.CacheSize == 0:
+		s.cache = eval.NewCompileCache(defaultCacheSize)
+	case conf.CacheSize > 0:
+		s.cache = eval.NewCompileCache(conf.CacheSize)
+	}
+	s.cc.CompileCache = s.cache
+
+	s.configs = make(map[evalpb.Syntax]*eval.CompileConfig, len(syntaxModes))
+	for syntax, mode := range syntaxModes {
+		cc := eval.CopyCompileConfig(s.cc)
+		cc.SyntaxMode = mode
+		s.configs[syntax] = cc
+	}
+	return s
+}
+
+// Compile checks whether the expression can be compiled
+func (s *Server) Compile(_ context.Context, req *evalpb.CompileRequest) (*evalpb.CompileResponse, error) {
+	expr, err := s.compile(req.GetExpr(), req.GetSyntax())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	plan := expr.SelectorPlan()
+	names := make(map[string]bool)
+	for _, name := range plan.Required {
+		names[name] = true
+	}
+	for _, group := range plan.Conditional {
+		for _, name := range group.Selectors {
+			names[name] = true
+		}
+	}
+	selectors := make([]string, 0, len(names))
+	for name := range names {
+		selectors = append(selectors, name)
+	}
+	sort.Strings(selectors)
+
+	return &evalpb.CompileResponse{
+		ReturnType: string(expr.ReturnType()),
+		Selectors:  selectors,
+	}, nil
+}
+
+// Eval evaluates the expression against the ctx,
+// the evaluation error is returned in the response
+func (s *Server) Eval(ctx context.Context, req *evalpb.EvalRequest) (*evalpb.EvalResponse, error) {
+	expr, err := s.compile(req.GetExpr(), req.GetSyntax())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.eval(ctx, expr, req.GetCtx(), req.GetTrace()), nil
+}
+
+// EvalBatch evaluates the expression against each of the ctxs
+func (s *Server) EvalBatch(ctx context.Context, req *evalpb.EvalBatchRequest) (*evalpb.EvalBatchResponse, error) {
+	expr, err := s.compile(req.GetExpr(), req.GetSyntax())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctxs := make([]*eval.Ctx, len(req.GetCtxs()))
+	resp := &evalpb.EvalBatchResponse{Results: make([]*evalpb.EvalResponse, len(ctxs))}
+	for i, vals := range req.GetCtxs() {
+		c, err := s.newCtx(ctx, vals)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		ctxs[i] = c
+	}
+
+	results, errs := expr.EvalBatch(ctxs)
+	for i := range results {
+		resp.Results[i] = response(results[i], errs[i])
+	}
+	return resp, nil
+}
+
+// EvalStream evaluates each of the requests in order until the client closes the stream,
+// the errors of the requests are returned in the responses
+func (s *Server) EvalStream(stream grpc.BidiStreamingServer[evalpb.EvalRequest, evalpb.EvalResponse]) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var resp *evalpb.EvalResponse
+		if expr, err := s.compile(req.GetExpr(), req.GetSyntax()); err != nil {
+			resp = &evalpb.EvalResponse{Error: err.Error()}
+		} else {
+			resp = s.eval(stream.Context(), expr, req.GetCtx(), req.GetTrace())
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) compile(exprStr string, syntax evalpb.Syntax) (*eval.Expr, error) {
+	if strings.TrimSpace(exprStr) == "" {
+		return nil, errors.New("expr is required")
+	}
+	cc := s.cc
+	if syntax != evalpb.Syntax_SYNTAX_UNSPECIFIED {
+		var exist bool
+		if cc, exist = s.configs[syntax]; !exist {
+			return nil, fmt.Errorf("unknown syntax: %v", syntax)
+		}
+	}
+	return eval.Compile(cc, exprStr)
+}
+
+func (s *Server) eval(ctx context.Context, expr *eval.Expr, vals *structpb.Struct, trace bool) *evalpb.EvalResponse {
+	c, err := s.newCtx(ctx, vals)
+	if err != nil {
+		return &evalpb.EvalResponse{Error: err.Error()}
+	}
+
+	var traceStr string
+	if trace {
+		_, t, _ := expr.EvalBoolWithTrace(c)
+		traceStr = t.String()
+	}
+	resp := response(expr.Eval(c))
+	resp.Trace = traceStr
+	return resp
+}
+
+// newCtx converts the struct to the selector values like the JSON objects,
+// e.g. the integral numbers are converted to int64
+func (s *Server) newCtx(ctx context.Context, vals *structpb.Struct) (*eval.Ctx, error) {
+	m := make(map[string]interface{}, len(vals.GetFields()))
+	if len(vals.GetFields()) != 0 {
+		bs, err := protojson.Marshal(vals)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ctx: %w", err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(bs))
+		dec.UseNumber()
+		var raw map[string]interface{}
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("invalid ctx: %w", err)
+		}
+		for k, v := range raw {
+			m[k] = eval.ConvertJSON(v)
+		}
+	}
+
+	c := eval.NewCtxWithMap(s.cc, m)
+	c.Ctx = ctx
+	return c, nil
+}
+
+// response converts the result to the protobuf value by its JSON encoding
+func response(res eval.Value, err error) *evalpb.EvalResponse {
+	if err != nil {
+		return &evalpb.EvalResponse{Error: err.Error()}
+	}
+	bs, err := json.Marshal(res)
+	if err != nil {
+		return &evalpb.EvalResponse{Error: fmt.Sprintf("unsupported result: %v", err)}
+	}
+	var v structpb.Value
+	if err := protojson.Unmarshal(bs, &v); err != nil {
+		return &evalpb.EvalResponse{Error: fmt.Sprintf("unsupported result: %v", err)}
+	}
+	return &evalpb.EvalResponse{Result: &v}
+}
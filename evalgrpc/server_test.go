@@ -0,0 +1,191 @@
+package evalgrpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval/evalgrpc/evalpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newClient(t *testing.T) evalpb.EvalServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	evalpb.RegisterEvalServiceServer(s, NewServer(Config{}))
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return evalpb.NewEvalServiceClient(conn)
+}
+
+func newStruct(t *testing.T, m map[string]interface{}) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestServer_Compile(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+
+	resp, err := client.Compile(ctx, &evalpb.CompileRequest{
+		Expr:   `age > 18 && (vip || country in ["US", "CA"])`,
+		Syntax: evalpb.Syntax_SYNTAX_INFIX,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetReturnType() != "bool" || strings.Join(resp.GetSelectors(), ",") != "age,country,vip" {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+
+	_, err = client.Compile(ctx, &evalpb.CompileRequest{Expr: `(> age`})
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "parentheses unmatched") {
+		t.Fatalf("want invalid argument error, got: %v", err)
+	}
+}
+
+func TestServer_Eval(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+
+	testCases := []struct {
+		expr   string
+		syntax evalpb.Syntax
+		vals   map[string]interface{}
+		trace  bool
+		want   interface{}
+		errMsg string
+	}{
+		{
+			expr: `(and (> age 18) (in "b" tags))`,
+			vals: map[string]interface{}{"age": 20, "tags": []interface{}{"a", "b"}},
+			want: true,
+		},
+		{
+			expr:   `status = 'active' AND score * 2 > 4`,
+			syntax: evalpb.Syntax_SYNTAX_SQL,
+			vals:   map[string]interface{}{"status": "active", "score": 2.5},
+			want:   true,
+		},
+		{
+			expr: `(+ age 1)`,
+			vals: map[string]interface{}{"age": 20},
+			want: float64(21),
+		},
+		{
+			expr:  `(or (> age 60) (< age 18))`,
+			vals:  map[string]interface{}{"age": 20},
+			trace: true,
+			want:  false,
+		},
+		{
+			expr:   `(> age 18)`,
+			vals:   map[string]interface{}{"age": "20"},
+			errMsg: "operator execution error",
+		},
+	}
+
+	for _, c := range testCases {
+		resp, err := client.Eval(ctx, &evalpb.EvalRequest{
+			Expr:   c.expr,
+			Syntax: c.syntax,
+			Ctx:    newStruct(t, c.vals),
+			Trace:  c.trace,
+		})
+		if err != nil {
+			t.Fatalf("expr: %s, unexpected error: %v", c.expr, err)
+		}
+		if c.errMsg != "" {
+			if !strings.Contains(resp.GetError(), c.errMsg) {
+				t.Fatalf("expr: %s, want error containing %q, got: %v", c.expr, c.errMsg, resp)
+			}
+			continue
+		}
+		if resp.GetError() != "" || resp.GetResult().AsInterface() != c.want {
+			t.Fatalf("expr: %s, want: %v, got: %v", c.expr, c.want, resp)
+		}
+		if c.trace != (resp.GetTrace() != "") {
+			t.Fatalf("expr: %s, unexpected trace: %q", c.expr, resp.GetTrace())
+		}
+	}
+}
+
+func TestServer_EvalBatch(t *testing.T) {
+	client := newClient(t)
+
+	resp, err := client.EvalBatch(context.Background(), &evalpb.EvalBatchRequest{
+		Expr: `(> age 18)`,
+		Ctxs: []*structpb.Struct{
+			newStruct(t, map[string]interface{}{"age": 20}),
+			newStruct(t, map[string]interface{}{"age": 10}),
+			newStruct(t, map[string]interface{}{}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := resp.GetResults()
+	if len(results) != 3 ||
+		results[0].GetResult().GetBoolValue() != true ||
+		results[1].GetResult().GetBoolValue() != false ||
+		!strings.Contains(results[2].GetError(), "age") {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+}
+
+func TestServer_EvalStream(t *testing.T) {
+	client := newClient(t)
+
+	stream, err := client.EvalStream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := []*evalpb.EvalRequest{
+		{Expr: `age >= 18`, Syntax: evalpb.Syntax_SYNTAX_INFIX, Ctx: newStruct(t, map[string]interface{}{"age": 18})},
+		{Expr: `(> age`},
+		{Expr: `name.startsWith("L")`, Syntax: evalpb.Syntax_SYNTAX_CEL},
+		{Expr: `age >= 18`, Syntax: evalpb.Syntax_SYNTAX_INFIX, Ctx: newStruct(t, map[string]interface{}{"age": 17})},
+	}
+	wants := []string{"true", "parentheses unmatched", "unknown token", "false"}
+
+	for i, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := resp.GetError()
+		if got == "" {
+			got = resp.GetResult().String()
+		}
+		if !strings.Contains(got, wants[i]) {
+			t.Fatalf("request %d, want: %s, got: %v", i, wants[i], resp)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,281 @@
+// Package evalhttp provides the http.Handlers checking and evaluating the expressions,
+// which can be embedded in the services managing the rules.
+//
+// Both of the endpoints accept a POST request of a JSON object:
+//
+//	{"expr": "(> age 18)", "syntax": "prefix", "ctx": {"age": 20}, "trace": true}
+//
+// The compile endpoint responds the return type and the selectors of the expression,
+// and the eval endpoint responds the result of the evaluation against the ctx:
+//
+//	{"result": true, "trace": "(> age 18) => true\n  ..."}
+//
+// The errors are responded as {"error": {"message": "...", "line": 1, "column": 4, "snippet": "..."}}
+// with the status 400 for the invalid requests and expressions, and 422 for the evaluation errors.
+package evalhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/larry618/eval"
+)
+
+const (
+	defaultCacheSize    = 1024
+	defaultMaxBodyBytes = 1 << 20
+)
+
+// ErrForbidden is returned by Config.Authorize to respond 403 instead of 401
+var ErrForbidden = errors.New("forbidden")
+
+// Config configures the handlers
+type Config struct {
+	// CompileConfig compiles the expressions, the unknown selectors are always allowed,
+	// as the selectors are provided by the ctx of the requests
+	CompileConfig *eval.CompileConfig
+
+	// Authorize authorizes the requests before they are handled, the request is
+	// rejected with 401 if it returns an error, or 403 if the error is ErrForbidden.
+	// All the requests are allowed if it is nil.
+	Authorize func(r *http.Request) error
+
+	// CacheSize is the number of the compiled expressions cached by their syntax and source,
+	// it's 1024 by default, and the cache is disabled if it is negative
+	CacheSize int
+
+	// MaxBodyBytes limits the size of the request bodies, it's 1MB by default
+	MaxBodyBytes int64
+}
+
+// Request is the body of the requests
+type Request struct {
+	Expr string `json:"expr"`
+	// Syntax of the expression: prefix, infix, cel or sql,
+	// the SyntaxMode of the CompileConfig by default
+	Syntax string                 `json:"syntax,omitempty"`
+	Ctx    map[string]interface{} `json:"ctx,omitempty"`
+	// Trace responds the trace explaining the result of the evaluation
+	Trace bool `json:"trace,omitempty"`
+}
+
+// CompileResponse is the body of the responses of the compile endpoint
+type CompileResponse struct {
+	ReturnType eval.Type `json:"returnType"`
+	Selectors  []string  `json:"selectors"`
+}
+
+// EvalResponse is the body of the responses of the eval endpoint
+type EvalResponse struct {
+	Result eval.Value `json:"result"`
+	Trace  string     `json:"trace,omitempty"`
+}
+
+// ErrorResponse is the body of the error responses
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+// Error describes why the request fails,
+// the position is set if the expression can't be compiled
+type Error struct {
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+var syntaxModes = map[string]eval.SyntaxMode{
+	"prefix": eval.PrefixSyntax,
+	"infix":  eval.InfixSyntax,
+	"cel":    eval.CELSyntax,
+	"sql":    eval.SQLSyntax,
+}
+
+// Server serves the compile and eval endpoints, it's safe for concurrent use
+type Server struct {
+	conf  Config
+	cc    *eval.CompileConfig
+	cache *eval.CompileCache
+	mux   *http.ServeMux
+
+	// copies of cc for each syntax mode requested explicitly, except the one of cc
+	configs map[eval.SyntaxMode]*eval.CompileConfig
+}
+
+// New creates a Server, the CompileConfig of conf is copied,
+// so the modification of it after New doesn't take effect.
+func New(conf Config) *Server {
+	s := &Server{
+		conf: conf,
+		cc:   eval.CopyCompileConfig(conf.CompileConfig),
+		mux:  http.NewServeMux(),
+	}
+	eval.EnableStringSelectors(s.cc)
+
+	if s.conf.MaxBodyBytes <= 0 {
+		s.conf.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	switch {
+	case conf.CacheSize == 0:
+		s.cache = eval.NewCompileCache(defaultCacheSize)
+	case conf.CacheSize > 0:
+		s.cache = eval.NewCompileCache(conf.CacheSize)
+	}
+	s.cc.CompileCache = s.cache
+
+	s.configs = make(map[eval.SyntaxMode]*eval.CompileConfig, len(syntaxModes))
+	for _, mode := range syntaxModes {
+		// the expressions are cached by the configs, so the default syntax mode shares the default config
+		if mode == s.cc.SyntaxMode {
+			s.configs[mode] = s.cc
+			continue
+		}
+		cc := eval.CopyCompileConfig(s.cc)
+		cc.SyntaxMode = mode
+		s.configs[mode] = cc
+	}
+
+	s.mux.Handle("/compile", s.CompileHandler())
+	s.mux.Handle("/eval", s.EvalHandler())
+	return s
+}
+
+// ServeHTTP serves the compile endpoint at /compile and the eval endpoint at /eval,
+// it can be mounted to a prefix with http.StripPrefix.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// CompileHandler returns the handler checking whether the expression can be compiled
+func (s *Server) CompileHandler() http.Handler {
+	return s.handler(func(req *Request, expr *eval.Expr) (interface{}, int) {
+		plan := expr.SelectorPlan()
+		names := make(map[string]bool)
+		for _, name := range plan.Required {
+			names[name] = true
+		}
+		for _, group := range plan.Conditional {
+			for _, name := range group.Selectors {
+				names[name] = true
+			}
+		}
+		selectors := make([]string, 0, len(names))
+		for name := range names {
+			selectors = append(selectors, name)
+		}
+		sort.Strings(selectors)
+
+		return CompileResponse{ReturnType: expr.ReturnType(), Selectors: selectors}, http.StatusOK
+	})
+}
+
+// EvalHandler returns the handler evaluating the expression against the ctx of the request
+func (s *Server) EvalHandler() http.Handler {
+	return s.handler(func(req *Request, expr *eval.Expr) (interface{}, int) {
+		vals := make(map[string]interface{}, len(req.Ctx))
+		for k, v := range req.Ctx {
+			vals[k] = eval.ConvertJSON(v)
+		}
+		ctx := eval.NewCtxWithMap(s.cc, vals)
+
+		var resp EvalResponse
+		if req.Trace {
+			_, trace, _ := expr.EvalBoolWithTrace(ctx)
+			resp.Trace = trace.String()
+		}
+		res, err := expr.Eval(ctx)
+		if err != nil {
+			return ErrorResponse{Error: Error{Message: err.Error()}}, http.StatusUnprocessableEntity
+		}
+		resp.Result = res
+		return resp, http.StatusOK
+	})
+}
+
+func (s *Server) handler(fn func(req *Request, expr *eval.Expr) (interface{}, int)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed", r.Method))
+			return
+		}
+		if s.conf.Authorize != nil {
+			if err := s.conf.Authorize(r); err != nil {
+				status := http.StatusUnauthorized
+				if errors.Is(err, ErrForbidden) {
+					status = http.StatusForbidden
+				}
+				writeError(w, status, err.Error())
+				return
+			}
+		}
+
+		req, err := s.decode(w, r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		expr, err := s.compile(req)
+		if err != nil {
+			resp := ErrorResponse{Error: Error{Message: err.Error()}}
+			var ce *eval.CompileError
+			if errors.As(err, &ce) {
+				resp.Error = Error{Message: ce.Err.Error(), Line: ce.Line, Column: ce.Column, Snippet: ce.Snippet}
+			}
+			writeJSON(w, http.StatusBadRequest, resp)
+			return
+		}
+
+		resp, status := fn(req, expr)
+		writeJSON(w, status, resp)
+	})
+}
+
+func (s *Server) decode(w http.ResponseWriter, r *http.Request) (*Request, error) {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.conf.MaxBodyBytes))
+	dec.UseNumber()
+	var req Request
+	if err := dec.Decode(&req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if strings.TrimSpace(req.Expr) == "" {
+		return nil, errors.New("invalid request: expr is required")
+	}
+	if _, exist := syntaxModes[req.Syntax]; !exist && req.Syntax != "" {
+		return nil, fmt.Errorf("invalid request: unknown syntax %q", req.Syntax)
+	}
+	return &req, nil
+}
+
+func (s *Server) compile(req *Request) (*eval.Expr, error) {
+	cc := s.cc
+	if mode, exist := syntaxModes[req.Syntax]; exist {
+		cc = s.configs[mode]
+	}
+	return eval.Compile(cc, req.Expr)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, ErrorResponse{Error: Error{Message: msg}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		status = http.StatusInternalServerError
+		buf.Reset()
+		_ = json.NewEncoder(&buf).Encode(ErrorResponse{Error: Error{Message: err.Error()}})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,176 @@
+package evalhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval"
+)
+
+func TestServer(t *testing.T) {
+	s := New(Config{
+		Authorize: func(r *http.Request) error {
+			switch r.Header.Get("Authorization") {
+			case "":
+				return errors.New("missing token")
+			case "Bearer guest":
+				return ErrForbidden
+			}
+			return nil
+		},
+		CacheSize: 2,
+	})
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		want   string
+	}{
+		{
+			name:   "compile",
+			path:   "/compile",
+			body:   `{"expr": "(and (> age 18) (in country (\"US\" \"CA\")) (= tier \"gold\"))"}`,
+			status: http.StatusOK,
+			want:   `{"returnType":"bool","selectors":["age","country","tier"]}`,
+		},
+		{
+			name:   "compile error",
+			path:   "/compile",
+			body:   `{"expr": "age > 18 &&", "syntax": "infix"}`,
+			status: http.StatusBadRequest,
+			want:   `"line":1,"column":11`,
+		},
+		{
+			name:   "eval",
+			path:   "/eval",
+			body:   `{"expr": "age > 18 && \"b\" in tags", "syntax": "infix", "ctx": {"age": 20, "tags": ["a", "b"]}}`,
+			status: http.StatusOK,
+			want:   `{"result":true}`,
+		},
+		{
+			name:   "eval sql",
+			path:   "/eval",
+			body:   `{"expr": "score * 2 BETWEEN 1 AND 10", "syntax": "sql", "ctx": {"score": 2.5}}`,
+			status: http.StatusOK,
+			want:   `{"result":true}`,
+		},
+		{
+			name:   "eval with trace",
+			path:   "/eval",
+			body:   `{"expr": "(or (> age 60) (< age 18))", "ctx": {"age": 20}, "trace": true}`,
+			status: http.StatusOK,
+			want:   `{"result":false,"trace":"(or (> age 60) (< age 18)) => false\n`,
+		},
+		{
+			name:   "eval error",
+			path:   "/eval",
+			body:   `{"expr": "(> age 18)", "ctx": {"age": "20"}}`,
+			status: http.StatusUnprocessableEntity,
+			want:   `operator execution error`,
+		},
+		{
+			name:   "invalid request",
+			path:   "/eval",
+			body:   `{"expr": "(> age 18)", "syntax": "lisp"}`,
+			status: http.StatusBadRequest,
+			want:   `unknown syntax \"lisp\"`,
+		},
+		{
+			name:   "empty expr",
+			path:   "/compile",
+			body:   `{}`,
+			status: http.StatusBadRequest,
+			want:   `expr is required`,
+		},
+		{
+			name:   "unauthorized",
+			path:   "/eval",
+			token:  "-",
+			body:   `{"expr": "true"}`,
+			status: http.StatusUnauthorized,
+			want:   `missing token`,
+		},
+		{
+			name:   "forbidden",
+			path:   "/eval",
+			token:  "Bearer guest",
+			body:   `{"expr": "true"}`,
+			status: http.StatusForbidden,
+			want:   `forbidden`,
+		},
+		{
+			name:   "method not allowed",
+			method: http.MethodGet,
+			path:   "/eval",
+			status: http.StatusMethodNotAllowed,
+			want:   `method GET is not allowed`,
+		},
+		{
+			name:   "not found",
+			path:   "/unknown",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			method := c.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			r := httptest.NewRequest(method, c.path, strings.NewReader(c.body))
+			switch c.token {
+			case "":
+				r.Header.Set("Authorization", "Bearer admin")
+			case "-":
+			default:
+				r.Header.Set("Authorization", c.token)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+
+			if w.Code != c.status {
+				t.Fatalf("want status: %d, got: %d, body: %s", c.status, w.Code, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), c.want) {
+				t.Fatalf("want body containing: %s, got: %s", c.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestServer_Cache(t *testing.T) {
+	s := New(Config{CompileConfig: eval.NewCompileConfig(eval.EnableInfixSyntax), CacheSize: 2})
+
+	for _, body := range []string{
+		`{"expr": "a > 1", "syntax": "infix", "ctx": {"a": 2}}`,
+		`{"expr": "a > 1", "syntax": "infix", "ctx": {"a": 0}}`,
+		`{"expr": "(> a 1)", "syntax": "prefix", "ctx": {"a": 2}}`,
+		`{"expr": "(> a 1)", "syntax": "prefix", "ctx": {"a": 0}}`,
+		`{"expr": "a > 2", "ctx": {"a": 2}}`,
+	} {
+		w := httptest.NewRecorder()
+		s.EvalHandler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status: %d, body: %s", w.Code, w.Body)
+		}
+	}
+
+	if s.cache.Len() != 2 {
+		t.Fatalf("want 2 cached expressions, got: %d", s.cache.Len())
+	}
+	cached, err := s.compile(&Request{Expr: "a > 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr, _ := s.compile(&Request{Expr: "a > 2", Syntax: "infix"}); expr != cached {
+		t.Fatalf("the most recently used expression should be cached")
+	}
+}
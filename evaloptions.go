@@ -0,0 +1,164 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// EvalOptions are the runtime policies of one evaluation, so that the callers sharing
+// a compiled expression can evaluate it differently. The zero value keeps the policies
+// decided by the CompileConfig of the expression.
+type EvalOptions struct {
+	// Timeout aborts the evaluation with context.DeadlineExceeded, it's not limited if it isn't positive.
+	// It works together with the Ctx.Ctx, whichever expires first.
+	Timeout time.Duration
+	// MaxSteps overrides CompileConfig.MaxSteps if it is positive
+	MaxSteps int
+	// Hook observes the nodes of this evaluation, along with CompileConfig.EvalHook
+	Hook EvalHook
+	// DebugWriter prints the result of each evaluated node, it works without the debug mode
+	DebugWriter io.Writer
+	// MemoizeSelectors reads each selector once in this evaluation,
+	// and DisableMemoization overrides the MemoizeSelectors option of the expression
+	MemoizeSelectors   bool
+	DisableMemoization bool
+	// ParallelSelectors fetches the required selectors, see SelectorPlan, concurrently by at most
+	// ParallelSelectors goroutines before the operators are executed, which reduces the latency
+	// if the selectors call the remote services. The Selector of the Ctx should be safe for concurrent use.
+	// The fetched values are memoized in this evaluation, and the failed ones are got again when evaluated.
+	ParallelSelectors int
+}
+
+// EvalWithOptions evaluates the expression like Eval with the runtime policies of opts,
+// the expression is not changed. The hooks, the step limit and the timeout make the evaluation
+// slower, as they are not supported by the ClosureBackend.
+func (e *Expr) EvalWithOptions(ctx *Ctx, opts EvalOptions) (Value, error) {
+	c := *e
+	if opts.MaxSteps > 0 {
+		c.maxSteps = opts.MaxSteps
+	}
+	c.parallelSelectors = opts.ParallelSelectors
+	switch {
+	case opts.DisableMemoization:
+		c.memoizeSelectors = false
+	case opts.MemoizeSelectors:
+		c.memoizeSelectors = true
+	}
+	for _, h := range []EvalHook{opts.Hook, debugWriterHook(opts.DebugWriter)} {
+		switch {
+		case h == nil:
+		case c.hook == nil:
+			c.hook = h
+		default:
+			c.hook = multiHook{c.hook, h}
+		}
+	}
+	if c.maxSteps > 0 || c.hook != nil || opts.Timeout > 0 {
+		c.closure = nil
+	}
+	if c.maxSteps > 0 || c.hook != nil || c.memoizeSelectors || opts.Timeout > 0 {
+		c.predicate = nil
+	}
+
+	if opts.Timeout > 0 {
+		parent := context.Background()
+		if ctx != nil && ctx.Ctx != nil {
+			parent = ctx.Ctx
+		}
+		timeout, cancel := context.WithTimeout(parent, opts.Timeout)
+		defer cancel()
+
+		wrapped := &Ctx{Ctx: timeout}
+		if ctx != nil {
+			wrapped.Selector = ctx.Selector
+		}
+		ctx = wrapped
+	}
+	return c.evalWithStacks(ctx, nil, nil)
+}
+
+// fetchParallel gets the required selectors concurrently by the bounded goroutines,
+// and returns the Ctx which memoizes the values got successfully
+func (e *Expr) fetchParallel(ctx *Ctx) *Ctx {
+	memo := &memoSelector{Selector: ctx.Selector}
+	res := &Ctx{Selector: memo, Ctx: ctx.Ctx}
+	size := len(e.requiredSelectors)
+	if size == 0 {
+		return res
+	}
+
+	entries := make([]memoEntry, size)
+	errs := make([]error, size)
+	fetch := func(i int) {
+		entries[i] = e.requiredSelectors[i]
+		entries[i].val, errs[i] = ctx.Get(entries[i].selKey, entries[i].strKey)
+	}
+
+	workers := min(e.parallelSelectors, size)
+	if workers == 1 {
+		for i := 0; i < size; i++ {
+			fetch(i)
+		}
+	} else {
+		var wg sync.WaitGroup
+		indexes := make(chan int, size)
+		for i := 0; i < size; i++ {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Add(workers)
+		for w := 0; w < workers; w++ {
+			go func() {
+				defer wg.Done()
+				for i := range indexes {
+					fetch(i)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	for i, entry := range entries {
+		if errs[i] == nil {
+			memo.entries = append(memo.entries, entry)
+		}
+	}
+	return res
+}
+
+// multiHook reports the nodes to all the hooks in order
+type multiHook []EvalHook
+
+func (m multiHook) BeforeNode(n HookNode) {
+	for _, h := range m {
+		h.BeforeNode(n)
+	}
+}
+
+func (m multiHook) AfterNode(n HookNode, res Value, err error, elapsed time.Duration) {
+	for _, h := range m {
+		h.AfterNode(n, res, err, elapsed)
+	}
+}
+
+// writerHook prints the evaluated nodes, it's the EvalOptions.DebugWriter
+type writerHook struct {
+	w io.Writer
+}
+
+func debugWriterHook(w io.Writer) EvalHook {
+	if w == nil {
+		return nil
+	}
+	return writerHook{w: w}
+}
+
+func (h writerHook) BeforeNode(HookNode) {}
+
+func (h writerHook) AfterNode(n HookNode, res Value, err error, elapsed time.Duration) {
+	fmt.Fprintf(h.w, "eval node, idx: %d, node: %v, res: %s, err: %v, elapsed: %v\n",
+		n.Index, n.Value, decompileValue(res), err, elapsed)
+}
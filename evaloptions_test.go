@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExpr_EvalWithOptions(t *testing.T) {
+	vals := map[string]interface{}{"age": 20, "country": "US"}
+	const exprStr = `(and (> age 18) (or (= country "US") (= country "CA")) (< age 60))`
+
+	cc := NewCompileConfig(RegisterSelKeys(vals), Optimizations(false))
+	expr, err := Compile(cc, exprStr)
+	assertNil(t, err)
+	ctx := NewCtxWithMap(cc, vals)
+
+	// the zero options are the same as Eval
+	res, err := expr.EvalWithOptions(ctx, EvalOptions{})
+	assertNil(t, err)
+	assertEquals(t, res, true)
+
+	// step limit per call
+	_, err = expr.EvalWithOptions(ctx, EvalOptions{MaxSteps: 3})
+	assertEquals(t, errors.Is(err, ErrStepLimitExceeded), true)
+	assertErrStrContains(t, err, "max steps: 3")
+	// the expression is not changed
+	res, err = expr.Eval(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, true)
+
+	// hook and debug writer per call
+	hook := &recordingHook{}
+	var sb strings.Builder
+	res, err = expr.EvalWithOptions(ctx, EvalOptions{Hook: hook, DebugWriter: &sb})
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	assertEquals(t, hook.events[0], "before 0 operator and")
+	assertEquals(t, hook.events[len(hook.events)-1], "after 0 true")
+	assertEquals(t, strings.Count(sb.String(), "eval node"), len(hook.events)/2)
+	assertEquals(t, strings.Contains(sb.String(), `idx: 0, node: and, res: true, err: <nil>`), true)
+
+	// memoization per call
+	counting := &countingSelector{MapSelector: NewMapSelector(vals)}
+	_, err = expr.EvalWithOptions(&Ctx{Selector: counting}, EvalOptions{})
+	assertNil(t, err)
+	assertEquals(t, counting.cnt, 3)
+	counting.cnt = 0
+	_, err = expr.EvalWithOptions(&Ctx{Selector: counting}, EvalOptions{MemoizeSelectors: true})
+	assertNil(t, err)
+	assertEquals(t, counting.cnt, 2)
+}
+
+func TestExpr_EvalWithOptions_Memoization(t *testing.T) {
+	vals := map[string]interface{}{"age": 20}
+	cc := NewCompileConfig(RegisterSelKeys(vals), EnableSelectorMemoization)
+	expr, err := Compile(cc, `(and (> age 18) (< age 60) (!= age 30))`)
+	assertNil(t, err)
+
+	counting := &countingSelector{MapSelector: NewMapSelector(vals)}
+	_, err = expr.EvalWithOptions(&Ctx{Selector: counting}, EvalOptions{})
+	assertNil(t, err)
+	assertEquals(t, counting.cnt, 1)
+
+	counting.cnt = 0
+	_, err = expr.EvalWithOptions(&Ctx{Selector: counting}, EvalOptions{DisableMemoization: true})
+	assertNil(t, err)
+	assertEquals(t, counting.cnt, 3)
+}
+
+func TestExpr_EvalWithOptions_Timeout(t *testing.T) {
+	vals := map[string]interface{}{"v1": 1, "v2": 2}
+	cc := NewCompileConfig(EnableStringSelectors, Optimizations(false), func(c *CompileConfig) {
+		c.Backend = ClosureBackend
+	})
+	expr, err := Compile(cc, `(+ v1 v2 v1 v2 v1 v2 v1 v2 v1 v2 v1 v2 v1 v2 v1 v2 v1 v2 v1 v2)`)
+	assertNil(t, err)
+	sel := slowSelector{MapSelector: NewMapSelector(vals), delay: time.Millisecond}
+
+	res, err := expr.EvalWithOptions(&Ctx{Selector: sel}, EvalOptions{Timeout: time.Second})
+	assertNil(t, err)
+	assertEquals(t, res, int64(30))
+
+	_, err = expr.EvalWithOptions(&Ctx{Selector: sel}, EvalOptions{Timeout: 5 * time.Millisecond})
+	assertEquals(t, err, context.DeadlineExceeded)
+
+	// the earlier deadline of the Ctx.Ctx wins
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = expr.EvalWithOptions(&Ctx{Selector: sel, Ctx: cancelled}, EvalOptions{Timeout: time.Second})
+	assertEquals(t, err, context.Canceled)
+}
+
+// concurrentSelector records the gets and the max number of the concurrent gets
+type concurrentSelector struct {
+	MapSelector
+	delay time.Duration
+
+	mu       sync.Mutex
+	inflight int
+	peak     int
+	gets     map[string]int
+}
+
+func (s *concurrentSelector) Get(key SelectorKey, strKey string) (Value, error) {
+	s.mu.Lock()
+	s.inflight++
+	s.peak = max(s.peak, s.inflight)
+	s.gets[strKey]++
+	s.mu.Unlock()
+
+	time.Sleep(s.delay)
+
+	s.mu.Lock()
+	s.inflight--
+	s.mu.Unlock()
+	return s.MapSelector.Get(key, strKey)
+}
+
+func TestExpr_EvalWithOptions_ParallelSelectors(t *testing.T) {
+	vals := map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4, "vip": true}
+	cc := NewCompileConfig(EnableStringSelectors)
+	expr, err := Compile(cc, `(if (> (+ a b c d a) 20) (= bonus 5) vip)`)
+	assertNil(t, err)
+
+	testCases := []struct {
+		workers int
+		peak    int
+	}{
+		{workers: 0, peak: 1},
+		{workers: 1, peak: 1},
+		{workers: 2, peak: 2},
+		{workers: 8, peak: 4},
+	}
+	for _, c := range testCases {
+		sel := &concurrentSelector{MapSelector: NewMapSelector(vals), delay: 5 * time.Millisecond, gets: make(map[string]int)}
+		res, err := expr.EvalWithOptions(&Ctx{Selector: sel}, EvalOptions{ParallelSelectors: c.workers})
+		assertNil(t, err, c.workers)
+		assertEquals(t, res, true, c.workers)
+		assertEquals(t, sel.peak, c.peak, c.workers)
+
+		// the required selectors are memoized, and the conditional ones are got lazily
+		want := map[string]int{"a": 1, "b": 1, "c": 1, "d": 1, "vip": 1}
+		if c.workers == 0 {
+			want["a"] = 2
+		}
+		assertEquals(t, sel.gets, want, c.workers)
+	}
+
+	// the failed selectors are got again when they are evaluated
+	sel := &concurrentSelector{MapSelector: NewMapSelector(map[string]interface{}{"a": 1}), gets: make(map[string]int)}
+	_, err = expr.EvalWithOptions(&Ctx{Selector: sel}, EvalOptions{ParallelSelectors: 4})
+	assertErrStrContains(t, err, "selectorKey not exist b")
+	assertEquals(t, sel.gets, map[string]int{"a": 1, "b": 2, "c": 1, "d": 1})
+}
@@ -0,0 +1,170 @@
+// Package evalotel traces the evaluations of the expressions with OpenTelemetry.
+//
+//	tracer := evalotel.New(evalotel.Config{SlowSelector: 10 * time.Millisecond})
+//	cc := eval.NewCompileConfig(tracer.CompileOption())
+//	expr, err := eval.Compile(cc, `(and (> age 18) (= country "US"))`)
+//	res, err := tracer.Eval(ctx, expr, eval.NewCtxWithMap(cc, vals))
+//
+// Each evaluation is recorded as a span with the hash of the expression and the type of the result.
+// The expressions compiled with the CompileOption report the number of the short circuits,
+// and the selectors slower than Config.SlowSelector are recorded as the child spans.
+// Note that the CompileOption installs an eval.EvalHook, which makes the evaluation much slower.
+//
+// It's a separate module, so that the OpenTelemetry dependencies are not required by the eval package.
+package evalotel
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/larry618/eval"
+	"github.com/larry618/eval/ast"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/larry618/eval/evalotel"
+
+// the attributes of the spans
+const (
+	ExprHashKey     = attribute.Key("eval.expr.hash")
+	ResultTypeKey   = attribute.Key("eval.result.type")
+	ShortCircuitKey = attribute.Key("eval.short_circuit.count")
+	SelectorKey     = attribute.Key("eval.selector")
+)
+
+// Config configures the Tracer
+type Config struct {
+	// TracerProvider creates the tracer, the global one is used if it is nil
+	TracerProvider trace.TracerProvider
+
+	// SlowSelector is the duration above which a selector is recorded as a child span
+	// of the evaluation, no selector is recorded if it is not positive
+	SlowSelector time.Duration
+}
+
+// Tracer evaluates the expressions with the spans, it's safe for concurrent use
+type Tracer struct {
+	tracer       trace.Tracer
+	slowSelector time.Duration
+
+	// hashes of the expressions
+	hashes sync.Map
+}
+
+// New creates a Tracer
+func New(conf Config) *Tracer {
+	tp := conf.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{
+		tracer:       tp.Tracer(instrumentationName),
+		slowSelector: conf.SlowSelector,
+	}
+}
+
+// CompileOption installs the hook counting the short circuits and recording the slow selectors
+func (t *Tracer) CompileOption() eval.CompileOption {
+	return func(c *eval.CompileConfig) {
+		c.EvalHook = hook{t}
+	}
+}
+
+// evalState is the state of an evaluation shared with the hook by the context
+type evalState struct {
+	ctx           context.Context
+	shortCircuits int
+}
+
+type stateKey struct{}
+
+// Eval evaluates the expression in a span, which is a child of the span in ctx.
+// The Ctx.Ctx of the evaluation is replaced by the context of the span.
+func (t *Tracer) Eval(ctx context.Context, expr *eval.Expr, evalCtx *eval.Ctx) (eval.Value, error) {
+	ctx, span := t.tracer.Start(ctx, "eval.Eval", trace.WithAttributes(ExprHashKey.String(t.hash(expr))))
+	defer span.End()
+
+	state := &evalState{ctx: ctx}
+	c := &eval.Ctx{Ctx: context.WithValue(ctx, stateKey{}, state)}
+	if evalCtx != nil {
+		c.Selector = evalCtx.Selector
+	}
+
+	res, err := expr.Eval(c)
+	span.SetAttributes(ShortCircuitKey.Int(state.shortCircuits))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(ResultTypeKey.String(fmt.Sprintf("%T", res)))
+	return res, nil
+}
+
+// EvalBool evaluates the expression like Eval, and converts the result to bool
+func (t *Tracer) EvalBool(ctx context.Context, expr *eval.Expr, evalCtx *eval.Ctx) (bool, error) {
+	res, err := t.Eval(ctx, expr, evalCtx)
+	if err != nil {
+		return false, err
+	}
+	b, ok := res.(bool)
+	if !ok {
+		return false, fmt.Errorf("invalid result type: %v, expected: bool, got: %T", res, res)
+	}
+	return b, nil
+}
+
+// hash returns the hash of the decompiled expression, which is the same for the same expression
+// compiled by the different processes
+func (t *Tracer) hash(expr *eval.Expr) string {
+	if h, exist := t.hashes.Load(expr); exist {
+		return h.(string)
+	}
+	f := fnv.New64a()
+	_, _ = f.Write([]byte(expr.Decompile()))
+	h := strconv.FormatUint(f.Sum64(), 16)
+	t.hashes.Store(expr, h)
+	return h
+}
+
+// hook counts the short circuits and records the slow selectors of the evaluations started by Eval
+type hook struct {
+	t *Tracer
+}
+
+func (h hook) BeforeNode(eval.HookNode) {}
+
+func (h hook) AfterNode(n eval.HookNode, _ eval.Value, err error, elapsed time.Duration) {
+	if n.Ctx == nil || n.Ctx.Ctx == nil {
+		return
+	}
+	state, ok := n.Ctx.Ctx.Value(stateKey{}).(*evalState)
+	if !ok {
+		return
+	}
+
+	if n.ShortCircuit {
+		state.shortCircuits++
+	}
+	if n.Kind != ast.Selector || h.t.slowSelector <= 0 || elapsed < h.t.slowSelector {
+		return
+	}
+
+	end := time.Now()
+	name := fmt.Sprint(n.Value)
+	_, span := h.t.tracer.Start(state.ctx, "eval.selector "+name,
+		trace.WithTimestamp(end.Add(-elapsed)),
+		trace.WithAttributes(SelectorKey.String(name)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
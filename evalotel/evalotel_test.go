@@ -0,0 +1,104 @@
+package evalotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/larry618/eval"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := New(Config{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		SlowSelector:   5 * time.Millisecond,
+	})
+
+	cc := eval.NewCompileConfig(eval.EnableStringSelectors, tracer.CompileOption())
+	expr, err := eval.Compile(cc, `(or (= country "US") (and (> age 18) (= tier "gold")))`)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	sel := eval.SelectorFunc(func(name string) (eval.Value, error) {
+		switch name {
+		case "country":
+			time.Sleep(10 * time.Millisecond)
+			return "CA", nil
+		case "age":
+			return int64(16), nil
+		}
+		return nil, errors.New("unexpected selector: " + name)
+	})
+	res, err := tracer.EvalBool(context.Background(), expr, eval.NewCtxWithStringSelector(sel))
+	if err != nil || res {
+		t.Fatalf("unexpected result: %v, error: %v", res, err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("unexpected spans count: %d", len(spans))
+	}
+	selSpan, evalSpan := spans[0], spans[1]
+	if selSpan.Name() != "eval.selector country" || selSpan.Parent().SpanID() != evalSpan.SpanContext().SpanID() {
+		t.Fatalf("unexpected selector span: %s", selSpan.Name())
+	}
+	if d := selSpan.EndTime().Sub(selSpan.StartTime()); d < 10*time.Millisecond {
+		t.Fatalf("unexpected selector span duration: %v", d)
+	}
+
+	attrs := attributeMap(evalSpan.Attributes())
+	if evalSpan.Name() != "eval.Eval" ||
+		attrs[ExprHashKey].AsString() == "" ||
+		attrs[ResultTypeKey].AsString() != "bool" ||
+		attrs[ShortCircuitKey].AsInt64() != 1 {
+		t.Fatalf("unexpected eval span: %s, attributes: %v", evalSpan.Name(), attrs)
+	}
+
+	// the hash is decided by the expression
+	same, err := eval.Compile(eval.NewCompileConfig(eval.EnableStringSelectors), `(or (= country "US") (and (> age 18) (= tier "gold")))`)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	if tracer.hash(same) != attrs[ExprHashKey].AsString() {
+		t.Fatalf("unexpected hash: %s", tracer.hash(same))
+	}
+}
+
+func TestTracer_Error(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := New(Config{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))})
+
+	// the expressions compiled without the hook are traced without the short circuits
+	cc := eval.NewCompileConfig(eval.EnableStringSelectors)
+	expr, err := eval.Compile(cc, `(> age 18)`)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	_, err = tracer.Eval(context.Background(), expr, eval.NewCtxWithMap(cc, map[string]interface{}{"age": "x"}))
+	if err == nil {
+		t.Fatalf("error expected")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+	if _, exist := attributeMap(spans[0].Attributes())[ResultTypeKey]; exist {
+		t.Fatalf("unexpected result type")
+	}
+}
+
+func attributeMap(attrs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value, len(attrs))
+	for _, kv := range attrs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
@@ -0,0 +1,172 @@
+// Package evalprom records the Prometheus metrics of the compilations and evaluations
+// of the expressions, which are labeled by the names of the expressions given by the callers.
+//
+//	m, err := evalprom.New(evalprom.Config{})
+//	cc := eval.NewCompileConfig(m.CompileOption())
+//	expr, err := m.Compile("is_adult", cc, `(> age 18)`)
+//	res, err := m.Eval("is_adult", expr, eval.NewCtxWithMap(cc, vals))
+//
+// The expressions compiled with the CompileOption also record the operator calls and errors,
+// and the short circuits, by an eval.EvalHook, which makes the evaluation much slower.
+//
+// It's a separate module, so that the Prometheus dependencies are not required by the eval package.
+package evalprom
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/larry618/eval"
+	"github.com/larry618/eval/ast"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	labelExpr     = "expr"
+	labelOperator = "operator"
+)
+
+// Config configures the Metrics
+type Config struct {
+	// Registerer registers the metrics, prometheus.DefaultRegisterer is used if it is nil
+	Registerer prometheus.Registerer
+	// Namespace of the metrics, it's "eval" by default
+	Namespace string
+	// Buckets of the evaluation latency histogram in seconds, prometheus.DefBuckets by default
+	Buckets []float64
+}
+
+// Metrics records the metrics, it's safe for concurrent use
+type Metrics struct {
+	compiles       *prometheus.CounterVec
+	compileErrors  *prometheus.CounterVec
+	evals          *prometheus.CounterVec
+	evalErrors     *prometheus.CounterVec
+	evalDuration   *prometheus.HistogramVec
+	shortCircuits  *prometheus.CounterVec
+	operatorCalls  *prometheus.CounterVec
+	operatorErrors *prometheus.CounterVec
+}
+
+// New creates the Metrics and registers them
+func New(conf Config) (*Metrics, error) {
+	if conf.Registerer == nil {
+		conf.Registerer = prometheus.DefaultRegisterer
+	}
+	if conf.Namespace == "" {
+		conf.Namespace = "eval"
+	}
+	if conf.Buckets == nil {
+		conf.Buckets = prometheus.DefBuckets
+	}
+
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: conf.Namespace, Name: name, Help: help}, labels)
+	}
+	m := &Metrics{
+		compiles:      counter("compiles_total", "Number of the compilations.", labelExpr),
+		compileErrors: counter("compile_errors_total", "Number of the failed compilations.", labelExpr),
+		evals:         counter("evaluations_total", "Number of the evaluations.", labelExpr),
+		evalErrors:    counter("evaluation_errors_total", "Number of the failed evaluations.", labelExpr),
+		evalDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: conf.Namespace,
+			Name:      "evaluation_duration_seconds",
+			Help:      "Latency of the evaluations.",
+			Buckets:   conf.Buckets,
+		}, []string{labelExpr}),
+		shortCircuits:  counter("short_circuits_total", "Number of the and/or operators decided before all their operands are evaluated.", labelExpr),
+		operatorCalls:  counter("operator_calls_total", "Number of the operator calls.", labelExpr, labelOperator),
+		operatorErrors: counter("operator_errors_total", "Number of the operator calls failed.", labelExpr, labelOperator),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.compiles, m.compileErrors, m.evals, m.evalErrors, m.evalDuration,
+		m.shortCircuits, m.operatorCalls, m.operatorErrors,
+	} {
+		if err := conf.Registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// CompileOption installs the hook recording the operator calls and the short circuits
+func (m *Metrics) CompileOption() eval.CompileOption {
+	return func(c *eval.CompileConfig) {
+		c.EvalHook = hook{m}
+	}
+}
+
+// Compile compiles the expression and records the compilation with the label
+func (m *Metrics) Compile(label string, cc *eval.CompileConfig, exprStr string) (*eval.Expr, error) {
+	m.compiles.WithLabelValues(label).Inc()
+	expr, err := eval.Compile(cc, exprStr)
+	if err != nil {
+		m.compileErrors.WithLabelValues(label).Inc()
+	}
+	return expr, err
+}
+
+// evalState is the state of an evaluation shared with the hook by the context
+type evalState struct {
+	label string
+	// the error has been recorded by the node where it occurred
+	failed bool
+}
+
+type stateKey struct{}
+
+// Eval evaluates the expression and records the evaluation with the label,
+// the Ctx.Ctx of the evaluation is wrapped to pass the label to the hook.
+func (m *Metrics) Eval(label string, expr *eval.Expr, ctx *eval.Ctx) (eval.Value, error) {
+	c := &eval.Ctx{}
+	if ctx != nil {
+		*c = *ctx
+	}
+	parent := c.Ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	c.Ctx = context.WithValue(parent, stateKey{}, &evalState{label: label})
+
+	start := time.Now()
+	res, err := expr.Eval(c)
+	m.evalDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
+	m.evals.WithLabelValues(label).Inc()
+	if err != nil {
+		m.evalErrors.WithLabelValues(label).Inc()
+	}
+	return res, err
+}
+
+// hook records the operator calls and the short circuits of the evaluations started by Eval
+type hook struct {
+	m *Metrics
+}
+
+func (h hook) BeforeNode(eval.HookNode) {}
+
+func (h hook) AfterNode(n eval.HookNode, _ eval.Value, err error, _ time.Duration) {
+	if n.Ctx == nil || n.Ctx.Ctx == nil {
+		return
+	}
+	state, ok := n.Ctx.Ctx.Value(stateKey{}).(*evalState)
+	if !ok {
+		return
+	}
+
+	if n.ShortCircuit {
+		h.m.shortCircuits.WithLabelValues(state.label).Inc()
+	}
+	if n.Kind == ast.Operator {
+		h.m.operatorCalls.WithLabelValues(state.label, fmt.Sprint(n.Value)).Inc()
+	}
+	// the error is passed to all the ancestors of the node where it occurred
+	if err != nil && !state.failed {
+		state.failed = true
+		if n.Kind == ast.Operator {
+			h.m.operatorErrors.WithLabelValues(state.label, fmt.Sprint(n.Value)).Inc()
+		}
+	}
+}
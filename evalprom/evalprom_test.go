@@ -0,0 +1,97 @@
+package evalprom
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/larry618/eval"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := New(Config{Registerer: reg})
+	if err != nil {
+		t.Fatalf("new metrics error: %v", err)
+	}
+
+	cc := eval.NewCompileConfig(eval.EnableStringSelectors, m.CompileOption())
+	expr, err := m.Compile("eligible", cc, `(or (= country "US") (and (> age 18) (= tier "gold")))`)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	if _, err := m.Compile("broken", cc, `(> age`); err == nil {
+		t.Fatalf("compile error expected")
+	}
+
+	vals := map[string]eval.Value{"country": "US", "age": int64(20), "tier": "gold"}
+	sel := eval.SelectorFunc(func(name string) (eval.Value, error) {
+		if v, ok := vals[name]; ok {
+			return v, nil
+		}
+		return nil, errors.New("unknown selector: " + name)
+	})
+	ctx := eval.NewCtxWithStringSelector(sel)
+
+	// short circuit on the first param of or
+	if res, err := m.Eval("eligible", expr, ctx); err != nil || res != true {
+		t.Fatalf("unexpected result: %v, error: %v", res, err)
+	}
+	// the age is not an int
+	vals["country"], vals["age"] = "CA", "old"
+	if _, err := m.Eval("eligible", expr, ctx); err == nil {
+		t.Fatalf("eval error expected")
+	}
+
+	cases := []struct {
+		name string
+		c    prometheus.Collector
+		want float64
+	}{
+		{"compiles", m.compiles.WithLabelValues("eligible"), 1},
+		{"compiles of broken", m.compiles.WithLabelValues("broken"), 1},
+		{"compile errors", m.compileErrors.WithLabelValues("eligible"), 0},
+		{"compile errors of broken", m.compileErrors.WithLabelValues("broken"), 1},
+		{"evaluations", m.evals.WithLabelValues("eligible"), 2},
+		{"evaluation errors", m.evalErrors.WithLabelValues("eligible"), 1},
+		{"short circuits", m.shortCircuits.WithLabelValues("eligible"), 1},
+		{"eq calls", m.operatorCalls.WithLabelValues("eligible", "="), 2},
+		{"gt errors", m.operatorErrors.WithLabelValues("eligible", ">"), 1},
+		{"or errors", m.operatorErrors.WithLabelValues("eligible", "or"), 0},
+	}
+	for _, c := range cases {
+		if got := testutil.ToFloat64(c.c); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	if n := testutil.CollectAndCount(m.evalDuration); n != 1 {
+		t.Errorf("unexpected histogram count: %d", n)
+	}
+}
+
+func TestMetrics_WithoutHook(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := New(Config{Registerer: reg, Namespace: "rules"})
+	if err != nil {
+		t.Fatalf("new metrics error: %v", err)
+	}
+	if _, err := New(Config{Registerer: reg, Namespace: "rules"}); err == nil {
+		t.Fatalf("duplicate registration error expected")
+	}
+
+	expr, err := m.Compile("const", eval.NewCompileConfig(), `(+ 1 2)`)
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	if res, err := m.Eval("const", expr, nil); err != nil || res != int64(3) {
+		t.Fatalf("unexpected result: %v, error: %v", res, err)
+	}
+	if got := testutil.ToFloat64(m.evals.WithLabelValues("const")); got != 1 {
+		t.Fatalf("unexpected evaluations: %v", got)
+	}
+	if n := testutil.CollectAndCount(m.operatorCalls); n != 0 {
+		t.Fatalf("unexpected operator calls: %d", n)
+	}
+}
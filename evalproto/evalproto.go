@@ -0,0 +1,204 @@
+// Package evalproto implements a Selector backed by a proto.Message, so that the protobuf
+// messages can be evaluated directly without being converted to maps.
+//
+//	expr, err := eval.Compile(cc, `(and (= event.type "CLICK") (> event.user.age 18))`)
+//	res, err := expr.EvalBool(evalproto.NewCtx(event))
+//
+// The selector names are the dot-paths of the fields, e.g. user.age, the fields are matched by
+// their proto names or json names. The values are converted to the types of the operands:
+//
+//	bool, string: as is
+//	integers: int64
+//	float, double: float64
+//	bytes: string
+//	enums: the names of the values, e.g. "CLICK", or the formatted numbers if they are unknown, e.g. "7"
+//	messages: the proto.Message, whose fields can be accessed by the field operator
+//	repeated: []int64, []string, or []eval.Value of the other element types
+//	maps: map[string]eval.Value keyed by the formatted keys
+//
+// Note that the dot-path is parsed as the field operator, e.g. user.age => (field user "age"),
+// unless it's registered as a selector, e.g. eval.GetOrRegisterKey(cc, "user.age"),
+// so register the paths to resolve the nested fields by protoreflect, including the enum names.
+//
+// It's a separate module, so that the protobuf dependencies are not required by the eval package.
+package evalproto
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/larry618/eval"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// pathKey is the key of the resolved field paths
+type pathKey struct {
+	desc protoreflect.MessageDescriptor
+	name string
+}
+
+// fieldPaths caches the field descriptors of the selector names by the message types,
+// map[pathKey][]protoreflect.FieldDescriptor
+var fieldPaths sync.Map
+
+// Selector is a Selector which gets the values from the fields of a proto.Message
+type Selector struct {
+	msg protoreflect.Message
+	// values set by Set, they take precedence over the values of the fields
+	overrides map[string]eval.Value
+}
+
+var _ eval.Selector = (*Selector)(nil)
+
+// NewSelector creates a Selector of the message
+func NewSelector(msg proto.Message) *Selector {
+	return &Selector{
+		msg:       msg.ProtoReflect(),
+		overrides: make(map[string]eval.Value),
+	}
+}
+
+// NewCtx creates a Ctx with a Selector of the message
+func NewCtx(msg proto.Message) *eval.Ctx {
+	return &eval.Ctx{
+		Selector: NewSelector(msg),
+	}
+}
+
+func (s *Selector) Get(_ eval.SelectorKey, name string) (eval.Value, error) {
+	if val, exist := s.overrides[name]; exist {
+		return val, nil
+	}
+
+	path, err := resolve(s.msg.Descriptor(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := s.msg
+	last := len(path) - 1
+	for _, fd := range path[:last] {
+		// the unset messages are empty, the same as the getters of the generated code
+		msg = msg.Get(fd).Message()
+	}
+	return fieldValue(path[last], msg.Get(path[last])), nil
+}
+
+func (s *Selector) Set(_ eval.SelectorKey, name string, val eval.Value) error {
+	s.overrides[name] = val
+	return nil
+}
+
+func (s *Selector) Cached(_ eval.SelectorKey, name string) bool {
+	_, exist := s.overrides[name]
+	return exist
+}
+
+// resolve returns the field descriptors of the dot-path of the message type
+func resolve(desc protoreflect.MessageDescriptor, name string) ([]protoreflect.FieldDescriptor, error) {
+	key := pathKey{desc: desc, name: name}
+	if path, exist := fieldPaths.Load(key); exist {
+		return path.([]protoreflect.FieldDescriptor), nil
+	}
+
+	names := strings.Split(name, ".")
+	path := make([]protoreflect.FieldDescriptor, 0, len(names))
+	for i, n := range names {
+		if desc == nil {
+			return nil, fmt.Errorf("%w %s, %s is not a message", eval.ErrSelectorNotExist, name, strings.Join(names[:i], "."))
+		}
+		fields := desc.Fields()
+		fd := fields.ByName(protoreflect.Name(n))
+		if fd == nil {
+			fd = fields.ByJSONName(n)
+		}
+		if fd == nil {
+			return nil, fmt.Errorf("%w %s", eval.ErrSelectorNotExist, name)
+		}
+		path = append(path, fd)
+
+		desc = nil
+		if fd.Kind() == protoreflect.MessageKind && !fd.IsList() && !fd.IsMap() {
+			desc = fd.Message()
+		}
+	}
+
+	fieldPaths.Store(key, path)
+	return path, nil
+}
+
+// fieldValue converts the value of the field to the types of the operands
+func fieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) eval.Value {
+	switch {
+	case fd.IsList():
+		return listValue(fd, v.List())
+	case fd.IsMap():
+		res := make(map[string]eval.Value, v.Map().Len())
+		v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+			res[k.String()] = scalarValue(fd.MapValue(), mv)
+			return true
+		})
+		return res
+	}
+	return scalarValue(fd, v)
+}
+
+func listValue(fd protoreflect.FieldDescriptor, l protoreflect.List) eval.Value {
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		res := make([]int64, l.Len())
+		for i := range res {
+			res[i] = scalarValue(fd, l.Get(i)).(int64)
+		}
+		return res
+	case protoreflect.StringKind, protoreflect.EnumKind:
+		res := make([]string, l.Len())
+		for i := range res {
+			res[i] = enumOrString(fd, l.Get(i))
+		}
+		return res
+	}
+	res := make([]eval.Value, l.Len())
+	for i := range res {
+		res[i] = scalarValue(fd, l.Get(i))
+	}
+	return res
+}
+
+// enumOrString returns the string, or the name of the enum value, which is the formatted number if it's unknown
+func enumOrString(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	if fd.Kind() != protoreflect.EnumKind {
+		return v.String()
+	}
+	if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+		return string(ev.Name())
+	}
+	return fmt.Sprint(int32(v.Enum()))
+}
+
+func scalarValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) eval.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return v.Bool()
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return v.Int()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return int64(v.Uint())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float()
+	case protoreflect.StringKind:
+		return v.String()
+	case protoreflect.BytesKind:
+		return string(v.Bytes())
+	case protoreflect.EnumKind:
+		return enumOrString(fd, v)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return v.Message().Interface()
+	}
+	return v.Interface()
+}
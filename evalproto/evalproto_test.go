@@ -0,0 +1,161 @@
+package evalproto
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestSelector(t *testing.T) {
+	file := &descriptorpb.FileDescriptorProto{
+		Name:             proto.String("event.proto"),
+		Package:          proto.String("events.v1"),
+		Dependency:       []string{"a.proto", "b.proto"},
+		PublicDependency: []int32{0, 1},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Event"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:   proto.String("id"),
+				Number: proto.Int32(1),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			}},
+		}},
+		Options: &descriptorpb.FileOptions{
+			JavaPackage:       proto.String("com.example"),
+			OptimizeFor:       descriptorpb.FileOptions_SPEED.Enum(),
+			CcEnableArenas:    proto.Bool(true),
+			JavaMultipleFiles: proto.Bool(false),
+		},
+	}
+
+	testCases := []struct {
+		name   string
+		want   eval.Value
+		errMsg string
+	}{
+		{name: "name", want: "event.proto"},
+		{name: "package", want: "events.v1"},
+		{name: "dependency", want: []string{"a.proto", "b.proto"}},
+		{name: "public_dependency", want: []int64{0, 1}},
+		{name: "publicDependency", want: []int64{0, 1}},
+		{name: "options.java_package", want: "com.example"},
+		{name: "options.javaPackage", want: "com.example"},
+		{name: "options.optimize_for", want: "SPEED"},
+		{name: "options.cc_enable_arenas", want: true},
+		{name: "options.java_multiple_files", want: false},
+		{name: "source_code_info.location", want: []eval.Value{}},
+		{name: "syntax", want: ""},
+
+		{name: "missing", errMsg: "not exist missing"},
+		{name: "options.missing", errMsg: "not exist options.missing"},
+		{name: "name.first", errMsg: "not exist name.first, name is not a message"},
+		{name: "message_type.name", errMsg: "not exist message_type.name, message_type is not a message"},
+	}
+
+	sel := NewSelector(file)
+	for _, c := range testCases {
+		got, err := sel.Get(eval.UndefinedSelKey, c.name)
+		if len(c.errMsg) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Fatalf("unexpected error, name: %s, got: %v, want: %s", c.name, err, c.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, name: %s, err: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected result, name: %s, got: %#v, want: %#v", c.name, got, c.want)
+		}
+	}
+
+	// the nested messages can be accessed by the field operator
+	got, err := sel.Get(eval.UndefinedSelKey, "options")
+	if err != nil || !proto.Equal(got.(proto.Message), file.Options) {
+		t.Fatalf("unexpected result, got: %v, err: %v", got, err)
+	}
+	msgs, err := sel.Get(eval.UndefinedSelKey, "message_type")
+	if err != nil || len(msgs.([]eval.Value)) != 1 || !proto.Equal(msgs.([]eval.Value)[0].(proto.Message), file.MessageType[0]) {
+		t.Fatalf("unexpected result, got: %v, err: %v", msgs, err)
+	}
+
+	// the values set take precedence over the fields
+	if sel.Cached(eval.UndefinedSelKey, "name") {
+		t.Fatal("name should not be cached")
+	}
+	if err := sel.Set(eval.UndefinedSelKey, "name", "other.proto"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := sel.Get(eval.UndefinedSelKey, "name"); got != "other.proto" || !sel.Cached(eval.UndefinedSelKey, "name") {
+		t.Fatalf("unexpected result, got: %v", got)
+	}
+}
+
+func TestEval(t *testing.T) {
+	field := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("user_id"),
+		Number:   proto.Int32(3),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+		Type:     descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+		JsonName: proto.String("userId"),
+		Options:  &descriptorpb.FieldOptions{Deprecated: proto.Bool(true)},
+	}
+
+	cc := eval.NewCompileConfig(eval.EnableStringSelectors)
+	for _, name := range []string{"options.deprecated", "json_name"} {
+		eval.GetOrRegisterKey(cc, name)
+	}
+
+	testCases := []struct {
+		expr string
+		want eval.Value
+	}{
+		{expr: `(and (= type "TYPE_INT64") (= label "LABEL_REPEATED"))`, want: true},
+		{expr: `(in type ("TYPE_INT32" "TYPE_INT64"))`, want: true},
+		{expr: `(>= number 3)`, want: true},
+		{expr: `(= json_name "userId")`, want: true},
+		{expr: `options.deprecated`, want: true},
+		{expr: `(= (len name) 7)`, want: true},
+	}
+
+	for _, c := range testCases {
+		expr, err := eval.Compile(cc, c.expr)
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, err: %v", c.expr, err)
+		}
+		got, err := expr.Eval(NewCtx(field))
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, err: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected result, expr: %s, got: %v, want: %v", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestMapAndUnknownEnum(t *testing.T) {
+	s, err := structpb.NewStruct(map[string]interface{}{"country": "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewSelector(s).Get(eval.UndefinedSelKey, "fields")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := got.(map[string]eval.Value)
+	if len(fields) != 1 || fields["country"].(*structpb.Value).GetStringValue() != "US" {
+		t.Fatalf("unexpected result, got: %v", got)
+	}
+
+	f := &descriptorpb.FieldDescriptorProto{Type: descriptorpb.FieldDescriptorProto_Type(99).Enum()}
+	got, err = NewSelector(f).Get(eval.UndefinedSelKey, "type")
+	if err != nil || got != "99" {
+		t.Fatalf("unexpected result, got: %v, err: %v", got, err)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/larry618/eval/evalproto
+
+go 1.25.0
+
+require (
+	github.com/larry618/eval v0.0.0
+	google.golang.org/protobuf v1.36.11
+)
+
+replace github.com/larry618/eval => ../
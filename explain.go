@@ -0,0 +1,146 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Trace explains how the value of a subexpression is decided
+type Trace struct {
+	// Expr is the decompiled subexpression
+	Expr  string
+	Value Value
+	Err   error
+	// Skipped is true if the subexpression is not evaluated,
+	// because of short circuit or the branch is not taken
+	Skipped bool
+	// ShortCircuit is true if the value of this subexpression decides the value of its parent,
+	// and the rest siblings are skipped
+	ShortCircuit bool
+	Children     []*Trace
+}
+
+// String renders the trace as an indented tree
+func (t *Trace) String() string {
+	var sb strings.Builder
+	t.write(&sb, 0)
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (t *Trace) write(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(t.Expr)
+	switch {
+	case t.Skipped:
+		sb.WriteString(" => skipped")
+	case t.Err != nil:
+		sb.WriteString(fmt.Sprintf(" => error: %v", t.Err))
+	default:
+		sb.WriteString(fmt.Sprintf(" => %s", decompileValue(t.Value)))
+	}
+	if t.ShortCircuit {
+		sb.WriteString(" (short circuit)")
+	}
+	sb.WriteRune('\n')
+	for _, child := range t.Children {
+		child.write(sb, depth+1)
+	}
+}
+
+// EvalBoolWithTrace evaluates the expression like EvalBool,
+// and returns the trace tree explaining the result, it's much slower than EvalBool.
+// The trace is returned even if the evaluation fails.
+func (e *Expr) EvalBoolWithTrace(ctx *Ctx) (bool, *Trace, error) {
+	if e.memoizeSelectors && ctx != nil {
+		ctx = newMemoCtx(ctx)
+	}
+	trace := e.evalTrace(ctx, e.getNode(0))
+	if _, err := checkMissingResult(trace.Value, trace.Err); err != nil {
+		return false, trace, err
+	}
+	res, ok := trace.Value.(bool)
+	if !ok {
+		return false, trace, resultTypeError(typeBool, trace.Value)
+	}
+	return res, trace, nil
+}
+
+// evalTrace evaluates the subexpression recursively,
+// the short circuit follows the same rules as the compiled one.
+func (e *Expr) evalTrace(ctx *Ctx, n *node) *Trace {
+	t := &Trace{Expr: e.decompileNode(n)}
+
+	switch n.getNodeType() {
+	case constant:
+		t.Value = n.value
+		return t
+	case selector:
+		t.Value, t.Err = e.getSelectorValue(ctx, n)
+		return t
+	}
+
+	children := e.childNodes(n)
+
+	var skip = func(from int) {
+		for _, child := range children[from:] {
+			t.Children = append(t.Children, &Trace{Expr: e.decompileNode(child), Skipped: true})
+		}
+	}
+
+	if n.getNodeType() == cond {
+		c := e.evalTrace(ctx, children[0])
+		t.Children = append(t.Children, c)
+		if c.Err != nil {
+			t.Err = c.Err
+			skip(1)
+			return t
+		}
+		condRes, ok := c.Value.(bool)
+		if !ok {
+			t.Err = condTypeError(c.Value)
+			skip(1)
+			return t
+		}
+
+		var branch *Trace
+		if condRes {
+			branch = e.evalTrace(ctx, children[1])
+			t.Children = append(t.Children, branch)
+			skip(2)
+		} else {
+			skip(1)
+			branch = e.evalTrace(ctx, children[2])
+			t.Children[2] = branch
+		}
+		t.Value, t.Err = branch.Value, branch.Err
+		return t
+	}
+
+	params := make([]Value, len(children))
+	for i, child := range children {
+		ct := e.evalTrace(ctx, child)
+		t.Children = append(t.Children, ct)
+		if ct.Err != nil {
+			t.Err = ct.Err
+			skip(i + 1)
+			return t
+		}
+
+		// same as the short circuit flags set by calAndSetShortCircuit
+		if b, ok := ct.Value.(bool); ok && shortCircuits(n, i, len(children), b) {
+			ct.ShortCircuit = i != len(children)-1
+			t.Value = b
+			skip(i + 1)
+			return t
+		}
+		params[i] = ct.Value
+	}
+
+	res, err := n.operator(ctx, params)
+	if err != nil {
+		t.Err = fmt.Errorf("operator execution error, operator: %v, error: %w", n.value, err)
+		return t
+	}
+	t.Value = res
+	return t
+}
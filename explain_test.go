@@ -0,0 +1,58 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestExpr_EvalBoolWithTrace(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     16,
+		"country": "US",
+		"vip":     false,
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals), Optimizations(false))
+
+	expr, err := Compile(cc, `(and (in country ("US" "CA")) (or vip (>= age 18) (= country "CN")) (if vip true false))`)
+	assertNil(t, err)
+
+	res, trace, err := expr.EvalBoolWithTrace(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, false)
+	assertEquals(t, trace.String(), `(and (in country ("US" "CA")) (or vip (>= age 18) (= country "CN")) (if vip true false)) => false
+  (in country ("US" "CA")) => true
+    country => "US"
+    ("US" "CA") => ("US" "CA")
+  (or vip (>= age 18) (= country "CN")) => false (short circuit)
+    vip => false
+    (>= age 18) => false
+      age => 16
+      18 => 18
+    (= country "CN") => false
+      country => "US"
+      "CN" => "CN"
+  (if vip true false) => skipped`)
+
+	want, err := expr.EvalBool(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, want)
+
+	// the branch not taken is skipped
+	expr, err = Compile(cc, `(if (> age 18) (= country "US") (= country "CA"))`)
+	assertNil(t, err)
+	res, trace, err = expr.EvalBoolWithTrace(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, false)
+	assertEquals(t, trace.Children[1].Skipped, true)
+	assertEquals(t, trace.Children[2].Value, false)
+
+	// the trace is returned with the error
+	_, trace, err = expr.EvalBoolWithTrace(NewCtxWithMap(cc, map[string]interface{}{}))
+	assertNotNil(t, err)
+	assertEquals(t, trace.Children[0].Err, err)
+	assertEquals(t, trace.Children[2].Skipped, true)
+
+	expr, err = Compile(cc, `(+ age 1)`)
+	assertNil(t, err)
+	_, _, err = expr.EvalBoolWithTrace(NewCtxWithMap(cc, vals))
+	assertErrStrContains(t, err, "invalid result type")
+}
@@ -0,0 +1,160 @@
+package eval
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldPathSeparator separates the names in the dot-path of the nested fields, e.g. user.address.city
+const fieldPathSeparator = "."
+
+// optionalChainMark marks the optional chaining of the nested fields, e.g. user?.address?.city
+const optionalChainMark = "?"
+
+// structFields caches the field indexes of the struct types, map[reflect.Type]map[string][]int
+var structFields sync.Map
+
+// getField returns the nested field of the selector value by the names, e.g. (field user "address" "city"),
+// the value can be a map with string keys, a struct or a pointer to them.
+// The struct fields are matched by the `eval` tags, the `json` tags, or the field names in order.
+func getField(_ *Ctx, params []Value) (Value, error) {
+	return nestedField("field", params, false)
+}
+
+// getOrNil returns the nested field like getField, but null is returned instead of an error
+// if any field along the path is missing, null or not accessible, e.g. (getOrNil user "address" "city"),
+// which is the compiled form of the optional chaining user?.address?.city
+func getOrNil(_ *Ctx, params []Value) (Value, error) {
+	return nestedField("getOrNil", params, true)
+}
+
+func nestedField(op string, params []Value, orNil bool) (Value, error) {
+	if len(params) < 2 {
+		return nil, ParamsCountError(op, 2, len(params))
+	}
+
+	v := params[0]
+	for _, p := range params[1:] {
+		name, ok := p.(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, p)
+		}
+		if v == nil && orNil {
+			continue
+		}
+
+		var err error
+		if v, err = fieldOf(v, name); err != nil {
+			if orNil {
+				v = nil
+				continue
+			}
+			return nil, OpExecError(op, err)
+		}
+	}
+	return unifyType(v), nil
+}
+
+func fieldOf(v Value, name string) (Value, error) {
+	switch m := v.(type) {
+	case map[string]Value:
+		if res, exist := m[name]; exist {
+			return res, nil
+		}
+		return nil, fmt.Errorf("field not found: %s", name)
+	case map[string]interface{}:
+		if res, exist := m[name]; exist {
+			return res, nil
+		}
+		return nil, fmt.Errorf("field not found: %s", name)
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("nil value, field: %s", name)
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		res := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !res.IsValid() {
+			return nil, fmt.Errorf("field not found: %s", name)
+		}
+		return res.Interface(), nil
+	case reflect.Struct:
+		idx, exist := fieldIndexes(rv.Type())[name]
+		if !exist {
+			return nil, fmt.Errorf("field not found: %s", name)
+		}
+		res, err := rv.FieldByIndexErr(idx)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		return res.Interface(), nil
+	}
+	return nil, fmt.Errorf("field %s is not accessible on type: %T", name, v)
+}
+
+// fieldIndexes returns the indexes of the exported fields of the struct type keyed by their names
+func fieldIndexes(typ reflect.Type) map[string][]int {
+	if res, exist := structFields.Load(typ); exist {
+		return res.(map[string][]int)
+	}
+
+	res := make(map[string][]int)
+	for _, f := range reflect.VisibleFields(typ) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag := tagName(f.Tag.Get("json")); tag != "" {
+			name = tag
+		}
+		if tag := tagName(f.Tag.Get("eval")); tag != "" {
+			name = tag
+		}
+		if _, exist := res[name]; !exist {
+			res[name] = f.Index
+		}
+	}
+
+	structFields.Store(typ, res)
+	return res
+}
+
+func tagName(tag string) string {
+	name := strings.Split(tag, ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// splitFieldPath splits the dot-path into the selector name and the field names,
+// e.g. user.address.city => user, [address city]. The path is optional if any of the
+// names is followed by ?, e.g. user?.address.city, which yields null on the missing fields.
+func splitFieldPath(path string) (string, []string, bool, bool) {
+	names := strings.Split(path, fieldPathSeparator)
+	if len(names) < 2 {
+		return "", nil, false, false
+	}
+	var optional bool
+	for i, name := range names {
+		if i != len(names)-1 && strings.HasSuffix(name, optionalChainMark) {
+			name = strings.TrimSuffix(name, optionalChainMark)
+			names[i], optional = name, true
+		}
+		if name == "" || strings.Contains(name, optionalChainMark) {
+			return "", nil, false, false
+		}
+	}
+	return names[0], names[1:], optional, true
+}
@@ -0,0 +1,141 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// formatString formats the params by the format like fmt.Sprintf, e.g. (format "%s-%03d" region id),
+// the verbs are checked against the types of the params:
+//   - %v: any value
+//   - %s, %q: string
+//   - %d, %o, %b: int64
+//   - %x, %X: int64 or string
+//   - %f, %F, %e, %E, %g, %G: float64 or int64
+//   - %t: bool
+//
+// The explicit argument indexes and the * widths and precisions are not supported.
+// The constant format is parsed at compile time, and its verbs are checked against the params count.
+func formatString(_ *Ctx, params []Value) (Value, error) {
+	const op = "format"
+	if len(params) == 0 {
+		return nil, ParamsCountError(op, 1, 0)
+	}
+	format, ok := params[0].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, params[0])
+	}
+	verbs, err := formatVerbs(format)
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	return executeFormat(format, verbs, params[1:])
+}
+
+// specializeFormat parses the constant format at compile time
+func specializeFormat(consts []Value, known []bool) (Operator, error) {
+	const op = "format"
+	if len(consts) == 0 || !known[0] {
+		return nil, nil
+	}
+	format, ok := consts[0].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, consts[0])
+	}
+	verbs, err := formatVerbs(format)
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	if len(verbs) != len(consts)-1 {
+		return nil, OpExecError(op, fmt.Errorf("format %q has %d verbs, got %d params", format, len(verbs), len(consts)-1))
+	}
+	return func(_ *Ctx, params []Value) (Value, error) {
+		return executeFormat(format, verbs, params[1:])
+	}, nil
+}
+
+func executeFormat(format string, verbs []rune, params []Value) (Value, error) {
+	const op = "format"
+	if len(verbs) != len(params) {
+		return nil, OpExecError(op, fmt.Errorf("format %q has %d verbs, got %d params", format, len(verbs), len(params)))
+	}
+
+	args := make([]interface{}, len(params))
+	for i, p := range params {
+		arg, err := formatArg(verbs[i], p)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = arg
+	}
+	return fmt.Sprintf(format, args...), nil
+}
+
+// formatArg checks the type of the param formatted by the verb
+func formatArg(verb rune, p Value) (interface{}, error) {
+	const op = "format"
+	switch verb {
+	case 'v':
+		return p, nil
+	case 's', 'q':
+		if _, ok := p.(string); ok {
+			return p, nil
+		}
+		return nil, ParamTypeError(op, typeStr, p)
+	case 'd', 'o', 'b':
+		if _, ok := p.(int64); ok {
+			return p, nil
+		}
+		return nil, ParamTypeError(op, typeInt, p)
+	case 'x', 'X':
+		switch p.(type) {
+		case int64, string:
+			return p, nil
+		}
+		return nil, ParamTypeError(op, "int64 or string", p)
+	case 't':
+		if _, ok := p.(bool); ok {
+			return p, nil
+		}
+		return nil, ParamTypeError(op, typeBool, p)
+	}
+
+	switch v := p.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	}
+	return nil, ParamTypeError(op, typeFloat, p)
+}
+
+// formatVerbs returns the verbs of the format in order, e.g. "%s-%03d%%" => ['s', 'd']
+func formatVerbs(format string) ([]rune, error) {
+	const (
+		flags = "+-# 0123456789."
+		verbs = "vsqdobxXfFeEgGt"
+	)
+
+	var res []rune
+	rs := []rune(format)
+	for i := 0; i < len(rs); i++ {
+		if rs[i] != '%' {
+			continue
+		}
+		j := i + 1
+		for j < len(rs) && strings.ContainsRune(flags, rs[j]) {
+			j++
+		}
+		switch {
+		case j == len(rs):
+			return nil, fmt.Errorf("format %q has an incomplete verb at %d", format, i)
+		case rs[j] == '%' && j == i+1:
+		case strings.ContainsRune(verbs, rs[j]):
+			res = append(res, rs[j])
+		default:
+			return nil, fmt.Errorf("format %q has an unsupported verb: %s", format, string(rs[i:j+1]))
+		}
+		i = j
+	}
+	return res, nil
+}
@@ -0,0 +1,71 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	vals := map[string]interface{}{
+		"region": "us-east",
+		"id":     7,
+		"ratio":  0.125,
+		"vip":    true,
+		"user":   map[string]interface{}{"name": "larry"},
+		"layout": "%s/%d",
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(format "%s-%03d" region id)`, res: "us-east-007"},
+		{expr: `(sprintf "%.2f%%" ratio)`, res: "0.12%"},
+		{expr: `(format "%.1f" id)`, res: "7.0"},
+		{expr: `(format "%v|%v|%t|%x" region id vip "ab")`, res: "us-east|7|true|6162"},
+		{expr: `(format "%q" user.name)`, res: `"larry"`},
+		{expr: `(format "no verbs")`, res: "no verbs"},
+		{expr: `(format layout region id)`, res: "us-east/7"},
+		{expr: `(= (format "%s:%d" region id) "us-east:7")`, opts: []CompileOption{EnableTypeCheck}, res: true},
+		{expr: "`route-${region}-${id}`", res: "route-us-east-7"},
+		{expr: "`${user.name} has 100%`", res: "larry has 100%"},
+		{expr: "`${(+ id 1)}${region}`", res: "8us-east"},
+		{expr: "(if vip `vip-${region}` `plain`)", res: "vip-us-east"},
+		{expr: "(= `plain text` \"plain text\")", res: true},
+
+		{expr: `(format "%s-%d" region)`, errMsg: "has 2 verbs, got 1 params"},
+		{expr: `(format "%s" region id)`, errMsg: "has 1 verbs, got 2 params"},
+		{expr: `(format "%w" region)`, errMsg: "unsupported verb: %w"},
+		{expr: `(format "%[1]s" region)`, errMsg: "unsupported verb: %["},
+		{expr: `(format "%5" region)`, errMsg: "incomplete verb"},
+		{expr: `(format "%d" region)`, errMsg: "expected: int64"},
+		{expr: `(format "%s" id)`, errMsg: "expected: string"},
+		{expr: `(format layout region)`, errMsg: "has 2 verbs, got 1 params"},
+		{expr: `(format id)`, errMsg: "expected: string"},
+		{expr: "`route-${region`", errMsg: "unterminated template placeholder"},
+		{expr: "`route-${}`", errMsg: "empty template placeholder"},
+		{expr: "`route-${region}", errMsg: "unterminated template string"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+}
+
+func TestFormat_Template(t *testing.T) {
+	cc := NewCompileConfig(RegisterSelKeys(map[string]interface{}{"region": "", "id": 0}))
+	expr, err := Compile(cc, "`${region}/100%/${id}`")
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(format "%v/100%%/%v" region id)`)
+
+	_, err = Compile(cc, "(+ 1 `${region`)")
+	assertErrStrContains(t, err, "(+ 1 `[$]{region`)")
+}
@@ -0,0 +1,201 @@
+package eval
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var (
+	ctxPtrType = reflect.TypeOf((*Ctx)(nil))
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RegisterFunc wraps the Go function into an operator and registers it with the name.
+// The function may take *Ctx as the first param, the other params and the result can be
+// bool, string, integers, floats, []int64, []string or interface{}, the last param can be variadic,
+// and the function may return an error as the second result, e.g. func(string, int64) (bool, error).
+// The params are converted and checked when the operator is executed,
+// the arity and signature of the function are also declared for the compile-time checking.
+func (cc *CompileConfig) RegisterFunc(name string, fn interface{}) error {
+	op, sig, err := newFuncOperator(name, fn)
+	if err != nil {
+		return err
+	}
+	if err := RegisterOperator(cc, name, op.execute); err != nil {
+		return err
+	}
+	cc.OperatorSignatures[name] = sig
+	cc.OperatorArities[name] = op.arity()
+	cc.changed()
+	return nil
+}
+
+type funcOperator struct {
+	name    string
+	fn      reflect.Value
+	withCtx bool
+	withErr bool
+	// types of the params except *Ctx, the last one is the element type if the function is variadic
+	params   []reflect.Type
+	variadic bool
+}
+
+func newFuncOperator(name string, fn interface{}) (*funcOperator, Signature, error) {
+	var sig Signature
+	typ := reflect.TypeOf(fn)
+	if typ == nil || typ.Kind() != reflect.Func {
+		return nil, sig, fmt.Errorf("register func error, %s is not a function: %T", name, fn)
+	}
+
+	op := &funcOperator{
+		name:     name,
+		fn:       reflect.ValueOf(fn),
+		variadic: typ.IsVariadic(),
+	}
+
+	for i := 0; i < typ.NumIn(); i++ {
+		in := typ.In(i)
+		if i == 0 && in == ctxPtrType {
+			op.withCtx = true
+			continue
+		}
+		if op.variadic && i == typ.NumIn()-1 {
+			in = in.Elem()
+		}
+		t, ok := funcValueType(in)
+		if !ok {
+			return nil, sig, fmt.Errorf("register func error, %s param %d type is unsupported: %s", name, i, in)
+		}
+		if t == TypeFloat {
+			// the int64 params are converted to float64
+			t = TypeNumber
+		}
+		op.params = append(op.params, in)
+		sig.Params = append(sig.Params, t)
+	}
+	sig.Variadic = op.variadic
+
+	switch {
+	case typ.NumOut() == 2 && typ.Out(1) == errorType:
+		op.withErr = true
+	case typ.NumOut() != 1:
+		return nil, sig, fmt.Errorf("register func error, %s should return a value and an optional error", name)
+	}
+	res, ok := funcValueType(typ.Out(0))
+	if !ok {
+		return nil, sig, fmt.Errorf("register func error, %s result type is unsupported: %s", name, typ.Out(0))
+	}
+	sig.Result = res
+	return op, sig, nil
+}
+
+// funcValueType returns the static type of the Go type used in the function
+func funcValueType(typ reflect.Type) (Type, bool) {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return TypeBool, true
+	case reflect.String:
+		return TypeString, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TypeInt, true
+	case reflect.Float32, reflect.Float64:
+		return TypeFloat, true
+	case reflect.Slice:
+		switch typ.Elem().Kind() {
+		case reflect.Int64:
+			return TypeIntList, true
+		case reflect.String:
+			return TypeStrList, true
+		}
+	case reflect.Interface:
+		if typ.NumMethod() == 0 {
+			return TypeAny, true
+		}
+	}
+	return "", false
+}
+
+func (f *funcOperator) arity() Arity {
+	if f.variadic {
+		return Arity{Min: len(f.params) - 1, Max: -1}
+	}
+	return Arity{Min: len(f.params), Max: len(f.params)}
+}
+
+func (f *funcOperator) execute(ctx *Ctx, params []Value) (Value, error) {
+	size := len(f.params)
+	if (!f.variadic && len(params) != size) || (f.variadic && len(params) < size-1) {
+		return nil, ParamsCountError(f.name, size, len(params))
+	}
+
+	args := make([]reflect.Value, 0, len(params)+1)
+	if f.withCtx {
+		args = append(args, reflect.ValueOf(ctx))
+	}
+	for i, p := range params {
+		typ := f.params[min(i, size-1)]
+		arg, ok := convertFuncParam(p, typ)
+		if !ok {
+			return nil, ParamTypeError(f.name, typ.String(), p)
+		}
+		args = append(args, arg)
+	}
+
+	out := f.fn.Call(args)
+	if f.withErr && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	return unifyType(out[0].Interface()), nil
+}
+
+// convertFuncParam converts the value to the param type of the function,
+// it fails if the type mismatches or the integer overflows.
+func convertFuncParam(v Value, typ reflect.Type) (reflect.Value, bool) {
+	switch typ.Kind() {
+	case reflect.Interface:
+		if v == nil {
+			return reflect.Zero(typ), true
+		}
+		return reflect.ValueOf(v), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := v.(int64)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		res := reflect.New(typ).Elem()
+		if res.OverflowInt(i) {
+			return reflect.Value{}, false
+		}
+		res.SetInt(i)
+		return res, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := v.(int64)
+		if !ok || i < 0 {
+			return reflect.Value{}, false
+		}
+		res := reflect.New(typ).Elem()
+		if res.OverflowUint(uint64(i)) {
+			return reflect.Value{}, false
+		}
+		res.SetUint(uint64(i))
+		return res, true
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch n := v.(type) {
+		case float64:
+			f = n
+		case int64:
+			f = float64(n)
+		default:
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(f).Convert(typ), true
+	}
+
+	res := reflect.ValueOf(v)
+	if !res.IsValid() || res.Type() != typ {
+		return reflect.Value{}, false
+	}
+	return res, true
+}
@@ -0,0 +1,84 @@
+package eval
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompileConfig_RegisterFunc(t *testing.T) {
+	vals := map[string]interface{}{
+		"name":  "Alice",
+		"age":   20,
+		"score": 85.5,
+		"tags":  []string{"vip", "new"},
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+
+	assertNil(t, cc.RegisterFunc("has_prefix", strings.HasPrefix))
+	assertNil(t, cc.RegisterFunc("repeat", func(s string, n int) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		return strings.Repeat(s, n), nil
+	}))
+	assertNil(t, cc.RegisterFunc("sum", func(nums ...float64) float64 {
+		var res float64
+		for _, n := range nums {
+			res += n
+		}
+		return res
+	}))
+	assertNil(t, cc.RegisterFunc("count", func(ctx *Ctx, list []string) uint8 {
+		assertNotNil(t, ctx)
+		return uint8(len(list))
+	}))
+	assertNil(t, cc.RegisterFunc("first", func(list ...interface{}) interface{} {
+		return list[0]
+	}))
+
+	testCases := []struct {
+		expr   string
+		want   Value
+		errMsg string
+	}{
+		{expr: `(has_prefix name "Al")`, want: true},
+		{expr: `(repeat "ab" 2)`, want: "abab"},
+		{expr: `(sum score 1 0.5)`, want: 87.0},
+		{expr: `(sum)`, want: 0.0},
+		{expr: `(count tags)`, want: int64(2)},
+		{expr: `(first age name)`, want: int64(20)},
+		{expr: `(repeat "ab" -1)`, errMsg: "negative count"},
+		{expr: `(has_prefix name)`, errMsg: "has_prefix parameters count error (want: 2, got: 1)"},
+		{expr: `(has_prefix name 1)`, errMsg: paramTypeErrMsg},
+		{expr: `(repeat "ab" 1.5)`, errMsg: paramTypeErrMsg},
+		{expr: `(count name)`, errMsg: paramTypeErrMsg},
+	}
+
+	for _, c := range testCases {
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.want, c.expr)
+	}
+
+	// the signatures are declared for the type checking
+	assertEquals(t, cc.OperatorSignatures["repeat"].String(), "(string, int64) string")
+	assertEquals(t, cc.OperatorSignatures["sum"].String(), "(number...) float64")
+	tc := CopyCompileConfig(cc)
+	tc.CompileOptions[TypeCheck] = true
+	tc.SelectorTypes["name"] = TypeString
+	_, err := Compile(tc, `(repeat name "2")`)
+	assertErrStrContains(t, err, "type check error, repeat param 1 should be int64, got: string")
+
+	// invalid functions
+	assertErrStrContains(t, cc.RegisterFunc("has_prefix", strings.HasSuffix), "operator already exist")
+	assertErrStrContains(t, cc.RegisterFunc("f1", "abc"), "is not a function")
+	assertErrStrContains(t, cc.RegisterFunc("f2", func(map[string]int) bool { return true }), "param 0 type is unsupported")
+	assertErrStrContains(t, cc.RegisterFunc("f3", func() {}), "should return a value")
+	assertErrStrContains(t, cc.RegisterFunc("f4", func() (int, int) { return 0, 0 }), "should return a value")
+	assertErrStrContains(t, cc.RegisterFunc("f5", func() struct{} { return struct{}{} }), "result type is unsupported")
+}
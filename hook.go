@@ -0,0 +1,138 @@
+package eval
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/larry618/eval/ast"
+)
+
+// EvalHook observes the evaluation of each node of the expressions compiled with
+// CompileConfig.EvalHook, e.g. to collect the latency of the operators.
+// The nodes skipped by short circuit and the branches not taken are not observed.
+//
+// The expressions with a hook are evaluated recursively instead of by the Backend,
+// which is much slower, and the MaxSteps limits the number of the evaluated nodes.
+// The hook should be safe for concurrent use if the expression is evaluated concurrently.
+type EvalHook interface {
+	// BeforeNode is called before the node is evaluated
+	BeforeNode(n HookNode)
+	// AfterNode is called after the node is evaluated,
+	// the elapsed time includes the evaluation of the children
+	AfterNode(n HookNode, res Value, err error, elapsed time.Duration)
+}
+
+// HookNode identifies the node observed by the EvalHook
+type HookNode struct {
+	// Index is the index of the node, which is the same as DebugEvent.NodeIdx
+	Index int
+	Kind  ast.Kind
+	// Value is the name of the operator or selector, or the constant value
+	Value Value
+	// Ctx is the ctx of the evaluation, which tells the concurrent evaluations apart,
+	// it may wrap the one passed to Eval, but their Ctx.Ctx are the same
+	Ctx *Ctx
+	// ShortCircuit is only set in AfterNode, it's true if the node is an and/or operator
+	// decided before all its operands are evaluated
+	ShortCircuit bool
+}
+
+// hookEvaluator evaluates the expression recursively and reports the nodes to the hook
+type hookEvaluator struct {
+	e     *Expr
+	ctx   *Ctx
+	done  <-chan struct{}
+	steps int
+}
+
+func (e *Expr) evalHooked(ctx *Ctx) (Value, error) {
+	h := &hookEvaluator{e: e, ctx: ctx}
+	if ctx != nil && ctx.Ctx != nil {
+		h.done = ctx.Ctx.Done()
+	}
+	return checkMissingResult(h.eval(0))
+}
+
+func (h *hookEvaluator) eval(idx int) (Value, error) {
+	e := h.e
+	h.steps++
+	if e.maxSteps > 0 && h.steps > e.maxSteps {
+		return nil, fmt.Errorf("%w, max steps: %d", ErrStepLimitExceeded, e.maxSteps)
+	}
+	if h.done != nil && h.steps%cancelCheckInterval == 0 {
+		select {
+		case <-h.done:
+			return nil, h.ctx.Ctx.Err()
+		default:
+		}
+	}
+
+	n := e.getNode(idx)
+	if size := len(e.nodes); e.nodes[0].getNodeType() == debug && idx >= size/2 {
+		// the children of the fast operators refer to the real nodes directly in the debug mode
+		idx -= size / 2
+	}
+	hn := HookNode{Index: idx, Kind: publicKind(n), Value: n.value, Ctx: h.ctx}
+
+	e.hook.BeforeNode(hn)
+	start := time.Now()
+	res, sc, err := h.evalNode(n)
+	hn.ShortCircuit = sc
+	e.hook.AfterNode(hn, res, err, time.Since(start))
+	return res, err
+}
+
+// evalNode evaluates the node, the short circuit follows the same rules as the interpreter,
+// sc is true if the and/or operator is decided before all its operands are evaluated
+func (h *hookEvaluator) evalNode(n *node) (res Value, sc bool, err error) {
+	e := h.e
+	switch n.getNodeType() {
+	case constant:
+		return n.value, false, nil
+	case selector:
+		res, err = e.getSelectorValue(h.ctx, n)
+		return res, false, err
+	}
+
+	children := make([]int, 0, n.childCnt)
+	for i := 0; i < int(n.childCnt); i++ {
+		if idx := int(n.childIdx) + i; e.getNode(idx).getNodeType() != end {
+			children = append(children, idx)
+		}
+	}
+
+	if n.getNodeType() == cond {
+		c, err := h.eval(children[0])
+		if err != nil {
+			return nil, false, err
+		}
+		condRes, ok := c.(bool)
+		if !ok {
+			return nil, false, condTypeError(c)
+		}
+		branch := children[2]
+		if condRes {
+			branch = children[1]
+		}
+		res, err = h.eval(branch)
+		return res, false, err
+	}
+
+	params := make([]Value, len(children))
+	for i, child := range children {
+		v, err := h.eval(child)
+		if err != nil {
+			return nil, false, err
+		}
+		if b, ok := v.(bool); ok && shortCircuits(n, i, len(children), b) {
+			return b, i != len(children)-1, nil
+		}
+		params[i] = v
+	}
+
+	res, err = n.operator(h.ctx, params)
+	if err != nil {
+		return nil, false, fmt.Errorf("operator execution error, operator: %v, error: %w", n.value, err)
+	}
+	return res, false, nil
+}
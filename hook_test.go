@@ -0,0 +1,149 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingHook struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (h *recordingHook) BeforeNode(n HookNode) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, fmt.Sprintf("before %d %s %v", n.Index, n.Kind, n.Value))
+}
+
+func (h *recordingHook) AfterNode(n HookNode, res Value, err error, elapsed time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if elapsed < 0 {
+		panic("negative elapsed time")
+	}
+	if err != nil {
+		h.events = append(h.events, fmt.Sprintf("after %d error", n.Index))
+		return
+	}
+	if n.ShortCircuit {
+		h.events = append(h.events, fmt.Sprintf("after %d %v (short circuit)", n.Index, FormatValue(res)))
+		return
+	}
+	h.events = append(h.events, fmt.Sprintf("after %d %v", n.Index, FormatValue(res)))
+}
+
+func TestEvalHook(t *testing.T) {
+	const exprStr = `(or (> age 18) (if vip (= country "US") false))`
+	vals := map[string]interface{}{"age": 16, "vip": true, "country": "US"}
+	want := []string{
+		`before 0 operator or`,
+		`before 1 operator >`,
+		`before 3 selector age`,
+		`after 3 16`,
+		`before 4 constant 18`,
+		`after 4 18`,
+		`after 1 false`,
+		`before 2 cond if`,
+		`before 5 selector vip`,
+		`after 5 true`,
+		`before 6 operator =`,
+		`before 9 selector country`,
+		`after 9 "US"`,
+		`before 10 constant US`,
+		`after 10 "US"`,
+		`after 6 true`,
+		`after 2 true`,
+		`after 0 true`,
+	}
+
+	testCases := []struct {
+		name string
+		opts []CompileOption
+	}{
+		{name: "bytecode"},
+		{name: "closure", opts: []CompileOption{func(c *CompileConfig) { c.Backend = ClosureBackend }}},
+		{name: "memoize", opts: []CompileOption{EnableSelectorMemoization}},
+		{name: "debug", opts: []CompileOption{EnableDebug, func(c *CompileConfig) { c.DebugWriter = io.Discard }}},
+	}
+
+	for _, c := range testCases {
+		hook := &recordingHook{}
+		cc := NewCompileConfig(append(c.opts, Optimizations(false), EnableStringSelectors)...)
+		cc.EvalHook = hook
+		expr, err := Compile(cc, exprStr)
+		assertNil(t, err, c.name)
+
+		res, err := expr.Eval(NewCtxWithMap(cc, vals))
+		assertNil(t, err, c.name)
+		assertEquals(t, res, true, c.name)
+		assertEquals(t, strings.Join(hook.events, "\n"), strings.Join(want, "\n"), c.name)
+	}
+}
+
+func TestEvalHook_ShortCircuit(t *testing.T) {
+	hook := &recordingHook{}
+	cc := NewCompileConfig(EnableStringSelectors, Optimizations(false))
+	cc.EvalHook = &ctxCheckingHook{EvalHook: hook, t: t}
+
+	expr, err := Compile(cc, `(and (> age 18) vip)`)
+	assertNil(t, err)
+	ctx := NewCtxWithMap(cc, map[string]interface{}{"age": 16})
+	cc.EvalHook.(*ctxCheckingHook).ctx = ctx
+
+	res, err := expr.Eval(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, false)
+	assertEquals(t, hook.events[len(hook.events)-1], "after 0 false (short circuit)")
+}
+
+// ctxCheckingHook checks that the nodes carry the ctx of the evaluation
+type ctxCheckingHook struct {
+	EvalHook
+	t   *testing.T
+	ctx *Ctx
+}
+
+func (h *ctxCheckingHook) BeforeNode(n HookNode) {
+	assertEquals(h.t, n.Ctx == h.ctx, true)
+	h.EvalHook.BeforeNode(n)
+}
+
+func TestEvalHook_Errors(t *testing.T) {
+	hook := &recordingHook{}
+	cc := NewCompileConfig(EnableStringSelectors, LimitSteps(3))
+	cc.EvalHook = hook
+
+	expr, err := Compile(cc, `(+ age 1)`)
+	assertNil(t, err)
+	_, err = expr.Eval(NewCtxWithMap(cc, map[string]interface{}{"age": "1"}))
+	assertErrStrContains(t, err, "operator: +")
+	assertEquals(t, hook.events[len(hook.events)-1], "after 0 error")
+
+	expr, err = Compile(cc, `(+ age 1 2)`)
+	assertNil(t, err)
+	_, err = expr.Eval(NewCtxWithMap(cc, map[string]interface{}{"age": 1}))
+	assertEquals(t, errors.Is(err, ErrStepLimitExceeded), true)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	cc = NewCompileConfig(EnableStringSelectors)
+	cc.EvalHook = hook
+	expr, err = Compile(cc, `(+ age `+strings.Repeat("1 ", cancelCheckInterval)+`)`)
+	assertNil(t, err)
+	_, err = expr.Eval(&Ctx{Selector: NewMapSelector(map[string]interface{}{"age": 1}), Ctx: canceled})
+	assertEquals(t, errors.Is(err, context.Canceled), true)
+
+	// the hook is installed to the derived expressions
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	loaded, err := UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+	assertEquals(t, loaded.hook, EvalHook(hook))
+}
@@ -0,0 +1,589 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// extra token types of the infix syntax
+	op       tokenType = "op"
+	comma    tokenType = "comma"
+	lBracket tokenType = "lBracket"
+	rBracket tokenType = "rBracket"
+	lBrace   tokenType = "lBrace"
+	rBrace   tokenType = "rBrace"
+	eof      tokenType = "eof"
+)
+
+type infixOp struct {
+	name       string // name of the builtin operator
+	precedence int
+	// whether `a op b op c` can be merged into `(op a b c)`
+	chainable bool
+}
+
+var (
+	infixBinaryOps = map[string]infixOp{
+		"||":  {name: "or", precedence: 1, chainable: true},
+		"or":  {name: "or", precedence: 1, chainable: true},
+		"&&":  {name: "and", precedence: 2, chainable: true},
+		"and": {name: "and", precedence: 2, chainable: true},
+		"==":  {name: "eq", precedence: 3},
+		"!=":  {name: "ne", precedence: 3},
+		"<":   {name: "lt", precedence: 4},
+		"<=":  {name: "le", precedence: 4},
+		">":   {name: "gt", precedence: 4},
+		">=":  {name: "ge", precedence: 4},
+		"in":  {name: "in", precedence: 4},
+		"+":   {name: "add", precedence: 5, chainable: true},
+		"-":   {name: "sub", precedence: 5, chainable: true},
+		"|":   {name: "bitor", precedence: 5, chainable: true},
+		"^":   {name: "bitxor", precedence: 5, chainable: true},
+		"*":   {name: "mul", precedence: 6, chainable: true},
+		"/":   {name: "div", precedence: 6, chainable: true},
+		"%":   {name: "mod", precedence: 6, chainable: true},
+		"&":   {name: "bitand", precedence: 6, chainable: true},
+		"<<":  {name: "<<", precedence: 6},
+		">>":  {name: ">>", precedence: 6},
+	}
+
+	infixUnaryOps = map[string]string{
+		"!":   "not",
+		"not": "not",
+		"-":   "sub",
+	}
+
+	// longer operators must be placed in front of their prefixes
+	infixOpSymbols = []string{
+		"||", "&&", "==", "!=", "<=", ">=", "<<", ">>",
+		"<", ">", "+", "-", "*", "/", "%", "!", "&", "|", "^",
+		"?", ":",
+	}
+)
+
+func (p *parser) lexInfix() error {
+	cel := p.conf.SyntaxMode == CELSyntax
+
+	var (
+		isIdentRune = func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_'
+		}
+
+		// the dot-path of the nested fields, e.g. user.address.city,
+		// and the optional chaining, e.g. user?.address?.city
+		isFieldPath = func(A []rune, j int) bool {
+			if !cel && j+2 < len(A) && A[j] == '?' && A[j+1] == '.' {
+				return unicode.IsLetter(A[j+2]) || A[j+2] == '_'
+			}
+			return j+1 < len(A) && A[j] == '.' && (unicode.IsLetter(A[j+1]) || A[j+1] == '_')
+		}
+
+		lexPunct = func(A []rune, i int) (token, int) {
+			typ, exist := map[rune]tokenType{
+				'(': lParen,
+				')': rParen,
+				'[': lBracket,
+				']': rBracket,
+				'{': lBrace,
+				'}': rBrace,
+				',': comma,
+			}[A[i]]
+			if !exist {
+				return token{}, i
+			}
+			return token{typ: typ, val: string(A[i])}, i + 1
+		}
+
+		lexOp = func(A []rune, i int) (token, int) {
+			if cel && A[i] == '.' {
+				// member access of the CEL syntax, e.g. "abc".size()
+				return token{typ: op, val: "."}, i + 1
+			}
+			s := string(A[i:min(i+2, len(A))])
+			for _, sym := range infixOpSymbols {
+				if strings.HasPrefix(s, sym) {
+					return token{typ: op, val: sym}, i + len([]rune(sym))
+				}
+			}
+			return token{}, i
+		}
+
+		lexInteger = func(A []rune, i int) (token, int) {
+			j := i
+			for ; j < len(A) && unicode.IsDigit(A[j]); j++ {
+			}
+			if j == i {
+				return token{}, i
+			}
+
+			// the fraction and exponent of float literals, e.g. 1.5, 2e10
+			k := j
+			if k+1 < len(A) && A[k] == '.' && unicode.IsDigit(A[k+1]) {
+				for k++; k < len(A) && unicode.IsDigit(A[k]); k++ {
+				}
+			}
+			if k < len(A) && (A[k] == 'e' || A[k] == 'E') {
+				e := k + 1
+				if e < len(A) && (A[e] == '+' || A[e] == '-') {
+					e++
+				}
+				if e < len(A) && unicode.IsDigit(A[e]) {
+					for k = e; k < len(A) && unicode.IsDigit(A[k]); k++ {
+					}
+				}
+			}
+			if !cel && k < len(A) && A[k] == 'd' && (k+1 == len(A) || !isIdentRune(A[k+1])) &&
+				!strings.ContainsAny(string(A[i:k]), "eE") {
+				// the decimal literals, e.g. 19.99d
+				return token{typ: decimal, val: string(A[i:k])}, k + 1
+			}
+			if k != j {
+				return token{typ: float, val: string(A[i:k])}, k
+			}
+			if cel && j < len(A) && (A[j] == 'u' || A[j] == 'U') {
+				// the unsigned integers of CEL are int64 as well, e.g. 1u
+				return token{typ: integer, val: string(A[i:j])}, j + 1
+			}
+			return token{typ: integer, val: string(A[i:j])}, j
+		}
+
+		lexStr = func(A []rune, i int) (token, int) {
+			quote := A[i]
+			if quote != '"' && !(cel && quote == '\'') {
+				return token{}, i
+			}
+			for j := i + 1; j < len(A); j++ {
+				if A[j] == quote {
+					return token{typ: str, val: string(A[i+1 : j])}, j + 1
+				}
+			}
+			return token{}, i
+		}
+
+		lexIdent = func(A []rune, i int) (token, int) {
+			if !unicode.IsLetter(A[i]) && A[i] != '_' {
+				return token{}, i
+			}
+			j := i
+			for ; j < len(A) && (isIdentRune(A[j]) || isFieldPath(A, j)); j++ {
+			}
+			if cel && j < len(A) && A[j] == '(' && !p.isModuleOperator(string(A[i:j])) {
+				// the method call of the CEL syntax, e.g. name.startsWith("L")
+				// is split into the receiver, the member access and the method,
+				// unless it's the function call of a module operator, e.g. geo.distance(a, b)
+				for k := j - 1; k > i; k-- {
+					if A[k] == '.' {
+						j = k
+						break
+					}
+				}
+			}
+			s := string(A[i:j])
+			if cel {
+				if s == "in" {
+					return token{typ: op, val: s}, j
+				}
+				// the other word operators are not keywords of CEL
+				return token{typ: ident, val: s}, j
+			}
+			if _, isBuiltin := builtinOperators[s]; isBuiltin && j < len(A) && A[j] == '(' {
+				// function call syntax of the builtin operators, e.g. in(x, [1, 2])
+				return token{typ: ident, val: s}, j
+			}
+			if _, isWordOp := infixBinaryOps[s]; isWordOp {
+				return token{typ: op, val: s}, j
+			}
+			if _, isWordOp := infixUnaryOps[s]; isWordOp {
+				return token{typ: op, val: s}, j
+			}
+			return token{typ: ident, val: s}, j
+		}
+
+		lexComment = func(A []rune, i int) (token, int) {
+			if A[i] != ';' {
+				return token{}, i
+			}
+			j := i
+			for ; j < len(A) && A[j] != '\n'; j++ {
+			}
+			return token{typ: comment, val: string(A[i:j])}, j + 1
+		}
+
+		lexers = []func([]rune, int) (token, int){
+			lexComment,
+			lexPunct,
+			lexInteger,
+			lexStr,
+			lexIdent,
+			lexOp,
+		}
+	)
+
+	var tokens []token
+	A := []rune(p.source)
+	for i := 0; i < len(A); {
+		if unicode.IsSpace(A[i]) {
+			i++
+			continue
+		}
+
+		found := false
+		for _, lexer := range lexers {
+			t, j := lexer(A, i)
+			if i != j {
+				found = true
+				t.pos = i
+				tokens = append(tokens, t)
+				i = j
+				break
+			}
+		}
+		if !found {
+			return p.errWithPos(errors.New("can not parse token"), i)
+		}
+	}
+	p.tokens = tokens
+	return nil
+}
+
+func (p *parser) parseInfixAstTree() (*astNode, error) {
+	n := 0
+	for _, t := range p.tokens {
+		if t.typ != comment {
+			p.tokens[n] = t
+			n++
+		}
+	}
+	p.tokens = p.tokens[:n]
+
+	if n == 0 {
+		return nil, errors.New("invalid expression error, expression is empty")
+	}
+
+	// append an eof token, so that peek never goes out of range
+	p.tokens = append(p.tokens, token{typ: eof, pos: len([]rune(p.source)) - 1})
+
+	root, err := p.parseInfixConditional()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.typ != eof {
+		return nil, p.invalidExprErr(t.pos)
+	}
+	return root, nil
+}
+
+// parseInfixConditional parses the conditional expression, e.g. age >= 18 ? "adult" : "child",
+// it has the lowest precedence and is right-associative, so a ? b : c ? d : e is a ? b : (c ? d : e)
+func (p *parser) parseInfixConditional() (*astNode, error) {
+	condition, err := p.parseInfixExpression(0)
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.typ != op || t.val != "?" {
+		return condition, nil
+	}
+	p.walk()
+
+	then, err := p.parseInfixConditional()
+	if err != nil {
+		return nil, err
+	}
+	if colon := p.next(); colon.typ != op || colon.val != ":" {
+		return nil, p.errWithToken(fmt.Errorf("token unexpected error (want: :, got: %s)", colon.val), colon)
+	}
+	otherwise, err := p.parseInfixConditional()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.buildKeywordNode(token{typ: ident, val: "if", pos: t.pos}, []*astNode{condition, then, otherwise})
+}
+
+// parseInfixExpression parses binary expressions by precedence climbing,
+// only the operators whose precedence is higher than minPrecedence are consumed.
+func (p *parser) parseInfixExpression(minPrecedence int) (*astNode, error) {
+	lhs, err := p.parseInfixUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		if t.typ != op {
+			return lhs, nil
+		}
+		bop, exist := infixBinaryOps[t.val]
+		width := 1
+		if next := p.tokens[p.idx+1]; t.val == "not" && next.typ == op && next.val == "in" {
+			// x not in list
+			bop, exist, width = infixOp{name: "not_in", precedence: infixBinaryOps["in"].precedence}, true, 2
+		}
+		if !exist || bop.precedence <= minPrecedence {
+			return lhs, nil
+		}
+		p.idx += width
+
+		rhs, err := p.parseInfixExpression(bop.precedence)
+		if err != nil {
+			return nil, err
+		}
+
+		// all the chainable operators are left-associative
+		// so (a - b) - c can be merged into (- a b c)
+		if bop.chainable &&
+			lhs.node.getNodeType() == operator &&
+			lhs.node.value == bop.name &&
+			len(lhs.children) < math.MaxInt8 {
+			lhs.children = append(lhs.children, rhs)
+			continue
+		}
+
+		lhs, err = p.buildNode(token{typ: ident, val: bop.name, pos: t.pos}, []*astNode{lhs, rhs})
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseInfixUnary() (*astNode, error) {
+	t := p.peek()
+	if t.typ != op {
+		return p.parseInfixPostfix()
+	}
+
+	name, exist := infixUnaryOps[t.val]
+	if !exist {
+		return nil, p.invalidExprErr(t.pos)
+	}
+	p.walk()
+
+	// negative number literal
+	if name == "sub" && p.peek().typ == integer {
+		num := p.next()
+		v, err := p.intLiteral("-"+num.val, num)
+		if err != nil {
+			return nil, err
+		}
+		return p.valNodeAt(v, t), nil
+	}
+	if name == "sub" && p.peek().typ == float {
+		num := p.next()
+		v, err := strconv.ParseFloat("-"+num.val, 64)
+		if err != nil {
+			return nil, p.errWithToken(err, num)
+		}
+		return p.valNodeAt(v, t), nil
+	}
+	if name == "sub" && p.peek().typ == decimal {
+		n, err := p.parseDecimal()
+		if err != nil {
+			return nil, err
+		}
+		n.node.value, n.pos = n.node.value.(Decimal).neg(), t.pos
+		return n, nil
+	}
+
+	operand, err := p.parseInfixUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []*astNode{operand}
+	if name == "sub" {
+		// -x => (- 0 x)
+		children = []*astNode{p.valNode(int64(0)), operand}
+	}
+	return p.buildNode(token{typ: ident, val: name, pos: t.pos}, children)
+}
+
+// parseInfixPostfix parses the primary expression followed by the indexing, e.g. tags[0], scores["math"]
+func (p *parser) parseInfixPostfix() (*astNode, error) {
+	n, err := p.parseInfixPrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if t := p.peek(); t.typ == op && t.val == "." {
+			if n, err = p.parseCELMember(n); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.peek().typ != lBracket {
+			return n, nil
+		}
+
+		t := p.next()
+		key, err := p.parseInfixConditional()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.eat(rBracket); err != nil {
+			return nil, err
+		}
+		n, err = p.buildNode(token{typ: ident, val: "index", pos: t.pos}, []*astNode{n, key})
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseInfixPrimary() (*astNode, error) {
+	t := p.peek()
+	switch t.typ {
+	case lParen:
+		p.walk()
+		n, err := p.parseInfixConditional()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.eat(rParen); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case lBracket:
+		return p.parseInfixList()
+	case lBrace:
+		return p.parseInfixMap()
+	case integer:
+		return p.parseInt()
+	case float:
+		return p.parseFloat()
+	case decimal:
+		return p.parseDecimal()
+	case str:
+		return p.parseStr()
+	case ident:
+		if p.tokens[p.idx+1].typ == lParen {
+			return p.parseInfixCall()
+		}
+		fns := []func() (*astNode, error){p.parseConst, p.parseSelector, p.parseFieldSelector, p.parseUnknownSelector}
+		for _, fn := range fns {
+			n, err := fn()
+			if n != nil || err != nil {
+				return n, err
+			}
+		}
+	}
+	return nil, p.invalidExprErr(t.pos)
+}
+
+// parseInfixCall parses the function call syntax, e.g. between(age, 18, 80)
+func (p *parser) parseInfixCall() (*astNode, error) {
+	car := p.next()
+	p.walk() // skip the left parenthesis
+
+	var children []*astNode
+	for p.peek().typ != rParen {
+		if len(children) != 0 {
+			if err := p.eat(comma); err != nil {
+				return nil, err
+			}
+		}
+		child, err := p.parseInfixConditional()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	p.walk()
+
+	if p.conf.SyntaxMode == CELSyntax {
+		return p.buildCELCall(car, children)
+	}
+	if p.isKeyword(car) {
+		return p.buildKeywordNode(car, children)
+	}
+	return p.buildNode(car, children)
+}
+
+// parseInfixList parses the list literal, e.g. [1, 2, 3] or ["a", b],
+// it's a constant if all the elements are constants, otherwise it's built at runtime.
+func (p *parser) parseInfixList() (*astNode, error) {
+	start := p.next() // skip the left bracket
+
+	elems, err := p.parseInfixElements(rBracket)
+	if err != nil {
+		return nil, err
+	}
+	return p.buildCollectionNode(start, "list", elems)
+}
+
+// parseInfixMap parses the map literal, e.g. {"a": 1, "b": x},
+// the keys should be string literals.
+func (p *parser) parseInfixMap() (*astNode, error) {
+	start := p.next() // skip the left brace
+
+	var pairs []*astNode
+	for p.peek().typ != rBrace {
+		if len(pairs) != 0 {
+			if err := p.eat(comma); err != nil {
+				return nil, err
+			}
+		}
+
+		key := p.next()
+		if key.typ != str {
+			return nil, p.tokenTypeError(str, key)
+		}
+		if colon := p.next(); colon.typ != op || colon.val != ":" {
+			return nil, p.errWithToken(fmt.Errorf("token unexpected error (want: :, got: %s)", colon.val), colon)
+		}
+		val, err := p.parseInfixConditional()
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p.valNodeAt(key.val, key), val)
+	}
+	p.walk()
+
+	return p.buildCollectionNode(start, "dict", pairs)
+}
+
+// parseInfixElements parses the comma separated expressions until the end token
+func (p *parser) parseInfixElements(end tokenType) ([]*astNode, error) {
+	var elems []*astNode
+	for p.peek().typ != end {
+		if len(elems) != 0 {
+			if err := p.eat(comma); err != nil {
+				return nil, err
+			}
+		}
+		elem, err := p.parseInfixConditional()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+	p.walk()
+	return elems, nil
+}
+
+// buildCollectionNode builds the list or map with the operator of the name,
+// it's evaluated at compile time if all the params are constants.
+func (p *parser) buildCollectionNode(start token, name string, params []*astNode) (*astNode, error) {
+	consts := make([]Value, len(params))
+	for i, param := range params {
+		if param.node.getNodeType() != constant {
+			return p.buildNode(token{typ: ident, val: name, pos: start.pos}, params)
+		}
+		consts[i] = param.node.value
+	}
+
+	op, _ := p.conf.getBuiltinOperator(name)
+	v, err := op(nil, consts)
+	if err != nil {
+		return nil, p.errWithToken(err, start)
+	}
+	return p.valNodeAt(v, start), nil
+}
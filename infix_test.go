@@ -0,0 +1,270 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestParseInfix(t *testing.T) {
+	testCases := []struct {
+		cc     *CompileConfig
+		infix  string
+		prefix string
+		errMsg string
+	}{
+		{
+			infix:  `1 + 1`,
+			prefix: `(add 1 1)`,
+		},
+		{
+			infix:  `1 + 2 * 3 - 4`,
+			prefix: `(sub (add 1 (mul 2 3)) 4)`,
+		},
+		{
+			infix:  `1 - 2 - 3 - 4`,
+			prefix: `(sub 1 2 3 4)`,
+		},
+		{
+			infix:  `(1 + 2) * (3 - -4) % 5`,
+			prefix: `(mod (mul (add 1 2) (sub 3 -4)) 5)`,
+		},
+		{
+			infix:  `age > 18 && country == "US"`,
+			prefix: `(and (gt age 18) (eq country "US"))`,
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `a || b && !c or not d and e`,
+			prefix: `(or a (and b (not c)) (and (not d) e))`,
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `-v1 * 2 <= 10 != true`,
+			prefix: `(ne (le (mul (sub 0 v1) 2) 10) true)`,
+			cc: &CompileConfig{
+				SelectorMap: map[string]SelectorKey{
+					"v1": SelectorKey(1),
+				},
+			},
+		},
+		{
+			infix:  `between(age, 18, 80) && in(country, ["US", "CA"]) || overlap([1, -2], [])`,
+			prefix: `(or (and (between age 18 80) (in country ("US" "CA"))) (overlap (1 -2) ()))`,
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `age in [18, 19] && country not in ["US", "CA"] || !(tag in tags)`,
+			prefix: `(or (and (in age (18 19)) (not_in country ("US" "CA"))) (not (in tag tags)))`,
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `if(t_version(app) >= t_version("1.2.3"), 1, 0) ;; comment`,
+			prefix: `(if (ge (t_version app) (t_version "1.2.3")) 1 0)`,
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `a || b ? x + 1 : y > 2 ? -1 : 0`,
+			prefix: `(if (or a b) (add x 1) (if (gt y 2) -1 0))`,
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `(a ? b : c) ? 1 : 2`,
+			prefix: `(if (if a b c) 1 2)`,
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `a ? 1`,
+			errMsg: "token unexpected error (want: :, got: )",
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `a ? 1 , 2`,
+			errMsg: "token unexpected error (want: :, got: ,)",
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `age > 18`,
+			errMsg: "unknown token error",
+		},
+		{
+			infix:  `1 + `,
+			errMsg: "invalid expression error",
+		},
+		{
+			infix:  `(1 + 2`,
+			errMsg: "token type unexpected error",
+		},
+		{
+			infix:  `1 + 2)`,
+			errMsg: "invalid expression error",
+		},
+		{
+			infix:  `[a, 1 + 2][0] == tags[-1 + 1] && {"x": [1], "y": b}["y"]`,
+			prefix: `(and (eq (index (list a (add 1 2)) 0) (index tags (add -1 1))) (index (dict "x" (1) "y" b) "y"))`,
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `-scores["math"] > {"math": pass}["math"]`,
+			prefix: `(gt (sub 0 (index scores "math")) (index (dict "math" pass) "math"))`,
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `{a: 1}`,
+			errMsg: "token type unexpected error (want: str, got: ident)",
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `{"a" 1}`,
+			errMsg: "token unexpected error (want: :, got: 1)",
+		},
+		{
+			infix:  `{"a": 1, "b"}`,
+			errMsg: "token unexpected error (want: :, got: })",
+		},
+		{
+			infix:  `tags[0`,
+			errMsg: "token type unexpected error (want: rBracket, got: eof)",
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `[a, "2", 1.5]`,
+			prefix: `(list a "2" 1.5)`,
+			cc:     NewCompileConfig(EnableStringSelectors),
+		},
+		{
+			infix:  `if(true, 1)`,
+			errMsg: "if parameters count error",
+		},
+		{
+			infix:  `1 # 2`,
+			errMsg: "can not parse token",
+		},
+		{
+			infix:  ``,
+			errMsg: "expression is empty",
+		},
+	}
+
+	for _, c := range testCases {
+		cc := CopyCompileConfig(c.cc)
+		cc.SyntaxMode = InfixSyntax
+		Optimizations(false)(cc)
+
+		got, err := Compile(cc, c.infix)
+		if len(c.errMsg) != 0 {
+			assertErrStrContains(t, err, c.errMsg, c)
+			continue
+		}
+		assertNil(t, err, c)
+
+		cc.SyntaxMode = PrefixSyntax
+		want, err := Compile(cc, c.prefix)
+		assertNil(t, err, c)
+
+		assertEquals(t, Dump(got), Dump(want), c)
+		assertEquals(t, PrintExpr(got), PrintExpr(want), c)
+	}
+}
+
+func TestEvalInfix(t *testing.T) {
+	testCases := []struct {
+		expr string
+		vals map[string]interface{}
+		want Value
+	}{
+		{
+			expr: `age > 18 && country == "US"`,
+			vals: map[string]interface{}{
+				"age":     20,
+				"country": "US",
+			},
+			want: true,
+		},
+		{
+			expr: `(age + 2) * 3 - bonus / 2`,
+			vals: map[string]interface{}{
+				"age":   10,
+				"bonus": 8,
+			},
+			want: int64(32),
+		},
+		{
+			expr: `!(a || b) == !a && !b`,
+			vals: map[string]interface{}{
+				"a": true,
+				"b": false,
+			},
+			want: true,
+		},
+		{
+			expr: `if(age >= 18, "adult", "child")`,
+			vals: map[string]interface{}{
+				"age": 17,
+			},
+			want: "child",
+		},
+		{
+			expr: `age >= 18 ? "adult" : "child"`,
+			vals: map[string]interface{}{
+				"age": 20,
+			},
+			want: "adult",
+		},
+		{
+			expr: `{"US": 18, "JP": 20}[country] <= age && tags[0] == "vip"`,
+			vals: map[string]interface{}{
+				"country": "JP",
+				"age":     20,
+				"tags":    []string{"vip"},
+			},
+			want: true,
+		},
+		{
+			// the lists and maps are compared by their elements
+			expr: `tags == ["a", "b"] && {"a": 1} == {"a": 1} && !(coalesce(tags, ["x"]) != tags) && profile == {"level": 3}`,
+			vals: map[string]interface{}{
+				"tags":    []string{"a", "b"},
+				"profile": map[string]interface{}{"level": 3},
+			},
+			want: true,
+		},
+		{
+			expr: `tags == ["a"] || {"a": 1} == {"a": 2} || profile == {"level": "3"}`,
+			vals: map[string]interface{}{
+				"tags":    []string{"a", "b"},
+				"profile": map[string]interface{}{"level": 3},
+			},
+			want: false,
+		},
+		{
+			expr: `[1, "2", 1.5][2] + [age, 0.5][1] > 1 && [[1], ["a"]] == [[1], ["a"]]`,
+			vals: map[string]interface{}{
+				"age": 20,
+			},
+			want: true,
+		},
+		{
+			expr: `[age, age + 1, 30][1] + profile["level"]`,
+			vals: map[string]interface{}{
+				"age":     20,
+				"profile": map[string]interface{}{"level": 3},
+			},
+			want: int64(24),
+		},
+		{
+			// the untaken branch is not evaluated
+			expr: `b != 0 ? a / b : -1`,
+			vals: map[string]interface{}{
+				"a": 10,
+				"b": 0,
+			},
+			want: int64(-1),
+		},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(RegisterSelKeys(c.vals), EnableInfixSyntax)
+		got, err := Eval(c.expr, c.vals, cc)
+		assertNil(t, err, c)
+		assertEquals(t, got, c.want, c)
+	}
+}
@@ -0,0 +1,347 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathElem is an element of the json path, either an object key or an array index
+type jsonPathElem struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// jsonPath is the parsed json path, e.g. $.user.tags[0] => [user tags 0]
+type jsonPath []jsonPathElem
+
+// parseJSONPath parses the subset of JSONPath which selects a single value:
+// the root $ followed by the keys .name or ['name'], and the array indexes [0],
+// the negative indexes count from the end, e.g. $.items[-1] is the last item.
+// e.g. $, $.user.name, $.items[0].price, $['user name']
+func parseJSONPath(s string) (jsonPath, error) {
+	invalid := fmt.Errorf("invalid json path: %q", s)
+	if !strings.HasPrefix(s, "$") {
+		return nil, invalid
+	}
+
+	var path jsonPath
+	for i := 1; i < len(s); {
+		switch s[i] {
+		case '.':
+			j := i + 1
+			for j < len(s) && s[j] != '.' && s[j] != '[' {
+				j++
+			}
+			if j == i+1 {
+				return nil, invalid
+			}
+			path = append(path, jsonPathElem{key: s[i+1 : j]})
+			i = j
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end == -1 {
+				return nil, invalid
+			}
+			inner := s[i+1 : i+end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				path = append(path, jsonPathElem{key: inner[1 : len(inner)-1]})
+			} else {
+				idx, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, invalid
+				}
+				path = append(path, jsonPathElem{index: idx, isIndex: true})
+			}
+			i += end + 1
+		default:
+			return nil, invalid
+		}
+	}
+	return path, nil
+}
+
+// get returns the value of the path in the decoded document, and whether the value exists
+func (p jsonPath) get(v Value) (Value, bool) {
+	for _, e := range p {
+		var exist bool
+		if e.isIndex {
+			v, exist = jsonIndex(v, e.index)
+		} else {
+			v, exist = jsonKey(v, e.key)
+		}
+		if !exist {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func jsonKey(v Value, key string) (Value, bool) {
+	switch m := v.(type) {
+	case map[string]Value:
+		res, exist := m[key]
+		return res, exist
+	case map[string]interface{}:
+		res, exist := m[key]
+		return unifyType(res), exist
+	}
+	return nil, false
+}
+
+func jsonIndex(v Value, i int) (Value, bool) {
+	var size int
+	switch l := v.(type) {
+	case []Value:
+		size = len(l)
+	case []interface{}:
+		size = len(l)
+	case []int64:
+		size = len(l)
+	case []string:
+		size = len(l)
+	default:
+		return nil, false
+	}
+	if i < 0 {
+		i += size
+	}
+	if i < 0 || i >= size {
+		return nil, false
+	}
+
+	switch l := v.(type) {
+	case []Value:
+		return l[i], true
+	case []interface{}:
+		return unifyType(l[i]), true
+	case []int64:
+		return l[i], true
+	default:
+		return v.([]string)[i], true
+	}
+}
+
+// decodeJSON decodes the json document to the types of the operands:
+// the integers are int64 and the other numbers are float64,
+// the arrays of integers or strings are []int64 or []string, the other arrays are []Value,
+// and the objects are map[string]Value
+func decodeJSON(data []byte) (Value, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, fmt.Errorf("invalid json: unexpected data after the top-level value")
+	}
+	return jsonValue(v), nil
+}
+
+func jsonValue(v interface{}) Value {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]interface{}:
+		res := make(map[string]Value, len(t))
+		for k, e := range t {
+			res[k] = jsonValue(e)
+		}
+		return res
+	case []interface{}:
+		res := make([]Value, len(t))
+		for i, e := range t {
+			res[i] = jsonValue(e)
+		}
+		return unifyJSONList(res)
+	}
+	return v
+}
+
+// unifyJSONList converts the non-empty list of integers or strings to []int64 or []string,
+// so that they can be used by the list operators, e.g. (in "vip" (jsonGet user "$.tags"))
+func unifyJSONList(l []Value) Value {
+	if len(l) == 0 {
+		return l
+	}
+	switch l[0].(type) {
+	case int64:
+		res := make([]int64, len(l))
+		for i, e := range l {
+			v, ok := e.(int64)
+			if !ok {
+				return l
+			}
+			res[i] = v
+		}
+		return res
+	case string:
+		res := make([]string, len(l))
+		for i, e := range l {
+			v, ok := e.(string)
+			if !ok {
+				return l
+			}
+			res[i] = v
+		}
+		return res
+	}
+	return l
+}
+
+// jsonPathCache caches the json paths which are not constants
+var jsonPathCache = newLRUCache(1024)
+
+func compileJSONPath(s string) (jsonPath, error) {
+	if p, exist := jsonPathCache.get(s); exist {
+		return p.(jsonPath), nil
+	}
+	p, err := parseJSONPath(s)
+	if err != nil {
+		return nil, err
+	}
+	jsonPathCache.add(s, p)
+	return p, nil
+}
+
+// jsonGet returns the value of the json path in the document, or null if it doesn't exist,
+// e.g. (jsonGet payload "$.items[0].price"), see parseJSONPath for the syntax.
+// The document is a json string, or a value which has been decoded, e.g. the values of JSONSelector.
+func jsonGet(_ *Ctx, params []Value) (Value, error) {
+	const op = "jsonGet"
+	if len(params) != 2 {
+		return nil, ParamsCountError(op, 2, len(params))
+	}
+	s, ok := params[1].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, params[1])
+	}
+	p, err := compileJSONPath(s)
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	return jsonGetPath(op, params[0], p)
+}
+
+// specializeJSONGet parses the constant json path only once at compile time
+func specializeJSONGet(consts []Value, known []bool) (Operator, error) {
+	const op = "jsonGet"
+	if len(consts) != 2 || !known[1] {
+		return nil, nil
+	}
+	s, ok := consts[1].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, consts[1])
+	}
+	p, err := parseJSONPath(s)
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	return func(_ *Ctx, params []Value) (Value, error) {
+		if len(params) != 2 {
+			return nil, ParamsCountError(op, 2, len(params))
+		}
+		return jsonGetPath(op, params[0], p)
+	}, nil
+}
+
+func jsonGetPath(op string, doc Value, p jsonPath) (Value, error) {
+	var data []byte
+	switch d := doc.(type) {
+	case string:
+		data = []byte(d)
+	case []byte:
+		data = d
+	}
+	if data != nil {
+		v, err := decodeJSON(data)
+		if err != nil {
+			return nil, OpExecError(op, err)
+		}
+		doc = v
+	}
+	res, _ := p.get(doc)
+	return res, nil
+}
+
+// JSONSelector is a Selector which gets the values from a json document,
+// the document is decoded only once when the first value is got.
+// The selector names are the keys of the top-level object, e.g. user, whose nested fields can be accessed
+// by the dot syntax, e.g. user.name. The names can also be the json paths, e.g. $.items[0].price,
+// see parseJSONPath for the syntax.
+type JSONSelector struct {
+	doc     []byte
+	root    Value
+	err     error
+	decoded bool
+	// paths caches the parsed json paths by the selector names
+	paths map[string]jsonPath
+	// values set by Set, they take precedence over the values of the document
+	overrides map[string]Value
+}
+
+// NewJSONSelector creates a JSONSelector of the unparsed json document
+func NewJSONSelector(doc []byte) *JSONSelector {
+	return &JSONSelector{
+		doc:       doc,
+		paths:     make(map[string]jsonPath),
+		overrides: make(map[string]Value),
+	}
+}
+
+// NewCtxWithJSON creates a Ctx with a JSONSelector of the json document,
+// the expression should be compiled with EnableStringSelectors if the selectors are not registered
+func NewCtxWithJSON(doc []byte) *Ctx {
+	return &Ctx{
+		Selector: NewJSONSelector(doc),
+	}
+}
+
+func (s *JSONSelector) Get(_ SelectorKey, key string) (Value, error) {
+	if val, exist := s.overrides[key]; exist {
+		return val, nil
+	}
+	if !s.decoded {
+		s.root, s.err = decodeJSON(s.doc)
+		s.decoded = true
+	}
+	if s.err != nil {
+		return nil, fmt.Errorf("decode json error, selector: %s, error: %w", key, s.err)
+	}
+
+	p, cached := s.paths[key]
+	if !cached {
+		path := key
+		if !strings.HasPrefix(path, "$") {
+			path = "$." + path
+		}
+		var err error
+		if p, err = parseJSONPath(path); err != nil {
+			return nil, err
+		}
+		s.paths[key] = p
+	}
+	res, exist := p.get(s.root)
+	if !exist {
+		return nil, fmt.Errorf("%w %s", ErrSelectorNotExist, key)
+	}
+	return res, nil
+}
+
+func (s *JSONSelector) Set(_ SelectorKey, key string, val Value) error {
+	s.overrides[key] = val
+	return nil
+}
+
+func (s *JSONSelector) Cached(_ SelectorKey, key string) bool {
+	_, exist := s.overrides[key]
+	return exist
+}
@@ -0,0 +1,126 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestJSONGet(t *testing.T) {
+	vals := map[string]interface{}{
+		"payload": `{"user": {"name": "larry", "age": 30, "score": 9.5, "tags": ["vip", "beta"], "vip": true},
+			"items": [{"price": 10, "sku": "a"}, {"price": 25.5, "sku": "b"}], "ids": [1, 2, 3], "mixed": [1, "a"],
+			"empty": [], "nil": null, "user name": "l"}`,
+		"decoded": map[string]interface{}{"user": map[string]interface{}{"age": 30}},
+		"path":    "$.user.name",
+		"invalid": `{"user":`,
+		"id":      7,
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(jsonGet payload "$.user.name")`, res: "larry"},
+		{expr: `(jsonGet payload "$.user.age")`, res: int64(30)},
+		{expr: `(jsonGet payload "$.user.score")`, res: 9.5},
+		{expr: `(jsonGet payload "$.user.vip")`, res: true},
+		{expr: `(jsonGet payload "$.user.tags")`, res: []string{"vip", "beta"}},
+		{expr: `(jsonGet payload "$.user.tags[0]")`, res: "vip"},
+		{expr: `(jsonGet payload "$.user.tags[-1]")`, res: "beta"},
+		{expr: `(jsonGet payload "$.items[1].price")`, res: 25.5},
+		{expr: `(jsonGet payload "$['items'][0]['sku']")`, res: "a"},
+		{expr: `(jsonGet payload "$['user name']")`, res: "l"},
+		{expr: `(jsonGet payload "$.ids")`, res: []int64{1, 2, 3}},
+		{expr: `(jsonGet payload "$.mixed")`, res: []Value{int64(1), "a"}},
+		{expr: `(jsonGet payload "$.empty")`, res: []Value{}},
+		{expr: `(jsonGet payload "$.nil")`, res: nil},
+		{expr: `(jsonGet payload "$.user.missing")`, res: nil},
+		{expr: `(jsonGet payload "$.ids[3]")`, res: nil},
+		{expr: `(jsonGet payload "$.user.name.first")`, res: nil},
+		{expr: `(jsonGet payload path)`, res: "larry"},
+		{expr: `(jsonGet decoded "$.user.age")`, res: int64(30)},
+		{expr: `(jsonGet (jsonGet payload "$.user") "$.age")`, res: int64(30)},
+		{expr: `(in "vip" (jsonGet payload "$.user.tags"))`, res: true},
+		{expr: `(> (jsonGet payload "$.items[0].price") 5)`, res: true},
+		{expr: `(jsonGet "[1, 2]" "$[1]")`, res: int64(2)},
+		{expr: `(jsonGet payload "$.user.name")`, opts: []CompileOption{EnableTypeCheck}, res: "larry"},
+
+		{expr: `(jsonGet payload "user.name")`, errMsg: `invalid json path: "user.name"`},
+		{expr: `(jsonGet payload "$.items[a]")`, errMsg: `invalid json path: "$.items[a]"`},
+		{expr: `(jsonGet payload "$.items[0")`, errMsg: `invalid json path: "$.items[0"`},
+		{expr: `(jsonGet payload "$..name")`, errMsg: `invalid json path: "$..name"`},
+		{expr: `(jsonGet payload id)`, errMsg: "unexpected param type, operator: jsonGet, expected: string"},
+		{expr: `(jsonGet invalid "$.user")`, errMsg: "operator: jsonGet, error: unexpected EOF"},
+		{expr: `(jsonGet "1 2" "$")`, errMsg: "unexpected data after the top-level value"},
+		{expr: `(jsonGet payload)`, errMsg: "unexpected params count, operator: jsonGet, expected: 2, got: 1"},
+		{expr: `(jsonGet payload 1)`, opts: []CompileOption{EnableTypeCheck}, errMsg: "jsonGet param 1 should be string, got: int"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+}
+
+func TestJSONSelector(t *testing.T) {
+	doc := []byte(`{"user": {"name": "larry", "age": 30, "tags": ["vip"]}, "items": [{"price": 10}], "a.b": 1}`)
+	cc := NewCompileConfig(EnableStringSelectors)
+
+	testCases := []struct {
+		expr   string
+		res    Value
+		errMsg string
+	}{
+		{expr: `(= user.name "larry")`, res: true},
+		{expr: `(and (>= user.age 18) (in "vip" user.tags))`, res: true},
+		{expr: `(jsonGet user "$.name")`, res: "larry"},
+		{expr: `(len user.tags)`, res: int64(1)},
+		{expr: `user.missing`, errMsg: "field not found: missing"},
+		{expr: `missing`, errMsg: "selectorKey not exist missing"},
+	}
+
+	for _, c := range testCases {
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err, c.expr)
+		res, err := expr.Eval(NewCtxWithJSON(doc))
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+
+	sel := NewJSONSelector(doc)
+	res, err := sel.Get(UndefinedSelKey, "$.items[0].price")
+	assertNil(t, err)
+	assertEquals(t, res, int64(10))
+	res, err = sel.Get(UndefinedSelKey, "$['a.b']")
+	assertNil(t, err)
+	assertEquals(t, res, int64(1))
+	_, err = sel.Get(UndefinedSelKey, "items[")
+	assertErrStrContains(t, err, `invalid json path: "$.items["`)
+
+	// the values set take precedence over the document
+	assertEquals(t, sel.Cached(UndefinedSelKey, "user.name"), false)
+	assertNil(t, sel.Set(UndefinedSelKey, "user.name", "lucy"))
+	assertEquals(t, sel.Cached(UndefinedSelKey, "user.name"), true)
+	res, err = sel.Get(UndefinedSelKey, "user.name")
+	assertNil(t, err)
+	assertEquals(t, res, "lucy")
+
+	_, err = NewJSONSelector([]byte(`{`)).Get(UndefinedSelKey, "user")
+	assertErrStrContains(t, err, "decode json error, selector: user, error: unexpected EOF")
+}
+
+func TestSpecializeJSONGet(t *testing.T) {
+	_, err := Compile(NewCompileConfig(RegisterSelKeys(map[string]interface{}{"doc": ""})), `(jsonGet doc "$.a[")`)
+	assertErrStrContains(t, err, `invalid json path: "$.a["`)
+}
@@ -0,0 +1,334 @@
+// Package jsonlogic converts the JsonLogic rules (https://jsonlogic.com) to the expressions
+// in the prefix notation, and vice versa.
+//
+//	expr, err := jsonlogic.FromJsonLogic([]byte(`{"and": [{">": [{"var": "age"}, 18]}, {"==": [{"var": "country"}, "US"]}]}`))
+//	// (and (> age 18) (= country "US"))
+//
+// The common operations are mapped to the builtin operators, e.g. == to =, ! to not,
+// "<=" with three params to between, and "var" to the selectors, the dot-paths of "var"
+// are kept, which access the nested fields of the selector values.
+// The "in" operation with a string constant as the second param is mapped to the contains operator
+// of the ops/strings module, otherwise it's mapped to the in operator of the lists.
+// The other operations are kept as the operators of the same names,
+// which should be registered to the CompileConfig before the expressions are compiled.
+// The number literals are the floats in JsonLogic, the integral ones are imported as int64
+// except the params of / and %, which are imported as float64 to keep the float division,
+// so the int64 values of the selectors are promoted to float64 when they are divided by the constants.
+// The array operations like map, filter and reduce, and the default values of "var" are not supported.
+package jsonlogic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/larry618/eval"
+	"github.com/larry618/eval/ast"
+)
+
+// the JsonLogic operations mapped to the operators of the different names
+var (
+	fromOps = map[string]string{
+		"==":  "=",
+		"===": "=",
+		"!=":  "!=",
+		"!==": "!=",
+		"!":   "not",
+	}
+	toOps = map[string]string{
+		"=":   "==",
+		"eq":  "==",
+		"ne":  "!=",
+		"not": "!",
+		"gt":  ">",
+		"lt":  "<",
+		"ge":  ">=",
+		"le":  "<=",
+		"add": "+",
+		"sub": "-",
+		"mul": "*",
+		"div": "/",
+		"mod": "%",
+		"&":   "and",
+		"|":   "or",
+	}
+	unsupportedOps = map[string]bool{
+		"map":    true,
+		"filter": true,
+		"reduce": true,
+		"all":    true,
+		"some":   true,
+		"none":   true,
+		"merge":  true,
+	}
+)
+
+// FromJsonLogic converts the JsonLogic rule to the expression in the prefix notation
+func FromJsonLogic(doc []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var rule interface{}
+	if err := dec.Decode(&rule); err != nil {
+		return "", fmt.Errorf("jsonlogic error, invalid json: %w", err)
+	}
+
+	root, err := fromRule(rule)
+	if err != nil {
+		return "", fmt.Errorf("jsonlogic error, %w", err)
+	}
+	return root.String(), nil
+}
+
+func fromRule(rule interface{}) (*ast.Node, error) {
+	m, ok := rule.(map[string]interface{})
+	if !ok {
+		return fromLiteral(rule)
+	}
+	if len(m) != 1 {
+		return nil, fmt.Errorf("operation should have exactly one key, got: %d", len(m))
+	}
+
+	var op string
+	var args []interface{}
+	for k, v := range m {
+		op = k
+		if arr, ok := v.([]interface{}); ok {
+			args = arr
+		} else {
+			// the unary syntax sugar, e.g. {"var": "a"}
+			args = []interface{}{v}
+		}
+	}
+
+	if unsupportedOps[op] {
+		return nil, fmt.Errorf("unsupported operation: %s", op)
+	}
+	if op == "var" {
+		return fromVar(args)
+	}
+
+	params := make([]*ast.Node, len(args))
+	for i, arg := range args {
+		var err error
+		if params[i], err = fromRule(arg); err != nil {
+			return nil, err
+		}
+	}
+
+	switch op {
+	case "if", "?:":
+		return fromIf(params)
+	case "!!":
+		if len(params) != 1 {
+			return nil, fmt.Errorf("!! should have 1 param, got: %d", len(params))
+		}
+		return operator("not", operator("not", params[0])), nil
+	case "<", "<=":
+		if len(params) == 3 {
+			if op == "<=" {
+				// a <= b <= c
+				return operator("between", params[1], params[0], params[2]), nil
+			}
+			return operator("and",
+				operator(op, params[0], params[1]),
+				operator(op, params[1], params[2])), nil
+		}
+	case "-":
+		if len(params) == 1 {
+			return operator("-", constant(int64(0)), params[0]), nil
+		}
+	case "/", "%":
+		// the arithmetic of JsonLogic is float, e.g. {"/": [3, 2]} is 1.5
+		for _, p := range params {
+			if i, ok := p.Value.(int64); ok && p.Kind == ast.Constant {
+				p.Value = float64(i)
+			}
+		}
+	case "in":
+		if len(params) == 2 && params[1].Kind == ast.Constant {
+			if _, ok := params[1].Value.(string); ok {
+				// the substring, which is provided by the strings module
+				return operator("contains", params[1], params[0]), nil
+			}
+		}
+	}
+
+	if name, exist := fromOps[op]; exist {
+		op = name
+	}
+	return operator(op, params...), nil
+}
+
+func fromVar(args []interface{}) (*ast.Node, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("the default value of var is not supported")
+	}
+	var name string
+	switch v := args[0].(type) {
+	case string:
+		name = v
+	case json.Number:
+		name = v.String()
+	default:
+		return nil, fmt.Errorf("invalid var: %v", args[0])
+	}
+	if !validName(name) {
+		return nil, fmt.Errorf("unsupported var name: %q", name)
+	}
+	return &ast.Node{Kind: ast.Selector, Value: name}, nil
+}
+
+func fromIf(params []*ast.Node) (*ast.Node, error) {
+	switch {
+	case len(params) < 3 || len(params)%2 == 0:
+		return nil, fmt.Errorf("if should have the odd number of params not less than 3, got: %d", len(params))
+	case len(params) == 3:
+		return &ast.Node{Kind: ast.Cond, Value: "if", Children: params}, nil
+	}
+	// if c1 v1 c2 v2 else => if c1 v1 (if c2 v2 else)
+	rest, err := fromIf(params[2:])
+	if err != nil {
+		return nil, err
+	}
+	return &ast.Node{Kind: ast.Cond, Value: "if", Children: []*ast.Node{params[0], params[1], rest}}, nil
+}
+
+func fromLiteral(v interface{}) (*ast.Node, error) {
+	switch val := v.(type) {
+	case bool:
+		return constant(val), nil
+	case string:
+		if strings.ContainsRune(val, '"') {
+			return nil, fmt.Errorf("unsupported string literal: %q", val)
+		}
+		return constant(val), nil
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return constant(i), nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %s", val)
+		}
+		return constant(f), nil
+	case []interface{}:
+		return fromArray(val)
+	case nil:
+		return constant(nil), nil
+	}
+	return nil, fmt.Errorf("unsupported literal: %v", v)
+}
+
+// fromArray converts the array of the literals to a list,
+// the list of the other values is built by the list operator
+func fromArray(arr []interface{}) (*ast.Node, error) {
+	ints := make([]int64, 0, len(arr))
+	strs := make([]string, 0, len(arr))
+	items := make([]*ast.Node, len(arr))
+	for i, item := range arr {
+		n, err := fromRule(item)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = n
+		switch c := n.Value.(type) {
+		case int64:
+			ints = append(ints, c)
+		case string:
+			if n.Kind == ast.Constant {
+				strs = append(strs, c)
+			}
+		}
+	}
+
+	switch {
+	case len(arr) == 0:
+		return constant([]string{}), nil
+	case len(ints) == len(arr):
+		return constant(ints), nil
+	case len(strs) == len(arr):
+		return constant(strs), nil
+	}
+	return operator("list", items...), nil
+}
+
+// ToJsonLogic converts the expression in the prefix notation to the JsonLogic rule
+func ToJsonLogic(expr string) ([]byte, error) {
+	root, err := eval.Parse(eval.NewCompileConfig(), expr)
+	if err != nil {
+		return nil, fmt.Errorf("jsonlogic error, %w", err)
+	}
+	rule, err := toRule(root)
+	if err != nil {
+		return nil, fmt.Errorf("jsonlogic error, %w", err)
+	}
+	return json.Marshal(rule)
+}
+
+func toRule(n *ast.Node) (interface{}, error) {
+	switch n.Kind {
+	case ast.Constant:
+		switch v := n.Value.(type) {
+		case map[string]eval.Value:
+			return nil, fmt.Errorf("unsupported constant: %s", eval.FormatValue(v))
+		}
+		return n.Value, nil
+	case ast.Selector:
+		return map[string]interface{}{"var": n.Value}, nil
+	}
+
+	args := make([]interface{}, len(n.Children))
+	for i, child := range n.Children {
+		var err error
+		if args[i], err = toRule(child); err != nil {
+			return nil, err
+		}
+	}
+
+	op := fmt.Sprint(n.Value)
+	switch op {
+	case "between":
+		if len(args) == 3 {
+			return map[string]interface{}{"<=": []interface{}{args[1], args[0], args[2]}}, nil
+		}
+	case "contains":
+		if len(args) == 2 {
+			return map[string]interface{}{"in": []interface{}{args[1], args[0]}}, nil
+		}
+	case "not_in":
+		return map[string]interface{}{"!": map[string]interface{}{"in": args}}, nil
+	case "list":
+		return args, nil
+	}
+
+	if name, exist := toOps[op]; exist {
+		op = name
+	}
+	return map[string]interface{}{op: args}, nil
+}
+
+func operator(name string, params ...*ast.Node) *ast.Node {
+	return &ast.Node{Kind: ast.Operator, Value: name, Children: params}
+}
+
+func constant(v interface{}) *ast.Node {
+	return &ast.Node{Kind: ast.Constant, Value: v}
+}
+
+// validName reports whether the var name can be a selector, e.g. user.address.city
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_' || r == '.' && i != 0 && i != len(name)-1:
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i != 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,233 @@
+package jsonlogic_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval"
+	"github.com/larry618/eval/jsonlogic"
+	evalstrings "github.com/larry618/eval/ops/strings"
+)
+
+func TestFromJsonLogic(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":          int64(20),
+		"vip":          true,
+		"country":      "US",
+		"score":        7.5,
+		"tags":         []string{"a", "b"},
+		"name":         "Larry Page",
+		"user.address": map[string]interface{}{"city": "NY"},
+	}
+
+	testCases := []struct {
+		rule   string
+		want   string
+		result eval.Value
+		errMsg string
+	}{
+		{
+			rule:   `{"and": [{">": [{"var": "age"}, 18]}, {"==": [{"var": "country"}, "US"]}]}`,
+			want:   `(and (> age 18) (= country "US"))`,
+			result: true,
+		},
+		{
+			rule:   `{"or": [{"!": {"var": "vip"}}, {"!==": [{"var": "country"}, "CN"]}]}`,
+			want:   `(or (not vip) (!= country "CN"))`,
+			result: true,
+		},
+		{
+			rule:   `{"<=": [18, {"var": "age"}, 60]}`,
+			want:   `(between age 18 60)`,
+			result: true,
+		},
+		{
+			rule:   `{"<": [18, {"var": "age"}, 20]}`,
+			want:   `(and (< 18 age) (< age 20))`,
+			result: false,
+		},
+		{
+			rule:   `{"if": [{">": [{"var": "age"}, 60]}, "senior", {">": [{"var": "age"}, 18]}, "adult", "minor"]}`,
+			want:   `(if (> age 60) "senior" (if (> age 18) "adult" "minor"))`,
+			result: "adult",
+		},
+		{
+			rule:   `{"!=": [{"var": "name"}, null]}`,
+			want:   `(!= name null)`,
+			result: true,
+		},
+		{
+			rule:   `{"in": [{"var": "country"}, ["US", "CA"]]}`,
+			want:   `(in country ("US" "CA"))`,
+			result: true,
+		},
+		{
+			rule:   `{"in": [{"var": "country"}, "US,CA"]}`,
+			want:   `(contains "US,CA" country)`,
+			result: true,
+		},
+		{
+			rule:   `{"in": [3, [1, 2, 3]]}`,
+			want:   `(in 3 (1 2 3))`,
+			result: true,
+		},
+		{
+			rule:   `{"+": [{"var": "score"}, 1.5, {"-": {"var": "age"}}]}`,
+			want:   `(+ score 1.5 (- 0 age))`,
+			result: -11.0,
+		},
+		{
+			rule:   `{"/": [3, 2]}`,
+			want:   `(/ 3.0 2.0)`,
+			result: 1.5,
+		},
+		{
+			rule:   `{"%": [{"var": "age"}, 3]}`,
+			want:   `(% age 3.0)`,
+			result: 2.0,
+		},
+		{
+			rule:   `{">": [{"/": [{"var": "age"}, 8]}, 2]}`,
+			want:   `(> (/ age 8.0) 2)`,
+			result: true,
+		},
+		{
+			rule:   `{"==": [{"var": "score"}, 7.5]}`,
+			want:   `(= score 7.5)`,
+			result: true,
+		},
+		{
+			rule:   `{"+": [3.0, 1e2]}`,
+			want:   `(+ 3.0 100.0)`,
+			result: 103.0,
+		},
+		{
+			rule:   `{"!!": [{"var": "tags"}]}`,
+			want:   `(not (not tags))`,
+			errMsg: "operator execution error",
+		},
+		{
+			rule: `{"==": [{"var": "user.address.city"}, "NY"]}`,
+			want: `(= user.address.city "NY")`,
+		},
+		{rule: `true`, want: `true`, result: true},
+
+		{rule: `{"var": ["age", 18]}`, errMsg: "default value of var"},
+		{rule: `{"var": "a b"}`, errMsg: "unsupported var name"},
+		{rule: `{"map": [{"var": "tags"}, {"var": ""}]}`, errMsg: "unsupported operation: map"},
+		{rule: `{"==": [{"var": "name"}, "a\"b"]}`, errMsg: "unsupported string literal"},
+		{rule: `{"if": [true, 1]}`, errMsg: "if should have the odd number of params"},
+		{rule: `{"and": [true], "or": [false]}`, errMsg: "exactly one key"},
+		{rule: `{"and": `, errMsg: "invalid json"},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.rule, func(t *testing.T) {
+			got, err := jsonlogic.FromJsonLogic([]byte(c.rule))
+			if c.want == "" {
+				if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+					t.Fatalf("want error containing %q, got: %v", c.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.want {
+				t.Fatalf("want: %s, got: %s", c.want, got)
+			}
+			if c.result == nil && c.errMsg == "" {
+				return
+			}
+
+			cc := eval.NewCompileConfig(eval.EnableStringSelectors)
+			if err := evalstrings.Register(cc); err != nil {
+				t.Fatal(err)
+			}
+			res, err := eval.Eval(got, vals, cc)
+			if c.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+					t.Fatalf("want error containing %q, got: %v", c.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(res, c.result) {
+				t.Fatalf("want result: %v, got: %v", c.result, res)
+			}
+		})
+	}
+}
+
+func TestToJsonLogic(t *testing.T) {
+	testCases := []struct {
+		expr   string
+		want   string
+		errMsg string
+	}{
+		{
+			expr: `(and (> age 18) (= country "US"))`,
+			want: `{"and": [{">": [{"var": "age"}, 18]}, {"==": [{"var": "country"}, "US"]}]}`,
+		},
+		{
+			expr: `(if (not vip) (between age 18 60) false)`,
+			want: `{"if": [{"!": [{"var": "vip"}]}, {"<=": [18, {"var": "age"}, 60]}, false]}`,
+		},
+		{
+			expr: `(not_in country ("CN" "RU"))`,
+			want: `{"!": {"in": [{"var": "country"}, ["CN", "RU"]]}}`,
+		},
+		{
+			expr: `(contains name "Page")`,
+			want: `{"in": ["Page", {"var": "name"}]}`,
+		},
+		{
+			expr: `(in 3 (list a 2))`,
+			want: `{"in": [3, [{"var": "a"}, 2]]}`,
+		},
+		{
+			expr: `(mul score 1.5)`,
+			want: `{"*": [{"var": "score"}, 1.5]}`,
+		},
+		{expr: `(> age`, errMsg: "jsonlogic error"},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			got, err := jsonlogic.ToJsonLogic(c.expr)
+			if c.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+					t.Fatalf("want error containing %q, got: %v", c.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertJSONEqual(t, c.want, got)
+
+			// the rule can be converted back to an equivalent expression
+			if _, err := jsonlogic.FromJsonLogic(got); err != nil {
+				t.Fatalf("round trip error: %v", err)
+			}
+		})
+	}
+}
+
+func assertJSONEqual(t *testing.T, want string, got []byte) {
+	t.Helper()
+	var w, g interface{}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(w, g) {
+		t.Fatalf("want: %s, got: %s", want, got)
+	}
+}
@@ -0,0 +1,368 @@
+package eval
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/larry618/eval/ast"
+)
+
+// The higher-order operators evaluate a lambda for each element of a list,
+// e.g. (any cart.items item (= item.category "books")), the param item is bound to
+// the elements within the body, and the other selectors of the body are read from the ctx.
+//   - any, all, none: whether the body is true for any, all or none of the elements
+//   - count: the number of the elements for which the body is true
+//   - filter: the elements for which the body is true
+//   - map: the results of the body
+//
+// The let binding evaluates the value once and binds the param to it within the body, e.g.
+// (let (total (+ price tax)) (and (> total 100) (< total 500))), the value is kept
+// on the operand stack as the param of the let operator while the body is evaluated.
+//
+// The list can be []int64, []string or any other slice, and null is an empty list.
+// The body is compiled to a separate expression with the same config, whose nodes are
+// not reported to the EvalHook. The param can also be written as a string, e.g. "item",
+// which is the form of the decompiled expressions.
+var (
+	higherOrderOperators = map[string]Operator{
+		"any":    higherOrder{mode: anyMatch}.execute,
+		"all":    higherOrder{mode: allMatch}.execute,
+		"none":   higherOrder{mode: noneMatch}.execute,
+		"count":  higherOrder{mode: countMatch}.execute,
+		"filter": higherOrder{mode: filterList}.execute,
+		"map":    higherOrder{mode: mapList}.execute,
+		"let":    higherOrder{mode: letBinding}.execute,
+	}
+
+	higherOrderSignatures = map[string]Signature{
+		"any":    {Params: []Type{TypeAny, TypeString, TypeBool}, Result: TypeBool},
+		"all":    {Params: []Type{TypeAny, TypeString, TypeBool}, Result: TypeBool},
+		"none":   {Params: []Type{TypeAny, TypeString, TypeBool}, Result: TypeBool},
+		"count":  {Params: []Type{TypeAny, TypeString, TypeBool}, Result: TypeInt},
+		"filter": {Params: []Type{TypeAny, TypeString, TypeBool}, Result: TypeAny},
+		"map":    {Params: []Type{TypeAny, TypeString, TypeAny}, Result: TypeAny},
+		// the result of let is the type of the body
+		"let": {Params: []Type{TypeAny, TypeString, TypeAny}, Result: TypeAny},
+	}
+)
+
+// isHigherOrder reports whether the operator is a higher-order one,
+// which can be overridden by the operators of the same name registered to the cc
+func isHigherOrder(cc *CompileConfig, name string) bool {
+	_, exist := higherOrderOperators[name]
+	_, overridden := cc.OperatorMap[name]
+	return exist && !overridden
+}
+
+// typeLambda is the type of the serialized lambdas
+const typeLambda = "lambda"
+
+// lambda is the compiled body of a higher-order operator
+type lambda struct {
+	param string
+	body  *Expr
+}
+
+func (l *lambda) String() string {
+	return l.body.Decompile()
+}
+
+// call evaluates the body with the param bound to the value
+func (l *lambda) call(ctx *Ctx, val Value) (Value, error) {
+	sel := &bindingSelector{name: l.param, val: val}
+	c := &Ctx{Selector: sel}
+	if ctx != nil {
+		sel.parent, c.Ctx, c.urls = ctx.Selector, ctx.Ctx, ctx.urls
+	}
+	return l.body.Eval(c)
+}
+
+// lambdaParam returns the param of the higher-order operator whose body isn't compiled yet
+func lambdaParam(cc *CompileConfig, root *astNode) (string, bool) {
+	n := root.node
+	if n.getNodeType() != operator || len(root.children) != 3 {
+		return "", false
+	}
+	if name, ok := n.value.(string); !ok || !isHigherOrder(cc, name) {
+		return "", false
+	}
+	param, body := root.children[1].node, root.children[2].node
+	if _, compiled := body.value.(*lambda); compiled && body.getNodeType() == constant {
+		return "", false
+	}
+	name, ok := param.value.(string)
+	return name, ok && param.getNodeType() == constant
+}
+
+// publicLambdaParam returns the param of the higher-order operator in the public syntax tree
+func publicLambdaParam(cc *CompileConfig, root *ast.Node) (string, bool) {
+	if root.Kind != ast.Operator || len(root.Children) != 3 || root.Children[1] == nil {
+		return "", false
+	}
+	if name, ok := root.Value.(string); !ok || !isHigherOrder(cc, name) {
+		return "", false
+	}
+	name, ok := root.Children[1].Value.(string)
+	return name, ok && root.Children[1].Kind == ast.Constant
+}
+
+func isBound(bindings []string, name string) bool {
+	for _, b := range bindings {
+		if b == name {
+			return true
+		}
+	}
+	return false
+}
+
+// compileLambdas compiles the bodies of the higher-order operators to the lambdas in place,
+// e.g. (any items "item" (= item.category "books")), the nested ones are compiled with the bodies
+func compileLambdas(conf *CompileConfig, root *astNode) error {
+	param, ok := lambdaParam(conf, root)
+	if !ok {
+		for _, child := range root.children {
+			if err := compileLambdas(conf, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := compileLambdas(conf, root.children[0]); err != nil {
+		return err
+	}
+	bodyConf := *conf
+	bodyConf.EvalHook = nil
+	body, err := compileAstTree(&bodyConf, root.children[2])
+	if err != nil {
+		return err
+	}
+	root.children[2] = &astNode{
+		node: &node{
+			flag:  constant,
+			value: &lambda{param: param, body: body},
+		},
+		pos: root.children[2].pos,
+	}
+	return nil
+}
+
+// lambdas returns the lambdas of the higher-order operators of the expression
+func (e *Expr) lambdas() []*lambda {
+	size := len(e.nodes)
+	if size != 0 && e.nodes[0].getNodeType() == debug {
+		size /= 2
+	}
+
+	var res []*lambda
+	for i := 0; i < size; i++ {
+		if l, ok := e.getNode(i).value.(*lambda); ok {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// freeSelectorNodes returns the selector nodes of the expression and its lambdas,
+// the params of the lambdas are excluded
+func (e *Expr) freeSelectorNodes() []*node {
+	res := e.selectorNodes()
+	for _, l := range e.lambdas() {
+		for _, n := range l.body.freeSelectorNodes() {
+			if n.value != l.param {
+				res = append(res, n)
+			}
+		}
+	}
+	return res
+}
+
+// bindingSelector binds the param of the lambda to the element,
+// the other selectors are read from the parent
+type bindingSelector struct {
+	parent Selector
+	name   string
+	val    Value
+}
+
+func (s *bindingSelector) Get(key SelectorKey, strKey string) (Value, error) {
+	if strKey == s.name {
+		return s.val, nil
+	}
+	if s.parent == nil {
+		return nil, fmt.Errorf("%w %s", ErrSelectorNotExist, strKey)
+	}
+	return s.parent.Get(key, strKey)
+}
+
+func (s *bindingSelector) Set(key SelectorKey, strKey string, val Value) error {
+	if strKey == s.name {
+		s.val = val
+		return nil
+	}
+	if s.parent == nil {
+		return fmt.Errorf("%w %s", ErrSelectorNotExist, strKey)
+	}
+	return s.parent.Set(key, strKey, val)
+}
+
+func (s *bindingSelector) Cached(key SelectorKey, strKey string) bool {
+	if strKey == s.name {
+		return true
+	}
+	return s.parent != nil && s.parent.Cached(key, strKey)
+}
+
+type higherOrder struct {
+	mode mode
+}
+
+func (h higherOrder) execute(ctx *Ctx, params []Value) (Value, error) {
+	op := modeNames[h.mode]
+	if len(params) != 3 {
+		return nil, ParamsCountError(op, 3, len(params))
+	}
+	fn, ok := params[2].(*lambda)
+	if !ok {
+		return nil, ParamTypeError(op, "lambda", params[2])
+	}
+	if h.mode == letBinding {
+		return fn.call(ctx, params[0])
+	}
+	size, at, err := listElems(op, params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		sel = &bindingSelector{name: fn.param}
+		c   = &Ctx{Selector: sel}
+
+		cnt    int64
+		kept   []int
+		mapped []Value
+	)
+	if ctx != nil {
+		sel.parent, c.Ctx, c.urls = ctx.Selector, ctx.Ctx, ctx.urls
+	}
+	if h.mode == mapList {
+		mapped = make([]Value, 0, size)
+	}
+
+	for i := 0; i < size; i++ {
+		sel.val = at(i)
+		res, err := fn.body.Eval(c)
+		if err != nil {
+			return nil, OpExecError(op, fmt.Errorf("element %d error: %w", i, err))
+		}
+		if h.mode == mapList {
+			mapped = append(mapped, res)
+			continue
+		}
+
+		b, ok := res.(bool)
+		if !ok {
+			return nil, OpExecError(op, fmt.Errorf("lambda result should be bool, element: %d, got: %v", i, res))
+		}
+		switch {
+		case b && h.mode == anyMatch:
+			return true, nil
+		case !b && h.mode == allMatch:
+			return false, nil
+		case b && h.mode == noneMatch:
+			return false, nil
+		case b:
+			cnt++
+			kept = append(kept, i)
+		}
+	}
+
+	switch h.mode {
+	case anyMatch:
+		return false, nil
+	case allMatch, noneMatch:
+		return true, nil
+	case countMatch:
+		return cnt, nil
+	case filterList:
+		return filterElems(params[0], kept, at), nil
+	}
+	return narrowList(mapped), nil
+}
+
+// listElems returns the length of the list and the getter of its elements
+func listElems(op string, list Value) (int, func(int) Value, error) {
+	switch l := list.(type) {
+	case nil:
+		return 0, nil, nil
+	case []int64:
+		return len(l), func(i int) Value { return l[i] }, nil
+	case []string:
+		return len(l), func(i int) Value { return l[i] }, nil
+	case []Value:
+		return len(l), func(i int) Value { return l[i] }, nil
+	case []interface{}:
+		return len(l), func(i int) Value { return l[i] }, nil
+	}
+
+	rv := reflect.ValueOf(list)
+	if kind := rv.Kind(); kind != reflect.Slice && kind != reflect.Array {
+		return 0, nil, ParamTypeError(op, "list", list)
+	}
+	return rv.Len(), func(i int) Value { return rv.Index(i).Interface() }, nil
+}
+
+// filterElems returns the kept elements in a list of the same type if it's []int64 or []string
+func filterElems(list Value, kept []int, at func(int) Value) Value {
+	switch l := list.(type) {
+	case []int64:
+		res := make([]int64, len(kept))
+		for j, i := range kept {
+			res[j] = l[i]
+		}
+		return res
+	case []string:
+		res := make([]string, len(kept))
+		for j, i := range kept {
+			res[j] = l[i]
+		}
+		return res
+	}
+
+	res := make([]Value, len(kept))
+	for j, i := range kept {
+		res[j] = at(i)
+	}
+	return res
+}
+
+// narrowList converts the values to []int64 or []string if they are all int64 or string,
+// the empty list is a string list, the same as the empty list literal
+func narrowList(vals []Value) Value {
+	if len(vals) == 0 {
+		return []string{}
+	}
+	switch vals[0].(type) {
+	case int64:
+		res := make([]int64, len(vals))
+		for i, v := range vals {
+			n, ok := v.(int64)
+			if !ok {
+				return vals
+			}
+			res[i] = n
+		}
+		return res
+	case string:
+		res := make([]string, len(vals))
+		for i, v := range vals {
+			s, ok := v.(string)
+			if !ok {
+				return vals
+			}
+			res[i] = s
+		}
+		return res
+	}
+	return vals
+}
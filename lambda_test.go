@@ -0,0 +1,211 @@
+package eval
+
+import (
+	"io"
+	"testing"
+
+	"github.com/larry618/eval/ast"
+)
+
+type cartItem struct {
+	Category string `json:"category"`
+	Price    int64  `json:"price"`
+}
+
+func TestHigherOrder(t *testing.T) {
+	vals := map[string]interface{}{
+		"items": []cartItem{
+			{Category: "books", Price: 30},
+			{Category: "games", Price: 60},
+			{Category: "books", Price: 15},
+		},
+		"tags":      []string{"vip", "new"},
+		"scores":    []int64{3, 8, 5},
+		"orders":    []interface{}{map[string]interface{}{"id": 1, "paid": true}, map[string]interface{}{"id": 2, "paid": false}},
+		"threshold": 20,
+		"empty":     nil,
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(any items item (= item.category "books"))`, res: true},
+		{expr: `(any items item (= item.category "toys"))`, res: false},
+		{expr: `(all items item (> item.price 10))`, res: true},
+		{expr: `(all items item (> item.price threshold))`, res: false},
+		{expr: `(none tags tag (= tag "banned"))`, res: true},
+		{expr: `(count items item (> item.price threshold))`, res: int64(2)},
+		{expr: `(filter scores s (> s 4))`, res: []int64{8, 5}},
+		{expr: `(filter tags tag (!= tag "new"))`, res: []string{"vip"}},
+		{expr: `(map items item item.category)`, res: []string{"books", "games", "books"}},
+		{expr: `(map scores s (* s 2))`, res: []int64{6, 16, 10}},
+		{expr: `(map orders o o.paid)`, res: []Value{true, false}},
+		{expr: `(filter orders o o.paid)`, res: []Value{map[string]interface{}{"id": 1, "paid": true}}},
+		{expr: `(any empty x (= x 1))`, res: false},
+		{expr: `(all empty x (= x 1))`, res: true},
+		{expr: `(map empty x x)`, res: []string{}},
+		{expr: `(in "books" (map items "item" item.category))`, res: true},
+		{expr: `(any items item (all tags tag (!= tag item.category)))`, res: true},
+		{expr: `(count (filter scores s (> s 4)) s (> s 6))`, res: int64(1)},
+		{expr: `(any items item (> item.price threshold))`, opts: []CompileOption{Optimizations(false)}, res: true},
+		{expr: `(any items item (> item.price threshold))`, opts: []CompileOption{EnableDebug, func(c *CompileConfig) {
+			c.DebugWriter = io.Discard
+		}}, res: true},
+		{
+			expr: `(any items item (> item.price threshold))`,
+			opts: []CompileOption{func(c *CompileConfig) { c.Backend = ClosureBackend }},
+			res:  true,
+		},
+
+		{expr: `(any items item item.category)`, errMsg: "lambda result should be bool"},
+		{expr: `(any threshold x (= x 1))`, errMsg: "expected: list"},
+		{expr: `(any items item (> item.weight 1))`, errMsg: "element 0 error"},
+		{expr: `(any items item (= item.category "books"))`, opts: []CompileOption{EnableTypeCheck}, res: true},
+		{expr: `(any items item item.price)`, opts: []CompileOption{EnableTypeCheck}, errMsg: "lambda result should be bool"},
+		{expr: `(any items item 1)`, opts: []CompileOption{EnableTypeCheck}, errMsg: "any param 2 should be bool"},
+		{expr: `(any items item)`, errMsg: "any parameters count error (want: 3, got: 2)"},
+		{expr: `(any items item true false)`, errMsg: "any parameters count error (want: 3, got: 4)"},
+		{expr: `(any items item.category true)`, errMsg: "token type unexpected error"},
+		{expr: `(any items item (= x 1))`, errMsg: "unknown token error"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+}
+
+func TestHigherOrder_Expr(t *testing.T) {
+	vals := map[string]interface{}{
+		"items":     []map[string]interface{}{{"category": "books", "price": 30}},
+		"threshold": 20,
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+	ctx := NewCtxWithMap(cc, vals)
+
+	expr, err := Compile(cc, `(and (> threshold 10) (any items item (> item.price threshold)))`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(and (> threshold 10) (any items "item" (> (field item "price") threshold)))`)
+	assertEquals(t, expr.Selectors(), []string{"threshold", "items"})
+
+	// the decompiled expression can be compiled again
+	decompiled, err := Compile(cc, expr.Decompile())
+	assertNil(t, err)
+
+	// the parsed tree can be rewritten and compiled
+	root, err := Parse(cc, `(any items item (> item.price threshold))`)
+	assertNil(t, err)
+	root, err = ast.Rewrite(root, func(n *ast.Node) (*ast.Node, error) {
+		if n.Kind == ast.Selector && n.Value == "threshold" {
+			return &ast.Node{Kind: ast.Constant, Value: int64(40)}, nil
+		}
+		return n, nil
+	})
+	assertNil(t, err)
+	rewritten, err := CompileAST(cc, root)
+	assertNil(t, err)
+
+	partial, err := expr.PartialEval(map[SelectorKey]Value{cc.SelectorMap["threshold"]: int64(50)})
+	assertNil(t, err)
+	assertEquals(t, partial.Decompile(), `(and true (any items "item" (> (field item "price") 50)))`)
+
+	for _, c := range []struct {
+		expr *Expr
+		res  Value
+	}{
+		{expr: expr, res: true},
+		{expr: decompiled, res: true},
+		{expr: rewritten, res: false},
+		{expr: partial, res: false},
+	} {
+		res, err := c.expr.Eval(ctx)
+		assertNil(t, err)
+		assertEquals(t, res, c.res, c.expr.Decompile())
+	}
+
+	// the operators of the same names registered to the cc are used instead
+	custom := NewCompileConfig(RegisterSelKeys(vals))
+	assertNil(t, RegisterOperator(custom, "count", func(_ *Ctx, params []Value) (Value, error) {
+		return int64(len(params)), nil
+	}))
+	res, err := Eval(`(count 1 2)`, vals, custom)
+	assertNil(t, err)
+	assertEquals(t, res, int64(2))
+}
+
+func TestLet(t *testing.T) {
+	vals := map[string]interface{}{
+		"price": 80,
+		"tax":   30,
+		"name":  "larry",
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(let (total (+ price tax)) (and (> total 100) (< total 500)))`, res: true},
+		{expr: `(let (total (+ price tax)) (if (> total 100) (- total 100) total))`, res: int64(10)},
+		{expr: `(let (x price) (let (y (* x 2)) (+ x y)))`, res: int64(240)},
+		{expr: `(let (price (+ price 1)) price)`, res: int64(81)},
+		{expr: `(let (x 1) (let (x (+ x 1)) x))`, res: int64(2)},
+		{expr: `(let (n name) (= n "larry"))`, opts: []CompileOption{EnableTypeCheck}, res: true},
+		{
+			expr: `(let (total (+ price tax)) (> total 100))`,
+			opts: []CompileOption{func(c *CompileConfig) { c.Backend = ClosureBackend }},
+			res:  true,
+		},
+
+		{expr: `(let (total (+ price tax)) (> total "100"))`, errMsg: "operator: >"},
+		{expr: `(and (let (x price) 1) true)`, opts: []CompileOption{EnableTypeCheck}, errMsg: "and param 0 should be bool, got: int64"},
+		{expr: `(let x 1 x)`, errMsg: "token type unexpected error"},
+		{expr: `(let (x 1))`, errMsg: "let parameters count error"},
+		{expr: `(let (x 1) x 2)`, errMsg: "let parameters count error"},
+		{expr: `(let (x 1) y)`, errMsg: "unknown token error"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+}
+
+func TestLet_Expr(t *testing.T) {
+	var calls int
+	cc := NewCompileConfig(RegisterSelKeys(map[string]interface{}{"price": 1}))
+	assertNil(t, RegisterOperator(cc, "expensive", func(_ *Ctx, params []Value) (Value, error) {
+		calls++
+		return params[0].(int64) * 2, nil
+	}))
+
+	expr, err := Compile(cc, `(let (x (expensive price)) (and (> x 10) (< x 100) (!= x 42)))`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(let (x (expensive price)) (and (> x 10) (< x 100) (!= x 42)))`)
+	assertEquals(t, expr.Selectors(), []string{"price"})
+
+	res, err := expr.Eval(NewCtxWithMap(cc, map[string]interface{}{"price": 20}))
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	assertEquals(t, calls, 1)
+
+	decompiled, err := Compile(cc, expr.Decompile())
+	assertNil(t, err)
+	assertEquals(t, decompiled.Decompile(), expr.Decompile())
+}
@@ -0,0 +1,162 @@
+package eval
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// likeMatches reports whether the string matches the pattern of SQL LIKE, e.g. (like name "foo%"),
+// % matches any sequence of characters, _ matches any character, and \ escapes them, e.g. "100\%".
+// The constant patterns are compiled to the prefix, suffix or contains checks at compile time.
+func likeMatches(_ *Ctx, params []Value) (Value, error) {
+	s, pattern, err := likeParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return compileLike(pattern)(s), nil
+}
+
+// specializeLike compiles the constant pattern only once at compile time
+func specializeLike(consts []Value, known []bool) (Operator, error) {
+	const op = "like"
+	if len(consts) != 2 || !known[1] {
+		return nil, nil
+	}
+	pattern, ok := consts[1].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, consts[1])
+	}
+	match := compileLike(pattern)
+	return func(_ *Ctx, params []Value) (Value, error) {
+		s, _, err := likeParams(params)
+		if err != nil {
+			return nil, err
+		}
+		return match(s), nil
+	}, nil
+}
+
+func likeParams(params []Value) (string, string, error) {
+	const op = "like"
+	if len(params) != 2 {
+		return "", "", ParamsCountError(op, 2, len(params))
+	}
+	s, ok := params[0].(string)
+	if !ok {
+		return "", "", ParamTypeError(op, typeStr, params[0])
+	}
+	pattern, ok := params[1].(string)
+	if !ok {
+		return "", "", ParamTypeError(op, typeStr, params[1])
+	}
+	return s, pattern, nil
+}
+
+// likeElem is an element of the like pattern, which is either a wildcard or a literal
+type likeElem struct {
+	wildcard rune // '%', '_' or 0 for the literal
+	literal  string
+}
+
+// parseLike splits the pattern to the wildcards and the literals between them,
+// the consecutive % are merged, and the escaped wildcards are literals
+func parseLike(pattern string) []likeElem {
+	var (
+		elems []likeElem
+		sb    strings.Builder
+	)
+	flush := func() {
+		if sb.Len() != 0 {
+			elems = append(elems, likeElem{literal: sb.String()})
+			sb.Reset()
+		}
+	}
+
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		i += size
+		switch r {
+		case '\\':
+			if i < len(pattern) {
+				r, size = utf8.DecodeRuneInString(pattern[i:])
+				i += size
+			}
+			sb.WriteRune(r)
+		case '%', '_':
+			flush()
+			if r == '%' && len(elems) != 0 && elems[len(elems)-1].wildcard == '%' {
+				continue
+			}
+			elems = append(elems, likeElem{wildcard: r})
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	flush()
+	return elems
+}
+
+// compileLike returns the matcher of the pattern, the common patterns are
+// matched without the backtracking, e.g. "foo" "foo%" "%foo" "%foo%"
+func compileLike(pattern string) func(s string) bool {
+	elems := parseLike(pattern)
+	isAny := func(i int) bool { return elems[i].wildcard == '%' }
+	isLiteral := func(i int) bool { return elems[i].wildcard == 0 }
+
+	switch {
+	case len(elems) == 0:
+		return func(s string) bool { return s == "" }
+	case len(elems) == 1 && isLiteral(0):
+		lit := elems[0].literal
+		return func(s string) bool { return s == lit }
+	case len(elems) == 1 && isAny(0):
+		return func(string) bool { return true }
+	case len(elems) == 2 && isLiteral(0) && isAny(1):
+		lit := elems[0].literal
+		return func(s string) bool { return strings.HasPrefix(s, lit) }
+	case len(elems) == 2 && isAny(0) && isLiteral(1):
+		lit := elems[1].literal
+		return func(s string) bool { return strings.HasSuffix(s, lit) }
+	case len(elems) == 3 && isAny(0) && isLiteral(1) && isAny(2):
+		lit := elems[1].literal
+		return func(s string) bool { return strings.Contains(s, lit) }
+	}
+	return func(s string) bool { return matchLike(s, elems) }
+}
+
+// matchLike matches the string with the elements, and backtracks to the last % on mismatch
+func matchLike(s string, elems []likeElem) bool {
+	var (
+		si, ei int
+		// the positions to retry after the last %
+		retryS, retryE = 0, -1
+	)
+	for si < len(s) {
+		if ei < len(elems) {
+			switch e := elems[ei]; {
+			case e.wildcard == '%':
+				retryS, retryE = si, ei+1
+				ei++
+				continue
+			case e.wildcard == '_':
+				_, size := utf8.DecodeRuneInString(s[si:])
+				si, ei = si+size, ei+1
+				continue
+			case strings.HasPrefix(s[si:], e.literal):
+				si, ei = si+len(e.literal), ei+1
+				continue
+			}
+		}
+		if retryE == -1 {
+			return false
+		}
+		// the last % matches one more character
+		_, size := utf8.DecodeRuneInString(s[retryS:])
+		retryS += size
+		si, ei = retryS, retryE
+	}
+	for ei < len(elems) && elems[ei].wildcard == '%' {
+		ei++
+	}
+	return ei == len(elems)
+}
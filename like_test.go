@@ -0,0 +1,79 @@
+package eval
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLike(t *testing.T) {
+	vals := map[string]interface{}{
+		"name":    "foobar",
+		"email":   "larry@example.com",
+		"city":    "東京都",
+		"pattern": "foo%",
+		"id":      7,
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(like name "foobar")`, res: true},
+		{expr: `(like name "foo")`, res: false},
+		{expr: `(like name "foo%")`, res: true},
+		{expr: `(like name "%bar")`, res: true},
+		{expr: `(like name "%oba%")`, res: true},
+		{expr: `(like name "%")`, res: true},
+		{expr: `(like name "")`, res: false},
+		{expr: `(like name "f_o%r")`, res: true},
+		{expr: `(like name "%o_a%")`, res: true},
+		{expr: `(like name "%o___a%")`, res: false},
+		{expr: `(like email "%@%.com")`, res: true},
+		{expr: `(like email "%@%.org")`, res: false},
+		{expr: `(like city "東_都")`, res: true},
+		{expr: `(like city "__")`, res: false},
+		{expr: `(like "100%" "100\%")`, res: true},
+		{expr: `(like "1000" "100\%")`, res: false},
+		{expr: `(like "a_b" "a\_b")`, res: true},
+		{expr: `(like "axb" "a\_b")`, res: false},
+		{expr: `(like name pattern)`, res: true},
+		{expr: `(like name "FOO%")`, res: false},
+		{expr: `(like name "foo%")`, opts: []CompileOption{EnableTypeCheck}, res: true},
+		{expr: `name LIKE 'foo%' AND email NOT LIKE '%.org'`, opts: []CompileOption{EnableSQLSyntax}, res: true},
+
+		{expr: `(like id "7")`, errMsg: "unexpected param type, operator: like, expected: string"},
+		{expr: `(like name 7)`, errMsg: "unexpected param type, operator: like, expected: string"},
+		{expr: `(like name)`, errMsg: "unexpected params count, operator: like, expected: 2, got: 1"},
+		{expr: `(like name 7)`, opts: []CompileOption{EnableTypeCheck}, errMsg: "like param 1 should be string, got: int"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+}
+
+func TestLike_Patterns(t *testing.T) {
+	patterns := []string{"", "%", "%%", "_", "a", "ab%", "%ab", "%ab%", "%%ab%%", "a%b", "a_b%", "%a%b%", "_%_", "%b_", "%ab%ab"}
+	inputs := []string{"", "a", "b", "ab", "ba", "abb", "aab", "abab", "acb", "xaby", "abcab", "aXbYab", "ä"}
+
+	for _, pattern := range patterns {
+		re := regexp.MustCompile("(?s)^" + strings.NewReplacer("%", ".*", "_", ".").Replace(pattern) + "$")
+		elems := parseLike(pattern)
+		match := compileLike(pattern)
+		for _, s := range inputs {
+			want := re.MatchString(s)
+			assertEquals(t, match(s), want, pattern, s)
+			assertEquals(t, matchLike(s, elems), want, pattern, s)
+		}
+	}
+}
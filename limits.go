@@ -0,0 +1,97 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrTooComplex is wrapped by the ComplexityError
+var ErrTooComplex = errors.New("expression too complex")
+
+// ComplexityLimits limits the complexity of the expressions compiled with a CompileConfig,
+// so that the user-authored expressions can't explode the cost of the compilation and evaluation.
+// There is no limit if it is not positive.
+type ComplexityLimits struct {
+	// MaxLength is the maximum number of bytes of the expression source
+	MaxLength int
+	// MaxDepth is the maximum depth of the syntax tree, the depth of a single constant or selector is 1
+	MaxDepth int
+	// MaxNodes is the maximum number of the nodes of the syntax tree
+	MaxNodes int
+	// MaxListSize is the maximum number of the elements of the list literals, e.g. (in x (1 2 3))
+	MaxListSize int
+}
+
+// ComplexityError is returned by Compile if the expression exceeds the ComplexityLimits
+type ComplexityError struct {
+	// Limit is the name of the exceeded limit, e.g. MaxDepth
+	Limit  string
+	Max    int
+	Actual int
+}
+
+func (e *ComplexityError) Error() string {
+	return fmt.Sprintf("%v, %s: %d, actual: %d", ErrTooComplex, e.Limit, e.Max, e.Actual)
+}
+
+func (e *ComplexityError) Unwrap() error {
+	return ErrTooComplex
+}
+
+func exceeds(limit, actual int) bool {
+	return limit > 0 && actual > limit
+}
+
+// checkLength checks the length of the expression source before it's parsed
+func (l ComplexityLimits) checkLength(exprStr string) error {
+	if exceeds(l.MaxLength, len(exprStr)) {
+		return &ComplexityError{Limit: "MaxLength", Max: l.MaxLength, Actual: len(exprStr)}
+	}
+	return nil
+}
+
+// checkTree checks the depth, the size and the list literals of the syntax tree
+func (l ComplexityLimits) checkTree(root *astNode) error {
+	if l.MaxDepth <= 0 && l.MaxNodes <= 0 && l.MaxListSize <= 0 {
+		return nil
+	}
+	var nodes int
+	if err := l.walk(root, 1, &nodes); err != nil {
+		return err
+	}
+	if exceeds(l.MaxNodes, nodes) {
+		return &ComplexityError{Limit: "MaxNodes", Max: l.MaxNodes, Actual: nodes}
+	}
+	return nil
+}
+
+func (l ComplexityLimits) walk(root *astNode, depth int, nodes *int) error {
+	*nodes++
+	if exceeds(l.MaxDepth, depth) {
+		return &ComplexityError{Limit: "MaxDepth", Max: l.MaxDepth, Actual: depth}
+	}
+
+	// the constant lists, and the ones built at runtime
+	size := -1
+	switch n := root.node; n.getNodeType() {
+	case constant:
+		if v := reflect.ValueOf(n.value); v.Kind() == reflect.Slice {
+			size = v.Len()
+		}
+	case operator:
+		if n.value == "list" {
+			size = len(root.children)
+		}
+	}
+	if exceeds(l.MaxListSize, size) {
+		return &ComplexityError{Limit: "MaxListSize", Max: l.MaxListSize, Actual: size}
+	}
+
+	for _, child := range root.children {
+		if err := l.walk(child, depth+1, nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
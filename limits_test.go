@@ -0,0 +1,82 @@
+package eval
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestComplexityLimits(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "US",
+	}
+
+	testCases := []struct {
+		limits ComplexityLimits
+		opt    CompileOption
+		expr   string
+		err    *ComplexityError
+	}{
+		{
+			limits: ComplexityLimits{MaxLength: 41, MaxDepth: 3, MaxNodes: 7, MaxListSize: 3},
+			expr:   `(and (> age 18) (in country ("US" "CA")))`,
+		},
+		{
+			limits: ComplexityLimits{MaxLength: 20},
+			expr:   `(and (> age 18) (in country ("US" "CA")))`,
+			err:    &ComplexityError{Limit: "MaxLength", Max: 20, Actual: 41},
+		},
+		{
+			limits: ComplexityLimits{MaxDepth: 2},
+			expr:   `(and (> age 18) (in country ("US" "CA")))`,
+			err:    &ComplexityError{Limit: "MaxDepth", Max: 2, Actual: 3},
+		},
+		{
+			limits: ComplexityLimits{MaxNodes: 6},
+			expr:   `(and (> age 18) (in country ("US" "CA")))`,
+			err:    &ComplexityError{Limit: "MaxNodes", Max: 6, Actual: 7},
+		},
+		{
+			limits: ComplexityLimits{MaxListSize: 2},
+			expr:   `(in country ("US" "CA" "UK"))`,
+			err:    &ComplexityError{Limit: "MaxListSize", Max: 2, Actual: 3},
+		},
+		{
+			// the lists built at runtime
+			limits: ComplexityLimits{MaxListSize: 2},
+			opt:    EnableInfixSyntax,
+			expr:   `age in [1, age, 3]`,
+			err:    &ComplexityError{Limit: "MaxListSize", Max: 2, Actual: 3},
+		},
+		{
+			// the depth of the nested parentheses
+			limits: ComplexityLimits{MaxDepth: 4},
+			opt:    EnableInfixSyntax,
+			expr:   `age + (age + (age + (age + 1))) > 0`,
+			err:    &ComplexityError{Limit: "MaxDepth", Max: 4, Actual: 5},
+		},
+	}
+
+	for _, c := range testCases {
+		opts := []CompileOption{RegisterSelKeys(vals), LimitComplexity(c.limits)}
+		if c.opt != nil {
+			opts = append(opts, c.opt)
+		}
+		cc := NewCompileConfig(opts...)
+		_, err := Compile(cc, c.expr)
+		if c.err == nil {
+			assertNil(t, err)
+			continue
+		}
+
+		assertEquals(t, errors.Is(err, ErrTooComplex), true)
+		var complexityErr *ComplexityError
+		assertEquals(t, errors.As(err, &complexityErr), true)
+		assertEquals(t, complexityErr, c.err)
+		assertErrStrContains(t, err, "expression too complex, "+c.err.Limit)
+
+		// the copies of the config are limited as well
+		_, err = Compile(CopyCompileConfig(cc), c.expr)
+		assertEquals(t, errors.Is(err, ErrTooComplex), true)
+	}
+}
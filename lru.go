@@ -0,0 +1,61 @@
+package eval
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a thread-safe cache which evicts the least recently used entry
+// once the number of entries exceeds its capacity
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	val interface{}
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, exist := c.items[key]
+	if !exist {
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return elem.Value.(*lruEntry).val, true
+}
+
+func (c *lruCache) add(key string, val interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, exist := c.items[key]; exist {
+		c.ll.MoveToFront(elem)
+		elem.Value.(*lruEntry).val = val
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, val: val})
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,439 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// version of the serialized format, it should be increased
+// whenever an incompatible change is made to the bytecode
+const marshalVersion = 1
+
+type exprData struct {
+	Version      int     `json:"version"`
+	MaxStackSize int16   `json:"max_stack_size"`
+	MaxSteps     int     `json:"max_steps,omitempty"`
+	ReturnType   Type    `json:"return_type,omitempty"`
+	Memoize      bool    `json:"memoize,omitempty"`
+	Backend      Backend `json:"backend,omitempty"`
+
+	MissingSelector  MissingSelectorPolicy `json:"missing_selector,omitempty"`
+	SelectorDefaults map[string]valueData  `json:"selector_defaults,omitempty"`
+	RecoverPanics    bool                  `json:"recover_panics,omitempty"`
+	ZeroAlloc        bool                  `json:"zero_alloc,omitempty"`
+
+	Nodes     []nodeData `json:"nodes"`
+	ParentIdx []int16    `json:"parent_idx"`
+	ScIdx     []int16    `json:"sc_idx"`
+	SfSize    []int16    `json:"sf_size"`
+	OsSize    []int16    `json:"os_size"`
+}
+
+type nodeData struct {
+	Flag      uint8           `json:"flag"`
+	ChildCnt  int8            `json:"child_cnt"`
+	ScIdx     int16           `json:"sc_idx"`
+	ChildIdx  int16           `json:"child_idx"`
+	SelKey    SelectorKey     `json:"sel_key,omitempty"`
+	ValueType string          `json:"value_type"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// Marshal serializes the compiled expression, so that it can be persisted
+// and loaded by UnmarshalExpr later without parsing and optimizing it again.
+// Operators are serialized by their names, and the lambdas of the higher-order operators
+// are serialized with their bodies as the nested expressions.
+func (e *Expr) Marshal() ([]byte, error) {
+	data := exprData{
+		Version:      marshalVersion,
+		MaxStackSize: e.maxStackSize,
+		MaxSteps:     e.maxSteps,
+		ReturnType:   e.returnType,
+		Memoize:      e.memoizeSelectors,
+		Backend:      e.backend,
+		Nodes:        make([]nodeData, len(e.nodes)),
+		ParentIdx:    e.parentIdx,
+		ScIdx:        e.scIdx,
+		SfSize:       e.sfSize,
+		OsSize:       e.osSize,
+
+		MissingSelector: e.missingSelector,
+		RecoverPanics:   e.recoverPanics,
+		ZeroAlloc:       e.zeroAlloc,
+	}
+
+	for name, val := range e.selectorDefaults {
+		typ, raw, err := marshalValue(val)
+		if err != nil {
+			return nil, fmt.Errorf("marshal expr error, selector default: %s, error: %w", name, err)
+		}
+		if data.SelectorDefaults == nil {
+			data.SelectorDefaults = make(map[string]valueData)
+		}
+		data.SelectorDefaults[name] = valueData{Type: typ, Value: raw}
+	}
+
+	for i, n := range e.nodes {
+		typ, raw, err := marshalValue(n.value)
+		if err != nil {
+			return nil, fmt.Errorf("marshal expr error, node index: %d, error: %w", i, err)
+		}
+		data.Nodes[i] = nodeData{
+			Flag:      n.flag,
+			ChildCnt:  n.childCnt,
+			ScIdx:     n.scIdx,
+			ChildIdx:  n.childIdx,
+			SelKey:    n.selKey,
+			ValueType: typ,
+			Value:     raw,
+		}
+	}
+	return json.Marshal(data)
+}
+
+// UnmarshalExpr loads an expression serialized by Expr.Marshal.
+// The operators are resolved by names from the builtin operators and cc.OperatorMap,
+// and the selector keys are resolved from cc.SelectorMap if the selectors are registered.
+// The debug outputs and the EvalHook of cc are installed to the loaded expression.
+func UnmarshalExpr(bs []byte, cc *CompileConfig) (*Expr, error) {
+	if cc == nil {
+		cc = NewCompileConfig()
+	}
+
+	var data exprData
+	if err := json.Unmarshal(bs, &data); err != nil {
+		return nil, fmt.Errorf("unmarshal expr error: %w", err)
+	}
+
+	if data.Version != marshalVersion {
+		return nil, fmt.Errorf("unmarshal expr error, unsupported version: %d", data.Version)
+	}
+
+	size := len(data.Nodes)
+	if size == 0 ||
+		len(data.ParentIdx) != size ||
+		len(data.ScIdx) != size ||
+		len(data.SfSize) != size ||
+		len(data.OsSize) != size {
+		return nil, fmt.Errorf("unmarshal expr error, corrupted data, node size: %d", size)
+	}
+	if err := checkIndices(&data); err != nil {
+		return nil, fmt.Errorf("unmarshal expr error, corrupted data, %w", err)
+	}
+
+	e := &Expr{
+		maxStackSize:     data.MaxStackSize,
+		maxSteps:         data.MaxSteps,
+		returnType:       data.ReturnType,
+		memoizeSelectors: data.Memoize,
+		nodes:            make([]*node, size),
+		parentIdx:        data.ParentIdx,
+		scIdx:            data.ScIdx,
+		sfSize:           data.SfSize,
+		osSize:           data.OsSize,
+	}
+
+	isDebug := data.Nodes[0].Flag&nodeTypeMask == debug
+
+	for i, nd := range data.Nodes {
+		val, err := unmarshalNodeValue(nd.ValueType, nd.Value, cc)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal expr error, node index: %d, error: %w", i, err)
+		}
+
+		n := &node{
+			flag:     nd.Flag,
+			childCnt: nd.ChildCnt,
+			scIdx:    nd.ScIdx,
+			childIdx: nd.ChildIdx,
+			selKey:   nd.SelKey,
+			value:    val,
+		}
+
+		switch n.getNodeType() {
+		case selector:
+			name, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("unmarshal expr error, invalid selector: %v", val)
+			}
+			if key, exist := cc.SelectorMap[name]; exist {
+				n.selKey = key
+			}
+		case operator, fastOperator:
+			name, ok := val.(string)
+			if !ok {
+				return nil, fmt.Errorf("unmarshal expr error, invalid operator: %v", val)
+			}
+			op, exist := cc.getOperator(name)
+			if !exist {
+				return nil, fmt.Errorf("unmarshal expr error, unknown operator: %s", name)
+			}
+			n.operator = op
+		}
+		e.nodes[i] = n
+	}
+
+	if err := specializeOperators(e, cc); err != nil {
+		return nil, fmt.Errorf("unmarshal expr error: %w", err)
+	}
+	e.setStackSize(isDebug)
+	calAndSetParamsSize(e)
+
+	defaults := make(map[string]Value, len(data.SelectorDefaults))
+	for name, vd := range data.SelectorDefaults {
+		val, err := unmarshalValue(vd.Type, vd.Value)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal expr error, selector default: %s, error: %w", name, err)
+		}
+		defaults[name] = val
+	}
+	e.setMissingSelectors(data.MissingSelector, defaults)
+	e.setRequiredSelectors()
+	if data.RecoverPanics {
+		e.setRecoverPanics()
+	}
+
+	if isDebug {
+		e.setDebugOutput(cc)
+		for i, n := range e.nodes[size/2:] {
+			if typ := n.getNodeType(); typ == operator || typ == fastOperator {
+				n.operator = e.wrapDebugInfo(i, n.value.(string), n.operator)
+			}
+		}
+	}
+
+	e.conf = cc
+	e.hook = cc.EvalHook
+	e.cachesURLs = callsURLOperators(e)
+	e.setBackend(data.Backend)
+	if data.ZeroAlloc {
+		e.ignoreCase = cc.CompileOptions[CaseInsensitive]
+		if err := e.setPredicate(); err != nil {
+			return nil, fmt.Errorf("unmarshal expr error: %w", err)
+		}
+	}
+	return e, nil
+}
+
+// setStackSize computes the stack sizes of the loaded expression rather than trusting the data,
+// the debug nodes share the sizes of their real nodes, see setDebugInfo
+func (e *Expr) setStackSize(isDebug bool) {
+	if !isDebug {
+		calAndSetParentIndex(e)
+		calAndSetStackSize(e)
+		return
+	}
+
+	offset := len(e.nodes) / 2
+	realExpr := &Expr{
+		nodes:     make([]*node, offset),
+		parentIdx: make([]int16, offset),
+		sfSize:    make([]int16, offset),
+		osSize:    make([]int16, offset),
+	}
+	for i := range realExpr.nodes {
+		// the children of the debug nodes are not offset
+		n := *e.nodes[i+offset]
+		n.childIdx = e.nodes[i].childIdx
+		realExpr.nodes[i] = &n
+	}
+	calAndSetParentIndex(realExpr)
+	calAndSetStackSize(realExpr)
+
+	e.maxStackSize = realExpr.maxStackSize
+	for i := 0; i < offset; i++ {
+		e.parentIdx[i], e.parentIdx[i+offset] = realExpr.parentIdx[i], realExpr.parentIdx[i]+int16(offset)
+		e.sfSize[i], e.sfSize[i+offset] = realExpr.sfSize[i], realExpr.sfSize[i]
+		e.osSize[i], e.osSize[i+offset] = realExpr.osSize[i], realExpr.osSize[i]
+	}
+}
+
+// checkIndices checks that the indices of the nodes are in range,
+// so that the corrupted data fails to be loaded rather than panics at evaluation
+func checkIndices(data *exprData) error {
+	size := len(data.Nodes)
+	inRange := func(idx int16) bool {
+		return idx >= 0 && int(idx) < size
+	}
+	for i, nd := range data.Nodes {
+		if nd.ChildCnt < 0 || (nd.ChildCnt > 0 && (nd.ChildIdx < 0 || int(nd.ChildIdx)+int(nd.ChildCnt) > size)) {
+			return fmt.Errorf("node index: %d, child index: %d, child count: %d", i, nd.ChildIdx, nd.ChildCnt)
+		}
+		if !inRange(nd.ScIdx) {
+			return fmt.Errorf("node index: %d, short circuit index: %d", i, nd.ScIdx)
+		}
+		if !inRange(data.ScIdx[i]) {
+			return fmt.Errorf("node index: %d, short circuit index: %d", i, data.ScIdx[i])
+		}
+	}
+	return nil
+}
+
+func marshalValue(v Value) (string, json.RawMessage, error) {
+	var typ string
+	switch val := v.(type) {
+	case nil:
+		return "nil", nil, nil
+	case bool:
+		typ = typeBool
+	case int64:
+		typ = typeInt
+	case float64:
+		typ = typeFloat
+	case string:
+		typ = typeStr
+	case []int64:
+		typ = typeIntList
+	case []string:
+		typ = typeStrList
+	case Decimal:
+		raw, err := json.Marshal(val.String())
+		return typeDecimal, raw, err
+	case *big.Int:
+		raw, err := json.Marshal(val.String())
+		return typeBigInt, raw, err
+	case []Value:
+		l := make([]valueData, len(val))
+		for i, elem := range val {
+			typ, raw, err := marshalValue(elem)
+			if err != nil {
+				return "", nil, err
+			}
+			l[i] = valueData{Type: typ, Value: raw}
+		}
+		raw, err := json.Marshal(l)
+		return typeList, raw, err
+	case *lambda:
+		body, err := val.body.Marshal()
+		if err != nil {
+			return "", nil, err
+		}
+		raw, err := json.Marshal(lambdaData{Param: val.param, Body: body})
+		return typeLambda, raw, err
+	case map[string]Value:
+		m := make(map[string]valueData, len(val))
+		for k, elem := range val {
+			typ, raw, err := marshalValue(elem)
+			if err != nil {
+				return "", nil, err
+			}
+			m[k] = valueData{Type: typ, Value: raw}
+		}
+		raw, err := json.Marshal(m)
+		return typeMap, raw, err
+	default:
+		return "", nil, fmt.Errorf("unsupported value type: %T", v)
+	}
+	raw, err := json.Marshal(v)
+	return typ, raw, err
+}
+
+// valueData is the serialized value of the map or list, which keeps the types of the values
+type valueData struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// lambdaData is the serialized lambda, whose body is a serialized expression
+type lambdaData struct {
+	Param string          `json:"param"`
+	Body  json.RawMessage `json:"body"`
+}
+
+// unmarshalNodeValue unmarshals the value of a node, the bodies of the lambdas are loaded with the cc
+func unmarshalNodeValue(typ string, raw json.RawMessage, cc *CompileConfig) (Value, error) {
+	if typ != typeLambda {
+		return unmarshalValue(typ, raw)
+	}
+	var data lambdaData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	// the same as compileLambdas, the nodes of the bodies are not reported to the EvalHook
+	bodyConf := *cc
+	bodyConf.EvalHook = nil
+	body, err := UnmarshalExpr(data.Body, &bodyConf)
+	if err != nil {
+		return nil, err
+	}
+	return &lambda{param: data.Param, body: body}, nil
+}
+
+func unmarshalValue(typ string, raw json.RawMessage) (Value, error) {
+	var (
+		v   Value
+		err error
+	)
+	switch typ {
+	case "nil":
+		return nil, nil
+	case typeBool:
+		var b bool
+		err = json.Unmarshal(raw, &b)
+		v = b
+	case typeInt:
+		var i int64
+		err = json.Unmarshal(raw, &i)
+		v = i
+	case typeFloat:
+		var f float64
+		err = json.Unmarshal(raw, &f)
+		v = f
+	case typeStr:
+		var s string
+		err = json.Unmarshal(raw, &s)
+		v = s
+	case typeDecimal:
+		var s string
+		if err = json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		v, err = ParseDecimal(s)
+	case typeBigInt:
+		var s string
+		if err = json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		b, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid big integer: %q", s)
+		}
+		v = b
+	case typeIntList:
+		ints := []int64{}
+		err = json.Unmarshal(raw, &ints)
+		v = ints
+	case typeStrList:
+		strs := []string{}
+		err = json.Unmarshal(raw, &strs)
+		v = strs
+	case typeList:
+		var data []valueData
+		if err = json.Unmarshal(raw, &data); err != nil {
+			return nil, err
+		}
+		l := make([]Value, len(data))
+		for i, d := range data {
+			if l[i], err = unmarshalValue(d.Type, d.Value); err != nil {
+				return nil, err
+			}
+		}
+		v = l
+	case typeMap:
+		var data map[string]valueData
+		if err = json.Unmarshal(raw, &data); err != nil {
+			return nil, err
+		}
+		m := make(map[string]Value, len(data))
+		for k, d := range data {
+			if m[k], err = unmarshalValue(d.Type, d.Value); err != nil {
+				return nil, err
+			}
+		}
+		v = m
+	default:
+		return nil, fmt.Errorf("unsupported value type: %s", typ)
+	}
+	return v, err
+}
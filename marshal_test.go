@@ -0,0 +1,238 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestMarshalExpr(t *testing.T) {
+	isChild := func(_ *Ctx, params []Value) (Value, error) {
+		const op = "is_child"
+		if len(params) != 1 {
+			return nil, ParamsCountError(op, 1, len(params))
+		}
+		age, ok := params[0].(int64)
+		if !ok {
+			return nil, ParamTypeError(op, typeInt, params[0])
+		}
+		return age < 18, nil
+	}
+
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "US",
+		"tags":    []string{"a", "b"},
+		"groups":  []int64{1, 2},
+		"app":     "1.2.4",
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		want   Value
+		errMsg string
+	}{
+		{
+			expr: `(+ 1 1)`,
+			want: int64(2),
+		},
+		{
+			expr: `(and (>= age 18) (= country "US") (not (is_child age)))`,
+			want: true,
+		},
+		{
+			expr: `(if (overlap tags ("c" "b")) (in 2 groups) false)`,
+			opts: []CompileOption{Optimizations(false)},
+			want: true,
+		},
+		{
+			expr: `(or (< age 18) (> (t_version app) (t_version "1.2.3")))`,
+			opts: []CompileOption{Optimizations(false)},
+			want: true,
+		},
+	}
+
+	for _, c := range testCases {
+		opts := append([]CompileOption{RegisterSelKeys(vals)}, c.opts...)
+		cc := NewCompileConfig(opts...)
+		err := RegisterOperator(cc, "is_child", isChild)
+		assertNil(t, err)
+
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err, c)
+
+		bs, err := expr.Marshal()
+		assertNil(t, err, c)
+
+		got, err := UnmarshalExpr(bs, cc)
+		assertNil(t, err, c)
+
+		assertEquals(t, PrintExpr(got), PrintExpr(expr), c)
+		assertEquals(t, Dump(got), Dump(expr), c)
+
+		res, err := got.Eval(NewCtxWithMap(cc, vals))
+		assertNil(t, err, c)
+		assertEquals(t, res, c.want, c)
+	}
+}
+
+func TestUnmarshalExprError(t *testing.T) {
+	cc := NewCompileConfig()
+	assertNil(t, RegisterOperator(cc, "custom", func(_ *Ctx, _ []Value) (Value, error) {
+		return true, nil
+	}))
+	expr, err := Compile(cc, `(custom 1)`)
+	assertNil(t, err)
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+
+	_, err = UnmarshalExpr(bs, nil)
+	assertErrStrContains(t, err, "unknown operator: custom")
+
+	_, err = UnmarshalExpr([]byte(`{"version": 0}`), nil)
+	assertErrStrContains(t, err, "unsupported version")
+
+	_, err = UnmarshalExpr([]byte(`{"version": 1, "nodes": [{}]}`), nil)
+	assertErrStrContains(t, err, "corrupted data")
+
+	_, err = UnmarshalExpr([]byte(`{`), nil)
+	assertErrStrContains(t, err, "unmarshal expr error")
+}
+
+func TestUnmarshalExprCorruptedIndices(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors)
+	expr, err := Compile(cc, `(and (> age 18) (= country "US"))`)
+	assertNil(t, err)
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+
+	testCases := []struct {
+		name    string
+		corrupt func(data *exprData)
+		errMsg  string
+	}{
+		{
+			name:    "child index",
+			corrupt: func(data *exprData) { data.Nodes[0].ChildIdx = int16(len(data.Nodes)) },
+			errMsg:  "child index",
+		},
+		{
+			name:    "negative child index",
+			corrupt: func(data *exprData) { data.Nodes[0].ChildIdx = -1 },
+			errMsg:  "child index",
+		},
+		{
+			name:    "child count",
+			corrupt: func(data *exprData) { data.Nodes[0].ChildCnt = 100 },
+			errMsg:  "child count: 100",
+		},
+		{
+			name:    "node short circuit index",
+			corrupt: func(data *exprData) { data.Nodes[1].ScIdx = 100 },
+			errMsg:  "short circuit index: 100",
+		},
+		{
+			name:    "short circuit index",
+			corrupt: func(data *exprData) { data.ScIdx[1] = -2 },
+			errMsg:  "short circuit index: -2",
+		},
+	}
+
+	for _, c := range testCases {
+		var data exprData
+		assertNil(t, json.Unmarshal(bs, &data), c.name)
+		c.corrupt(&data)
+		corrupted, err := json.Marshal(data)
+		assertNil(t, err, c.name)
+
+		_, err = UnmarshalExpr(corrupted, cc)
+		assertErrStrContains(t, err, "corrupted data", c.name)
+		assertErrStrContains(t, err, c.errMsg, c.name)
+	}
+}
+
+func TestUnmarshalExprStackSize(t *testing.T) {
+	// (+ 1 (+ 1 ... (+ 1 a)))
+	exprStr := "a"
+	for i := 0; i < 19; i++ {
+		exprStr = fmt.Sprintf("(+ 1 %s)", exprStr)
+	}
+
+	for _, opts := range [][]CompileOption{nil, {EnableDebug}} {
+		cc := NewCompileConfig(append(opts, EnableStringSelectors, Optimizations(false))...)
+		cc.DebugWriter = io.Discard
+		expr, err := Compile(cc, exprStr)
+		assertNil(t, err)
+		bs, err := expr.Marshal()
+		assertNil(t, err)
+
+		// the stack sizes of the data are not trusted
+		var data exprData
+		assertNil(t, json.Unmarshal(bs, &data))
+		data.MaxStackSize = 1
+		for i := range data.Nodes {
+			data.SfSize[i], data.OsSize[i], data.ParentIdx[i] = 1, 0, -1
+		}
+		corrupted, err := json.Marshal(data)
+		assertNil(t, err)
+
+		got, err := UnmarshalExpr(corrupted, cc)
+		assertNil(t, err)
+		assertEquals(t, PrintExpr(got), PrintExpr(expr), opts)
+		res, err := got.Eval(NewCtxWithMap(cc, map[string]interface{}{"a": 1}))
+		assertNil(t, err)
+		assertEquals(t, res, int64(20))
+	}
+}
+
+func TestMarshalLambdas(t *testing.T) {
+	vals := map[string]interface{}{
+		"items": []int64{1, 5, 8},
+		"tags":  []string{"a", "bb"},
+		"limit": 4,
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+
+	testCases := []struct {
+		expr string
+		want Value
+	}{
+		{expr: `(any items x (> x limit))`, want: true},
+		{expr: `(all items x (> x limit))`, want: false},
+		{expr: `(none items x (> x 10))`, want: true},
+		{expr: `(count items x (> x limit))`, want: int64(2)},
+		{expr: `(filter items x (> x limit))`, want: []int64{5, 8}},
+		{expr: `(map tags t (len t))`, want: []int64{1, 2}},
+		{expr: `(any tags t (all items x (> (+ x (len t)) 1)))`, want: true},
+		{expr: `(let (total (+ limit 1)) (> total 4))`, want: true},
+	}
+
+	for _, c := range testCases {
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err, c.expr)
+		bs, err := expr.Marshal()
+		assertNil(t, err, c.expr)
+
+		got, err := UnmarshalExpr(bs, cc)
+		assertNil(t, err, c.expr)
+		assertEquals(t, got.Decompile(), expr.Decompile(), c.expr)
+
+		res, err := got.Eval(NewCtxWithMap(cc, vals))
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.want, c.expr)
+	}
+
+	// the operator of the lambda body should be registered
+	custom := CopyCompileConfig(cc)
+	assertNil(t, RegisterOperator(custom, "big", func(_ *Ctx, params []Value) (Value, error) {
+		return params[0].(int64) > 4, nil
+	}))
+	expr, err := Compile(custom, `(any items x (big x))`)
+	assertNil(t, err)
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	_, err = UnmarshalExpr(bs, cc)
+	assertErrStrContains(t, err, "unknown operator: big")
+}
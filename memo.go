@@ -0,0 +1,152 @@
+package eval
+
+import (
+	"fmt"
+)
+
+// Memo evaluates an expression incrementally, the results of the subexpressions are cached
+// until any of the selectors they depend on is reported by Changed, so that only the dependent
+// subexpressions are evaluated again, e.g. the rules re-evaluated on every event of a session,
+// whose inputs are mostly unchanged.
+//
+//	memo := expr.NewMemo()
+//	res, err := memo.Eval(ctx) // all the subexpressions are evaluated
+//	memo.Changed("lastEvent")
+//	res, err = memo.Eval(ctx) // only the subexpressions reading lastEvent are evaluated
+//
+// The operators are assumed to be deterministic, i.e. their results are decided by their params,
+// and the errors are not cached. A Memo is not safe for concurrent use.
+type Memo struct {
+	expr *Expr
+	// dependents are the nodes of the subexpressions reading the selectors by their names
+	dependents map[string][]*node
+	results    map[*node]Value
+}
+
+// NewMemo creates a Memo of the expression, nothing is cached before the first evaluation
+func (e *Expr) NewMemo() *Memo {
+	m := &Memo{
+		expr:       e,
+		dependents: make(map[string][]*node),
+		results:    make(map[*node]Value),
+	}
+	m.setDependents(e.getNode(0))
+	return m
+}
+
+// setDependents adds the node to the dependents of the selectors of the subexpression, and returns the selectors
+func (m *Memo) setDependents(n *node) map[string]bool {
+	deps := make(map[string]bool)
+	switch n.getNodeType() {
+	case selector:
+		deps[n.value.(string)] = true
+	case constant:
+		// the lambda body reads its free selectors when it's called by the higher-order operator
+		if l, ok := n.value.(*lambda); ok {
+			for _, sel := range l.body.freeSelectorNodes() {
+				if sel.value != l.param {
+					deps[sel.value.(string)] = true
+				}
+			}
+		}
+	}
+	for _, child := range m.expr.childNodes(n) {
+		for name := range m.setDependents(child) {
+			deps[name] = true
+		}
+	}
+	for name := range deps {
+		m.dependents[name] = append(m.dependents[name], n)
+	}
+	return deps
+}
+
+// Changed invalidates the cached results of the subexpressions reading the selectors,
+// they are evaluated again by the next Eval
+func (m *Memo) Changed(names ...string) {
+	for _, name := range names {
+		for _, n := range m.dependents[name] {
+			delete(m.results, n)
+		}
+	}
+}
+
+// Reset invalidates all the cached results
+func (m *Memo) Reset() {
+	m.results = make(map[*node]Value)
+}
+
+// Eval evaluates the expression like Expr.Eval with the cached results of the unchanged subexpressions,
+// the short circuit follows the same rules as the compiled one.
+func (m *Memo) Eval(ctx *Ctx) (Value, error) {
+	if m.expr.cachesURLs {
+		ctx = withURLCache(ctx)
+	}
+	return checkMissingResult(m.eval(ctx, m.expr.getNode(0)))
+}
+
+func (m *Memo) eval(ctx *Ctx, n *node) (res Value, err error) {
+	if res, cached := m.results[n]; cached {
+		return res, nil
+	}
+	defer func() {
+		if err == nil {
+			m.results[n] = res
+		}
+	}()
+
+	e := m.expr
+	switch n.getNodeType() {
+	case constant:
+		return n.value, nil
+	case selector:
+		return e.getSelectorValue(ctx, n)
+	}
+
+	children := e.childNodes(n)
+	if n.getNodeType() == cond {
+		c, err := m.eval(ctx, children[0])
+		if err != nil {
+			return nil, err
+		}
+		condRes, ok := c.(bool)
+		if !ok {
+			return nil, condTypeError(c)
+		}
+		if condRes {
+			return m.eval(ctx, children[1])
+		}
+		return m.eval(ctx, children[2])
+	}
+
+	params := make([]Value, len(children))
+	for i, child := range children {
+		v, err := m.eval(ctx, child)
+		if err != nil {
+			return nil, err
+		}
+
+		// same as the short circuit flags set by calAndSetShortCircuit
+		if b, ok := v.(bool); ok && shortCircuits(n, i, len(children), b) {
+			return b, nil
+		}
+		params[i] = v
+	}
+
+	res, err = n.operator(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", n.value, err)
+	}
+	return res, nil
+}
+
+// childNodes returns the children of the node, the end nodes are skipped
+func (e *Expr) childNodes(n *node) []*node {
+	children := make([]*node, 0, n.childCnt)
+	for i := 0; i < int(n.childCnt); i++ {
+		if child := e.getNode(int(n.childIdx) + i); child.getNodeType() != end {
+			children = append(children, child)
+		}
+	}
+	return children
+}
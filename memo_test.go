@@ -0,0 +1,144 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestMemo(t *testing.T) {
+	vals := map[string]interface{}{
+		"events":   10,
+		"country":  "US",
+		"score":    50,
+		"lastType": "login",
+	}
+
+	var scored int
+	cc := NewCompileConfig(RegisterSelKeys(vals), Optimizations(false))
+	assertNil(t, RegisterOperator(cc, "risk", func(_ *Ctx, params []Value) (Value, error) {
+		scored++
+		return params[0].(int64) * 2, nil
+	}))
+	expr, err := Compile(cc, `(and (= country "US") (> (risk score) 80) (or (> events 5) (= lastType "purchase")))`)
+	assertNil(t, err)
+
+	sel := &countingSelector{MapSelector: NewMapSelector(vals)}
+	ctx := &Ctx{Selector: sel}
+	memo := expr.NewMemo()
+
+	res, err := memo.Eval(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	assertEquals(t, sel.cnt, 3)
+	assertEquals(t, scored, 1)
+
+	// nothing is evaluated if nothing changed
+	sel.cnt = 0
+	res, err = memo.Eval(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	assertEquals(t, sel.cnt, 0)
+
+	// only the subexpressions of the changed selectors are evaluated
+	sel.Values["events"] = int64(1)
+	memo.Changed("events")
+	res, err = memo.Eval(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, false)
+	assertEquals(t, sel.cnt, 2)
+	assertEquals(t, scored, 1)
+
+	sel.cnt = 0
+	sel.Values["lastType"] = "purchase"
+	memo.Changed("lastType")
+	res, err = memo.Eval(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	assertEquals(t, sel.cnt, 1)
+
+	sel.cnt = 0
+	sel.Values["score"] = int64(30)
+	memo.Changed("score", "unknown")
+	res, err = memo.Eval(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, false)
+	assertEquals(t, sel.cnt, 1)
+	assertEquals(t, scored, 2)
+
+	// the skipped subexpressions are evaluated once they are needed
+	sel.cnt = 0
+	sel.Values["country"] = "CA"
+	memo.Changed("country")
+	res, err = memo.Eval(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, false)
+	sel.Values["country"] = "US"
+	sel.Values["score"] = int64(60)
+	memo.Changed("country", "score")
+	res, err = memo.Eval(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	assertEquals(t, sel.cnt, 3)
+
+	// all the subexpressions are evaluated after reset
+	sel.cnt = 0
+	memo.Reset()
+	res, err = memo.Eval(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	assertEquals(t, sel.cnt, 4)
+}
+
+func TestMemo_Errors(t *testing.T) {
+	vals := map[string]interface{}{"age": 20, "name": "larry"}
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+
+	expr, err := Compile(cc, `(if (> age 18) (+ age 1) name)`)
+	assertNil(t, err)
+	memo := expr.NewMemo()
+
+	// the errors are not cached
+	sel := &countingSelector{MapSelector: NewMapSelector(map[string]interface{}{})}
+	_, err = memo.Eval(&Ctx{Selector: sel})
+	assertErrStrContains(t, err, "selectorKey not exist age")
+	sel.Values["age"] = int64(20)
+	res, err := memo.Eval(&Ctx{Selector: sel})
+	assertNil(t, err)
+	assertEquals(t, res, int64(21))
+
+	sel.Values["age"] = int64(10)
+	memo.Changed("age")
+	_, err = memo.Eval(&Ctx{Selector: sel})
+	assertErrStrContains(t, err, "selectorKey not exist name")
+
+	expr, err = Compile(cc, `(if name 1 2)`)
+	assertNil(t, err)
+	_, err = expr.NewMemo().Eval(NewCtxWithMap(cc, vals))
+	assertErrStrContains(t, err, "result type of if condition should be bool")
+
+	expr, err = Compile(cc, `(/ age 0)`)
+	assertNil(t, err)
+	_, err = expr.NewMemo().Eval(NewCtxWithMap(cc, vals))
+	assertErrStrContains(t, err, "operator execution error, operator: /")
+}
+
+func TestMemo_Lambda(t *testing.T) {
+	vals := map[string]interface{}{"items": []int{5, 20}, "limit": 10}
+	cc := NewCompileConfig(EnableStringSelectors)
+
+	expr, err := Compile(cc, `(and (any items x (> x limit)) (all items y (any items z (> (+ y z) limit))))`)
+	assertNil(t, err)
+	memo := expr.NewMemo()
+
+	sel := NewMapSelector(vals)
+	res, err := memo.Eval(&Ctx{Selector: sel})
+	assertNil(t, err)
+	assertEquals(t, res, true)
+
+	// the selectors read by the lambda bodies are dependencies, unlike the params
+	sel.Values["limit"] = int64(30)
+	memo.Changed("limit")
+	res, err = memo.Eval(&Ctx{Selector: sel})
+	assertNil(t, err)
+	assertEquals(t, res, false)
+	assertEquals(t, len(memo.dependents["x"])+len(memo.dependents["y"])+len(memo.dependents["z"]), 0)
+}
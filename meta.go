@@ -0,0 +1,97 @@
+package eval
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OperatorMeta is the metadata of an operator
+type OperatorMeta struct {
+	// Arity and Signature are declared to OperatorArities and OperatorSignatures for the compile-time checking,
+	// they are nil if not declared
+	Arity     *Arity
+	Signature *Signature
+	// Doc describes the usage of the operator, e.g. `(distance lat1 lng1 lat2 lng2) returns the distance in km`
+	Doc string
+	// Deterministic operators return the same results for the same params,
+	// e.g. they don't depend on the clock, the randomness or the ctx,
+	// so they are evaluated at compile time with the nil ctx if all their params are constants,
+	// e.g. (sha256 "salt"), with the ConstantFolding option.
+	// The others, e.g. now, are never evaluated at compile time.
+	Deterministic bool
+}
+
+// OperatorInfo describes an operator which can be called in the expressions compiled with a CompileConfig
+type OperatorInfo struct {
+	Name    string
+	Builtin bool
+	OperatorMeta
+}
+
+// RegisterOperatorMeta registers the metadata of the operator registered to the cc,
+// the arity and signature are declared for the compile-time checking if they are not nil
+func RegisterOperatorMeta(cc *CompileConfig, name string, meta OperatorMeta) error {
+	if _, exist := cc.OperatorMap[name]; !exist {
+		return fmt.Errorf("operator not registered %s", name)
+	}
+
+	if meta.Arity != nil {
+		cc.OperatorArities[name] = *meta.Arity
+	}
+	if meta.Signature != nil {
+		cc.OperatorSignatures[name] = *meta.Signature
+	}
+	cc.OperatorMetas[name] = meta
+	cc.changed()
+	return nil
+}
+
+// Operators returns the operators which can be called in the expressions compiled with the cc,
+// including the builtin ones, sorted by their names.
+// It's used for the introspection, e.g. the autocomplete and docs of the rule editors.
+func (cc *CompileConfig) Operators() []OperatorInfo {
+	infos := make([]OperatorInfo, 0, len(builtinOperators)+len(higherOrderOperators)+len(cc.OperatorMap))
+	for name := range builtinOperators {
+		infos = append(infos, cc.operatorInfo(name, true))
+	}
+	for name := range higherOrderOperators {
+		if _, exist := cc.OperatorMap[name]; !exist {
+			infos = append(infos, cc.operatorInfo(name, true))
+		}
+	}
+	for name := range cc.OperatorMap {
+		infos = append(infos, cc.operatorInfo(name, false))
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// operatorInfo returns the info of the operator, the arity and signature are the declared ones,
+// which may be declared without the metadata, e.g. by RegisterFunc
+func (cc *CompileConfig) operatorInfo(name string, builtin bool) OperatorInfo {
+	info := OperatorInfo{Name: name, Builtin: builtin}
+	if builtin {
+		// the builtin operators are constant-folded, so they are deterministic
+		info.Deterministic = true
+	} else {
+		info.OperatorMeta = cc.OperatorMetas[name]
+	}
+
+	if arity, exist := cc.OperatorArities[name]; exist {
+		info.Arity = &arity
+	}
+	sig, exist := cc.OperatorSignatures[name]
+	if !exist && builtin {
+		sig, exist = builtinSignatures[name]
+		if !exist {
+			sig, exist = higherOrderSignatures[name]
+		}
+	}
+	if exist {
+		info.Signature = &sig
+	}
+	return info
+}
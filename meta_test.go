@@ -0,0 +1,111 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestRegisterOperatorMeta(t *testing.T) {
+	distance := func(_ *Ctx, params []Value) (Value, error) {
+		return params[1].(int64) - params[0].(int64), nil
+	}
+
+	cc := NewCompileConfig(RegisterSelKeys(map[string]interface{}{"from": 0, "to": 0}), EnableTypeCheck)
+	err := RegisterOperatorMeta(cc, "distance", OperatorMeta{Doc: "distance"})
+	assertErrStrContains(t, err, "operator not registered distance")
+
+	assertNil(t, RegisterOperator(cc, "distance", distance))
+	assertNil(t, RegisterOperatorMeta(cc, "distance", OperatorMeta{
+		Arity:         &Arity{Min: 2, Max: 2},
+		Signature:     &Signature{Params: []Type{TypeInt, TypeInt}, Result: TypeInt},
+		Doc:           "(distance from to) returns the distance between from and to",
+		Deterministic: true,
+	}))
+	assertNil(t, cc.RegisterFunc("greet", func(name string) string { return "hi " + name }))
+
+	// the arity and signature are checked at compile time
+	_, err = Compile(cc, `(distance from)`)
+	assertErrStrContains(t, err, "distance parameters count error")
+	_, err = Compile(cc, `(distance from "to")`)
+	assertErrStrContains(t, err, "type")
+	res, err := Eval(`(distance from to)`, map[string]interface{}{"from": 3, "to": 10}, cc)
+	assertNil(t, err)
+	assertEquals(t, res, int64(7))
+
+	infos := make(map[string]OperatorInfo)
+	ops := cc.Operators()
+	for i, info := range ops {
+		if i > 0 && ops[i-1].Name >= info.Name {
+			t.Fatalf("operators are not sorted: %s, %s", ops[i-1].Name, info.Name)
+		}
+		infos[info.Name] = info
+	}
+
+	info := infos["distance"]
+	assertEquals(t, info.Builtin, false)
+	assertEquals(t, info.Deterministic, true)
+	assertEquals(t, info.Doc, "(distance from to) returns the distance between from and to")
+	assertEquals(t, info.Arity.String(), "2")
+	assertEquals(t, info.Signature.String(), "(int64, int64) int64")
+
+	// declared by RegisterFunc without the metadata
+	info = infos["greet"]
+	assertEquals(t, info.Builtin, false)
+	assertEquals(t, info.Deterministic, false)
+	assertEquals(t, info.Signature.String(), "(string) string")
+
+	info = infos["add"]
+	assertEquals(t, info.Builtin, true)
+	assertEquals(t, info.Deterministic, true)
+	assertEquals(t, info.Signature.String(), numArithmetic.String())
+
+	info = infos["filter"]
+	assertEquals(t, info.Builtin, true)
+	assertEquals(t, info.Signature != nil, true)
+
+	// copied with the config
+	assertEquals(t, CopyCompileConfig(cc).OperatorMetas["distance"].Doc, infos["distance"].Doc)
+}
+
+func TestDeterministicOperators(t *testing.T) {
+	var hashed, clocked int
+	cc := NewCompileConfig(RegisterSelKeys(map[string]interface{}{"token": ""}), Optimizations(true))
+	assertNil(t, RegisterOperator(cc, "hash", func(_ *Ctx, params []Value) (Value, error) {
+		hashed++
+		return "h:" + params[0].(string), nil
+	}))
+	assertNil(t, RegisterOperatorMeta(cc, "hash", OperatorMeta{Deterministic: true}))
+	assertNil(t, RegisterOperator(cc, "now", func(_ *Ctx, _ []Value) (Value, error) {
+		clocked++
+		return int64(clocked), nil
+	}))
+	assertNil(t, RegisterOperatorMeta(cc, "now", OperatorMeta{Deterministic: false}))
+
+	expr, err := Compile(cc, `(and (= token (hash "salt")) (> (now) 0) (= (hash token) "h:h:salt"))`)
+	assertNil(t, err)
+	assertEquals(t, hashed, 1)
+	assertEquals(t, clocked, 0)
+	assertEquals(t, expr.Decompile(), `(and (= token "h:salt") (> (now) 0) (= (hash token) "h:h:salt"))`)
+
+	res, err := expr.Eval(NewCtxWithMap(cc, map[string]interface{}{"token": "h:salt"}))
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	assertEquals(t, hashed, 2)
+	assertEquals(t, clocked, 1)
+}
+
+func TestDeterministicOperatorsCache(t *testing.T) {
+	cc := NewCompileConfig(Optimizations(true), WithCompileCache(NewCompileCache(8)))
+	assertNil(t, RegisterOperator(cc, "hash", func(_ *Ctx, params []Value) (Value, error) {
+		return "h:" + params[0].(string), nil
+	}))
+	expr, err := Compile(cc, `(hash "salt")`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(hash "salt")`)
+
+	// the expression compiled without the meta is not folded, so it's not shared
+	deterministic := CopyCompileConfig(cc)
+	assertNil(t, RegisterOperatorMeta(deterministic, "hash", OperatorMeta{Deterministic: true}))
+	expr, err = Compile(deterministic, `(hash "salt")`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `"h:salt"`)
+}
@@ -0,0 +1,122 @@
+package eval
+
+import (
+	"errors"
+)
+
+// ErrSelectorNotExist is returned by the selectors if the ctx doesn't have the value of a selector.
+// The custom selectors should wrap it, so that the missing selectors are handled by
+// the MissingSelectorPolicy and the SelectorDefaults of the CompileConfig.
+var ErrSelectorNotExist = errors.New("selectorKey not exist")
+
+// MissingSelectorPolicy decides how the selectors without values are evaluated,
+// the selectors having the SelectorDefaults are always evaluated to the default values.
+type MissingSelectorPolicy int
+
+const (
+	// MissingSelectorError fails the evaluation with the error of the selector
+	MissingSelectorError MissingSelectorPolicy = iota
+	// MissingSelectorFalse evaluates the innermost comparison containing the selector to false,
+	// e.g. both (> age 18) and (!= age 18) are false if age doesn't exist,
+	// and (not (> age 18)) is true. The evaluation still fails if the selector
+	// isn't contained by any comparison, e.g. (and active (> age 18)).
+	MissingSelectorFalse
+)
+
+// comparisonOperators are the operators evaluated to false by MissingSelectorFalse,
+// the other operators pass the missing value to their parents
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "gt": true, "lt": true, "ge": true, "le": true,
+	"=": true, "!=": true, ">": true, "<": true, ">=": true, "<=": true,
+	"between": true, "in": true, "not_in": true, "overlap": true, "matches": true, "like": true,
+	"semverSatisfies": true,
+}
+
+// missingValue is the value of a missing selector evaluated with MissingSelectorFalse
+type missingValue struct {
+	err error
+}
+
+func (m missingValue) String() string {
+	return "<missing>"
+}
+
+// setMissingSelectors sets the values of the missing selectors,
+// only the defaults of the selectors in the expression are kept
+func (e *Expr) setMissingSelectors(policy MissingSelectorPolicy, defaults map[string]Value) {
+	e.missingSelector = policy
+	e.selectorDefaults = nil
+	for _, n := range e.selectorNodes() {
+		name := n.value.(string)
+		if val, exist := defaults[name]; exist {
+			if e.selectorDefaults == nil {
+				e.selectorDefaults = make(map[string]Value)
+			}
+			e.selectorDefaults[name] = unifyType(val)
+		}
+	}
+
+	if policy != MissingSelectorFalse {
+		return
+	}
+	for _, n := range e.nodes {
+		if typ := n.getNodeType(); typ == operator || typ == fastOperator {
+			n.operator = wrapMissingValue(n.value.(string), n.operator)
+		}
+	}
+}
+
+// wrapMissingValue wraps the operator to evaluate the comparisons with the missing values to false
+func wrapMissingValue(name string, op Operator) Operator {
+	isComparison := comparisonOperators[name]
+	return func(ctx *Ctx, params []Value) (Value, error) {
+		for _, param := range params {
+			if m, ok := param.(missingValue); ok {
+				if isComparison {
+					return false, nil
+				}
+				return m, nil
+			}
+		}
+		return op(ctx, params)
+	}
+}
+
+// getSelectorValue gets the value of the selector node,
+// the missing value is handled by the policy of the expression
+func (e *Expr) getSelectorValue(ctx *Ctx, n *node) (Value, error) {
+	name := n.value.(string)
+	res, err := ctx.GetValue(n.selKey, name)
+	if err == nil || !errors.Is(err, ErrSelectorNotExist) {
+		return res, err
+	}
+	return e.missingSelectorValue(name, err)
+}
+
+// missingSelectorValue returns the default value of the missing selector, or the missing value
+// under the MissingSelectorFalse policy, otherwise the error of the selector is returned
+func (e *Expr) missingSelectorValue(name string, err error) (Value, error) {
+	if val, exist := e.selectorDefaults[name]; exist {
+		return val, nil
+	}
+	if e.missingSelector == MissingSelectorFalse {
+		return missingValue{err: err}, nil
+	}
+	return nil, err
+}
+
+func (e *Expr) getNodeValue(ctx *Ctx, n *node) (Value, error) {
+	if n.flag&nodeTypeMask == constant {
+		return n.value, nil
+	}
+	return e.getSelectorValue(ctx, n)
+}
+
+// checkMissingResult fails the evaluation whose result is a missing value,
+// which isn't contained by any comparison
+func checkMissingResult(res Value, err error) (Value, error) {
+	if m, ok := res.(missingValue); ok && err == nil {
+		return nil, m.err
+	}
+	return res, err
+}
@@ -0,0 +1,42 @@
+package eval
+
+// The null value is the nil Value, which is written as null in all the syntax modes,
+// e.g. (= nickname null), and it's also the value of the JSON null.
+//
+// The null value equals to null only, e.g. (= null null) is true and (!= 0 null) is true.
+// The other operators fail with the null params by default, e.g. the ordering comparisons.
+// The arithmetic operators return null if any param is null with the NullPropagation option,
+// so that (> (+ bonus salary) 100) fails for the null bonus, while (coalesce (+ bonus salary) 0)
+// is evaluated to 0.
+
+// nullPropagatingOperators return null if any param is null with the NullPropagation option
+var nullPropagatingOperators = map[string]bool{
+	"add": true, "sub": true, "mul": true, "div": true, "mod": true,
+	"+": true, "-": true, "*": true, "/": true, "%": true,
+}
+
+func propagateNull(op Operator) Operator {
+	return func(ctx *Ctx, params []Value) (Value, error) {
+		for _, p := range params {
+			if p == nil {
+				return nil, nil
+			}
+		}
+		return op(ctx, params)
+	}
+}
+
+// coalesce returns the first param which isn't null, or null if all of them are null.
+// All the params are evaluated before the operator is executed.
+func coalesce(_ *Ctx, params []Value) (Value, error) {
+	const op = "coalesce"
+	if len(params) == 0 {
+		return nil, ParamsCountError(op, 1, 0)
+	}
+	for _, p := range params {
+		if p != nil {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,127 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestNull(t *testing.T) {
+	vals := map[string]interface{}{
+		"nickname": nil,
+		"name":     "Larry",
+		"bonus":    nil,
+		"salary":   100,
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(= null null)`, res: true},
+		{expr: `(= nickname null)`, res: true},
+		{expr: `(!= name null)`, res: true},
+		{expr: `(= 0 null)`, res: false},
+		{expr: `(coalesce nickname name)`, res: "Larry"},
+		{expr: `(coalesce nickname bonus)`, res: nil},
+		{expr: `(coalesce bonus 0)`, res: int64(0)},
+		{expr: `(> bonus 10)`, errMsg: "operator: >"},
+		{expr: `(+ bonus salary)`, errMsg: "operator: +"},
+		{expr: `(+ bonus salary)`, opts: []CompileOption{EnableNullPropagation}, res: nil},
+		{expr: `(+ salary 1)`, opts: []CompileOption{EnableNullPropagation}, res: int64(101)},
+		{expr: `(coalesce (* bonus 2) salary)`, opts: []CompileOption{EnableNullPropagation}, res: int64(100)},
+		{expr: `(coalesce (+ bonus 1.5) 0)`, opts: []CompileOption{EnableNullPropagation, Optimizations(false)}, res: int64(0)},
+		{expr: `nickname == null || name != null`, opts: []CompileOption{EnableInfixSyntax}, res: true},
+		{expr: `coalesce(nickname, name) == "Larry"`, opts: []CompileOption{EnableCELSyntax}, res: true},
+		{expr: `nickname IS NULL AND name IS NOT NULL`, opts: []CompileOption{EnableSQLSyntax}, res: true},
+		{expr: `bonus = NULL`, opts: []CompileOption{EnableSQLSyntax}, res: true},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, EnableStringSelectors)...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+}
+
+func TestNull_Expr(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors, EnableNullPropagation)
+	expr, err := Compile(cc, `(if (= nickname null) (coalesce (+ bonus 1) 0) 1)`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(if (= nickname null) (coalesce (+ bonus 1) 0) 1)`)
+
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	loaded, err := UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+
+	ctx := NewCtxWithMap(cc, map[string]interface{}{"nickname": nil, "bonus": nil})
+	for _, e := range []*Expr{expr, loaded} {
+		res, err := e.Eval(ctx)
+		assertNil(t, err)
+		assertEquals(t, res, int64(0))
+	}
+
+	_, err = Compile(NewCompileConfig(EnableSQLSyntax, EnableStringSelectors), `nickname IS 1`)
+	assertErrStrContains(t, err, "want: NULL")
+
+	_, err = Eval(`(coalesce)`, nil)
+	assertErrStrContains(t, err, "coalesce")
+}
+
+func TestNull_OptionalChaining(t *testing.T) {
+	vals := map[string]interface{}{
+		"event": map[string]interface{}{
+			"user":    map[string]interface{}{"name": "larry", "address": nil},
+			"payment": nil,
+		},
+		"items": []cartItem{{Category: "books", Price: 30}},
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `event?.user?.name`, res: "larry"},
+		{expr: `event?.user?.address?.city`, res: nil},
+		{expr: `event?.device?.os`, res: nil},
+		{expr: `event?.payment.amount`, res: nil},
+		{expr: `(= event?.user?.address?.city null)`, res: true},
+		{expr: `(coalesce event?.user?.address?.city "unknown")`, res: "unknown"},
+		{expr: `(> (coalesce event?.payment?.amount 0) 100)`, res: false},
+		{expr: `(getOrNil event "user" "name")`, res: "larry"},
+		{expr: `(getOrNil items "price")`, res: nil},
+		{expr: `(any items item (= item?.discount null))`, res: true},
+		{expr: `event?.payment?.amount == null ? "unpaid" : "paid"`, opts: []CompileOption{EnableInfixSyntax}, res: "unpaid"},
+		{expr: `coalesce(event?.user?.name, "") == "larry"`, opts: []CompileOption{EnableInfixSyntax}, res: true},
+		{expr: `(= event?.user?.name "larry")`, opts: []CompileOption{EnableTypeCheck}, res: true},
+
+		{expr: `event.user.address.city`, errMsg: "field city is not accessible"},
+		{expr: `(getOrNil event 1)`, errMsg: "expected: string"},
+		{expr: `(getOrNil event)`, errMsg: "operator: getOrNil, expected: 2, got: 1"},
+		{expr: `event?.user?`, errMsg: "can not parse token"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+	expr, err := Compile(cc, `(coalesce event?.user.address?.city "unknown")`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(coalesce (getOrNil event "user" "address" "city") "unknown")`)
+}
@@ -3,11 +3,18 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+// moduleSeparator separates the namespaces of the modules and the names of their operators, e.g. geo.distance
+const moduleSeparator = "."
+
 func RegisterOperator(cc *CompileConfig, name string, op Operator) error {
 	if _, exist := builtinOperators[name]; exist {
 		return fmt.Errorf("operator already exist %s", name)
@@ -18,50 +25,92 @@ func RegisterOperator(cc *CompileConfig, name string, op Operator) error {
 	}
 
 	cc.OperatorMap[name] = op
+	cc.changed()
+	return nil
+}
+
+// RegisterModule registers the operators of a module under the namespace of the name,
+// e.g. the operator distance of the module geo is called by geo.distance, so that the operators
+// of the third-party packages can coexist without name collisions.
+// The name can be nested, e.g. acme.geo, and the module can only be registered once,
+// nothing is registered if it conflicts.
+func (cc *CompileConfig) RegisterModule(name string, ops map[string]Operator) error {
+	for _, ns := range strings.Split(name, moduleSeparator) {
+		if !isIdentName(ns) {
+			return fmt.Errorf("register module error, invalid module name: %q", name)
+		}
+	}
+	prefix := name + moduleSeparator
+	for opName := range cc.OperatorMap {
+		// the nested modules are different modules, e.g. acme and acme.geo
+		if strings.HasPrefix(opName, prefix) && !strings.Contains(opName[len(prefix):], moduleSeparator) {
+			return fmt.Errorf("register module error, module already exist %s", name)
+		}
+	}
+	for opName := range ops {
+		if !isIdentName(opName) {
+			return fmt.Errorf("register module error, module: %s, invalid operator name: %q", name, opName)
+		}
+	}
+
+	for opName, op := range ops {
+		cc.OperatorMap[prefix+opName] = op
+	}
+	cc.changed()
+	return nil
+}
+
+// RegisterSpecializer registers the specializer of the operator registered to the cc,
+// which builds the specialized operator with the constant params at compile time
+func RegisterSpecializer(cc *CompileConfig, name string, s OperatorSpecializer) error {
+	if _, exist := cc.OperatorMap[name]; !exist {
+		return fmt.Errorf("operator not registered %s", name)
+	}
+	if _, exist := cc.OperatorSpecializers[name]; exist {
+		return fmt.Errorf("specializer already exist %s", name)
+	}
+
+	cc.OperatorSpecializers[name] = s
+	cc.changed()
 	return nil
 }
 
+// LookupOperator returns the operator of the name, from the builtin operators or
+// the ones registered to the cc, the cc can be nil if only the builtin ones are needed
+func LookupOperator(cc *CompileConfig, name string) (Operator, bool) {
+	if cc == nil {
+		cc = NewCompileConfig()
+	}
+	return cc.getOperator(name)
+}
+
 var (
-	builtinOperators = map[string]Operator{
-		// arithmetic
-		"add": arithmetic{mode: add}.execute,
-		"sub": arithmetic{mode: sub}.execute,
-		"mul": arithmetic{mode: mul}.execute,
-		"div": arithmetic{mode: div}.execute,
-		"mod": arithmetic{mode: mod}.execute,
-		"+":   arithmetic{mode: add}.execute,
-		"-":   arithmetic{mode: sub}.execute,
-		"*":   arithmetic{mode: mul}.execute,
-		"/":   arithmetic{mode: div}.execute,
-		"%":   arithmetic{mode: mod}.execute,
+	builtinOperators = mergeOperators(numericOperators(false), map[string]Operator{
+		// integer division
+		"truncdiv": intDivision{mode: truncDiv}.execute,
+		"floordiv": intDivision{mode: floorDiv}.execute,
+		"floormod": intDivision{mode: floorMod}.execute,
 
 		// logic
 		"and": logic{mode: and}.execute,
 		"or":  logic{mode: or}.execute,
 		"xor": logic{mode: xor}.execute,
 		"not": logicNot,
-		"&":   logic{mode: and}.execute,
-		"|":   logic{mode: or}.execute,
-		"^":   logic{mode: xor}.execute,
+		"&":   maskOrLogic{mode: and, bitMode: bitAnd}.execute,
+		"|":   maskOrLogic{mode: or, bitMode: bitOr}.execute,
+		"^":   maskOrLogic{mode: xor, bitMode: bitXor}.execute,
 		"!":   logicNot,
 
-		// comparison
-		"eq":      comparisonEquals,
-		"ne":      comparisonNotEquals,
-		"gt":      comparison{mode: greater}.execute,
-		"lt":      comparison{mode: less}.execute,
-		"ge":      comparison{mode: greaterEquals}.execute,
-		"le":      comparison{mode: lessEquals}.execute,
-		"=":       comparisonEquals,
-		"!=":      comparisonNotEquals,
-		">":       comparison{mode: greater}.execute,
-		"<":       comparison{mode: less}.execute,
-		">=":      comparison{mode: greaterEquals}.execute,
-		"<=":      comparison{mode: lessEquals}.execute,
-		"between": comparisonBetween,
+		// bitwise
+		"bitand": bitwise{mode: bitAnd}.execute,
+		"bitor":  bitwise{mode: bitOr}.execute,
+		"bitxor": bitwise{mode: bitXor}.execute,
+		"<<":     bitwise{mode: shiftLeft}.execute,
+		">>":     bitwise{mode: shiftRight}.execute,
 
 		// list
 		"in":      listIn,
+		"not_in":  listNotIn,
 		"overlap": listOverlap,
 
 		// time
@@ -76,9 +125,118 @@ var (
 		// version
 		"version":   versionConvert{mode: version, validLen: 3}.execute,
 		"t_version": versionConvert{mode: toVersion, validLen: 3}.execute,
+
+		"semverCompare":   semverCompare,
+		"semverSatisfies": semverSatisfies,
+
+		// string
+		"matches": stringMatches,
+		"like":    likeMatches,
+
+		"equalsIgnoreCase": equalsIgnoreCase,
+		"compare":          collationCompare{}.execute,
+		"format":           formatString,
+		"sprintf":          formatString,
+
+		// url
+		"urlScheme":     urlPart{mode: urlScheme}.execute,
+		"urlHost":       urlPart{mode: urlHost}.execute,
+		"urlPath":       urlPart{mode: urlPath}.execute,
+		"urlQueryParam": urlPart{mode: urlQueryParam}.execute,
+
+		// collection
+		"list":  newList,
+		"dict":  newDict,
+		"index": indexOf,
+		"len":   lengthOf{}.execute,
+		"field": getField,
+
+		// json
+		"jsonGet": jsonGet,
+
+		// conversion
+		"toInt":      valueConvert{mode: castInt}.execute,
+		"toFloat":    valueConvert{mode: castFloat}.execute,
+		"toString":   valueConvert{mode: castString}.execute,
+		"toBool":     valueConvert{mode: castBool}.execute,
+		"parseInt":   valueConvert{mode: parseInteger}.execute,
+		"parseFloat": valueConvert{mode: parseFloating}.execute,
+
+		// decimal
+		"decimal": decimalConvert,
+
+		// null
+		"coalesce": coalesce,
+		"getOrNil": getOrNil,
+	})
+
+	// strictNumericOperators are used instead of the builtin ones if StrictNumeric is enabled
+	strictNumericOperators = numericOperators(true)
+
+	// builtinSpecializers build specialized operators at compile time
+	// with the params which are constants
+	builtinSpecializers = map[string]OperatorSpecializer{
+		"matches": specializeMatches,
+		"like":    specializeLike,
+
+		"semverSatisfies": specializeSemverSatisfies,
+		"jsonGet":         specializeJSONGet,
+		"format":          specializeFormat,
+		"sprintf":         specializeFormat,
+		"in":              listMembershipSpecializer(in),
+		"not_in":          listMembershipSpecializer(notIn),
 	}
 )
 
+// numericOperators builds the arithmetic and comparison operators,
+// the int64 params are promoted to float64 when they are mixed with float64 params,
+// unless strict is true, in which case the mixed params result in errors.
+func numericOperators(strict bool) map[string]Operator {
+	return map[string]Operator{
+		// arithmetic
+		"add": arithmetic{mode: add, strict: strict}.execute,
+		"sub": arithmetic{mode: sub, strict: strict}.execute,
+		"mul": arithmetic{mode: mul, strict: strict}.execute,
+		"div": arithmetic{mode: div, strict: strict}.execute,
+		"mod": arithmetic{mode: mod, strict: strict}.execute,
+		"+":   arithmetic{mode: add, strict: strict}.execute,
+		"-":   arithmetic{mode: sub, strict: strict}.execute,
+		"*":   arithmetic{mode: mul, strict: strict}.execute,
+		"/":   arithmetic{mode: div, strict: strict}.execute,
+		"%":   arithmetic{mode: mod, strict: strict}.execute,
+
+		// comparison
+		"eq":      equality{mode: equals, strict: strict}.execute,
+		"ne":      equality{mode: notEquals, strict: strict}.execute,
+		"gt":      comparison{mode: greater, strict: strict}.execute,
+		"lt":      comparison{mode: less, strict: strict}.execute,
+		"ge":      comparison{mode: greaterEquals, strict: strict}.execute,
+		"le":      comparison{mode: lessEquals, strict: strict}.execute,
+		"=":       equality{mode: equals, strict: strict}.execute,
+		"!=":      equality{mode: notEquals, strict: strict}.execute,
+		">":       comparison{mode: greater, strict: strict}.execute,
+		"<":       comparison{mode: less, strict: strict}.execute,
+		">=":      comparison{mode: greaterEquals, strict: strict}.execute,
+		"<=":      comparison{mode: lessEquals, strict: strict}.execute,
+		"between": comparisonBetween{strict: strict}.execute,
+	}
+}
+
+func mergeOperators(maps ...map[string]Operator) map[string]Operator {
+	res := make(map[string]Operator)
+	for _, m := range maps {
+		for name, op := range m {
+			res[name] = op
+		}
+	}
+	return res
+}
+
+// OperatorSpecializer builds a specialized operator with the constant params at compile time,
+// e.g. the constant pattern of matches is compiled only once, consts[i] is the value of the
+// i-th param if known[i] is true. It returns a nil Operator if the operator can't be specialized.
+type OperatorSpecializer func(consts []Value, known []bool) (Operator, error)
+
 type mode int
 
 const (
@@ -88,6 +246,9 @@ const (
 	mul
 	div
 	mod
+	truncDiv
+	floorDiv
+	floorMod
 
 	// logical
 	and
@@ -95,6 +256,13 @@ const (
 	xor
 	not
 
+	// bitwise
+	bitAnd
+	bitOr
+	bitXor
+	shiftLeft
+	shiftRight
+
 	// comparison
 	equals
 	notEquals
@@ -106,6 +274,7 @@ const (
 
 	// list
 	in
+	notIn
 	overlap
 
 	// time
@@ -118,6 +287,35 @@ const (
 
 	version
 	toVersion
+
+	// conversion
+	castInt
+	castFloat
+	castString
+	castBool
+	parseInteger
+	parseFloating
+
+	// url
+	urlScheme
+	urlHost
+	urlPath
+	urlQueryParam
+
+	// collection
+	makeList
+	makeDict
+	index
+	length
+
+	// higher-order
+	anyMatch
+	allMatch
+	noneMatch
+	countMatch
+	filterList
+	mapList
+	letBinding
 )
 
 var modeNames = [...]string{
@@ -128,12 +326,23 @@ var modeNames = [...]string{
 	div: "div",
 	mod: "mod",
 
+	truncDiv: "truncdiv",
+	floorDiv: "floordiv",
+	floorMod: "floormod",
+
 	// logical
 	and: "and",
 	or:  "or",
 	xor: "xor",
 	not: "not",
 
+	// bitwise
+	bitAnd:     "bitand",
+	bitOr:      "bitor",
+	bitXor:     "bitxor",
+	shiftLeft:  "<<",
+	shiftRight: ">>",
+
 	// comparison
 	equals:        "eq",
 	notEquals:     "ne",
@@ -145,6 +354,7 @@ var modeNames = [...]string{
 
 	// list
 	in:      "in",
+	notIn:   "not_in",
 	overlap: "overlap",
 
 	// time
@@ -158,18 +368,55 @@ var modeNames = [...]string{
 	// version
 	version:   "version",
 	toVersion: "toVersion",
+
+	// conversion
+	castInt:       "toInt",
+	castFloat:     "toFloat",
+	castString:    "toString",
+	castBool:      "toBool",
+	parseInteger:  "parseInt",
+	parseFloating: "parseFloat",
+
+	// url
+	urlScheme:     "urlScheme",
+	urlHost:       "urlHost",
+	urlPath:       "urlPath",
+	urlQueryParam: "urlQueryParam",
+
+	// collection
+	makeList: "list",
+	makeDict: "dict",
+	index:    "index",
+	length:   "len",
+
+	// higher-order
+	anyMatch:   "any",
+	allMatch:   "all",
+	noneMatch:  "none",
+	countMatch: "count",
+	filterList: "filter",
+	mapList:    "map",
+	letBinding: "let",
 }
 
 const (
 	typeBool    = "bool"
 	typeInt     = "int64"
+	typeFloat   = "float64"
 	typeStr     = "string"
 	typeIntList = "[]int64"
 	typeStrList = "[]string"
+	typeMap     = "map"
+	typeList    = "list"
 )
 
 type arithmetic struct {
-	mode mode
+	mode   mode
+	strict bool
+	// the integer divide-by-zero and overflow are handled by executeChecked
+	// if the policy isn't ArithmeticDefault
+	policy   ArithmeticPolicy
+	sentinel Value
 }
 
 func (a arithmetic) execute(_ *Ctx, params []Value) (Value, error) {
@@ -181,9 +428,54 @@ func (a arithmetic) execute(_ *Ctx, params []Value) (Value, error) {
 	for i, p := range params {
 		v, ok := p.(int64)
 		if !ok {
+			if _, isFloat := p.(float64); isFloat {
+				return a.executeFloat(params)
+			}
 			return nil, errTypeInt(a.mode, p)
 		}
 
+		if i == 0 {
+			res = v
+		} else if a.policy != ArithmeticDefault {
+			r, stop, err := a.executeChecked(res, v)
+			if stop {
+				return r, err
+			}
+			res = r.(int64)
+		} else {
+			switch a.mode {
+			case add:
+				res += v
+			case sub:
+				res -= v
+			case mul:
+				res *= v
+			case div:
+				if v == 0 {
+					return nil, OpExecError("div", errDivideByZero)
+				}
+				res /= v
+			case mod:
+				if v == 0 {
+					return nil, OpExecError("mod", errDivideByZero)
+				}
+				res %= v
+			default:
+				return 0, errInvalidMode(a.mode, "arithmetic")
+			}
+		}
+	}
+	return res, nil
+}
+
+func (a arithmetic) executeFloat(params []Value) (Value, error) {
+	var res float64
+	for i, p := range params {
+		v, ok := toFloat(p, a.strict)
+		if !ok {
+			return nil, errTypeFloat(a.mode, p)
+		}
+
 		if i == 0 {
 			res = v
 		} else {
@@ -203,7 +495,7 @@ func (a arithmetic) execute(_ *Ctx, params []Value) (Value, error) {
 				if v == 0 {
 					return nil, OpExecError("mod", errors.New("divide by zero"))
 				}
-				res %= v
+				res = math.Mod(res, v)
 			default:
 				return 0, errInvalidMode(a.mode, "arithmetic")
 			}
@@ -212,6 +504,35 @@ func (a arithmetic) execute(_ *Ctx, params []Value) (Value, error) {
 	return res, nil
 }
 
+// toFloat converts the numeric param to float64,
+// the int64 param is not converted if strict is true
+func toFloat(p Value, strict bool) (float64, bool) {
+	switch v := p.(type) {
+	case float64:
+		return v, true
+	case int64:
+		if !strict {
+			return float64(v), true
+		}
+	}
+	return 0, false
+}
+
+// isMixedNumbers returns whether the params are numbers and at least one of them is float64
+func isMixedNumbers(params ...Value) bool {
+	var hasFloat bool
+	for _, p := range params {
+		switch p.(type) {
+		case float64:
+			hasFloat = true
+		case int64:
+		default:
+			return false
+		}
+	}
+	return hasFloat
+}
+
 type logic struct {
 	mode mode
 }
@@ -258,7 +579,8 @@ func logicNot(_ *Ctx, params []Value) (Value, error) {
 }
 
 type comparison struct {
-	mode mode
+	mode   mode
+	strict bool
 }
 
 func (c comparison) execute(_ *Ctx, params []Value) (Value, error) {
@@ -267,12 +589,14 @@ func (c comparison) execute(_ *Ctx, params []Value) (Value, error) {
 	}
 
 	i, ok := params[0].(int64)
-	if !ok {
-		return nil, errTypeInt(c.mode, params[0])
-	}
-
-	j, ok := params[1].(int64)
-	if !ok {
+	j, ok2 := params[1].(int64)
+	if !ok || !ok2 {
+		if isMixedNumbers(params...) {
+			return c.executeFloat(params)
+		}
+		if !ok {
+			return nil, errTypeInt(c.mode, params[0])
+		}
 		return nil, errTypeInt(c.mode, params[1])
 	}
 
@@ -290,38 +614,127 @@ func (c comparison) execute(_ *Ctx, params []Value) (Value, error) {
 	}
 }
 
-func comparisonEquals(_ *Ctx, params []Value) (Value, error) {
-	if len(params) < 2 {
-		return nil, errCnt2(equals, params)
+func (c comparison) executeFloat(params []Value) (Value, error) {
+	i, ok := toFloat(params[0], c.strict)
+	if !ok {
+		return nil, errTypeFloat(c.mode, params[0])
+	}
+	j, ok := toFloat(params[1], c.strict)
+	if !ok {
+		return nil, errTypeFloat(c.mode, params[1])
 	}
 
-	if len(params) == 2 {
-		return params[0] == params[1], nil
+	switch c.mode {
+	case greater:
+		return i > j, nil
+	case less:
+		return i < j, nil
+	case greaterEquals:
+		return i >= j, nil
+	case lessEquals:
+		return i <= j, nil
+	default:
+		return false, errInvalidMode(c.mode, "comparison")
 	}
+}
 
-	v := params[0]
-	for _, p := range params {
-		if v != p {
-			return false, nil
+type equality struct {
+	mode   mode
+	strict bool
+}
+
+func (e equality) execute(_ *Ctx, params []Value) (Value, error) {
+	switch e.mode {
+	case equals:
+		if len(params) < 2 {
+			return nil, errCnt2(equals, params)
+		}
+		v := params[0]
+		for _, p := range params[1:] {
+			if !e.equals(v, p) {
+				return false, nil
+			}
 		}
+		return true, nil
+	case notEquals:
+		if len(params) != 2 {
+			return nil, errCnt2(notEquals, params)
+		}
+		return !e.equals(params[0], params[1]), nil
+	default:
+		return false, errInvalidMode(e.mode, "comparison")
 	}
-	return true, nil
 }
 
-func comparisonNotEquals(_ *Ctx, params []Value) (Value, error) {
-	if len(params) != 2 {
-		return nil, errCnt2(notEquals, params)
+// equals compares int64 and float64 by their numeric values if the promotion is allowed,
+// the lists and maps are compared by their elements of the unified types, e.g. (= tags ("a" "b")),
+// which panic if compared by ==
+func (e equality) equals(a, b Value) bool {
+	if !e.strict && isMixedNumbers(a, b) {
+		x, _ := toFloat(a, false)
+		y, _ := toFloat(b, false)
+		return x == y
+	}
+	switch a.(type) {
+	case nil, bool, int64, float64, string:
+		return a == b
+	}
+	if b == nil {
+		return false
 	}
 
-	return params[0] != params[1], nil
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case va.Kind() == reflect.Slice && vb.Kind() == reflect.Slice:
+		if va.Len() != vb.Len() {
+			return false
+		}
+		for i := 0; i < va.Len(); i++ {
+			if !e.equals(unifyType(va.Index(i).Interface()), unifyType(vb.Index(i).Interface())) {
+				return false
+			}
+		}
+		return true
+	case va.Kind() == reflect.Map && vb.Kind() == reflect.Map:
+		if va.Len() != vb.Len() || va.Type().Key() != vb.Type().Key() {
+			return false
+		}
+		iter := va.MapRange()
+		for iter.Next() {
+			v := vb.MapIndex(iter.Key())
+			if !v.IsValid() || !e.equals(unifyType(iter.Value().Interface()), unifyType(v.Interface())) {
+				return false
+			}
+		}
+		return true
+	case !va.Type().Comparable():
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
 }
 
-func comparisonBetween(_ *Ctx, params []Value) (Value, error) {
+type comparisonBetween struct {
+	strict bool
+}
+
+func (c comparisonBetween) execute(_ *Ctx, params []Value) (Value, error) {
 	const op = "between"
 	if len(params) != 3 {
 		return nil, ParamsCountError(op, 3, len(params))
 	}
 
+	if isMixedNumbers(params...) {
+		var fs [3]float64
+		for i, p := range params {
+			v, ok := toFloat(p, c.strict)
+			if !ok {
+				return nil, errTypeFloat(between, p)
+			}
+			fs[i] = v
+		}
+		return fs[1] <= fs[0] && fs[0] <= fs[2], nil
+	}
+
 	v, ok := params[0].(int64)
 	if !ok {
 		return nil, errTypeInt(between, params[0])
@@ -338,15 +751,40 @@ func comparisonBetween(_ *Ctx, params []Value) (Value, error) {
 }
 
 func listIn(_ *Ctx, params []Value) (Value, error) {
-	const op = "in"
+	res, err := listContains(in, params)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func listNotIn(_ *Ctx, params []Value) (Value, error) {
+	res, err := listContains(notIn, params)
+	if err != nil {
+		return nil, err
+	}
+	return !res, nil
+}
+
+func listContains(m mode, params []Value) (bool, error) {
+	op := modeNames[m]
 	if len(params) != 2 {
-		return nil, errCnt2(in, params)
+		return false, errCnt2(m, params)
+	}
+	if list, ok := params[1].([]Value); ok {
+		// the list of the mixed types, e.g. (list 1.5 "a")
+		for _, e := range list {
+			if (equality{strict: true}).equals(params[0], e) {
+				return true, nil
+			}
+		}
+		return false, nil
 	}
 	switch v := params[0].(type) {
 	case string:
 		list, ok := params[1].([]string)
 		if !ok {
-			return nil, ParamTypeError(op, typeStrList, params[1])
+			return false, ParamTypeError(op, typeStrList, params[1])
 		}
 		for _, s := range list {
 			if s == v {
@@ -368,9 +806,9 @@ func listIn(_ *Ctx, params []Value) (Value, error) {
 				return false, nil
 			}
 		}
-		return nil, ParamTypeError(op, typeIntList, params[1])
+		return false, ParamTypeError(op, typeIntList, params[1])
 	}
-	return nil, OpExecError(op, errors.New("unsupported list type"))
+	return false, OpExecError(op, errors.New("unsupported list type"))
 }
 
 func listOverlap(_ *Ctx, params []Value) (Value, error) {
@@ -447,6 +885,129 @@ func listOverlap(_ *Ctx, params []Value) (Value, error) {
 	return nil, ParamTypeError(op, typeStrList, params[0])
 }
 
+// newList builds the list with the elements, e.g. (list 1 x 3),
+// it's []int64 or []string if the elements are all int64 or all string, otherwise []Value, e.g. (list 1.5 "a")
+func newList(_ *Ctx, params []Value) (Value, error) {
+	// the params are reused by the next operators
+	return narrowList(append(make([]Value, 0, len(params)), params...)), nil
+}
+
+// newDict builds the map with the key value pairs, e.g. (dict "a" 1 "b" x)
+func newDict(_ *Ctx, params []Value) (Value, error) {
+	op := modeNames[makeDict]
+	if len(params)%2 != 0 {
+		return nil, OpExecError(op, fmt.Errorf("params should be key value pairs, got: %d params", len(params)))
+	}
+
+	res := make(map[string]Value, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		k, ok := params[i].(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, params[i])
+		}
+		res[k] = params[i+1]
+	}
+	return res, nil
+}
+
+// lengthOf returns the length of the string, list or map, e.g. (len name), (len tags)
+// the strings are counted by the runes, or by the bytes with the ByteLength option
+type lengthOf struct {
+	bytes bool
+}
+
+func (l lengthOf) execute(_ *Ctx, params []Value) (Value, error) {
+	if len(params) != 1 {
+		return nil, ParamsCountError(modeNames[length], 1, len(params))
+	}
+
+	switch c := params[0].(type) {
+	case string:
+		if l.bytes {
+			return int64(len(c)), nil
+		}
+		return int64(utf8.RuneCountInString(c)), nil
+	case []int64:
+		return int64(len(c)), nil
+	case []string:
+		return int64(len(c)), nil
+	case []Value:
+		return int64(len(c)), nil
+	case []interface{}:
+		return int64(len(c)), nil
+	case map[string]Value:
+		return int64(len(c)), nil
+	case map[string]interface{}:
+		return int64(len(c)), nil
+	}
+	return nil, ParamTypeError(modeNames[length], "string, list or map", params[0])
+}
+
+// indexOf returns the element of the list at the index, or the value of the map with the key
+// e.g. (index list 0), (index map "key")
+func indexOf(_ *Ctx, params []Value) (Value, error) {
+	op := modeNames[index]
+	if len(params) != 2 {
+		return nil, errCnt2(index, params)
+	}
+
+	var listIndex = func(size int) (int, error) {
+		i, ok := params[1].(int64)
+		if !ok {
+			return 0, ParamTypeError(op, typeInt, params[1])
+		}
+		if i < 0 || i >= int64(size) {
+			return 0, OpExecError(op, fmt.Errorf("index out of range [%d] with length %d", i, size))
+		}
+		return int(i), nil
+	}
+
+	var mapKey = func() (string, error) {
+		k, ok := params[1].(string)
+		if !ok {
+			return "", ParamTypeError(op, typeStr, params[1])
+		}
+		return k, nil
+	}
+
+	switch c := params[0].(type) {
+	case map[string]Value:
+		k, err := mapKey()
+		if err != nil {
+			return nil, err
+		}
+		v, exist := c[k]
+		if !exist {
+			return nil, OpExecError(op, fmt.Errorf("key not found: %s", k))
+		}
+		return v, nil
+	case map[string]interface{}:
+		// the map returned by the selectors
+		k, err := mapKey()
+		if err != nil {
+			return nil, err
+		}
+		v, exist := c[k]
+		if !exist {
+			return nil, OpExecError(op, fmt.Errorf("key not found: %s", k))
+		}
+		return unifyType(v), nil
+	case nil:
+		return nil, ParamTypeError(op, "list or map", params[0])
+	}
+
+	// the lists of any kinds, e.g. []interface{} decoded from JSON
+	size, at, err := listElems(op, params[0])
+	if err != nil {
+		return nil, ParamTypeError(op, "list or map", params[0])
+	}
+	i, err := listIndex(size)
+	if err != nil {
+		return nil, err
+	}
+	return unifyType(at(i)), nil
+}
+
 const (
 	defaultDatetimeLayout = "2006-01-02 15:04:05"
 	defaultDateLayout     = "2006-01-02"
@@ -546,6 +1107,136 @@ func (c versionConvert) execute(_ *Ctx, params []Value) (Value, error) {
 	return res, nil
 }
 
+// regexCache caches the regular expressions which are not constants
+var regexCache = newLRUCache(1024)
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, exist := regexCache.get(pattern); exist {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.add(pattern, re)
+	return re, nil
+}
+
+// stringMatches reports whether the string contains any match of the regular expression
+// e.g. (matches s pattern)
+func stringMatches(_ *Ctx, params []Value) (Value, error) {
+	const op = "matches"
+	if len(params) != 2 {
+		return nil, ParamsCountError(op, 2, len(params))
+	}
+	s, ok := params[0].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, params[0])
+	}
+	pattern, ok := params[1].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, params[1])
+	}
+	re, err := compileRegex(pattern)
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	return re.MatchString(s), nil
+}
+
+// specializeMatches compiles the constant pattern only once at compile time
+func specializeMatches(consts []Value, known []bool) (Operator, error) {
+	const op = "matches"
+	if len(consts) != 2 || !known[1] {
+		return nil, nil
+	}
+	pattern, ok := consts[1].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, consts[1])
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	return func(_ *Ctx, params []Value) (Value, error) {
+		if len(params) != 2 {
+			return nil, ParamsCountError(op, 2, len(params))
+		}
+		s, ok := params[0].(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, params[0])
+		}
+		return re.MatchString(s), nil
+	}, nil
+}
+
+// setLookupThreshold is the minimum size of the constant list
+// which is converted to a hash set for the membership checks
+const setLookupThreshold = 8
+
+// listMembershipSpecializer converts the constant list of in/not_in to a hash set at compile time
+func listMembershipSpecializer(m mode) OperatorSpecializer {
+	return func(consts []Value, known []bool) (Operator, error) {
+		if len(consts) != 2 || !known[1] {
+			return nil, nil
+		}
+
+		var contains func(v Value) (bool, bool)
+		switch list := consts[1].(type) {
+		case []string:
+			if len(list) < setLookupThreshold {
+				return nil, nil
+			}
+			set := make(map[string]struct{}, len(list))
+			for _, s := range list {
+				set[s] = empty
+			}
+			contains = func(v Value) (bool, bool) {
+				s, ok := v.(string)
+				if !ok {
+					return false, false
+				}
+				_, exist := set[s]
+				return exist, true
+			}
+		case []int64:
+			if len(list) < setLookupThreshold {
+				return nil, nil
+			}
+			set := make(map[int64]struct{}, len(list))
+			for _, i := range list {
+				set[i] = empty
+			}
+			contains = func(v Value) (bool, bool) {
+				i, ok := v.(int64)
+				if !ok {
+					return false, false
+				}
+				_, exist := set[i]
+				return exist, true
+			}
+		default:
+			return nil, nil
+		}
+
+		negate := m == notIn
+		return func(_ *Ctx, params []Value) (Value, error) {
+			if len(params) != 2 {
+				return nil, errCnt2(m, params)
+			}
+			res, ok := contains(params[0])
+			if !ok {
+				// the unexpected types are handled by the linear scan
+				var err error
+				if res, err = listContains(m, params); err != nil {
+					return nil, err
+				}
+			}
+			return res != negate, nil
+		}, nil
+	}
+}
+
 func OpExecError(opName string, err error) error {
 	return fmt.Errorf("operator execuation error, operator: %s, error: %w", opName, err)
 }
@@ -566,6 +1257,10 @@ func errTypeInt(m mode, p Value) error {
 	return ParamTypeError(modeNames[m], typeInt, p)
 }
 
+func errTypeFloat(m mode, p Value) error {
+	return ParamTypeError(modeNames[m], typeFloat, p)
+}
+
 func errTypeBool(m mode, p Value) error {
 	return ParamTypeError(modeNames[m], typeBool, p)
 }
@@ -1,6 +1,9 @@
 package eval
 
 import (
+	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -55,6 +58,111 @@ func TestRegisterOperator(t *testing.T) {
 	assertErrStrContains(t, err, "operator already exist")
 }
 
+func TestRegisterModule(t *testing.T) {
+	contains := func(_ *Ctx, params []Value) (Value, error) {
+		return strings.Contains(params[0].(string), params[1].(string)), nil
+	}
+	distance := func(_ *Ctx, params []Value) (Value, error) {
+		return params[1].(int64) - params[0].(int64), nil
+	}
+
+	cc := NewCompileConfig(RegisterSelKeys(map[string]interface{}{"name": "", "from": 0, "to": 0}))
+	assertNil(t, cc.RegisterModule("strings", map[string]Operator{"contains": contains}))
+	assertNil(t, cc.RegisterModule("acme.geo", map[string]Operator{"distance": distance}))
+	// the same operator names in the different modules
+	assertNil(t, cc.RegisterModule("geo", map[string]Operator{"distance": distance, "contains": contains}))
+
+	vals := map[string]interface{}{"name": "larry", "from": 3, "to": 10}
+	testCases := []struct {
+		opt  CompileOption
+		expr string
+	}{
+		{expr: `(and (strings.contains name "rr") (= (acme.geo.distance from to) 7))`},
+		{opt: EnableInfixSyntax, expr: `strings.contains(name, "rr") && acme.geo.distance(from, to) == 7`},
+		{opt: EnableCELSyntax, expr: `strings.contains(name, "rr") && geo.distance(from, to) == 7 && name.size() == 5`},
+		{opt: EnableSQLSyntax, expr: `strings.contains(name, 'rr') AND geo.distance(from, to) = 7`},
+	}
+	for _, c := range testCases {
+		conf := CopyCompileConfig(cc)
+		if c.opt != nil {
+			c.opt(conf)
+		}
+		res, err := Eval(c.expr, vals, conf)
+		assertNil(t, err)
+		assertEquals(t, res, true)
+	}
+
+	// conflicts
+	err := cc.RegisterModule("strings", map[string]Operator{"hasPrefix": contains})
+	assertErrStrContains(t, err, "module already exist strings")
+	assertNil(t, RegisterOperator(cc, "time.now", contains))
+	err = cc.RegisterModule("time", map[string]Operator{"since": contains})
+	assertErrStrContains(t, err, "module already exist time")
+	err = cc.RegisterModule("acme", map[string]Operator{"distance": distance})
+	assertNil(t, err)
+	err = cc.RegisterModule("math", map[string]Operator{"max": contains, "add.one": contains})
+	assertErrStrContains(t, err, `invalid operator name: "add.one"`)
+	_, exist := cc.OperatorMap["math.max"]
+	assertEquals(t, exist, false)
+	err = cc.RegisterModule("1geo", map[string]Operator{"distance": distance})
+	assertErrStrContains(t, err, `invalid module name: "1geo"`)
+}
+
+func TestRegisterSpecializer(t *testing.T) {
+	var (
+		hasPrefix = func(_ *Ctx, params []Value) (Value, error) {
+			return strings.HasPrefix(params[0].(string), params[1].(string)), nil
+		}
+		specialized int
+		specialize  = func(consts []Value, known []bool) (Operator, error) {
+			if !known[1] {
+				return nil, nil
+			}
+			prefix, ok := consts[1].(string)
+			if !ok {
+				return nil, ParamTypeError("hasPrefix", typeStr, consts[1])
+			}
+			specialized++
+			return func(_ *Ctx, params []Value) (Value, error) {
+				return strings.HasPrefix(params[0].(string), prefix), nil
+			}, nil
+		}
+	)
+
+	cc := NewCompileConfig(RegisterSelKeys(map[string]interface{}{"name": "", "prefix": ""}))
+	err := RegisterSpecializer(cc, "hasPrefix", specialize)
+	assertErrStrContains(t, err, "operator not registered hasPrefix")
+
+	assertNil(t, RegisterOperator(cc, "hasPrefix", hasPrefix))
+	assertNil(t, RegisterSpecializer(cc, "hasPrefix", specialize))
+	err = RegisterSpecializer(cc, "hasPrefix", specialize)
+	assertErrStrContains(t, err, "specializer already exist hasPrefix")
+
+	vals := map[string]interface{}{"name": "larry", "prefix": "la"}
+	for expr, want := range map[string]Value{
+		`(hasPrefix name "la")`:   true,
+		`(hasPrefix name prefix)`: true,
+		`(hasPrefix name "x")`:    false,
+	} {
+		res, err := Eval(expr, vals, cc)
+		assertNil(t, err, expr)
+		assertEquals(t, res, want, expr)
+	}
+	assertEquals(t, specialized, 2)
+
+	// the specializer errors fail the compilation and the unmarshaling
+	_, err = Compile(cc, `(hasPrefix name 1)`)
+	assertErrStrContains(t, err, "operator: hasPrefix, expected: string")
+
+	expr, err := Compile(cc, `(hasPrefix name "la")`)
+	assertNil(t, err)
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	_, err = UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+	assertEquals(t, specialized, 4)
+}
+
 func TestBuiltinOperators(t *testing.T) {
 	toParams := func(vs []int64) []Value {
 		params := make([]Value, len(vs))
@@ -116,7 +224,7 @@ func TestBuiltinOperators(t *testing.T) {
 		{
 			op:     "add",
 			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			res:    2.0, // int64 is promoted to float64
 		},
 
 		// sub
@@ -153,7 +261,7 @@ func TestBuiltinOperators(t *testing.T) {
 		{
 			op:     "sub",
 			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			res:    0.0, // int64 is promoted to float64
 		},
 
 		// mul
@@ -190,7 +298,7 @@ func TestBuiltinOperators(t *testing.T) {
 		{
 			op:     "mul",
 			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			res:    1.0, // int64 is promoted to float64
 		},
 
 		// div
@@ -239,7 +347,7 @@ func TestBuiltinOperators(t *testing.T) {
 		{
 			op:     "div",
 			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			res:    1.0, // int64 is promoted to float64
 		},
 
 		// mod
@@ -288,7 +396,7 @@ func TestBuiltinOperators(t *testing.T) {
 		{
 			op:     "mod",
 			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			res:    0.0, // int64 is promoted to float64
 		},
 
 		// logic
@@ -575,7 +683,7 @@ func TestBuiltinOperators(t *testing.T) {
 		{
 			op:     "gt",
 			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			res:    false, // int64 is promoted to float64
 		},
 
 		// ge
@@ -624,7 +732,7 @@ func TestBuiltinOperators(t *testing.T) {
 		{
 			op:     "ge",
 			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			res:    true, // int64 is promoted to float64
 		},
 
 		// lt
@@ -673,7 +781,7 @@ func TestBuiltinOperators(t *testing.T) {
 		{
 			op:     "lt",
 			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			res:    false, // int64 is promoted to float64
 		},
 
 		// le
@@ -722,7 +830,7 @@ func TestBuiltinOperators(t *testing.T) {
 		{
 			op:     "le",
 			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			res:    true, // int64 is promoted to float64
 		},
 
 		// between
@@ -818,6 +926,37 @@ func TestBuiltinOperators(t *testing.T) {
 			errMsg: paramTypeErrMsg, // type of int param should be int64
 		},
 
+		// not_in
+		{
+			op:     "not_in",
+			params: []Value{int64(0), []int64{1, 2, 3}},
+			res:    true,
+		},
+
+		{
+			op:     "not_in",
+			params: []Value{"a", []string{"a", "b", "c"}},
+			res:    false,
+		},
+
+		{
+			op:     "not_in",
+			params: []Value{int64(1), []string{}},
+			res:    true,
+		},
+
+		{
+			op:     "not_in",
+			params: []Value{"a"},
+			errMsg: paramsCntErrMsg,
+		},
+
+		{
+			op:     "not_in",
+			params: []Value{"a", []int64{1, 2, 3}},
+			errMsg: paramTypeErrMsg,
+		},
+
 		// overlap
 		{
 			op:     "overlap",
@@ -1141,6 +1280,34 @@ func TestBuiltinOperators(t *testing.T) {
 			params: []Value{},
 			errMsg: paramsCntErrMsg,
 		},
+
+		// string
+		// matches
+		{
+			op:     "matches",
+			params: []Value{"larry@gmail.com", `^\w+@gmail\.com$`},
+			res:    true,
+		},
+		{
+			op:     "matches",
+			params: []Value{"larry@yahoo.com", `@gmail`},
+			res:    false,
+		},
+		{
+			op:     "matches",
+			params: []Value{"abc", `(`},
+			errMsg: "error parsing regexp",
+		},
+		{
+			op:     "matches",
+			params: []Value{1, `\d`},
+			errMsg: paramTypeErrMsg,
+		},
+		{
+			op:     "matches",
+			params: []Value{"abc"},
+			errMsg: paramsCntErrMsg,
+		},
 	}
 
 	for _, c := range testCases {
@@ -1157,3 +1324,261 @@ func TestBuiltinOperators(t *testing.T) {
 		assertEquals(t, res, c.res, c)
 	}
 }
+
+func TestMatches(t *testing.T) {
+	vals := map[string]interface{}{
+		"email":   "larry@gmail.com",
+		"pattern": `^larry@`,
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+
+	// constant patterns are compiled at compile time
+	expr, err := Compile(cc, `(matches email "@gmail\.com$")`)
+	assertNil(t, err)
+	res, err := expr.EvalBool(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, true)
+
+	_, err = Compile(cc, `(matches email "(")`)
+	assertErrStrContains(t, err, "error parsing regexp")
+
+	// dynamic patterns are cached
+	defer func(origin *lruCache) { regexCache = origin }(regexCache)
+	regexCache = newLRUCache(1)
+	expr, err = Compile(cc, `(matches email pattern)`)
+	assertNil(t, err)
+	for i := 0; i < 3; i++ {
+		res, err = expr.EvalBool(NewCtxWithMap(cc, vals))
+		assertNil(t, err)
+		assertEquals(t, res, true)
+	}
+	_, cached := regexCache.get(`^larry@`)
+	assertEquals(t, cached, true)
+
+	vals["pattern"] = `^page@`
+	res, err = expr.EvalBool(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, false)
+	_, cached = regexCache.get(`^larry@`)
+	assertEquals(t, cached, false)
+	assertEquals(t, regexCache.len(), 1)
+}
+
+func TestListMembership(t *testing.T) {
+	vals := map[string]interface{}{
+		"id":   7,
+		"name": "g",
+		"tags": []string{"x", "y"},
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+
+	testCases := []struct {
+		expr   string
+		res    bool
+		errMsg string
+	}{
+		// the constant lists are converted to hash sets
+		{expr: `(in id (1 2 3 4 5 6 7 8 9))`, res: true},
+		{expr: `(in id (10 11 12 13 14 15 16 17))`, res: false},
+		{expr: `(not_in id (1 2 3 4 5 6 7 8 9))`, res: false},
+		{expr: `(in name ("a" "b" "c" "d" "e" "f" "g" "h"))`, res: true},
+		{expr: `(not_in name ("a" "b" "c" "d" "e" "f" "h" "i"))`, res: true},
+		{expr: `(in name (1 2 3 4 5 6 7 8 9))`, errMsg: paramTypeErrMsg},
+		{expr: `(not_in id ("a" "b" "c" "d" "e" "f" "g" "h"))`, errMsg: paramTypeErrMsg},
+
+		// small lists and dynamic lists
+		{expr: `(in id (6 7))`, res: true},
+		{expr: `(in "x" tags)`, res: true},
+		{expr: `(not_in name tags)`, res: true},
+	}
+
+	for _, c := range testCases {
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err, c.expr)
+		res, err := expr.EvalBool(NewCtxWithMap(cc, vals))
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+
+	// the specialized operator is used
+	expr, err := Compile(cc, `(in id (1 2 3 4 5 6 7 8 9))`)
+	assertNil(t, err)
+	assertEquals(t, reflect.ValueOf(expr.nodes[0].operator).Pointer() != reflect.ValueOf(Operator(listIn)).Pointer(), true)
+}
+
+func TestIndexJSON(t *testing.T) {
+	var vals map[string]interface{}
+	err := json.Unmarshal([]byte(`{"tags": ["a", "b"], "scores": [1, 2.5], "user": {"roles": ["admin"]}, "matrix": [[1, 2]]}`), &vals)
+	assertNil(t, err)
+	vals["ids"] = []int32{3, 4}
+	cc := NewCompileConfig(RegisterSelKeys(vals), EnableInfixSyntax)
+
+	testCases := []struct {
+		expr   string
+		want   Value
+		errMsg string
+	}{
+		{expr: `tags[0] == "a"`, want: true},
+		{expr: `user.roles[0] == "admin"`, want: true},
+		{expr: `scores[1] > 2`, want: true},
+		{expr: `matrix[0][1]`, want: 2.0},
+		{expr: `ids[1]`, want: int64(4)},
+		{expr: `tags[2]`, errMsg: "index out of range [2] with length 2"},
+		{expr: `len(tags)`, want: int64(2)},
+	}
+
+	for _, c := range testCases {
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.want, c.expr)
+	}
+}
+
+func TestCollections(t *testing.T) {
+	vals := map[string]interface{}{
+		"id":    7,
+		"name":  "g",
+		"tags":  []string{"x", "y"},
+		"attrs": map[string]interface{}{"level": 3},
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+
+	testCases := []struct {
+		expr   string
+		want   Value
+		errMsg string
+	}{
+		{expr: `(list id 8)`, want: []int64{7, 8}},
+		{expr: `(list name "h")`, want: []string{"g", "h"}},
+		{expr: `(list)`, want: []string{}},
+		{expr: `(list id name)`, want: []Value{int64(7), "g"}},
+		{expr: `(list 1.5 2.5)`, want: []Value{1.5, 2.5}},
+		{expr: `(list (list id) tags)`, want: []Value{[]int64{7}, []string{"x", "y"}}},
+		{expr: `(index (list 1.5 name) 1)`, want: "g"},
+		{expr: `(len (list 1.5 name))`, want: int64(2)},
+		{expr: `(in 2.5 (list 1.5 2.5))`, want: true},
+		{expr: `(in name (list 1.5 "g"))`, want: true},
+		{expr: `(in id (list 1.5 "g"))`, want: false},
+		{expr: `(= (list 1.5 id) (list 1.5 7))`, want: true},
+		{expr: `(dict "id" id "tags" tags)`, want: map[string]Value{"id": int64(7), "tags": []string{"x", "y"}}},
+		{expr: `(dict "id")`, errMsg: "params should be key value pairs"},
+		{expr: `(dict id 1)`, errMsg: paramTypeErrMsg},
+		{expr: `(index (list id 8) 1)`, want: int64(8)},
+		{expr: `(index tags 0)`, want: "x"},
+		{expr: `(index tags 2)`, errMsg: "index out of range [2] with length 2"},
+		{expr: `(index tags "0")`, errMsg: paramTypeErrMsg},
+		{expr: `(index (dict "a" (dict "b" id)) "a")`, want: map[string]Value{"b": int64(7)}},
+		{expr: `(index (index (dict "a" (dict "b" id)) "a") "b")`, want: int64(7)},
+		{expr: `(index (dict "a" id) "b")`, errMsg: "key not found: b"},
+		{expr: `(index attrs "level")`, want: int64(3)},
+		{expr: `(index attrs 0)`, errMsg: paramTypeErrMsg},
+		{expr: `(index id 0)`, errMsg: paramTypeErrMsg},
+		{expr: `(in id (list 1 id))`, want: true},
+		{expr: `(= tags ("x" "y"))`, want: true},
+		{expr: `(= tags ("x") tags)`, want: false},
+		{expr: `(!= (list id 8) (7 8))`, want: false},
+		{expr: `(= attrs (dict "level" 3))`, want: true},
+		{expr: `(= attrs (dict "level" 3.0))`, want: true},
+		{expr: `(= attrs (dict "level" "3"))`, want: false},
+		{expr: `(= attrs tags)`, want: false},
+		{expr: `(= () ())`, want: true},
+	}
+
+	for _, c := range testCases {
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.want, c.expr)
+	}
+
+	// the constant maps are folded, and kept in the decompiled and serialized expressions
+	expr, err := Compile(cc, `(index (dict "b" (1 2) "a" (dict "c" "d")) name)`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(index (dict "a" (dict "c" "d") "b" (1 2)) name)`)
+	_, err = Compile(cc, expr.Decompile())
+	assertNil(t, err)
+
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	loaded, err := UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+	assertEquals(t, loaded.Decompile(), expr.Decompile())
+	for _, n := range loaded.nodes {
+		if n.getNodeType() == constant {
+			assertEquals(t, n.value, map[string]Value{
+				"a": map[string]Value{"c": "d"},
+				"b": []int64{1, 2},
+			})
+		}
+	}
+
+	// the constant lists of the mixed types are kept as well
+	expr, err = Compile(cc, `(in name (list 1.5 "g" (dict "a" 1)))`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(in name (list 1.5 "g" (dict "a" 1)))`)
+	bs, err = expr.Marshal()
+	assertNil(t, err)
+	loaded, err = UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+	assertEquals(t, loaded.Decompile(), expr.Decompile())
+	res, err := loaded.Eval(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, true)
+}
+
+func TestLength(t *testing.T) {
+	vals := map[string]interface{}{
+		"name":  "héllo, 世界",
+		"ids":   []int{1, 2, 3},
+		"tags":  []string{"x", "y"},
+		"items": []interface{}{1, "a"},
+		"attrs": map[string]interface{}{"level": 3},
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		want   Value
+		errMsg string
+	}{
+		{expr: `(len name)`, want: int64(9)},
+		{expr: `(len name)`, opts: []CompileOption{EnableByteLength}, want: int64(14)},
+		{expr: `;;;; byte_length:true
+(len name)`, want: int64(14)},
+		{expr: `(len "")`, want: int64(0)},
+		{expr: `(len ids)`, want: int64(3)},
+		{expr: `(len tags)`, want: int64(2)},
+		{expr: `(len items)`, want: int64(2)},
+		{expr: `(len attrs)`, want: int64(1)},
+		{expr: `(len (dict "a" 1 "b" 2))`, want: int64(2)},
+		{expr: `(> (len tags) 1)`, opts: []CompileOption{EnableTypeCheck}, want: true},
+		{expr: `size(name) == 9 && tags.size() == 2`, opts: []CompileOption{EnableCELSyntax}, want: true},
+		{expr: `len(name) > 5`, opts: []CompileOption{EnableInfixSyntax}, want: true},
+
+		{expr: `(len 1)`, errMsg: "expected: string, list or map"},
+		{expr: `(len tags ids)`, errMsg: "unexpected params count, operator: len, expected: 1, got: 2"},
+		{expr: `(+ (len name) "a")`, opts: []CompileOption{EnableTypeCheck}, errMsg: "type check error"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.want, c.expr)
+	}
+}
@@ -0,0 +1,478 @@
+// Package collections provides an opt-in module of list operators over []int64, []string and []eval.Value,
+// the operators can be registered to a CompileConfig by Register.
+//
+//	cc := eval.NewCompileConfig()
+//	if err := collections.Register(cc); err != nil {
+//		...
+//	}
+//	expr, err := eval.Compile(cc, `(and (> (len (distinct tags)) 2) (< (avg scores) 60))`)
+//
+// The []interface{} values are accepted as []eval.Value, and the results of the list operators are of
+// the same type as the params, or []eval.Value if the params are of different types.
+// The lengths of the lists are returned by the builtin len operator, e.g. (len tags).
+// Note that contains conflicts with the one of the ops/strings module, they can't be registered together.
+package collections
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/larry618/eval"
+)
+
+const (
+	typeInt  = "int64"
+	typeStr  = "string"
+	typeList = "list"
+)
+
+var errEmptyList = errors.New("empty list")
+
+// Operators contains all the operators of the module
+var Operators = map[string]eval.Operator{
+	"contains":  contains,
+	"indexOf":   indexOf,
+	"distinct":  distinct,
+	"sum":       sum,
+	"avg":       avg,
+	"minOf":     extremum("minOf", -1),
+	"maxOf":     extremum("maxOf", 1),
+	"sort":      sortList,
+	"slice":     slice,
+	"concat":    concat,
+	"union":     union,
+	"intersect": intersect,
+}
+
+// Register registers all the operators of the module to cc
+func Register(cc *eval.CompileConfig) error {
+	for name, op := range Operators {
+		if err := eval.RegisterOperator(cc, name, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type kind int
+
+const (
+	intList kind = iota
+	strList
+	valList
+)
+
+// elems returns the elements of the list and its kind
+func elems(op string, p eval.Value) ([]eval.Value, kind, error) {
+	switch l := p.(type) {
+	case []int64:
+		res := make([]eval.Value, len(l))
+		for i, v := range l {
+			res[i] = v
+		}
+		return res, intList, nil
+	case []string:
+		res := make([]eval.Value, len(l))
+		for i, v := range l {
+			res[i] = v
+		}
+		return res, strList, nil
+	case []eval.Value:
+		return l, valList, nil
+	case []interface{}:
+		res := make([]eval.Value, len(l))
+		for i, v := range l {
+			res[i] = v
+		}
+		return res, valList, nil
+	}
+	return nil, 0, eval.ParamTypeError(op, typeList, p)
+}
+
+// newList returns the list of the kind with the elements
+func newList(vals []eval.Value, k kind) eval.Value {
+	switch k {
+	case intList:
+		res := make([]int64, len(vals))
+		for i, v := range vals {
+			res[i] = v.(int64)
+		}
+		return res
+	case strList:
+		res := make([]string, len(vals))
+		for i, v := range vals {
+			res[i] = v.(string)
+		}
+		return res
+	}
+	return vals
+}
+
+// listParams returns the elements of the params which are all lists,
+// the kind is valList if they are of different kinds
+func listParams(op string, params []eval.Value) ([][]eval.Value, kind, error) {
+	var (
+		res = make([][]eval.Value, len(params))
+		k   kind
+	)
+	for i, p := range params {
+		vals, pk, err := elems(op, p)
+		if err != nil {
+			return nil, 0, err
+		}
+		if i == 0 {
+			k = pk
+		} else if pk != k {
+			k = valList
+		}
+		res[i] = vals
+	}
+	return res, k, nil
+}
+
+func checkCount(op string, params []eval.Value, min, max int) error {
+	if len(params) < min || len(params) > max {
+		return eval.ParamsCountError(op, min, len(params))
+	}
+	return nil
+}
+
+// compare compares the numbers or strings, the int64 values are promoted to float64
+// if they are compared with float64 values
+func compare(op string, a, b eval.Value) (int, error) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return cmp(x < y, x > y), nil
+		case float64:
+			return cmp(float64(x) < y, float64(x) > y), nil
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return cmp(x < float64(y), x > float64(y)), nil
+		case float64:
+			return cmp(x < y, x > y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return cmp(x < y, x > y), nil
+		}
+	}
+	return 0, eval.OpExecError(op, fmt.Errorf("elements are not comparable: %v, %v", a, b))
+}
+
+func cmp(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+func index(vals []eval.Value, elem eval.Value) int {
+	for i, v := range vals {
+		if v == elem {
+			return i
+		}
+	}
+	return -1
+}
+
+// contains returns whether the list contains the element
+// e.g. (contains list elem)
+func contains(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "contains"
+	if err := checkCount(op, params, 2, 2); err != nil {
+		return nil, err
+	}
+	vals, _, err := elems(op, params[0])
+	if err != nil {
+		return nil, err
+	}
+	return index(vals, params[1]) != -1, nil
+}
+
+// indexOf returns the index of the first element equal to elem, or -1 if it's not found
+// e.g. (indexOf list elem)
+func indexOf(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "indexOf"
+	if err := checkCount(op, params, 2, 2); err != nil {
+		return nil, err
+	}
+	vals, _, err := elems(op, params[0])
+	if err != nil {
+		return nil, err
+	}
+	return int64(index(vals, params[1])), nil
+}
+
+func distinctVals(op string, vals []eval.Value) ([]eval.Value, error) {
+	var (
+		seen = make(map[eval.Value]struct{}, len(vals))
+		res  = make([]eval.Value, 0, len(vals))
+	)
+	for _, v := range vals {
+		if !isHashable(v) {
+			return nil, eval.ParamTypeError(op, "list of comparable elements", v)
+		}
+		if _, exist := seen[v]; !exist {
+			seen[v] = struct{}{}
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+func isHashable(v eval.Value) bool {
+	switch v.(type) {
+	case nil, bool, int64, float64, string:
+		return true
+	}
+	return false
+}
+
+// distinct returns the elements without the duplicated ones in order
+// e.g. (distinct list)
+func distinct(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "distinct"
+	if err := checkCount(op, params, 1, 1); err != nil {
+		return nil, err
+	}
+	vals, k, err := elems(op, params[0])
+	if err != nil {
+		return nil, err
+	}
+	res, err := distinctVals(op, vals)
+	if err != nil {
+		return nil, err
+	}
+	return newList(res, k), nil
+}
+
+// sum returns the sum of the numbers, it's float64 if any of them is float64
+// e.g. (sum list)
+func sum(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "sum"
+	if err := checkCount(op, params, 1, 1); err != nil {
+		return nil, err
+	}
+	vals, _, err := elems(op, params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ints    int64
+		floats  float64
+		isFloat bool
+	)
+	for _, v := range vals {
+		switch n := v.(type) {
+		case int64:
+			ints += n
+		case float64:
+			floats, isFloat = floats+n, true
+		default:
+			return nil, eval.ParamTypeError(op, "list of numbers", v)
+		}
+	}
+	if isFloat {
+		return floats + float64(ints), nil
+	}
+	return ints, nil
+}
+
+// avg returns the average of the numbers as float64
+// e.g. (avg list)
+func avg(ctx *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "avg"
+	if err := checkCount(op, params, 1, 1); err != nil {
+		return nil, err
+	}
+	vals, _, err := elems(op, params[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 0 {
+		return nil, eval.OpExecError(op, errEmptyList)
+	}
+	total, err := sum(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := total.(int64); ok {
+		return float64(n) / float64(len(vals)), nil
+	}
+	return total.(float64) / float64(len(vals)), nil
+}
+
+// extremum returns the min or max element of the numbers or strings
+// e.g. (minOf list) (maxOf list)
+func extremum(op string, want int) eval.Operator {
+	return func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		if err := checkCount(op, params, 1, 1); err != nil {
+			return nil, err
+		}
+		vals, _, err := elems(op, params[0])
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) == 0 {
+			return nil, eval.OpExecError(op, errEmptyList)
+		}
+
+		res := vals[0]
+		for _, v := range vals[1:] {
+			c, err := compare(op, v, res)
+			if err != nil {
+				return nil, err
+			}
+			if c == want {
+				res = v
+			}
+		}
+		return res, nil
+	}
+}
+
+// sortList returns the sorted copy of the numbers or strings, in ascending order by default
+// e.g. (sort list) (sort list "desc")
+func sortList(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "sort"
+	if err := checkCount(op, params, 1, 2); err != nil {
+		return nil, err
+	}
+	var desc bool
+	if len(params) == 2 {
+		switch params[1] {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return nil, eval.OpExecError(op, fmt.Errorf("invalid sort order: %v, want: asc or desc", params[1]))
+		}
+	}
+	vals, k, err := elems(op, params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	res := append([]eval.Value(nil), vals...)
+	var cmpErr error
+	sort.SliceStable(res, func(i, j int) bool {
+		c, err := compare(op, res[i], res[j])
+		if err != nil && cmpErr == nil {
+			cmpErr = err
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	if cmpErr != nil {
+		return nil, cmpErr
+	}
+	return newList(res, k), nil
+}
+
+// slice returns the elements in [start, end), end is the length of the list by default,
+// the negative indexes count from the end, and the indexes are clamped to the list
+// e.g. (slice list 1) (slice list 0 3) (slice list -2)
+func slice(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "slice"
+	if err := checkCount(op, params, 2, 3); err != nil {
+		return nil, err
+	}
+	vals, k, err := elems(op, params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := []int{0, len(vals)}
+	for i, p := range params[1:] {
+		n, ok := p.(int64)
+		if !ok {
+			return nil, eval.ParamTypeError(op, typeInt, p)
+		}
+		if n < 0 {
+			n += int64(len(vals))
+		}
+		switch {
+		case n < 0:
+			n = 0
+		case n > int64(len(vals)):
+			n = int64(len(vals))
+		}
+		bounds[i] = int(n)
+	}
+	if bounds[0] > bounds[1] {
+		bounds[0] = bounds[1]
+	}
+	return newList(vals[bounds[0]:bounds[1]], k), nil
+}
+
+// concat returns the elements of all the lists in order
+// e.g. (concat list1 list2 list3)
+func concat(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "concat"
+	if len(params) == 0 {
+		return nil, eval.ParamsCountError(op, 1, 0)
+	}
+	lists, k, err := listParams(op, params)
+	if err != nil {
+		return nil, err
+	}
+	var res []eval.Value
+	for _, l := range lists {
+		res = append(res, l...)
+	}
+	return newList(res, k), nil
+}
+
+// union returns the distinct elements of the lists in order
+// e.g. (union list1 list2)
+func union(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "union"
+	if err := checkCount(op, params, 2, 2); err != nil {
+		return nil, err
+	}
+	lists, k, err := listParams(op, params)
+	if err != nil {
+		return nil, err
+	}
+	res, err := distinctVals(op, append(append([]eval.Value(nil), lists[0]...), lists[1]...))
+	if err != nil {
+		return nil, err
+	}
+	return newList(res, k), nil
+}
+
+// intersect returns the distinct elements of the first list which are in the second list
+// e.g. (intersect list1 list2)
+func intersect(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "intersect"
+	if err := checkCount(op, params, 2, 2); err != nil {
+		return nil, err
+	}
+	lists, k, err := listParams(op, params)
+	if err != nil {
+		return nil, err
+	}
+	left, err := distinctVals(op, lists[0])
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]eval.Value, 0, len(left))
+	for _, v := range left {
+		if index(lists[1], v) != -1 {
+			res = append(res, v)
+		}
+	}
+	return newList(res, k), nil
+}
@@ -0,0 +1,99 @@
+package collections
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval"
+)
+
+func TestOperators(t *testing.T) {
+	vals := map[string]interface{}{
+		"scores": []int{70, 45, 90, 45},
+		"ratios": []interface{}{0.5, int64(2), 1.5},
+		"tags":   []string{"go", "rule", "go", "eval"},
+		"mixed":  []eval.Value{"go", int64(1), true},
+		"empty":  []int64{},
+		"attrs":  map[string]eval.Value{"a": int64(1), "b": int64(2)},
+	}
+
+	testCases := []struct {
+		expr   string
+		want   eval.Value
+		errMsg string
+	}{
+		{expr: `(len scores)`, want: int64(4)},
+		{expr: `(len mixed)`, want: int64(3)},
+		{expr: `(len attrs)`, want: int64(2)},
+		{expr: `(len empty)`, want: int64(0)},
+		{expr: `(contains tags "rule")`, want: true},
+		{expr: `(contains scores 100)`, want: false},
+		{expr: `(contains mixed true)`, want: true},
+		{expr: `(indexOf tags "go")`, want: int64(0)},
+		{expr: `(indexOf scores 45)`, want: int64(1)},
+		{expr: `(indexOf tags "java")`, want: int64(-1)},
+		{expr: `(distinct tags)`, want: []string{"go", "rule", "eval"}},
+		{expr: `(distinct scores)`, want: []int64{70, 45, 90}},
+		{expr: `(sum scores)`, want: int64(250)},
+		{expr: `(sum ratios)`, want: 4.0},
+		{expr: `(sum empty)`, want: int64(0)},
+		{expr: `(avg scores)`, want: 62.5},
+		{expr: `(avg ratios)`, want: 4.0 / 3},
+		{expr: `(minOf scores)`, want: int64(45)},
+		{expr: `(maxOf scores)`, want: int64(90)},
+		{expr: `(maxOf ratios)`, want: int64(2)},
+		{expr: `(minOf tags)`, want: "eval"},
+		{expr: `(sort scores)`, want: []int64{45, 45, 70, 90}},
+		{expr: `(sort tags "desc")`, want: []string{"rule", "go", "go", "eval"}},
+		{expr: `(sort ratios)`, want: []eval.Value{0.5, 1.5, int64(2)}},
+		{expr: `(slice scores 1 3)`, want: []int64{45, 90}},
+		{expr: `(slice tags -2)`, want: []string{"go", "eval"}},
+		{expr: `(slice scores 3 1)`, want: []int64{}},
+		{expr: `(slice scores 0 100)`, want: []int64{70, 45, 90, 45}},
+		{expr: `(concat tags ("x"))`, want: []string{"go", "rule", "go", "eval", "x"}},
+		{expr: `(concat scores tags)`, want: []eval.Value{int64(70), int64(45), int64(90), int64(45), "go", "rule", "go", "eval"}},
+		{expr: `(union scores (90 100))`, want: []int64{70, 45, 90, 100}},
+		{expr: `(intersect tags ("eval" "go" "java"))`, want: []string{"go", "eval"}},
+		{expr: `(intersect scores empty)`, want: []int64{}},
+		{expr: `(and (> (len (distinct tags)) 2) (< (avg scores) 70))`, want: true},
+
+		{expr: `(len 1)`, errMsg: "unexpected param type"},
+		{expr: `(contains tags)`, errMsg: "unexpected params count"},
+		{expr: `(sum tags)`, errMsg: "expected: list of numbers"},
+		{expr: `(avg empty)`, errMsg: "empty list"},
+		{expr: `(maxOf empty)`, errMsg: "empty list"},
+		{expr: `(minOf mixed)`, errMsg: "elements are not comparable"},
+		{expr: `(sort mixed)`, errMsg: "elements are not comparable"},
+		{expr: `(sort scores "up")`, errMsg: "invalid sort order: up"},
+		{expr: `(slice scores "1")`, errMsg: "expected: int64"},
+		{expr: `(concat)`, errMsg: "unexpected params count"},
+		{expr: `(union scores 1)`, errMsg: "expected: list"},
+	}
+
+	cc := eval.NewCompileConfig(eval.RegisterSelKeys(vals))
+	if err := Register(cc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range testCases {
+		got, err := eval.Eval(c.expr, vals, cc)
+		if len(c.errMsg) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Fatalf("unexpected error, expr: %s, got: %v, want: %s", c.expr, err, c.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, err: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected result, expr: %s, got: %v, want: %v", c.expr, got, c.want)
+		}
+	}
+
+	// registering twice causes conflicts
+	if err := Register(cc); err == nil {
+		t.Fatal("operators should not be registered twice")
+	}
+}
@@ -0,0 +1,189 @@
+// Package env provides an opt-in module of the operators and the selector of the evaluation environment,
+// i.e. the wall-clock time, the environment variables and the random values.
+// The operators can be registered to a CompileConfig by Register.
+//
+//	cc := eval.NewCompileConfig()
+//	if err := env.Register(cc, env.WithLocation(loc)); err != nil {
+//		...
+//	}
+//	expr, err := eval.Compile(cc, `(and (< (hourOfDay) 18) (= (env "REGION") "us") (< (random) 0.1))`)
+//
+// The same values can be provided as the selectors now, today, hourOfDay and random by NewSelector,
+// which is usually the fallback of the selectors of the requests, e.g.
+//
+//	ctx := &eval.Ctx{Selector: eval.ChainSelector(request, env.NewSelector())}
+//
+// The times are represented by unix seconds, the same as the ops/time module,
+// whose now operator conflicts with the one of this module, so register only one of them.
+// The clock, the randomness and the environment variables can be frozen by the options in the tests.
+package env
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/larry618/eval"
+)
+
+const typeStr = "string"
+
+// Operators contains all the operators of the module with the default options
+var Operators = newOperators(defaultOptions())
+
+type options struct {
+	loc       *time.Location
+	now       func() time.Time
+	random    func() float64
+	lookupEnv func(key string) (string, bool)
+}
+
+func defaultOptions() options {
+	return options{
+		loc:       time.UTC,
+		now:       time.Now,
+		random:    rand.Float64,
+		lookupEnv: os.LookupEnv,
+	}
+}
+
+// Option configures the operators registered by Register and the selector created by NewSelector
+type Option func(o *options)
+
+// WithLocation sets the location of today and hourOfDay, UTC by default
+func WithLocation(loc *time.Location) Option {
+	return func(o *options) {
+		o.loc = loc
+	}
+}
+
+// WithClock replaces the clock, it's mainly used to freeze the time in tests
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithRandom replaces the source of the random values in [0, 1),
+// it's mainly used to make the random values deterministic in tests.
+// Note that the source should be safe for concurrent use if the operators are shared by the goroutines.
+func WithRandom(random func() float64) Option {
+	return func(o *options) {
+		o.random = random
+	}
+}
+
+// WithEnv replaces the environment variables of the process by vars, it's mainly used in tests
+func WithEnv(vars map[string]string) Option {
+	return func(o *options) {
+		o.lookupEnv = func(key string) (string, bool) {
+			v, exist := vars[key]
+			return v, exist
+		}
+	}
+}
+
+// Register registers all the operators of the module to cc
+func Register(cc *eval.CompileConfig, opts ...Option) error {
+	o := newOptions(opts)
+	for name, op := range newOperators(o) {
+		if err := eval.RegisterOperator(cc, name, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newOptions(opts []Option) options {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// values returns the values without params by their names, they are both the operators and the selectors
+func (o options) values() map[string]func() eval.Value {
+	return map[string]func() eval.Value{
+		// now is the current unix seconds
+		"now": func() eval.Value {
+			return o.now().Unix()
+		},
+		// today is the unix seconds of the start of the current day
+		"today": func() eval.Value {
+			y, m, d := o.now().In(o.loc).Date()
+			return time.Date(y, m, d, 0, 0, 0, 0, o.loc).Unix()
+		},
+		// hourOfDay is the current hour in [0, 23]
+		"hourOfDay": func() eval.Value {
+			return int64(o.now().In(o.loc).Hour())
+		},
+		// random is a random float in [0, 1)
+		"random": func() eval.Value {
+			return o.random()
+		},
+	}
+}
+
+func newOperators(o options) map[string]eval.Operator {
+	res := map[string]eval.Operator{
+		"env": o.env,
+	}
+	for name, fn := range o.values() {
+		name, fn := name, fn
+		res[name] = func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+			if len(params) != 0 {
+				return nil, eval.ParamsCountError(name, 0, len(params))
+			}
+			return fn(), nil
+		}
+	}
+	return res
+}
+
+// env returns the environment variable, or the default value if it's not set, which is null if omitted,
+// e.g. (env "REGION"), (env "REGION" "us")
+func (o options) env(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "env"
+	if len(params) != 1 && len(params) != 2 {
+		return nil, eval.ParamsCountError(op, 1, len(params))
+	}
+	key, ok := params[0].(string)
+	if !ok {
+		return nil, eval.ParamTypeError(op, typeStr, params[0])
+	}
+	if v, exist := o.lookupEnv(key); exist {
+		return v, nil
+	}
+	if len(params) == 2 {
+		return params[1], nil
+	}
+	return nil, nil
+}
+
+type selector struct {
+	values map[string]func() eval.Value
+}
+
+// NewSelector creates a read-only Selector of the values now, today, hourOfDay and random,
+// which are computed by each Get, it's safe for concurrent use
+func NewSelector(opts ...Option) eval.Selector {
+	return selector{values: newOptions(opts).values()}
+}
+
+func (s selector) Get(_ eval.SelectorKey, key string) (eval.Value, error) {
+	fn, exist := s.values[key]
+	if !exist {
+		return nil, fmt.Errorf("%w %s", eval.ErrSelectorNotExist, key)
+	}
+	return fn(), nil
+}
+
+func (s selector) Set(_ eval.SelectorKey, key string, _ eval.Value) error {
+	return fmt.Errorf("env selector is read-only, selector: %s", key)
+}
+
+func (s selector) Cached(_ eval.SelectorKey, _ string) bool {
+	return false
+}
@@ -0,0 +1,108 @@
+package env
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/larry618/eval"
+)
+
+func TestOperators(t *testing.T) {
+	vals := map[string]interface{}{
+		"region": "REGION",
+	}
+	clock := func() time.Time { return time.Date(2024, 3, 10, 22, 30, 0, 0, time.UTC) }
+
+	testCases := []struct {
+		expr   string
+		opts   []Option
+		want   eval.Value
+		errMsg string
+	}{
+		{expr: `(now)`, want: int64(1710109800)},
+		{expr: `(today)`, want: int64(1710028800)},
+		{expr: `(hourOfDay)`, want: int64(22)},
+		{expr: `(hourOfDay)`, opts: []Option{WithLocation(time.FixedZone("JST", 9*3600))}, want: int64(7)},
+		{expr: `(today)`, opts: []Option{WithLocation(time.FixedZone("JST", 9*3600))}, want: int64(1710082800)},
+		{expr: `(< (- (now) (today)) 86400)`, want: true},
+		{expr: `(random)`, want: 0.25},
+		{expr: `(< (random) 0.3)`, want: true},
+		{expr: `(env "REGION")`, want: "us-east-1"},
+		{expr: `(env region)`, want: "us-east-1"},
+		{expr: `(env "MISSING")`, want: nil},
+		{expr: `(env "MISSING" "default")`, want: "default"},
+		{expr: `(env "REGION" "default")`, want: "us-east-1"},
+
+		{expr: `(now 1)`, errMsg: "unexpected params count, operator: now, expected: 0, got: 1"},
+		{expr: `(env)`, errMsg: "unexpected params count"},
+		{expr: `(env 1)`, errMsg: "unexpected param type"},
+	}
+
+	for _, c := range testCases {
+		cc := eval.NewCompileConfig(eval.RegisterSelKeys(vals))
+		opts := append([]Option{
+			WithClock(clock),
+			WithRandom(func() float64 { return 0.25 }),
+			WithEnv(map[string]string{"REGION": "us-east-1"}),
+		}, c.opts...)
+		if err := Register(cc, opts...); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := eval.Eval(c.expr, vals, cc)
+		if len(c.errMsg) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Fatalf("unexpected error, expr: %s, got: %v, want: %s", c.expr, err, c.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, err: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected result, expr: %s, got: %v, want: %v", c.expr, got, c.want)
+		}
+
+		// registering twice causes conflicts
+		if err := Register(cc); err == nil {
+			t.Fatal("operators should not be registered twice")
+		}
+	}
+}
+
+func TestSelector(t *testing.T) {
+	var now time.Time
+	sel := NewSelector(WithClock(func() time.Time { return now }), WithRandom(func() float64 { return 0.75 }))
+	cc := eval.NewCompileConfig(eval.EnableStringSelectors)
+	expr, err := eval.Compile(cc, `(and (>= hourOfDay 9) (< hourOfDay 18) (> now today) (> random 0.5) (= tier "gold"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := eval.NewMapSelector(map[string]interface{}{"tier": "gold"})
+
+	for _, c := range []struct {
+		hour int
+		want bool
+	}{{hour: 8, want: false}, {hour: 9, want: true}, {hour: 17, want: true}, {hour: 18, want: false}} {
+		now = time.Date(2024, 3, 10, c.hour, 30, 0, 0, time.UTC)
+		got, err := expr.EvalBool(&eval.Ctx{Selector: eval.ChainSelector(request, sel)})
+		if err != nil {
+			t.Fatalf("unexpected error, hour: %d, err: %v", c.hour, err)
+		}
+		if got != c.want {
+			t.Fatalf("unexpected result, hour: %d, got: %v, want: %v", c.hour, got, c.want)
+		}
+	}
+
+	if _, err := sel.Get(eval.UndefinedSelKey, "tier"); err == nil || !strings.Contains(err.Error(), "selectorKey not exist tier") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sel.Set(eval.UndefinedSelKey, "now", int64(0)); err == nil {
+		t.Fatal("the selector should be read-only")
+	}
+	if sel.Cached(eval.UndefinedSelKey, "now") {
+		t.Fatal("the values should not be cached")
+	}
+}
@@ -0,0 +1,163 @@
+// Package hash provides an opt-in module of hash and encoding operators over the strings,
+// the operators can be registered to a CompileConfig by Register.
+//
+//	cc := eval.NewCompileConfig()
+//	if err := hash.Register(cc); err != nil {
+//		...
+//	}
+//	expr, err := eval.Compile(cc, `(< (% (sha256 userId) 1000) 10)`)
+//
+// The hash operators return the non-negative int64 of the digests for the consistent bucketing,
+// e.g. (% (sha256 userId) 1000), or the hex strings of the digests with the "hex" format,
+// e.g. (sha256 userId "hex"). The crc32 checksums are the IEEE ones.
+//
+// The percentage rollouts should use bucket, which assigns the stable buckets to the keys,
+// e.g. (< (bucket userId "new-checkout" 100) 10) is true for 10% of the users.
+package hash
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strconv"
+
+	"github.com/larry618/eval"
+)
+
+const (
+	typeStr = "string"
+	typeInt = "int64"
+
+	formatHex = "hex"
+)
+
+// Operators contains all the operators of the module
+var Operators = map[string]eval.Operator{
+	"md5":          digest("md5", func(b []byte) []byte { s := md5.Sum(b); return s[:] }),
+	"sha256":       digest("sha256", func(b []byte) []byte { s := sha256.Sum256(b); return s[:] }),
+	"crc32":        checksum,
+	"base64Encode": encoding("base64Encode", base64.StdEncoding.EncodeToString),
+	"base64Decode": base64Decode,
+	"hexEncode":    encoding("hexEncode", hex.EncodeToString),
+	"bucket":       bucket,
+}
+
+// Register registers all the operators of the module to cc,
+// they are deterministic, so they are folded with the constant params at compile time, e.g. (sha256 "salt")
+func Register(cc *eval.CompileConfig) error {
+	for name, op := range Operators {
+		if err := eval.RegisterOperator(cc, name, op); err != nil {
+			return err
+		}
+		if err := eval.RegisterOperatorMeta(cc, name, eval.OperatorMeta{Deterministic: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func strParam(op string, params []eval.Value, min, max int) (string, error) {
+	if len(params) < min || len(params) > max {
+		return "", eval.ParamsCountError(op, min, len(params))
+	}
+	s, ok := params[0].(string)
+	if !ok {
+		return "", eval.ParamTypeError(op, typeStr, params[0])
+	}
+	return s, nil
+}
+
+// digest returns the non-negative int64 of the first 8 bytes of the digest in big-endian,
+// or the hex string of the digest with the "hex" format
+// e.g. (sha256 userId), (sha256 userId "hex")
+func digest(op string, sum func(b []byte) []byte) eval.Operator {
+	return func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		s, err := strParam(op, params, 1, 2)
+		if err != nil {
+			return nil, err
+		}
+		d := sum([]byte(s))
+		if len(params) == 1 {
+			return int64(binary.BigEndian.Uint64(d) & math.MaxInt64), nil
+		}
+
+		if format, ok := params[1].(string); !ok || format != formatHex {
+			return nil, eval.OpExecError(op, fmt.Errorf("unsupported format: %v, want: %s", params[1], formatHex))
+		}
+		return hex.EncodeToString(d), nil
+	}
+}
+
+// checksum returns the IEEE crc32 checksum of the string, e.g. (crc32 userId)
+func checksum(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	s, err := strParam("crc32", params, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return int64(crc32.ChecksumIEEE([]byte(s))), nil
+}
+
+// encoding returns the encoded string, e.g. (base64Encode token), (hexEncode token)
+func encoding(op string, encode func(b []byte) string) eval.Operator {
+	return func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		s, err := strParam(op, params, 1, 1)
+		if err != nil {
+			return nil, err
+		}
+		return encode([]byte(s)), nil
+	}
+}
+
+// base64Decode returns the string decoded from the standard base64 encoding, e.g. (base64Decode token)
+func base64Decode(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "base64Decode"
+	s, err := strParam(op, params, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, eval.OpExecError(op, err)
+	}
+	return string(b), nil
+}
+
+// bucket assigns the key to one of the buckets stably, e.g. (bucket userId "new-checkout" 100),
+// the same key and salt are always in the same bucket, and the different salts shuffle the keys independently.
+// The bucket is the first 8 bytes of SHA-256(salt + ":" + key) in big-endian as uint64 modulo buckets,
+// the int64 keys are formatted in decimal, e.g. 42 => "42".
+func bucket(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "bucket"
+	if len(params) != 3 {
+		return nil, eval.ParamsCountError(op, 3, len(params))
+	}
+
+	var key string
+	switch k := params[0].(type) {
+	case string:
+		key = k
+	case int64:
+		key = strconv.FormatInt(k, 10)
+	default:
+		return nil, eval.ParamTypeError(op, typeStr+" or "+typeInt, params[0])
+	}
+	salt, ok := params[1].(string)
+	if !ok {
+		return nil, eval.ParamTypeError(op, typeStr, params[1])
+	}
+	buckets, ok := params[2].(int64)
+	if !ok {
+		return nil, eval.ParamTypeError(op, typeInt, params[2])
+	}
+	if buckets <= 0 {
+		return nil, eval.OpExecError(op, fmt.Errorf("buckets should be positive, got: %d", buckets))
+	}
+
+	d := sha256.Sum256([]byte(salt + ":" + key))
+	return int64(binary.BigEndian.Uint64(d[:8]) % uint64(buckets)), nil
+}
@@ -0,0 +1,107 @@
+package hash
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval"
+)
+
+func TestOperators(t *testing.T) {
+	vals := map[string]interface{}{
+		"userId": "user-42",
+		"token":  "dXNlci00Mg==",
+		"count":  42,
+	}
+
+	testCases := []struct {
+		expr   string
+		want   eval.Value
+		errMsg string
+	}{
+		{expr: `(sha256 userId)`, want: int64(7892921889885005129)},
+		{expr: `(sha256 userId "hex")`, want: "6d894aa3ee802549d7f340e7c1cf0d1c1cb14cd84f768d92ffaa6785337c4997"},
+		{expr: `(md5 userId)`, want: int64(8516795111299649485)},
+		{expr: `(md5 userId "hex")`, want: "7631bc07a1cc8fcd56e70fc6b2fb4a43"},
+		{expr: `(% (sha256 userId) 1000)`, want: int64(129)},
+		{expr: `(< (% (sha256 userId) 1000) 10)`, want: false},
+		{expr: `(crc32 userId)`, want: int64(2097592435)},
+		{expr: `(base64Encode userId)`, want: "dXNlci00Mg=="},
+		{expr: `(base64Decode token)`, want: "user-42"},
+		{expr: `(= (base64Decode (base64Encode userId)) userId)`, want: true},
+		{expr: `(hexEncode "eval")`, want: "6576616c"},
+		{expr: `(base64Encode "")`, want: ""},
+		{expr: `(bucket userId "new-checkout" 100)`, want: int64(62)},
+		{expr: `(bucket userId "new-checkout" 1000)`, want: int64(762)},
+		{expr: `(bucket userId "other" 100)`, want: int64(11)},
+		{expr: `(bucket count "new-checkout" 100)`, want: int64(0)},
+		{expr: `(bucket userId "new-checkout" 1)`, want: int64(0)},
+
+		{expr: `(sha256 count)`, errMsg: "unexpected param type"},
+		{expr: `(sha256 userId "base32")`, errMsg: "unsupported format: base32, want: hex"},
+		{expr: `(sha256)`, errMsg: "unexpected params count"},
+		{expr: `(crc32 userId "hex")`, errMsg: "unexpected params count"},
+		{expr: `(base64Decode "%%%")`, errMsg: "illegal base64 data"},
+		{expr: `(hexEncode count)`, errMsg: "unexpected param type"},
+		{expr: `(bucket 1.5 "salt" 100)`, errMsg: "expected: string or int64"},
+		{expr: `(bucket userId 1 100)`, errMsg: "expected: string"},
+		{expr: `(bucket userId "salt" 0)`, errMsg: "buckets should be positive, got: 0"},
+		{expr: `(bucket userId "salt")`, errMsg: "unexpected params count"},
+	}
+
+	cc := eval.NewCompileConfig(eval.RegisterSelKeys(vals))
+	if err := Register(cc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range testCases {
+		got, err := eval.Eval(c.expr, vals, cc)
+		if len(c.errMsg) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Fatalf("unexpected error, expr: %s, got: %v, want: %s", c.expr, err, c.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, err: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected result, expr: %s, got: %v, want: %v", c.expr, got, c.want)
+		}
+	}
+
+	// the keys are distributed to the buckets evenly
+	expr, err := eval.Compile(cc, `(< (bucket userId "exp" 100) 10)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hits int
+	for i := 0; i < 10000; i++ {
+		res, err := expr.EvalBool(eval.NewCtxWithMap(cc, map[string]interface{}{"userId": "user-" + strconv.Itoa(i)}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res {
+			hits++
+		}
+	}
+	if hits != 1015 {
+		t.Fatalf("unexpected hits, got: %d, want: %d", hits, 1015)
+	}
+
+	// the operators are folded with the constant params at compile time
+	expr, err = eval.Compile(cc, `(= userId (hexEncode "eval"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := expr.Decompile(), `(= userId "6576616c")`; got != want {
+		t.Fatalf("unexpected folded expression, got: %s, want: %s", got, want)
+	}
+
+	// registering twice causes conflicts
+	if err := Register(cc); err == nil {
+		t.Fatal("operators should not be registered twice")
+	}
+}
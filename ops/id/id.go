@@ -0,0 +1,139 @@
+// Package id provides an opt-in module of identifier operators of the UUIDs and the snowflake IDs,
+// the operators can be registered to a CompileConfig by Register.
+//
+//	cc := eval.NewCompileConfig()
+//	if err := id.Register(cc); err != nil {
+//		...
+//	}
+//	expr, err := eval.Compile(cc, `(and (isUUID requestId) (= (uuidVersion requestId) 4))`)
+//
+// The timestamps of the snowflake IDs are unix seconds, the same as the builtin time operators,
+// e.g. (> (snowflakeTime orderId) (date "2024-01-01")).
+package id
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/larry618/eval"
+)
+
+const (
+	typeInt = "int64"
+	typeStr = "string"
+
+	// twitterEpoch is the epoch of the snowflake IDs of Twitter in unix milliseconds, 2010-11-04T01:42:54.657Z
+	twitterEpoch = 1288834974657
+	// snowflakeTimeShift is the number of the bits following the timestamp, the worker and sequence ones
+	snowflakeTimeShift = 22
+)
+
+// Operators contains all the operators of the module
+var Operators = map[string]eval.Operator{
+	"isUUID":        isUUID,
+	"uuidVersion":   uuidVersion,
+	"snowflakeTime": snowflakeTime,
+}
+
+// Register registers all the operators of the module to cc
+func Register(cc *eval.CompileConfig) error {
+	for name, op := range Operators {
+		if err := eval.RegisterOperator(cc, name, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func strParam(op string, params []eval.Value) (string, error) {
+	if len(params) != 1 {
+		return "", eval.ParamsCountError(op, 1, len(params))
+	}
+	s, ok := params[0].(string)
+	if !ok {
+		return "", eval.ParamTypeError(op, typeStr, params[0])
+	}
+	return s, nil
+}
+
+// validUUID reports whether s is a UUID in the canonical 8-4-4-4-12 form of the hex digits of any case,
+// e.g. "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+func validUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isUUID reports whether the string is a UUID in the canonical form, e.g. (isUUID requestId)
+func isUUID(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	s, err := strParam("isUUID", params)
+	if err != nil {
+		return nil, err
+	}
+	return validUUID(s), nil
+}
+
+// uuidVersion returns the version of the UUID, e.g. (uuidVersion requestId) is 4 for the random UUIDs
+func uuidVersion(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "uuidVersion"
+	s, err := strParam(op, params)
+	if err != nil {
+		return nil, err
+	}
+	if !validUUID(s) {
+		return nil, eval.OpExecError(op, fmt.Errorf("invalid uuid: %q", s))
+	}
+	// the version is the high nibble of the 7th byte
+	v, _ := strconv.ParseInt(s[14:15], 16, 64)
+	return v, nil
+}
+
+// snowflakeTime returns the timestamp of the snowflake ID in unix seconds, the epoch is the one of Twitter
+// by default, and the other ones can be specified in unix milliseconds as the second param,
+// e.g. (snowflakeTime orderId), (snowflakeTime messageId 1420070400000).
+// The IDs are int64 values or the decimal strings, which are common in JSON.
+func snowflakeTime(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "snowflakeTime"
+	if len(params) != 1 && len(params) != 2 {
+		return nil, eval.ParamsCountError(op, 1, len(params))
+	}
+
+	var id int64
+	switch v := params[0].(type) {
+	case int64:
+		id = v
+	case string:
+		var err error
+		if id, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, eval.OpExecError(op, fmt.Errorf("invalid snowflake id: %q", v))
+		}
+	default:
+		return nil, eval.ParamTypeError(op, typeInt+" or "+typeStr, params[0])
+	}
+	if id < 0 {
+		return nil, eval.OpExecError(op, fmt.Errorf("invalid snowflake id: %d", id))
+	}
+
+	epoch := int64(twitterEpoch)
+	if len(params) == 2 {
+		v, ok := params[1].(int64)
+		if !ok {
+			return nil, eval.ParamTypeError(op, typeInt, params[1])
+		}
+		epoch = v
+	}
+	return ((id >> snowflakeTimeShift) + epoch) / 1000, nil
+}
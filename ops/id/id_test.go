@@ -0,0 +1,76 @@
+package id
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval"
+)
+
+func TestOperators(t *testing.T) {
+	vals := map[string]interface{}{
+		"requestId": "f47ac10b-58cc-4372-a567-0e02b2c3d479",
+		"tweetId":   1541815603606036480,
+		"tweetStr":  "1541815603606036480",
+		"messageId": 175928847299117063,
+	}
+
+	testCases := []struct {
+		expr   string
+		want   eval.Value
+		errMsg string
+	}{
+		{expr: `(isUUID requestId)`, want: true},
+		{expr: `(isUUID "F47AC10B-58CC-4372-A567-0E02B2C3D479")`, want: true},
+		{expr: `(isUUID "00000000-0000-0000-0000-000000000000")`, want: true},
+		{expr: `(isUUID "f47ac10b58cc4372a5670e02b2c3d479")`, want: false},
+		{expr: `(isUUID "f47ac10b-58cc-4372-a567-0e02b2c3d47g")`, want: false},
+		{expr: `(isUUID "f47ac10b-58cc-4372-a567_0e02b2c3d479")`, want: false},
+		{expr: `(isUUID "")`, want: false},
+		{expr: `(uuidVersion requestId)`, want: int64(4)},
+		{expr: `(uuidVersion "6ba7b810-9dad-11d1-80b4-00c04fd430c8")`, want: int64(1)},
+		{expr: `(uuidVersion "017f22e2-79b0-7cc3-98c4-dc0c0c07398f")`, want: int64(7)},
+		{expr: `(and (isUUID requestId) (= (uuidVersion requestId) 4))`, want: true},
+		{expr: `(snowflakeTime tweetId)`, want: int64(1656432460)},
+		{expr: `(snowflakeTime tweetStr)`, want: int64(1656432460)},
+		{expr: `(snowflakeTime messageId 1420070400000)`, want: int64(1462015105)},
+		{expr: `(snowflakeTime 0)`, want: int64(1288834974)},
+		{expr: `(> (snowflakeTime tweetId) 1640995200)`, want: true},
+
+		{expr: `(isUUID 1)`, errMsg: "unexpected param type"},
+		{expr: `(isUUID)`, errMsg: "unexpected params count"},
+		{expr: `(uuidVersion "not-a-uuid")`, errMsg: `invalid uuid: "not-a-uuid"`},
+		{expr: `(snowflakeTime "abc")`, errMsg: `invalid snowflake id: "abc"`},
+		{expr: `(snowflakeTime -1)`, errMsg: "invalid snowflake id: -1"},
+		{expr: `(snowflakeTime 1.5)`, errMsg: "expected: int64 or string"},
+		{expr: `(snowflakeTime tweetId "epoch")`, errMsg: "expected: int64"},
+		{expr: `(snowflakeTime tweetId 1 2)`, errMsg: "unexpected params count"},
+	}
+
+	cc := eval.NewCompileConfig(eval.RegisterSelKeys(vals))
+	if err := Register(cc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range testCases {
+		got, err := eval.Eval(c.expr, vals, cc)
+		if len(c.errMsg) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Fatalf("unexpected error, expr: %s, got: %v, want: %s", c.expr, err, c.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, err: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected result, expr: %s, got: %v, want: %v", c.expr, got, c.want)
+		}
+	}
+
+	// registering twice causes conflicts
+	if err := Register(cc); err == nil {
+		t.Fatal("operators should not be registered twice")
+	}
+}
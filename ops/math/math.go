@@ -0,0 +1,270 @@
+// Package math provides an opt-in module of math operators for both int64 and float64,
+// the operators can be registered to a CompileConfig by Register.
+//
+//	cc := eval.NewCompileConfig()
+//	if err := math.Register(cc); err != nil {
+//		...
+//	}
+//	expr, err := eval.Compile(cc, `(< (abs (- score (round avg))) (sqrt variance))`)
+//
+// The results are int64 if all the params are int64, and float64 if any param is float64,
+// except that sqrt and log always result in float64, and pow results in float64 for a negative exponent.
+package math
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/larry618/eval"
+)
+
+const typeNumber = "int64 or float64"
+
+var errIntOverflow = errors.New("integer overflow")
+
+// Operators contains all the operators of the module
+var Operators = map[string]eval.Operator{
+	"abs":   abs,
+	"min":   extremum("min", true),
+	"max":   extremum("max", false),
+	"pow":   pow,
+	"sqrt":  sqrt,
+	"round": round,
+	"floor": rounding("floor", math.Floor),
+	"ceil":  rounding("ceil", math.Ceil),
+	"clamp": clamp,
+	"log":   log,
+}
+
+// Register registers all the operators of the module to cc
+func Register(cc *eval.CompileConfig) error {
+	for name, op := range Operators {
+		if err := eval.RegisterOperator(cc, name, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// numbers returns the params as float64 values, isInt is true if all of them are int64
+func numbers(op string, params []eval.Value) (fs []float64, isInt bool, err error) {
+	fs, isInt = make([]float64, len(params)), true
+	for i, p := range params {
+		switch v := p.(type) {
+		case int64:
+			fs[i] = float64(v)
+		case float64:
+			fs[i], isInt = v, false
+		default:
+			return nil, false, eval.ParamTypeError(op, typeNumber, p)
+		}
+	}
+	return fs, isInt, nil
+}
+
+func checkCount(op string, params []eval.Value, min, max int) error {
+	if len(params) < min || len(params) > max {
+		return eval.ParamsCountError(op, min, len(params))
+	}
+	return nil
+}
+
+// abs returns the absolute value of x
+// e.g. (abs x)
+func abs(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "abs"
+	if err := checkCount(op, params, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := params[0].(type) {
+	case int64:
+		if v == math.MinInt64 {
+			return nil, eval.OpExecError(op, errIntOverflow)
+		}
+		if v < 0 {
+			return -v, nil
+		}
+		return v, nil
+	case float64:
+		return math.Abs(v), nil
+	}
+	return nil, eval.ParamTypeError(op, typeNumber, params[0])
+}
+
+// extremum returns the min or max of the params, the int64 params are promoted
+// to float64 if they are mixed with float64 params
+// e.g. (min x y z) (max x y)
+func extremum(op string, isMin bool) eval.Operator {
+	return func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		if len(params) == 0 {
+			return nil, eval.ParamsCountError(op, 1, 0)
+		}
+		fs, isInt, err := numbers(op, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if isInt {
+			// compared as int64 to avoid the precision loss of the large values
+			res := params[0].(int64)
+			for _, p := range params[1:] {
+				if v := p.(int64); v != res && (v < res) == isMin {
+					res = v
+				}
+			}
+			return res, nil
+		}
+		res := fs[0]
+		for _, v := range fs[1:] {
+			if isMin {
+				res = math.Min(res, v)
+			} else {
+				res = math.Max(res, v)
+			}
+		}
+		return res, nil
+	}
+}
+
+// pow returns base**exp, it's int64 if both of them are int64 and exp isn't negative
+// e.g. (pow base exp)
+func pow(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "pow"
+	if err := checkCount(op, params, 2, 2); err != nil {
+		return nil, err
+	}
+	fs, isInt, err := numbers(op, params)
+	if err != nil {
+		return nil, err
+	}
+	if !isInt || params[1].(int64) < 0 {
+		return math.Pow(fs[0], fs[1]), nil
+	}
+
+	base, exp := params[0].(int64), params[1].(int64)
+	res := int64(1)
+	for ; exp > 0; exp-- {
+		r := res * base
+		if base != 0 && (r/base != res || (res == -1 && base == math.MinInt64) || (base == -1 && res == math.MinInt64)) {
+			return nil, eval.OpExecError(op, errIntOverflow)
+		}
+		res = r
+	}
+	return res, nil
+}
+
+// sqrt returns the square root of x
+// e.g. (sqrt x)
+func sqrt(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "sqrt"
+	if err := checkCount(op, params, 1, 1); err != nil {
+		return nil, err
+	}
+	fs, _, err := numbers(op, params)
+	if err != nil {
+		return nil, err
+	}
+	if fs[0] < 0 {
+		return nil, eval.OpExecError(op, fmt.Errorf("square root of negative number: %v", params[0]))
+	}
+	return math.Sqrt(fs[0]), nil
+}
+
+// round returns x rounded to the digits after the decimal point, 0 by default,
+// the halves are rounded away from zero, the int64 values are returned as they are
+// e.g. (round x) (round x 2)
+func round(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "round"
+	if err := checkCount(op, params, 1, 2); err != nil {
+		return nil, err
+	}
+	var digits int64
+	if len(params) == 2 {
+		d, ok := params[1].(int64)
+		if !ok {
+			return nil, eval.ParamTypeError(op, "int64", params[1])
+		}
+		digits = d
+	}
+
+	switch v := params[0].(type) {
+	case int64:
+		return v, nil
+	case float64:
+		if digits == 0 {
+			return math.Round(v), nil
+		}
+		scale := math.Pow(10, float64(digits))
+		return math.Round(v*scale) / scale, nil
+	}
+	return nil, eval.ParamTypeError(op, typeNumber, params[0])
+}
+
+// rounding returns x rounded by fn, the int64 values are returned as they are
+// e.g. (floor x) (ceil x)
+func rounding(op string, fn func(float64) float64) eval.Operator {
+	return func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		if err := checkCount(op, params, 1, 1); err != nil {
+			return nil, err
+		}
+		switch v := params[0].(type) {
+		case int64:
+			return v, nil
+		case float64:
+			return fn(v), nil
+		}
+		return nil, eval.ParamTypeError(op, typeNumber, params[0])
+	}
+}
+
+// clamp returns x limited to the range [lo, hi]
+// e.g. (clamp x lo hi)
+func clamp(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "clamp"
+	if err := checkCount(op, params, 3, 3); err != nil {
+		return nil, err
+	}
+	fs, isInt, err := numbers(op, params)
+	if err != nil {
+		return nil, err
+	}
+	if fs[1] > fs[2] {
+		return nil, eval.OpExecError(op, fmt.Errorf("lower bound %v is greater than upper bound %v", params[1], params[2]))
+	}
+
+	if isInt {
+		x, lo, hi := params[0].(int64), params[1].(int64), params[2].(int64)
+		switch {
+		case x < lo:
+			return lo, nil
+		case x > hi:
+			return hi, nil
+		}
+		return x, nil
+	}
+	return math.Min(math.Max(fs[0], fs[1]), fs[2]), nil
+}
+
+// log returns the natural logarithm of x, or the logarithm of the base if it's specified
+// e.g. (log x) (log x 10)
+func log(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "log"
+	if err := checkCount(op, params, 1, 2); err != nil {
+		return nil, err
+	}
+	fs, _, err := numbers(op, params)
+	if err != nil {
+		return nil, err
+	}
+	if fs[0] <= 0 {
+		return nil, eval.OpExecError(op, fmt.Errorf("logarithm of non-positive number: %v", params[0]))
+	}
+	if len(fs) == 1 {
+		return math.Log(fs[0]), nil
+	}
+	if fs[1] <= 0 || fs[1] == 1 {
+		return nil, eval.OpExecError(op, fmt.Errorf("invalid logarithm base: %v", params[1]))
+	}
+	return math.Log(fs[0]) / math.Log(fs[1]), nil
+}
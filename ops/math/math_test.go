@@ -0,0 +1,88 @@
+package math
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval"
+)
+
+func TestOperators(t *testing.T) {
+	vals := map[string]interface{}{
+		"score":    -42,
+		"ratio":    -2.5,
+		"variance": 16.0,
+		"big":      int64(math.MaxInt64),
+	}
+
+	testCases := []struct {
+		expr   string
+		want   eval.Value
+		errMsg string
+	}{
+		{expr: `(abs score)`, want: int64(42)},
+		{expr: `(abs ratio)`, want: 2.5},
+		{expr: `(min 3 1 2)`, want: int64(1)},
+		{expr: `(max 3 1 2)`, want: int64(3)},
+		{expr: `(max score 1.5)`, want: 1.5},
+		{expr: `(min big (- big 1))`, want: int64(math.MaxInt64 - 1)},
+		{expr: `(pow 2 10)`, want: int64(1024)},
+		{expr: `(pow -2 63)`, want: int64(math.MinInt64)},
+		{expr: `(pow 2 -1)`, want: 0.5},
+		{expr: `(pow 4.0 0.5)`, want: 2.0},
+		{expr: `(sqrt variance)`, want: 4.0},
+		{expr: `(sqrt 9)`, want: 3.0},
+		{expr: `(round ratio)`, want: -3.0},
+		{expr: `(round 2.345 2)`, want: 2.35},
+		{expr: `(round score)`, want: int64(-42)},
+		{expr: `(floor ratio)`, want: -3.0},
+		{expr: `(ceil ratio)`, want: -2.0},
+		{expr: `(ceil score)`, want: int64(-42)},
+		{expr: `(clamp score 0 100)`, want: int64(0)},
+		{expr: `(clamp 150 0 100)`, want: int64(100)},
+		{expr: `(clamp ratio -1 1.5)`, want: -1.0},
+		{expr: `(log 1)`, want: 0.0},
+		{expr: `(log 1000 10)`, want: 2.9999999999999996},
+		{expr: `(log 8 2)`, want: 3.0},
+		{expr: `(< (abs (- score (round ratio))) (sqrt 10000))`, want: true},
+
+		{expr: `(abs)`, errMsg: "unexpected params count"},
+		{expr: `(abs "x")`, errMsg: "unexpected param type"},
+		{expr: `(abs -9223372036854775808)`, errMsg: "integer overflow"},
+		{expr: `(pow 10 19)`, errMsg: "integer overflow"},
+		{expr: `(sqrt -1)`, errMsg: "square root of negative number"},
+		{expr: `(round 1.5 "x")`, errMsg: "unexpected param type"},
+		{expr: `(clamp 1 10 0)`, errMsg: "lower bound 10 is greater than upper bound 0"},
+		{expr: `(log 0)`, errMsg: "logarithm of non-positive number"},
+		{expr: `(log 10 1)`, errMsg: "invalid logarithm base"},
+		{expr: `(min)`, errMsg: "unexpected params count"},
+	}
+
+	cc := eval.NewCompileConfig(eval.RegisterSelKeys(vals))
+	if err := Register(cc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range testCases {
+		got, err := eval.Eval(c.expr, vals, cc)
+		if len(c.errMsg) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Fatalf("unexpected error, expr: %s, got: %v, want: %s", c.expr, err, c.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, err: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected result, expr: %s, got: %v, want: %v", c.expr, got, c.want)
+		}
+	}
+
+	// registering twice causes conflicts
+	if err := Register(cc); err == nil {
+		t.Fatal("operators should not be registered twice")
+	}
+}
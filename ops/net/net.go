@@ -0,0 +1,225 @@
+// Package net provides an opt-in module of IP and CIDR operators over the IPv4 and IPv6 strings,
+// the operators and their specializers can be registered to a CompileConfig by Register.
+//
+//	cc := eval.NewCompileConfig()
+//	if err := net.Register(cc); err != nil {
+//		...
+//	}
+//	expr, err := eval.Compile(cc, `(and (not (isPrivateIP ip)) (ipInCIDR ip ("10.0.0.0/8" "192.168.0.0/16")))`)
+//
+// The constant CIDRs and ranges are parsed only once at compile time, and the invalid ones fail the compilation.
+// The IPv4-mapped IPv6 addresses are treated as the IPv4 ones, e.g. "::ffff:10.0.0.1" is in "10.0.0.0/8".
+package net
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/larry618/eval"
+)
+
+const (
+	typeStr     = "string"
+	typeStrList = "[]string"
+)
+
+// Operators contains all the operators of the module
+var Operators = map[string]eval.Operator{
+	"ipInCIDR":    ipInCIDR,
+	"isPrivateIP": isPrivateIP,
+	"ipBetween":   ipBetween,
+}
+
+// Specializers contains the specializers of the operators with the constant params
+var Specializers = map[string]eval.OperatorSpecializer{
+	"ipInCIDR":  specializeIPInCIDR,
+	"ipBetween": specializeIPBetween,
+}
+
+// Register registers all the operators and their specializers of the module to cc
+func Register(cc *eval.CompileConfig) error {
+	for name, op := range Operators {
+		if err := eval.RegisterOperator(cc, name, op); err != nil {
+			return err
+		}
+	}
+	for name, s := range Specializers {
+		if err := eval.RegisterSpecializer(cc, name, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseIP(op string, p eval.Value) (netip.Addr, error) {
+	s, ok := p.(string)
+	if !ok {
+		return netip.Addr{}, eval.ParamTypeError(op, typeStr, p)
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, eval.OpExecError(op, fmt.Errorf("invalid ip: %q", s))
+	}
+	return ip.Unmap(), nil
+}
+
+// parseCIDRs parses the CIDR or the list of CIDRs, e.g. "10.0.0.0/8" or ("10.0.0.0/8" "fc00::/7")
+func parseCIDRs(op string, p eval.Value) ([]netip.Prefix, error) {
+	var cidrs []string
+	switch v := p.(type) {
+	case string:
+		cidrs = []string{v}
+	case []string:
+		cidrs = v
+	default:
+		return nil, eval.ParamTypeError(op, typeStr+" or "+typeStrList, p)
+	}
+
+	res := make([]netip.Prefix, len(cidrs))
+	for i, s := range cidrs {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, eval.OpExecError(op, fmt.Errorf("invalid cidr: %q", s))
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		res[i] = prefix.Masked()
+	}
+	return res, nil
+}
+
+func checkCount(op string, params []eval.Value, cnt int) error {
+	if len(params) != cnt {
+		return eval.ParamsCountError(op, cnt, len(params))
+	}
+	return nil
+}
+
+func containsIP(cidrs []netip.Prefix, ip netip.Addr) bool {
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipInCIDR reports whether the ip is in the CIDR or any of the CIDRs
+// e.g. (ipInCIDR ip "10.0.0.0/8"), (ipInCIDR ip ("10.0.0.0/8" "fc00::/7"))
+func ipInCIDR(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "ipInCIDR"
+	if err := checkCount(op, params, 2); err != nil {
+		return nil, err
+	}
+	cidrs, err := parseCIDRs(op, params[1])
+	if err != nil {
+		return nil, err
+	}
+	ip, err := parseIP(op, params[0])
+	if err != nil {
+		return nil, err
+	}
+	return containsIP(cidrs, ip), nil
+}
+
+// specializeIPInCIDR parses the constant CIDRs only once at compile time
+func specializeIPInCIDR(consts []eval.Value, known []bool) (eval.Operator, error) {
+	const op = "ipInCIDR"
+	if len(consts) != 2 || !known[1] {
+		return nil, nil
+	}
+	cidrs, err := parseCIDRs(op, consts[1])
+	if err != nil {
+		return nil, err
+	}
+	return func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		if err := checkCount(op, params, 2); err != nil {
+			return nil, err
+		}
+		ip, err := parseIP(op, params[0])
+		if err != nil {
+			return nil, err
+		}
+		return containsIP(cidrs, ip), nil
+	}, nil
+}
+
+// isPrivateIP reports whether the ip is a private address of RFC 1918 or RFC 4193,
+// e.g. "10.0.0.1", "192.168.1.1", "fd00::1", the loopback addresses are not private
+func isPrivateIP(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "isPrivateIP"
+	if err := checkCount(op, params, 1); err != nil {
+		return nil, err
+	}
+	ip, err := parseIP(op, params[0])
+	if err != nil {
+		return nil, err
+	}
+	return ip.IsPrivate(), nil
+}
+
+// ipRange is the inclusive range of the addresses of the same family
+type ipRange struct {
+	start, end netip.Addr
+}
+
+func parseRange(op string, start, end eval.Value) (ipRange, error) {
+	from, err := parseIP(op, start)
+	if err != nil {
+		return ipRange{}, err
+	}
+	to, err := parseIP(op, end)
+	if err != nil {
+		return ipRange{}, err
+	}
+	if from.BitLen() != to.BitLen() {
+		return ipRange{}, eval.OpExecError(op, fmt.Errorf("ip range of different families: %s, %s", from, to))
+	}
+	return ipRange{start: from, end: to}, nil
+}
+
+// contains reports whether the ip is in the range, the ones of the other family are not
+func (r ipRange) contains(ip netip.Addr) bool {
+	return ip.BitLen() == r.start.BitLen() && r.start.Compare(ip) <= 0 && ip.Compare(r.end) <= 0
+}
+
+// ipBetween reports whether the ip is in the inclusive range
+// e.g. (ipBetween ip "10.0.0.1" "10.0.0.99")
+func ipBetween(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "ipBetween"
+	if err := checkCount(op, params, 3); err != nil {
+		return nil, err
+	}
+	r, err := parseRange(op, params[1], params[2])
+	if err != nil {
+		return nil, err
+	}
+	ip, err := parseIP(op, params[0])
+	if err != nil {
+		return nil, err
+	}
+	return r.contains(ip), nil
+}
+
+// specializeIPBetween parses the constant range only once at compile time
+func specializeIPBetween(consts []eval.Value, known []bool) (eval.Operator, error) {
+	const op = "ipBetween"
+	if len(consts) != 3 || !known[1] || !known[2] {
+		return nil, nil
+	}
+	r, err := parseRange(op, consts[1], consts[2])
+	if err != nil {
+		return nil, err
+	}
+	return func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		if err := checkCount(op, params, 3); err != nil {
+			return nil, err
+		}
+		ip, err := parseIP(op, params[0])
+		if err != nil {
+			return nil, err
+		}
+		return r.contains(ip), nil
+	}, nil
+}
@@ -0,0 +1,93 @@
+package net
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval"
+)
+
+func TestOperators(t *testing.T) {
+	vals := map[string]interface{}{
+		"ip":      "10.1.2.3",
+		"public":  "8.8.8.8",
+		"ipv6":    "fd12::1",
+		"mapped":  "::ffff:192.168.1.10",
+		"cidr":    "10.0.0.0/8",
+		"invalid": "10.0.0",
+		"port":    8080,
+	}
+
+	testCases := []struct {
+		expr   string
+		want   eval.Value
+		errMsg string
+	}{
+		{expr: `(ipInCIDR ip "10.0.0.0/8")`, want: true},
+		{expr: `(ipInCIDR ip "10.1.2.0/24")`, want: true},
+		{expr: `(ipInCIDR ip "10.1.3.0/24")`, want: false},
+		{expr: `(ipInCIDR ip "10.1.2.3/32")`, want: true},
+		{expr: `(ipInCIDR public "0.0.0.0/0")`, want: true},
+		{expr: `(ipInCIDR ip cidr)`, want: true},
+		{expr: `(ipInCIDR public ("10.0.0.0/8" "8.8.0.0/16"))`, want: true},
+		{expr: `(ipInCIDR public ("10.0.0.0/8" "172.16.0.0/12"))`, want: false},
+		{expr: `(ipInCIDR ipv6 "fc00::/7")`, want: true},
+		{expr: `(ipInCIDR ipv6 "10.0.0.0/8")`, want: false},
+		{expr: `(ipInCIDR mapped "192.168.0.0/16")`, want: true},
+		{expr: `(ipInCIDR ip "::ffff:10.0.0.0/104")`, want: true},
+		{expr: `(isPrivateIP ip)`, want: true},
+		{expr: `(isPrivateIP public)`, want: false},
+		{expr: `(isPrivateIP ipv6)`, want: true},
+		{expr: `(isPrivateIP mapped)`, want: true},
+		{expr: `(isPrivateIP "127.0.0.1")`, want: false},
+		{expr: `(ipBetween ip "10.0.0.0" "10.255.255.255")`, want: true},
+		{expr: `(ipBetween ip "10.1.2.3" "10.1.2.3")`, want: true},
+		{expr: `(ipBetween public "10.0.0.0" "10.255.255.255")`, want: false},
+		{expr: `(ipBetween ipv6 "10.0.0.0" "10.255.255.255")`, want: false},
+		{expr: `(ipBetween ipv6 "fd00::" "fdff::")`, want: true},
+		{expr: `(ipBetween ip cidr "10.255.255.255")`, errMsg: `invalid ip: "10.0.0.0/8"`},
+		{expr: `(and (not (isPrivateIP public)) (ipInCIDR ip ("10.0.0.0/8" "192.168.0.0/16")))`, want: true},
+
+		{expr: `(ipInCIDR invalid "10.0.0.0/8")`, errMsg: `invalid ip: "10.0.0"`},
+		{expr: `(ipInCIDR port "10.0.0.0/8")`, errMsg: "unexpected param type"},
+		{expr: `(ipInCIDR ip "10.0.0.0/33")`, errMsg: `invalid cidr: "10.0.0.0/33"`},
+		{expr: `(ipInCIDR ip invalid)`, errMsg: `invalid cidr: "10.0.0"`},
+		{expr: `(ipInCIDR ip 8)`, errMsg: "expected: string or []string"},
+		{expr: `(ipInCIDR ip)`, errMsg: "unexpected params count"},
+		{expr: `(isPrivateIP invalid)`, errMsg: `invalid ip: "10.0.0"`},
+		{expr: `(ipBetween ip "10.0.0.0" "ff::")`, errMsg: "ip range of different families"},
+		{expr: `(ipBetween ip "10.0.0.0")`, errMsg: "unexpected params count"},
+	}
+
+	cc := eval.NewCompileConfig(eval.RegisterSelKeys(vals))
+	if err := Register(cc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range testCases {
+		got, err := eval.Eval(c.expr, vals, cc)
+		if len(c.errMsg) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Fatalf("unexpected error, expr: %s, got: %v, want: %s", c.expr, err, c.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, err: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected result, expr: %s, got: %v, want: %v", c.expr, got, c.want)
+		}
+	}
+
+	// the invalid constant CIDRs fail the compilation
+	if _, err := eval.Compile(cc, `(ipInCIDR ip "10.0.0.0/33")`); err == nil || !strings.Contains(err.Error(), "invalid cidr") {
+		t.Fatalf("unexpected error, got: %v, want: invalid cidr", err)
+	}
+
+	// registering twice causes conflicts
+	if err := Register(cc); err == nil {
+		t.Fatal("operators should not be registered twice")
+	}
+}
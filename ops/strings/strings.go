@@ -0,0 +1,135 @@
+// Package strings provides an opt-in module of string operators,
+// the operators can be registered to a CompileConfig by Register.
+//
+//	cc := eval.NewCompileConfig()
+//	if err := strings.Register(cc); err != nil {
+//		...
+//	}
+//	expr, err := eval.Compile(cc, `(startsWith (lower name) "larry")`)
+package strings
+
+import (
+	"strings"
+
+	"github.com/larry618/eval"
+)
+
+const (
+	typeStr     = "string"
+	typeStrList = "[]string"
+)
+
+// Operators contains all the operators of the module
+var Operators = map[string]eval.Operator{
+	"contains":   strPredicate("contains", strings.Contains),
+	"startsWith": strPredicate("startsWith", strings.HasPrefix),
+	"endsWith":   strPredicate("endsWith", strings.HasSuffix),
+	"lower":      strMapping("lower", strings.ToLower),
+	"upper":      strMapping("upper", strings.ToUpper),
+	"trim":       trim,
+	"split":      split,
+	"join":       join,
+	"replace":    replace,
+}
+
+// Register registers all the operators of the module to cc
+func Register(cc *eval.CompileConfig) error {
+	for name, op := range Operators {
+		if err := eval.RegisterOperator(cc, name, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func strParams(op string, params []eval.Value, cnt int) ([]string, error) {
+	if len(params) != cnt {
+		return nil, eval.ParamsCountError(op, cnt, len(params))
+	}
+	res := make([]string, cnt)
+	for i, p := range params {
+		s, ok := p.(string)
+		if !ok {
+			return nil, eval.ParamTypeError(op, typeStr, p)
+		}
+		res[i] = s
+	}
+	return res, nil
+}
+
+func strPredicate(op string, fn func(s, t string) bool) eval.Operator {
+	return func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		strs, err := strParams(op, params, 2)
+		if err != nil {
+			return nil, err
+		}
+		return fn(strs[0], strs[1]), nil
+	}
+}
+
+func strMapping(op string, fn func(s string) string) eval.Operator {
+	return func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		strs, err := strParams(op, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return fn(strs[0]), nil
+	}
+}
+
+// trim returns s without leading and trailing white spaces,
+// or without the leading and trailing characters in the cutset if it's specified
+// e.g. (trim s) (trim s cutset)
+func trim(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "trim"
+	if len(params) == 1 {
+		strs, err := strParams(op, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return strings.TrimSpace(strs[0]), nil
+	}
+	strs, err := strParams(op, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Trim(strs[0], strs[1]), nil
+}
+
+// split slices s into all substrings separated by sep
+// e.g. (split s sep)
+func split(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	strs, err := strParams("split", params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strs[0], strs[1]), nil
+}
+
+// join concatenates the elements of list with sep
+// e.g. (join list sep)
+func join(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "join"
+	if len(params) != 2 {
+		return nil, eval.ParamsCountError(op, 2, len(params))
+	}
+	list, ok := params[0].([]string)
+	if !ok {
+		return nil, eval.ParamTypeError(op, typeStrList, params[0])
+	}
+	sep, ok := params[1].(string)
+	if !ok {
+		return nil, eval.ParamTypeError(op, typeStr, params[1])
+	}
+	return strings.Join(list, sep), nil
+}
+
+// replace returns a copy of s with all the old replaced by new
+// e.g. (replace s old new)
+func replace(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	strs, err := strParams("replace", params, 3)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ReplaceAll(strs[0], strs[1], strs[2]), nil
+}
@@ -0,0 +1,66 @@
+package strings
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval"
+)
+
+func TestOperators(t *testing.T) {
+	vals := map[string]interface{}{
+		"name": "  Larry Page ",
+		"tags": []string{"a", "b", "c"},
+	}
+
+	testCases := []struct {
+		expr   string
+		want   eval.Value
+		errMsg string
+	}{
+		{expr: `(contains name "Page")`, want: true},
+		{expr: `(contains name "page")`, want: false},
+		{expr: `(startsWith (trim name) "Larry")`, want: true},
+		{expr: `(endsWith name "Page")`, want: false},
+		{expr: `(lower (trim name))`, want: "larry page"},
+		{expr: `(upper (trim name))`, want: "LARRY PAGE"},
+		{expr: `(trim "--a-b--" "-")`, want: "a-b"},
+		{expr: `(split "a,b,c" ",")`, want: []string{"a", "b", "c"}},
+		{expr: `(join tags "-")`, want: "a-b-c"},
+		{expr: `(join (split "a b c" " ") ",")`, want: "a,b,c"},
+		{expr: `(replace (trim name) " " "_")`, want: "Larry_Page"},
+		{expr: `(in "b" (split "a,b" ","))`, want: true},
+
+		{expr: `(contains name)`, errMsg: "unexpected params count"},
+		{expr: `(lower 1)`, errMsg: "unexpected param type"},
+		{expr: `(join name ",")`, errMsg: "unexpected param type"},
+		{expr: `(replace name "a")`, errMsg: "unexpected params count"},
+	}
+
+	cc := eval.NewCompileConfig(eval.RegisterSelKeys(vals))
+	if err := Register(cc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range testCases {
+		got, err := eval.Eval(c.expr, vals, cc)
+		if len(c.errMsg) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Fatalf("unexpected error, expr: %s, got: %v, want: %s", c.expr, err, c.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, err: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected result, expr: %s, got: %v, want: %v", c.expr, got, c.want)
+		}
+	}
+
+	// registering twice causes conflicts
+	if err := Register(cc); err == nil {
+		t.Fatal("operators should not be registered twice")
+	}
+}
@@ -0,0 +1,279 @@
+// Package time provides an opt-in module of datetime operators,
+// the operators can be registered to a CompileConfig by Register.
+// The times are represented by unix seconds and the durations by seconds,
+// the same as the builtin operators, e.g. (date "2006-01-02") and (datetime "2006-01-02 15:04:05").
+//
+//	cc := eval.NewCompileConfig()
+//	if err := time.Register(cc, time.WithLocation(loc)); err != nil {
+//		...
+//	}
+//	expr, err := eval.Compile(cc, `(and (after (now) (- deadline (duration "2h30m"))) (< (hour (now)) 18))`)
+//
+// The calendar operators use the location of the module, UTC by default,
+// and they accept the IANA time zone name as the optional last param,
+// e.g. (hour (now) "Asia/Tokyo").
+package time
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/larry618/eval"
+)
+
+const (
+	typeInt = "int64"
+	typeStr = "string"
+)
+
+// Operators contains all the operators of the module with the default options
+var Operators = newOperators(defaultOptions())
+
+type options struct {
+	loc *time.Location
+	now func() time.Time
+}
+
+func defaultOptions() options {
+	return options{
+		loc: time.UTC,
+		now: time.Now,
+	}
+}
+
+// Option configures the operators registered by Register
+type Option func(o *options)
+
+// WithLocation sets the default location of the calendar operators
+func WithLocation(loc *time.Location) Option {
+	return func(o *options) {
+		o.loc = loc
+	}
+}
+
+// WithClock replaces the clock of the now operator, it's mainly used in tests
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// Register registers all the operators of the module to cc
+func Register(cc *eval.CompileConfig, opts ...Option) error {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for name, op := range newOperators(o) {
+		if err := eval.RegisterOperator(cc, name, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newOperators(o options) map[string]eval.Operator {
+	c := calendar{loc: o.loc}
+	return map[string]eval.Operator{
+		"now": func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+			if len(params) != 0 {
+				return nil, eval.ParamsCountError("now", 0, len(params))
+			}
+			return o.now().Unix(), nil
+		},
+		"duration": duration,
+		"before":   compare("before", func(a, b int64) bool { return a < b }),
+		"after":    compare("after", func(a, b int64) bool { return a > b }),
+
+		"year":       c.field("year", func(t time.Time) int { return t.Year() }),
+		"month":      c.field("month", func(t time.Time) int { return int(t.Month()) }),
+		"day":        c.field("day", func(t time.Time) int { return t.Day() }),
+		"hour":       c.field("hour", func(t time.Time) int { return t.Hour() }),
+		"minute":     c.field("minute", func(t time.Time) int { return t.Minute() }),
+		"dayOfWeek":  c.field("dayOfWeek", func(t time.Time) int { return int(t.Weekday()) }),
+		"startOfDay": c.startOfDay,
+		"dateDiff":   c.dateDiff,
+	}
+}
+
+// duration parses the duration string to seconds, e.g. (duration "2h30m"),
+// the units are the same as time.ParseDuration, plus "d" for days, e.g. (duration "1d12h")
+func duration(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "duration"
+	if len(params) != 1 {
+		return nil, eval.ParamsCountError(op, 1, len(params))
+	}
+	s, ok := params[0].(string)
+	if !ok {
+		return nil, eval.ParamTypeError(op, typeStr, params[0])
+	}
+	d, err := parseDuration(s)
+	if err != nil {
+		return nil, eval.OpExecError(op, err)
+	}
+	return int64(d / time.Second), nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	sign, rest := "", s
+	if strings.HasPrefix(rest, "-") || strings.HasPrefix(rest, "+") {
+		sign, rest = rest[:1], rest[1:]
+	}
+
+	var days time.Duration
+	if i := strings.IndexByte(rest, 'd'); i != -1 {
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		days, rest = time.Duration(n)*24*time.Hour, rest[i+1:]
+	}
+
+	var d time.Duration
+	if rest != "" || days == 0 {
+		var err error
+		if d, err = time.ParseDuration(rest); err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	if sign == "-" {
+		return -(days + d), nil
+	}
+	return days + d, nil
+}
+
+func compare(op string, fn func(a, b int64) bool) eval.Operator {
+	return func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		if len(params) != 2 {
+			return nil, eval.ParamsCountError(op, 2, len(params))
+		}
+		a, ok := params[0].(int64)
+		if !ok {
+			return nil, eval.ParamTypeError(op, typeInt, params[0])
+		}
+		b, ok := params[1].(int64)
+		if !ok {
+			return nil, eval.ParamTypeError(op, typeInt, params[1])
+		}
+		return fn(a, b), nil
+	}
+}
+
+// locations caches the loaded locations by their names
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, exist := locations.Load(name); exist {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+type calendar struct {
+	loc *time.Location
+}
+
+// times converts the first cnt params to times, the optional param after them is the time zone
+func (c calendar) times(op string, params []eval.Value, cnt int) ([]time.Time, error) {
+	if len(params) != cnt && len(params) != cnt+1 {
+		return nil, eval.ParamsCountError(op, cnt, len(params))
+	}
+
+	loc := c.loc
+	if len(params) > cnt {
+		name, ok := params[cnt].(string)
+		if !ok {
+			return nil, eval.ParamTypeError(op, typeStr, params[cnt])
+		}
+		var err error
+		if loc, err = loadLocation(name); err != nil {
+			return nil, eval.OpExecError(op, err)
+		}
+	}
+
+	res := make([]time.Time, cnt)
+	for i, p := range params[:cnt] {
+		sec, ok := p.(int64)
+		if !ok {
+			return nil, eval.ParamTypeError(op, typeInt, p)
+		}
+		res[i] = time.Unix(sec, 0).In(loc)
+	}
+	return res, nil
+}
+
+// field returns the calendar field of the time, e.g. (hour t), (dayOfWeek t "Asia/Tokyo"),
+// the day of week starts from Sunday, which is 0
+func (c calendar) field(op string, fn func(t time.Time) int) eval.Operator {
+	return func(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+		ts, err := c.times(op, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return int64(fn(ts[0])), nil
+	}
+}
+
+// startOfDay returns the midnight of the day, e.g. (startOfDay t)
+func (c calendar) startOfDay(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "startOfDay"
+	ts, err := c.times(op, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	t := ts[0]
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Unix(), nil
+}
+
+// dateDiff returns the number of the units from the first time to the second one,
+// e.g. (dateDiff start end "day"), the units are second, minute, hour, day, week, month and year.
+// The days, weeks, months and years are the differences of the calendar dates,
+// and the others are truncated.
+func (c calendar) dateDiff(_ *eval.Ctx, params []eval.Value) (eval.Value, error) {
+	const op = "dateDiff"
+	if len(params) != 3 && len(params) != 4 {
+		return nil, eval.ParamsCountError(op, 3, len(params))
+	}
+	unit, ok := params[2].(string)
+	if !ok {
+		return nil, eval.ParamTypeError(op, typeStr, params[2])
+	}
+	// the time zone is the optional last param
+	ts, err := c.times(op, append(params[:2:2], params[3:]...), 2)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := ts[0], ts[1]
+	days := func() int64 {
+		a := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+		b := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+		return int64(b.Sub(a) / (24 * time.Hour))
+	}
+
+	switch unit {
+	case "second":
+		return to.Unix() - from.Unix(), nil
+	case "minute":
+		return (to.Unix() - from.Unix()) / 60, nil
+	case "hour":
+		return (to.Unix() - from.Unix()) / 3600, nil
+	case "day":
+		return days(), nil
+	case "week":
+		return days() / 7, nil
+	case "month":
+		return int64((to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())), nil
+	case "year":
+		return int64(to.Year() - from.Year()), nil
+	}
+	return nil, eval.OpExecError(op, fmt.Errorf("unknown unit: %s", unit))
+}
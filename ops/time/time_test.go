@@ -0,0 +1,110 @@
+package time
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/larry618/eval"
+)
+
+func TestOperators(t *testing.T) {
+	// Saturday, 2024-03-09 22:30:00 UTC
+	now := time.Date(2024, 3, 9, 22, 30, 0, 0, time.UTC)
+	vals := map[string]interface{}{
+		"created":  now.Add(-36 * time.Hour).Unix(),
+		"deadline": now.Add(2 * time.Hour).Unix(),
+	}
+
+	testCases := []struct {
+		expr   string
+		want   eval.Value
+		errMsg string
+	}{
+		{expr: `(now)`, want: now.Unix()},
+		{expr: `(duration "2h30m")`, want: int64(9000)},
+		{expr: `(duration "1d12h")`, want: int64(129600)},
+		{expr: `(duration "-2d")`, want: int64(-172800)},
+		{expr: `(before created (now))`, want: true},
+		{expr: `(after (now) (- deadline (duration "1h")))`, want: false},
+		{expr: `(year (now))`, want: int64(2024)},
+		{expr: `(month (now))`, want: int64(3)},
+		{expr: `(day (now))`, want: int64(9)},
+		{expr: `(hour (now))`, want: int64(22)},
+		{expr: `(minute (now))`, want: int64(30)},
+		{expr: `(dayOfWeek (now))`, want: int64(6)},
+		// the time zone is specified
+		{expr: `(hour (now) "Asia/Tokyo")`, want: int64(7)},
+		{expr: `(dayOfWeek (now) "Asia/Tokyo")`, want: int64(0)},
+		{expr: `(startOfDay (now))`, want: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC).Unix()},
+		{expr: `(dateDiff created (now) "hour")`, want: int64(36)},
+		{expr: `(dateDiff created (now) "day")`, want: int64(1)},
+		{expr: `(dateDiff created (now) "day" "Asia/Tokyo")`, want: int64(2)},
+		{expr: `(dateDiff (now) created "minute")`, want: int64(-2160)},
+		{expr: `(dateDiff (date "2023-11-30") (now) "month")`, want: int64(4)},
+		{expr: `(dateDiff (date "2023-11-30") (now) "year")`, want: int64(1)},
+		{expr: `(dateDiff (date "2024-01-01") (now) "week")`, want: int64(9)},
+		{expr: `(between (hour (now)) 9 23)`, want: true},
+
+		{expr: `(now 1)`, errMsg: "unexpected params count"},
+		{expr: `(duration "2x")`, errMsg: `invalid duration "2x"`},
+		{expr: `(duration "d")`, errMsg: `invalid duration "d"`},
+		{expr: `(before (now) "now")`, errMsg: "unexpected param type"},
+		{expr: `(hour "now")`, errMsg: "unexpected param type"},
+		{expr: `(hour (now) "Mars/Base")`, errMsg: "unknown time zone Mars/Base"},
+		{expr: `(hour (now) 8)`, errMsg: "unexpected param type"},
+		{expr: `(hour (now) "UTC" 1)`, errMsg: "unexpected params count"},
+		{expr: `(dateDiff created (now))`, errMsg: "unexpected params count"},
+		{expr: `(dateDiff created (now) "decade")`, errMsg: "unknown unit: decade"},
+	}
+
+	cc := eval.NewCompileConfig(eval.RegisterSelKeys(vals))
+	if err := Register(cc, WithClock(func() time.Time { return now })); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range testCases {
+		got, err := eval.Eval(c.expr, vals, cc)
+		if len(c.errMsg) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Fatalf("unexpected error, expr: %s, got: %v, want: %s", c.expr, err, c.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error, expr: %s, err: %v", c.expr, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected result, expr: %s, got: %v, want: %v", c.expr, got, c.want)
+		}
+	}
+
+	// registering twice causes conflicts
+	if err := Register(cc); err == nil {
+		t.Fatal("operators should not be registered twice")
+	}
+}
+
+func TestWithLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	// 2024-03-08 21:00:00 in New York
+	vals := map[string]interface{}{
+		"ts": time.Date(2024, 3, 9, 2, 0, 0, 0, time.UTC).Unix(),
+	}
+	cc := eval.NewCompileConfig(eval.RegisterSelKeys(vals))
+	if err := Register(cc, WithLocation(loc)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := eval.Eval(`(list (day ts) (hour ts) (day ts "UTC"))`, vals, cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{8, 21, 9}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected result, got: %v, want: %v", got, want)
+	}
+}
@@ -3,6 +3,7 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 	"unicode"
@@ -12,6 +13,8 @@ type tokenType string
 
 const (
 	integer tokenType = "integer"
+	float   tokenType = "float"
+	decimal tokenType = "decimal"
 	str     tokenType = "str"
 	ident   tokenType = "ident"
 	lParen  tokenType = "lParen"
@@ -34,6 +37,8 @@ type astNode struct {
 	node     *node
 	children []*astNode
 	cost     int
+	// pos is the index of the token in the source, it's used in the error messages
+	pos int
 }
 
 type parser struct {
@@ -41,6 +46,12 @@ type parser struct {
 	conf   *CompileConfig
 	tokens []token
 	idx    int
+
+	// unknown operators and selectors are kept in the tree if deferResolving is true,
+	// they're resolved after the tree is rewritten
+	deferResolving bool
+	// the params of the enclosing lambdas
+	bindings []string
 }
 
 func newParser(cc *CompileConfig, source string) *parser {
@@ -78,7 +89,7 @@ func (p *parser) lex() error {
 
 		lexInteger = func(A []rune, i int) (token, int) {
 			s, j := nextToken(A, i)
-			if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			if isBigIntLiteral(s) {
 				return token{
 					typ: integer,
 					val: s,
@@ -87,6 +98,29 @@ func (p *parser) lex() error {
 			return token{}, i
 		}
 
+		lexFloat = func(A []rune, i int) (token, int) {
+			s, j := nextToken(A, i)
+			if isFloatLiteral(s) {
+				return token{
+					typ: float,
+					val: s,
+				}, j
+			}
+			return token{}, i
+		}
+
+		// the decimal literals are suffixed with d, e.g. 19.99d
+		lexDecimal = func(A []rune, i int) (token, int) {
+			s, j := nextToken(A, i)
+			if num := strings.TrimSuffix(s, "d"); num != s && isDecimalLiteral(num) {
+				return token{
+					typ: decimal,
+					val: num,
+				}, j
+			}
+			return token{}, i
+		}
+
 		lexStr = func(A []rune, i int) (token, int) {
 			const quote = '"'
 			if A[i] != quote {
@@ -107,7 +141,8 @@ func (p *parser) lex() error {
 		lexIdent = func(A []rune, i int) (token, int) {
 			s, j := nextToken(A, i)
 
-			for idx, r := range []rune(s) {
+			rs := []rune(s)
+			for idx, r := range rs {
 				if unicode.IsNumber(r) {
 					if idx != 0 {
 						continue
@@ -119,6 +154,14 @@ func (p *parser) lex() error {
 				if r == '_' {
 					continue
 				}
+				if r == '.' && idx != 0 {
+					// the dot-path of the nested fields, e.g. user.address.city
+					continue
+				}
+				if r == '?' && idx != 0 && idx+1 < len(rs) && rs[idx+1] == '.' {
+					// the optional chaining, e.g. user?.address?.city
+					continue
+				}
 
 				// if the code execute to here, it means
 				// the ident contains special character
@@ -160,35 +203,109 @@ func (p *parser) lex() error {
 		lexers = []func([]rune, int) (token, int){
 			lexParen,
 			lexInteger,
+			lexFloat,
+			lexDecimal,
 			lexStr,
 			lexIdent,
 			lexComment,
 		}
-	)
 
-	var tokens []token
-	A := []rune(p.source)
-	for i := 0; i < len(A); {
-		r := A[i]
-		if unicode.IsSpace(r) {
-			i++
-			continue
+		// lexRunes lexes the runes starting at the offset of the source
+		lexRunes func(A []rune, offset int) ([]token, error)
+
+		// lexTemplate expands the template string to a format operator,
+		// e.g. `order-${region}-${id}` => (format "order-%v-%v" region id)
+		lexTemplate = func(A []rune, i, offset int) ([]token, int, error) {
+			var (
+				text, format strings.Builder
+				args         []token
+				cnt          int
+			)
+			for j := i + 1; j < len(A); j++ {
+				switch {
+				case A[j] == '`':
+					start := offset + i
+					if cnt == 0 {
+						return []token{{typ: str, val: text.String(), pos: start}}, j + 1, nil
+					}
+					tokens := []token{
+						{typ: lParen, val: "(", pos: start},
+						{typ: ident, val: "format", pos: start},
+						{typ: str, val: format.String(), pos: start},
+					}
+					tokens = append(tokens, args...)
+					return append(tokens, token{typ: rParen, val: ")", pos: start}), j + 1, nil
+				case A[j] == '$' && j+1 < len(A) && A[j+1] == '{':
+					end := j + 2
+					for end < len(A) && A[end] != '}' {
+						end++
+					}
+					if end == len(A) {
+						return nil, i, p.errWithPos(errors.New("unterminated template placeholder"), offset+j)
+					}
+					tokens, err := lexRunes(A[j+2:end], offset+j+2)
+					if err != nil {
+						return nil, i, err
+					}
+					if len(tokens) == 0 {
+						return nil, i, p.errWithPos(errors.New("empty template placeholder"), offset+j)
+					}
+					args = append(args, tokens...)
+					cnt++
+					format.WriteString("%v")
+					j = end
+				case A[j] == '%':
+					text.WriteRune('%')
+					format.WriteString("%%")
+				default:
+					text.WriteRune(A[j])
+					format.WriteRune(A[j])
+				}
+			}
+			return nil, i, p.errWithPos(errors.New("unterminated template string"), offset+i)
 		}
+	)
 
-		found := false
-		for _, lexer := range lexers {
-			t, j := lexer(A, i)
-			if i != j {
-				found = true
-				t.pos = i
-				tokens = append(tokens, t)
+	lexRunes = func(A []rune, offset int) ([]token, error) {
+		var tokens []token
+		for i := 0; i < len(A); {
+			r := A[i]
+			if unicode.IsSpace(r) {
+				i++
+				continue
+			}
+
+			if r == '`' {
+				ts, j, err := lexTemplate(A, i, offset)
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, ts...)
 				i = j
-				break
+				continue
+			}
+
+			found := false
+			for _, lexer := range lexers {
+				t, j := lexer(A, i)
+				if i != j {
+					found = true
+					t.pos = offset + i
+					tokens = append(tokens, t)
+					i = j
+					break
+				}
+			}
+			if !found {
+				return nil, p.errWithPos(errors.New("can not parse token"), offset+i)
 			}
 		}
-		if !found {
-			return p.errWithPos(errors.New("can not parse token"), i)
-		}
+		return tokens, nil
+	}
+
+	tokens, err := lexRunes([]rune(p.source), 0)
+	if err != nil {
+		return err
 	}
 	p.tokens = tokens
 	return nil
@@ -220,6 +337,15 @@ func (p *parser) parseAstTree() (*astNode, error) {
 }
 
 func (p *parser) checkParentheses() error {
+	if len(p.tokens) == 0 {
+		return errors.New("invalid expression error, expression is empty")
+	}
+
+	// an expression with only one constant or selector, e.g. true
+	if len(p.tokens) == 1 && p.tokens[0].typ != lParen && p.tokens[0].typ != rParen {
+		return nil
+	}
+
 	last := len(p.tokens) - 1
 	if p.tokens[0].typ != lParen || p.tokens[last].typ != rParen {
 		return p.parenUnmatchedErr(0)
@@ -237,12 +363,28 @@ func (p *parser) checkParentheses() error {
 			return p.parenUnmatchedErr(t.pos)
 		}
 	}
+	if parenCnt != 0 {
+		return p.parenUnmatchedErr(0)
+	}
 
 	return nil
 }
 
 func (p *parser) parse() (*astNode, *CompileConfig, error) {
-	err := p.lex()
+	mode := PrefixSyntax
+	if p.conf != nil {
+		mode = p.conf.SyntaxMode
+	}
+
+	var err error
+	switch mode {
+	case InfixSyntax, CELSyntax:
+		err = p.lexInfix()
+	case SQLSyntax:
+		err = p.lexSQL()
+	default:
+		err = p.lex()
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -250,7 +392,16 @@ func (p *parser) parse() (*astNode, *CompileConfig, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	ast, err := p.parseAstTree()
+
+	var ast *astNode
+	switch mode {
+	case InfixSyntax, CELSyntax:
+		ast, err = p.parseInfixAstTree()
+	case SQLSyntax:
+		ast, err = p.parseSQLAstTree()
+	default:
+		ast, err = p.parseAstTree()
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -312,7 +463,86 @@ func (p *parser) errWithToken(err error, t token) error {
 }
 
 func (p *parser) errWithPos(err error, idx int) error {
-	return fmt.Errorf("%w occurs at %s", err, p.pos(idx))
+	return newCompileError(err, p.source, idx)
+}
+
+// CompileError is the error of compiling an expression at the position of the offending token
+type CompileError struct {
+	Err error
+	// Offset is the index of the offending rune in the expression
+	Offset int
+	// Line and Column of the offending rune, both start from 1
+	Line   int
+	Column int
+	// Snippet is the offending line with a caret pointing to the rune,
+	// the line is trimmed if it's too long
+	Snippet string
+
+	pos string
+}
+
+func newCompileError(err error, source string, offset int) *CompileError {
+	A := []rune(source)
+	if offset >= len(A) {
+		offset = len(A) - 1
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	e := &CompileError{
+		Err:    err,
+		Offset: offset,
+		Line:   1,
+	}
+	if len(A) == 0 {
+		e.Column = 1
+		e.Snippet = "^"
+		return e
+	}
+
+	lineStart := 0
+	for i := 0; i < offset; i++ {
+		if A[i] == '\n' {
+			e.Line++
+			lineStart = i + 1
+		}
+	}
+	lineEnd := lineStart
+	for lineEnd < len(A) && A[lineEnd] != '\n' {
+		lineEnd++
+	}
+	e.Column = offset - lineStart + 1
+
+	const width = 40
+	left, right := lineStart, lineEnd
+	var prefix, suffix string
+	if offset-left > width {
+		left, prefix = offset-width, "..."
+	}
+	if right-offset > width {
+		right, suffix = offset+width, "..."
+	}
+	// the tabs are kept to align the caret
+	caret := []rune(strings.Repeat(" ", len(prefix)))
+	for _, r := range A[left:offset] {
+		if r != '\t' {
+			r = ' '
+		}
+		caret = append(caret, r)
+	}
+	e.Snippet = prefix + string(A[left:right]) + suffix + "\n" + string(caret) + "^"
+
+	e.pos = (&parser{source: source}).pos(offset)
+	return e
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("%s occurs at %s", e.Err, e.pos)
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
 }
 
 func (p *parser) printPosMsg(msg string, idx int) {
@@ -353,6 +583,12 @@ func (p *parser) valNode(v Value) *astNode {
 	}
 }
 
+func (p *parser) valNodeAt(v Value, t token) *astNode {
+	n := p.valNode(v)
+	n.pos = t.pos
+	return n
+}
+
 func (p *parser) parseList() (*astNode, error) {
 	i := p.idx
 	T := p.tokens
@@ -363,6 +599,7 @@ func (p *parser) parseList() (*astNode, error) {
 	if typ != rParen && typ != integer && typ != str {
 		return nil, nil
 	}
+	start := T[i]
 	strs := []string{}
 	for j := i + 1; j < len(T); j++ {
 		if T[j].typ == rParen {
@@ -394,6 +631,7 @@ func (p *parser) parseList() (*astNode, error) {
 	p.idx = i + 1
 	return &astNode{
 		node: n,
+		pos:  start.pos,
 	}, nil
 }
 
@@ -402,20 +640,85 @@ func (p *parser) parseInt() (*astNode, error) {
 	if t.typ != integer {
 		return nil, nil
 	}
-	v, err := strconv.ParseInt(t.val, 10, 64)
+	v, err := p.intLiteral(t.val, t)
 	if err != nil {
 		return nil, err
 	}
 	p.walk()
-	return p.valNode(v), nil
+	return p.valNodeAt(v, t), nil
+}
+
+// intLiteral parses the integer literal, the ones beyond int64 are *big.Int with the BigIntegers option
+func (p *parser) intLiteral(s string, t token) (Value, error) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err == nil {
+		return v, nil
+	}
+	if b, ok := new(big.Int).SetString(s, 10); ok && p.conf.CompileOptions[BigIntegers] {
+		return b, nil
+	}
+	return nil, p.errWithToken(err, t)
+}
+func (p *parser) parseFloat() (*astNode, error) {
+	t := p.peek()
+	if t.typ != float {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(t.val, 64)
+	if err != nil {
+		return nil, p.errWithToken(err, t)
+	}
+	p.walk()
+	return p.valNodeAt(v, t), nil
 }
+
+// isFloatLiteral reports whether s is a decimal float literal, e.g. 1.5, -0.25, 1e-3
+func isFloatLiteral(s string) bool {
+	digits := strings.TrimLeft(s, "+-")
+	if len(digits) == 0 || len(s)-len(digits) > 1 || !unicode.IsDigit(rune(digits[0])) {
+		return false
+	}
+	if !strings.ContainsAny(digits, ".eE") {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// parseDecimal parses the decimal literal, which requires the DecimalArithmetic option
+func (p *parser) parseDecimal() (*astNode, error) {
+	t := p.peek()
+	if t.typ != decimal {
+		return nil, nil
+	}
+	if !p.conf.CompileOptions[DecimalArithmetic] {
+		return nil, p.errWithToken(fmt.Errorf("decimal literal %sd requires the %s option", t.val, DecimalArithmetic), t)
+	}
+	v, err := ParseDecimal(t.val)
+	if err != nil {
+		return nil, p.errWithToken(err, t)
+	}
+	p.walk()
+	return p.valNodeAt(v, t), nil
+}
+
+// isDecimalLiteral reports whether s is a decimal literal without the suffix, e.g. 19.99, -5,
+// the out of range ones are reported by parseDecimal
+func isDecimalLiteral(s string) bool {
+	digits := strings.TrimLeft(s, "+-")
+	if len(digits) == 0 || len(s)-len(digits) > 1 || !unicode.IsDigit(rune(digits[0])) {
+		return false
+	}
+	return strings.Count(digits, ".") <= 1 && strings.Trim(digits, "0123456789.") == ""
+}
+
 func (p *parser) parseStr() (*astNode, error) {
 	t := p.peek()
 	if t.typ != str {
 		return nil, nil
 	}
 	p.walk()
-	return p.valNode(t.val), nil
+	return p.valNodeAt(t.val, t), nil
 }
 func (p *parser) parseConst() (*astNode, error) {
 	t := p.peek()
@@ -425,12 +728,12 @@ func (p *parser) parseConst() (*astNode, error) {
 
 	if val, ok := builtinConstants[t.val]; ok {
 		p.walk()
-		return p.valNode(val), nil
+		return p.valNodeAt(val, t), nil
 	}
 
 	if val, ok := p.conf.ConstantMap[t.val]; ok {
 		p.walk()
-		return p.valNode(val), nil
+		return p.valNodeAt(val, t), nil
 	}
 	return nil, nil
 }
@@ -440,6 +743,17 @@ func (p *parser) parseSelector() (*astNode, error) {
 	if t.typ != ident {
 		return nil, nil
 	}
+	if isBound(p.bindings, t.val) {
+		p.walk()
+		return &astNode{
+			node: &node{
+				flag:   selector,
+				value:  t.val,
+				selKey: UndefinedSelKey,
+			},
+			pos: t.pos,
+		}, nil
+	}
 	if key, ok := p.conf.SelectorMap[t.val]; ok {
 		p.walk()
 		return &astNode{
@@ -448,14 +762,75 @@ func (p *parser) parseSelector() (*astNode, error) {
 				value:  t.val,
 				selKey: key,
 			},
+			pos: t.pos,
 		}, nil
 	}
 	return nil, nil
 }
 
+// parseFieldSelector parses the dot-path of the nested fields of a selector,
+// e.g. user.address.city => (field user "address" "city"),
+// and the optional one, e.g. user?.address?.city => (getOrNil user "address" "city").
+// The dot-path registered as a selector is parsed to the selector itself.
+func (p *parser) parseFieldSelector() (*astNode, error) {
+	t := p.peek()
+	if t.typ != ident {
+		return nil, nil
+	}
+	name, fields, optional, ok := splitFieldPath(t.val)
+	if !ok {
+		return nil, nil
+	}
+
+	key, exist := p.conf.SelectorMap[name]
+	if isBound(p.bindings, name) {
+		key, exist = UndefinedSelKey, true
+	}
+	if !exist {
+		if !p.conf.CompileOptions[AllowUnknownSelectors] && !p.deferResolving {
+			return nil, p.unknownTokenError(t)
+		}
+		key = UndefinedSelKey
+	}
+	p.walk()
+
+	children := []*astNode{{
+		node: &node{
+			flag:   selector,
+			value:  name,
+			selKey: key,
+		},
+		pos: t.pos,
+	}}
+	for _, field := range fields {
+		children = append(children, p.valNodeAt(field, t))
+	}
+	op := "field"
+	if optional {
+		op = "getOrNil"
+	}
+	return p.buildNode(token{typ: ident, val: op, pos: t.pos}, children)
+}
+
+func (p *parser) parseUnknownSelector() (*astNode, error) {
+	t := p.peek()
+	if !p.conf.CompileOptions[AllowUnknownSelectors] && !p.deferResolving {
+		return nil, p.unknownTokenError(t)
+	}
+	p.walk()
+	return &astNode{
+		node: &node{
+			flag:   selector,
+			value:  t.val,
+			selKey: UndefinedSelKey,
+		},
+		pos: t.pos,
+	}, nil
+}
+
 func (p *parser) parseExpression() (*astNode, error) {
 	fns := []func() (*astNode, error){
-		p.parseInt, p.parseStr, p.parseConst, p.parseSelector, p.parseList}
+		p.parseInt, p.parseFloat, p.parseDecimal, p.parseStr, p.parseConst, p.parseSelector, p.parseFieldSelector, p.parseList}
 	for _, fn := range fns {
 		n, err := fn()
 		if n != nil || err != nil {
@@ -464,17 +839,7 @@ func (p *parser) parseExpression() (*astNode, error) {
 	}
 
 	if t := p.peek(); t.typ == ident {
-		if p.conf.CompileOptions[AllowUnknownSelectors] {
-			p.walk()
-			return &astNode{
-				node: &node{
-					flag:   selector,
-					value:  t.val,
-					selKey: UndefinedSelKey,
-				},
-			}, nil
-		}
-		return nil, p.unknownTokenError(p.peek())
+		return p.parseUnknownSelector()
 	}
 
 	err := p.eat(lParen)
@@ -486,6 +851,12 @@ func (p *parser) parseExpression() (*astNode, error) {
 	if car.typ != ident {
 		return nil, p.tokenTypeError(ident, car)
 	}
+	if isHigherOrder(p.conf, car.val) {
+		if car.val == "let" {
+			return p.parseLet(car)
+		}
+		return p.parseHigherOrder(car)
+	}
 
 	var children []*astNode
 	for p.peek().typ != rParen {
@@ -508,8 +879,88 @@ func (p *parser) parseExpression() (*astNode, error) {
 	return n, err
 }
 
+// parseHigherOrder parses the higher-order operator, e.g. (any items item (= item.category "books")),
+// the param is bound to the elements of the list within the body
+func (p *parser) parseHigherOrder(car token) (*astNode, error) {
+	var children []*astNode
+	for p.peek().typ != rParen && len(children) < 2 {
+		if len(children) == 1 {
+			param, err := p.parseParam(ident, str)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, param)
+			continue
+		}
+
+		child, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	return p.parseBody(car, children)
+}
+
+// parseLet parses the let binding, e.g. (let (total (+ price tax)) (between total 100 500)),
+// which is compiled to (let (+ price tax) "total" body), the param is bound to the value within the body
+func (p *parser) parseLet(car token) (*astNode, error) {
+	if err := p.eat(lParen); err != nil {
+		return nil, err
+	}
+	param, err := p.parseParam(ident)
+	if err != nil {
+		return nil, err
+	}
+	val, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.eat(rParen); err != nil {
+		return nil, err
+	}
+	return p.parseBody(car, []*astNode{val, param})
+}
+
+// parseParam parses the param of the lambda to a string constant
+func (p *parser) parseParam(types ...tokenType) (*astNode, error) {
+	t := p.next()
+	for _, typ := range types {
+		if t.typ == typ && t.val != "" && !strings.Contains(t.val, fieldPathSeparator) {
+			return p.valNodeAt(t.val, t), nil
+		}
+	}
+	return nil, p.tokenTypeError(ident, t)
+}
+
+// parseBody parses the body of the lambda with the param bound,
+// the children are the list or value and the param
+func (p *parser) parseBody(car token, children []*astNode) (*astNode, error) {
+	if len(children) == 2 && p.peek().typ != rParen {
+		p.bindings = append(p.bindings, children[1].node.value.(string))
+		body, err := p.parseExpression()
+		p.bindings = p.bindings[:len(p.bindings)-1]
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, body)
+	}
+
+	cnt := len(children)
+	for ; p.peek().typ != rParen; cnt++ {
+		if _, err := p.parseExpression(); err != nil {
+			return nil, err
+		}
+	}
+	if cnt != 3 {
+		return nil, p.paramsCountErr(3, cnt, car)
+	}
+	p.walk()
+	return p.buildNode(car, children)
+}
+
 func (p *parser) isKeyword(car token) bool {
-	keywords := []string{"if", "let", "map", "filter", "any", "all", "collect", "reduce"}
+	keywords := []string{"if", "switch", "collect", "reduce"}
 	for _, keyword := range keywords {
 		if car.val == keyword {
 			return true
@@ -519,44 +970,61 @@ func (p *parser) isKeyword(car token) bool {
 }
 
 func (p *parser) buildKeywordNode(car token, children []*astNode) (*astNode, error) {
-	if car.val != "if" {
-		return nil, p.errWithToken(fmt.Errorf("[%s] is not currently supported", car.val), car)
-	}
-
-	n := &astNode{
-		node: &node{value: car.val},
-	}
-
-	if len(children) != 3 {
-		return nil, p.paramsCountErr(3, len(children), car)
+	switch car.val {
+	case "if":
+		if len(children) != 3 {
+			return nil, p.paramsCountErr(3, len(children), car)
+		}
+		return p.buildCondNode(car, children[0], children[1], children[2]), nil
+	case "switch":
+		return p.buildSwitchNode(car, children)
 	}
+	return nil, p.errWithToken(fmt.Errorf("[%s] is not currently supported", car.val), car)
+}
 
-	// append an end node
-	children = append(children, &astNode{
+func (p *parser) buildCondNode(car token, condition, then, otherwise *astNode) *astNode {
+	return &astNode{
 		node: &node{
-			flag:  end,
-			value: "end",
+			flag:  cond,
+			value: "if",
 		},
-	})
+		// append an end node
+		children: []*astNode{condition, then, otherwise, {
+			node: &node{
+				flag:  end,
+				value: "end",
+			},
+		}},
+		pos: car.pos,
+	}
+}
 
-	n.node.flag = cond
-	n.children = children
+// buildSwitchNode builds the chained cond nodes of the switch,
+// e.g. (switch c1 v1 c2 v2 v3) => (if c1 v1 (if c2 v2 v3))
+func (p *parser) buildSwitchNode(car token, children []*astNode) (*astNode, error) {
+	if len(children) < 3 || len(children)%2 == 0 {
+		err := fmt.Errorf("%s parameters count error (want: condition value pairs and a default value, got: %d)",
+			car.val, len(children))
+		return nil, p.errWithToken(err, car)
+	}
 
-	return n, nil
+	res := children[len(children)-1]
+	for i := len(children) - 3; i >= 0; i -= 2 {
+		res = p.buildCondNode(car, children[i], children[i+1], res)
+	}
+	return res, nil
 }
 
 func (p *parser) buildNode(car token, children []*astNode) (*astNode, error) {
 	treeNode := &astNode{
 		node:     &node{value: car.val},
 		children: children,
+		pos:      car.pos,
 	}
 
 	// parse op node
-	op, exist := builtinOperators[car.val]
-	if !exist {
-		op, exist = p.conf.OperatorMap[car.val]
-	}
-	if !exist {
+	op, exist := p.conf.getOperator(car.val)
+	if !exist && !p.deferResolving {
 		return nil, p.unknownTokenError(car)
 	}
 	flag := operator
@@ -565,6 +1033,21 @@ func (p *parser) buildNode(car token, children []*astNode) (*astNode, error) {
 	return treeNode, nil
 }
 
+// checkArity checks the params count of the operators with the declared arities
+func (p *parser) checkArity(root *astNode) error {
+	if typ := root.node.getNodeType(); typ == operator || typ == fastOperator {
+		if err := p.conf.checkArity(root.node.value.(string), len(root.children)); err != nil {
+			return p.errWithPos(err, root.pos)
+		}
+	}
+	for _, child := range root.children {
+		if err := p.checkArity(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *parser) parseConfig() error {
 	const prefix = ";;;;" // prefix of compile config
 	const separator = "," // separator of compile config
@@ -601,7 +1084,8 @@ func (p *parser) parseConfig() error {
 				for _, opt := range AllOptimizations {
 					confCopy.CompileOptions[opt] = enabled
 				}
-			case Reordering, FastEvaluation, ConstantFolding:
+			case Reordering, FastEvaluation, ConstantFolding, TypeCheck, StrictNumeric,
+				DecimalArithmetic, BigIntegers, ByteLength, CaseInsensitive, NoShortCircuit:
 				confCopy.CompileOptions[option] = enabled
 			default:
 				return p.errWithToken(fmt.Errorf("unsupported compile config %s", s), t)
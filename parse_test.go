@@ -1,6 +1,7 @@
 package eval
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -195,11 +196,32 @@ func TestLex(t *testing.T) {
 		},
 
 		{
-			expr:   `(< age 18.0)`,
+			expr: `(< age 18.0)`,
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "<"},
+				{typ: ident, val: "age"},
+				{typ: float, val: "18.0"},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr: `(+ -1.5 1e3 2.5E-2)`,
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "+"},
+				{typ: float, val: "-1.5"},
+				{typ: float, val: "1e3"},
+				{typ: float, val: "2.5E-2"},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr:   `(+ 1 1.0.0)`,
 			errMsg: "can not parse token",
 		},
 		{
-			expr:   `(+ 1 1.0)`,
+			expr:   `(+ 1 .5)`,
 			errMsg: "can not parse token",
 		},
 		{
@@ -844,6 +866,11 @@ func TestParseAstTree(t *testing.T) {
 			errMsg: "if parameters count error",
 		},
 
+		{
+			expr:   `(switch (= 1 1) 1)`,
+			errMsg: "switch parameters count error",
+		},
+
 		{
 			expr:   `(< 12 18`,
 			errMsg: "parentheses unmatched error",
@@ -858,6 +885,25 @@ func TestParseAstTree(t *testing.T) {
 			expr:   `(+ 1 1) (+ 1 1)`,
 			errMsg: "parentheses unmatched error",
 		},
+
+		// expressions with only one constant or selector
+		{
+			expr: `true`,
+			ast:  verifyNode{tpy: constant, data: true},
+		},
+		{
+			cc:   NewCompileConfig(EnableStringSelectors),
+			expr: ` age ;; comment`,
+			ast:  verifyNode{tpy: selector, data: "age"},
+		},
+		{
+			expr:   `)`,
+			errMsg: "parentheses unmatched error",
+		},
+		{
+			expr:   ` ;; comment`,
+			errMsg: "expression is empty",
+		},
 	}
 
 	for _, c := range testCases {
@@ -871,3 +917,52 @@ func TestParseAstTree(t *testing.T) {
 		assertAstTreeIdentical(t, ast, c.ast, c)
 	}
 }
+
+func TestCompileError(t *testing.T) {
+	testCases := []struct {
+		expr    string
+		errMsg  string
+		line    int
+		column  int
+		snippet string
+	}{
+		{
+			expr:    `(and (> age 18) (= country "US")`,
+			errMsg:  "parentheses unmatched error",
+			line:    1,
+			column:  1,
+			snippet: "(and (> age 18) (= country \"US\")\n^",
+		},
+		{
+			expr: `
+(and
+	(> age 18)
+	(= country "US" "CN" @))`,
+			errMsg:  "can not parse token",
+			line:    4,
+			column:  23,
+			snippet: "\t(= country \"US\" \"CN\" @))\n\t                     ^",
+		},
+		{
+			expr:    `(and (> age 18) (if vip 1) (= country "US") (= gender "M") (in city ("a" "b")))`,
+			errMsg:  "if parameters count error",
+			line:    1,
+			column:  18,
+			snippet: "(and (> age 18) (if vip 1) (= country \"US\") (= gender \"M\"...\n                 ^",
+		},
+	}
+
+	for _, c := range testCases {
+		_, err := Compile(NewCompileConfig(EnableStringSelectors), c.expr)
+		assertErrStrContains(t, err, c.errMsg, c)
+
+		var ce *CompileError
+		if !errors.As(err, &ce) {
+			t.Fatalf("want CompileError, got: %T", err)
+		}
+		if ce.Line != c.line || ce.Column != c.column || ce.Snippet != c.snippet {
+			t.Errorf("expr: %s, want: %d:%d\n%s\ngot: %d:%d\n%s", c.expr,
+				c.line, c.column, c.snippet, ce.Line, ce.Column, ce.Snippet)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package eval
+
+// PartialEval substitutes the selectors with the known values,
+// folds the subexpressions and the branches determined by them,
+// and returns the residual expression, the original expression is not changed.
+// The selectors which are not registered (UndefinedSelKey) are never substituted.
+func (e *Expr) PartialEval(known map[SelectorKey]Value) (*Expr, error) {
+	root := e.toAstTree(e.getNode(0), known)
+
+	// the residual expression is compiled by the config of the original one,
+	// except that the order of the nodes has been decided when the expression was compiled
+	cc := CopyCompileConfig(e.conf)
+	Optimizations(false, Reordering)(cc)
+	OnMissingSelector(e.missingSelector)(cc)
+	cc.SelectorDefaults = e.selectorDefaults
+	cc.CompileOptions[RecoverPanics] = e.recoverPanics
+	cc.CompileOptions[ZeroAlloc] = e.zeroAlloc
+	cc.EvalHook = e.hook
+	expr, err := compileAstTree(cc, root)
+	if err != nil {
+		return nil, err
+	}
+	expr.maxSteps = e.maxSteps
+	expr.parallelSelectors = e.parallelSelectors
+	expr.memoizeSelectors = e.memoizeSelectors && hasRepeatedSelectors(expr)
+	if expr.returnType == TypeAny {
+		// the declared types are not kept in the expression
+		expr.returnType = e.returnType
+	}
+	expr.setBackend(e.backend)
+	return expr, nil
+}
+
+// toAstTree rebuilds the syntax tree from the compiled nodes,
+// the nodes are copied without the short circuit flags, and the operators are resolved again,
+// so that they aren't wrapped twice by the recovery and the debug info.
+func (e *Expr) toAstTree(n *node, known map[SelectorKey]Value) *astNode {
+	if n.getNodeType() == selector && n.selKey != UndefinedSelKey {
+		if val, exist := known[n.selKey]; exist {
+			return &astNode{
+				node: &node{
+					flag:  constant,
+					value: unifyType(val),
+				},
+			}
+		}
+	}
+
+	c := *n
+	c.flag &^= scIfTrue | scIfFalse
+	if typ := c.getNodeType(); (typ == operator || typ == fastOperator) && e.conf != nil {
+		if op, exist := e.conf.getOperator(c.value.(string)); exist {
+			c.operator = op
+		}
+	}
+	if l, ok := n.value.(*lambda); ok && len(known) != 0 {
+		// the body is kept as it is if it fails to be folded, the error is reported by the evaluation
+		if body, err := l.body.PartialEval(known); err == nil {
+			c.value = &lambda{param: l.param, body: body}
+		}
+	}
+	root := &astNode{node: &c}
+	for i := 0; i < int(n.childCnt); i++ {
+		child := e.getNode(int(n.childIdx) + i)
+		root.children = append(root.children, e.toAstTree(child, known))
+	}
+	return root
+}
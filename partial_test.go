@@ -0,0 +1,150 @@
+package eval
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExpr_PartialEval(t *testing.T) {
+	vals := map[string]interface{}{
+		"tenant":  "a",
+		"age":     20,
+		"country": "US",
+		"level":   3,
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals), Optimizations(false, Reordering))
+	key := func(name string) SelectorKey {
+		return cc.SelectorMap[name]
+	}
+
+	testCases := []struct {
+		expr     string
+		known    map[SelectorKey]Value
+		residual string
+	}{
+		{
+			expr:     `(and (= tenant "a") (> age 18))`,
+			known:    map[SelectorKey]Value{key("tenant"): "a"},
+			residual: `(and true (> age 18))`,
+		},
+		{
+			expr:     `(and (= tenant "a") (> age 18))`,
+			known:    map[SelectorKey]Value{key("tenant"): "b"},
+			residual: `false`,
+		},
+		{
+			expr:     `(or (= tenant "a") (> age 18))`,
+			known:    map[SelectorKey]Value{key("tenant"): "a"},
+			residual: `true`,
+		},
+		{
+			expr:     `(if (= tenant "a") (> age (* level 6)) (= country "US"))`,
+			known:    map[SelectorKey]Value{key("tenant"): "a", key("level"): 3},
+			residual: `(> age 18)`,
+		},
+		{
+			expr:     `(if (= tenant "a") (> age 18) (= country "US"))`,
+			known:    map[SelectorKey]Value{key("tenant"): "b"},
+			residual: `(= country "US")`,
+		},
+		{
+			expr:     `(if (= tenant "a") (> age 18) (= country "US"))`,
+			known:    nil,
+			residual: `(if (= tenant "a") (> age 18) (= country "US"))`,
+		},
+		{
+			// the errors are kept in the residual expression
+			expr:     `(or (= tenant "a") (> country 18))`,
+			known:    map[SelectorKey]Value{key("country"): "US"},
+			residual: `(or (= tenant "a") (> "US" 18))`,
+		},
+	}
+
+	for _, c := range testCases {
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err)
+
+		residual, err := expr.PartialEval(c.known)
+		assertNil(t, err)
+		assertEquals(t, residual.Decompile(), c.residual, c.expr)
+
+		// the original expression is not changed
+		assertEquals(t, expr.Decompile(), c.expr)
+
+		// the results are the same as the original expression with the known values
+		input := make(map[string]interface{})
+		for k, v := range vals {
+			input[k] = v
+		}
+		for name, k := range cc.SelectorMap {
+			if v, exist := c.known[k]; exist {
+				input[name] = v
+			}
+		}
+		want, wantErr := expr.Eval(NewCtxWithMap(cc, input))
+		got, gotErr := residual.Eval(NewCtxWithMap(cc, input))
+		assertEquals(t, got, want, c.expr)
+		assertEquals(t, gotErr == nil, wantErr == nil, c.expr)
+	}
+}
+
+func TestExpr_PartialEvalConfig(t *testing.T) {
+	vals := map[string]interface{}{
+		"tenant":  "a",
+		"country": "US",
+		"level":   "abc",
+	}
+
+	testCases := []struct {
+		name   string
+		opts   []CompileOption
+		expr   string
+		input  map[string]interface{}
+		want   Value
+		output string
+	}{
+		{
+			name:  "case insensitive",
+			opts:  []CompileOption{EnableCaseInsensitive, EnableZeroAlloc},
+			expr:  `(and (= tenant "a") (= country "us"))`,
+			input: map[string]interface{}{"country": "US"},
+			want:  true,
+		},
+		{
+			name:  "conversion policy",
+			opts:  []CompileOption{OnConversionError(ConversionNull)},
+			expr:  `(if (= tenant "a") (toInt level) 0)`,
+			input: map[string]interface{}{"level": "abc"},
+			want:  nil,
+		},
+		{
+			// the operators are wrapped by the debug info once
+			name:   "debug",
+			opts:   []CompileOption{EnableDebug},
+			expr:   `(and (= tenant "a") (= country "US"))`,
+			input:  map[string]interface{}{"country": "US"},
+			want:   true,
+			output: "execute operator, op: =, params: [US US], res: true, err: <nil>\n\n",
+		},
+	}
+
+	for _, c := range testCases {
+		var buf bytes.Buffer
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals), Optimizations(false))...)
+		cc.DebugWriter = &buf
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err, c.name)
+
+		residual, err := expr.PartialEval(map[SelectorKey]Value{cc.SelectorMap["tenant"]: "a"})
+		assertNil(t, err, c.name)
+
+		buf.Reset()
+		got, err := residual.Eval(NewCtxWithMap(cc, c.input))
+		assertNil(t, err, c.name)
+		assertEquals(t, got, c.want, c.name)
+		if c.output != "" {
+			assertEquals(t, strings.Count(buf.String(), c.output), 1, c.name)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package eval
+
+import "fmt"
+
+// SelectorPlan groups the selectors of an expression by when they may be needed in the evaluation
+type SelectorPlan struct {
+	// Required are the names of the selectors read by every evaluation which doesn't fail,
+	// they can be batch-fetched before the evaluation.
+	Required []string
+	// Conditional are the selectors which are read only if their subexpressions are evaluated,
+	// the subexpressions can be skipped by short circuit or untaken branches.
+	// The groups are in the evaluation order, and the selectors in Required are not repeated.
+	Conditional []SelectorGroup
+}
+
+// SelectorGroup is the selectors of a subexpression which may be skipped
+type SelectorGroup struct {
+	// NodeIdx is the index of the root node of the subexpression, same as DebugEvent.NodeIdx
+	NodeIdx int
+	// Expr is the decompiled subexpression
+	Expr      string
+	Selectors []string
+}
+
+// SelectorPlan returns the selectors grouped by when they may be needed,
+// so that the required ones can be fetched in a batch before the evaluation,
+// and the conditional ones can be fetched lazily.
+func (e *Expr) SelectorPlan() SelectorPlan {
+	var plan SelectorPlan
+	required := make(map[string]bool)
+	var groups []int
+
+	var walk func(idx int)
+	walk = func(idx int) {
+		n := e.getNode(idx)
+		switch n.getNodeType() {
+		case selector:
+			name := n.value.(string)
+			if !required[name] {
+				required[name] = true
+				plan.Required = append(plan.Required, name)
+			}
+			return
+		case constant:
+			// the lambda body is called for each element of the list, which may be empty
+			if _, ok := n.value.(*lambda); ok {
+				groups = append(groups, idx)
+			}
+			return
+		case end:
+			return
+		}
+
+		// the first child is always evaluated, the rest can be skipped
+		skippable := n.getNodeType() == cond || isBoolOpNode(n)
+		for i := 0; i < int(n.childCnt); i++ {
+			childIdx := int(n.childIdx) + i
+			if i != 0 && skippable {
+				groups = append(groups, childIdx)
+			} else {
+				walk(childIdx)
+			}
+		}
+	}
+	walk(0)
+
+	for _, idx := range groups {
+		g := SelectorGroup{
+			NodeIdx: idx,
+			Expr:    e.decompileNode(e.getNode(idx)),
+		}
+		seen := make(map[string]bool)
+		e.walkSelectors(idx, func(name string) {
+			if !required[name] && !seen[name] {
+				seen[name] = true
+				g.Selectors = append(g.Selectors, name)
+			}
+		})
+		if len(g.Selectors) != 0 {
+			plan.Conditional = append(plan.Conditional, g)
+		}
+	}
+	return plan
+}
+
+// walkSelectors calls fn with the names of the selectors of the subexpression in the evaluation order
+func (e *Expr) walkSelectors(idx int, fn func(name string)) {
+	n := e.getNode(idx)
+	switch n.getNodeType() {
+	case selector:
+		fn(n.value.(string))
+		return
+	case constant:
+		// the free selectors of the lambda body, the param is bound to the elements
+		if l, ok := n.value.(*lambda); ok {
+			for _, sel := range l.body.freeSelectorNodes() {
+				if sel.value != l.param {
+					fn(sel.value.(string))
+				}
+			}
+		}
+		return
+	}
+	for i := 0; i < int(n.childCnt); i++ {
+		e.walkSelectors(int(n.childIdx)+i, fn)
+	}
+}
+
+// BatchSelector is an optional interface of the Selector, which gets the values of the keys in one call,
+// e.g. by a remote KV store. If the Selector of the Ctx implements it, the required selectors of the expression,
+// see SelectorPlan, are fetched by GetMany before the evaluation, and the others are got by Get lazily.
+// The keys missing in the result are got by Get as well, and the unregistered selectors are never batched.
+type BatchSelector interface {
+	Selector
+	GetMany(keys []SelectorKey) (map[SelectorKey]Value, error)
+}
+
+// setRequiredSelectors sets the required selectors, which can be fetched before the evaluation,
+// the registered ones are fetched by BatchSelector
+func (e *Expr) setRequiredSelectors() {
+	keys := make(map[string]SelectorKey)
+	for _, n := range e.selectorNodes() {
+		keys[n.value.(string)] = n.selKey
+	}
+
+	e.requiredSelectors, e.batchKeys = nil, nil
+	for _, name := range e.SelectorPlan().Required {
+		key, exist := keys[name]
+		if !exist {
+			continue
+		}
+		e.requiredSelectors = append(e.requiredSelectors, memoEntry{selKey: key, strKey: name})
+		if key != UndefinedSelKey {
+			e.batchKeys = append(e.batchKeys, key)
+		}
+	}
+}
+
+// isBatchSelector reports whether the required selectors should be fetched by the BatchSelector of ctx
+func (e *Expr) isBatchSelector(ctx *Ctx) bool {
+	if len(e.batchKeys) == 0 || ctx == nil {
+		return false
+	}
+	_, ok := ctx.Selector.(BatchSelector)
+	return ok
+}
+
+// prefetch fetches the values of the required selectors by the BatchSelector of ctx,
+// and returns the Ctx which gets the fetched values without calling the selector
+func (e *Expr) prefetch(ctx *Ctx) (*Ctx, error) {
+	if !e.isBatchSelector(ctx) {
+		return ctx, nil
+	}
+	vals, err := ctx.Selector.(BatchSelector).GetMany(e.batchKeys)
+	if err != nil {
+		return nil, fmt.Errorf("batch selector error, keys: %v, error: %w", e.batchKeys, err)
+	}
+	return &Ctx{
+		Selector: &prefetchedSelector{Selector: ctx.Selector, vals: vals},
+		Ctx:      ctx.Ctx,
+	}, nil
+}
+
+// prefetchedSelector gets the values fetched by BatchSelector, the others are got from the Selector
+type prefetchedSelector struct {
+	Selector
+	vals map[SelectorKey]Value
+}
+
+func (s *prefetchedSelector) Get(selKey SelectorKey, strKey string) (Value, error) {
+	if val, exist := s.vals[selKey]; exist && selKey != UndefinedSelKey {
+		return val, nil
+	}
+	return s.Selector.Get(selKey, strKey)
+}
+
+func (s *prefetchedSelector) Set(selKey SelectorKey, strKey string, val Value) error {
+	if err := s.Selector.Set(selKey, strKey, val); err != nil {
+		return err
+	}
+	if _, exist := s.vals[selKey]; exist {
+		s.vals[selKey] = val
+	}
+	return nil
+}
+
+func (s *prefetchedSelector) Cached(selKey SelectorKey, strKey string) bool {
+	_, exist := s.vals[selKey]
+	return (exist && selKey != UndefinedSelKey) || s.Selector.Cached(selKey, strKey)
+}
@@ -0,0 +1,238 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestExpr_SelectorPlan(t *testing.T) {
+	testCases := []struct {
+		expr string
+		want SelectorPlan
+	}{
+		{
+			expr: `(+ age bonus)`,
+			want: SelectorPlan{Required: []string{"age", "bonus"}},
+		},
+		{
+			expr: `(and (> age 18) (= country "US") (in city cities))`,
+			want: SelectorPlan{
+				Required: []string{"age"},
+				Conditional: []SelectorGroup{
+					{NodeIdx: 2, Expr: `(= country "US")`, Selectors: []string{"country"}},
+					{NodeIdx: 3, Expr: `(in city cities)`, Selectors: []string{"city", "cities"}},
+				},
+			},
+		},
+		{
+			expr: `(if (> age 18) (* age score) (- age penalty))`,
+			want: SelectorPlan{
+				Required: []string{"age"},
+				Conditional: []SelectorGroup{
+					{NodeIdx: 2, Expr: `(* age score)`, Selectors: []string{"score"}},
+					{NodeIdx: 3, Expr: `(- age penalty)`, Selectors: []string{"penalty"}},
+				},
+			},
+		},
+		{
+			// the selectors required later are not conditional
+			expr: `(= (or vip (> age 18)) (> age 20))`,
+			want: SelectorPlan{
+				Required: []string{"vip", "age"},
+			},
+		},
+		{
+			expr: `(or true false)`,
+		},
+		{
+			// the free selectors of the lambda bodies are conditional, the list may be empty
+			expr: `(any items x (> x limit))`,
+			want: SelectorPlan{
+				Required: []string{"items"},
+				Conditional: []SelectorGroup{
+					{NodeIdx: 3, Expr: `(> x limit)`, Selectors: []string{"limit"}},
+				},
+			},
+		},
+		{
+			expr: `(or vip (all items x (any tags y (= y (+ x bonus)))))`,
+			want: SelectorPlan{
+				Required: []string{"vip"},
+				Conditional: []SelectorGroup{
+					{NodeIdx: 2, Expr: `(all items "x" (any tags "y" (= y (+ x bonus))))`, Selectors: []string{"items", "tags", "bonus"}},
+				},
+			},
+		},
+	}
+
+	cc := NewCompileConfig(EnableStringSelectors, Optimizations(false))
+	for _, c := range testCases {
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err)
+		if got := expr.SelectorPlan(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("expr: %s, want: %+v, got: %+v", c.expr, c.want, got)
+		}
+	}
+}
+
+// kvSelector is a BatchSelector which counts the calls of the remote store
+type kvSelector struct {
+	store   map[SelectorKey]Value
+	gets    []SelectorKey
+	batches [][]SelectorKey
+	err     error
+}
+
+func (s *kvSelector) Get(key SelectorKey, strKey string) (Value, error) {
+	s.gets = append(s.gets, key)
+	val, exist := s.store[key]
+	if !exist {
+		return nil, fmt.Errorf("%w %s", ErrSelectorNotExist, strKey)
+	}
+	return val, nil
+}
+
+func (s *kvSelector) Set(key SelectorKey, _ string, val Value) error {
+	s.store[key] = val
+	return nil
+}
+
+func (s *kvSelector) Cached(SelectorKey, string) bool {
+	return false
+}
+
+func (s *kvSelector) GetMany(keys []SelectorKey) (map[SelectorKey]Value, error) {
+	s.batches = append(s.batches, keys)
+	if s.err != nil {
+		return nil, s.err
+	}
+	res := make(map[SelectorKey]Value, len(keys))
+	for _, key := range keys {
+		if val, exist := s.store[key]; exist {
+			res[key] = val
+		}
+	}
+	return res, nil
+}
+
+func TestBatchSelector(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "US",
+		"score":   90,
+		"vip":     true,
+		"penalty": 5,
+	}
+
+	testCases := []struct {
+		expr    string
+		opts    []CompileOption
+		want    Value
+		batches [][]string
+		gets    []string
+	}{
+		{
+			expr:    `(+ age score)`,
+			want:    int64(110),
+			batches: [][]string{{"age", "score"}},
+		},
+		{
+			expr:    `(and (> age 18) (= country "US"))`,
+			want:    true,
+			batches: [][]string{{"age"}},
+			gets:    []string{"country"},
+		},
+		{
+			expr:    `(if (> age 30) (- score penalty) score)`,
+			want:    int64(90),
+			batches: [][]string{{"age"}},
+			gets:    []string{"score"},
+		},
+		{
+			// the missing keys are got lazily
+			expr:    `(+ age bonus)`,
+			opts:    []CompileOption{RegisterSelKeys(map[string]interface{}{"bonus": 0})},
+			want:    nil,
+			batches: [][]string{{"age", "bonus"}},
+			gets:    []string{"bonus"},
+		},
+		{
+			expr:    `(and vip (> age 18) (> score 60))`,
+			opts:    []CompileOption{EnableZeroAlloc},
+			want:    true,
+			batches: [][]string{{"vip"}},
+			gets:    []string{"age", "score"},
+		},
+		{
+			expr:    `(+ age age)`,
+			opts:    []CompileOption{EnableSelectorMemoization},
+			want:    int64(40),
+			batches: [][]string{{"age"}},
+		},
+		{
+			expr:    `(+ age 1)`,
+			opts:    []CompileOption{func(c *CompileConfig) { c.Backend = ClosureBackend }},
+			want:    int64(21),
+			batches: [][]string{{"age"}},
+		},
+		{
+			expr: `(> 2 1)`,
+			want: true,
+		},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err, c.expr)
+
+		names := make(map[SelectorKey]string)
+		store := make(map[SelectorKey]Value)
+		for name, key := range cc.SelectorMap {
+			names[key] = name
+			if val, exist := vals[name]; exist {
+				store[key] = unifyType(val)
+			}
+		}
+		toNames := func(keys []SelectorKey) []string {
+			var res []string
+			for _, key := range keys {
+				res = append(res, names[key])
+			}
+			return res
+		}
+
+		sel := &kvSelector{store: store}
+		res, err := expr.Eval(&Ctx{Selector: sel})
+		if c.want == nil {
+			assertErrStrContains(t, err, "selectorKey not exist bonus", c.expr)
+		} else {
+			assertNil(t, err, c.expr)
+			assertEquals(t, res, c.want, c.expr)
+		}
+
+		var batches [][]string
+		for _, b := range sel.batches {
+			batches = append(batches, toNames(b))
+		}
+		assertEquals(t, batches, c.batches, c.expr)
+		assertEquals(t, toNames(sel.gets), c.gets, c.expr)
+	}
+
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+	expr, err := Compile(cc, `(> age 18)`)
+	assertNil(t, err)
+	_, err = expr.Eval(&Ctx{Selector: &kvSelector{err: errors.New("connection refused")}})
+	assertErrStrContains(t, err, fmt.Sprintf("batch selector error, keys: [%d], error: connection refused", cc.SelectorMap["age"]))
+
+	// the unregistered selectors are never batched
+	expr, err = Compile(NewCompileConfig(EnableStringSelectors), `(> age 18)`)
+	assertNil(t, err)
+	sel := &kvSelector{store: map[SelectorKey]Value{UndefinedSelKey: int64(20)}}
+	res, err := expr.Eval(&Ctx{Selector: sel})
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	assertEquals(t, len(sel.batches), 0)
+}
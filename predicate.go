@@ -0,0 +1,250 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The ZeroAlloc option compiles the boolean predicates into the closures evaluated without
+// any allocation, e.g. (and (> age 18) (!= country "US") vip). Only the logic operators,
+// the comparisons and the equalities are allowed, and their operands should be constants,
+// selectors or the other allowed operators, otherwise the expression fails to compile.
+//
+// The int64, string and bool operands are compared by the typed fast paths,
+// the others are passed to the builtin operators, which may allocate the params.
+// The expressions in the debug mode, with the EvalHook, MaxSteps or MemoizeSelectors
+// can't be compiled with ZeroAlloc, since they are evaluated by the other evaluators.
+
+// predicateFunc evaluates a subexpression compiled by ZeroAlloc
+type predicateFunc func(ctx *Ctx) (Value, error)
+
+var predicateModes = map[string]mode{
+	"and": and, "&": and,
+	"or": or, "|": or,
+	"not": not, "!": not,
+
+	"eq": equals, "=": equals,
+	"ne": notEquals, "!=": notEquals,
+	"gt": greater, ">": greater,
+	"lt": less, "<": less,
+	"ge": greaterEquals, ">=": greaterEquals,
+	"le": lessEquals, "<=": lessEquals,
+}
+
+// setPredicate compiles the expression with ZeroAlloc
+func (e *Expr) setPredicate() error {
+	isDebug := len(e.nodes) != 0 && e.nodes[0].getNodeType() == debug
+	switch {
+	case isDebug:
+		return fmt.Errorf("zero alloc is not supported in the debug mode")
+	case e.hook != nil:
+		return fmt.Errorf("zero alloc is not supported with the eval hook")
+	case e.maxSteps > 0:
+		return fmt.Errorf("zero alloc is not supported with the step limit")
+	case e.memoizeSelectors:
+		return fmt.Errorf("zero alloc is not supported with the selector memoization")
+	}
+
+	p, err := e.buildPredicate(e.nodes[0])
+	if err != nil {
+		return err
+	}
+	e.zeroAlloc, e.predicate = true, p
+	return nil
+}
+
+// evalPredicate evaluates the expression compiled with ZeroAlloc,
+// the cancellation of Ctx.Ctx is only checked before the evaluation.
+func (e *Expr) evalPredicate(ctx *Ctx) (Value, error) {
+	if ctx != nil && ctx.Ctx != nil {
+		if err := ctx.Ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return checkMissingResult(e.predicate(ctx))
+}
+
+func (e *Expr) buildPredicate(n *node) (predicateFunc, error) {
+	switch n.getNodeType() {
+	case constant:
+		v := n.value
+		return func(*Ctx) (Value, error) {
+			return v, nil
+		}, nil
+	case selector:
+		return func(ctx *Ctx) (Value, error) {
+			return e.getSelectorValue(ctx, n)
+		}, nil
+	case cond:
+		return nil, fmt.Errorf("zero alloc is not supported by if")
+	}
+
+	m, exist := predicateModes[n.value.(string)]
+	if !exist {
+		return nil, fmt.Errorf("zero alloc is not supported by operator: %v", n.value)
+	}
+
+	children := make([]predicateFunc, 0, n.childCnt)
+	for i := int16(0); i < int16(n.childCnt); i++ {
+		child := e.nodes[n.childIdx+i]
+		if child.getNodeType() == end {
+			continue
+		}
+		p, err := e.buildPredicate(child)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, p)
+	}
+
+	switch m {
+	case and, or:
+		if !isBoolOpNode(n) {
+			return nil, fmt.Errorf("zero alloc is not supported without the short circuit, operator: %v", n.value)
+		}
+		return e.boolOpPredicate(n, children, m == and), nil
+	case not:
+		if len(children) != 1 {
+			break
+		}
+		return e.notPredicate(n, children[0]), nil
+	default:
+		if len(children) != 2 {
+			break
+		}
+		return e.comparisonPredicate(n, m, children[0], children[1]), nil
+	}
+	return nil, fmt.Errorf("zero alloc is not supported by operator: %v, params count: %d", n.value, len(children))
+}
+
+// executePredicate executes the operator of the node with the allocated params
+func executePredicate(ctx *Ctx, n *node, params []Value) (Value, error) {
+	res, err := n.operator(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("operator execution error, operator: %v, error: %w", n.value, err)
+	}
+	return res, nil
+}
+
+// boolOpPredicate follows the same rules as boolOpClosure,
+// the params are allocated only if an operand is not a bool value
+func (e *Expr) boolOpPredicate(n *node, children []predicateFunc, isAnd bool) predicateFunc {
+	last := len(children) - 1
+	return func(ctx *Ctx) (Value, error) {
+		for i, child := range children {
+			res, err := child(ctx)
+			if err != nil {
+				return nil, err
+			}
+			b, ok := res.(bool)
+			if !ok {
+				return e.executeBoolOp(ctx, n, children, isAnd, i, res)
+			}
+			if i == last || b != isAnd {
+				return b, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// executeBoolOp evaluates the rest operands after the i-th one which isn't a bool value
+func (e *Expr) executeBoolOp(ctx *Ctx, n *node, children []predicateFunc, isAnd bool, i int, res Value) (Value, error) {
+	last := len(children) - 1
+	params := make([]Value, len(children))
+	for j := 0; j < i; j++ {
+		params[j] = isAnd
+	}
+	params[i] = res
+
+	for j := i + 1; j < len(children); j++ {
+		res, err := children[j](ctx)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := res.(bool); ok && (j == last || b != isAnd) {
+			return b, nil
+		}
+		params[j] = res
+	}
+	return executePredicate(ctx, n, params)
+}
+
+func (e *Expr) notPredicate(n *node, child predicateFunc) predicateFunc {
+	return func(ctx *Ctx) (Value, error) {
+		res, err := child(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := res.(bool); ok {
+			return !b, nil
+		}
+		return executePredicate(ctx, n, []Value{res})
+	}
+}
+
+func (e *Expr) comparisonPredicate(n *node, m mode, left, right predicateFunc) predicateFunc {
+	return func(ctx *Ctx) (Value, error) {
+		x, err := left(ctx)
+		if err != nil {
+			return nil, err
+		}
+		y, err := right(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if res, ok := compareTyped(m, x, y, e.ignoreCase); ok {
+			return res, nil
+		}
+		return executePredicate(ctx, n, []Value{x, y})
+	}
+}
+
+// compareTyped compares the int64, string and bool values of the same type,
+// ok is false if they should be compared by the operator
+func compareTyped(m mode, x, y Value, ignoreCase bool) (res bool, ok bool) {
+	switch a := x.(type) {
+	case int64:
+		b, ok := y.(int64)
+		if !ok {
+			return false, false
+		}
+		switch m {
+		case equals:
+			return a == b, true
+		case notEquals:
+			return a != b, true
+		case greater:
+			return a > b, true
+		case less:
+			return a < b, true
+		case greaterEquals:
+			return a >= b, true
+		case lessEquals:
+			return a <= b, true
+		}
+	case string:
+		b, ok := y.(string)
+		if !ok {
+			return false, false
+		}
+		switch m {
+		case equals:
+			return a == b || (ignoreCase && strings.EqualFold(a, b)), true
+		case notEquals:
+			return a != b && !(ignoreCase && strings.EqualFold(a, b)), true
+		}
+	case bool:
+		b, ok := y.(bool)
+		if !ok {
+			return false, false
+		}
+		switch m {
+		case equals:
+			return a == b, true
+		case notEquals:
+			return a != b, true
+		}
+	}
+	return false, false
+}
@@ -0,0 +1,124 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestZeroAlloc(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "US",
+		"vip":     true,
+		"score":   9.5,
+		"tier":    "gold",
+	}
+
+	testCases := []struct {
+		expr   string
+		allocs float64
+	}{
+		{expr: `(and (> age 18) (= country "US"))`},
+		{expr: `(or (< age 18) (!= country "US") (not vip))`},
+		{expr: `(and (>= age 20) (<= age 60) (= vip true) (eq tier "gold"))`},
+		{expr: `(not (or (= country "CA") (= country "UK")))`},
+		{expr: `(= age 20.0)`, allocs: 1},
+		{expr: `(> score 9)`, allocs: 1},
+		{expr: `(and (> age 18) country)`},
+		{expr: `(> country 1)`},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			cc := NewCompileConfig(RegisterSelKeys(vals), Optimizations(false))
+			want, wantErr := Eval(c.expr, vals, cc)
+
+			cc.CompileOptions[ZeroAlloc] = true
+			expr, err := Compile(cc, c.expr)
+			assertNil(t, err)
+			ctx := NewCtxWithMap(cc, vals)
+
+			res, err := expr.Eval(ctx)
+			assertEquals(t, res, want)
+			assertEquals(t, err == nil, wantErr == nil, err, wantErr)
+			if err != nil {
+				// the errors are allocated
+				return
+			}
+
+			allocs := testing.AllocsPerRun(100, func() {
+				_, _ = expr.EvalBool(ctx)
+			})
+			if allocs > c.allocs {
+				t.Fatalf("unexpected allocs: %v, want: %v", allocs, c.allocs)
+			}
+		})
+	}
+}
+
+func TestZeroAlloc_Compile(t *testing.T) {
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		errMsg string
+	}{
+		{expr: `(> (+ age 1) 18)`, errMsg: "zero alloc is not supported by operator: +"},
+		{expr: `(in country ("US" "CA"))`, errMsg: "zero alloc is not supported by operator: in"},
+		{expr: `(if vip (> age 18) false)`, errMsg: "zero alloc is not supported by if"},
+		{expr: `(= age 18 18)`, errMsg: "params count: 3"},
+		{expr: `(> age 18)`, opts: []CompileOption{LimitSteps(10)}, errMsg: "step limit"},
+		{expr: `(> age 18)`, opts: []CompileOption{EnableDebug}, errMsg: "debug mode"},
+		{expr: `(or (> age 60) (< age 18))`, opts: []CompileOption{EnableSelectorMemoization}, errMsg: "memoization"},
+		{expr: `(and (> age 18) (= country "US"))`},
+		{expr: `(and (> age 18) (= country "US"))`, opts: []CompileOption{
+			func(c *CompileConfig) { c.Backend = ClosureBackend },
+		}},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, EnableStringSelectors, Optimizations(false), EnableZeroAlloc)...)
+		_, err := Compile(cc, c.expr)
+		if c.errMsg == "" {
+			assertNil(t, err, c.expr)
+			continue
+		}
+		assertErrStrContains(t, err, c.errMsg)
+	}
+}
+
+func TestZeroAlloc_Expr(t *testing.T) {
+	vals := map[string]interface{}{"age": 20}
+	cc := NewCompileConfig(EnableZeroAlloc,
+		RegisterSelKeys(map[string]interface{}{"age": 0, "country": "", "tier": ""}),
+		OnMissingSelector(MissingSelectorFalse), SelectorDefault("tier", "gold"))
+	expr, err := Compile(cc, `(and (> age 18) (= tier "gold") (!= country "US"))`)
+	assertNil(t, err)
+	ctx := NewCtxWithMap(cc, vals)
+
+	// the missing selectors are handled by the policy
+	res, err := expr.Eval(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, false)
+
+	// the mode is kept by the derived expressions
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	loaded, err := UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+	assertEquals(t, loaded.zeroAlloc, true)
+	partial, err := expr.PartialEval(map[SelectorKey]Value{cc.SelectorMap["age"]: 20})
+	assertNil(t, err)
+	assertEquals(t, partial.zeroAlloc, true)
+
+	for _, e := range []*Expr{loaded, partial} {
+		res, err := e.Eval(ctx)
+		assertNil(t, err)
+		assertEquals(t, res, false)
+	}
+
+	// the per call hook is evaluated by the hook evaluator
+	hook := &recordingHook{}
+	res, err = expr.EvalWithOptions(ctx, EvalOptions{Hook: hook})
+	assertNil(t, err)
+	assertEquals(t, res, false)
+	assertEquals(t, len(hook.events) > 0, true)
+}
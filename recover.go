@@ -0,0 +1,32 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOperatorPanic is returned if an operator panics in the expressions compiled with the
+// RecoverPanics option, the error names the operator and the index of its node
+var ErrOperatorPanic = errors.New("operator panic")
+
+// setRecoverPanics wraps the operators to recover the panics,
+// it should be applied after all the other wrappers except the debug ones
+func (e *Expr) setRecoverPanics() {
+	e.recoverPanics = true
+	for i, n := range e.nodes {
+		if typ := n.getNodeType(); typ == operator || typ == fastOperator {
+			n.operator = recoverPanic(i, n.value.(string), n.operator)
+		}
+	}
+}
+
+func recoverPanic(idx int, name string, op Operator) Operator {
+	return func(ctx *Ctx, params []Value) (res Value, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				res, err = nil, fmt.Errorf("%w, operator: %s, node index: %d, panic: %v", ErrOperatorPanic, name, idx, r)
+			}
+		}()
+		return op(ctx, params)
+	}
+}
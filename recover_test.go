@@ -0,0 +1,57 @@
+package eval
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	boom := func(_ *Ctx, params []Value) (Value, error) {
+		return params[5], nil
+	}
+
+	testCases := []struct {
+		name string
+		opts []CompileOption
+	}{
+		{name: "bytecode"},
+		{name: "fast", opts: []CompileOption{Optimizations(false)}},
+		{name: "closure", opts: []CompileOption{func(c *CompileConfig) { c.Backend = ClosureBackend }}},
+		{name: "debug", opts: []CompileOption{EnableDebug, func(c *CompileConfig) { c.DebugWriter = io.Discard }}},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, EnableStringSelectors, EnablePanicRecovery)...)
+		assertNil(t, RegisterOperator(cc, "boom", boom))
+
+		expr, err := Compile(cc, `(if (> age 18) (boom age) false)`)
+		assertNil(t, err, c.name)
+		ctx := NewCtxWithMap(cc, map[string]interface{}{"age": 20})
+
+		_, err = expr.Eval(ctx)
+		assertEquals(t, errors.Is(err, ErrOperatorPanic), true, c.name, err)
+		assertErrStrContains(t, err, "error: operator panic, operator: boom, node index: ", c.name)
+
+		res, err := expr.Eval(NewCtxWithMap(cc, map[string]interface{}{"age": 16}))
+		assertNil(t, err, c.name)
+		assertEquals(t, res, false, c.name)
+
+		bs, err := expr.Marshal()
+		assertNil(t, err, c.name)
+		loaded, err := UnmarshalExpr(bs, cc)
+		assertNil(t, err, c.name)
+		_, err = loaded.Eval(ctx)
+		assertEquals(t, errors.Is(err, ErrOperatorPanic), true, c.name, err)
+	}
+
+	// the panics are not recovered by default
+	cc := NewCompileConfig(EnableStringSelectors)
+	assertNil(t, RegisterOperator(cc, "boom", boom))
+	expr, err := Compile(cc, `(boom 1)`)
+	assertNil(t, err)
+	defer func() {
+		assertNotNil(t, recover())
+	}()
+	_, _ = expr.Eval(nil)
+}
@@ -0,0 +1,220 @@
+// Package registry provides a Registry of the compiled expressions keyed by the rule IDs,
+// which are reloaded from a Provider without restarting the service, e.g. from a file,
+// an HTTP endpoint or a database.
+//
+//	reg := registry.New(cc, func(ctx context.Context) (map[string]string, error) {
+//		return loadRulesFromDB(ctx)
+//	})
+//	if err := reg.Reload(ctx); err != nil {
+//		...
+//	}
+//	go reg.Watch(ctx, time.Minute, logError)
+//
+//	res, err := reg.Eval("is_adult", evalCtx)
+//
+// The rules are swapped atomically as a Snapshot, the evaluations started before
+// a reload finish on the old version, and a reload failing to compile any rule
+// keeps the current version.
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/larry618/eval"
+)
+
+// ErrRuleNotFound is returned if the rule ID isn't in the registry
+var ErrRuleNotFound = errors.New("rule not found")
+
+// Provider returns the sources of all the rules keyed by the rule IDs
+type Provider func(ctx context.Context) (map[string]string, error)
+
+// Snapshot is an immutable version of the rules
+type Snapshot struct {
+	// Version starts from 1, and it's increased by every swap
+	Version uint64
+	// UpdatedAt is when the snapshot was swapped in
+	UpdatedAt time.Time
+
+	sources map[string]string
+	exprs   map[string]*eval.Expr
+}
+
+// Get returns the compiled expression of the rule
+func (s *Snapshot) Get(id string) (*eval.Expr, bool) {
+	expr, exist := s.exprs[id]
+	return expr, exist
+}
+
+// Source returns the source of the rule
+func (s *Snapshot) Source(id string) (string, bool) {
+	src, exist := s.sources[id]
+	return src, exist
+}
+
+// IDs returns the sorted IDs of the rules
+func (s *Snapshot) IDs() []string {
+	ids := make([]string, 0, len(s.exprs))
+	for id := range s.exprs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Eval evaluates the rule against the ctx
+func (s *Snapshot) Eval(id string, ctx *eval.Ctx) (eval.Value, error) {
+	expr, exist := s.exprs[id]
+	if !exist {
+		return nil, fmt.Errorf("%w: %s", ErrRuleNotFound, id)
+	}
+	return expr.Eval(ctx)
+}
+
+// Registry holds the current Snapshot of the rules, it's safe for concurrent use
+type Registry struct {
+	cc       *eval.CompileConfig
+	provider Provider
+
+	// reloads are serialized, the current snapshot is read without lock
+	mu      sync.Mutex
+	current atomic.Value // *Snapshot
+}
+
+// New creates an empty Registry compiling the rules with the cc,
+// the provider can be nil if the rules are only swapped by Swap
+func New(cc *eval.CompileConfig, provider Provider) *Registry {
+	r := &Registry{cc: cc, provider: provider}
+	r.current.Store(&Snapshot{
+		sources: map[string]string{},
+		exprs:   map[string]*eval.Expr{},
+	})
+	return r
+}
+
+// Snapshot returns the current version of the rules,
+// the rules evaluated with the same snapshot are consistent
+func (r *Registry) Snapshot() *Snapshot {
+	return r.current.Load().(*Snapshot)
+}
+
+// Version returns the version of the current snapshot, it's 0 before the first swap
+func (r *Registry) Version() uint64 {
+	return r.Snapshot().Version
+}
+
+// Get returns the compiled expression of the rule in the current snapshot
+func (r *Registry) Get(id string) (*eval.Expr, bool) {
+	return r.Snapshot().Get(id)
+}
+
+// Eval evaluates the rule of the current snapshot against the ctx
+func (r *Registry) Eval(id string, ctx *eval.Ctx) (eval.Value, error) {
+	return r.Snapshot().Eval(id, ctx)
+}
+
+// Reload loads the rules from the provider and swaps them in
+func (r *Registry) Reload(ctx context.Context) error {
+	if r.provider == nil {
+		return errors.New("registry reload error, provider is nil")
+	}
+	sources, err := r.provider(ctx)
+	if err != nil {
+		return fmt.Errorf("registry reload error: %w", err)
+	}
+	return r.Swap(sources)
+}
+
+// Swap compiles the rules and replaces all the current ones with them,
+// the current snapshot is kept if any rule fails to compile.
+// The unchanged rules are not compiled again, and the version isn't
+// increased if nothing is changed.
+func (r *Registry) Swap(sources map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	curt := r.Snapshot()
+	next := &Snapshot{
+		Version:   curt.Version + 1,
+		UpdatedAt: time.Now(),
+		sources:   make(map[string]string, len(sources)),
+		exprs:     make(map[string]*eval.Expr, len(sources)),
+	}
+
+	var (
+		errs    []error
+		changed = len(sources) != len(curt.sources)
+	)
+	for _, id := range sortedKeys(sources) {
+		src := sources[id]
+		if old, exist := curt.sources[id]; exist && old == src {
+			next.sources[id], next.exprs[id] = src, curt.exprs[id]
+			continue
+		}
+
+		changed = true
+		expr, err := eval.Compile(r.cc, src)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s compile error: %w", id, err))
+			continue
+		}
+		next.sources[id], next.exprs[id] = src, expr
+	}
+
+	if len(errs) != 0 {
+		return multiError(errs)
+	}
+	if changed {
+		r.current.Store(next)
+	}
+	return nil
+}
+
+// Watch reloads the rules every interval until the ctx is done,
+// the errors of the reloads are passed to onError if it isn't nil.
+func (r *Registry) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Reload(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// multiError is the errors of the rules failed to compile, the messages are joined by the newlines
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors, which are checked by errors.Is and errors.As since go 1.20
+func (m multiError) Unwrap() []error {
+	return m
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,151 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/larry618/eval"
+)
+
+func TestRegistry(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		sources = map[string]string{
+			"adult": `(>= age 18)`,
+			"vip":   `(= tier "gold")`,
+		}
+		providerErr error
+	)
+	reg := New(eval.NewCompileConfig(eval.EnableStringSelectors), func(context.Context) (map[string]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		res := make(map[string]string, len(sources))
+		for k, v := range sources {
+			res[k] = v
+		}
+		return res, providerErr
+	})
+	ctx := &eval.Ctx{Selector: eval.NewMapSelector(map[string]interface{}{"age": 18, "tier": "gold"})}
+
+	if _, err := reg.Eval("adult", ctx); !errors.Is(err, ErrRuleNotFound) || reg.Version() != 0 {
+		t.Fatalf("unexpected error: %v, version: %d", err, reg.Version())
+	}
+
+	if err := reg.Reload(context.Background()); err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	old := reg.Snapshot()
+	if res, err := reg.Eval("adult", ctx); err != nil || res != true || old.Version != 1 {
+		t.Fatalf("unexpected result: %v, error: %v, version: %d", res, err, old.Version)
+	}
+
+	// change the threshold
+	mu.Lock()
+	sources["adult"] = `(>= age 21)`
+	mu.Unlock()
+	if err := reg.Reload(context.Background()); err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	if res, err := reg.Eval("adult", ctx); err != nil || res != false || reg.Version() != 2 {
+		t.Fatalf("unexpected result: %v, error: %v, version: %d", res, err, reg.Version())
+	}
+	// the old snapshot is not changed
+	if res, err := old.Eval("adult", ctx); err != nil || res != true {
+		t.Fatalf("unexpected result of old snapshot: %v, error: %v", res, err)
+	}
+	// the unchanged rules are reused
+	oldVIP, _ := old.Get("vip")
+	newVIP, _ := reg.Get("vip")
+	if oldVIP != newVIP {
+		t.Fatalf("the unchanged rule is compiled again")
+	}
+
+	// nothing changed
+	if err := reg.Reload(context.Background()); err != nil || reg.Version() != 2 {
+		t.Fatalf("unexpected error: %v, version: %d", err, reg.Version())
+	}
+
+	// the current version is kept if any rule fails to compile
+	mu.Lock()
+	sources["adult"] = `(>= age`
+	sources["senior"] = `(>= age 60)`
+	mu.Unlock()
+	err := reg.Reload(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "rule adult compile error") || reg.Version() != 2 {
+		t.Fatalf("unexpected error: %v, version: %d", err, reg.Version())
+	}
+	if _, exist := reg.Get("senior"); exist {
+		t.Fatalf("the failed reload is swapped in")
+	}
+
+	// the errors of all the failed rules are reported in order
+	err = reg.Swap(map[string]string{"b": `(< age`, "a": `(> age`})
+	if err == nil || !strings.HasPrefix(err.Error(), "rule a compile error") || strings.Count(err.Error(), "\nrule b compile error") != 1 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	providerErr = errors.New("db is down")
+	mu.Unlock()
+	if err := reg.Reload(context.Background()); err == nil || !strings.Contains(err.Error(), "db is down") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the rules are removed by swap
+	if err := reg.Swap(map[string]string{"senior": `(>= age 60)`}); err != nil {
+		t.Fatalf("swap error: %v", err)
+	}
+	if ids := reg.Snapshot().IDs(); len(ids) != 1 || ids[0] != "senior" || reg.Version() != 3 {
+		t.Fatalf("unexpected rules: %v, version: %d", ids, reg.Version())
+	}
+	if src, _ := reg.Snapshot().Source("senior"); src != `(>= age 60)` {
+		t.Fatalf("unexpected source: %s", src)
+	}
+}
+
+func TestRegistry_Watch(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		threshold = "18"
+	)
+	reg := New(eval.NewCompileConfig(eval.EnableStringSelectors), func(context.Context) (map[string]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return map[string]string{"adult": `(>= age ` + threshold + `)`}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		reg.Watch(ctx, time.Millisecond, func(err error) {
+			t.Errorf("watch error: %v", err)
+		})
+		close(done)
+	}()
+
+	// the evaluations are concurrent with the reloads
+	evalCtx := &eval.Ctx{Selector: eval.NewMapSelector(map[string]interface{}{"age": 20})}
+	deadline := time.Now().Add(time.Second)
+	for reg.Version() < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	threshold = "21"
+	mu.Unlock()
+	for time.Now().Before(deadline) {
+		if res, err := reg.Eval("adult", evalCtx); err == nil && res == false {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if res, err := reg.Eval("adult", evalCtx); err != nil || res != false || reg.Version() != 2 {
+		t.Fatalf("unexpected result: %v, error: %v, version: %d", res, err, reg.Version())
+	}
+}
@@ -0,0 +1,235 @@
+// Package repl implements an interactive shell evaluating the expressions
+// against a mutable context, which is useful to experiment with the operators.
+//
+// The lines starting with a colon are commands, the others are expressions:
+//
+//	>>> :set age 20
+//	>>> :set tags ("a" "b")
+//	>>> (and (> age 18) (in "a" tags))
+//	true
+//
+// An expression can span multiple lines until its parentheses are balanced or an empty line is read.
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/larry618/eval"
+)
+
+const (
+	prompt     = ">>> "
+	contPrompt = "... "
+)
+
+const help = `commands:
+  :set <name> <expr>  set the selector to the value of the expression
+  :unset <name>       remove the selector
+  :vars               print the selectors
+  :debug on|off       print the stacks of each evaluation step
+  :explain on|off     print the trace explaining the result
+  :help               print this message
+  :quit               exit
+`
+
+// REPL keeps the selector values and the options across the lines
+type REPL struct {
+	conf    *eval.CompileConfig
+	vals    map[string]interface{}
+	debug   bool
+	explain bool
+}
+
+// New creates a REPL compiling the expressions with a copy of the conf,
+// the unknown selectors are allowed, as the selectors can be set at any time.
+func New(conf *eval.CompileConfig) *REPL {
+	var cc *eval.CompileConfig
+	if conf == nil {
+		cc = eval.NewCompileConfig()
+	} else {
+		cc = eval.CopyCompileConfig(conf)
+	}
+	eval.EnableStringSelectors(cc)
+	return &REPL{
+		conf: cc,
+		vals: make(map[string]interface{}),
+	}
+}
+
+// Run reads the lines from in until EOF or :quit, and writes the results to out
+func Run(conf *eval.CompileConfig, in io.Reader, out io.Writer) error {
+	return New(conf).Run(in, out)
+}
+
+// Set sets the value of the selector
+func (r *REPL) Set(name string, val interface{}) {
+	r.vals[name] = val
+}
+
+// Run reads the lines from in until EOF or :quit, and writes the results to out
+func (r *REPL) Run(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	var pending strings.Builder
+
+	fmt.Fprint(out, prompt)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if pending.Len() != 0 {
+			pending.WriteRune('\n')
+		}
+		pending.WriteString(line)
+
+		// an empty line submits the unfinished expression
+		input := strings.TrimSpace(pending.String())
+		if !strings.HasPrefix(input, ":") && unbalanced(input) && strings.TrimSpace(line) != "" {
+			fmt.Fprint(out, contPrompt)
+			continue
+		}
+		pending.Reset()
+
+		quit, err := r.exec(input, out)
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+		if quit {
+			return nil
+		}
+		fmt.Fprint(out, prompt)
+	}
+	return scanner.Err()
+}
+
+func (r *REPL) exec(input string, out io.Writer) (bool, error) {
+	if input == "" {
+		return false, nil
+	}
+	if !strings.HasPrefix(input, ":") {
+		return false, r.eval(input, out)
+	}
+
+	cmd, args := input, ""
+	if i := strings.IndexAny(input, " \t"); i >= 0 {
+		cmd, args = input[:i], strings.TrimSpace(input[i+1:])
+	}
+
+	switch cmd {
+	case ":quit", ":q", ":exit":
+		return true, nil
+	case ":help":
+		fmt.Fprint(out, help)
+	case ":set":
+		name, exprStr := args, ""
+		if i := strings.IndexAny(args, " \t"); i >= 0 {
+			name, exprStr = args[:i], strings.TrimSpace(args[i+1:])
+		}
+		if name == "" || exprStr == "" {
+			return false, errors.New("usage: :set <name> <expr>")
+		}
+		val, err := r.value(exprStr)
+		if err != nil {
+			return false, err
+		}
+		r.vals[name] = val
+	case ":unset":
+		if args == "" {
+			return false, errors.New("usage: :unset <name>")
+		}
+		delete(r.vals, args)
+	case ":vars":
+		names := make([]string, 0, len(r.vals))
+		for name := range r.vals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(out, "%s = %s\n", name, eval.FormatValue(r.vals[name]))
+		}
+	case ":debug", ":explain":
+		var on bool
+		switch args {
+		case "on":
+			on = true
+		case "off":
+		default:
+			return false, fmt.Errorf("usage: %s on|off", cmd)
+		}
+		if cmd == ":debug" {
+			r.debug = on
+		} else {
+			r.explain = on
+		}
+	default:
+		return false, fmt.Errorf("unknown command: %s, see :help", cmd)
+	}
+	return false, nil
+}
+
+func (r *REPL) eval(exprStr string, out io.Writer) error {
+	cc := eval.CopyCompileConfig(r.conf)
+	if r.debug {
+		eval.EnableDebug(cc)
+		cc.DebugWriter = out
+	}
+
+	expr, err := eval.Compile(cc, exprStr)
+	if err != nil {
+		var ce *eval.CompileError
+		if errors.As(err, &ce) {
+			return fmt.Errorf("%w\n%s", ce.Err, ce.Snippet)
+		}
+		return err
+	}
+
+	ctx := eval.NewCtxWithMap(cc, r.vals)
+	if r.explain {
+		_, trace, _ := expr.EvalBoolWithTrace(ctx)
+		fmt.Fprintln(out, trace)
+	}
+
+	res, err := expr.Eval(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, eval.FormatValue(res))
+	return nil
+}
+
+// value evaluates the expression of :set against the current selectors
+func (r *REPL) value(exprStr string) (eval.Value, error) {
+	expr, err := eval.Compile(r.conf, exprStr)
+	if err != nil {
+		return nil, err
+	}
+	return expr.Eval(eval.NewCtxWithMap(r.conf, r.vals))
+}
+
+// unbalanced reports whether there are more open parentheses than the closed ones,
+// the parentheses in the string literals are ignored
+func unbalanced(s string) bool {
+	depth := 0
+	inStr := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inStr && c == '\\':
+			i++
+		case c == '"':
+			inStr = !inStr
+		case inStr:
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == ';':
+			// the rest of the line is a comment
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		}
+	}
+	return depth > 0
+}
@@ -0,0 +1,73 @@
+package repl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/larry618/eval"
+)
+
+func TestRun(t *testing.T) {
+	input := `:set age 20
+:set tags ("a" "b")
+:set score (* age 1.5)
+:vars
+(and
+  (> age 18)
+  (in "a" tags))
+:explain on
+(or (< age 1) (> score 2))
+:explain off
+(> age
+
+:unset age
+(> age 1)
+:foo
+:quit
+(> score 1)
+`
+	want := `>>> >>> >>> >>> age = 20
+score = 30.0
+tags = ("a" "b")
+>>> ... ... true
+>>> >>> (or (< age 1) (> score 2)) => true
+  (< age 1) => false
+    age => 20
+    1 => 1
+  (> score 2) => true
+    score => 30.0
+    2 => 2
+true
+>>> >>> ... error: parentheses unmatched error
+(> age
+^
+>>> >>> error: selectorKey not exist age
+>>> error: unknown command: :foo, see :help
+>>> `
+
+	var out strings.Builder
+	if err := Run(nil, strings.NewReader(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != want {
+		t.Fatalf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestREPL_Debug(t *testing.T) {
+	cc := eval.NewCompileConfig()
+	r := New(cc)
+	r.Set("age", 20)
+
+	var out strings.Builder
+	if err := r.Run(strings.NewReader(":debug on\n(> age 18)\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "Stack Frame:") || !strings.HasSuffix(got, "true\n>>> ") {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+	// the conf is not changed
+	if cc.CompileOptions[eval.AllowUnknownSelectors] {
+		t.Fatal("the conf should be copied")
+	}
+}
@@ -0,0 +1,161 @@
+// Package rules provides an Engine firing the rules whose conditions are matched,
+// the rules are evaluated in the order of their priorities, and the Strategy of the Engine
+// decides which of the matched rules are fired.
+//
+//	engine := rules.NewEngine(cc, rules.FirstMatch)
+//	err := engine.AddRule("vip", `(and (> age 18) (= tier "gold"))`, 10, grantVIP)
+//	err = engine.AddRule("adult", `(> age 18)`, 0, nil)
+//	fired, err := engine.Fire(eval.NewCtxWithMap(cc, vals)) // ["vip"]
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/larry618/eval"
+)
+
+// ErrDuplicateRule is returned by AddRule if the name of the rule has been added
+var ErrDuplicateRule = errors.New("duplicate rule")
+
+// Strategy decides which of the matched rules are fired
+type Strategy int
+
+const (
+	// FirstMatch fires the first matched rule, the rest rules are not evaluated
+	FirstMatch Strategy = iota
+	// AllMatches fires all the matched rules
+	AllMatches
+	// HighestPriority fires all the matched rules of the highest priority among the matched ones,
+	// the rules of the lower priorities are not evaluated once a rule is matched
+	HighestPriority
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case FirstMatch:
+		return "first_match"
+	case AllMatches:
+		return "all_matches"
+	case HighestPriority:
+		return "highest_priority"
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+// Action is executed when the rule is fired, the ctx is the one passed to Engine.Fire
+type Action func(ctx *eval.Ctx) error
+
+// Rule is a rule added to the Engine
+type Rule struct {
+	Name string
+	// Expr is the compiled condition of the rule, which should be evaluated to a bool value
+	Expr *eval.Expr
+	// Priority of the rule, the rules of the higher priorities are evaluated first,
+	// and the rules of the same priority are evaluated in the order they are added
+	Priority int
+	Action   Action
+}
+
+// Engine evaluates the rules and fires the matched ones by the Strategy,
+// it's safe for concurrent use.
+type Engine struct {
+	cc       *eval.CompileConfig
+	strategy Strategy
+
+	mu    sync.RWMutex
+	rules []*Rule // ordered by the priorities
+	names map[string]bool
+}
+
+// NewEngine creates an Engine compiling the rules with the cc
+func NewEngine(cc *eval.CompileConfig, strategy Strategy) *Engine {
+	return &Engine{
+		cc:       cc,
+		strategy: strategy,
+		names:    make(map[string]bool),
+	}
+}
+
+// AddRule compiles the condition and adds the rule, the action can be nil
+func (e *Engine) AddRule(name, expr string, priority int, action Action) error {
+	compiled, err := eval.Compile(e.cc, expr)
+	if err != nil {
+		return fmt.Errorf("rule %s compile error: %w", name, err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.names[name] {
+		return fmt.Errorf("%w: %s", ErrDuplicateRule, name)
+	}
+	e.names[name] = true
+
+	// after the rules of the same priority
+	i := sort.Search(len(e.rules), func(i int) bool {
+		return e.rules[i].Priority < priority
+	})
+	// the rules are copied on write, so that Match iterates them without the lock
+	rules := make([]*Rule, 0, len(e.rules)+1)
+	rules = append(rules, e.rules[:i]...)
+	rules = append(rules, &Rule{Name: name, Expr: compiled, Priority: priority, Action: action})
+	e.rules = append(rules, e.rules[i:]...)
+	return nil
+}
+
+// Rules returns the rules in the order they are evaluated
+func (e *Engine) Rules() []Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	res := make([]Rule, len(e.rules))
+	for i, r := range e.rules {
+		res[i] = *r
+	}
+	return res
+}
+
+// Fire evaluates the rules against the ctx, and executes the actions of the fired rules in order.
+// It returns the names of the fired rules, the evaluation stops at the first error,
+// and the rules fired before it are still returned.
+func (e *Engine) Fire(ctx *eval.Ctx) ([]string, error) {
+	matched, err := e.Match(ctx)
+	fired := make([]string, 0, len(matched))
+	for _, r := range matched {
+		if r.Action != nil {
+			if err := r.Action(ctx); err != nil {
+				return fired, fmt.Errorf("rule %s action error: %w", r.Name, err)
+			}
+		}
+		fired = append(fired, r.Name)
+	}
+	return fired, err
+}
+
+// Match evaluates the rules against the ctx like Fire, but the actions are not executed
+func (e *Engine) Match(ctx *eval.Ctx) ([]Rule, error) {
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
+
+	var matched []Rule
+	for _, r := range rules {
+		if e.strategy == HighestPriority && len(matched) != 0 && r.Priority < matched[0].Priority {
+			break
+		}
+
+		ok, err := r.Expr.EvalBool(ctx)
+		if err != nil {
+			return matched, fmt.Errorf("rule %s eval error: %w", r.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		matched = append(matched, *r)
+		if e.strategy == FirstMatch {
+			break
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,140 @@
+package rules
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/larry618/eval"
+)
+
+func newTestEngine(t *testing.T, strategy Strategy, actions *[]string) *Engine {
+	cc := eval.NewCompileConfig(eval.EnableStringSelectors)
+	engine := NewEngine(cc, strategy)
+
+	record := func(name string) Action {
+		return func(*eval.Ctx) error {
+			*actions = append(*actions, name)
+			return nil
+		}
+	}
+	rules := []struct {
+		name     string
+		expr     string
+		priority int
+	}{
+		{name: "adult", expr: `(>= age 18)`, priority: 0},
+		{name: "gold", expr: `(= tier "gold")`, priority: 10},
+		{name: "senior", expr: `(>= age 60)`, priority: 5},
+		{name: "gold_adult", expr: `(and (= tier "gold") (>= age 18))`, priority: 10},
+		{name: "everyone", expr: `true`, priority: -1},
+	}
+	for _, r := range rules {
+		if err := engine.AddRule(r.name, r.expr, r.priority, record(r.name)); err != nil {
+			t.Fatalf("add rule error: %v", err)
+		}
+	}
+	return engine
+}
+
+func TestEngine_Fire(t *testing.T) {
+	testCases := []struct {
+		strategy Strategy
+		vals     map[string]interface{}
+		want     []string
+	}{
+		{strategy: FirstMatch, vals: map[string]interface{}{"age": 70, "tier": "gold"}, want: []string{"gold"}},
+		{strategy: FirstMatch, vals: map[string]interface{}{"age": 70, "tier": "silver"}, want: []string{"senior"}},
+		{strategy: FirstMatch, vals: map[string]interface{}{"age": 10, "tier": "silver"}, want: []string{"everyone"}},
+		{strategy: AllMatches, vals: map[string]interface{}{"age": 70, "tier": "gold"},
+			want: []string{"gold", "gold_adult", "senior", "adult", "everyone"}},
+		{strategy: AllMatches, vals: map[string]interface{}{"age": 20, "tier": "silver"}, want: []string{"adult", "everyone"}},
+		{strategy: HighestPriority, vals: map[string]interface{}{"age": 70, "tier": "gold"}, want: []string{"gold", "gold_adult"}},
+		{strategy: HighestPriority, vals: map[string]interface{}{"age": 10, "tier": "gold"}, want: []string{"gold"}},
+		{strategy: HighestPriority, vals: map[string]interface{}{"age": 20, "tier": "silver"}, want: []string{"adult"}},
+	}
+
+	for _, c := range testCases {
+		var actions []string
+		engine := newTestEngine(t, c.strategy, &actions)
+		fired, err := engine.Fire(newCtx(c.vals))
+		if err != nil {
+			t.Fatalf("%v fire error: %v", c.strategy, err)
+		}
+		if !reflect.DeepEqual(fired, c.want) || !reflect.DeepEqual(actions, c.want) {
+			t.Fatalf("%v unexpected fired rules: %v, actions: %v, want: %v", c.strategy, fired, actions, c.want)
+		}
+	}
+}
+
+func TestEngine_Errors(t *testing.T) {
+	cc := eval.NewCompileConfig(eval.EnableStringSelectors)
+	engine := NewEngine(cc, AllMatches)
+
+	if err := engine.AddRule("adult", `(> age 18)`, 0, nil); err != nil {
+		t.Fatalf("add rule error: %v", err)
+	}
+	if err := engine.AddRule("adult", `(> age 20)`, 0, nil); !errors.Is(err, ErrDuplicateRule) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := engine.AddRule("broken", `(> age`, 0, nil); err == nil {
+		t.Fatalf("compile error expected")
+	}
+
+	errAction := errors.New("action failed")
+	if err := engine.AddRule("vip", `(= tier "gold")`, 1, func(*eval.Ctx) error { return errAction }); err != nil {
+		t.Fatalf("add rule error: %v", err)
+	}
+	fired, err := engine.Fire(newCtx(map[string]interface{}{"age": 20, "tier": "gold"}))
+	if !errors.Is(err, errAction) || len(fired) != 0 {
+		t.Fatalf("unexpected fired rules: %v, error: %v", fired, err)
+	}
+
+	// the evaluation stops at the failed rule
+	fired, err = engine.Fire(newCtx(map[string]interface{}{"age": "old", "tier": "silver"}))
+	if err == nil || !strings.Contains(err.Error(), "rule adult eval error") || len(fired) != 0 {
+		t.Fatalf("unexpected fired rules: %v, error: %v", fired, err)
+	}
+	matched, err := engine.Match(newCtx(map[string]interface{}{"age": 20, "tier": "silver"}))
+	if err != nil || len(matched) != 1 || matched[0].Name != "adult" {
+		t.Fatalf("unexpected matched rules: %v, error: %v", matched, err)
+	}
+
+	names := make([]string, 0)
+	for _, r := range engine.Rules() {
+		names = append(names, r.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"vip", "adult"}) {
+		t.Fatalf("unexpected rules: %v", names)
+	}
+}
+
+func TestEngine_Concurrent(t *testing.T) {
+	cc := eval.NewCompileConfig(eval.EnableStringSelectors)
+	engine := NewEngine(cc, AllMatches)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_ = engine.AddRule(string(rune('a'+i)), `(> age 18)`, i, nil)
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, _ = engine.Fire(newCtx(map[string]interface{}{"age": 20}))
+		}()
+	}
+	wg.Wait()
+
+	fired, err := engine.Fire(newCtx(map[string]interface{}{"age": 20}))
+	if err != nil || len(fired) != 8 || fired[0] != "h" {
+		t.Fatalf("unexpected fired rules: %v, error: %v", fired, err)
+	}
+}
+
+func newCtx(vals map[string]interface{}) *eval.Ctx {
+	return &eval.Ctx{Selector: eval.NewMapSelector(vals)}
+}
@@ -0,0 +1,291 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sharedSelectorPrefix is the name prefix of the selectors
+// which refer to the shared subexpressions of a RuleSet
+const sharedSelectorPrefix = "$shared."
+
+// RuleSet is a group of expressions compiled together.
+// The subexpressions appearing more than once in the rules are extracted,
+// and they are evaluated at most once in each RuleSet.Eval,
+// the values of selectors are also fetched at most once.
+type RuleSet struct {
+	rules     []*Expr
+	shared    []*Expr
+	sharedIdx map[string]int
+}
+
+// RuleResult is the evaluation result of a rule in the RuleSet
+type RuleResult struct {
+	Value Value
+	Err   error
+}
+
+// CompileRuleSet compiles the rules with the same compile config
+func CompileRuleSet(cc *CompileConfig, rules ...string) (*RuleSet, error) {
+	b := &ruleSetBuilder{
+		keys:    make(map[*astNode]string),
+		counts:  make(map[string]int),
+		bodies:  make(map[string]*astNode),
+		indexes: make(map[string]int),
+	}
+
+	trees := make([]*astNode, len(rules))
+	confs := make([]*CompileConfig, len(rules))
+	for i, rule := range rules {
+		tree, conf, err := parseAndRewrite(cc, rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d compile error: %w", i, err)
+		}
+		if enabled, exist := conf.CompileOptions[ConstantFolding]; enabled || !exist {
+			_ = optimizeConstantFolding(conf, tree)
+		}
+		b.count(tree, conf)
+		trees[i], confs[i] = tree, conf
+	}
+
+	// the shared subexpressions are numbered in the order of their first occurrence
+	for i, tree := range trees {
+		b.assign(tree, confs[i])
+	}
+
+	rs := &RuleSet{
+		rules:     make([]*Expr, len(rules)),
+		sharedIdx: make(map[string]int, len(b.order)),
+	}
+	for i, key := range b.order {
+		expr, err := compileAstTree(b.confs[i], b.replace(b.bodies[key], b.confs[i], true))
+		if err != nil {
+			return nil, err
+		}
+		rs.shared = append(rs.shared, expr)
+		rs.sharedIdx[sharedSelectorName(i)] = i
+	}
+
+	for i, tree := range trees {
+		expr, err := compileAstTree(confs[i], b.replace(tree, confs[i], false))
+		if err != nil {
+			return nil, fmt.Errorf("rule %d compile error: %w", i, err)
+		}
+		rs.rules[i] = expr
+	}
+	return rs, nil
+}
+
+// Len returns the number of rules
+func (rs *RuleSet) Len() int {
+	return len(rs.rules)
+}
+
+// Rule returns the compiled expression of the i-th rule,
+// the shared subexpressions are replaced with selectors in it,
+// so it should only be evaluated by RuleSet.Eval.
+func (rs *RuleSet) Rule(i int) *Expr {
+	return rs.rules[i]
+}
+
+// SharedCount returns the number of the extracted shared subexpressions
+func (rs *RuleSet) SharedCount() int {
+	return len(rs.shared)
+}
+
+// Eval evaluates all the rules against the ctx,
+// the results are in the same order as the rules.
+func (rs *RuleSet) Eval(ctx *Ctx) []RuleResult {
+	sel := &ruleSetSelector{
+		rs:     rs,
+		values: make(map[string]Value),
+		shared: make([]*RuleResult, len(rs.shared)),
+	}
+	c := &Ctx{Selector: sel}
+	if ctx != nil {
+		sel.Selector = ctx.Selector
+		c.Ctx = ctx.Ctx
+	}
+	sel.ctx = c
+
+	res := make([]RuleResult, len(rs.rules))
+	for i, rule := range rs.rules {
+		res[i].Value, res[i].Err = rule.Eval(c)
+	}
+	return res
+}
+
+// ruleSetSelector evaluates the shared subexpressions lazily,
+// and caches the values of shared subexpressions and selectors
+type ruleSetSelector struct {
+	Selector
+	rs     *RuleSet
+	ctx    *Ctx
+	values map[string]Value
+	shared []*RuleResult
+}
+
+func (s *ruleSetSelector) Get(selKey SelectorKey, strKey string) (Value, error) {
+	if idx, exist := s.rs.sharedIdx[strKey]; exist {
+		if res := s.shared[idx]; res != nil {
+			return res.Value, res.Err
+		}
+		val, err := s.rs.shared[idx].Eval(s.ctx)
+		s.shared[idx] = &RuleResult{Value: val, Err: err}
+		return val, err
+	}
+
+	if val, exist := s.values[strKey]; exist {
+		return val, nil
+	}
+	if s.Selector == nil {
+		return nil, fmt.Errorf("selector is nil, key: %s", strKey)
+	}
+	val, err := s.Selector.Get(selKey, strKey)
+	if err != nil {
+		return nil, err
+	}
+	s.values[strKey] = val
+	return val, nil
+}
+
+type ruleSetBuilder struct {
+	keys   map[*astNode]string
+	counts map[string]int
+	bodies map[string]*astNode
+	// indexes of the shared subexpressions
+	indexes map[string]int
+	order   []string
+	confs   []*CompileConfig
+}
+
+// count counts the occurrences of the subexpressions,
+// the repeated occurrence is not descended, so the subexpressions
+// which only appear inside a shared one are not counted twice.
+// The lambda bodies are not counted, since they read the params of the lambdas.
+func (b *ruleSetBuilder) count(root *astNode, conf *CompileConfig) {
+	if isAstLeaf(root) {
+		return
+	}
+	key := b.key(root)
+	b.counts[key]++
+	if b.counts[key] > 1 {
+		return
+	}
+	b.bodies[key] = root
+	body := lambdaBodyIdx(conf, root)
+	for i, child := range root.children {
+		if i != body {
+			b.count(child, conf)
+		}
+	}
+}
+
+func (b *ruleSetBuilder) assign(root *astNode, conf *CompileConfig) {
+	if isAstLeaf(root) {
+		return
+	}
+	key := b.key(root)
+	if b.counts[key] > 1 {
+		if _, exist := b.indexes[key]; exist {
+			return
+		}
+		root = b.bodies[key]
+		b.indexes[key] = len(b.order)
+		b.order = append(b.order, key)
+		b.confs = append(b.confs, conf)
+	}
+	body := lambdaBodyIdx(conf, root)
+	for i, child := range root.children {
+		if i != body {
+			b.assign(child, conf)
+		}
+	}
+}
+
+// replace copies the tree and replaces the shared subexpressions with selectors,
+// the lambda bodies are copied as they are
+func (b *ruleSetBuilder) replace(root *astNode, conf *CompileConfig, isBody bool) *astNode {
+	if !isBody && !isAstLeaf(root) {
+		if idx, exist := b.indexes[b.key(root)]; exist {
+			return &astNode{
+				node: &node{
+					flag:   selector,
+					value:  sharedSelectorName(idx),
+					selKey: UndefinedSelKey,
+				},
+			}
+		}
+	}
+
+	n := *root.node
+	res := &astNode{
+		node:     &n,
+		children: make([]*astNode, len(root.children)),
+	}
+	body := lambdaBodyIdx(conf, root)
+	for i, child := range root.children {
+		if i == body {
+			res.children[i] = copyAstTree(child)
+			continue
+		}
+		res.children[i] = b.replace(child, conf, false)
+	}
+	return res
+}
+
+// lambdaBodyIdx returns the index of the lambda body if the node is a higher-order operator, e.g. any or let,
+// otherwise -1
+func lambdaBodyIdx(conf *CompileConfig, root *astNode) int {
+	if _, ok := lambdaParam(conf, root); ok {
+		return 2
+	}
+	return -1
+}
+
+func copyAstTree(root *astNode) *astNode {
+	n := *root.node
+	res := &astNode{
+		node:     &n,
+		children: make([]*astNode, len(root.children)),
+	}
+	for i, child := range root.children {
+		res.children[i] = copyAstTree(child)
+	}
+	return res
+}
+
+// key returns the canonical representation of the subexpression
+func (b *ruleSetBuilder) key(root *astNode) string {
+	if key, exist := b.keys[root]; exist {
+		return key
+	}
+
+	var key string
+	switch root.node.getNodeType() {
+	case constant:
+		key = fmt.Sprintf("%s:%T", decompileValue(root.node.value), root.node.value)
+	case selector, end:
+		key = fmt.Sprint(root.node.value)
+	default:
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("(%v", root.node.value))
+		for _, child := range root.children {
+			sb.WriteRune(' ')
+			sb.WriteString(b.key(child))
+		}
+		sb.WriteRune(')')
+		key = sb.String()
+	}
+
+	b.keys[root] = key
+	return key
+}
+
+func sharedSelectorName(idx int) string {
+	return fmt.Sprintf("%s%d", sharedSelectorPrefix, idx)
+}
+
+func isAstLeaf(root *astNode) bool {
+	return len(root.children) == 0
+}
@@ -0,0 +1,139 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestRuleSet(t *testing.T) {
+	vals := map[string]interface{}{
+		"age":     20,
+		"country": "US",
+		"vip":     false,
+		"score":   80,
+	}
+	rules := []string{
+		`(and (> age 18) (= country "US"))`,
+		`(and (> age 18) (= country "US") vip)`,
+		`(or (> age 18) (> score 90))`,
+		`(if (> age 18) (* score 2) (/ score 2))`,
+		`(and (> age 18) (= country "US"))`,
+		`(< (+ score 10) 100)`,
+		`(> (+ score 10) 50)`,
+		`(= (+ 1 2) 3)`,
+	}
+
+	reads := make(map[string]int)
+	sel := SelectorFunc(func(name string) (Value, error) {
+		reads[name]++
+		v, exist := vals[name]
+		if !exist {
+			return nil, fmt.Errorf("field not found: %s", name)
+		}
+		return v, nil
+	})
+
+	cc := NewCompileConfig(EnableStringSelectors)
+	rs, err := CompileRuleSet(cc, rules...)
+	assertNil(t, err)
+	assertEquals(t, rs.Len(), len(rules))
+	// (> age 18), (and (> age 18) (= country "US")), (= country "US"), (+ score 10)
+	assertEquals(t, rs.SharedCount(), 4)
+
+	results := rs.Eval(NewCtxWithStringSelector(sel))
+	assertEquals(t, len(results), len(rules))
+	for i, rule := range rules {
+		want, err := Eval(rule, vals)
+		assertNil(t, err)
+		assertNil(t, results[i].Err)
+		assertEquals(t, results[i].Value, want, rule)
+	}
+
+	// each selector is read only once
+	for name, cnt := range reads {
+		assertEquals(t, cnt, 1, name)
+	}
+}
+
+func TestRuleSet_ShortCircuit(t *testing.T) {
+	var called int
+	cc := NewCompileConfig(EnableStringSelectors, Optimizations(false, Reordering))
+	err := RegisterOperator(cc, "expensive", func(ctx *Ctx, params []Value) (Value, error) {
+		called++
+		return true, nil
+	})
+	assertNil(t, err)
+
+	rs, err := CompileRuleSet(cc,
+		`(and false (expensive))`,
+		`(and (> age 18) (= (expensive) true))`,
+		`(or (> age 18) (= (expensive) true))`,
+	)
+	assertNil(t, err)
+
+	sel := SelectorFunc(func(name string) (Value, error) {
+		return int64(20), nil
+	})
+	results := rs.Eval(NewCtxWithStringSelector(sel))
+	assertEquals(t, results[0].Value, false)
+	assertEquals(t, results[1].Value, true)
+	assertEquals(t, results[2].Value, true)
+	assertEquals(t, called, 1)
+}
+
+func TestRuleSet_Errors(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors)
+
+	_, err := CompileRuleSet(cc, `(> age 18)`, `(unknown age)`)
+	assertErrStrContains(t, err, "rule 1 compile error")
+
+	rs, err := CompileRuleSet(cc,
+		`(and (> age 18) true)`,
+		`(or (> age 18) false)`,
+		`(= country "US")`,
+	)
+	assertNil(t, err)
+
+	errAge := errors.New("age is unavailable")
+	sel := SelectorFunc(func(name string) (Value, error) {
+		if name == "age" {
+			return nil, errAge
+		}
+		return "US", nil
+	})
+	results := rs.Eval(NewCtxWithStringSelector(sel))
+	assertEquals(t, errors.Is(results[0].Err, errAge), true)
+	assertEquals(t, errors.Is(results[1].Err, errAge), true)
+	assertNil(t, results[2].Err)
+	assertEquals(t, results[2].Value, true)
+}
+
+func TestRuleSet_Lambdas(t *testing.T) {
+	vals := map[string]interface{}{
+		"items": []int64{1, 5},
+		"limit": 3,
+	}
+	rules := []string{
+		`(any items x (> x 3))`,
+		`(all items x (> x 3))`,
+		`(any items x (> x 3))`,
+		`(count items x (> x limit))`,
+		`(let (y (+ limit 1)) (> y 3))`,
+		`(let (y (+ limit 1)) (< y 3))`,
+	}
+
+	cc := NewCompileConfig(EnableStringSelectors)
+	rs, err := CompileRuleSet(cc, rules...)
+	assertNil(t, err)
+	// (any items x (> x 3)), (+ limit 1), the lambda bodies are not shared
+	assertEquals(t, rs.SharedCount(), 2)
+
+	results := rs.Eval(NewCtxWithMap(cc, vals))
+	for i, rule := range rules {
+		want, err := Eval(rule, vals)
+		assertNil(t, err, rule)
+		assertNil(t, results[i].Err, rule)
+		assertEquals(t, results[i].Value, want, rule)
+	}
+}
@@ -1,14 +1,17 @@
 package eval
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"strings"
 )
 
 var (
 	builtinConstants = map[string]Value{
 		"true":  true,
 		"false": false,
+		"null":  nil,
 	}
 )
 
@@ -42,11 +45,13 @@ func GetOrRegisterKey(cc *CompileConfig, name string) SelectorKey {
 		key := SelectorKey(i)
 		if !keySet[key] {
 			cc.SelectorMap[name] = key
+			cc.changed()
 			return key
 		}
 	}
 	key := SelectorKey(size + 1)
 	cc.SelectorMap[name] = key
+	cc.changed()
 	return key
 }
 
@@ -96,6 +101,8 @@ func sliceSelectorAvailable(cc *CompileConfig) bool {
 
 type SliceSelector struct {
 	Values []Value
+	// whether the values are set, all the values exist if it is nil
+	exist []bool
 }
 
 func NewSliceSelector(cc *CompileConfig, vals map[string]interface{}) SliceSelector {
@@ -109,34 +116,39 @@ func NewSliceSelector(cc *CompileConfig, vals map[string]interface{}) SliceSelec
 	size := max(len(cc.SelectorMap), maxKey+1)
 	sel := SliceSelector{
 		Values: make([]Value, size),
+		exist:  make([]bool, size),
 	}
 	for name, val := range vals {
 		key := cc.SelectorMap[name]
 		sel.Values[key] = unifyType(val)
+		sel.exist[key] = true
 	}
 	return sel
 }
 
 func (s SliceSelector) Get(key SelectorKey, _ string) (Value, error) {
-	if int(key) >= len(s.Values) {
-		return nil, fmt.Errorf("selectorKey not exist %d", key)
+	if !s.Cached(key, "") {
+		return nil, fmt.Errorf("%w %d", ErrSelectorNotExist, key)
 	}
 	return s.Values[key], nil
 }
 
 func (s SliceSelector) Set(key SelectorKey, _ string, val Value) error {
-	if int(key) >= len(s.Values) {
-		return fmt.Errorf("selectorKey not exist %d", key)
+	if key < 0 || int(key) >= len(s.Values) {
+		return fmt.Errorf("%w %d", ErrSelectorNotExist, key)
 	}
 	s.Values[key] = val
+	if s.exist != nil {
+		s.exist[key] = true
+	}
 	return nil
 }
 
 func (s SliceSelector) Cached(key SelectorKey, _ string) bool {
-	if int(key) >= len(s.Values) {
+	if key < 0 || int(key) >= len(s.Values) {
 		return false
 	}
-	return true
+	return s.exist == nil || s.exist[key]
 }
 
 type MapSelector struct {
@@ -156,7 +168,7 @@ func NewMapSelector(vals map[string]interface{}) MapSelector {
 func (s MapSelector) Get(_ SelectorKey, key string) (Value, error) {
 	val, exist := s.Values[key]
 	if !exist {
-		return nil, fmt.Errorf("selectorKey not exist %s", key)
+		return nil, fmt.Errorf("%w %s", ErrSelectorNotExist, key)
 	}
 	return val, nil
 }
@@ -170,3 +182,280 @@ func (s MapSelector) Cached(_ SelectorKey, key string) bool {
 	_, exist := s.Values[key]
 	return exist
 }
+
+// StringSelector is a simplified selector which gets values by the names of selectors only.
+// It can be converted to a Selector by NewStringSelector, the expression should be
+// compiled with EnableStringSelectors, so that no SelectorKey needs to be registered.
+type StringSelector interface {
+	Get(name string) (Value, error)
+}
+
+// SelectorFunc is an adapter to allow the use of ordinary functions as StringSelector
+type SelectorFunc func(name string) (Value, error)
+
+func (f SelectorFunc) Get(name string) (Value, error) {
+	return f(name)
+}
+
+type stringSelector struct {
+	sel StringSelector
+	// values set by Set, they take precedence over the values of sel
+	overrides map[string]Value
+}
+
+// NewStringSelector converts a StringSelector to a Selector
+func NewStringSelector(sel StringSelector) Selector {
+	return &stringSelector{
+		sel:       sel,
+		overrides: make(map[string]Value),
+	}
+}
+
+func (s *stringSelector) Get(_ SelectorKey, key string) (Value, error) {
+	if val, exist := s.overrides[key]; exist {
+		return val, nil
+	}
+	return s.sel.Get(key)
+}
+
+func (s *stringSelector) Set(_ SelectorKey, key string, val Value) error {
+	s.overrides[key] = val
+	return nil
+}
+
+func (s *stringSelector) Cached(_ SelectorKey, key string) bool {
+	_, exist := s.overrides[key]
+	return exist
+}
+
+// NewCtxWithStringSelector creates a Ctx with a StringSelector
+func NewCtxWithStringSelector(sel StringSelector) *Ctx {
+	return &Ctx{
+		Selector: NewStringSelector(sel),
+	}
+}
+
+type structSelector struct {
+	v Value
+	// values set by Set, they take precedence over the fields of v
+	overrides map[string]Value
+}
+
+// NewStructSelector creates a Selector of the fields of the struct or the pointer to it,
+// the selector names are the field names, or the dot-paths of the nested fields, e.g. Address.City.
+// The fields are matched by the `eval` tags, the `json` tags, or the field names in order,
+// and their indexes are cached by the struct types, so that no map conversion is needed.
+func NewStructSelector(v interface{}) Selector {
+	return &structSelector{
+		v:         v,
+		overrides: make(map[string]Value),
+	}
+}
+
+func (s *structSelector) Get(_ SelectorKey, key string) (Value, error) {
+	if val, exist := s.overrides[key]; exist {
+		return val, nil
+	}
+	v := s.v
+	for _, name := range strings.Split(key, fieldPathSeparator) {
+		var err error
+		if v, err = fieldOf(v, name); err != nil {
+			return nil, fmt.Errorf("%w %s, error: %v", ErrSelectorNotExist, key, err)
+		}
+	}
+	return unifyType(v), nil
+}
+
+func (s *structSelector) Set(_ SelectorKey, key string, val Value) error {
+	s.overrides[key] = val
+	return nil
+}
+
+func (s *structSelector) Cached(_ SelectorKey, key string) bool {
+	_, exist := s.overrides[key]
+	return exist
+}
+
+// NewCtxWithStruct creates a Ctx with the selector of the struct fields,
+// the expression should be compiled with EnableStringSelectors if the selectors are not registered
+func NewCtxWithStruct(v interface{}) *Ctx {
+	return &Ctx{
+		Selector: NewStructSelector(v),
+	}
+}
+
+// ConstSelector is a read-only Selector of the fixed values, e.g. the defaults of a tenant,
+// it's safe for concurrent use, so it can be shared by the evaluations as the fallback of ChainSelector
+type ConstSelector struct {
+	values map[string]Value
+}
+
+// NewConstSelector creates a ConstSelector of the values by their names
+func NewConstSelector(vals map[string]interface{}) ConstSelector {
+	return ConstSelector{values: ToValueMap(vals)}
+}
+
+func (s ConstSelector) Get(_ SelectorKey, key string) (Value, error) {
+	val, exist := s.values[key]
+	if !exist {
+		return nil, fmt.Errorf("%w %s", ErrSelectorNotExist, key)
+	}
+	return val, nil
+}
+
+func (s ConstSelector) Set(_ SelectorKey, key string, _ Value) error {
+	return fmt.Errorf("const selector is read-only, selector: %s", key)
+}
+
+func (s ConstSelector) Cached(_ SelectorKey, key string) bool {
+	_, exist := s.values[key]
+	return exist
+}
+
+type chainSelector []Selector
+
+// ChainSelector creates a Selector which gets the values from the selectors in order,
+// the fallback selectors are tried only if the former ones return ErrSelectorNotExist,
+// e.g. ChainSelector(request, session, NewConstSelector(tenantDefaults)).
+// The values are set to the primary selector.
+func ChainSelector(primary Selector, fallback ...Selector) Selector {
+	return append(chainSelector{primary}, fallback...)
+}
+
+func (c chainSelector) Get(selKey SelectorKey, strKey string) (res Value, err error) {
+	for _, sel := range c {
+		res, err = sel.Get(selKey, strKey)
+		if err == nil || !errors.Is(err, ErrSelectorNotExist) {
+			return res, err
+		}
+	}
+	return nil, err
+}
+
+func (c chainSelector) Set(selKey SelectorKey, strKey string, val Value) error {
+	return c[0].Set(selKey, strKey, val)
+}
+
+func (c chainSelector) Cached(selKey SelectorKey, strKey string) bool {
+	for _, sel := range c {
+		if sel.Cached(selKey, strKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// Selectors returns the names of the selectors referenced by the expression,
+// each name is reported only once, in the order of the compiled nodes.
+// It can be used to prefetch the required values before the evaluation.
+// The selectors read by the lambdas are included, except their params.
+func (e *Expr) Selectors() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, n := range e.freeSelectorNodes() {
+		name := n.value.(string)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// SelectorKeys returns the keys of the selectors referenced by the expression,
+// the unregistered selectors are reported as UndefinedSelKey.
+func (e *Expr) SelectorKeys() []SelectorKey {
+	var keys []SelectorKey
+	seen := make(map[SelectorKey]bool)
+	for _, n := range e.freeSelectorNodes() {
+		if !seen[n.selKey] {
+			seen[n.selKey] = true
+			keys = append(keys, n.selKey)
+		}
+	}
+	return keys
+}
+
+func (e *Expr) selectorNodes() []*node {
+	size := len(e.nodes)
+	if size != 0 && e.nodes[0].getNodeType() == debug {
+		size /= 2
+	}
+
+	var res []*node
+	for i := 0; i < size; i++ {
+		if n := e.getNode(i); n.getNodeType() == selector {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// hasRepeatedSelectors reports whether any selector is referenced more than once
+func hasRepeatedSelectors(e *Expr) bool {
+	seen := make(map[string]bool)
+	for _, n := range e.selectorNodes() {
+		name := n.value.(string)
+		if seen[name] {
+			return true
+		}
+		seen[name] = true
+	}
+	return false
+}
+
+// memoSelector caches the values read from the selector within one evaluation,
+// the errors are not cached.
+type memoSelector struct {
+	Selector
+	entries []memoEntry
+}
+
+type memoEntry struct {
+	selKey SelectorKey
+	strKey string
+	val    Value
+}
+
+func newMemoCtx(ctx *Ctx) *Ctx {
+	return &Ctx{
+		Selector: &memoSelector{Selector: ctx.Selector},
+		Ctx:      ctx.Ctx,
+	}
+}
+
+func (m *memoSelector) Get(selKey SelectorKey, strKey string) (Value, error) {
+	if i := m.find(selKey, strKey); i >= 0 {
+		return m.entries[i].val, nil
+	}
+	val, err := m.Selector.Get(selKey, strKey)
+	if err != nil {
+		return nil, err
+	}
+	m.entries = append(m.entries, memoEntry{selKey: selKey, strKey: strKey, val: val})
+	return val, nil
+}
+
+func (m *memoSelector) Set(selKey SelectorKey, strKey string, val Value) error {
+	if err := m.Selector.Set(selKey, strKey, val); err != nil {
+		return err
+	}
+	if i := m.find(selKey, strKey); i >= 0 {
+		m.entries[i].val = val
+	}
+	return nil
+}
+
+func (m *memoSelector) Cached(selKey SelectorKey, strKey string) bool {
+	return m.find(selKey, strKey) >= 0 || m.Selector.Cached(selKey, strKey)
+}
+
+// find returns the index of the cached entry, the unregistered selectors are matched by strKey
+func (m *memoSelector) find(selKey SelectorKey, strKey string) int {
+	for i, entry := range m.entries {
+		if entry.selKey == selKey && (selKey != UndefinedSelKey || entry.strKey == strKey) {
+			return i
+		}
+	}
+	return -1
+}
@@ -1 +1,455 @@
 package eval
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStringSelector(t *testing.T) {
+	user := map[string]interface{}{
+		"age":     20,
+		"country": "US",
+	}
+
+	var reads []string
+	sel := SelectorFunc(func(name string) (Value, error) {
+		reads = append(reads, name)
+		v, exist := user[name]
+		if !exist {
+			return nil, fmt.Errorf("field not found: %s", name)
+		}
+		return v, nil
+	})
+
+	cc := NewCompileConfig(EnableStringSelectors, EnableInfixSyntax)
+	expr, err := Compile(cc, `age > 18 && country == "US"`)
+	assertNil(t, err)
+
+	ctx := NewCtxWithStringSelector(sel)
+	res, err := expr.EvalBool(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	assertEquals(t, reads, []string{"age", "country"})
+
+	// the values set to the selector take precedence
+	assertEquals(t, ctx.Cached(UndefinedSelKey, "age"), false)
+	assertNil(t, ctx.Set(UndefinedSelKey, "age", int64(16)))
+	assertEquals(t, ctx.Cached(UndefinedSelKey, "age"), true)
+	res, err = expr.EvalBool(ctx)
+	assertNil(t, err)
+	assertEquals(t, res, false)
+
+	expr, err = Compile(cc, `gender == "male"`)
+	assertNil(t, err)
+	_, err = expr.Eval(ctx)
+	assertErrStrContains(t, err, "field not found: gender")
+
+	failed := SelectorFunc(func(name string) (Value, error) {
+		return nil, errors.New("store unavailable")
+	})
+	_, err = expr.Eval(NewCtxWithStringSelector(failed))
+	assertErrStrContains(t, err, "store unavailable")
+}
+
+func TestExpr_Selectors(t *testing.T) {
+	cc := NewCompileConfig(RegisterSelKeys(map[string]interface{}{
+		"age":     20,
+		"country": "US",
+		"vip":     true,
+	}))
+	debugCC := CopyCompileConfig(cc)
+	debugCC.CompileOptions[Debug] = true
+
+	testCases := []struct {
+		expr  string
+		names []string
+		keys  []SelectorKey
+	}{
+		{
+			expr:  `(> 2 1)`,
+			names: nil,
+			keys:  nil,
+		},
+		{
+			expr:  `age`,
+			names: []string{"age"},
+			keys:  []SelectorKey{cc.SelectorMap["age"]},
+		},
+		{
+			expr:  `(and (> age 18) (or vip (= country "US")) (< age 60))`,
+			names: []string{"age", "vip", "country"},
+			keys:  []SelectorKey{cc.SelectorMap["age"], cc.SelectorMap["vip"], cc.SelectorMap["country"]},
+		},
+		{
+			expr:  `(if vip (+ age 1) (- age 1))`,
+			names: []string{"vip", "age"},
+			keys:  []SelectorKey{cc.SelectorMap["vip"], cc.SelectorMap["age"]},
+		},
+	}
+
+	for _, c := range testCases {
+		for _, conf := range []*CompileConfig{cc, debugCC} {
+			expr, err := Compile(conf, c.expr)
+			assertNil(t, err)
+			assertEquals(t, expr.Selectors(), c.names, c.expr)
+			assertEquals(t, expr.SelectorKeys(), c.keys, c.expr)
+		}
+	}
+
+	// the unregistered selectors
+	cc = NewCompileConfig(EnableStringSelectors)
+	expr, err := Compile(cc, `(and (> age 18) (= country "US"))`)
+	assertNil(t, err)
+	assertEquals(t, expr.Selectors(), []string{"age", "country"})
+	assertEquals(t, expr.SelectorKeys(), []SelectorKey{UndefinedSelKey})
+}
+
+func TestFieldSelector(t *testing.T) {
+	type Address struct {
+		City    string `json:"city"`
+		ZipCode int    `eval:"zip" json:"zip_code"`
+	}
+	type Base struct {
+		ID int64
+	}
+	type User struct {
+		Base
+		Name    string
+		Age     int
+		Address *Address
+		Tags    []string
+		Extra   map[string]interface{}
+		secret  string
+	}
+
+	user := &User{
+		Base:    Base{ID: 42},
+		Name:    "Alice",
+		Age:     20,
+		Address: &Address{City: "Paris", ZipCode: 75001},
+		Tags:    []string{"vip"},
+		Extra:   map[string]interface{}{"level": 3, "nested": map[string]interface{}{"ok": true}},
+		secret:  "s",
+	}
+	vals := map[string]interface{}{
+		"user":      user,
+		"nobody":    (*User)(nil),
+		"user.name": "registered",
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals))
+
+	testCases := []struct {
+		expr   string
+		want   Value
+		errMsg string
+	}{
+		{expr: `user.Name`, want: "Alice"},
+		{expr: `user.Age`, want: int64(20)},
+		{expr: `user.ID`, want: int64(42)},
+		{expr: `user.Address.city`, want: "Paris"},
+		{expr: `user.Address.zip`, want: int64(75001)},
+		{expr: `user.Tags`, want: []string{"vip"}},
+		{expr: `user.Extra.level`, want: int64(3)},
+		{expr: `user.Extra.nested.ok`, want: true},
+		{expr: `(and (> user.Age 18) (= user.Address.city "Paris"))`, want: true},
+		{expr: `(field user "Address" "city")`, want: "Paris"},
+		// the registered dot-path is a plain selector
+		{expr: `user.name`, want: "registered"},
+		{expr: `user.Address.City`, errMsg: "field not found: City"},
+		{expr: `user.secret`, errMsg: "field not found: secret"},
+		{expr: `user.Name.first`, errMsg: "field first is not accessible on type: string"},
+		{expr: `nobody.Name`, errMsg: "nil value, field: Name"},
+		{expr: `user.Extra.missing`, errMsg: "field not found: missing"},
+		{expr: `guest.Name`, errMsg: "unknown token error"},
+	}
+
+	for _, c := range testCases {
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.want, c.expr)
+	}
+
+	expr, err := Compile(cc, `(= user.Address.city "Paris")`)
+	assertNil(t, err)
+	assertEquals(t, expr.Decompile(), `(= (field user "Address" "city") "Paris")`)
+	assertEquals(t, expr.Selectors(), []string{"user"})
+
+	// the infix syntax
+	infix := CopyCompileConfig(cc)
+	infix.SyntaxMode = InfixSyntax
+	res, err := Eval(`user.Age >= 18 && user.Extra.level + 1 == 4`, vals, infix)
+	assertNil(t, err)
+	assertEquals(t, res, true)
+
+	// the unknown selectors
+	res, err = Eval(`guest.Name`, map[string]interface{}{"guest": user}, NewCompileConfig(EnableStringSelectors))
+	assertNil(t, err)
+	assertEquals(t, res, "Alice")
+}
+
+func TestStructSelector(t *testing.T) {
+	type Address struct {
+		City string `json:"city"`
+	}
+	type Request struct {
+		UserID  int64  `eval:"user_id" json:"uid"`
+		Country string `json:"country"`
+		Amount  float32
+		Tags    []string
+		Address *Address
+		Created time.Time
+		secret  string
+	}
+	req := &Request{
+		UserID:  42,
+		Country: "US",
+		Amount:  9.5,
+		Tags:    []string{"vip"},
+		Address: &Address{City: "Paris"},
+		Created: time.Unix(1700000000, 0),
+		secret:  "s",
+	}
+
+	cc := NewCompileConfig(EnableStringSelectors)
+	GetOrRegisterKey(cc, "Address.city")
+
+	testCases := []struct {
+		expr   string
+		want   Value
+		errMsg string
+	}{
+		{expr: `user_id`, want: int64(42)},
+		{expr: `(and (= country "US") (> Amount 9))`, want: true},
+		{expr: `(in "vip" Tags)`, want: true},
+		{expr: `Address.city`, want: "Paris"},
+		{expr: `(= (field Address "city") "Paris")`, want: true},
+		{expr: `Created`, want: int64(1700000000)},
+		{expr: `uid`, errMsg: "selectorKey not exist uid, error: field not found: uid"},
+		{expr: `secret`, errMsg: "field not found: secret"},
+	}
+
+	for _, c := range testCases {
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err, c.expr)
+		res, err := expr.Eval(NewCtxWithStruct(req))
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.want, c.expr)
+	}
+
+	// the struct value and the overridden values
+	sel := NewStructSelector(*req)
+	res, err := sel.Get(UndefinedSelKey, "country")
+	assertNil(t, err)
+	assertEquals(t, res, "US")
+	assertEquals(t, sel.Cached(UndefinedSelKey, "country"), false)
+	assertNil(t, sel.Set(UndefinedSelKey, "country", "FR"))
+	assertEquals(t, sel.Cached(UndefinedSelKey, "country"), true)
+	res, err = sel.Get(UndefinedSelKey, "country")
+	assertNil(t, err)
+	assertEquals(t, res, "FR")
+
+	_, err = NewStructSelector((*Request)(nil)).Get(UndefinedSelKey, "country")
+	assertErrStrContains(t, err, "nil value, field: country")
+}
+
+func TestChainSelector(t *testing.T) {
+	tenant := NewConstSelector(map[string]interface{}{
+		"country":  "US",
+		"limit":    100,
+		"currency": "USD",
+	})
+	cc := NewCompileConfig(EnableStringSelectors)
+
+	testCases := []struct {
+		expr   string
+		want   Value
+		errMsg string
+	}{
+		{expr: `user`, want: "larry"},
+		{expr: `country`, want: "FR"},
+		{expr: `limit`, want: int64(100)},
+		{expr: `(format "%s:%s" currency country)`, want: "USD:FR"},
+		{expr: `(> (+ limit tier) 100)`, want: true},
+		{expr: `missing`, errMsg: "selectorKey not exist missing"},
+		{expr: `broken`, errMsg: "session unavailable"},
+	}
+
+	for _, c := range testCases {
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err, c.expr)
+
+		request := NewMapSelector(map[string]interface{}{"user": "larry", "country": "FR"})
+		session := SelectorFunc(func(name string) (Value, error) {
+			switch name {
+			case "tier":
+				return int64(2), nil
+			case "broken":
+				return nil, errors.New("session unavailable")
+			}
+			return nil, fmt.Errorf("%w %s", ErrSelectorNotExist, name)
+		})
+		res, err := expr.Eval(&Ctx{Selector: ChainSelector(request, NewStringSelector(session), tenant)})
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.want, c.expr)
+	}
+
+	// the values are set to the primary selector
+	request := NewMapSelector(map[string]interface{}{})
+	chain := ChainSelector(request, tenant)
+	assertEquals(t, chain.Cached(UndefinedSelKey, "country"), true)
+	assertEquals(t, chain.Cached(UndefinedSelKey, "user"), false)
+	assertNil(t, chain.Set(UndefinedSelKey, "country", "FR"))
+	res, err := chain.Get(UndefinedSelKey, "country")
+	assertNil(t, err)
+	assertEquals(t, res, "FR")
+	res, err = tenant.Get(UndefinedSelKey, "country")
+	assertNil(t, err)
+	assertEquals(t, res, "US")
+
+	assertErrStrContains(t, tenant.Set(UndefinedSelKey, "country", "FR"), "const selector is read-only, selector: country")
+}
+
+func TestSelectorMemoization(t *testing.T) {
+	const exprStr = `(and (> score 10) (< score 100) (!= score 50) (= name "a"))`
+	vals := map[string]interface{}{"score": 20, "name": "a"}
+
+	testCases := []struct {
+		opts []CompileOption
+		cnt  int
+	}{
+		{opts: nil, cnt: 4},
+		{opts: []CompileOption{EnableSelectorMemoization}, cnt: 2},
+		{opts: []CompileOption{EnableSelectorMemoization, Optimizations(false)}, cnt: 2},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, EnableStringSelectors)...)
+		expr, err := Compile(cc, exprStr)
+		assertNil(t, err)
+
+		// the cache is reset for each evaluation
+		for i := 0; i < 2; i++ {
+			sel := &countingSelector{MapSelector: NewMapSelector(vals)}
+			res, err := expr.EvalBool(&Ctx{Selector: sel})
+			assertNil(t, err)
+			assertEquals(t, res, true)
+			assertEquals(t, sel.cnt, c.cnt, c.opts)
+		}
+	}
+}
+
+func TestMissingSelectors(t *testing.T) {
+	vals := map[string]interface{}{"age": 20, "country": "US"}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(> score 10)`, errMsg: "selectorKey not exist"},
+		{expr: `(> score 10)`, opts: []CompileOption{SelectorDefault("score", 0)}, res: false},
+		{expr: `(+ score age)`, opts: []CompileOption{SelectorDefault("score", 5)}, res: int64(25)},
+		{expr: `(> score 10)`, opts: []CompileOption{OnMissingSelector(MissingSelectorFalse)}, res: false},
+		{expr: `(!= score 10)`, opts: []CompileOption{OnMissingSelector(MissingSelectorFalse)}, res: false},
+		{expr: `(not (> score 10))`, opts: []CompileOption{OnMissingSelector(MissingSelectorFalse)}, res: true},
+		{expr: `(> (+ score 1) 10)`, opts: []CompileOption{OnMissingSelector(MissingSelectorFalse)}, res: false},
+		{expr: `(in score (1 2))`, opts: []CompileOption{OnMissingSelector(MissingSelectorFalse)}, res: false},
+		{expr: `(or (> score 10) (= country "US"))`, opts: []CompileOption{OnMissingSelector(MissingSelectorFalse)}, res: true},
+		{expr: `(and (> age 18) (= score 1))`, opts: []CompileOption{OnMissingSelector(MissingSelectorFalse)}, res: false},
+		{expr: `(+ score 1)`, opts: []CompileOption{OnMissingSelector(MissingSelectorFalse)}, errMsg: "selectorKey not exist"},
+		{expr: `(if vip 1 2)`, opts: []CompileOption{OnMissingSelector(MissingSelectorFalse)}, errMsg: "selectorKey not exist"},
+		{
+			expr: `(and (> score 10) (= tier "gold"))`,
+			opts: []CompileOption{OnMissingSelector(MissingSelectorFalse), SelectorDefault("score", 100)},
+			res:  false,
+		},
+	}
+
+	backends := []struct {
+		name string
+		opts []CompileOption
+	}{
+		{name: "bytecode"},
+		{name: "closure", opts: []CompileOption{func(c *CompileConfig) { c.Backend = ClosureBackend }}},
+		{name: "debug", opts: []CompileOption{EnableDebug, func(c *CompileConfig) { c.DebugWriter = io.Discard }}},
+	}
+
+	for _, c := range testCases {
+		for _, b := range backends {
+			cc := NewCompileConfig(append(c.opts, b.opts...)...)
+			for _, name := range []string{"age", "country", "score", "tier", "vip"} {
+				GetOrRegisterKey(cc, name)
+			}
+			expr, err := Compile(cc, c.expr)
+			assertNil(t, err, c.expr)
+
+			for _, ctx := range []*Ctx{NewCtxWithMap(cc, vals), {Selector: NewMapSelector(vals)}} {
+				res, err := expr.Eval(ctx)
+				if c.errMsg != "" {
+					assertErrStrContains(t, err, c.errMsg, c.expr, b.name)
+					continue
+				}
+				assertNil(t, err, c.expr, b.name)
+				assertEquals(t, res, c.res, c.expr, b.name)
+			}
+		}
+	}
+}
+
+func TestMissingSelectors_Expr(t *testing.T) {
+	cc := NewCompileConfig(OnMissingSelector(MissingSelectorFalse), SelectorDefault("tier", "gold"),
+		RegisterSelKeys(map[string]interface{}{"age": 0, "country": "", "tier": ""}))
+	expr, err := Compile(cc, `(and (> age 18) (= tier "gold") (!= country "US"))`)
+	assertNil(t, err)
+	ctx := NewCtxWithMap(cc, map[string]interface{}{"age": 20})
+
+	// the policy is kept by the derived expressions
+	bs, err := expr.Marshal()
+	assertNil(t, err)
+	loaded, err := UnmarshalExpr(bs, cc)
+	assertNil(t, err)
+	partial, err := expr.PartialEval(map[SelectorKey]Value{cc.SelectorMap["age"]: 20})
+	assertNil(t, err)
+
+	for _, e := range []*Expr{expr, loaded, partial} {
+		res, err := e.Eval(ctx)
+		assertNil(t, err)
+		assertEquals(t, res, false)
+
+		res, trace, err := e.EvalBoolWithTrace(ctx)
+		assertNil(t, err)
+		assertEquals(t, res, false)
+		assertEquals(t, trace.Children[len(trace.Children)-1].Value, false)
+	}
+
+	// the custom selectors wrap ErrSelectorNotExist
+	sel := SelectorFunc(func(name string) (Value, error) {
+		return nil, fmt.Errorf("%w: %s", ErrSelectorNotExist, name)
+	})
+	res, err := expr.Eval(NewCtxWithStringSelector(sel))
+	assertNil(t, err)
+	assertEquals(t, res, false)
+
+	// the other errors are not handled
+	failed := SelectorFunc(func(name string) (Value, error) {
+		return nil, errors.New("store unavailable")
+	})
+	_, err = expr.Eval(NewCtxWithStringSelector(failed))
+	assertErrStrContains(t, err, "store unavailable")
+}
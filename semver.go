@@ -0,0 +1,361 @@
+package eval
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver is a semantic version of https://semver.org, e.g. 1.2.3-beta.1+build.5,
+// the build metadata is ignored in the comparisons
+type semver struct {
+	major, minor, patch uint64
+	pre                 []string
+}
+
+// parseSemver parses the version, the leading v and the missing minor and patch are allowed,
+// e.g. "v1.2.3", "1.2" => 1.2.0, "2.0.0-rc.1"
+func parseSemver(s string) (semver, error) {
+	v, _, wildcard, err := parsePartialSemver(s)
+	if err != nil {
+		return semver{}, err
+	}
+	if wildcard {
+		return semver{}, fmt.Errorf("invalid semver: %q", s)
+	}
+	return v, nil
+}
+
+// parsePartialSemver parses the version whose trailing parts may be missing or the wildcards x, X or *,
+// parts is the number of the numeric parts before the wildcards or the end, e.g. "1.x" and "1" are 1
+func parsePartialSemver(s string) (v semver, parts int, wildcard bool, err error) {
+	invalid := fmt.Errorf("invalid semver: %q", s)
+	core := strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(core, '+'); i != -1 {
+		core = core[:i]
+	}
+	if i := strings.IndexByte(core, '-'); i != -1 {
+		if v.pre, err = parsePrerelease(core[i+1:]); err != nil {
+			return semver{}, 0, false, err
+		}
+		core = core[:i]
+	}
+
+	nums := strings.Split(core, ".")
+	if len(nums) > 3 {
+		return semver{}, 0, false, invalid
+	}
+	dst := []*uint64{&v.major, &v.minor, &v.patch}
+	for i, n := range nums {
+		if n == "x" || n == "X" || n == "*" {
+			if i != len(nums)-1 || v.pre != nil {
+				return semver{}, 0, false, invalid
+			}
+			return v, i, true, nil
+		}
+		if !isNumericIdent(n) {
+			return semver{}, 0, false, invalid
+		}
+		if *dst[i], err = strconv.ParseUint(n, 10, 64); err != nil {
+			return semver{}, 0, false, invalid
+		}
+	}
+	if len(nums) < 3 && v.pre != nil {
+		return semver{}, 0, false, invalid
+	}
+	return v, len(nums), false, nil
+}
+
+func parsePrerelease(s string) ([]string, error) {
+	ids := strings.Split(s, ".")
+	for _, id := range ids {
+		if id == "" || strings.Trim(id, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ-") != "" {
+			return nil, fmt.Errorf("invalid prerelease: %q", s)
+		}
+		if strings.Trim(id, "0123456789") == "" && !isNumericIdent(id) {
+			return nil, fmt.Errorf("invalid prerelease: %q", s)
+		}
+	}
+	return ids, nil
+}
+
+// isNumericIdent reports whether s is a number without the leading zeros
+func isNumericIdent(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == "" && (s == "0" || s[0] != '0')
+}
+
+// compare returns -1, 0 or 1 by the precedence of the versions,
+// the prerelease versions have lower precedence than the normal ones, e.g. 1.0.0-rc.1 < 1.0.0
+func (v semver) compare(x semver) int {
+	for _, c := range [][2]uint64{{v.major, x.major}, {v.minor, x.minor}, {v.patch, x.patch}} {
+		if c[0] != c[1] {
+			return cmpOrder(c[0] < c[1])
+		}
+	}
+
+	switch {
+	case len(v.pre) == 0 && len(x.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(x.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(x.pre); i++ {
+		a, b := v.pre[i], x.pre[i]
+		if a == b {
+			continue
+		}
+		numA, numB := isNumericIdent(a), isNumericIdent(b)
+		switch {
+		case numA && numB:
+			if len(a) != len(b) {
+				return cmpOrder(len(a) < len(b))
+			}
+			return cmpOrder(a < b)
+		case numA || numB:
+			// the numeric identifiers have lower precedence than the alphanumeric ones
+			return cmpOrder(numA)
+		}
+		return cmpOrder(a < b)
+	}
+	if len(v.pre) != len(x.pre) {
+		return cmpOrder(len(v.pre) < len(x.pre))
+	}
+	return 0
+}
+
+// cmpOrder returns -1 if less is true, otherwise 1
+func cmpOrder(less bool) int {
+	if less {
+		return -1
+	}
+	return 1
+}
+
+// semverComparator is a comparison with a version, e.g. >=1.2.0
+type semverComparator struct {
+	op string
+	v  semver
+}
+
+func (c semverComparator) matches(v semver) bool {
+	r := v.compare(c.v)
+	switch c.op {
+	case "=":
+		return r == 0
+	case "!=":
+		return r != 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "<":
+		return r < 0
+	}
+	return r <= 0
+}
+
+// semverConstraint is the union of the ranges, each of which is the intersection of the comparators
+type semverConstraint [][]semverComparator
+
+// parseSemverConstraint parses the constraint, e.g. ">=1.2.0 <2.0.0 || ^3.1", the comparators
+// separated by the spaces or commas are intersected, and the ranges separated by || are united.
+// The operators are =, !=, >, >=, <, <=, ~ and ^, e.g. ~1.2.3 is >=1.2.3 <1.3.0, ^1.2.3 is >=1.2.3 <2.0.0,
+// and ^0.2.3 is >=0.2.3 <0.3.0. The versions without operators match the same versions or the wildcards,
+// e.g. "1.2" and "1.2.x" are >=1.2.0 <1.3.0, and "*" matches all the versions.
+func parseSemverConstraint(s string) (semverConstraint, error) {
+	var res semverConstraint
+	for _, union := range strings.Split(s, "||") {
+		var comparators []semverComparator
+		fields := strings.FieldsFunc(union, func(r rune) bool { return r == ' ' || r == ',' })
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			if strings.Trim(field, semverOperators) == "" && i+1 < len(fields) {
+				// the operator separated from the version, e.g. ">= 1.2.0"
+				field, i = field+fields[i+1], i+1
+			}
+			cs, err := parseSemverComparator(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid semver constraint: %q, error: %w", s, err)
+			}
+			comparators = append(comparators, cs...)
+		}
+		if len(comparators) == 0 && strings.TrimSpace(s) != "" {
+			return nil, fmt.Errorf("invalid semver constraint: %q, error: empty range", s)
+		}
+		res = append(res, comparators)
+	}
+	return res, nil
+}
+
+const semverOperators = "=!<>~^"
+
+func parseSemverComparator(s string) ([]semverComparator, error) {
+	op := s[:len(s)-len(strings.TrimLeft(s, semverOperators))]
+	v, parts, _, err := parsePartialSemver(s[len(op):])
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "", "=", "==":
+		if parts == 3 {
+			return []semverComparator{{op: "=", v: v}}, nil
+		}
+		return wildcardRange(v, parts), nil
+	case "!=", ">", ">=", "<", "<=":
+		// the missing parts are zeros
+		return []semverComparator{{op: op, v: v}}, nil
+	case "~":
+		if parts == 3 {
+			parts = 2
+		}
+		return append([]semverComparator{{op: ">=", v: v}}, wildcardRange(v, parts)[1:]...), nil
+	case "^":
+		// the first non-zero part can't be changed
+		switch {
+		case v.major != 0 || parts <= 1:
+			parts = 1
+		case v.minor != 0 || parts == 2:
+			parts = 2
+		}
+		return append([]semverComparator{{op: ">=", v: v}}, wildcardRange(v, parts)[1:]...), nil
+	}
+	return nil, fmt.Errorf("invalid operator: %q", op)
+}
+
+// wildcardRange returns the range of the versions whose first parts are the same as v, e.g. 1.2.x => >=1.2.0 <1.3.0
+func wildcardRange(v semver, parts int) []semverComparator {
+	lo := semver{major: v.major, minor: v.minor, patch: v.patch}
+	hi := lo
+	switch parts {
+	case 0:
+		return []semverComparator{{op: ">=", v: semver{}}}
+	case 1:
+		lo.minor, lo.patch = 0, 0
+		hi = semver{major: v.major + 1}
+	case 2:
+		lo.patch = 0
+		hi = semver{major: v.major, minor: v.minor + 1}
+	default:
+		hi = semver{major: v.major, minor: v.minor, patch: v.patch + 1}
+	}
+	// the lowest prerelease of hi is excluded
+	hi.pre = []string{"0"}
+	return []semverComparator{{op: ">=", v: lo}, {op: "<", v: hi}}
+}
+
+func (c semverConstraint) matches(v semver) bool {
+	for _, comparators := range c {
+		matched := true
+		for _, comparator := range comparators {
+			if !comparator.matches(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// semverConstraintCache caches the semver constraints which are not constants
+var semverConstraintCache = newLRUCache(1024)
+
+func compileSemverConstraint(s string) (semverConstraint, error) {
+	if c, exist := semverConstraintCache.get(s); exist {
+		return c.(semverConstraint), nil
+	}
+	c, err := parseSemverConstraint(s)
+	if err != nil {
+		return nil, err
+	}
+	semverConstraintCache.add(s, c)
+	return c, nil
+}
+
+func semverParams(op string, params []Value) ([]string, error) {
+	if len(params) != 2 {
+		return nil, ParamsCountError(op, 2, len(params))
+	}
+	res := make([]string, len(params))
+	for i, p := range params {
+		s, ok := p.(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, p)
+		}
+		res[i] = s
+	}
+	return res, nil
+}
+
+// semverCompare returns -1, 0 or 1 by the precedence of the semantic versions,
+// e.g. (semverCompare "1.2.0" "1.10.0") is -1
+func semverCompare(_ *Ctx, params []Value) (Value, error) {
+	const op = "semverCompare"
+	strs, err := semverParams(op, params)
+	if err != nil {
+		return nil, err
+	}
+	x, err := parseSemver(strs[0])
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	y, err := parseSemver(strs[1])
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	return int64(x.compare(y)), nil
+}
+
+// semverSatisfies reports whether the semantic version satisfies the constraint,
+// e.g. (semverSatisfies version ">=1.2.0 <2.0.0"), see parseSemverConstraint for the syntax
+func semverSatisfies(_ *Ctx, params []Value) (Value, error) {
+	const op = "semverSatisfies"
+	strs, err := semverParams(op, params)
+	if err != nil {
+		return nil, err
+	}
+	c, err := compileSemverConstraint(strs[1])
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	v, err := parseSemver(strs[0])
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	return c.matches(v), nil
+}
+
+// specializeSemverSatisfies parses the constant constraint only once at compile time
+func specializeSemverSatisfies(consts []Value, known []bool) (Operator, error) {
+	const op = "semverSatisfies"
+	if len(consts) != 2 || !known[1] {
+		return nil, nil
+	}
+	s, ok := consts[1].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, consts[1])
+	}
+	c, err := parseSemverConstraint(s)
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	return func(_ *Ctx, params []Value) (Value, error) {
+		if len(params) != 2 {
+			return nil, ParamsCountError(op, 2, len(params))
+		}
+		s, ok := params[0].(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, params[0])
+		}
+		v, err := parseSemver(s)
+		if err != nil {
+			return nil, OpExecError(op, err)
+		}
+		return c.matches(v), nil
+	}, nil
+}
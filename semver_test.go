@@ -0,0 +1,80 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestSemver(t *testing.T) {
+	vals := map[string]interface{}{
+		"appVersion": "1.4.2",
+		"beta":       "2.0.0-beta.2",
+		"legacy":     "v0.9",
+		"constraint": "^1.2.0",
+		"build":      7,
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(semverCompare appVersion "1.10.0")`, res: int64(-1)},
+		{expr: `(semverCompare appVersion "1.4.2+build.5")`, res: int64(0)},
+		{expr: `(semverCompare beta "2.0.0")`, res: int64(-1)},
+		{expr: `(semverCompare beta "2.0.0-beta.11")`, res: int64(-1)},
+		{expr: `(semverCompare "2.0.0-beta" "2.0.0-alpha.1")`, res: int64(1)},
+		{expr: `(semverCompare "1.0.0-rc.1" "1.0.0-1")`, res: int64(1)},
+		{expr: `(semverCompare "1.0.0-alpha" "1.0.0-alpha.1")`, res: int64(-1)},
+		{expr: `(semverCompare legacy "0.9.0")`, res: int64(0)},
+		{expr: `(semverSatisfies appVersion ">=1.2.0 <2.0.0")`, res: true},
+		{expr: `(semverSatisfies appVersion ">= 1.2.0, < 1.4.2")`, res: false},
+		{expr: `(semverSatisfies appVersion "1.4.2")`, res: true},
+		{expr: `(semverSatisfies appVersion "=1.4.1")`, res: false},
+		{expr: `(semverSatisfies appVersion "!=1.4.1")`, res: true},
+		{expr: `(semverSatisfies appVersion "1.4")`, res: true},
+		{expr: `(semverSatisfies appVersion "1.x")`, res: true},
+		{expr: `(semverSatisfies appVersion "1.3.x")`, res: false},
+		{expr: `(semverSatisfies appVersion "*")`, res: true},
+		{expr: `(semverSatisfies appVersion "~1.4.0")`, res: true},
+		{expr: `(semverSatisfies appVersion "~1.3.0")`, res: false},
+		{expr: `(semverSatisfies appVersion "^1.2.0")`, res: true},
+		{expr: `(semverSatisfies appVersion "^0.1.0 || ^1.4.3")`, res: false},
+		{expr: `(semverSatisfies appVersion "<1.0.0 || >=1.4.0")`, res: true},
+		{expr: `(semverSatisfies appVersion constraint)`, res: true},
+		{expr: `(semverSatisfies "0.2.5" "^0.2.3")`, res: true},
+		{expr: `(semverSatisfies "0.3.0" "^0.2.3")`, res: false},
+		{expr: `(semverSatisfies "0.0.4" "^0.0.3")`, res: false},
+		{expr: `(semverSatisfies beta "^1.2.0")`, res: false},
+		{expr: `(semverSatisfies beta ">=2.0.0-beta.1")`, res: true},
+		{expr: `(semverSatisfies legacy "<1")`, res: true},
+		{expr: `(semverSatisfies appVersion "^1.2.0")`, opts: []CompileOption{EnableTypeCheck}, res: true},
+		{expr: `(semverSatisfies appVersion "^1.2.0")`, opts: []CompileOption{Optimizations(false)}, res: true},
+
+		{expr: `(semverCompare appVersion "1.2.3.4")`, errMsg: `invalid semver: "1.2.3.4"`},
+		{expr: `(semverCompare "01.2.3" appVersion)`, errMsg: `invalid semver: "01.2.3"`},
+		{expr: `(semverCompare "1.x" appVersion)`, errMsg: `invalid semver: "1.x"`},
+		{expr: `(semverCompare "1.0.0-beta..1" appVersion)`, errMsg: `invalid prerelease: "beta..1"`},
+		{expr: `(semverCompare appVersion build)`, errMsg: "unexpected param type, operator: semverCompare, expected: string"},
+		{expr: `(semverSatisfies appVersion "=>1.2.0")`, errMsg: `invalid operator: "=>"`},
+		{expr: `(semverSatisfies appVersion "^1.2.0 ||")`, errMsg: "empty range"},
+		{expr: `(semverSatisfies "1.2.x" "^1.2.0")`, errMsg: `invalid semver: "1.2.x"`},
+		{expr: `(semverSatisfies appVersion)`, errMsg: "unexpected params count, operator: semverSatisfies, expected: 2, got: 1"},
+		{expr: `(semverSatisfies 7 "^1.2.0")`, opts: []CompileOption{EnableTypeCheck}, errMsg: "type check error"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+
+	// the invalid constant constraints fail the compilation
+	_, err := Compile(NewCompileConfig(RegisterSelKeys(vals)), `(semverSatisfies appVersion ">=1.2.0 <2.a")`)
+	assertErrStrContains(t, err, `invalid semver constraint: ">=1.2.0 <2.a"`)
+}
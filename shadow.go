@@ -0,0 +1,66 @@
+package eval
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Mismatch is reported by the Shadow if the results of the primary and the shadow expressions differ
+type Mismatch struct {
+	// Ctx is the ctx the expressions are evaluated against
+	Ctx *Ctx
+
+	Primary    Value
+	PrimaryErr error
+	Shadow     Value
+	ShadowErr  error
+}
+
+// Shadow evaluates a shadow version of the primary expression, e.g. a rewritten rule,
+// against the same ctx, and reports the mismatches of their results,
+// while only the primary result is returned. The selectors are read by both of them.
+//
+// The results match if both of them fail, or both of them succeed with the deeply equal values.
+// The panics of the shadow expression are recovered and reported as its errors.
+type Shadow struct {
+	primary    *Expr
+	shadow     *Expr
+	onMismatch func(m Mismatch)
+}
+
+// NewShadow creates a Shadow, onMismatch should be safe for concurrent use
+// if the Shadow is evaluated concurrently
+func NewShadow(primary, shadow *Expr, onMismatch func(m Mismatch)) *Shadow {
+	return &Shadow{primary: primary, shadow: shadow, onMismatch: onMismatch}
+}
+
+// Eval evaluates both of the expressions, and returns the result of the primary one
+func (s *Shadow) Eval(ctx *Ctx) (Value, error) {
+	res, err := s.primary.Eval(ctx)
+	shadowRes, shadowErr := s.evalShadow(ctx)
+
+	if (err == nil) != (shadowErr == nil) || (err == nil && !reflect.DeepEqual(res, shadowRes)) {
+		s.onMismatch(Mismatch{
+			Ctx:        ctx,
+			Primary:    res,
+			PrimaryErr: err,
+			Shadow:     shadowRes,
+			ShadowErr:  shadowErr,
+		})
+	}
+	return res, err
+}
+
+// EvalBool evaluates both of the expressions like Eval, and returns the result of the primary one
+func (s *Shadow) EvalBool(ctx *Ctx) (bool, error) {
+	return boolResult(s.Eval(ctx))
+}
+
+func (s *Shadow) evalShadow(ctx *Ctx) (res Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			res, err = nil, fmt.Errorf("shadow expression panic: %v", r)
+		}
+	}()
+	return s.shadow.Eval(ctx)
+}
@@ -0,0 +1,71 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestShadow(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors)
+	primary, err := Compile(cc, `(and (>= age 18) (in country ("US" "CA")))`)
+	assertNil(t, err)
+	// rewritten with a wrong threshold
+	shadow, err := Compile(cc, `(and (> age 18) (or (= country "US") (= country "CA")))`)
+	assertNil(t, err)
+
+	var mismatches []Mismatch
+	s := NewShadow(primary, shadow, func(m Mismatch) {
+		mismatches = append(mismatches, m)
+	})
+
+	testCases := []struct {
+		vals     map[string]interface{}
+		want     bool
+		mismatch bool
+	}{
+		{vals: map[string]interface{}{"age": 20, "country": "US"}, want: true},
+		{vals: map[string]interface{}{"age": 16, "country": "CA"}, want: false},
+		{vals: map[string]interface{}{"age": 18, "country": "CA"}, want: true, mismatch: true},
+	}
+	for _, c := range testCases {
+		mismatches = nil
+		ctx := &Ctx{Selector: NewMapSelector(c.vals)}
+		res, err := s.EvalBool(ctx)
+		assertNil(t, err)
+		assertEquals(t, res, c.want)
+		assertEquals(t, len(mismatches) == 1, c.mismatch, c.vals)
+		if c.mismatch {
+			assertEquals(t, mismatches[0].Ctx, ctx)
+			assertEquals(t, mismatches[0].Primary, true)
+			assertEquals(t, mismatches[0].Shadow, false)
+		}
+	}
+
+	// both fail
+	mismatches = nil
+	_, err = s.Eval(&Ctx{Selector: NewMapSelector(map[string]interface{}{"age": "old"})})
+	assertNotNil(t, err)
+	assertEquals(t, len(mismatches), 0)
+}
+
+func TestShadow_Errors(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors)
+	assertNil(t, RegisterOperator(cc, "boom", func(*Ctx, []Value) (Value, error) {
+		panic("boom")
+	}))
+	primary, err := Compile(cc, `(+ a 1)`)
+	assertNil(t, err)
+	shadow, err := Compile(cc, `(boom a)`)
+	assertNil(t, err)
+
+	var mismatches []Mismatch
+	s := NewShadow(primary, shadow, func(m Mismatch) {
+		mismatches = append(mismatches, m)
+	})
+
+	res, err := s.Eval(&Ctx{Selector: NewMapSelector(map[string]interface{}{"a": 1})})
+	assertNil(t, err)
+	assertEquals(t, res, int64(2))
+	assertEquals(t, len(mismatches), 1)
+	assertErrStrContains(t, mismatches[0].ShadowErr, "shadow expression panic: boom")
+	assertNil(t, mismatches[0].PrimaryErr)
+}
@@ -0,0 +1,82 @@
+package eval
+
+import (
+	"fmt"
+)
+
+// ShortCircuit declares the short circuit semantics of an operator, see RegisterShortCircuit
+type ShortCircuit uint8
+
+const (
+	// ShortCircuitIfFalse short-circuits the operator to false once any param is false, like and
+	ShortCircuitIfFalse ShortCircuit = iota + 1
+	// ShortCircuitIfTrue short-circuits the operator to true once any param is true, like or
+	ShortCircuitIfTrue
+)
+
+const (
+	// the operators short-circuiting like and/or, which are declared by RegisterShortCircuit
+	scAndOp = uint8(0b0100000)
+	scOrOp  = uint8(0b1000000)
+	// the and/or operators which don't short-circuit, see NoShortCircuit
+	noShortCircuit = uint8(0b10000000)
+)
+
+// RegisterShortCircuit declares the short circuit semantics of the operator registered to the cc,
+// so that it short-circuits the same as and/or, e.g. an and which treats null as false:
+//
+//	RegisterShortCircuit(cc, "nullableAnd", ShortCircuitIfFalse)
+//
+// The params are evaluated in order, the rest params are skipped once a param of the declared value
+// short-circuits the operator, and the result is the param, e.g. (nullableAnd false x) is false.
+// Otherwise the operator is executed after the last param, e.g. (nullableAnd null true).
+// Note that the params of these operators are not reordered by the Reordering option.
+func RegisterShortCircuit(cc *CompileConfig, name string, sc ShortCircuit) error {
+	if _, exist := cc.OperatorMap[name]; !exist {
+		return fmt.Errorf("operator not registered %s", name)
+	}
+	if sc != ShortCircuitIfFalse && sc != ShortCircuitIfTrue {
+		return fmt.Errorf("invalid short circuit %d, operator: %s", sc, name)
+	}
+
+	cc.OperatorShortCircuits[name] = sc
+	cc.changed()
+	return nil
+}
+
+// markShortCircuits marks the operators of the tree declared by RegisterShortCircuit,
+// and the and/or operators if the NoShortCircuit option is enabled
+func markShortCircuits(conf *CompileConfig, root *astNode) {
+	for _, child := range root.children {
+		markShortCircuits(conf, child)
+	}
+
+	n := root.node
+	if typ := n.getNodeType(); typ != operator && typ != fastOperator {
+		return
+	}
+	name, _ := n.value.(string)
+	switch sc, exist := conf.OperatorShortCircuits[name]; {
+	case conf.CompileOptions[NoShortCircuit]:
+		n.flag |= noShortCircuit
+	case !exist:
+	case sc == ShortCircuitIfFalse:
+		n.flag |= scAndOp
+	case sc == ShortCircuitIfTrue:
+		n.flag |= scOrOp
+	}
+}
+
+// shortCircuits reports whether the bool param of the index decides the result of the operator,
+// the same as the short circuit flags set by calAndSetShortCircuit
+func shortCircuits(n *node, idx, cnt int, b bool) bool {
+	if !isBoolOpNode(n) {
+		return false
+	}
+	return (idx == cnt-1 && !isCustomBoolOpNode(n)) || (!b && isAndOpNode(n)) || (b && isOrOpNode(n))
+}
+
+// isCustomBoolOpNode reports whether the node is an operator declared by RegisterShortCircuit
+func isCustomBoolOpNode(n *node) bool {
+	return isBoolOpNode(n) && n.flag&(scAndOp|scOrOp) != 0
+}
@@ -0,0 +1,165 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestRegisterShortCircuit(t *testing.T) {
+	vals := map[string]interface{}{
+		"a": nil,
+		"b": true,
+		"f": false,
+	}
+
+	var touched int
+	newConf := func(backend Backend, opts ...CompileOption) *CompileConfig {
+		cc := NewCompileConfig(append(opts, RegisterSelKeys(vals), EnableStringSelectors)...)
+		cc.Backend = backend
+		// nullableAnd treats null as false
+		assertNil(t, RegisterOperator(cc, "nullableAnd", func(_ *Ctx, params []Value) (Value, error) {
+			for _, p := range params {
+				if p == nil || p == false {
+					return false, nil
+				}
+			}
+			return true, nil
+		}))
+		assertNil(t, RegisterOperator(cc, "anyOf", func(_ *Ctx, params []Value) (Value, error) {
+			for _, p := range params {
+				if p == true {
+					return true, nil
+				}
+			}
+			return false, nil
+		}))
+		assertNil(t, RegisterOperator(cc, "touch", func(_ *Ctx, params []Value) (Value, error) {
+			touched++
+			return params[0], nil
+		}))
+		assertNil(t, RegisterShortCircuit(cc, "nullableAnd", ShortCircuitIfFalse))
+		assertNil(t, RegisterShortCircuit(cc, "anyOf", ShortCircuitIfTrue))
+		return cc
+	}
+
+	testCases := []struct {
+		expr    string
+		want    Value
+		touched int
+	}{
+		{expr: `(nullableAnd f (touch b))`, want: false, touched: 0},
+		{expr: `(nullableAnd b (touch b))`, want: true, touched: 1},
+		// the operator is executed after the last param unless it's short-circuited
+		{expr: `(nullableAnd (touch b) a)`, want: false, touched: 1},
+		{expr: `(nullableAnd a true)`, want: false},
+		{expr: `(nullableAnd a b)`, want: false},
+		{expr: `(nullableAnd b b)`, want: true},
+		{expr: `(nullableAnd b f)`, want: false},
+		{expr: `(nullableAnd a (touch b) (touch a))`, want: false, touched: 2},
+		{expr: `(nullableAnd a (touch f) (touch b))`, want: false, touched: 1},
+		{expr: `(nullableAnd a (touch b))`, want: false, touched: 1},
+		{expr: `(anyOf b (touch f))`, want: true, touched: 0},
+		{expr: `(anyOf a (touch f) (touch b))`, want: true, touched: 2},
+		{expr: `(anyOf (touch f) a)`, want: false, touched: 1},
+		{expr: `(anyOf a false)`, want: false},
+		{expr: `(anyOf f b)`, want: true},
+		{expr: `(anyOf a (touch b) (touch f))`, want: true, touched: 1},
+		// short-circuit to the ancestors
+		{expr: `(nullableAnd (nullableAnd b (anyOf (touch b) (touch f))) (touch b))`, want: true, touched: 2},
+		{expr: `(anyOf (nullableAnd b (anyOf f (touch f))) (touch b))`, want: true, touched: 2},
+	}
+
+	// the results don't depend on the optimizations, e.g. the fast evaluation
+	for _, optimize := range []bool{false, true} {
+		for _, backend := range []Backend{BytecodeBackend, ClosureBackend} {
+			cc := newConf(backend, Optimizations(optimize))
+			for _, c := range testCases {
+				expr, err := Compile(cc, c.expr)
+				assertNil(t, err)
+
+				touched = 0
+				res, err := expr.Eval(NewCtxWithMap(cc, vals))
+				assertNil(t, err)
+				assertEquals(t, res, c.want, c.expr, backend, optimize)
+				assertEquals(t, touched, c.touched, c.expr, backend, optimize)
+			}
+		}
+	}
+
+	cc := NewCompileConfig()
+	err := RegisterShortCircuit(cc, "nullableAnd", ShortCircuitIfFalse)
+	assertErrStrContains(t, err, "operator not registered nullableAnd")
+	cc = newConf(BytecodeBackend)
+	err = RegisterShortCircuit(cc, "touch", ShortCircuit(0))
+	assertErrStrContains(t, err, "invalid short circuit 0, operator: touch")
+}
+
+func TestDisableShortCircuit(t *testing.T) {
+	vals := map[string]interface{}{
+		"age": 10,
+	}
+
+	var touched int
+	for _, backend := range []Backend{BytecodeBackend, ClosureBackend} {
+		cc := NewCompileConfig(RegisterSelKeys(vals), DisableShortCircuit)
+		cc.Backend = backend
+		assertNil(t, RegisterOperator(cc, "touch", func(_ *Ctx, params []Value) (Value, error) {
+			touched++
+			return params[0], nil
+		}))
+
+		// all the params are evaluated, and the constants are not folded by the short circuit
+		expr, err := Compile(cc, `(and (> age 18) (touch true) (or (touch true) (touch false)) false)`)
+		assertNil(t, err)
+		touched = 0
+		res, err := expr.Eval(NewCtxWithMap(cc, vals))
+		assertNil(t, err)
+		assertEquals(t, res, false)
+		assertEquals(t, touched, 3)
+
+		expr, err = Compile(cc, `(or (< age 18) (touch true))`)
+		assertNil(t, err)
+		touched = 0
+		res, err = expr.Eval(NewCtxWithMap(cc, vals))
+		assertNil(t, err)
+		assertEquals(t, res, true)
+		assertEquals(t, touched, 1)
+	}
+
+	// enabled by the compile config in the expression
+	cc := NewCompileConfig(RegisterSelKeys(vals), EnableZeroAlloc)
+	_, err := Compile(cc, `;;;;no_short_circuit:true
+(and (> age 18) (< age 60))`)
+	assertErrStrContains(t, err, "zero alloc is not supported without the short circuit")
+}
+
+func TestRegisterShortCircuitCache(t *testing.T) {
+	vals := map[string]interface{}{
+		"f": false,
+	}
+
+	var touched int
+	cc := NewCompileConfig(RegisterSelKeys(vals), WithCompileCache(NewCompileCache(8)))
+	assertNil(t, RegisterOperator(cc, "allOf", func(_ *Ctx, params []Value) (Value, error) {
+		return params[0] == true && params[1] == true, nil
+	}))
+	assertNil(t, RegisterOperator(cc, "touch", func(_ *Ctx, params []Value) (Value, error) {
+		touched++
+		return params[0], nil
+	}))
+	expr, err := Compile(cc, `(allOf f (touch true))`)
+	assertNil(t, err)
+	_, err = expr.Eval(NewCtxWithMap(cc, vals))
+	assertNil(t, err)
+	assertEquals(t, touched, 1)
+
+	// the expression compiled without the short circuit is not shared
+	sc := CopyCompileConfig(cc)
+	assertNil(t, RegisterShortCircuit(sc, "allOf", ShortCircuitIfFalse))
+	expr, err = Compile(sc, `(allOf f (touch true))`)
+	assertNil(t, err)
+	touched = 0
+	res, err := expr.Eval(NewCtxWithMap(sc, vals))
+	assertNil(t, err)
+	assertEquals(t, res, false)
+	assertEquals(t, touched, 0)
+}
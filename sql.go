@@ -0,0 +1,482 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	sqlKeywords = map[string]bool{
+		"AND":     true,
+		"OR":      true,
+		"NOT":     true,
+		"BETWEEN": true,
+		"IN":      true,
+		"LIKE":    true,
+		"IS":      true,
+		"CASE":    true,
+		"WHEN":    true,
+		"THEN":    true,
+		"ELSE":    true,
+		"END":     true,
+	}
+
+	sqlComparisonOps = map[string]string{
+		"=":  "eq",
+		"<>": "ne",
+		"!=": "ne",
+		"<":  "lt",
+		"<=": "le",
+		">":  "gt",
+		">=": "ge",
+	}
+
+	// longer operators must be placed in front of their prefixes
+	sqlOpSymbols = []string{
+		"<>", "!=", "<=", ">=",
+		"=", "<", ">", "+", "-", "*", "/", "%",
+	}
+)
+
+// lexSQL splits the SQL predicate into tokens, the keywords are case-insensitive
+// and returned as the upper case op tokens, e.g. status = 'active' AND age BETWEEN 18 AND 65
+func (p *parser) lexSQL() error {
+	var tokens []token
+	A := []rune(p.source)
+	for i := 0; i < len(A); {
+		r := A[i]
+		t := token{pos: i}
+		j := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '-' && j < len(A) && A[j] == '-':
+			// the rest of the line is a comment
+			for ; j < len(A) && A[j] != '\n'; j++ {
+			}
+			t.typ, t.val = comment, string(A[i:j])
+		case r == '(':
+			t.typ, t.val = lParen, "("
+		case r == ')':
+			t.typ, t.val = rParen, ")"
+		case r == ',':
+			t.typ, t.val = comma, ","
+		case unicode.IsDigit(r):
+			for ; j < len(A) && unicode.IsDigit(A[j]); j++ {
+			}
+			t.typ = integer
+			if j+1 < len(A) && A[j] == '.' && unicode.IsDigit(A[j+1]) {
+				for j++; j < len(A) && unicode.IsDigit(A[j]); j++ {
+				}
+				t.typ = float
+			}
+			t.val = string(A[i:j])
+		case r == '\'' || r == '"':
+			// the string literals are single-quoted, and the double-quoted are identifiers,
+			// the quote is escaped by doubling it, e.g. 'it''s'
+			var sb strings.Builder
+			closed := false
+			for ; j < len(A); j++ {
+				if A[j] == r {
+					if j+1 < len(A) && A[j+1] == r {
+						j++
+					} else {
+						closed = true
+						j++
+						break
+					}
+				}
+				sb.WriteRune(A[j])
+			}
+			if !closed {
+				return p.errWithPos(errors.New("can not parse token"), i)
+			}
+			t.typ, t.val = str, sb.String()
+			if r == '"' {
+				t.typ = ident
+			}
+		case unicode.IsLetter(r) || r == '_':
+			for ; j < len(A) && (unicode.IsLetter(A[j]) || unicode.IsDigit(A[j]) || A[j] == '_' ||
+				A[j] == '.' && j+1 < len(A) && (unicode.IsLetter(A[j+1]) || A[j+1] == '_')); j++ {
+			}
+			s := string(A[i:j])
+			switch upper := strings.ToUpper(s); {
+			case sqlKeywords[upper]:
+				t.typ, t.val = op, upper
+			case upper == "TRUE" || upper == "FALSE" || upper == "NULL":
+				t.typ, t.val = ident, strings.ToLower(s)
+			default:
+				t.typ, t.val = ident, s
+			}
+		default:
+			rest := string(A[i:min(i+2, len(A))])
+			for _, sym := range sqlOpSymbols {
+				if strings.HasPrefix(rest, sym) {
+					t.typ, t.val = op, sym
+					j = i + len(sym)
+					break
+				}
+			}
+			if t.typ == "" {
+				return p.errWithPos(errors.New("can not parse token"), i)
+			}
+		}
+		tokens = append(tokens, t)
+		i = j
+	}
+	p.tokens = tokens
+	return nil
+}
+
+func (p *parser) parseSQLAstTree() (*astNode, error) {
+	n := 0
+	for _, t := range p.tokens {
+		if t.typ != comment {
+			p.tokens[n] = t
+			n++
+		}
+	}
+	p.tokens = p.tokens[:n]
+
+	if n == 0 {
+		return nil, errors.New("invalid expression error, expression is empty")
+	}
+
+	// append an eof token, so that peek never goes out of range
+	p.tokens = append(p.tokens, token{typ: eof, pos: len([]rune(p.source)) - 1})
+
+	root, err := p.parseSQLOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.typ != eof {
+		return nil, p.invalidExprErr(t.pos)
+	}
+	return root, nil
+}
+
+func (p *parser) isSQLOp(val string) bool {
+	t := p.peek()
+	return t.typ == op && t.val == val
+}
+
+// parseSQLChain parses the left-associative operators of the same precedence,
+// `a op b op c` is merged into `(op a b c)`
+func (p *parser) parseSQLChain(ops map[string]string, operand func() (*astNode, error)) (*astNode, error) {
+	lhs, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		name, exist := ops[t.val]
+		if t.typ != op || !exist {
+			return lhs, nil
+		}
+		p.walk()
+
+		rhs, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if lhs.node.getNodeType() == operator && lhs.node.value == name && len(lhs.children) < math.MaxInt8 {
+			lhs.children = append(lhs.children, rhs)
+			continue
+		}
+		if lhs, err = p.buildNode(token{typ: ident, val: name, pos: t.pos}, []*astNode{lhs, rhs}); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseSQLOr() (*astNode, error) {
+	return p.parseSQLChain(map[string]string{"OR": "or"}, p.parseSQLAnd)
+}
+
+func (p *parser) parseSQLAnd() (*astNode, error) {
+	return p.parseSQLChain(map[string]string{"AND": "and"}, p.parseSQLNot)
+}
+
+func (p *parser) parseSQLNot() (*astNode, error) {
+	if !p.isSQLOp("NOT") {
+		return p.parseSQLPredicate()
+	}
+	t := p.next()
+	operand, err := p.parseSQLNot()
+	if err != nil {
+		return nil, err
+	}
+	return p.buildNode(token{typ: ident, val: "not", pos: t.pos}, []*astNode{operand})
+}
+
+// parseSQLPredicate parses the comparison, BETWEEN, IN, LIKE and IS NULL predicates,
+// e.g. age BETWEEN 18 AND 65, country NOT IN ('US', 'CA'), name LIKE 'foo%', nickname IS NOT NULL
+func (p *parser) parseSQLPredicate() (*astNode, error) {
+	lhs, err := p.parseSQLAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.typ != op {
+		return lhs, nil
+	}
+	if name, exist := sqlComparisonOps[t.val]; exist {
+		p.walk()
+		rhs, err := p.parseSQLAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return p.buildNode(token{typ: ident, val: name, pos: t.pos}, []*astNode{lhs, rhs})
+	}
+
+	negated := false
+	if next := p.tokens[p.idx+1]; t.val == "NOT" && next.typ == op {
+		negated, t = true, next
+	}
+
+	var n *astNode
+	switch t.val {
+	case "BETWEEN":
+		n, err = p.parseSQLBetween(lhs)
+	case "IN":
+		n, err = p.parseSQLIn(lhs, negated)
+		negated = false
+	case "LIKE":
+		n, err = p.parseSQLLike(lhs)
+	case "IS":
+		if negated {
+			return lhs, nil
+		}
+		return p.parseSQLIsNull(lhs)
+	default:
+		return lhs, nil
+	}
+	if err != nil || !negated {
+		return n, err
+	}
+	return p.buildNode(token{typ: ident, val: "not", pos: t.pos}, []*astNode{n})
+}
+
+func (p *parser) walkSQLNegation() token {
+	if p.isSQLOp("NOT") {
+		p.walk()
+	}
+	return p.next()
+}
+
+func (p *parser) parseSQLBetween(lhs *astNode) (*astNode, error) {
+	t := p.walkSQLNegation()
+	lo, err := p.parseSQLAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if and := p.next(); and.typ != op || and.val != "AND" {
+		return nil, p.errWithToken(fmt.Errorf("token unexpected error (want: AND, got: %s)", and.val), and)
+	}
+	hi, err := p.parseSQLAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return p.buildNode(token{typ: ident, val: "between", pos: t.pos}, []*astNode{lhs, lo, hi})
+}
+
+func (p *parser) parseSQLIn(lhs *astNode, negated bool) (*astNode, error) {
+	t := p.walkSQLNegation()
+	start := p.peek()
+	if err := p.eat(lParen); err != nil {
+		return nil, err
+	}
+	elems, err := p.parseSQLElements()
+	if err != nil {
+		return nil, err
+	}
+	list, err := p.buildCollectionNode(start, "list", elems)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "in"
+	if negated {
+		name = "not_in"
+	}
+	return p.buildNode(token{typ: ident, val: name, pos: t.pos}, []*astNode{lhs, list})
+}
+
+// parseSQLLike compiles the LIKE predicate to the like operator, the pattern should be a string literal,
+// % matches any sequence of characters, _ matches any character, and \ escapes them.
+func (p *parser) parseSQLLike(lhs *astNode) (*astNode, error) {
+	t := p.walkSQLNegation()
+	pattern := p.next()
+	if pattern.typ != str {
+		return nil, p.tokenTypeError(str, pattern)
+	}
+	return p.buildNode(token{typ: ident, val: "like", pos: t.pos}, []*astNode{lhs, p.valNodeAt(pattern.val, pattern)})
+}
+
+// parseSQLIsNull parses IS [NOT] NULL, which compares the operand with null
+func (p *parser) parseSQLIsNull(lhs *astNode) (*astNode, error) {
+	t := p.next()
+	name := "eq"
+	if p.isSQLOp("NOT") {
+		p.walk()
+		name = "ne"
+	}
+	null := p.next()
+	if null.typ != ident || null.val != "null" {
+		return nil, p.errWithToken(fmt.Errorf("token unexpected error (want: NULL, got: %s)", null.val), null)
+	}
+	return p.buildNode(token{typ: ident, val: name, pos: t.pos}, []*astNode{lhs, p.valNodeAt(nil, null)})
+}
+
+func (p *parser) parseSQLAdditive() (*astNode, error) {
+	return p.parseSQLChain(map[string]string{"+": "add", "-": "sub"}, p.parseSQLMultiplicative)
+}
+
+func (p *parser) parseSQLMultiplicative() (*astNode, error) {
+	return p.parseSQLChain(map[string]string{"*": "mul", "/": "div", "%": "mod"}, p.parseSQLUnary)
+}
+
+func (p *parser) parseSQLUnary() (*astNode, error) {
+	if !p.isSQLOp("-") {
+		return p.parseSQLPrimary()
+	}
+	t := p.next()
+
+	// negative number literal
+	switch num := p.peek(); num.typ {
+	case integer:
+		p.walk()
+		v, err := p.intLiteral("-"+num.val, num)
+		if err != nil {
+			return nil, err
+		}
+		return p.valNodeAt(v, t), nil
+	case float:
+		p.walk()
+		v, err := strconv.ParseFloat("-"+num.val, 64)
+		if err != nil {
+			return nil, p.errWithToken(err, num)
+		}
+		return p.valNodeAt(v, t), nil
+	}
+
+	operand, err := p.parseSQLUnary()
+	if err != nil {
+		return nil, err
+	}
+	return p.buildNode(token{typ: ident, val: "sub", pos: t.pos}, []*astNode{p.valNode(int64(0)), operand})
+}
+
+func (p *parser) parseSQLPrimary() (*astNode, error) {
+	t := p.peek()
+	if p.isSQLOp("CASE") {
+		return p.parseSQLCase()
+	}
+	switch t.typ {
+	case lParen:
+		p.walk()
+		n, err := p.parseSQLOr()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.eat(rParen); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case integer:
+		return p.parseInt()
+	case float:
+		return p.parseFloat()
+	case str:
+		return p.parseStr()
+	case ident:
+		if p.tokens[p.idx+1].typ == lParen {
+			// function call, e.g. lower(name) = 'foo'
+			car := p.next()
+			p.walk()
+			args, err := p.parseSQLElements()
+			if err != nil {
+				return nil, err
+			}
+			return p.buildNode(car, args)
+		}
+		fns := []func() (*astNode, error){p.parseConst, p.parseSelector, p.parseFieldSelector, p.parseUnknownSelector}
+		for _, fn := range fns {
+			n, err := fn()
+			if n != nil || err != nil {
+				return n, err
+			}
+		}
+	}
+	return nil, p.invalidExprErr(t.pos)
+}
+
+// parseSQLCase parses the searched case expression to the chained cond nodes,
+// e.g. CASE WHEN c1 THEN v1 WHEN c2 THEN v2 ELSE v3 END, the default value is NULL without ELSE
+func (p *parser) parseSQLCase() (*astNode, error) {
+	t := p.next()
+	var branches [][2]*astNode
+	for p.isSQLOp("WHEN") {
+		p.walk()
+		c, err := p.parseSQLOr()
+		if err != nil {
+			return nil, err
+		}
+		if then := p.next(); then.typ != op || then.val != "THEN" {
+			return nil, p.errWithToken(fmt.Errorf("token unexpected error (want: THEN, got: %s)", then.val), then)
+		}
+		v, err := p.parseSQLOr()
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, [2]*astNode{c, v})
+	}
+	if len(branches) == 0 {
+		when := p.peek()
+		return nil, p.errWithToken(fmt.Errorf("token unexpected error (want: WHEN, got: %s)", when.val), when)
+	}
+
+	res := p.valNodeAt(nil, t)
+	if p.isSQLOp("ELSE") {
+		p.walk()
+		var err error
+		if res, err = p.parseSQLOr(); err != nil {
+			return nil, err
+		}
+	}
+	if end := p.next(); end.typ != op || end.val != "END" {
+		return nil, p.errWithToken(fmt.Errorf("token unexpected error (want: END, got: %s)", end.val), end)
+	}
+
+	for i := len(branches) - 1; i >= 0; i-- {
+		res = p.buildCondNode(t, branches[i][0], branches[i][1], res)
+	}
+	return res, nil
+}
+
+// parseSQLElements parses the comma separated expressions until the right parenthesis
+func (p *parser) parseSQLElements() ([]*astNode, error) {
+	var elems []*astNode
+	for p.peek().typ != rParen {
+		if len(elems) != 0 {
+			if err := p.eat(comma); err != nil {
+				return nil, err
+			}
+		}
+		elem, err := p.parseSQLOr()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+	p.walk()
+	return elems, nil
+}
@@ -0,0 +1,120 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestParseSQL(t *testing.T) {
+	testCases := []struct {
+		sql    string
+		prefix string
+		errMsg string
+	}{
+		{
+			sql:    `status = 'active' AND age BETWEEN 18 AND 65`,
+			prefix: `(and (eq status "active") (between age 18 65))`,
+		},
+		{
+			sql:    `country in ('US', 'CA') or country NOT IN ('CN') and not vip`,
+			prefix: `(or (in country ("US" "CA")) (and (not_in country ("CN")) (not vip)))`,
+		},
+		{
+			sql:    `name LIKE 'fo_%' AND name NOT LIKE '%.com'`,
+			prefix: `(and (like name "fo_%") (not (like name "%.com")))`,
+		},
+		{
+			sql:    `(a + b) * -2 <> c - -1.5 OR "IN" != 'it''s' -- comment`,
+			prefix: `(or (ne (mul (add a b) -2) (sub c -1.5)) (ne IN "it's"))`,
+		},
+		{
+			sql:    `score NOT BETWEEN 1 AND 2 AND flag = TRUE AND user.age >= 18`,
+			prefix: `(and (not (between score 1 2)) (eq flag true) (ge user.age 18))`,
+		},
+		{
+			sql:    `CASE WHEN age < 18 THEN 'minor' WHEN age < 65 THEN 'adult' ELSE 'senior' END = tier`,
+			prefix: `(eq (if (lt age 18) "minor" (if (lt age 65) "adult" "senior")) tier)`,
+		},
+		{
+			sql:    `case when vip then 1 end`,
+			prefix: `(if vip 1 null)`,
+		},
+		{
+			sql:    `age BETWEEN 18 OR 65`,
+			errMsg: "want: AND, got: OR",
+		},
+		{
+			sql:    `CASE ELSE 1 END`,
+			errMsg: "want: WHEN, got: ELSE",
+		},
+		{
+			sql:    `CASE WHEN vip 1 END`,
+			errMsg: "want: THEN, got: 1",
+		},
+		{
+			sql:    `CASE WHEN vip THEN 1 ELSE 2`,
+			errMsg: "want: END, got: ",
+		},
+		{
+			sql:    `name LIKE prefix`,
+			errMsg: "token type unexpected error",
+		},
+		{
+			sql:    `name = 'abc`,
+			errMsg: "can not parse token",
+		},
+		{
+			sql:    `age > 18 AND`,
+			errMsg: "invalid expression error",
+		},
+		{
+			sql:    `-- empty`,
+			errMsg: "expression is empty",
+		},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(EnableStringSelectors, EnableSQLSyntax)
+		Optimizations(false)(cc)
+
+		got, err := Compile(cc, c.sql)
+		if len(c.errMsg) != 0 {
+			assertErrStrContains(t, err, c.errMsg, c)
+			continue
+		}
+		assertNil(t, err, c)
+
+		cc.SyntaxMode = PrefixSyntax
+		want, err := Compile(cc, c.prefix)
+		assertNil(t, err, c)
+
+		assertEquals(t, Dump(got), Dump(want), c)
+	}
+}
+
+func TestEvalSQL(t *testing.T) {
+	vals := map[string]interface{}{
+		"status": "active",
+		"age":    30,
+		"name":   "foo.bar",
+		"tags":   []string{"vip"},
+	}
+	cc := NewCompileConfig(RegisterSelKeys(vals), EnableSQLSyntax)
+
+	testCases := []struct {
+		expr string
+		want Value
+	}{
+		{expr: `status = 'active' AND age BETWEEN 18 AND 65`, want: true},
+		{expr: `name LIKE 'foo%' AND name NOT LIKE 'foo_bar_'`, want: true},
+		{expr: `age NOT IN (18, 30) OR (age + 1) * 2 = 62`, want: true},
+		{expr: `NOT status IN ('active', 'pending')`, want: false},
+		{expr: `CASE WHEN age < 18 THEN 'minor' WHEN age < 65 THEN 'adult' ELSE 'senior' END`, want: "adult"},
+		{expr: `CASE WHEN status = 'inactive' THEN 1 END IS NULL`, want: true},
+	}
+
+	for _, c := range testCases {
+		got, err := Eval(c.expr, vals, cc)
+		assertNil(t, err, c)
+		assertEquals(t, got, c.want, c)
+	}
+}
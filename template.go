@@ -0,0 +1,91 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/larry618/eval/ast"
+)
+
+// placeholderPrefix prefixes the names of the selectors which stand for the placeholders of the templates,
+// e.g. {threshold} => __placeholder_threshold
+const placeholderPrefix = "__placeholder_"
+
+// CompileTemplate compiles the template whose placeholders are bound to the constant values at compile time,
+// so that the same rule shape can be instantiated for each tenant, and the expressions are specialized
+// and folded with the values, e.g.
+//
+//	CompileTemplate(cc, `score > {threshold} && region == {region}`, map[string]interface{}{"threshold": 80, "region": "US"})
+//
+// The placeholders are the names in braces out of the string literals, e.g. {threshold},
+// they can be used wherever a constant is allowed, and all of them should be bound.
+// The template is compiled by the syntax mode of the config, and the CompileCache of the config is not used.
+func CompileTemplate(conf *CompileConfig, tmpl string, bindings map[string]interface{}) (*Expr, error) {
+	exprStr, names := expandPlaceholders(tmpl)
+	for _, name := range names {
+		if _, exist := bindings[name]; !exist {
+			return nil, fmt.Errorf("template error, placeholder is not bound: %s", name)
+		}
+	}
+
+	cc := CopyCompileConfig(conf)
+	// the placeholders are bound before the other rewriters see them
+	cc.Rewriters = append([]ast.RewriteFunc{bindPlaceholders(bindings)}, cc.Rewriters...)
+	return compile(cc, exprStr)
+}
+
+// bindPlaceholders replaces the selectors of the placeholders with the constants of the bindings
+func bindPlaceholders(bindings map[string]interface{}) ast.RewriteFunc {
+	return func(n *ast.Node) (*ast.Node, error) {
+		name, ok := n.Value.(string)
+		if n.Kind != ast.Selector || !ok || !strings.HasPrefix(name, placeholderPrefix) {
+			return n, nil
+		}
+		return &ast.Node{
+			Kind:  ast.Constant,
+			Value: bindings[strings.TrimPrefix(name, placeholderPrefix)],
+		}, nil
+	}
+}
+
+// expandPlaceholders replaces the placeholders out of the string literals with the selectors,
+// and returns the names of the placeholders in order, e.g. (> score {threshold}) => (> score __placeholder_threshold).
+// The braces which don't enclose a name are kept, e.g. the map literals of CEL.
+func expandPlaceholders(tmpl string) (string, []string) {
+	var (
+		sb    strings.Builder
+		names []string
+		seen  = make(map[string]bool)
+		quote byte
+	)
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(tmpl) {
+				sb.WriteByte(c)
+				i++
+				c = tmpl[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '{':
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end == -1 || !isIdentName(tmpl[i+1:i+end]) {
+				break
+			}
+			name := tmpl[i+1 : i+end]
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+			sb.WriteString(placeholderPrefix + name)
+			i += end
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String(), names
+}
@@ -0,0 +1,122 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestCompileTemplate(t *testing.T) {
+	vals := map[string]interface{}{
+		"score":  90,
+		"region": "US",
+	}
+
+	testCases := []struct {
+		opt      CompileOption
+		tmpl     string
+		bindings map[string]interface{}
+		want     Value
+		decomp   string
+		errMsg   string
+	}{
+		{
+			tmpl:     `(and (> score {threshold}) (= region {region}))`,
+			bindings: map[string]interface{}{"threshold": 80, "region": "US"},
+			want:     true,
+		},
+		{
+			opt:      EnableInfixSyntax,
+			tmpl:     `score > {threshold} && region == {region}`,
+			bindings: map[string]interface{}{"threshold": 95, "region": "US"},
+			want:     false,
+		},
+		{
+			opt:      EnableSQLSyntax,
+			tmpl:     `score > {threshold} AND region IN ({region}, 'CA')`,
+			bindings: map[string]interface{}{"threshold": 80, "region": "US"},
+			want:     true,
+		},
+		{
+			// the placeholders are not replaced in the string literals
+			tmpl:     `(= (format "{region}:%v" region) {key})`,
+			bindings: map[string]interface{}{"key": "{region}:US"},
+			want:     true,
+		},
+		{
+			// the placeholders are folded with the constants
+			tmpl:     `(and (> {limit} 10) (> score {threshold}))`,
+			bindings: map[string]interface{}{"limit": 5, "threshold": 80},
+			want:     false,
+			decomp:   `false`,
+		},
+		{
+			tmpl:     `(> score (* {threshold} 2))`,
+			bindings: map[string]interface{}{"threshold": 40},
+			want:     true,
+			decomp:   `(> score 80)`,
+		},
+		{
+			tmpl:     `(> score {threshold})`,
+			bindings: map[string]interface{}{},
+			errMsg:   "template error, placeholder is not bound: threshold",
+		},
+		{
+			opt:      EnableInfixSyntax,
+			tmpl:     `score > {threshold} && region == {region}`,
+			bindings: map[string]interface{}{"threshold": 80},
+			errMsg:   "template error, placeholder is not bound: region",
+		},
+	}
+
+	for _, c := range testCases {
+		opts := []CompileOption{RegisterSelKeys(vals)}
+		if c.opt != nil {
+			opts = append(opts, c.opt)
+		}
+		cc := NewCompileConfig(opts...)
+		expr, err := CompileTemplate(cc, c.tmpl, c.bindings)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg)
+			continue
+		}
+		assertNil(t, err)
+		if c.decomp != "" {
+			assertEquals(t, expr.Decompile(), c.decomp)
+		}
+		res, err := expr.Eval(NewCtxWithMap(cc, vals))
+		assertNil(t, err)
+		assertEquals(t, res, c.want)
+	}
+}
+
+func TestExpandPlaceholders(t *testing.T) {
+	testCases := []struct {
+		tmpl  string
+		want  string
+		names []string
+	}{
+		{
+			tmpl:  `(> score {threshold})`,
+			want:  `(> score __placeholder_threshold)`,
+			names: []string{"threshold"},
+		},
+		{
+			tmpl:  `a > {x} && b < {x} || c == {y_2}`,
+			want:  `a > __placeholder_x && b < __placeholder_x || c == __placeholder_y_2`,
+			names: []string{"x", "y_2"},
+		},
+		{
+			tmpl: `(= a "{x} \"{y}\"") (= b '{z}')`,
+			want: `(= a "{x} \"{y}\"") (= b '{z}')`,
+		},
+		{
+			tmpl: `{"a": 1, "b": {}}.a == {1x}`,
+			want: `{"a": 1, "b": {}}.a == {1x}`,
+		},
+	}
+
+	for _, c := range testCases {
+		got, names := expandPlaceholders(c.tmpl)
+		assertEquals(t, got, c.want)
+		assertEquals(t, names, c.names)
+	}
+}
@@ -0,0 +1,355 @@
+package eval
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Type is the static type of the values in the expression
+type Type string
+
+const (
+	// TypeAny is compatible with all the types, it's used when the type is unknown
+	TypeAny     Type = "any"
+	TypeBool    Type = typeBool
+	TypeInt     Type = typeInt
+	TypeFloat   Type = typeFloat
+	TypeString  Type = typeStr
+	TypeIntList Type = typeIntList
+	TypeStrList Type = typeStrList
+	TypeMap     Type = typeMap
+	TypeDecimal Type = typeDecimal
+	// TypeNumber is either TypeInt, TypeFloat or TypeDecimal, the int64 values are promoted to float64
+	// if they are mixed with float64 values, unless the StrictNumeric option is enabled.
+	// As the result type of an operator, it's TypeFloat if any param is TypeFloat,
+	// TypeDecimal if any param is TypeDecimal and the others are TypeInt,
+	// TypeInt if all the params are TypeInt, otherwise TypeAny.
+	TypeNumber Type = "number"
+)
+
+// Signature declares the param types and the result type of an operator
+type Signature struct {
+	Params []Type
+	// the last param can be repeated if Variadic is true
+	Variadic bool
+	Result   Type
+}
+
+func (s Signature) String() string {
+	params := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		params[i] = string(p)
+	}
+	variadic := ""
+	if s.Variadic {
+		variadic = "..."
+	}
+	return fmt.Sprintf("(%s%s) %s", strings.Join(params, ", "), variadic, s.Result)
+}
+
+var (
+	numArithmetic = Signature{Params: []Type{TypeNumber, TypeNumber}, Variadic: true, Result: TypeNumber}
+	boolLogic     = Signature{Params: []Type{TypeBool, TypeBool}, Variadic: true, Result: TypeBool}
+	numComparison = Signature{Params: []Type{TypeNumber, TypeNumber}, Result: TypeBool}
+	intQuotient   = Signature{Params: []Type{TypeInt, TypeInt}, Result: TypeInt}
+	intBitwise    = Signature{Params: []Type{TypeInt, TypeInt}, Variadic: true, Result: TypeInt}
+	// the params of the mask or logic operators are either all bool or all int64, see maskOrLogicResult
+	maskOrLogicSig = Signature{Params: []Type{TypeAny, TypeAny}, Variadic: true, Result: TypeAny}
+
+	builtinSignatures = map[string]Signature{
+		// arithmetic
+		"add": numArithmetic,
+		"sub": numArithmetic,
+		"mul": numArithmetic,
+		"div": numArithmetic,
+		"mod": numArithmetic,
+		"+":   numArithmetic,
+		"-":   numArithmetic,
+		"*":   numArithmetic,
+		"/":   numArithmetic,
+		"%":   numArithmetic,
+
+		// integer division
+		"truncdiv": intQuotient,
+		"floordiv": intQuotient,
+		"floormod": intQuotient,
+
+		// logic
+		"and": boolLogic,
+		"or":  boolLogic,
+		"xor": boolLogic,
+		"not": {Params: []Type{TypeBool}, Result: TypeBool},
+		"&":   maskOrLogicSig,
+		"|":   maskOrLogicSig,
+		"^":   maskOrLogicSig,
+		"!":   {Params: []Type{TypeBool}, Result: TypeBool},
+
+		// bitwise
+		"bitand": intBitwise,
+		"bitor":  intBitwise,
+		"bitxor": intBitwise,
+		"<<":     {Params: []Type{TypeInt, TypeInt}, Result: TypeInt},
+		">>":     {Params: []Type{TypeInt, TypeInt}, Result: TypeInt},
+
+		// comparison
+		"eq":      {Params: []Type{TypeAny, TypeAny}, Variadic: true, Result: TypeBool},
+		"ne":      {Params: []Type{TypeAny, TypeAny}, Result: TypeBool},
+		"gt":      numComparison,
+		"lt":      numComparison,
+		"ge":      numComparison,
+		"le":      numComparison,
+		"=":       {Params: []Type{TypeAny, TypeAny}, Variadic: true, Result: TypeBool},
+		"!=":      {Params: []Type{TypeAny, TypeAny}, Result: TypeBool},
+		">":       numComparison,
+		"<":       numComparison,
+		">=":      numComparison,
+		"<=":      numComparison,
+		"between": {Params: []Type{TypeNumber, TypeNumber, TypeNumber}, Result: TypeBool},
+
+		// list
+		"in":      {Params: []Type{TypeAny, TypeAny}, Result: TypeBool},
+		"not_in":  {Params: []Type{TypeAny, TypeAny}, Result: TypeBool},
+		"overlap": {Params: []Type{TypeAny, TypeAny}, Result: TypeBool},
+
+		// string
+		"matches": {Params: []Type{TypeString, TypeString}, Result: TypeBool},
+		"like":    {Params: []Type{TypeString, TypeString}, Result: TypeBool},
+
+		// version
+		"semverCompare":   {Params: []Type{TypeString, TypeString}, Result: TypeInt},
+		"semverSatisfies": {Params: []Type{TypeString, TypeString}, Result: TypeBool},
+
+		"equalsIgnoreCase": {Params: []Type{TypeString, TypeString}, Result: TypeBool},
+		"compare":          {Params: []Type{TypeString, TypeString, TypeString}, Result: TypeInt},
+		"format":           {Variadic: true, Result: TypeString},
+		"sprintf":          {Variadic: true, Result: TypeString},
+
+		// url
+		"urlScheme":     {Params: []Type{TypeString}, Result: TypeString},
+		"urlHost":       {Params: []Type{TypeString}, Result: TypeString},
+		"urlPath":       {Params: []Type{TypeString}, Result: TypeString},
+		"urlQueryParam": {Params: []Type{TypeString, TypeString}, Result: TypeString},
+
+		// collection
+		"list":  {Variadic: true, Result: TypeAny},
+		"dict":  {Variadic: true, Result: TypeMap},
+		"index": {Params: []Type{TypeAny, TypeAny}, Result: TypeAny},
+		"field": {Params: []Type{TypeAny, TypeString}, Variadic: true, Result: TypeAny},
+		"len":   {Params: []Type{TypeAny}, Result: TypeInt},
+
+		// json
+		"jsonGet": {Params: []Type{TypeAny, TypeString}, Result: TypeAny},
+
+		// conversion
+		"toInt":      {Params: []Type{TypeAny}, Result: TypeInt},
+		"toFloat":    {Params: []Type{TypeAny}, Result: TypeFloat},
+		"toString":   {Params: []Type{TypeAny}, Result: TypeString},
+		"toBool":     {Params: []Type{TypeAny}, Result: TypeBool},
+		"parseInt":   {Params: []Type{TypeString}, Result: TypeInt},
+		"parseFloat": {Params: []Type{TypeString}, Result: TypeFloat},
+
+		// decimal
+		"decimal": {Params: []Type{TypeAny}, Result: TypeDecimal},
+
+		// null
+		"coalesce": {Params: []Type{TypeAny}, Variadic: true, Result: TypeAny},
+		"getOrNil": {Params: []Type{TypeAny, TypeString}, Variadic: true, Result: TypeAny},
+	}
+)
+
+// typeOf returns the static type of the constant value
+func typeOf(v Value) Type {
+	switch v := v.(type) {
+	case bool:
+		return TypeBool
+	case int64, *big.Int:
+		return TypeInt
+	case float64:
+		return TypeFloat
+	case string:
+		return TypeString
+	case []int64:
+		return TypeIntList
+	case []string:
+		return TypeStrList
+	case map[string]Value:
+		return TypeMap
+	case Decimal:
+		return TypeDecimal
+	case *lambda:
+		return v.body.returnType
+	}
+	return TypeAny
+}
+
+func (t Type) assignableTo(want Type) bool {
+	if want == TypeNumber && (t == TypeInt || t == TypeFloat || t == TypeDecimal) {
+		return true
+	}
+	return t == want || t == TypeAny || want == TypeAny
+}
+
+// numberResult returns the result type of the numeric operator with the param types
+func numberResult(types []Type) Type {
+	res := TypeInt
+	for _, typ := range types {
+		switch {
+		case typ == TypeInt:
+		case res == TypeInt && (typ == TypeFloat || typ == TypeDecimal):
+			res = typ
+		case typ != res:
+			// the decimals are not mixed with float64 values
+			return TypeAny
+		}
+	}
+	return res
+}
+
+func isMaskOrLogic(name string) bool {
+	return name == "&" || name == "|" || name == "^"
+}
+
+// maskOrLogicResult returns the result type of the mask or logic operator, which is TypeBool
+// or TypeInt if all the params are of the type, otherwise TypeAny.
+// The index of the param which is neither bool nor int64 is returned, or -1.
+func maskOrLogicResult(types []Type) (Type, int) {
+	res := types[0]
+	for i, typ := range types {
+		switch typ {
+		case TypeBool, TypeInt:
+			if typ != res {
+				res = TypeAny
+			}
+		case TypeAny:
+			res = TypeAny
+		default:
+			return "", i
+		}
+	}
+	return res, -1
+}
+
+// typeChecker infers the types of the tree with the declared selector types and operator signatures
+type typeChecker struct {
+	conf *CompileConfig
+	// errWithPos decorates the errors with the position in the source
+	errWithPos func(err error, pos int) error
+}
+
+func (p *parser) typeCheck(root *astNode) (Type, error) {
+	return typeChecker{conf: p.conf, errWithPos: p.errWithPos}.check(root)
+}
+
+// checkTypes checks the types of the tree without the positions in the source,
+// e.g. the tree compiled by CompileAST
+func checkTypes(conf *CompileConfig, root *astNode) (Type, error) {
+	noPos := func(err error, _ int) error {
+		return err
+	}
+	return typeChecker{conf: conf, errWithPos: noPos}.check(root)
+}
+
+// inferType returns the type of the tree, it's TypeAny if the tree is ill-typed
+func inferType(conf *CompileConfig, root *astNode) Type {
+	typ, err := checkTypes(conf, root)
+	if err != nil {
+		return TypeAny
+	}
+	return typ
+}
+
+func (p typeChecker) signature(name string) (Signature, bool) {
+	if sig, exist := p.conf.OperatorSignatures[name]; exist {
+		return sig, true
+	}
+	if sig, exist := builtinSignatures[name]; exist {
+		return sig, true
+	}
+	if isHigherOrder(p.conf, name) {
+		return higherOrderSignatures[name], true
+	}
+	return Signature{}, false
+}
+
+// check checks the types of the tree bottom-up and returns the type of the root.
+// The selectors without declared types and the operators without signatures are of TypeAny.
+func (p typeChecker) check(root *astNode) (Type, error) {
+	n := root.node
+	switch n.getNodeType() {
+	case constant:
+		return typeOf(n.value), nil
+	case selector:
+		if typ, exist := p.conf.SelectorTypes[n.value.(string)]; exist {
+			return typ, nil
+		}
+		return TypeAny, nil
+	case end:
+		return TypeAny, nil
+	}
+
+	types := make([]Type, 0, len(root.children))
+	for _, child := range root.children {
+		if child.node.getNodeType() == end {
+			continue
+		}
+		typ, err := p.check(child)
+		if err != nil {
+			return "", err
+		}
+		types = append(types, typ)
+	}
+
+	name := fmt.Sprint(n.value)
+	if n.getNodeType() == cond {
+		if !types[0].assignableTo(TypeBool) {
+			err := fmt.Errorf("type check error, if condition should be %s, got: %s", TypeBool, types[0])
+			return "", p.errWithPos(err, root.children[0].pos)
+		}
+		if types[1] == types[2] {
+			return types[1], nil
+		}
+		return TypeAny, nil
+	}
+
+	sig, exist := p.signature(name)
+	if !exist {
+		return TypeAny, nil
+	}
+
+	size := len(sig.Params)
+	if (!sig.Variadic && len(types) != size) || (sig.Variadic && len(types) < size) {
+		err := fmt.Errorf("type check error, %s parameters count error (signature: %s, got: %d)", name, sig, len(types))
+		return "", p.errWithPos(err, root.pos)
+	}
+
+	for i, typ := range types {
+		if size == 0 {
+			break
+		}
+		want := sig.Params[min(i, size-1)]
+		if !typ.assignableTo(want) {
+			err := fmt.Errorf("type check error, %s param %d should be %s, got: %s", name, i, want, typ)
+			return "", p.errWithPos(err, root.children[i].pos)
+		}
+	}
+
+	if name == "let" && isHigherOrder(p.conf, name) {
+		return types[2], nil
+	}
+	if _, declared := p.conf.OperatorSignatures[name]; !declared && isMaskOrLogic(name) {
+		typ, i := maskOrLogicResult(types)
+		if i >= 0 {
+			err := fmt.Errorf("type check error, %s param %d should be %s or %s, got: %s", name, i, TypeBool, TypeInt, types[i])
+			return "", p.errWithPos(err, root.children[i].pos)
+		}
+		return typ, nil
+	}
+	switch sig.Result {
+	case "":
+		return TypeAny, nil
+	case TypeNumber:
+		return numberResult(types), nil
+	}
+	return sig.Result, nil
+}
@@ -0,0 +1,143 @@
+package eval
+
+import (
+	"testing"
+)
+
+func TestTypeCheck(t *testing.T) {
+	cc := NewCompileConfig(EnableTypeCheck, EnableStringSelectors)
+	cc.SelectorTypes = map[string]Type{
+		"age":     TypeInt,
+		"name":    TypeString,
+		"vip":     TypeBool,
+		"tags":    TypeStrList,
+		"country": TypeString,
+	}
+	cc.OperatorSignatures["is_adult"] = Signature{Params: []Type{TypeInt}, Result: TypeBool}
+	assertNil(t, RegisterOperator(cc, "is_adult", func(_ *Ctx, params []Value) (Value, error) {
+		return params[0].(int64) >= 18, nil
+	}))
+
+	testCases := []struct {
+		expr   string
+		errMsg string
+	}{
+		{expr: `(and (> age 18) (= country "US"))`},
+		{expr: `(or vip (is_adult age) (in "a" tags))`},
+		{expr: `(+ age (if vip 1 0) 2)`},
+		{expr: `(matches name "^a")`},
+		// the undeclared selectors and operators are of type any
+		{expr: `(+ score 1)`},
+		{
+			expr:   `(add "a" 1)`,
+			errMsg: `type check error, add param 0 should be number, got: string occurs at  (add ["]a" 1)`,
+		},
+		{
+			expr:   `(and (> age 18) (+ age 1))`,
+			errMsg: `type check error, and param 1 should be bool, got: int64 occurs at  (and (> age 18) ([+] age 1))`,
+		},
+		{
+			expr:   `(> name 1)`,
+			errMsg: `type check error, > param 0 should be number, got: string`,
+		},
+		{
+			expr:   `(if age 1 2)`,
+			errMsg: `type check error, if condition should be bool, got: int64`,
+		},
+		{
+			expr:   `(+ (if vip 1 "a") 1)`,
+			errMsg: ``,
+		},
+		{
+			expr:   `(+ (if vip "b" "a") 1)`,
+			errMsg: `type check error, + param 0 should be number, got: string`,
+		},
+		{
+			expr:   `(is_adult name)`,
+			errMsg: `type check error, is_adult param 0 should be int64, got: string`,
+		},
+		{expr: `(is_adult (+ age 2))`},
+		{expr: `(> (* age 1.5) 20)`},
+		{
+			expr:   `(is_adult (* age 1.5))`,
+			errMsg: `type check error, is_adult param 0 should be int64, got: float64`,
+		},
+		{
+			expr:   `(not vip vip)`,
+			errMsg: `type check error, not parameters count error (signature: (bool) bool, got: 2)`,
+		},
+		{
+			expr:   `(and vip)`,
+			errMsg: `type check error, and parameters count error (signature: (bool, bool...) bool, got: 1)`,
+		},
+	}
+
+	for _, c := range testCases {
+		_, err := Compile(cc, c.expr)
+		if c.errMsg == "" {
+			assertNil(t, err, c.expr)
+		} else {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+		}
+	}
+
+	// the types are checked only if the TypeCheck option is enabled
+	conf := CopyCompileConfig(cc)
+	conf.CompileOptions[TypeCheck] = false
+	_, err := Compile(conf, `(+ (if vip "b" "a") 1)`)
+	assertNil(t, err)
+
+	_, err = Compile(conf, ";;;; type_check: true\n(+ (if vip \"b\" \"a\") 1)")
+	assertErrStrContains(t, err, "type check error")
+
+	// infix syntax
+	conf = CopyCompileConfig(cc)
+	conf.SyntaxMode = InfixSyntax
+	_, err = Compile(conf, `age > 18 && name + 1 > 2`)
+	assertErrStrContains(t, err, `type check error, add param 0 should be number, got: string occurs at  age > 18 && [n]ame + 1 > 2`)
+}
+
+func TestExpr_ReturnType(t *testing.T) {
+	cc := NewCompileConfig(EnableStringSelectors)
+	cc.SelectorTypes = map[string]Type{
+		"age":  TypeInt,
+		"name": TypeString,
+		"vip":  TypeBool,
+	}
+
+	testCases := []struct {
+		expr string
+		want Type
+	}{
+		{expr: `(and (> age 18) vip)`, want: TypeBool},
+		{expr: `(+ age 1)`, want: TypeInt},
+		{expr: `(* age 1.5)`, want: TypeFloat},
+		{expr: `name`, want: TypeString},
+		{expr: `(if vip "a" "b")`, want: TypeString},
+		{expr: `(if vip "a" 1)`, want: TypeAny},
+		{expr: `(list 1 2)`, want: TypeIntList},
+		// unknown
+		{expr: `(+ score 1)`, want: TypeAny},
+		{expr: `score`, want: TypeAny},
+		// ill-typed without the type checking
+		{expr: `(> name 1)`, want: TypeAny},
+	}
+
+	for _, c := range testCases {
+		expr, err := Compile(cc, c.expr)
+		assertNil(t, err)
+		if got := expr.ReturnType(); got != c.want {
+			t.Errorf("expr: %s, want: %s, got: %s", c.expr, c.want, got)
+		}
+
+		if c.want == TypeAny {
+			continue
+		}
+		// the inferred type is kept after the declared types are lost
+		partial, err := expr.PartialEval(nil)
+		assertNil(t, err)
+		if got := partial.ReturnType(); got != c.want {
+			t.Errorf("partial expr: %s, want: %s, got: %s", c.expr, c.want, got)
+		}
+	}
+}
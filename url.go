@@ -0,0 +1,120 @@
+package eval
+
+import (
+	"net/url"
+)
+
+// urlCacheSize is the number of the parsed URLs cached in a Ctx,
+// the rules usually refer to a few URLs, e.g. the request URL and the referrer
+const urlCacheSize = 4
+
+// urlCache caches the recently parsed URLs of an evaluation, so that the url operators
+// on the same URL, e.g. (urlHost referrer) and (urlPath referrer), parse it only once.
+// It's created for each evaluation of the expressions calling the url operators, see withURLCache,
+// so that the Ctx passed to Eval is not modified, and it can be shared by the concurrent evaluations.
+type urlCache struct {
+	raws [urlCacheSize]string
+	urls [urlCacheSize]*url.URL
+	next int
+}
+
+func (c *urlCache) parse(raw string) (*url.URL, error) {
+	for i, u := range c.urls {
+		if u != nil && c.raws[i] == raw {
+			return u, nil
+		}
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	c.raws[c.next], c.urls[c.next] = raw, u
+	c.next = (c.next + 1) % urlCacheSize
+	return u, nil
+}
+
+// urlOperators are the operators parsing the URLs with the cache of the evaluation
+var urlOperators = map[string]bool{
+	modeNames[urlScheme]:     true,
+	modeNames[urlHost]:       true,
+	modeNames[urlPath]:       true,
+	modeNames[urlQueryParam]: true,
+}
+
+// callsURLOperators reports whether any url operator is called by the expression
+func callsURLOperators(e *Expr) bool {
+	for _, n := range e.nodes {
+		if typ := n.getNodeType(); typ != operator && typ != fastOperator {
+			continue
+		}
+		if name, ok := n.value.(string); ok && urlOperators[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// withURLCache returns the ctx of an evaluation with a new URL cache,
+// the ctx of a lambda body shares the cache of the evaluation calling it
+func withURLCache(ctx *Ctx) *Ctx {
+	if ctx == nil || ctx.urls != nil {
+		return ctx
+	}
+	return &Ctx{Selector: ctx.Selector, Ctx: ctx.Ctx, urls: &urlCache{}}
+}
+
+// parseURL parses the URL with the cache of the evaluation, it's not cached if the operator
+// is executed at compile time, i.e. ctx is nil
+func parseURL(ctx *Ctx, raw string) (*url.URL, error) {
+	if ctx == nil || ctx.urls == nil {
+		return url.Parse(raw)
+	}
+	return ctx.urls.parse(raw)
+}
+
+// urlPart returns the part of the URL of the mode:
+//
+//	urlScheme: the lower case scheme, e.g. "https"
+//	urlHost: the host without the port, e.g. "example.com"
+//	urlPath: the unescaped path, e.g. "/a b"
+//	urlQueryParam: the first value of the query param, or "" if it's absent, e.g. (urlQueryParam url "utm_source")
+type urlPart struct {
+	mode mode
+}
+
+func (p urlPart) execute(ctx *Ctx, params []Value) (Value, error) {
+	op := modeNames[p.mode]
+	paramsCnt := 1
+	if p.mode == urlQueryParam {
+		paramsCnt = 2
+	}
+	if len(params) != paramsCnt {
+		return nil, ParamsCountError(op, paramsCnt, len(params))
+	}
+	strs := make([]string, paramsCnt)
+	for i, param := range params {
+		s, ok := param.(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, param)
+		}
+		strs[i] = s
+	}
+
+	u, err := parseURL(ctx, strs[0])
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+
+	switch p.mode {
+	case urlScheme:
+		return u.Scheme, nil
+	case urlHost:
+		return u.Hostname(), nil
+	case urlPath:
+		return u.Path, nil
+	case urlQueryParam:
+		// the query is not cached, since it's usually accessed once
+		return u.Query().Get(strs[1]), nil
+	}
+	return nil, errInvalidMode(p.mode, "url")
+}
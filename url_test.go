@@ -0,0 +1,118 @@
+package eval
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	vals := map[string]interface{}{
+		"url":      "HTTPS://Example.com:8443/a%20b/c?utm_source=news&tag=x&tag=y#top",
+		"referrer": "https://www.google.com/search?q=eval",
+		"relative": "/checkout?step=2",
+		"invalid":  "http://[::1",
+		"id":       7,
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []CompileOption
+		res    Value
+		errMsg string
+	}{
+		{expr: `(urlScheme url)`, res: "https"},
+		{expr: `(urlHost url)`, res: "Example.com"},
+		{expr: `(urlPath url)`, res: "/a b/c"},
+		{expr: `(urlQueryParam url "utm_source")`, res: "news"},
+		{expr: `(urlQueryParam url "tag")`, res: "x"},
+		{expr: `(urlQueryParam url "missing")`, res: ""},
+		{expr: `(urlHost "http://[::1]:80/")`, res: "::1"},
+		{expr: `(urlScheme relative)`, res: ""},
+		{expr: `(urlHost relative)`, res: ""},
+		{expr: `(urlPath relative)`, res: "/checkout"},
+		{expr: `(urlQueryParam relative "step")`, res: "2"},
+		{expr: `(and (= (urlHost referrer) "www.google.com") (= (urlQueryParam referrer "q") "eval"))`, res: true},
+		{expr: `(in (urlHost referrer) ("www.google.com" "www.bing.com"))`, res: true},
+		{expr: `(urlHost "https://example.com/")`, res: "example.com"},
+		{expr: `(urlQueryParam referrer "q")`, opts: []CompileOption{EnableTypeCheck}, res: "eval"},
+
+		{expr: `(urlHost invalid)`, errMsg: "operator: urlHost, error: parse"},
+		{expr: `(urlHost id)`, errMsg: "unexpected param type, operator: urlHost, expected: string"},
+		{expr: `(urlQueryParam url 1)`, errMsg: "unexpected param type, operator: urlQueryParam, expected: string"},
+		{expr: `(urlPath url "a")`, errMsg: "unexpected params count, operator: urlPath, expected: 1, got: 2"},
+		{expr: `(urlQueryParam url)`, errMsg: "unexpected params count, operator: urlQueryParam, expected: 2, got: 1"},
+		{expr: `(urlHost 1)`, opts: []CompileOption{EnableTypeCheck}, errMsg: "urlHost param 0 should be string, got: int"},
+	}
+
+	for _, c := range testCases {
+		cc := NewCompileConfig(append(c.opts, RegisterSelKeys(vals))...)
+		res, err := Eval(c.expr, vals, cc)
+		if c.errMsg != "" {
+			assertErrStrContains(t, err, c.errMsg, c.expr)
+			continue
+		}
+		assertNil(t, err, c.expr)
+		assertEquals(t, res, c.res, c.expr)
+	}
+}
+
+func TestURLCache(t *testing.T) {
+	c := &urlCache{}
+	raws := []string{"https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com"}
+	first, err := c.parse(raws[0])
+	assertNil(t, err)
+
+	// the cached URL is reused
+	u, err := c.parse(raws[0])
+	assertNil(t, err)
+	assertEquals(t, u == first, true)
+
+	// the oldest URL is evicted once the cache is full
+	for _, raw := range raws[1:] {
+		u, err = c.parse(raw)
+		assertNil(t, err)
+		assertEquals(t, u.Host, raw[len("https://"):])
+	}
+	u, err = c.parse(raws[0])
+	assertNil(t, err)
+	assertEquals(t, u == first, false)
+	assertEquals(t, u.Host, "a.com")
+
+	// the invalid URLs fail
+	_, err = c.parse("http://[::1")
+	assertErrStrContains(t, err, "missing ']' in host")
+}
+
+func TestURLConcurrentEval(t *testing.T) {
+	vals := map[string]interface{}{
+		"referrer": "https://www.google.com/search?q=eval",
+		"links":    []string{"https://a.com/x", "https://www.google.com/y"},
+	}
+
+	for _, backend := range []Backend{BytecodeBackend, ClosureBackend} {
+		cc := NewCompileConfig(RegisterSelKeys(vals))
+		cc.Backend = backend
+		expr, err := Compile(cc, `(and
+		  (= (urlHost referrer) "www.google.com")
+		  (= (urlPath referrer) "/search")
+		  (any links x (= (urlHost x) (urlHost referrer))))`)
+		assertNil(t, err)
+
+		// the read-only ctx is shared by the concurrent evaluations, the URLs are cached in each of them
+		ctx := NewCtxWithMap(cc, vals)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					res, err := expr.Eval(ctx)
+					assertNil(t, err)
+					assertEquals(t, res, true)
+				}
+			}()
+		}
+		wg.Wait()
+		assertEquals(t, ctx.urls == nil, true)
+	}
+}
@@ -1,11 +1,16 @@
 package eval
 
 import (
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
+
+	"github.com/larry618/eval/ast"
 )
 
 var empty = struct{}{}
@@ -365,15 +370,6 @@ func IndentByParentheses(s string) string {
 }
 
 func Dump(e *Expr) string {
-	var getNode = func(idx int) *node {
-		n := e.nodes[idx]
-		if n.getNodeType() != debug {
-			return n
-		}
-		offset := len(e.nodes) / 2
-		return e.nodes[idx+offset]
-	}
-
 	var helper func(*node) (string, bool)
 
 	helper = func(root *node) (string, bool) {
@@ -385,7 +381,7 @@ func Dump(e *Expr) string {
 		sb.WriteString(fmt.Sprintf("(%v", root.value))
 		for i := 0; i < int(root.childCnt); i++ {
 			childIdx := int(root.childIdx) + i
-			child := getNode(childIdx)
+			child := e.getNode(childIdx)
 			if child.getNodeType() == end {
 				continue
 			}
@@ -403,10 +399,169 @@ func Dump(e *Expr) string {
 		return sb.String(), false
 	}
 
-	res, _ := helper(getNode(0))
+	res, _ := helper(e.getNode(0))
 	return res
 }
 
+// Decompile reconstructs the expression in the prefix notation from the compiled nodes.
+// The result is the optimized version of the original expression, and it can be compiled again.
+func (e *Expr) Decompile() string {
+	return e.decompileNode(e.getNode(0))
+}
+
+// decompileNode reconstructs the subexpression rooted at the node
+func (e *Expr) decompileNode(root *node) string {
+	var sb strings.Builder
+
+	var helper func(n *node)
+	helper = func(n *node) {
+		switch n.getNodeType() {
+		case constant:
+			sb.WriteString(decompileValue(n.value))
+			return
+		case selector:
+			sb.WriteString(fmt.Sprint(n.value))
+			return
+		}
+
+		if n.value == "let" && n.childCnt == 3 {
+			// the let binding is written as (let (x value) body)
+			if l, ok := e.getNode(int(n.childIdx) + 2).value.(*lambda); ok {
+				sb.WriteString("(let (" + l.param + " ")
+				helper(e.getNode(int(n.childIdx)))
+				sb.WriteString(") " + l.String() + ")")
+				return
+			}
+		}
+
+		sb.WriteString(fmt.Sprintf("(%v", n.value))
+		for i := 0; i < int(n.childCnt); i++ {
+			child := e.getNode(int(n.childIdx) + i)
+			if child.getNodeType() == end {
+				continue
+			}
+			sb.WriteRune(' ')
+			helper(child)
+		}
+		sb.WriteRune(')')
+	}
+
+	helper(root)
+	return sb.String()
+}
+
+// String returns the decompiled expression
+func (e *Expr) String() string {
+	return e.Decompile()
+}
+
+// ConvertJSON converts the value decoded from JSON with json.Decoder.UseNumber to the value types of the operators,
+// the integers are converted to int64, the other numbers to float64,
+// and the arrays of integers or strings to []int64 or []string.
+func ConvertJSON(v interface{}) Value {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case []interface{}:
+		if len(val) == 0 {
+			return []string{}
+		}
+		ints := make([]int64, 0, len(val))
+		strs := make([]string, 0, len(val))
+		for _, item := range val {
+			switch c := ConvertJSON(item).(type) {
+			case int64:
+				ints = append(ints, c)
+			case string:
+				strs = append(strs, c)
+			}
+		}
+		switch len(val) {
+		case len(ints):
+			return ints
+		case len(strs):
+			return strs
+		}
+		res := make([]interface{}, len(val))
+		for i, item := range val {
+			res[i] = ConvertJSON(item)
+		}
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			res[k] = ConvertJSON(item)
+		}
+		return res
+	}
+	return v
+}
+
+// FormatValue formats the value as a literal in the prefix notation, e.g. ("a" "b")
+func FormatValue(val Value) string {
+	return decompileValue(val)
+}
+
+func decompileValue(val Value) string {
+	var sb strings.Builder
+	switch v := val.(type) {
+	case nil:
+		sb.WriteString("null")
+	case string:
+		sb.WriteString(ast.QuoteString(v))
+	case []string:
+		sb.WriteRune('(')
+		for i, s := range v {
+			if i != 0 {
+				sb.WriteRune(' ')
+			}
+			sb.WriteString(ast.QuoteString(s))
+		}
+		sb.WriteRune(')')
+	case []int64:
+		sb.WriteRune('(')
+		for i, n := range v {
+			if i != 0 {
+				sb.WriteRune(' ')
+			}
+			sb.WriteString(strconv.FormatInt(n, 10))
+		}
+		sb.WriteRune(')')
+	case []Value:
+		// the list of the mixed types is built by the list operator, e.g. (list 1.5 "a")
+		sb.WriteString("(list")
+		for _, e := range v {
+			sb.WriteString(" " + decompileValue(e))
+		}
+		sb.WriteRune(')')
+	case float64:
+		sb.WriteString(ast.FormatFloat(v))
+	case Decimal:
+		sb.WriteString(v.String() + "d")
+	case *big.Int:
+		sb.WriteString(v.String())
+	case map[string]Value:
+		// the keys are sorted, so that the result is deterministic
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sb.WriteString("(dict")
+		for _, k := range keys {
+			sb.WriteString(fmt.Sprintf(` %s %s`, ast.QuoteString(k), decompileValue(v[k])))
+		}
+		sb.WriteRune(')')
+	default:
+		sb.WriteString(fmt.Sprint(v))
+	}
+	return sb.String()
+}
+
 func dumpLeafNode(node *node) (string, bool) {
 	switch node.getNodeType() {
 	case debug:
@@ -421,6 +576,8 @@ func dumpLeafNode(node *node) (string, bool) {
 
 	var res string
 	switch v := node.value.(type) {
+	case nil:
+		res = "null"
 	case string:
 		res = strconv.Quote(v)
 	case []string:
@@ -445,6 +602,8 @@ func dumpLeafNode(node *node) (string, bool) {
 		}
 		sb.WriteRune(')')
 		res = sb.String()
+	case []Value, map[string]Value:
+		res = decompileValue(v)
 	default:
 		res = fmt.Sprint(v)
 	}
@@ -459,6 +618,14 @@ func max(a, b int) int {
 	}
 }
 
+func min(a, b int) int {
+	if a < b {
+		return a
+	} else {
+		return b
+	}
+}
+
 func maxInt16(a, b int16) int16 {
 	if a > b {
 		return a
@@ -572,3 +739,16 @@ func PrintExpr(expr *Expr, fields ...string) string {
 
 	return sb.String()
 }
+
+// isIdentName reports whether s is an identifier, i.e. the letters, digits and underscores not starting with a digit
+func isIdentName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && (i == 0 || !('0' <= c && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
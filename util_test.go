@@ -143,6 +143,75 @@ func TestPrintCode(t *testing.T) {
   (overlap tags ("bbb" "aaa")))`)
 }
 
+func TestDecompile(t *testing.T) {
+	cc := &CompileConfig{
+		SelectorMap: map[string]SelectorKey{
+			"age":    SelectorKey(1),
+			"gender": SelectorKey(2),
+			"tags":   SelectorKey(3),
+			"ok":     SelectorKey(4),
+		},
+		OperatorMap: map[string]Operator{
+			"now": func(_ *Ctx, _ []Value) (Value, error) {
+				return time.Now().Unix(), nil
+			},
+		},
+	}
+
+	testCases := []struct {
+		expr string
+		want string
+		opts []CompileOption
+	}{
+		{
+			expr: `(+ 1 1)`,
+			want: `2`,
+		},
+		{
+			expr: `
+;; comment
+(and
+  (between age 18 80) ;; age
+  (= gender "male")
+  (overlap tags ("a" "b"))
+  (in age (1 -2 3)))`,
+			want: `(and (between age 18 80) (= gender "male") (overlap tags ("a" "b")) (in age (1 -2 3)))`,
+			opts: []CompileOption{Optimizations(false)},
+		},
+		{
+			expr: `(if ok (< (now) (+ 1 2)) (not ok))`,
+			want: `(if ok (< (now) 3) (not ok))`,
+		},
+		{
+			expr: `(if ok (< (now) (+ 1 2)) (not ok))`,
+			want: `(if ok (< (now) 3) (not ok))`,
+			opts: []CompileOption{EnableDebug},
+		},
+		{
+			expr: `(in "" ())`,
+			want: `(in "" ())`,
+			opts: []CompileOption{Optimizations(false)},
+		},
+	}
+
+	for _, c := range testCases {
+		conf := CopyCompileConfig(cc)
+		for _, opt := range c.opts {
+			opt(conf)
+		}
+
+		expr, err := Compile(conf, c.expr)
+		assertNil(t, err, c)
+		assertEquals(t, expr.Decompile(), c.want, c)
+		assertEquals(t, expr.String(), c.want, c)
+
+		// the decompiled expression can be compiled again
+		again, err := Compile(conf, c.want)
+		assertNil(t, err, c)
+		assertEquals(t, again.Decompile(), c.want, c)
+	}
+}
+
 func TestGenerateRandomExpr_Bool(t *testing.T) {
 	const size = 50
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -316,3 +385,50 @@ func TestGenerateTestCase(t *testing.T) {
 		assertEquals(t, got, c.want)
 	}
 }
+
+func TestDecompile_Strings(t *testing.T) {
+	testCases := []struct {
+		expr string
+		want string
+		opts []CompileOption
+	}{
+		{
+			expr: `name = 'say "hi"'`,
+			want: "(eq name `say \"hi\"`)",
+			opts: []CompileOption{EnableSQLSyntax},
+		},
+		{
+			expr: "(= name `\"000`)",
+			want: "(= name `\"000`)",
+		},
+		{
+			expr: "(= name \"a`b${c}\")",
+			want: "(= name \"a`b${c}\")",
+		},
+		{
+			expr: "name = '100% \"x\" `y`'",
+			want: "(eq name (format \"100%% %sx%s `y`\" `\"` `\"`))",
+			opts: []CompileOption{EnableSQLSyntax},
+		},
+		{
+			expr: `(in name ("a" "b"))`,
+			want: `(in name ("a" "b"))`,
+		},
+		{
+			expr: `{'"a"': 1, "b": 2}[name]`,
+			want: "(index (dict `\"a\"` 1 \"b\" 2) name)",
+			opts: []CompileOption{EnableCELSyntax},
+		},
+	}
+
+	for _, c := range testCases {
+		expr, err := Compile(NewCompileConfig(append(c.opts, EnableStringSelectors)...), c.expr)
+		assertNil(t, err, c)
+		assertEquals(t, expr.Decompile(), c.want, c)
+
+		// the decompiled expression is compiled to the same one
+		again, err := Compile(NewCompileConfig(EnableStringSelectors), c.want)
+		assertNil(t, err, c)
+		assertEquals(t, again.Decompile(), c.want, c)
+	}
+}
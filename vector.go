@@ -0,0 +1,332 @@
+package eval
+
+import "fmt"
+
+// EvalVectors evaluates the expression against the rows of the columnar values like EvalColumns,
+// but the columns are keyed by the SelectorKeys, so the selectors should be registered.
+// The number of rows is the length of the longest column, reading a row beyond the length
+// of a shorter column results in an error of that row, and a missing column is handled by
+// the missing selector policy of the expression.
+//
+// Each node is evaluated across all the rows in a tight loop before its parent,
+// the rows decided by short circuit or failed are excluded from the rest siblings, and
+// the branches of if are evaluated for the rows taking them only. The operators are called
+// with the Ctx whose Selector reads the current row.
+//
+// The expressions with the EvalHook, the MaxSteps or in the debug mode
+// are evaluated row by row instead.
+func (e *Expr) EvalVectors(columns map[SelectorKey][]Value) ([]Value, []error) {
+	var rows int
+	for _, col := range columns {
+		rows = max(rows, len(col))
+	}
+
+	var (
+		sel  = &rowSelector{columns: columns}
+		ctx  = &Ctx{Selector: sel}
+		res  = make([]Value, rows)
+		errs = make([]error, rows)
+	)
+
+	isDebug := len(e.nodes) != 0 && e.nodes[0].getNodeType() == debug
+	if e.hook != nil || e.maxSteps > 0 || isDebug {
+		size := max(int(e.maxStackSize), 8)
+		os, sf := make([]Value, size), make([]int16, size)
+		for i := 0; i < rows; i++ {
+			sel.row = i
+			res[i], errs[i] = e.evalWithStacks(ctx, os, sf)
+		}
+		return res, errs
+	}
+
+	all := make([]int, rows)
+	for i := range all {
+		all[i] = i
+	}
+	v := &vectorEvaluator{e: e, sel: sel, ctx: ctx, errs: errs}
+	col, _ := v.eval(e.nodes[0], all)
+	for i := 0; i < rows; i++ {
+		if errs[i] == nil {
+			res[i], errs[i] = checkMissingResult(col.at(i), nil)
+		}
+	}
+	return res, errs
+}
+
+// rowSelector gets the values of the current row from the columns
+type rowSelector struct {
+	columns map[SelectorKey][]Value
+	row     int
+}
+
+func (s *rowSelector) Get(key SelectorKey, _ string) (Value, error) {
+	col, exist := s.columns[key]
+	if !exist {
+		return nil, fmt.Errorf("%w %d", ErrSelectorNotExist, key)
+	}
+	if s.row >= len(col) {
+		return nil, fmt.Errorf("column %d out of range, row: %d, length: %d", key, s.row, len(col))
+	}
+	return col[s.row], nil
+}
+
+func (s *rowSelector) Set(key SelectorKey, _ string, val Value) error {
+	col, exist := s.columns[key]
+	if !exist || s.row >= len(col) {
+		return fmt.Errorf("column %d out of range, row: %d", key, s.row)
+	}
+	col[s.row] = val
+	return nil
+}
+
+func (s *rowSelector) Cached(key SelectorKey, _ string) bool {
+	col, exist := s.columns[key]
+	return exist && s.row < len(col)
+}
+
+// column is the values of a node indexed by the rows, only the evaluated rows are valid
+type column struct {
+	vals []Value
+	// constant is the value of all the rows if vals is nil
+	constant Value
+	// the vals are allocated by the evaluator, which can be reused once the column is consumed
+	owned bool
+}
+
+func (c column) at(row int) Value {
+	if c.vals == nil {
+		return c.constant
+	}
+	return c.vals[row]
+}
+
+// vectorEvaluator evaluates the nodes across the rows,
+// the errors of the rows are written to errs once they fail.
+type vectorEvaluator struct {
+	e    *Expr
+	sel  *rowSelector
+	ctx  *Ctx
+	errs []error
+	// the vals of the consumed columns
+	free [][]Value
+}
+
+func (v *vectorEvaluator) alloc() column {
+	if n := len(v.free); n > 0 {
+		vals := v.free[n-1]
+		v.free = v.free[:n-1]
+		return column{vals: vals, owned: true}
+	}
+	return column{vals: make([]Value, len(v.errs)), owned: true}
+}
+
+func (v *vectorEvaluator) release(c column) {
+	if c.owned {
+		v.free = append(v.free, c.vals)
+	}
+}
+
+// eval evaluates the node for the given rows, the rows which haven't failed are returned
+func (v *vectorEvaluator) eval(n *node, rows []int) (column, []int) {
+	switch n.getNodeType() {
+	case constant:
+		return column{constant: n.value}, rows
+	case selector:
+		return v.evalSelector(n, rows)
+	case cond:
+		return v.evalCond(n, rows)
+	}
+
+	if isBoolOpNode(n) {
+		return v.evalBoolOp(n, rows)
+	}
+
+	children := v.children(n)
+	params := make([]column, len(children))
+	for i, child := range children {
+		params[i], rows = v.eval(child, rows)
+	}
+
+	var (
+		res = v.alloc()
+		ok  = make([]int, 0, len(rows))
+		// the params are reused across the rows
+		p = make([]Value, len(children))
+	)
+	for _, r := range rows {
+		for i, param := range params {
+			p[i] = param.at(r)
+		}
+		if val, err := v.execute(n, r, p); err == nil {
+			res.vals[r] = val
+			ok = append(ok, r)
+		}
+	}
+	for _, param := range params {
+		v.release(param)
+	}
+	return res, ok
+}
+
+func (v *vectorEvaluator) children(n *node) []*node {
+	children := make([]*node, 0, n.childCnt)
+	for i := int16(0); i < int16(n.childCnt); i++ {
+		if child := v.e.nodes[n.childIdx+i]; child.getNodeType() != end {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// execute executes the operator of the node for the row, the error is recorded
+func (v *vectorEvaluator) execute(n *node, row int, params []Value) (Value, error) {
+	v.sel.row = row
+	res, err := n.operator(v.ctx, params)
+	if err != nil {
+		err = fmt.Errorf("operator execution error, operator: %v, error: %w", n.value, err)
+		v.errs[row] = err
+	}
+	return res, err
+}
+
+// evalSelector reads the column of the selector,
+// which is used as it is if all the rows are in range and of the operand types
+func (v *vectorEvaluator) evalSelector(n *node, rows []int) (column, []int) {
+	name := n.value.(string)
+	col, exist := v.sel.columns[n.selKey]
+	if exist && isUnifiedColumn(col, rows) {
+		return column{vals: col}, rows
+	}
+
+	var (
+		res = v.alloc()
+		ok  = make([]int, 0, len(rows))
+	)
+	for _, r := range rows {
+		var (
+			val Value
+			err error
+		)
+		switch {
+		case !exist:
+			val, err = v.e.missingSelectorValue(name, fmt.Errorf("%w %d", ErrSelectorNotExist, n.selKey))
+		case r >= len(col):
+			err = fmt.Errorf("column %d out of range, row: %d, length: %d", n.selKey, r, len(col))
+		default:
+			val = unifySelectorValue(col[r])
+		}
+		if err != nil {
+			v.errs[r] = err
+			continue
+		}
+		res.vals[r] = val
+		ok = append(ok, r)
+	}
+	return res, ok
+}
+
+func isUnifiedColumn(col []Value, rows []int) bool {
+	for _, r := range rows {
+		if r >= len(col) {
+			return false
+		}
+		switch col[r].(type) {
+		case bool, string, int64, float64, []int64, []string:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// evalCond splits the rows by the condition, and evaluates each branch for its rows
+func (v *vectorEvaluator) evalCond(n *node, rows []int) (column, []int) {
+	children := v.children(n)
+	condCol, rows := v.eval(children[0], rows)
+
+	var then, otherwise []int
+	for _, r := range rows {
+		switch b, isBool := condCol.at(r).(bool); {
+		case !isBool:
+			v.errs[r] = condTypeError(condCol.at(r))
+		case b:
+			then = append(then, r)
+		default:
+			otherwise = append(otherwise, r)
+		}
+	}
+	v.release(condCol)
+
+	var (
+		res = v.alloc()
+		ok  = make([]int, 0, len(rows))
+	)
+	for i, branchRows := range [][]int{then, otherwise} {
+		if len(branchRows) == 0 {
+			continue
+		}
+		branchCol, branchRows := v.eval(children[i+1], branchRows)
+		for _, r := range branchRows {
+			res.vals[r] = branchCol.at(r)
+		}
+		v.release(branchCol)
+		ok = append(ok, branchRows...)
+	}
+	return res, ok
+}
+
+// evalBoolOp follows the same rules as boolOpClosure, the rows decided by an operand
+// are excluded from the rest operands, and the operator is executed for the rows
+// whose operands are not all bool values.
+func (v *vectorEvaluator) evalBoolOp(n *node, rows []int) (column, []int) {
+	var (
+		children = v.children(n)
+		last     = len(children) - 1
+		isAnd    = isAndOpNode(n)
+		isCustom = isCustomBoolOpNode(n)
+
+		res     = v.alloc()
+		ok      = make([]int, 0, len(rows))
+		pending = rows
+		params  map[int][]Value
+	)
+
+	for i, child := range children {
+		childCol, childRows := v.eval(child, pending)
+		pending = make([]int, 0, len(childRows))
+		for _, r := range childRows {
+			val := childCol.at(r)
+			if b, isBool := val.(bool); isBool {
+				if (i == last && !isCustom) || b != isAnd {
+					res.vals[r] = b
+					ok = append(ok, r)
+					continue
+				}
+				if params[r] == nil {
+					pending = append(pending, r)
+					continue
+				}
+			} else if params[r] == nil {
+				// the previous operands are all bool values not deciding the result
+				if params == nil {
+					params = make(map[int][]Value)
+				}
+				params[r] = boolParams(len(children), isAnd)
+			}
+			params[r][i] = val
+			pending = append(pending, r)
+		}
+		v.release(childCol)
+	}
+
+	for _, r := range pending {
+		if params[r] == nil {
+			params[r] = boolParams(len(children), isAnd)
+		}
+		if val, err := v.execute(n, r, params[r]); err == nil {
+			res.vals[r] = val
+			ok = append(ok, r)
+		}
+	}
+	return res, ok
+}
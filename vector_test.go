@@ -0,0 +1,122 @@
+package eval
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpr_EvalVectors(t *testing.T) {
+	columns := map[string][]Value{
+		"age":     {20, 16, int64(30), "old", 70, 40},
+		"country": {"US", "CA", "CN", "US", "UK"},
+		"score":   {1, 2, 3, 4, 5, 6},
+	}
+
+	testCases := []struct {
+		expr string
+		opts []CompileOption
+	}{
+		{expr: `(+ age score 1)`},
+		{expr: `(and (> age 18) score)`},
+		{expr: `(if (> age 18) (+ score 10) score)`},
+		{expr: `(and (> age 18) (in country ("US" "UK")))`},
+		{expr: `(or (= country "US") (and (> age 18) (< score 5)))`},
+		{expr: `(if (in country ("US" "CA")) (* age 2) (- 0 score))`},
+		{expr: `(and (> age 18) (= tier "gold"))`, opts: []CompileOption{OnMissingSelector(MissingSelectorFalse)}},
+		{expr: `(= tier "gold")`, opts: []CompileOption{SelectorDefault("tier", "gold")}},
+		{expr: `(if (> age 18) tier "none")`},
+		{expr: `(and (> age 18) (< score 5))`, opts: []CompileOption{LimitSteps(100)}},
+		{expr: `(or (> age 60) (< score 3))`, opts: []CompileOption{
+			func(c *CompileConfig) { c.Backend = ClosureBackend },
+		}},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			cc := NewCompileConfig(append(c.opts, RegisterSelKeys(map[string]interface{}{
+				"age": 0, "country": "", "score": 0, "tier": "",
+			}))...)
+			expr, err := Compile(cc, c.expr)
+			assertNil(t, err)
+
+			vectors := make(map[SelectorKey][]Value)
+			for name, col := range columns {
+				vectors[cc.SelectorMap[name]] = col
+			}
+			res, errs := expr.EvalVectors(vectors)
+			assertEquals(t, len(res), 6)
+
+			// same as the row by row evaluation
+			for i := range res {
+				row := make(map[string]interface{})
+				for name, col := range columns {
+					if i < len(col) {
+						row[name] = col[i]
+					}
+				}
+				want, wantErr := expr.Eval(NewCtxWithMap(cc, row))
+				assertEquals(t, res[i], want, i)
+				assertEquals(t, errs[i] == nil, wantErr == nil, i, errs[i], wantErr)
+			}
+		})
+	}
+}
+
+func TestExpr_EvalVectors_Errors(t *testing.T) {
+	cc := NewCompileConfig(RegisterSelKeys(map[string]interface{}{"age": 0, "score": 0}))
+	expr, err := Compile(cc, `(if (> age 18) (+ score 10) score)`)
+	assertNil(t, err)
+	age, score := cc.SelectorMap["age"], cc.SelectorMap["score"]
+
+	res, errs := expr.EvalVectors(map[SelectorKey][]Value{
+		age:   {20, 16, int64(30), 40},
+		score: {1, 2, 3},
+	})
+	assertEquals(t, res[:3], []Value{int64(11), int64(2), int64(13)})
+	assertEquals(t, errs[:3], []error{nil, nil, nil})
+	assertErrStrContains(t, errs[3], "out of range, row: 3, length: 3")
+
+	_, errs = expr.EvalVectors(map[SelectorKey][]Value{age: {20}})
+	assertEquals(t, errors.Is(errs[0], ErrSelectorNotExist), true)
+
+	_, errs = expr.EvalVectors(map[SelectorKey][]Value{age: {"20"}, score: {1}})
+	assertErrStrContains(t, errs[0], "operator execution error, operator: >")
+}
+
+func BenchmarkEvalVectors(b *testing.B) {
+	const (
+		exprStr = `(and (> age 18) (= country "US") (< (+ age 5) 100))`
+		rows    = 1024
+	)
+	columns := map[string][]Value{
+		"age":     make([]Value, rows),
+		"country": make([]Value, rows),
+	}
+	for i := 0; i < rows; i++ {
+		columns["age"][i] = int64(i % 100)
+		columns["country"][i] = []string{"US", "CA"}[i%2]
+	}
+
+	cc := NewCompileConfig(RegisterSelKeys(map[string]interface{}{"age": 0, "country": ""}))
+	expr, err := Compile(cc, exprStr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	vectors := map[SelectorKey][]Value{
+		cc.SelectorMap["age"]:     columns["age"],
+		cc.SelectorMap["country"]: columns["country"],
+	}
+
+	b.Run("columns", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = expr.EvalColumns(columns)
+		}
+	})
+	b.Run("vectors", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = expr.EvalVectors(vectors)
+		}
+	})
+}